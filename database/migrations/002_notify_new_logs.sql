@@ -0,0 +1,27 @@
+-- Announce every stored log entry on the new_logs channel so live-tail
+-- subscribers and alerting consumers don't have to poll the logs table.
+CREATE OR REPLACE FUNCTION notify_new_log() RETURNS trigger AS $$
+DECLARE
+    payload TEXT;
+BEGIN
+    payload := json_build_object(
+        'id', NEW.id,
+        'level', NEW.level,
+        'message', NEW.message,
+        'timestamp', NEW.timestamp,
+        'source', NEW.source
+    )::text;
+
+    -- NOTIFY payloads are limited to 8000 bytes; announce large rows by id only
+    IF octet_length(payload) > 7900 THEN
+        payload := json_build_object('id', NEW.id, 'truncated', true)::text;
+    END IF;
+
+    PERFORM pg_notify('new_logs', payload);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER logs_notify_new_log
+    AFTER INSERT ON logs
+    FOR EACH ROW EXECUTE FUNCTION notify_new_log();
@@ -0,0 +1,15 @@
+-- Hourly per-source, per-level summaries that replace aged raw log rows
+CREATE TABLE log_rollups (
+    id SERIAL PRIMARY KEY,
+    bucket TIMESTAMPTZ NOT NULL,
+    source VARCHAR(255) NOT NULL,
+    level VARCHAR(10) NOT NULL,
+    count BIGINT NOT NULL,
+    first_seen TIMESTAMPTZ NOT NULL,
+    last_seen TIMESTAMPTZ NOT NULL,
+    sample_message TEXT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (bucket, source, level)
+);
+
+CREATE INDEX idx_log_rollups_bucket ON log_rollups (bucket);
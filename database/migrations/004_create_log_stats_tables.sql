@@ -0,0 +1,18 @@
+-- Per-minute counts by source and level, maintained incrementally from the
+-- logs table so aggregate queries never group-by over raw rows.
+CREATE TABLE log_stats_minute (
+    bucket TIMESTAMPTZ NOT NULL,
+    source VARCHAR(255) NOT NULL,
+    level VARCHAR(10) NOT NULL,
+    count BIGINT NOT NULL,
+    PRIMARY KEY (bucket, source, level)
+);
+
+-- High-water mark of the last logs.id folded into log_stats_minute
+CREATE TABLE log_stats_watermark (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    last_log_id BIGINT NOT NULL DEFAULT 0,
+    refreshed_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO log_stats_watermark (last_log_id) VALUES (0);
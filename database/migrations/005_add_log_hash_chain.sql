@@ -0,0 +1,27 @@
+-- Tamper-evident integrity mode: each chained entry stores the hash of the
+-- previous chained entry and a hash over itself plus that previous hash.
+ALTER TABLE logs ADD COLUMN prev_hash CHAR(64);
+ALTER TABLE logs ADD COLUMN entry_hash CHAR(64);
+
+CREATE INDEX idx_logs_chained ON logs (id) WHERE entry_hash IS NOT NULL;
+
+-- Tail of the chain; locked by every chained insert to serialize appends
+CREATE TABLE log_chain_head (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    last_log_id BIGINT NOT NULL DEFAULT 0,
+    last_hash CHAR(64) NOT NULL DEFAULT repeat('0', 64)
+);
+
+INSERT INTO log_chain_head DEFAULT VALUES;
+
+-- Chained entries are append-only
+CREATE OR REPLACE FUNCTION reject_chained_log_change() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'log entry % is part of the integrity chain and cannot be modified', OLD.id;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER logs_chain_append_only
+    BEFORE UPDATE OR DELETE ON logs
+    FOR EACH ROW WHEN (OLD.entry_hash IS NOT NULL)
+    EXECUTE FUNCTION reject_chained_log_change();
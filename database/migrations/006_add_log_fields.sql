@@ -0,0 +1,28 @@
+-- Structured attributes produced by the processing pipeline
+ALTER TABLE logs ADD COLUMN fields JSONB;
+
+CREATE INDEX idx_logs_fields ON logs USING GIN (fields);
+
+-- Include structured fields in new log notifications
+CREATE OR REPLACE FUNCTION notify_new_log() RETURNS trigger AS $$
+DECLARE
+    payload TEXT;
+BEGIN
+    payload := json_build_object(
+        'id', NEW.id,
+        'level', NEW.level,
+        'message', NEW.message,
+        'timestamp', NEW.timestamp,
+        'source', NEW.source,
+        'fields', NEW.fields
+    )::text;
+
+    -- NOTIFY payloads are limited to 8000 bytes; announce large rows by id only
+    IF octet_length(payload) > 7900 THEN
+        payload := json_build_object('id', NEW.id, 'truncated', true)::text;
+    END IF;
+
+    PERFORM pg_notify('new_logs', payload);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,14 @@
+-- Dead-letter queue: submissions that failed parsing, validation, the
+-- processing pipeline or storage, kept with their error for replay
+CREATE TABLE dead_letters (
+    id BIGSERIAL PRIMARY KEY,
+    received_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    reason VARCHAR(20) NOT NULL,
+    error TEXT NOT NULL,
+    source VARCHAR(100),
+    payload TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    replayed_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_dead_letters_pending ON dead_letters (reason, id) WHERE replayed_at IS NULL;
@@ -0,0 +1,18 @@
+-- Per-session summaries of entries sharing a trace or session ID, written
+-- by the correlate pipeline stage. An ID seen again after its session
+-- closed starts a new row.
+CREATE TABLE sessions (
+    session_id VARCHAR(255) NOT NULL,
+    started_at TIMESTAMPTZ NOT NULL,
+    ended_at TIMESTAMPTZ NOT NULL,
+    entry_count BIGINT NOT NULL,
+    error_count BIGINT NOT NULL,
+    levels JSONB NOT NULL DEFAULT '{}',
+    sources TEXT[] NOT NULL DEFAULT '{}',
+    status VARCHAR(10) NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (session_id, started_at)
+);
+
+CREATE INDEX idx_sessions_started_at ON sessions (started_at DESC);
+CREATE INDEX idx_sessions_sources ON sessions USING GIN (sources);
@@ -0,0 +1,15 @@
+-- API keys clients authenticate with. Only the SHA-256 hash of a key is
+-- stored; the prefix identifies it in listings. Scopes grant ingest, read
+-- or admin access, sources (when not empty) restrict the sources a key may
+-- write, and rate_limit caps its requests per minute (0 for no limit).
+CREATE TABLE api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    sources TEXT[] NOT NULL DEFAULT '{}',
+    rate_limit INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMPTZ
+);
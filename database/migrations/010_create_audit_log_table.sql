@@ -0,0 +1,37 @@
+-- Audit trail of administrative actions: who (the credential type and its
+-- holder) did what, to which resource, from where and with what outcome.
+-- Records are immutable: updates, deletes and truncation are rejected.
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    occurred_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    actor_type VARCHAR(20) NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    action VARCHAR(100) NOT NULL,
+    resource VARCHAR(255),
+    method VARCHAR(10),
+    path TEXT,
+    status INTEGER,
+    request_id VARCHAR(100),
+    client_ip VARCHAR(45),
+    details JSONB
+);
+
+CREATE INDEX idx_audit_log_occurred_at ON audit_log (occurred_at DESC);
+CREATE INDEX idx_audit_log_actor ON audit_log (actor, occurred_at DESC);
+CREATE INDEX idx_audit_log_action ON audit_log (action, occurred_at DESC);
+
+CREATE OR REPLACE FUNCTION reject_audit_log_change() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit log records cannot be modified or removed';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW
+    EXECUTE FUNCTION reject_audit_log_change();
+
+CREATE TRIGGER audit_log_no_truncate
+    BEFORE TRUNCATE ON audit_log
+    FOR EACH STATEMENT
+    EXECUTE FUNCTION reject_audit_log_change();
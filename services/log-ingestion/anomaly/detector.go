@@ -0,0 +1,315 @@
+package anomaly
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/models"
+	"log-processing-system/services/log-ingestion/pubsub"
+)
+
+// AllLevels is the level of the per-source series that counts every level
+const AllLevels = "*"
+
+// maxAlerts bounds the in-memory alert history
+const maxAlerts = 500
+
+// AlertType classifies a volume anomaly
+type AlertType string
+
+const (
+	AlertSpike   AlertType = "spike"
+	AlertDrop    AlertType = "drop"
+	AlertSilence AlertType = "silence"
+)
+
+// Config tunes the detector
+type Config struct {
+	Window         time.Duration // length of a counting window
+	Alpha          float64       // EWMA smoothing factor, 0 < alpha <= 1
+	Threshold      float64       // deviations from the baseline that count as anomalous
+	WarmupWindows  int           // windows observed before a series can alert
+	SilenceWindows int           // consecutive empty windows before a silence alert
+	MinRate        float64       // baseline entries per window below which drops and silence are ignored
+	ExpireAfter    time.Duration // forget series that have been silent this long
+}
+
+// Alert describes one anomalous window for a source/level series
+type Alert struct {
+	Type        AlertType `json:"type"`
+	Source      string    `json:"source"`
+	Level       string    `json:"level"`
+	Observed    int64     `json:"observed"`
+	Expected    float64   `json:"expected"`
+	StdDev      float64   `json:"stddev"`
+	WindowStart time.Time `json:"window_start"`
+	WindowEnd   time.Time `json:"window_end"`
+	DetectedAt  time.Time `json:"detected_at"`
+}
+
+// Baseline is the learned rate of one series
+type Baseline struct {
+	Source      string    `json:"source"`
+	Level       string    `json:"level"`
+	Mean        float64   `json:"mean"`
+	StdDev      float64   `json:"stddev"`
+	Windows     int       `json:"windows"`
+	EmptyStreak int       `json:"empty_streak"`
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+type seriesKey struct {
+	source string
+	level  string
+}
+
+type series struct {
+	mean        float64
+	variance    float64
+	windows     int
+	emptyStreak int
+	silenced    bool
+	lastSeen    time.Time
+}
+
+// stddev is floored at the Poisson deviation so that very regular series
+// do not alert on a difference of one or two entries
+func (s *series) stddev() float64 {
+	return math.Max(math.Sqrt(s.variance), math.Max(math.Sqrt(s.mean), 1))
+}
+
+// Detector learns per source/level ingest rates with an exponentially
+// weighted moving average and variance, and raises alerts when a window's
+// count deviates sharply from the baseline or a series stops logging.
+type Detector struct {
+	cfg    Config
+	logger *logger.Logger
+
+	mu          sync.Mutex
+	counts      map[seriesKey]int64
+	series      map[seriesKey]*series
+	windowStart time.Time
+	alerts      []Alert
+}
+
+// NewDetector creates a detector; zero config values fall back to defaults
+func NewDetector(cfg Config, log *logger.Logger) *Detector {
+	if cfg.Window <= 0 {
+		cfg.Window = time.Minute
+	}
+	if cfg.Alpha <= 0 || cfg.Alpha > 1 {
+		cfg.Alpha = 0.1
+	}
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = 4
+	}
+	if cfg.SilenceWindows <= 0 {
+		cfg.SilenceWindows = 5
+	}
+	if cfg.ExpireAfter <= 0 {
+		cfg.ExpireAfter = 24 * time.Hour
+	}
+
+	return &Detector{
+		cfg:         cfg,
+		logger:      log,
+		counts:      make(map[seriesKey]int64),
+		series:      make(map[seriesKey]*series),
+		windowStart: time.Now(),
+	}
+}
+
+// Start counts entries published on the hub and evaluates each window until
+// ctx is cancelled
+func (d *Detector) Start(ctx context.Context, hub *pubsub.Hub) {
+	sub := hub.Subscribe(pubsub.DefaultBufferSize*4, nil)
+	defer sub.Close()
+
+	d.logger.WithFields(map[string]interface{}{
+		"window":    d.cfg.Window.String(),
+		"alpha":     d.cfg.Alpha,
+		"threshold": d.cfg.Threshold,
+	}).Info("Log volume anomaly detector started")
+
+	ticker := time.NewTicker(d.cfg.Window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			d.logger.Info("Log volume anomaly detector stopped")
+			return
+		case entry, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			d.Observe(entry)
+		case now := <-ticker.C:
+			d.closeWindow(now)
+		}
+	}
+}
+
+// Observe counts one entry in the current window
+func (d *Detector) Observe(entry models.Log) {
+	level := strings.ToLower(entry.Level)
+
+	d.mu.Lock()
+	d.counts[seriesKey{entry.Source, level}]++
+	d.counts[seriesKey{entry.Source, AllLevels}]++
+	d.mu.Unlock()
+}
+
+// closeWindow folds the finished window into the baselines and returns the
+// alerts it raised
+func (d *Detector) closeWindow(now time.Time) []Alert {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	start := d.windowStart
+	d.windowStart = now
+
+	var raised []Alert
+	for key := range d.counts {
+		if _, ok := d.series[key]; !ok {
+			d.series[key] = &series{}
+		}
+	}
+
+	for key, s := range d.series {
+		count := d.counts[key]
+
+		if count == 0 && now.Sub(s.lastSeen) > d.cfg.ExpireAfter {
+			delete(d.series, key)
+			continue
+		}
+
+		if alert, ok := d.evaluate(key, s, count); ok {
+			alert.WindowStart = start
+			alert.WindowEnd = now
+			alert.DetectedAt = now
+			raised = append(raised, alert)
+		}
+		if count > 0 {
+			s.lastSeen = now
+		}
+	}
+	d.counts = make(map[seriesKey]int64)
+
+	sort.Slice(raised, func(i, j int) bool {
+		if raised[i].Source != raised[j].Source {
+			return raised[i].Source < raised[j].Source
+		}
+		return raised[i].Level < raised[j].Level
+	})
+
+	for _, alert := range raised {
+		d.logger.WithFields(map[string]interface{}{
+			"anomaly_type": string(alert.Type),
+			"source":       alert.Source,
+			"level":        alert.Level,
+			"observed":     alert.Observed,
+			"expected":     math.Round(alert.Expected*100) / 100,
+			"window_start": alert.WindowStart,
+		}).Warn("Log volume anomaly detected")
+	}
+
+	d.alerts = append(d.alerts, raised...)
+	if len(d.alerts) > maxAlerts {
+		d.alerts = append([]Alert(nil), d.alerts[len(d.alerts)-maxAlerts:]...)
+	}
+
+	return raised
+}
+
+// evaluate checks one window's count against the baseline, then updates it.
+// Empty windows do not update the baseline so that a silent series keeps
+// its expected rate; its streak drives silence alerts instead.
+func (d *Detector) evaluate(key seriesKey, s *series, count int64) (Alert, bool) {
+	alert := Alert{
+		Source:   key.source,
+		Level:    key.level,
+		Observed: count,
+		Expected: s.mean,
+		StdDev:   s.stddev(),
+	}
+	warm := s.windows >= d.cfg.WarmupWindows
+	established := warm && s.mean >= d.cfg.MinRate
+
+	if count == 0 {
+		s.emptyStreak++
+		if established && !s.silenced && s.emptyStreak >= d.cfg.SilenceWindows {
+			s.silenced = true
+			alert.Type = AlertSilence
+			return alert, true
+		}
+		return alert, false
+	}
+
+	s.emptyStreak = 0
+	s.silenced = false
+
+	x := float64(count)
+	deviation := (x - s.mean) / s.stddev()
+	switch {
+	case warm && deviation >= d.cfg.Threshold:
+		alert.Type = AlertSpike
+	case established && deviation <= -d.cfg.Threshold:
+		alert.Type = AlertDrop
+	}
+
+	if s.windows == 0 {
+		s.mean = x
+	} else {
+		diff := x - s.mean
+		s.mean += d.cfg.Alpha * diff
+		s.variance = (1 - d.cfg.Alpha) * (s.variance + d.cfg.Alpha*diff*diff)
+	}
+	s.windows++
+
+	return alert, alert.Type != ""
+}
+
+// Alerts returns the alerts detected after since, oldest first
+func (d *Detector) Alerts(since time.Time) []Alert {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	alerts := []Alert{}
+	for _, alert := range d.alerts {
+		if alert.DetectedAt.After(since) {
+			alerts = append(alerts, alert)
+		}
+	}
+	return alerts
+}
+
+// Baselines returns the learned baseline of every tracked series
+func (d *Detector) Baselines() []Baseline {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	baselines := make([]Baseline, 0, len(d.series))
+	for key, s := range d.series {
+		baselines = append(baselines, Baseline{
+			Source:      key.source,
+			Level:       key.level,
+			Mean:        s.mean,
+			StdDev:      s.stddev(),
+			Windows:     s.windows,
+			EmptyStreak: s.emptyStreak,
+			LastSeen:    s.lastSeen,
+		})
+	}
+	sort.Slice(baselines, func(i, j int) bool {
+		if baselines[i].Source != baselines[j].Source {
+			return baselines[i].Source < baselines[j].Source
+		}
+		return baselines[i].Level < baselines[j].Level
+	})
+	return baselines
+}
@@ -0,0 +1,161 @@
+package anomaly
+
+import (
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func testDetector() *Detector {
+	log := logger.New(logger.Config{Level: "ERROR", Service: "test-service", Component: "anomaly"})
+	return NewDetector(Config{
+		Window:         time.Minute,
+		Alpha:          0.2,
+		Threshold:      4,
+		WarmupWindows:  5,
+		SilenceWindows: 3,
+		MinRate:        5,
+	}, log)
+}
+
+// window feeds count entries from source at level and closes the window
+func window(d *Detector, now time.Time, source, level string, count int) []Alert {
+	for i := 0; i < count; i++ {
+		d.Observe(models.Log{Source: source, Level: level})
+	}
+	return d.closeWindow(now)
+}
+
+func TestDetector_Spike(t *testing.T) {
+	d := testDetector()
+	now := time.Now()
+
+	for i := 0; i < 10; i++ {
+		now = now.Add(time.Minute)
+		if alerts := window(d, now, "api", "error", 20); len(alerts) != 0 {
+			t.Fatalf("Expected no alerts while learning, got %+v", alerts)
+		}
+	}
+
+	now = now.Add(time.Minute)
+	alerts := window(d, now, "api", "error", 200)
+	if len(alerts) != 2 {
+		t.Fatalf("Expected spike alerts for api/error and api/*, got %+v", alerts)
+	}
+	for _, alert := range alerts {
+		if alert.Type != AlertSpike || alert.Observed != 200 {
+			t.Errorf("Expected spike with 200 observed, got %+v", alert)
+		}
+	}
+	if alerts[0].Level != AllLevels || alerts[1].Level != "error" {
+		t.Errorf("Expected alerts sorted by level, got %s and %s", alerts[0].Level, alerts[1].Level)
+	}
+}
+
+func TestDetector_NoAlertDuringWarmup(t *testing.T) {
+	d := testDetector()
+	now := time.Now()
+
+	window(d, now.Add(time.Minute), "api", "info", 10)
+	if alerts := window(d, now.Add(2*time.Minute), "api", "info", 500); len(alerts) != 0 {
+		t.Errorf("Expected no alerts before warmup, got %+v", alerts)
+	}
+}
+
+func TestDetector_Drop(t *testing.T) {
+	d := testDetector()
+	now := time.Now()
+
+	for i := 0; i < 10; i++ {
+		now = now.Add(time.Minute)
+		window(d, now, "worker", "info", 400)
+	}
+
+	now = now.Add(time.Minute)
+	alerts := window(d, now, "worker", "info", 20)
+	if len(alerts) == 0 || alerts[0].Type != AlertDrop {
+		t.Fatalf("Expected drop alert, got %+v", alerts)
+	}
+}
+
+func TestDetector_Silence(t *testing.T) {
+	d := testDetector()
+	now := time.Now()
+
+	for i := 0; i < 10; i++ {
+		now = now.Add(time.Minute)
+		window(d, now, "billing", "info", 30)
+	}
+
+	var silence []Alert
+	for i := 0; i < 5; i++ {
+		now = now.Add(time.Minute)
+		silence = append(silence, d.closeWindow(now)...)
+	}
+	if len(silence) != 2 {
+		t.Fatalf("Expected one silence alert per series, got %+v", silence)
+	}
+	for _, alert := range silence {
+		if alert.Type != AlertSilence || alert.Expected < 29 {
+			t.Errorf("Expected silence alert keeping the baseline, got %+v", alert)
+		}
+	}
+
+	// Recovery re-arms the silence alert
+	now = now.Add(time.Minute)
+	window(d, now, "billing", "info", 30)
+	for _, b := range d.Baselines() {
+		if b.EmptyStreak != 0 {
+			t.Errorf("Expected empty streak reset for %s/%s, got %d", b.Source, b.Level, b.EmptyStreak)
+		}
+	}
+}
+
+func TestDetector_LowVolumeSeriesDoNotAlertOnSilence(t *testing.T) {
+	d := testDetector()
+	now := time.Now()
+
+	for i := 0; i < 10; i++ {
+		now = now.Add(time.Minute)
+		window(d, now, "cron", "info", 1)
+	}
+	for i := 0; i < 5; i++ {
+		now = now.Add(time.Minute)
+		if alerts := d.closeWindow(now); len(alerts) != 0 {
+			t.Errorf("Expected no alerts below min rate, got %+v", alerts)
+		}
+	}
+}
+
+func TestDetector_ExpiresSilentSeries(t *testing.T) {
+	d := testDetector()
+	now := time.Now()
+
+	window(d, now, "old", "info", 3)
+	d.closeWindow(now.Add(25 * time.Hour))
+
+	if baselines := d.Baselines(); len(baselines) != 0 {
+		t.Errorf("Expected silent series to expire, got %+v", baselines)
+	}
+}
+
+func TestDetector_Alerts(t *testing.T) {
+	d := testDetector()
+	start := time.Now()
+	now := start
+
+	for i := 0; i < 10; i++ {
+		now = now.Add(time.Minute)
+		window(d, now, "api", "warn", 10)
+	}
+	now = now.Add(time.Minute)
+	window(d, now, "api", "warn", 500)
+
+	if alerts := d.Alerts(start); len(alerts) != 2 {
+		t.Errorf("Expected 2 alerts, got %d", len(alerts))
+	}
+	if alerts := d.Alerts(now); len(alerts) != 0 {
+		t.Errorf("Expected no alerts after the last window, got %d", len(alerts))
+	}
+}
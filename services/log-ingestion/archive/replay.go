@@ -0,0 +1,262 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/models"
+
+	"github.com/google/uuid"
+)
+
+// Replay job states
+const (
+	ReplayJobRunning   = "running"
+	ReplayJobCompleted = "completed"
+	ReplayJobFailed    = "failed"
+)
+
+// ErrEmptyReplay is returned when a replay would re-ingest the whole archive
+var ErrEmptyReplay = errors.New("archive replay requires a prefix or at least one filter criterion")
+
+// ErrStopped is returned when a replay is requested after Stop, and ends
+// the replays Stop interrupted
+var ErrStopped = errors.New("archive replay stopped by shutdown")
+
+// ErrRejected marks an entry the pipeline or validation rejected; the
+// replay counts it and moves on
+var ErrRejected = errors.New("archived entry rejected")
+
+// IngestFunc runs an archived entry through the current pipeline and stores
+// the result. It returns an error wrapping ErrRejected for an entry that is
+// rejected, and any other error if ingestion cannot continue.
+type IngestFunc func(ctx context.Context, entry *models.Log) error
+
+// ReplayRequest selects the archived entries to replay: the objects whose
+// keys start with Prefix and, within them, the entries matching the filter
+type ReplayRequest struct {
+	Prefix string `json:"prefix"`
+	models.LogFilter
+}
+
+// ReplayJob reports the progress of an archive replay
+type ReplayJob struct {
+	ID          string           `json:"id"`
+	Prefix      string           `json:"prefix"`
+	Filter      models.LogFilter `json:"filter"`
+	Status      string           `json:"status"`
+	Objects     int              `json:"objects"`
+	ObjectsRead int              `json:"objects_read"`
+	Read        int64            `json:"read"`
+	Replayed    int64            `json:"replayed"`
+	Skipped     int64            `json:"skipped"`
+	Rejected    int64            `json:"rejected"`
+	Error       string           `json:"error,omitempty"`
+	StartedAt   time.Time        `json:"started_at"`
+	FinishedAt  *time.Time       `json:"finished_at,omitempty"`
+}
+
+// Replayer re-ingests archived entries in the background, e.g. to apply new
+// parsing rules retroactively or to rebuild data after a schema change
+type Replayer struct {
+	source Source
+	ingest IngestFunc
+	logger *logger.Logger
+
+	mu   sync.RWMutex
+	jobs map[string]*ReplayJob
+
+	// Closed by Stop; running jobs finish their current object and end
+	stop     chan struct{}
+	stopOnce sync.Once
+	running  sync.WaitGroup
+}
+
+// NewReplayer creates a replayer reading from source
+func NewReplayer(source Source, ingest IngestFunc, log *logger.Logger) *Replayer {
+	return &Replayer{
+		source: source,
+		ingest: ingest,
+		logger: log,
+		jobs:   make(map[string]*ReplayJob),
+		stop:   make(chan struct{}),
+	}
+}
+
+// Start validates the request and launches a background replay job
+func (r *Replayer) Start(req ReplayRequest) (ReplayJob, error) {
+	if req.Prefix == "" && req.LogFilter.IsEmpty() {
+		return ReplayJob{}, ErrEmptyReplay
+	}
+	if err := req.LogFilter.Validate(); err != nil {
+		return ReplayJob{}, err
+	}
+
+	job := &ReplayJob{
+		ID:        uuid.New().String(),
+		Prefix:    req.Prefix,
+		Filter:    req.LogFilter,
+		Status:    ReplayJobRunning,
+		StartedAt: time.Now().UTC(),
+	}
+
+	r.mu.Lock()
+	select {
+	case <-r.stop:
+		r.mu.Unlock()
+		return ReplayJob{}, ErrStopped
+	default:
+	}
+	r.jobs[job.ID] = job
+	snapshot := *job
+	// Under the lock, so Stop cannot miss the job
+	r.running.Add(1)
+	r.mu.Unlock()
+
+	r.logger.WithFields(map[string]interface{}{
+		"job_id": job.ID,
+		"prefix": job.Prefix,
+		"filter": job.Filter,
+	}).Info("Archive replay started")
+
+	go r.run(job)
+
+	return snapshot, nil
+}
+
+// Get returns a snapshot of the job with the given id
+func (r *Replayer) Get(id string) (ReplayJob, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	job, ok := r.jobs[id]
+	if !ok {
+		return ReplayJob{}, false
+	}
+	return *job, true
+}
+
+// Stop interrupts running replays once they have ingested the archive
+// object they are reading, and waits for them until ctx is done. A stopped
+// replay fails with ErrStopped; its objects_read tells how far it got.
+func (r *Replayer) Stop(ctx context.Context) error {
+	r.stopOnce.Do(func() {
+		r.mu.Lock()
+		close(r.stop)
+		r.mu.Unlock()
+	})
+
+	done := make(chan struct{})
+	go func() {
+		r.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Replayer) run(job *ReplayJob) {
+	defer r.running.Done()
+	ctx := context.Background()
+
+	keys, err := r.source.List(ctx, job.Prefix)
+	r.mu.Lock()
+	if err != nil {
+		r.finish(job, ReplayJobFailed, err)
+		r.mu.Unlock()
+		return
+	}
+	job.Objects = len(keys)
+	r.mu.Unlock()
+
+	for _, key := range keys {
+		select {
+		case <-r.stop:
+			r.mu.Lock()
+			r.finish(job, ReplayJobFailed, ErrStopped)
+			r.mu.Unlock()
+			return
+		default:
+		}
+		if err := r.replayObject(ctx, job, key); err != nil {
+			r.mu.Lock()
+			r.finish(job, ReplayJobFailed, err)
+			r.mu.Unlock()
+			return
+		}
+		r.mu.Lock()
+		job.ObjectsRead++
+		r.mu.Unlock()
+	}
+
+	r.mu.Lock()
+	r.finish(job, ReplayJobCompleted, nil)
+	r.mu.Unlock()
+}
+
+// replayObject ingests the matching entries of one archive object
+func (r *Replayer) replayObject(ctx context.Context, job *ReplayJob, key string) error {
+	body, err := r.source.Open(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	return ReadEntries(body, key, func(entry models.Log) error {
+		if !job.Filter.Matches(entry) {
+			r.count(&job.Read, &job.Skipped)
+			return nil
+		}
+		// Archived entries carry the ID they were stored under
+		entry.ID = 0
+
+		err := r.ingest(ctx, &entry)
+		switch {
+		case err == nil:
+			r.count(&job.Read, &job.Replayed)
+		case errors.Is(err, ErrRejected):
+			r.count(&job.Read, &job.Rejected)
+		default:
+			return err
+		}
+		return nil
+	})
+}
+
+// count increments job counters under the lock
+func (r *Replayer) count(counters ...*int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range counters {
+		*c++
+	}
+}
+
+// finish marks the job as done; callers must hold r.mu
+func (r *Replayer) finish(job *ReplayJob, status string, err error) {
+	now := time.Now().UTC()
+	job.Status = status
+	job.FinishedAt = &now
+
+	fields := map[string]interface{}{
+		"job_id":       job.ID,
+		"objects_read": job.ObjectsRead,
+		"read":         job.Read,
+		"replayed":     job.Replayed,
+		"rejected":     job.Rejected,
+		"duration_ms":  now.Sub(job.StartedAt).Milliseconds(),
+	}
+
+	if err != nil {
+		job.Error = err.Error()
+		r.logger.WithFields(fields).WithError(err).Error("Archive replay failed")
+		return
+	}
+	r.logger.WithFields(fields).Info("Archive replay completed")
+}
@@ -0,0 +1,227 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+// writeArchive writes a gzipped NDJSON object below root
+func writeArchive(t *testing.T, root, key string, lines ...string) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	for _, line := range lines {
+		zw.Write([]byte(line + "\n"))
+	}
+	zw.Close()
+
+	path := filepath.Join(root, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create archive dir: %v", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+}
+
+func waitForJob(t *testing.T, replayer *Replayer, id string) ReplayJob {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		job, ok := replayer.Get(id)
+		if !ok {
+			t.Fatalf("Expected job %s to exist", id)
+		}
+		if job.Status != ReplayJobRunning {
+			return job
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Job %s did not finish in time", id)
+	return ReplayJob{}
+}
+
+func newTestReplayer(root string, ingest IngestFunc) *Replayer {
+	return NewReplayer(NewDirSource(root), ingest, logger.New(logger.Config{Service: "test-service", Component: "archive"}))
+}
+
+func TestReplayer_ReplaysMatchingEntries(t *testing.T) {
+	root := t.TempDir()
+	writeArchive(t, root, "logs/dt=2024-01-01/hour=10/1-a.ndjson.gz",
+		`{"id":7,"timestamp":"2024-01-01T10:00:00Z","level":"error","message":"boom","source":"api"}`,
+		`{"id":8,"timestamp":"2024-01-01T10:01:00Z","level":"info","message":"ok","source":"api"}`,
+		``,
+		`{"id":9,"timestamp":"2024-01-01T10:02:00Z","level":"ERROR","message":"bad","source":"api"}`)
+	writeArchive(t, root, "logs/dt=2024-01-02/hour=10/2-b.ndjson.gz",
+		`{"id":10,"timestamp":"2024-01-02T10:00:00Z","level":"error","message":"later","source":"api"}`)
+
+	var mu sync.Mutex
+	var replayed []models.Log
+	replayer := newTestReplayer(root, func(ctx context.Context, entry *models.Log) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if entry.Message == "bad" {
+			return ErrRejected
+		}
+		replayed = append(replayed, *entry)
+		return nil
+	})
+
+	job, err := replayer.Start(ReplayRequest{Prefix: "logs/dt=2024-01-01/", LogFilter: models.LogFilter{Level: "error"}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	job = waitForJob(t, replayer, job.ID)
+	if job.Status != ReplayJobCompleted {
+		t.Fatalf("Expected status completed, got %s (%s)", job.Status, job.Error)
+	}
+	if job.Objects != 1 || job.ObjectsRead != 1 {
+		t.Errorf("Expected 1 object read, got %d of %d", job.ObjectsRead, job.Objects)
+	}
+	if job.Read != 3 || job.Replayed != 1 || job.Skipped != 1 || job.Rejected != 1 {
+		t.Errorf("Unexpected counters: %+v", job)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(replayed) != 1 || replayed[0].Message != "boom" {
+		t.Fatalf("Expected the boom entry to be replayed, got %+v", replayed)
+	}
+	if replayed[0].ID != 0 {
+		t.Errorf("Expected the archived ID to be cleared, got %d", replayed[0].ID)
+	}
+}
+
+func TestReplayer_FailsOnIngestError(t *testing.T) {
+	root := t.TempDir()
+	writeArchive(t, root, "logs/1.ndjson.gz",
+		`{"timestamp":"2024-01-01T10:00:00Z","level":"info","message":"a","source":"api"}`,
+		`{"timestamp":"2024-01-01T10:00:01Z","level":"info","message":"b","source":"api"}`)
+
+	replayer := newTestReplayer(root, func(ctx context.Context, entry *models.Log) error {
+		return errors.New("database unavailable")
+	})
+
+	job, _ := replayer.Start(ReplayRequest{Prefix: "logs/"})
+	job = waitForJob(t, replayer, job.ID)
+	if job.Status != ReplayJobFailed {
+		t.Errorf("Expected status failed, got %s", job.Status)
+	}
+	if job.Error != "database unavailable" || job.Read != 0 {
+		t.Errorf("Expected the job to stop at the first entry, got %+v", job)
+	}
+}
+
+func TestReplayer_FailsOnCorruptObject(t *testing.T) {
+	root := t.TempDir()
+	writeArchive(t, root, "logs/1.ndjson.gz", `{"message":`)
+
+	replayer := newTestReplayer(root, func(ctx context.Context, entry *models.Log) error { return nil })
+
+	job, _ := replayer.Start(ReplayRequest{Prefix: "logs/"})
+	job = waitForJob(t, replayer, job.ID)
+	if job.Status != ReplayJobFailed || job.Error == "" {
+		t.Errorf("Expected the corrupt object to fail the job, got %+v", job)
+	}
+}
+
+func TestReplayer_Stop(t *testing.T) {
+	root := t.TempDir()
+	writeArchive(t, root, "logs/1.ndjson.gz",
+		`{"timestamp":"2024-01-01T10:00:00Z","level":"info","message":"a","source":"api"}`,
+		`{"timestamp":"2024-01-01T10:00:01Z","level":"info","message":"b","source":"api"}`)
+	writeArchive(t, root, "logs/2.ndjson.gz",
+		`{"timestamp":"2024-01-01T11:00:00Z","level":"info","message":"c","source":"api"}`)
+
+	// The first entry holds the replay until Stop has been called
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var replayed int
+	replayer := newTestReplayer(root, func(ctx context.Context, entry *models.Log) error {
+		if entry.Message == "a" {
+			close(started)
+			<-release
+		}
+		replayed++
+		return nil
+	})
+
+	job, err := replayer.Start(ReplayRequest{Prefix: "logs/"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	<-started
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- replayer.Stop(context.Background()) }()
+	// Stop waits for the object being read
+	select {
+	case err := <-stopped:
+		t.Fatalf("Expected Stop to wait for the running replay, got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	if err := <-stopped; err != nil {
+		t.Fatalf("Expected Stop to succeed, got %v", err)
+	}
+
+	job, _ = replayer.Get(job.ID)
+	if job.Status != ReplayJobFailed || job.Error != ErrStopped.Error() {
+		t.Errorf("Expected the replay to be stopped, got %+v", job)
+	}
+	if job.ObjectsRead != 1 || replayed != 2 {
+		t.Errorf("Expected the first object to be finished and the second skipped, got %d objects, %d entries", job.ObjectsRead, replayed)
+	}
+
+	if _, err := replayer.Start(ReplayRequest{Prefix: "logs/"}); !errors.Is(err, ErrStopped) {
+		t.Errorf("Expected ErrStopped after Stop, got %v", err)
+	}
+}
+
+func TestReplayer_StopDeadline(t *testing.T) {
+	root := t.TempDir()
+	writeArchive(t, root, "logs/1.ndjson.gz",
+		`{"timestamp":"2024-01-01T10:00:00Z","level":"info","message":"a","source":"api"}`)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	replayer := newTestReplayer(root, func(ctx context.Context, entry *models.Log) error {
+		close(started)
+		<-release
+		return nil
+	})
+	replayer.Start(ReplayRequest{Prefix: "logs/"})
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := replayer.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline to end the wait, got %v", err)
+	}
+}
+
+func TestReplayer_RejectsInvalidRequests(t *testing.T) {
+	replayer := newTestReplayer(t.TempDir(), nil)
+
+	if _, err := replayer.Start(ReplayRequest{}); !errors.Is(err, ErrEmptyReplay) {
+		t.Errorf("Expected ErrEmptyReplay, got %v", err)
+	}
+	if _, err := replayer.Start(ReplayRequest{Prefix: "logs/", LogFilter: models.LogFilter{Level: "loud"}}); err == nil {
+		t.Errorf("Expected an invalid level to be rejected")
+	}
+}
+
+func TestDirSource_RejectsEscapingKeys(t *testing.T) {
+	if _, err := NewDirSource(t.TempDir()).Open(context.Background(), "../secret.ndjson"); err == nil {
+		t.Errorf("Expected a key outside the root to be rejected")
+	}
+}
@@ -0,0 +1,184 @@
+// Package archive reads log archives written by the s3 output sink, or
+// copied from it to a directory, and replays them through ingestion.
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"log-processing-system/services/log-ingestion/models"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Source lists and opens archive objects by key
+type Source interface {
+	List(ctx context.Context, prefix string) ([]string, error)
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// Pinger is implemented by sources that can check the archive is reachable
+// without reading it
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+type s3Source struct {
+	client *s3.Client
+	bucket string
+}
+
+// NewS3Source reads archives from an S3 bucket. Credentials come from the
+// environment, shared config or instance role; endpoint addresses an
+// S3-compatible store such as MinIO.
+func NewS3Source(bucket, region, endpoint string) (Source, error) {
+	var opts []func(*config.LoadOptions) error
+	if region != "" {
+		opts = append(opts, config.WithRegion(region))
+	}
+	awsCfg, err := config.LoadDefaultConfig(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if endpoint != "" {
+			o.EndpointResolver = s3.EndpointResolverFromURL(endpoint)
+			o.UsePathStyle = true
+		}
+	})
+	return &s3Source{client: client, bucket: bucket}, nil
+}
+
+func (s *s3Source) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range page.Contents {
+			keys = append(keys, aws.ToString(object.Key))
+		}
+	}
+	return keys, nil
+}
+
+func (s *s3Source) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+func (s *s3Source) Ping(ctx context.Context) error {
+	_, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(s.bucket)})
+	return err
+}
+
+type dirSource struct {
+	root string
+}
+
+// NewDirSource reads archives from a directory, such as a cold tier mount,
+// with keys being slash-separated paths below it
+func NewDirSource(root string) Source {
+	return &dirSource{root: root}
+}
+
+func (s *dirSource) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := filepath.Walk(s.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(s.root, path)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	sort.Strings(keys)
+	return keys, err
+}
+
+func (s *dirSource) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	path := filepath.Join(s.root, filepath.FromSlash(key))
+	// Keys come from requests, so they must not leave the archive root
+	if rel, err := filepath.Rel(s.root, path); err != nil || strings.HasPrefix(rel, "..") {
+		return nil, fmt.Errorf("invalid archive key %q", key)
+	}
+	return os.Open(path)
+}
+
+func (s *dirSource) Ping(ctx context.Context) error {
+	info, err := os.Stat(s.root)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", s.root)
+	}
+	return nil
+}
+
+// maxLineSize bounds a single archived entry
+const maxLineSize = 1024 * 1024
+
+// ReadEntries decodes an NDJSON archive object, decompressing it by the
+// key's extension (.gz or .zst), and calls fn for every entry until fn
+// returns an error
+func ReadEntries(r io.Reader, key string, fn func(models.Log) error) error {
+	switch {
+	case strings.HasSuffix(key, ".gz"):
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	case strings.HasSuffix(key, ".zst"):
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var entry models.Log
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return fmt.Errorf("%s line %d: %w", key, line, err)
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,145 @@
+// Package audit records who performed administrative actions, such as
+// creating API keys, deleting logs or changing the log level, in an
+// append-only audit trail.
+package audit
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"log-processing-system/services/log-ingestion/auth"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/middleware"
+	"log-processing-system/services/log-ingestion/models"
+
+	"github.com/gorilla/mux"
+)
+
+// actions names the administrative routes by method and path template;
+// other routes are recorded as "<method> <template>"
+var actions = map[string]string{
+	"POST /admin/api-keys":                 "api_key.create",
+	"DELETE /admin/api-keys/{id}":          "api_key.revoke",
+	"POST /admin/logs/delete":              "logs.delete",
+	"POST /admin/dead-letters/replay":      "dead_letters.replay",
+	"DELETE /admin/dead-letters/{id}":      "dead_letter.delete",
+	"POST /admin/dead-letters/{id}/replay": "dead_letter.replay",
+	"POST /admin/archive/replay":           "archive.replay",
+	"PUT /admin/log-level":                 "log_level.set",
+}
+
+// Recorder writes audit events to a store
+type Recorder struct {
+	store  Store
+	logger *logger.Logger
+	now    func() time.Time
+}
+
+// NewRecorder creates a recorder writing to store
+func NewRecorder(store Store, log *logger.Logger) *Recorder {
+	return &Recorder{store: store, logger: log, now: time.Now}
+}
+
+// Record stores an event, stamped with the current time unless it has
+// one. A failure is logged, not returned: the action it records has
+// already happened.
+func (rec *Recorder) Record(ctx context.Context, event models.AuditEvent) {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = rec.now().UTC()
+	}
+	if event.RequestID == "" {
+		event.RequestID = logger.GetRequestID(ctx)
+	}
+
+	fields := map[string]interface{}{
+		"audit_action": event.Action,
+		"actor_type":   event.ActorType,
+		"actor":        event.Actor,
+		"resource":     event.Resource,
+		"status":       event.Status,
+	}
+	if _, err := rec.store.Add(event); err != nil {
+		fields["error"] = err.Error()
+		rec.logger.WithFields(fields).ErrorContext(ctx, "Failed to record audit event")
+		return
+	}
+	rec.logger.WithFields(fields).InfoContext(ctx, "Audit event recorded")
+}
+
+// List returns the stored events matching filter, newest first
+func (rec *Recorder) List(filter models.AuditFilter) ([]models.AuditEvent, error) {
+	return rec.store.List(filter)
+}
+
+// Middleware records every request that changes state under /admin/, once
+// the handler has answered, with the authenticated actor, the outcome and
+// any details the handler added with Annotate. It goes after the
+// authentication middleware; requests it rejects are not recorded.
+func (rec *Recorder) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/admin/") || r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		notes := &annotations{details: make(models.Fields)}
+		sw := middleware.NewStatusWriter(w)
+		next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), annotationsKey{}, notes)))
+
+		actorType, actor := auth.Identity(r.Context())
+		event := models.AuditEvent{
+			ActorType: actorType,
+			Actor:     actor,
+			Action:    action(r),
+			Resource:  mux.Vars(r)["id"],
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			Status:    sw.Status(),
+			ClientIP:  middleware.ClientIP(r),
+		}
+		notes.mu.Lock()
+		if len(notes.details) > 0 {
+			event.Details = notes.details
+		}
+		notes.mu.Unlock()
+		rec.Record(r.Context(), event)
+	})
+}
+
+// action names the action a request performs
+func action(r *http.Request) string {
+	path := r.URL.Path
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			path = template
+		}
+	}
+	key := r.Method + " " + path
+	if name, ok := actions[key]; ok {
+		return name
+	}
+	return key
+}
+
+type annotationsKey struct{}
+
+// annotations collects the details handlers add to a request's event
+type annotations struct {
+	mu      sync.Mutex
+	details models.Fields
+}
+
+// Annotate adds a detail to the audit event of the request ctx belongs
+// to, such as the id of the resource an action created. It does nothing
+// for requests that are not audited.
+func Annotate(ctx context.Context, key string, value interface{}) {
+	notes, ok := ctx.Value(annotationsKey{}).(*annotations)
+	if !ok {
+		return
+	}
+	notes.mu.Lock()
+	notes.details[key] = value
+	notes.mu.Unlock()
+}
@@ -0,0 +1,134 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/auth"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/models"
+
+	"github.com/gorilla/mux"
+)
+
+// memoryStore is an append-only Store for tests
+type memoryStore struct {
+	mu     sync.Mutex
+	events []models.AuditEvent
+	err    error
+}
+
+func (s *memoryStore) Add(event models.AuditEvent) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return 0, s.err
+	}
+	event.ID = int64(len(s.events) + 1)
+	s.events = append(s.events, event)
+	return event.ID, nil
+}
+
+func (s *memoryStore) List(filter models.AuditFilter) ([]models.AuditEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.AuditEvent(nil), s.events...), nil
+}
+
+func newTestRouter(store Store) *mux.Router {
+	log := logger.New(logger.Config{Service: "test-service", Component: "audit"})
+	authenticator := auth.NewAuthenticator(nil, auth.Config{AdminKey: "admin-secret"}, log)
+	rec := NewRecorder(store, log)
+	rec.now = func() time.Time { return time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC) }
+
+	router := mux.NewRouter()
+	router.Use(authenticator.Middleware)
+	router.Use(rec.Middleware)
+	router.HandleFunc("/admin/api-keys", func(w http.ResponseWriter, r *http.Request) {
+		Annotate(r.Context(), "key_id", 7)
+		w.WriteHeader(http.StatusCreated)
+	}).Methods("POST", "GET")
+	router.HandleFunc("/admin/api-keys/{id}", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "API key not found", http.StatusNotFound)
+	}).Methods("DELETE")
+	router.HandleFunc("/admin/reindex", func(w http.ResponseWriter, r *http.Request) {}).Methods("POST")
+	router.HandleFunc("/logs", func(w http.ResponseWriter, r *http.Request) {}).Methods("POST")
+	return router
+}
+
+func send(router http.Handler, method, path string) int {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("X-API-Key", "admin-secret")
+	req.RemoteAddr = "203.0.113.7:51234"
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestMiddleware_RecordsAdminActions(t *testing.T) {
+	store := &memoryStore{}
+	router := newTestRouter(store)
+
+	if status := send(router, "POST", "/admin/api-keys"); status != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", status)
+	}
+	send(router, "DELETE", "/admin/api-keys/42")
+	send(router, "POST", "/admin/reindex")
+
+	if len(store.events) != 3 {
+		t.Fatalf("Expected 3 events, got %d: %+v", len(store.events), store.events)
+	}
+
+	created := store.events[0]
+	if created.Action != "api_key.create" || created.ActorType != models.ActorAdminKey || created.Actor != "admin" {
+		t.Errorf("Unexpected event %+v", created)
+	}
+	if created.Status != http.StatusCreated || created.ClientIP != "203.0.113.7" || created.Method != "POST" || created.Path != "/admin/api-keys" {
+		t.Errorf("Unexpected request details %+v", created)
+	}
+	if created.Details["key_id"] != 7 {
+		t.Errorf("Expected the handler's annotation, got %v", created.Details)
+	}
+	if !created.OccurredAt.Equal(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected time %v", created.OccurredAt)
+	}
+
+	revoked := store.events[1]
+	if revoked.Action != "api_key.revoke" || revoked.Resource != "42" || revoked.Status != http.StatusNotFound || revoked.Details != nil {
+		t.Errorf("Unexpected event %+v", revoked)
+	}
+	if store.events[2].Action != "POST /admin/reindex" {
+		t.Errorf("Expected unnamed routes to be recorded by method and path, got %s", store.events[2].Action)
+	}
+}
+
+func TestMiddleware_SkipsReadsAndOtherRoutes(t *testing.T) {
+	store := &memoryStore{}
+	router := newTestRouter(store)
+
+	send(router, "GET", "/admin/api-keys")
+	send(router, "POST", "/logs")
+
+	if len(store.events) != 0 {
+		t.Errorf("Expected no events, got %+v", store.events)
+	}
+}
+
+func TestRecorder_StoreFailure(t *testing.T) {
+	store := &memoryStore{err: errors.New("database down")}
+	router := newTestRouter(store)
+
+	// The action already happened; its response is not changed
+	if status := send(router, "POST", "/admin/api-keys"); status != http.StatusCreated {
+		t.Errorf("Expected 201, got %d", status)
+	}
+}
+
+func TestAnnotate_WithoutAuditing(t *testing.T) {
+	// Must not panic for requests that are not audited
+	Annotate(context.Background(), "key", "value")
+}
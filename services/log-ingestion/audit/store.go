@@ -0,0 +1,29 @@
+package audit
+
+import (
+	"log-processing-system/services/log-ingestion/database"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+// Store persists audit events. It is append-only: events cannot be changed
+// or removed through it.
+type Store interface {
+	Add(event models.AuditEvent) (int64, error)
+	List(filter models.AuditFilter) ([]models.AuditEvent, error)
+}
+
+// tableStore keeps audit events in the audit_log table
+type tableStore struct{}
+
+// NewTableStore returns a store backed by the audit_log table
+func NewTableStore() Store {
+	return tableStore{}
+}
+
+func (tableStore) Add(event models.AuditEvent) (int64, error) {
+	return database.InsertAuditEvent(event)
+}
+
+func (tableStore) List(filter models.AuditFilter) ([]models.AuditEvent, error) {
+	return database.ListAuditEvents(filter)
+}
@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+// AdminTokenHeader carries the admin token of a request
+const AdminTokenHeader = "X-Admin-Token"
+
+// AdminConfig is a credential reserved for the admin endpoints. When it is
+// set, those endpoints accept nothing else: ingestion keys, tokens and
+// certificates cannot administer the service, whatever their scopes.
+type AdminConfig struct {
+	Username string // with Password, HTTP basic auth
+	Password string
+	Token    string // sent in the X-Admin-Token header
+}
+
+// enabled reports whether an admin credential is configured
+func (c AdminConfig) enabled() bool {
+	return c.basic() || c.Token != ""
+}
+
+// basic reports whether basic auth is configured
+func (c AdminConfig) basic() bool {
+	return c.Username != "" && c.Password != ""
+}
+
+// equal compares secrets in constant time; hashing first keeps the time
+// independent of their lengths too
+func equal(given, want string) bool {
+	g, w := sha256.Sum256([]byte(given)), sha256.Sum256([]byte(want))
+	return subtle.ConstantTimeCompare(g[:], w[:]) == 1
+}
+
+// authenticateAdmin checks the admin credential of a request to an admin
+// endpoint, answering the request if it is not accepted. The request then
+// carries the built-in admin key, named after the basic auth user.
+func (a *Authenticator) authenticateAdmin(w http.ResponseWriter, r *http.Request, fields map[string]interface{}) (context.Context, bool) {
+	ctx := r.Context()
+	cfg := a.cfg.Admin
+
+	var name string
+	ok := false
+	if token := r.Header.Get(AdminTokenHeader); token != "" {
+		fields["auth"] = "admin_token"
+		name = "admin"
+		ok = cfg.Token != "" && equal(token, cfg.Token)
+	} else if username, password, hasBasic := r.BasicAuth(); hasBasic {
+		fields["auth"] = "basic"
+		fields["user_id"] = username
+		name = username
+		// Both are compared, so a wrong username takes as long as a wrong password
+		userOK := equal(username, cfg.Username)
+		passwordOK := equal(password, cfg.Password)
+		ok = cfg.basic() && userOK && passwordOK
+	} else {
+		a.logger.WithFields(fields).WarnContext(ctx, "Admin request without credentials rejected")
+		a.adminUnauthorized(w, "Missing admin credentials")
+		return nil, false
+	}
+
+	if !ok {
+		a.logger.WithFields(fields).WarnContext(ctx, "Admin request with invalid credentials rejected")
+		a.adminUnauthorized(w, "Invalid admin credentials")
+		return nil, false
+	}
+
+	ctx = WithKey(ctx, models.APIKey{Name: name, Scopes: []string{models.ScopeAdmin}})
+	return logger.WithUserID(ctx, name), true
+}
+
+// adminUnauthorized answers 401, challenging for basic auth when it is
+// accepted
+func (a *Authenticator) adminUnauthorized(w http.ResponseWriter, message string) {
+	if a.cfg.Admin.basic() {
+		w.Header().Set("WWW-Authenticate", `Basic realm="admin", charset="UTF-8"`)
+	}
+	http.Error(w, message, http.StatusUnauthorized)
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+// serveAdmin sends a request with the given headers through the middleware
+// and returns the response and the actor the handler saw
+func serveAdmin(a *Authenticator, method, path string, setup func(*http.Request)) (*httptest.ResponseRecorder, string) {
+	var actor string
+	handler := a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, actor = Identity(r.Context())
+	}))
+
+	req := httptest.NewRequest(method, path, nil)
+	if setup != nil {
+		setup(req)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec, actor
+}
+
+func TestMiddleware_AdminCredential(t *testing.T) {
+	a := newTestAuthenticator(&memoryStore{}, Config{
+		AdminKey: "bootstrap-secret",
+		Admin:    AdminConfig{Username: "ops", Password: "s3cret", Token: "admin-token"},
+	})
+	ingestKey, _, _ := a.Create(models.APIKey{Name: "shipper", Scopes: []string{models.ScopeIngest}})
+	adminKey, _, _ := a.Create(models.APIKey{Name: "ops-key", Scopes: []string{models.ScopeAdmin}})
+
+	tests := []struct {
+		name   string
+		setup  func(*http.Request)
+		status int
+		actor  string
+	}{
+		{"basic auth", func(r *http.Request) { r.SetBasicAuth("ops", "s3cret") }, http.StatusOK, "ops"},
+		{"token", func(r *http.Request) { r.Header.Set(AdminTokenHeader, "admin-token") }, http.StatusOK, "admin"},
+		{"wrong password", func(r *http.Request) { r.SetBasicAuth("ops", "s3creT") }, http.StatusUnauthorized, ""},
+		{"wrong username", func(r *http.Request) { r.SetBasicAuth("root", "s3cret") }, http.StatusUnauthorized, ""},
+		{"wrong token", func(r *http.Request) { r.Header.Set(AdminTokenHeader, "admin-tokeN") }, http.StatusUnauthorized, ""},
+		{"admin key", func(r *http.Request) { r.Header.Set("X-API-Key", "bootstrap-secret") }, http.StatusUnauthorized, ""},
+		{"admin scoped key", func(r *http.Request) { r.Header.Set("X-API-Key", adminKey) }, http.StatusUnauthorized, ""},
+		{"no credentials", nil, http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		rec, actor := serveAdmin(a, http.MethodPut, "/admin/log-level", tt.setup)
+		if rec.Code != tt.status || actor != tt.actor {
+			t.Errorf("%s: expected %d as %q, got %d as %q", tt.name, tt.status, tt.actor, rec.Code, actor)
+		}
+		if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") != `Basic realm="admin", charset="UTF-8"` {
+			t.Errorf("%s: expected a basic auth challenge, got %q", tt.name, rec.Header().Get("WWW-Authenticate"))
+		}
+	}
+
+	// The admin credential does not ingest; keys still do
+	if rec, _ := serveAdmin(a, http.MethodPost, "/ingest", func(r *http.Request) { r.SetBasicAuth("ops", "s3cret") }); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected the admin credential to be refused for ingestion, got %d", rec.Code)
+	}
+	if status, _ := serve(a, http.MethodPost, "/ingest", ingestKey); status != http.StatusOK {
+		t.Errorf("Expected an ingest key to be accepted, got %d", status)
+	}
+}
+
+func TestMiddleware_AdminCredentialOnly(t *testing.T) {
+	a := newTestAuthenticator(nil, Config{Admin: AdminConfig{Token: "admin-token"}})
+
+	if rec, _ := serveAdmin(a, http.MethodPost, "/ingest", nil); rec.Code != http.StatusOK {
+		t.Errorf("Expected ingestion to stay open, got %d", rec.Code)
+	}
+	rec, _ := serveAdmin(a, http.MethodGet, "/admin/integrity/verify", nil)
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") != "" {
+		t.Errorf("Expected 401 without a basic auth challenge, got %d %v", rec.Code, rec.Header())
+	}
+	// Basic auth is not configured, so no username and password is accepted
+	if rec, _ := serveAdmin(a, http.MethodGet, "/admin/integrity/verify", func(r *http.Request) { r.SetBasicAuth("", "") }); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected empty basic auth to be rejected, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,509 @@
+// Package auth authenticates requests with API keys, bearer tokens from an
+// identity provider, HMAC request signatures or TLS client certificates.
+// Each key carries the scopes it grants, optionally the sources it may
+// write, and a rate limit; tokens carry their scopes, user and tenant as
+// claims; client certificates get the scopes configured for their common
+// name. The admin endpoints can instead require a separate basic auth or
+// token credential.
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/middleware"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+// ErrNotFound is returned when revoking an API key that does not exist or
+// was already revoked
+var ErrNotFound = errors.New("API key not found")
+
+// publicPaths are served without a key, for probes and metrics scrapers
+var publicPaths = map[string]bool{
+	"/health":  true,
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// Config tunes the authenticator; zero values fall back to defaults
+type Config struct {
+	Header   string        // request header carrying the key, X-API-Key by default
+	AdminKey string        // a key with the admin scope that is not stored, to create the first keys with
+	CacheTTL time.Duration // how long a looked up key is trusted before it is read again
+	JWT      JWTConfig     // bearer token validation, enabled by JWT.JWKSURL
+	HMAC     HMACConfig    // signed ingestion requests, enabled by HMAC.Secrets
+	Admin    AdminConfig   // a credential the admin endpoints require instead, when set
+	// ClientCNs grants verified TLS client certificates the scopes listed
+	// for their subject common name; "*" matches any other name
+	ClientCNs map[string][]string
+}
+
+type contextKey struct{}
+
+// WithKey returns a context carrying the authenticated API key
+func WithKey(ctx context.Context, key models.APIKey) context.Context {
+	return context.WithValue(ctx, contextKey{}, key)
+}
+
+// KeyFromContext returns the API key a request was authenticated with
+func KeyFromContext(ctx context.Context) (models.APIKey, bool) {
+	key, ok := ctx.Value(contextKey{}).(models.APIKey)
+	return key, ok
+}
+
+// Identity returns who a request was authenticated as, for audit records:
+// the kind of actor (models.ActorAPIKey and so on) and its name, the key
+// name, token subject or certificate common name. Requests that were not
+// authenticated are anonymous.
+func Identity(ctx context.Context) (actorType, actor string) {
+	if key, ok := KeyFromContext(ctx); ok {
+		if key.ID == 0 {
+			return models.ActorAdminKey, key.Name
+		}
+		return models.ActorAPIKey, key.Name
+	}
+	if claims, ok := ClaimsFromContext(ctx); ok {
+		return models.ActorToken, claims.Subject
+	}
+	if cn, ok := ctx.Value(clientCNKey{}).(string); ok {
+		return models.ActorCertificate, cn
+	}
+	return models.ActorAnonymous, ""
+}
+
+// ingestPaths are read with the ingest scope, for clients that only ingest
+var ingestPaths = map[string]bool{
+	"/quota": true,
+}
+
+// RequiredScope returns the scope a request needs: admin for the /admin
+// endpoints, read for other GET requests and ingest for the rest, and for
+// the ingestPaths clients that only ingest read. Public paths need none.
+func RequiredScope(r *http.Request) string {
+	switch {
+	case publicPaths[r.URL.Path]:
+		return ""
+	case strings.HasPrefix(r.URL.Path, "/admin/"):
+		return models.ScopeAdmin
+	case ingestPaths[r.URL.Path]:
+		return models.ScopeIngest
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		return models.ScopeRead
+	default:
+		return models.ScopeIngest
+	}
+}
+
+type cachedKey struct {
+	key     models.APIKey
+	expires time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// Authenticator checks the API key of each request against a Store, its
+// bearer token with a JWTValidator, or its HMAC signature. Keys are cached
+// for CacheTTL, so a key revoked through another instance stops working
+// here within that time; revoking through this one takes effect at once.
+type Authenticator struct {
+	cfg    Config
+	store  Store
+	jwt    *JWTValidator
+	hmac   *signatureVerifier
+	logger *logger.Logger
+	now    func() time.Time
+
+	mu      sync.Mutex
+	cache   map[string]cachedKey
+	buckets map[int64]*bucket
+}
+
+// NewAuthenticator creates an authenticator for the keys in store, tokens
+// signed by the identity provider when cfg.JWT.JWKSURL is set, and requests
+// signed with one of cfg.HMAC.Secrets. A nil store accepts no API keys
+// besides cfg.AdminKey.
+func NewAuthenticator(store Store, cfg Config, log *logger.Logger) *Authenticator {
+	if cfg.Header == "" {
+		cfg.Header = "X-API-Key"
+	}
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = 30 * time.Second
+	}
+	a := &Authenticator{
+		cfg:     cfg,
+		store:   store,
+		logger:  log,
+		now:     time.Now,
+		cache:   make(map[string]cachedKey),
+		buckets: make(map[int64]*bucket),
+	}
+	if cfg.JWT.JWKSURL != "" {
+		a.jwt = NewJWTValidator(cfg.JWT)
+	}
+	if len(cfg.HMAC.Secrets) > 0 {
+		a.hmac = newSignatureVerifier(cfg.HMAC)
+	}
+	return a
+}
+
+// Create stores a new key with the name, scopes, sources and rate limit of
+// key and returns the key itself, which is not kept and cannot be shown
+// again, along with the stored record
+func (a *Authenticator) Create(key models.APIKey) (string, models.APIKey, error) {
+	if err := key.Validate(); err != nil {
+		return "", key, err
+	}
+
+	secret, err := generateKey()
+	if err != nil {
+		return "", key, err
+	}
+	key.Prefix = displayPrefix(secret)
+	key.KeyHash = hashKey(secret)
+	key.CreatedAt = a.now().UTC()
+	key.RevokedAt = nil
+
+	if key.ID, err = a.store.Add(key); err != nil {
+		return "", key, err
+	}
+
+	a.logger.WithFields(map[string]interface{}{
+		"key_id":     key.ID,
+		"key_name":   key.Name,
+		"key_prefix": key.Prefix,
+		"scopes":     key.Scopes,
+		"sources":    key.Sources,
+	}).Info("API key created")
+	return secret, key, nil
+}
+
+// List returns every stored key, revoked ones included
+func (a *Authenticator) List() ([]models.APIKey, error) {
+	return a.store.List()
+}
+
+// Revoke stops a key from authenticating
+func (a *Authenticator) Revoke(id int64) error {
+	found, err := a.store.Revoke(id, a.now().UTC())
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrNotFound
+	}
+
+	a.mu.Lock()
+	for hash, cached := range a.cache {
+		if cached.key.ID == id {
+			delete(a.cache, hash)
+		}
+	}
+	delete(a.buckets, id)
+	a.mu.Unlock()
+
+	a.logger.WithField("key_id", id).Info("API key revoked")
+	return nil
+}
+
+// Authenticate returns the active key matching secret; ok is false for an
+// unknown or revoked key
+func (a *Authenticator) Authenticate(secret string) (key models.APIKey, ok bool, err error) {
+	if a.cfg.AdminKey != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(a.cfg.AdminKey)) == 1 {
+		return models.APIKey{Name: "admin", Prefix: displayPrefix(secret), Scopes: []string{models.ScopeAdmin}}, true, nil
+	}
+
+	if a.store == nil {
+		return models.APIKey{}, false, nil
+	}
+
+	hash := hashKey(secret)
+	now := a.now()
+
+	a.mu.Lock()
+	cached, hit := a.cache[hash]
+	a.mu.Unlock()
+	if hit && now.Before(cached.expires) {
+		return cached.key, true, nil
+	}
+
+	key, found, err := a.store.GetByHash(hash)
+	if err != nil || !found || key.RevokedAt != nil {
+		a.mu.Lock()
+		delete(a.cache, hash)
+		a.mu.Unlock()
+		return models.APIKey{}, false, err
+	}
+
+	a.mu.Lock()
+	a.cache[hash] = cachedKey{key: key, expires: now.Add(a.cfg.CacheTTL)}
+	a.mu.Unlock()
+	return key, true, nil
+}
+
+// allow takes one request from the key's budget of RateLimit requests per
+// minute, refilled continuously, and returns when to retry if it is spent
+func (a *Authenticator) allow(key models.APIKey) (bool, time.Duration) {
+	if key.RateLimit <= 0 {
+		return true, 0
+	}
+	perSecond := float64(key.RateLimit) / 60
+	now := a.now()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	b, ok := a.buckets[key.ID]
+	if !ok {
+		b = &bucket{tokens: float64(key.RateLimit), updated: now}
+		a.buckets[key.ID] = b
+	}
+	b.tokens = math.Min(float64(key.RateLimit), b.tokens+now.Sub(b.updated).Seconds()*perSecond)
+	b.updated = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / perSecond * float64(time.Second))
+}
+
+// Middleware rejects requests without a valid key, token, signature or
+// client certificate for the scope they need (see RequiredScope) with 401
+// or 403, and requests over the key's rate limit with 429. Explicit
+// credentials take precedence over a client certificate. With an admin
+// credential configured, the admin endpoints accept only that one, and
+// other requests need none unless another method is enabled. Authenticated
+// requests carry their key or token claims in the context; a token's user
+// and tenant, or a certificate's common name, are also added for the
+// logger.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scope := RequiredScope(r)
+		if scope == "" || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		fields := map[string]interface{}{
+			"http_method":      r.Method,
+			"http_path":        r.URL.Path,
+			"http_remote_addr": middleware.ClientIP(r),
+			"request_id":       logger.GetRequestID(r.Context()),
+			"scope":            scope,
+		}
+
+		var (
+			ctx context.Context
+			ok  bool
+		)
+		token, hasToken := bearerToken(r)
+		switch {
+		case scope == models.ScopeAdmin && a.cfg.Admin.enabled():
+			ctx, ok = a.authenticateAdmin(w, r, fields)
+		case a.credentials() == "":
+			// Only the admin endpoints are protected
+			ctx, ok = r.Context(), true
+		case hasToken && a.jwt != nil:
+			ctx, ok = a.authenticateToken(w, r, token, scope, fields)
+		case r.Header.Get(SignatureHeader) != "" && a.hmac != nil:
+			ctx, ok = a.authenticateSignature(w, r, scope, fields)
+		case r.Header.Get(a.cfg.Header) != "":
+			ctx, ok = a.authenticateKey(w, r, r.Header.Get(a.cfg.Header), scope, fields)
+		case hasClientCertificate(r) && a.cfg.ClientCNs != nil:
+			ctx, ok = a.authenticateCertificate(w, r, scope, fields)
+		default:
+			a.logger.WithFields(fields).WarnContext(r.Context(), "Request without credentials rejected")
+			a.unauthorized(w, "", "Missing "+a.credentials())
+		}
+		if !ok {
+			return
+		}
+
+		a.logger.WithFields(fields).DebugContext(ctx, "Request authenticated")
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authenticateKey checks an API key, answering the request if it is not
+// accepted
+func (a *Authenticator) authenticateKey(w http.ResponseWriter, r *http.Request, secret, scope string, fields map[string]interface{}) (context.Context, bool) {
+	ctx := r.Context()
+	fields["key_prefix"] = displayPrefix(secret)
+
+	key, ok, err := a.Authenticate(secret)
+	if err != nil {
+		fields["error"] = err.Error()
+		a.logger.WithFields(fields).ErrorContext(ctx, "Failed to look up API key")
+		http.Error(w, "Failed to authenticate request", http.StatusInternalServerError)
+		return nil, false
+	}
+	if !ok {
+		a.logger.WithFields(fields).WarnContext(ctx, "Request with invalid API key rejected")
+		a.unauthorized(w, "", "Invalid API key")
+		return nil, false
+	}
+
+	fields["key_id"] = key.ID
+	fields["key_name"] = key.Name
+	if !key.HasScope(scope) {
+		a.logger.WithFields(fields).WarnContext(ctx, "API key lacks the required scope")
+		http.Error(w, "API key lacks the "+scope+" scope", http.StatusForbidden)
+		return nil, false
+	}
+
+	if allowed, retryAfter := a.allow(key); !allowed {
+		fields["rate_limit"] = key.RateLimit
+		a.logger.WithFields(fields).WarnContext(ctx, "API key rate limit exceeded")
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		http.Error(w, "API key rate limit exceeded", http.StatusTooManyRequests)
+		return nil, false
+	}
+	return WithKey(ctx, key), true
+}
+
+// authenticateToken checks a bearer token, answering the request if it is
+// not accepted
+func (a *Authenticator) authenticateToken(w http.ResponseWriter, r *http.Request, token, scope string, fields map[string]interface{}) (context.Context, bool) {
+	ctx := r.Context()
+
+	claims, err := a.jwt.Validate(token)
+	if errors.Is(err, errKeysUnavailable) {
+		fields["error"] = err.Error()
+		a.logger.WithFields(fields).ErrorContext(ctx, "Failed to fetch token signing keys")
+		http.Error(w, "Failed to authenticate request", http.StatusServiceUnavailable)
+		return nil, false
+	}
+	if err != nil {
+		fields["error"] = err.Error()
+		a.logger.WithFields(fields).WarnContext(ctx, "Request with invalid bearer token rejected")
+		a.unauthorized(w, "invalid_token", "Invalid bearer token")
+		return nil, false
+	}
+
+	if !claims.HasScope(scope) {
+		fields["user_id"] = claims.Subject
+		fields["tenant_id"] = claims.Tenant
+		a.logger.WithFields(fields).WarnContext(ctx, "Bearer token lacks the required scope")
+		w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+scope+`"`)
+		http.Error(w, "Token lacks the "+scope+" scope", http.StatusForbidden)
+		return nil, false
+	}
+
+	ctx = WithClaims(ctx, claims)
+	if claims.Subject != "" {
+		ctx = logger.WithUserID(ctx, claims.Subject)
+	}
+	if claims.Tenant != "" {
+		ctx = logger.WithTenantID(ctx, claims.Tenant)
+	}
+	return ctx, true
+}
+
+// authenticateSignature checks the HMAC signature of a request, which only
+// grants the ingest scope, answering the request if it is not accepted. The
+// body is read to check it and replaced for the handler.
+func (a *Authenticator) authenticateSignature(w http.ResponseWriter, r *http.Request, scope string, fields map[string]interface{}) (context.Context, bool) {
+	ctx := r.Context()
+	fields["auth"] = "hmac"
+
+	if scope != models.ScopeIngest {
+		a.logger.WithFields(fields).WarnContext(ctx, "Signed request outside ingestion rejected")
+		http.Error(w, "Signed requests may only ingest logs", http.StatusForbidden)
+		return nil, false
+	}
+
+	// The whole body is held to check it, so a signed request cannot be
+	// made to buffer more than the limit
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, a.hmac.maxBody))
+	if err != nil {
+		if int64(len(body)) >= a.hmac.maxBody {
+			fields["error"] = err.Error()
+			a.logger.WithFields(fields).WarnContext(ctx, "Signed request body too large")
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return nil, false
+		}
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return nil, false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	if err := a.hmac.verify(r.Method, r.URL.Path, r.Header.Get(TimestampHeader), r.Header.Get(SignatureHeader), body); err != nil {
+		fields["error"] = err.Error()
+		a.logger.WithFields(fields).WarnContext(ctx, "Request with invalid signature rejected")
+		a.unauthorized(w, "", "Invalid request signature")
+		return nil, false
+	}
+	return ctx, true
+}
+
+// authenticateCertificate checks the scopes granted to the verified client
+// certificate of a request, answering the request if it is not accepted.
+// The certificate's common name is the user of the request's log entries.
+func (a *Authenticator) authenticateCertificate(w http.ResponseWriter, r *http.Request, scope string, fields map[string]interface{}) (context.Context, bool) {
+	ctx := r.Context()
+	cn, _ := clientCommonName(r)
+	fields["auth"] = "mtls"
+	fields["client_cn"] = cn
+
+	scopes, ok := a.clientScopes(cn)
+	if !ok {
+		a.logger.WithFields(fields).WarnContext(ctx, "Client certificate not authorized")
+		http.Error(w, "Client certificate not authorized", http.StatusForbidden)
+		return nil, false
+	}
+	if !hasClientScope(scopes, scope) {
+		a.logger.WithFields(fields).WarnContext(ctx, "Client certificate lacks the required scope")
+		http.Error(w, "Client certificate lacks the "+scope+" scope", http.StatusForbidden)
+		return nil, false
+	}
+	ctx = context.WithValue(ctx, clientCNKey{}, cn)
+	if cn != "" {
+		ctx = logger.WithUserID(ctx, cn)
+	}
+	return ctx, true
+}
+
+// credentials names what a request may authenticate with
+func (a *Authenticator) credentials() string {
+	var names []string
+	if a.store != nil || a.cfg.AdminKey != "" {
+		names = append(names, "API key")
+	}
+	if a.jwt != nil {
+		names = append(names, "bearer token")
+	}
+	if a.hmac != nil {
+		names = append(names, "request signature")
+	}
+	if a.cfg.ClientCNs != nil {
+		names = append(names, "client certificate")
+	}
+	return strings.Join(names, " or ")
+}
+
+// unauthorized answers 401, challenging for a bearer token when tokens are
+// accepted
+func (a *Authenticator) unauthorized(w http.ResponseWriter, bearerError, message string) {
+	if a.jwt != nil {
+		challenge := "Bearer"
+		if bearerError != "" {
+			challenge += ` error="` + bearerError + `"`
+		}
+		w.Header().Set("WWW-Authenticate", challenge)
+	}
+	http.Error(w, message, http.StatusUnauthorized)
+}
@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+// memoryStore keeps API keys in memory for tests
+type memoryStore struct {
+	keys    []models.APIKey
+	lookups int
+}
+
+func (s *memoryStore) Add(key models.APIKey) (int64, error) {
+	key.ID = int64(len(s.keys) + 1)
+	s.keys = append(s.keys, key)
+	return key.ID, nil
+}
+
+func (s *memoryStore) List() ([]models.APIKey, error) {
+	return s.keys, nil
+}
+
+func (s *memoryStore) GetByHash(hash string) (models.APIKey, bool, error) {
+	s.lookups++
+	for _, key := range s.keys {
+		if key.KeyHash == hash {
+			return key, true, nil
+		}
+	}
+	return models.APIKey{}, false, nil
+}
+
+func (s *memoryStore) Revoke(id int64, revokedAt time.Time) (bool, error) {
+	for i := range s.keys {
+		if s.keys[i].ID == id && s.keys[i].RevokedAt == nil {
+			s.keys[i].RevokedAt = &revokedAt
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func newTestAuthenticator(store Store, cfg Config) *Authenticator {
+	return NewAuthenticator(store, cfg, logger.New(logger.Config{Service: "test-service", Component: "auth"}))
+}
+
+// serve sends a request through the middleware and returns the status and
+// the key the handler saw
+func serve(a *Authenticator, method, path, key string) (int, models.APIKey) {
+	var seen models.APIKey
+	handler := a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = KeyFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(method, path, nil)
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Code, seen
+}
+
+func TestMiddleware_Scopes(t *testing.T) {
+	a := newTestAuthenticator(&memoryStore{}, Config{})
+	ingestKey, record, err := a.Create(models.APIKey{Name: "shipper", Scopes: []string{models.ScopeIngest}})
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if record.Prefix != ingestKey[:12] || record.KeyHash == ingestKey {
+		t.Errorf("Expected the key's prefix and hash to be stored, got %+v", record)
+	}
+	adminKey, _, _ := a.Create(models.APIKey{Name: "ops", Scopes: []string{models.ScopeAdmin}})
+
+	tests := []struct {
+		method, path, key string
+		expected          int
+	}{
+		{http.MethodGet, "/health", "", http.StatusOK},
+		{http.MethodGet, "/metrics", "", http.StatusOK},
+		{http.MethodPost, "/ingest", "", http.StatusUnauthorized},
+		{http.MethodPost, "/ingest", "lps_0123456789", http.StatusUnauthorized},
+		{http.MethodPost, "/ingest", ingestKey, http.StatusOK},
+		{http.MethodPost, "/ingest/batch", ingestKey, http.StatusOK},
+		{http.MethodGet, "/logs/tail", ingestKey, http.StatusForbidden},
+		{http.MethodPut, "/admin/log-level", ingestKey, http.StatusForbidden},
+		{http.MethodGet, "/logs/tail", adminKey, http.StatusOK},
+		{http.MethodPut, "/admin/log-level", adminKey, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if status, _ := serve(a, tt.method, tt.path, tt.key); status != tt.expected {
+			t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.path, tt.expected, status)
+		}
+	}
+
+	if _, key := serve(a, http.MethodPost, "/ingest", ingestKey); key.Name != "shipper" {
+		t.Errorf("Expected the handler to see the key, got %+v", key)
+	}
+}
+
+func TestAuthenticator_RevokeAndCache(t *testing.T) {
+	store := &memoryStore{}
+	a := newTestAuthenticator(store, Config{CacheTTL: time.Minute})
+	secret, record, _ := a.Create(models.APIKey{Name: "shipper", Scopes: []string{models.ScopeIngest}})
+
+	for i := 0; i < 3; i++ {
+		if status, _ := serve(a, http.MethodPost, "/ingest", secret); status != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", status)
+		}
+	}
+	if store.lookups != 1 {
+		t.Errorf("Expected the key to be looked up once, got %d lookups", store.lookups)
+	}
+
+	if err := a.Revoke(record.ID); err != nil {
+		t.Fatalf("Failed to revoke key: %v", err)
+	}
+	if status, _ := serve(a, http.MethodPost, "/ingest", secret); status != http.StatusUnauthorized {
+		t.Errorf("Expected a revoked key to be rejected at once, got %d", status)
+	}
+	if err := a.Revoke(record.ID); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound revoking twice, got %v", err)
+	}
+}
+
+func TestAuthenticator_RateLimit(t *testing.T) {
+	a := newTestAuthenticator(&memoryStore{}, Config{})
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+	secret, _, _ := a.Create(models.APIKey{Name: "shipper", Scopes: []string{models.ScopeIngest}, RateLimit: 2})
+
+	for i := 0; i < 2; i++ {
+		if status, _ := serve(a, http.MethodPost, "/ingest", secret); status != http.StatusOK {
+			t.Fatalf("Request %d: expected status 200, got %d", i, status)
+		}
+	}
+	if status, _ := serve(a, http.MethodPost, "/ingest", secret); status != http.StatusTooManyRequests {
+		t.Errorf("Expected status 429 over the limit, got %d", status)
+	}
+
+	// Two requests a minute refill one every 30 seconds
+	now = now.Add(30 * time.Second)
+	if status, _ := serve(a, http.MethodPost, "/ingest", secret); status != http.StatusOK {
+		t.Errorf("Expected status 200 after the refill, got %d", status)
+	}
+}
+
+func TestAuthenticator_AdminKey(t *testing.T) {
+	a := newTestAuthenticator(&memoryStore{}, Config{AdminKey: "bootstrap-secret"})
+
+	if status, _ := serve(a, http.MethodPost, "/admin/api-keys", "bootstrap-secret"); status != http.StatusOK {
+		t.Errorf("Expected the admin key to be accepted, got %d", status)
+	}
+	if status, _ := serve(a, http.MethodPost, "/admin/api-keys", "bootstrap-secreT"); status != http.StatusUnauthorized {
+		t.Errorf("Expected a wrong key to be rejected, got %d", status)
+	}
+}
+
+func TestAPIKey_Validate(t *testing.T) {
+	a := newTestAuthenticator(&memoryStore{}, Config{})
+	invalid := []models.APIKey{
+		{Scopes: []string{models.ScopeIngest}},
+		{Name: "shipper"},
+		{Name: "shipper", Scopes: []string{"write"}},
+		{Name: "shipper", Scopes: []string{models.ScopeIngest}, RateLimit: -1},
+	}
+	for _, key := range invalid {
+		if _, _, err := a.Create(key); err == nil {
+			t.Errorf("Expected %+v to be rejected", key)
+		}
+	}
+
+	key := models.APIKey{Scopes: []string{models.ScopeIngest}, Sources: []string{"billing"}}
+	if !key.AllowsSource("billing") || key.AllowsSource("auth") {
+		t.Errorf("Expected only the billing source to be allowed")
+	}
+	if key.HasScope(models.ScopeRead) {
+		t.Errorf("Expected an ingest key not to grant read")
+	}
+}
@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveSigned sends a signed request through the middleware and returns
+// the status and the body the handler read
+func serveSigned(a *Authenticator, method, path, body, timestamp, signature string) (int, string) {
+	var seen string
+	handler := a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		seen = string(data)
+	}))
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, signature)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Code, seen
+}
+
+func TestMiddleware_Signature(t *testing.T) {
+	a := newTestAuthenticator(nil, Config{HMAC: HMACConfig{Secrets: []string{"current", "previous"}}})
+	now := time.Unix(1700000000, 0)
+	a.hmac.now = func() time.Time { return now }
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	body := `{"level":"info","message":"signed"}`
+
+	status, seen := serveSigned(a, "POST", "/logs", body, timestamp, Sign("current", "POST", "/logs", now, []byte(body)))
+	if status != http.StatusOK || seen != body {
+		t.Fatalf("Expected a signed request to reach the handler with its body, got %d %q", status, seen)
+	}
+	if status, _ := serveSigned(a, "POST", "/logs", body, timestamp, Sign("current", "POST", "/logs", now, []byte(body))); status != http.StatusUnauthorized {
+		t.Errorf("Expected a replayed signature to be rejected, got %d", status)
+	}
+
+	other := `{"level":"info","message":"rotated"}`
+	if status, _ := serveSigned(a, "POST", "/logs", other, timestamp, Sign("previous", "POST", "/logs", now, []byte(other))); status != http.StatusOK {
+		t.Errorf("Expected a signature with the previous secret to be accepted, got %d", status)
+	}
+
+	tests := []struct {
+		name      string
+		body      string
+		timestamp time.Time
+		signature string
+	}{
+		{"tampered body", `{"level":"info","message":"tampered"}`, now, Sign("current", "POST", "/logs", now, []byte(body))},
+		{"unknown secret", body, now, Sign("other", "POST", "/logs", now, []byte(body))},
+		{"stale timestamp", body, now.Add(-6 * time.Minute), Sign("current", "POST", "/logs", now.Add(-6*time.Minute), []byte(body))},
+		{"future timestamp", body, now.Add(6 * time.Minute), Sign("current", "POST", "/logs", now.Add(6*time.Minute), []byte(body))},
+		{"unsupported scheme", body, now, "md5=" + strings.TrimPrefix(Sign("current", "POST", "/logs", now, []byte(body)), "sha256=")},
+		{"other path", body, now, Sign("current", "POST", "/ingest", now, []byte(body))},
+		{"other method", body, now, Sign("current", "PUT", "/logs", now, []byte(body))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, _ := serveSigned(a, "POST", "/logs", tt.body, strconv.FormatInt(tt.timestamp.Unix(), 10), tt.signature)
+			if status != http.StatusUnauthorized {
+				t.Errorf("Expected 401, got %d", status)
+			}
+		})
+	}
+}
+
+func TestMiddleware_SignatureIngestOnly(t *testing.T) {
+	a := newTestAuthenticator(nil, Config{HMAC: HMACConfig{Secrets: []string{"secret"}}})
+	now := time.Now()
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	if status, _ := serveSigned(a, "GET", "/logs", "", timestamp, Sign("secret", "GET", "/logs", now, nil)); status != http.StatusForbidden {
+		t.Errorf("Expected a signed read to be forbidden, got %d", status)
+	}
+	if status, _ := serveSigned(a, "POST", "/admin/api-keys", "{}", timestamp, Sign("secret", "POST", "/admin/api-keys", now, []byte("{}"))); status != http.StatusForbidden {
+		t.Errorf("Expected a signed admin request to be forbidden, got %d", status)
+	}
+}
+
+func TestSignatureVerifier_PrunesExpired(t *testing.T) {
+	v := newSignatureVerifier(HMACConfig{Secrets: []string{"secret"}, Window: time.Minute})
+	now := time.Unix(1700000000, 0)
+	v.now = func() time.Time { return now }
+
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	if err := v.verify("POST", "/logs", timestamp, Sign("secret", "POST", "/logs", now, []byte("a")), []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(2 * time.Minute)
+	timestamp = strconv.FormatInt(now.Unix(), 10)
+	if err := v.verify("POST", "/logs", timestamp, Sign("secret", "POST", "/logs", now, []byte("b")), []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	if len(v.seen) != 1 {
+		t.Errorf("Expected expired signatures to be pruned, %d remembered", len(v.seen))
+	}
+}
+
+func TestMiddleware_SignatureBodyLimit(t *testing.T) {
+	a := newTestAuthenticator(nil, Config{HMAC: HMACConfig{Secrets: []string{"secret"}, MaxBody: 16}})
+	now := time.Now()
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	body := `{"message":"fits"}`[:16]
+	if status, _ := serveSigned(a, "POST", "/logs", body, timestamp, Sign("secret", "POST", "/logs", now, []byte(body))); status != http.StatusOK {
+		t.Errorf("Expected a body at the limit to be accepted, got %d", status)
+	}
+	body = `{"message":"too large"}`
+	if status, _ := serveSigned(a, "POST", "/logs", body, timestamp, Sign("secret", "POST", "/logs", now, []byte(body))); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected a body over the limit to be refused, got %d", status)
+	}
+}
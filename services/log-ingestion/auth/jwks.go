@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// jwksMinRefresh limits how often an unknown key ID or a failed fetch
+// triggers another fetch, so tokens with made-up key IDs cannot flood the
+// identity provider
+const jwksMinRefresh = 30 * time.Second
+
+// jsonWebKey is a key of a JWKS document (RFC 7517). Only RSA and EC
+// signing keys are used.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// jwks fetches and caches the signing keys published at a JWKS URL. Keys
+// are refetched every refresh interval, and sooner when a token names a
+// key ID that is not known yet, as after the provider rotates its keys.
+type jwks struct {
+	url     string
+	refresh time.Duration
+	client  *http.Client
+	now     func() time.Time
+
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	err     error
+	fetched time.Time
+}
+
+func newJWKS(url string, refresh time.Duration) *jwks {
+	return &jwks{
+		url:     url,
+		refresh: refresh,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		now:     time.Now,
+	}
+}
+
+// key returns the key with the given ID; an empty ID matches the only key
+// of a document with one key
+func (j *jwks) key(kid string) (crypto.PublicKey, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := j.now()
+	_, known := j.keys[kid]
+	due := now.Sub(j.fetched) >= j.refresh
+	retry := now.Sub(j.fetched) >= jwksMinRefresh && (j.keys == nil || !known && kid != "")
+	if due || retry {
+		// On failure the previous keys stay in use until the next attempt
+		if keys, err := j.fetch(); err != nil {
+			j.err = err
+		} else {
+			j.keys, j.err = keys, nil
+		}
+		j.fetched = now
+	}
+	if j.keys == nil {
+		return nil, j.err
+	}
+
+	if kid == "" && len(j.keys) == 1 {
+		for _, key := range j.keys {
+			return key, nil
+		}
+	}
+	key, ok := j.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// keysKnown reports whether a key set was fetched, so a failed lookup is
+// down to the token rather than the identity provider
+func (j *jwks) keysKnown() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.keys != nil
+}
+
+// fetch downloads and parses the key set
+func (j *jwks) fetch() (map[string]crypto.PublicKey, error) {
+	resp, err := j.client.Get(j.url)
+	if err != nil {
+		return nil, fmt.Errorf("fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch JWKS: unexpected status %s", resp.Status)
+	}
+
+	var doc struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("parse JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(doc.Keys))
+	for _, jwk := range doc.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			// Keys of other types may be published alongside
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("parse JWKS: no usable signing keys")
+	}
+	return keys, nil
+}
+
+// publicKey decodes an RSA or EC public key
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("RSA exponent too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("EC point not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,296 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+// errKeysUnavailable is returned when a token cannot be checked because the
+// signing keys could not be fetched
+var errKeysUnavailable = errors.New("signing keys unavailable")
+
+// curveHashes pairs each curve with the hash of its ES* algorithm: ES256
+// with P-256, ES384 with P-384 and ES512 with P-521
+var curveHashes = map[string]crypto.Hash{
+	"P-256": crypto.SHA256,
+	"P-384": crypto.SHA384,
+	"P-521": crypto.SHA512,
+}
+
+// JWTConfig configures bearer token validation; zero values fall back to
+// defaults
+type JWTConfig struct {
+	JWKSURL         string        // where the identity provider publishes its signing keys; empty disables tokens
+	Issuer          string        // required iss claim, if set
+	Audience        string        // value the aud claim must contain, if set
+	ClockSkew       time.Duration // leeway for the exp, nbf and iat claims, 1 minute by default
+	UserClaim       string        // claim holding the user ID, sub by default
+	TenantClaim     string        // claim holding the tenant ID, tenant_id by default
+	ScopeClaim      string        // claim holding the scopes, scope by default
+	RefreshInterval time.Duration // how often the signing keys are refetched, 1 hour by default
+}
+
+// Claims are the claims of a validated bearer token the service uses
+type Claims struct {
+	Subject   string
+	Tenant    string
+	Scopes    []string
+	ExpiresAt time.Time
+}
+
+// HasScope reports whether the token grants scope; admin implies the others
+func (c Claims) HasScope(scope string) bool {
+	for _, s := range c.Scopes {
+		if s == scope || s == models.ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+type claimsContextKey struct{}
+
+// WithClaims returns a context carrying the claims of a validated token
+func WithClaims(ctx context.Context, claims Claims) context.Context {
+	return context.WithValue(ctx, claimsContextKey{}, claims)
+}
+
+// ClaimsFromContext returns the claims of the token a request was
+// authenticated with
+func ClaimsFromContext(ctx context.Context) (Claims, bool) {
+	claims, ok := ctx.Value(claimsContextKey{}).(Claims)
+	return claims, ok
+}
+
+// JWTValidator validates JWTs signed with a key published in a JWKS
+// document, as issued by identity providers. RS*, PS* and ES* signatures
+// are accepted; unsigned and HMAC tokens are not.
+type JWTValidator struct {
+	cfg  JWTConfig
+	keys *jwks
+	now  func() time.Time
+}
+
+// NewJWTValidator creates a validator for the keys at cfg.JWKSURL. Keys are
+// fetched on first use.
+func NewJWTValidator(cfg JWTConfig) *JWTValidator {
+	if cfg.ClockSkew <= 0 {
+		cfg.ClockSkew = time.Minute
+	}
+	if cfg.UserClaim == "" {
+		cfg.UserClaim = "sub"
+	}
+	if cfg.TenantClaim == "" {
+		cfg.TenantClaim = "tenant_id"
+	}
+	if cfg.ScopeClaim == "" {
+		cfg.ScopeClaim = "scope"
+	}
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = time.Hour
+	}
+	return &JWTValidator{
+		cfg:  cfg,
+		keys: newJWKS(cfg.JWKSURL, cfg.RefreshInterval),
+		now:  time.Now,
+	}
+}
+
+// Validate checks a token's signature and claims and returns its claims
+func (v *JWTValidator) Validate(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Claims{}, fmt.Errorf("malformed token header: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, errors.New("malformed token signature")
+	}
+	hash, err := signatureHash(header.Alg)
+	if err != nil {
+		return Claims{}, err
+	}
+	key, err := v.keys.key(header.Kid)
+	if err != nil {
+		if v.keys.keysKnown() {
+			return Claims{}, err
+		}
+		return Claims{}, fmt.Errorf("%w: %v", errKeysUnavailable, err)
+	}
+	if err := verifySignature(header.Alg, hash, key, parts[0]+"."+parts[1], signature); err != nil {
+		return Claims{}, err
+	}
+
+	var raw map[string]interface{}
+	if err := decodeSegment(parts[1], &raw); err != nil {
+		return Claims{}, fmt.Errorf("malformed token claims: %w", err)
+	}
+	return v.checkClaims(raw)
+}
+
+// checkClaims checks the time, issuer and audience claims and extracts the
+// user, tenant and scopes
+func (v *JWTValidator) checkClaims(raw map[string]interface{}) (Claims, error) {
+	now := v.now()
+	skew := v.cfg.ClockSkew
+
+	exp, ok := numericDate(raw["exp"])
+	if !ok {
+		return Claims{}, errors.New("token has no expiry")
+	}
+	if now.After(exp.Add(skew)) {
+		return Claims{}, errors.New("token expired")
+	}
+	if nbf, ok := numericDate(raw["nbf"]); ok && now.Add(skew).Before(nbf) {
+		return Claims{}, errors.New("token not yet valid")
+	}
+	if iat, ok := numericDate(raw["iat"]); ok && now.Add(skew).Before(iat) {
+		return Claims{}, errors.New("token issued in the future")
+	}
+
+	if v.cfg.Issuer != "" && raw["iss"] != v.cfg.Issuer {
+		return Claims{}, fmt.Errorf("unexpected issuer %v", raw["iss"])
+	}
+	if v.cfg.Audience != "" && !containsString(raw["aud"], v.cfg.Audience) {
+		return Claims{}, fmt.Errorf("token not issued for audience %q", v.cfg.Audience)
+	}
+
+	claims := Claims{ExpiresAt: exp}
+	claims.Subject, _ = raw[v.cfg.UserClaim].(string)
+	claims.Tenant, _ = raw[v.cfg.TenantClaim].(string)
+	switch scopes := raw[v.cfg.ScopeClaim].(type) {
+	case string:
+		claims.Scopes = strings.Fields(scopes)
+	case []interface{}:
+		for _, scope := range scopes {
+			if s, ok := scope.(string); ok {
+				claims.Scopes = append(claims.Scopes, s)
+			}
+		}
+	}
+	return claims, nil
+}
+
+// bearerToken returns the token of an Authorization: Bearer header
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// signatureHash returns the hash of a supported signing algorithm
+func signatureHash(alg string) (crypto.Hash, error) {
+	if len(alg) == 5 {
+		switch alg[:2] {
+		case "RS", "PS", "ES":
+			switch alg[2:] {
+			case "256":
+				return crypto.SHA256, nil
+			case "384":
+				return crypto.SHA384, nil
+			case "512":
+				return crypto.SHA512, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unsupported signing algorithm %q", alg)
+}
+
+// verifySignature checks the signature of signed with key, which must be of
+// the type alg calls for
+func verifySignature(alg string, hash crypto.Hash, key crypto.PublicKey, signed string, signature []byte) error {
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	invalid := errors.New("invalid token signature")
+	switch alg[:2] {
+	case "RS", "PS":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("signing key does not match algorithm %s", alg)
+		}
+		if alg[:2] == "RS" {
+			if rsa.VerifyPKCS1v15(pub, hash, digest, signature) != nil {
+				return invalid
+			}
+			return nil
+		}
+		if rsa.VerifyPSS(pub, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) != nil {
+			return invalid
+		}
+		return nil
+	default:
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("signing key does not match algorithm %s", alg)
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if curveHashes[pub.Curve.Params().Name] != hash || len(signature) != 2*size {
+			return invalid
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return invalid
+		}
+		return nil
+	}
+}
+
+// numericDate reads a JWT NumericDate claim
+func numericDate(v interface{}) (time.Time, bool) {
+	seconds, ok := v.(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(seconds), 0), true
+}
+
+// containsString reports whether an aud claim, a string or an array of
+// strings, contains want
+func containsString(v interface{}, want string) bool {
+	switch values := v.(type) {
+	case string:
+		return values == want
+	case []interface{}:
+		for _, value := range values {
+			if value == want {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,255 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+// testPKI is a CA issuing the server certificate and client certificates,
+// written to PEM files in a temporary directory
+type testPKI struct {
+	dir    string
+	ca     *x509.Certificate
+	caKey  *ecdsa.PrivateKey
+	serial int64
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &testPKI{dir: t.TempDir(), ca: ca, caKey: key, serial: 1}
+	p.write(t, "ca.pem", "CERTIFICATE", der)
+	return p
+}
+
+func (p *testPKI) write(t *testing.T, name, blockType string, der []byte) string {
+	path := filepath.Join(p.dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// issue returns a certificate for cn signed by the CA
+func (p *testPKI) issue(t *testing.T, cn string, usage x509.ExtKeyUsage) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.serial++
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(p.serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, p.ca, &key.PublicKey, p.caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// serverConfig writes a server certificate and returns the TLS config of
+// a listener verifying clients against the CA
+func (p *testPKI) serverConfig(t *testing.T, optional bool) *tls.Config {
+	cert := p.issue(t, "127.0.0.1", x509.ExtKeyUsageServerAuth)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := NewServerTLSConfig(TLSConfig{
+		CertFile:           p.write(t, "server.pem", "CERTIFICATE", cert.Certificate[0]),
+		KeyFile:            p.write(t, "server-key.pem", "PRIVATE KEY", keyDER),
+		ClientCAFile:       filepath.Join(p.dir, "ca.pem"),
+		ClientCertOptional: optional,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+// client returns an HTTP client trusting the CA, presenting cn's
+// certificate unless cn is empty
+func (p *testPKI) client(t *testing.T, cn string) *http.Client {
+	roots := x509.NewCertPool()
+	roots.AddCert(p.ca)
+	config := &tls.Config{RootCAs: roots}
+	if cn != "" {
+		config.Certificates = []tls.Certificate{p.issue(t, cn, x509.ExtKeyUsageClientAuth)}
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+}
+
+func newTLSServer(t *testing.T, config *tls.Config, handler http.Handler) *httptest.Server {
+	server := httptest.NewUnstartedServer(handler)
+	server.TLS = config
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestMiddleware_ClientCertificate(t *testing.T) {
+	pki := newTestPKI(t)
+	a := newTestAuthenticator(&memoryStore{}, Config{ClientCNs: map[string][]string{
+		"log-agent": {models.ScopeIngest},
+		"ops":       {models.ScopeAdmin},
+	}})
+	secret, _, err := a.Create(models.APIKey{Name: "reader", Scopes: []string{models.ScopeRead}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var user string
+	server := newTLSServer(t, pki.serverConfig(t, true), a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user = logger.GetUserID(r.Context())
+	})))
+
+	tests := []struct {
+		name     string
+		cn       string
+		method   string
+		path     string
+		apiKey   string
+		expected int
+		user     string
+	}{
+		{"ingest with granted scope", "log-agent", "POST", "/logs", "", http.StatusOK, "log-agent"},
+		{"read without scope", "log-agent", "GET", "/logs", "", http.StatusForbidden, ""},
+		{"admin implies read", "ops", "GET", "/logs", "", http.StatusOK, "ops"},
+		{"unknown common name", "intruder", "POST", "/logs", "", http.StatusForbidden, ""},
+		{"no certificate", "", "POST", "/logs", "", http.StatusUnauthorized, ""},
+		{"API key takes precedence", "log-agent", "GET", "/logs", secret, http.StatusOK, ""},
+		{"public path", "", "GET", "/health", "", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user = ""
+			req, _ := http.NewRequest(tt.method, server.URL+tt.path, nil)
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			resp, err := pki.client(t, tt.cn).Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.expected {
+				t.Errorf("Expected %d, got %d", tt.expected, resp.StatusCode)
+			}
+			if user != tt.user {
+				t.Errorf("Expected user %q in the context, got %q", tt.user, user)
+			}
+		})
+	}
+}
+
+func TestMiddleware_ClientCertificateWildcard(t *testing.T) {
+	pki := newTestPKI(t)
+	a := newTestAuthenticator(nil, Config{ClientCNs: map[string][]string{"*": {models.ScopeIngest}}})
+	server := newTLSServer(t, pki.serverConfig(t, false), a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	resp, err := pki.client(t, "any-agent").Post(server.URL+"/logs", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected the wildcard to grant ingest, got %d", resp.StatusCode)
+	}
+}
+
+func TestNewServerTLSConfig_RequiresClientCertificate(t *testing.T) {
+	pki := newTestPKI(t)
+	server := newTLSServer(t, pki.serverConfig(t, false), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	if resp, err := pki.client(t, "").Get(server.URL + "/health"); err == nil {
+		resp.Body.Close()
+		t.Error("Expected the handshake to fail without a client certificate")
+	}
+
+	other := newTestPKI(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(pki.ca)
+	untrusted := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      roots,
+		Certificates: []tls.Certificate{other.issue(t, "log-agent", x509.ExtKeyUsageClientAuth)},
+	}}}
+	if resp, err := untrusted.Get(server.URL + "/health"); err == nil {
+		resp.Body.Close()
+		t.Error("Expected the handshake to fail with a certificate from another CA")
+	}
+}
+
+func TestNewServerTLSConfig_Errors(t *testing.T) {
+	pki := newTestPKI(t)
+	empty := filepath.Join(pki.dir, "empty.pem")
+	if err := os.WriteFile(empty, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		cfg  TLSConfig
+	}{
+		{"missing key", TLSConfig{CertFile: filepath.Join(pki.dir, "ca.pem")}},
+		{"unreadable certificate", TLSConfig{CertFile: filepath.Join(pki.dir, "missing.pem"), KeyFile: filepath.Join(pki.dir, "missing-key.pem")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewServerTLSConfig(tt.cfg); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+
+	config := pki.serverConfig(t, false)
+	if config.ClientAuth != tls.RequireAndVerifyClientCert {
+		t.Errorf("Expected client certificates to be required, got %v", config.ClientAuth)
+	}
+	if _, err := NewServerTLSConfig(TLSConfig{
+		CertFile:     filepath.Join(pki.dir, "server.pem"),
+		KeyFile:      filepath.Join(pki.dir, "server-key.pem"),
+		ClientCAFile: empty,
+	}); err == nil {
+		t.Error("Expected an error for a client CA file without certificates")
+	}
+}
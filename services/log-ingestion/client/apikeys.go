@@ -0,0 +1,46 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+// CreatedAPIKey is a new API key: its record and the key itself, which the
+// service never shows again
+type CreatedAPIKey struct {
+	models.APIKey
+	Key string `json:"key"`
+}
+
+// CreateAPIKey creates an API key with the name, scopes, sources and rate
+// limit of key; it needs a client with an admin key
+func (c *Client) CreateAPIKey(ctx context.Context, key models.APIKey) (*CreatedAPIKey, error) {
+	body, err := json.Marshal(key)
+	if err != nil {
+		return nil, err
+	}
+	var created CreatedAPIKey
+	if err := c.do(ctx, http.MethodPost, "/admin/api-keys", nil, body, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// ListAPIKeys lists the API keys, revoked ones included
+func (c *Client) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	var response struct {
+		APIKeys []models.APIKey `json:"api_keys"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/admin/api-keys", nil, nil, &response); err != nil {
+		return nil, err
+	}
+	return response.APIKeys, nil
+}
+
+// RevokeAPIKey revokes the API key with id so it no longer authenticates
+func (c *Client) RevokeAPIKey(ctx context.Context, id int64) error {
+	return c.do(ctx, http.MethodDelete, "/admin/api-keys/"+strconv.FormatInt(id, 10), nil, nil, nil)
+}
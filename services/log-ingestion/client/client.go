@@ -0,0 +1,499 @@
+// Package client is a Go client of the log ingestion API. It sends entries
+// one at a time or in batches, queries stored entries and follows the live
+// tail, retrying failed requests with exponential backoff.
+//
+//	c, err := client.New(client.Config{URL: "http://localhost:8080", APIKey: key})
+//	if err != nil {
+//		return err
+//	}
+//	_, err = c.Send(ctx, models.Log{Message: "user signed in", Level: "info", Source: "auth-service"})
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+// MaxBatchEntries is the most entries the service accepts in one batch;
+// SendBatch splits larger batches into requests of this size
+const MaxBatchEntries = 1000
+
+// Config configures a Client. Zero values select the defaults.
+type Config struct {
+	URL          string        // base URL of the service, such as http://localhost:8080
+	APIKey       string        // sent in APIKeyHeader when set
+	APIKeyHeader string        // X-API-Key by default
+	Timeout      time.Duration // per request, 10s by default; the live tail has none
+	MaxRetries   int           // retries after a failed attempt, 3 by default; negative disables them
+	RetryBackoff time.Duration // wait before the first retry, doubling after each, 500ms by default
+	MaxBackoff   time.Duration // longest wait between retries, 30s by default
+	Gzip         bool          // compress request bodies
+	HTTPClient   *http.Client  // used instead of a client with Timeout when set
+}
+
+// Client calls the log ingestion API. It is safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	apiKey     string
+	header     string
+	maxRetries int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	gzip       bool
+	http       *http.Client
+}
+
+// APIError is a response the service answered with an error status
+type APIError struct {
+	StatusCode int
+	Message    string
+	RequestID  string
+	RetryAfter time.Duration // the Retry-After of throttled and maintenance responses
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("log ingestion returned %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("log ingestion returned %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Temporary reports whether retrying the same request may succeed: on
+// timeouts, throttling and server errors
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// Result is the answer to an entry sent on its own
+type Result struct {
+	Status        string  `json:"status"` // accepted, queued, buffered, dead_lettered or dropped
+	Message       string  `json:"message"`
+	RequestID     string  `json:"request_id"`
+	DeadLetterIDs []int64 `json:"dead_letter_ids,omitempty"`
+}
+
+// Rejection reports an entry of a batch that was not ingested
+type Rejection struct {
+	Index             int    `json:"index"` // position in the slice passed to SendBatch
+	Error             string `json:"error"`
+	DeadLetterID      int64  `json:"dead_letter_id,omitempty"`
+	RetryAfterSeconds int    `json:"retry_after_seconds,omitempty"`
+	// Retryable marks an entry the service could not store, to send again
+	Retryable bool `json:"retryable,omitempty"`
+}
+
+// BatchResult sums up the answers to the requests of a batch
+type BatchResult struct {
+	Accepted   int
+	Sampled    int
+	Rejected   []Rejection
+	RequestIDs []string
+}
+
+// Query selects stored entries; zero fields match everything
+type Query struct {
+	Source string
+	Level  string
+	From   time.Time
+	To     time.Time
+	Limit  int // entries per page, 100 by default and at most 1000
+	Before int // only entries stored before the one with this id, see QueryResult.NextBefore
+}
+
+// QueryResult is a page of stored entries, most recently stored first
+type QueryResult struct {
+	Logs  []models.Log `json:"logs"`
+	Count int          `json:"count"`
+	// NextBefore is the Before of the next page, or 0 on the last one
+	NextBefore int `json:"next_before"`
+}
+
+// TailOptions selects the entries followed by Tail
+type TailOptions struct {
+	Level        string
+	Source       string
+	IncludeNoise bool // include entries the processor classified as noise
+}
+
+// New returns a client of the service at cfg.URL
+func New(cfg Config) (*Client, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid log ingestion url %q", cfg.URL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+
+	c := &Client{
+		baseURL:    u,
+		apiKey:     cfg.APIKey,
+		header:     cfg.APIKeyHeader,
+		maxRetries: cfg.MaxRetries,
+		backoff:    cfg.RetryBackoff,
+		maxBackoff: cfg.MaxBackoff,
+		gzip:       cfg.Gzip,
+		http:       cfg.HTTPClient,
+	}
+	if c.header == "" {
+		c.header = "X-API-Key"
+	}
+	switch {
+	case c.maxRetries == 0:
+		c.maxRetries = 3
+	case c.maxRetries < 0:
+		c.maxRetries = 0
+	}
+	if c.backoff <= 0 {
+		c.backoff = 500 * time.Millisecond
+	}
+	if c.maxBackoff <= 0 {
+		c.maxBackoff = 30 * time.Second
+	}
+	if c.http == nil {
+		timeout := cfg.Timeout
+		if timeout <= 0 {
+			timeout = 10 * time.Second
+		}
+		c.http = &http.Client{Timeout: timeout}
+	}
+	return c, nil
+}
+
+// Send ingests one entry through /ingest. An entry the service could not
+// ingest but kept for replay succeeds with the dead_lettered status.
+func (c *Client) Send(ctx context.Context, entry models.Log) (*Result, error) {
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	var result Result
+	if err := c.do(ctx, http.MethodPost, "/ingest", nil, body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SendBatch ingests entries through /ingest/batch, in requests of up to
+// MaxBatchEntries. Entries the service rejects are reported by their index
+// in entries, those it could not store marked Retryable; a failed request
+// stops the batch and returns what the earlier requests ingested along with
+// the error.
+func (c *Client) SendBatch(ctx context.Context, entries []models.Log) (*BatchResult, error) {
+	result := &BatchResult{Rejected: []Rejection{}}
+	for start := 0; start < len(entries); start += MaxBatchEntries {
+		end := start + MaxBatchEntries
+		if end > len(entries) {
+			end = len(entries)
+		}
+		body, err := json.Marshal(entries[start:end])
+		if err != nil {
+			return result, err
+		}
+
+		var response struct {
+			Accepted  int         `json:"accepted"`
+			Sampled   int         `json:"sampled"`
+			Rejected  []Rejection `json:"rejected"`
+			RequestID string      `json:"request_id"`
+		}
+		if err := c.do(ctx, http.MethodPost, "/ingest/batch", nil, body, &response); err != nil {
+			return result, err
+		}
+		result.Accepted += response.Accepted
+		result.Sampled += response.Sampled
+		for _, rejection := range response.Rejected {
+			rejection.Index += start
+			result.Rejected = append(result.Rejected, rejection)
+		}
+		result.RequestIDs = append(result.RequestIDs, response.RequestID)
+	}
+	return result, nil
+}
+
+// Query returns a page of stored entries matching q from GET /logs
+func (c *Client) Query(ctx context.Context, q Query) (*QueryResult, error) {
+	params := url.Values{}
+	if q.Source != "" {
+		params.Set("source", q.Source)
+	}
+	if q.Level != "" {
+		params.Set("level", q.Level)
+	}
+	if !q.From.IsZero() {
+		params.Set("from", q.From.Format(time.RFC3339))
+	}
+	if !q.To.IsZero() {
+		params.Set("to", q.To.Format(time.RFC3339))
+	}
+	if q.Limit > 0 {
+		params.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.Before > 0 {
+		params.Set("before", strconv.Itoa(q.Before))
+	}
+
+	var result QueryResult
+	if err := c.do(ctx, http.MethodGet, "/logs", params, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Tail follows /logs/tail, calling fn with each entry as it is stored,
+// until ctx is done, fn returns an error, or the service refuses the
+// stream. Dropped streams are reopened with backoff; entries stored while
+// reconnecting are missed.
+func (c *Client) Tail(ctx context.Context, opts TailOptions, fn func(models.Log) error) error {
+	params := url.Values{}
+	if opts.Level != "" {
+		params.Set("level", opts.Level)
+	}
+	if opts.Source != "" {
+		params.Set("source", opts.Source)
+	}
+	if opts.IncludeNoise {
+		params.Set("include_noise", "true")
+	}
+	// The stream lasts as long as ctx, past any client timeout
+	stream := *c.http
+	stream.Timeout = 0
+
+	backoff := c.backoff
+	for {
+		connected, err := c.tail(ctx, &stream, params, fn)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var callback *callbackError
+		if errors.As(err, &callback) {
+			return callback.err
+		}
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && !apiErr.Temporary() {
+			return err
+		}
+		if connected {
+			backoff = c.backoff
+		}
+
+		if err := sleep(ctx, retryWait(err, backoff)); err != nil {
+			return err
+		}
+		if backoff *= 2; backoff > c.maxBackoff {
+			backoff = c.maxBackoff
+		}
+	}
+}
+
+// callbackError is an error returned by the function passed to Tail
+type callbackError struct {
+	err error
+}
+
+func (e *callbackError) Error() string {
+	return e.err.Error()
+}
+
+// tail reads one stream until it ends, reporting whether it was opened
+func (c *Client) tail(ctx context.Context, stream *http.Client, params url.Values, fn func(models.Log) error) (bool, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, "/logs/tail", params, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := stream.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, responseError(resp)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	var event string
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			// A blank line ends the event
+			if (event == "" || event == "log") && len(data) > 0 {
+				var entry models.Log
+				if err := json.Unmarshal([]byte(strings.Join(data, "\n")), &entry); err != nil {
+					return true, fmt.Errorf("invalid tail event: %w", err)
+				}
+				if err := fn(entry); err != nil {
+					return true, &callbackError{err: err}
+				}
+			}
+			event, data = "", data[:0]
+		case strings.HasPrefix(line, ":"):
+			// Heartbeat comment
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return true, err
+	}
+	return true, io.ErrUnexpectedEOF
+}
+
+// do sends a request, retrying network errors, timeouts, throttling and
+// server errors with exponential backoff, and decodes the JSON response
+// into out unless it is nil
+func (c *Client) do(ctx context.Context, method, path string, params url.Values, body []byte, out interface{}) error {
+	if c.gzip && body != nil {
+		compressed, err := compress(body)
+		if err != nil {
+			return err
+		}
+		body = compressed
+	}
+
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		err := c.attempt(ctx, method, path, params, body, out)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && !apiErr.Temporary() || attempt >= c.maxRetries {
+			return err
+		}
+
+		if err := sleep(ctx, retryWait(err, backoff)); err != nil {
+			return err
+		}
+		if backoff *= 2; backoff > c.maxBackoff {
+			backoff = c.maxBackoff
+		}
+	}
+}
+
+func (c *Client) attempt(ctx context.Context, method, path string, params url.Values, body []byte, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := c.newRequest(ctx, method, path, params, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+		if c.gzip {
+			req.Header.Set("Content-Encoding", "gzip")
+		}
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return responseError(resp)
+	}
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid log ingestion response: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) newRequest(ctx context.Context, method, path string, params url.Values, body io.Reader) (*http.Request, error) {
+	u := *c.baseURL
+	u.Path += path
+	u.RawQuery = params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set(c.header, c.apiKey)
+	}
+	return req, nil
+}
+
+// responseError reads an error response into an APIError, taking the
+// message of JSON bodies and the text of plain ones
+func responseError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Message:    strings.TrimSpace(string(body)),
+		RequestID:  resp.Header.Get("X-Request-ID"),
+	}
+	var response struct {
+		Message   string `json:"message"`
+		RequestID string `json:"request_id"`
+	}
+	if json.Unmarshal(body, &response) == nil && response.Message != "" {
+		apiErr.Message = response.Message
+		if response.RequestID != "" {
+			apiErr.RequestID = response.RequestID
+		}
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return apiErr
+}
+
+// retryWait is the backoff, or the wait the service asked for
+func retryWait(err error, backoff time.Duration) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return apiErr.RetryAfter
+	}
+	return backoff
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func compress(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,240 @@
+package client
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc, cfg Config) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	cfg.URL = server.URL
+	cfg.RetryBackoff = time.Millisecond
+	c, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	return c
+}
+
+func TestNew_InvalidURL(t *testing.T) {
+	for _, u := range []string{"", "localhost:8080", "ftp://logs"} {
+		if _, err := New(Config{URL: u}); err == nil {
+			t.Errorf("Expected an error for %q", u)
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ingest" || r.Header.Get("X-Token") != "secret" || r.Header.Get("Content-Encoding") != "gzip" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, "Invalid gzip request body", http.StatusBadRequest)
+			return
+		}
+		var entry models.Log
+		if err := json.NewDecoder(zr).Decode(&entry); err != nil || entry.Message != "hello" {
+			http.Error(w, "unexpected entry", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprint(w, `{"status":"accepted","message":"Log entry ingested","request_id":"req-1"}`)
+	}, Config{APIKey: "secret", APIKeyHeader: "X-Token", Gzip: true})
+
+	result, err := c.Send(context.Background(), models.Log{Message: "hello", Level: "info"})
+	if err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+	if result.Status != "accepted" || result.RequestID != "req-1" {
+		t.Errorf("Unexpected result %+v", result)
+	}
+}
+
+func TestSend_Retries(t *testing.T) {
+	var attempts int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			http.Error(w, "Failed to store log entry", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprint(w, `{"status":"accepted"}`)
+	}, Config{})
+
+	if _, err := c.Send(context.Background(), models.Log{Message: "hello", Level: "info"}); err != nil {
+		t.Fatalf("Expected the third attempt to succeed, got %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestSend_PermanentError(t *testing.T) {
+	var attempts int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.Header().Set("X-Request-ID", "req-2")
+		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+	}, Config{})
+
+	_, err := c.Send(context.Background(), models.Log{Message: "hello", Level: "info"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || apiErr.Message != "Invalid JSON format" || apiErr.RequestID != "req-2" {
+		t.Fatalf("Expected a 400 APIError, got %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("Expected a single attempt, got %d", attempts)
+	}
+}
+
+func TestSend_RetryAfter(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, `{"status":"maintenance","message":"Ingestion is in maintenance mode, retry later"}`)
+	}, Config{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := c.Send(ctx, models.Log{Message: "hello", Level: "info"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the wait for Retry-After to outlast the context, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("Expected the context to cut the wait short")
+	}
+}
+
+func TestSendBatch_Chunks(t *testing.T) {
+	var requests int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		var entries []models.Log
+		json.NewDecoder(r.Body).Decode(&entries)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":     "accepted",
+			"accepted":   len(entries) - 1,
+			"rejected":   []Rejection{{Index: 0, Error: "invalid log level"}},
+			"request_id": fmt.Sprintf("req-%d", n),
+		})
+	}, Config{})
+
+	entries := make([]models.Log, MaxBatchEntries+5)
+	result, err := c.SendBatch(context.Background(), entries)
+	if err != nil {
+		t.Fatalf("Failed to send batch: %v", err)
+	}
+	if requests != 2 || result.Accepted != len(entries)-2 || len(result.RequestIDs) != 2 {
+		t.Fatalf("Expected two requests, got %d: %+v", requests, result)
+	}
+	if len(result.Rejected) != 2 || result.Rejected[1].Index != MaxBatchEntries {
+		t.Errorf("Expected rejections indexed into the whole batch, got %+v", result.Rejected)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.Method != http.MethodGet || r.URL.Path != "/logs" || q.Get("source") != "api" || q.Get("from") != "2024-01-01T00:00:00Z" || q.Get("before") != "42" || q.Has("level") {
+			http.Error(w, "unexpected query "+r.URL.RawQuery, http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"logs":[{"id":41,"message":"hello","level":"info","source":"api"}],"count":1,"next_before":41}`)
+	}, Config{})
+
+	result, err := c.Query(context.Background(), Query{
+		Source: "api",
+		From:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Before: 42,
+	})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if result.Count != 1 || result.Logs[0].ID != 41 || result.NextBefore != 41 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+}
+
+func TestTail(t *testing.T) {
+	var connections int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&connections, 1)
+		if r.URL.Query().Get("level") != "error" {
+			http.Error(w, "unexpected query", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": heartbeat\n\n")
+		fmt.Fprintf(w, "id: %d\nevent: log\ndata: {\"id\":%d,\"message\":\"entry %d\",\"level\":\"error\"}\n\n", n, n, n)
+		// Ending the response drops the stream, which the client reopens
+	}, Config{Timeout: time.Millisecond})
+
+	var received []string
+	err := c.Tail(context.Background(), TailOptions{Level: "error"}, func(entry models.Log) error {
+		received = append(received, entry.Message)
+		if len(received) == 2 {
+			return io.EOF
+		}
+		return nil
+	})
+	if err != io.EOF {
+		t.Fatalf("Expected the callback's error, got %v", err)
+	}
+	if len(received) != 2 || received[0] != "entry 1" || received[1] != "entry 2" {
+		t.Errorf("Expected an entry from each of two streams, got %v", received)
+	}
+}
+
+func TestTail_Refused(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	}, Config{})
+
+	err := c.Tail(context.Background(), TailOptions{}, func(models.Log) error { return nil })
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected a 401 APIError, got %v", err)
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/admin/api-keys":
+			fmt.Fprint(w, `{"api_keys":[{"id":3,"name":"shipper","scopes":["ingest"]}],"count":1}`)
+		case r.Method == http.MethodDelete && r.URL.Path == "/admin/api-keys/3":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}, Config{})
+
+	keys, err := c.ListAPIKeys(context.Background())
+	if err != nil || len(keys) != 1 || keys[0].Name != "shipper" {
+		t.Fatalf("Expected one key, got %v, %v", keys, err)
+	}
+	if err := c.RevokeAPIKey(context.Background(), 3); err != nil {
+		t.Errorf("Failed to revoke: %v", err)
+	}
+	var apiErr *APIError
+	if err := c.RevokeAPIKey(context.Background(), 4); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected a 404 APIError, got %v", err)
+	}
+}
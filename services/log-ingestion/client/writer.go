@@ -0,0 +1,258 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"log-processing-system/services/log-ingestion/logfile"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+// ErrWriterClosed is returned by writes to a closed Writer
+var ErrWriterClosed = errors.New("log writer is closed")
+
+// WriterConfig configures a Writer. Zero values select the defaults.
+type WriterConfig struct {
+	// Mapping maps written lines to entries. By default each line is the
+	// message of an entry with Mapping.Level and Mapping.Source; Format
+	// logfile.NDJSON takes apart the JSON lines of loggers such as slog or
+	// zap, with Keys naming their message, level and timestamp keys. Lines
+	// that do not map are sent whole as messages.
+	Mapping       logfile.Config
+	BatchSize     int           // entries per request, 100 by default
+	FlushInterval time.Duration // longest wait before buffered entries are sent, 1s by default
+	MaxBuffered   int           // entries buffered before further lines are dropped, 10000 by default
+	// OnError is called from the Writer's goroutine with failed requests
+	// and rejected entries, which are dropped. It must not write to the
+	// Writer.
+	OnError func(error)
+}
+
+// Writer is an io.Writer that ships each written line to the service as an
+// entry, so the standard log package or any logger writing to an io.Writer
+// can log into it:
+//
+//	w, err := c.NewWriter(client.WriterConfig{Mapping: logfile.Config{Source: "billing"}})
+//	if err != nil {
+//		return err
+//	}
+//	defer w.Close()
+//	log.SetOutput(io.MultiWriter(os.Stderr, w))
+//
+// Writes only buffer; entries are sent in batches from a goroutine, so
+// logging does not wait for the service. It is safe for concurrent use.
+type Writer struct {
+	client   *Client
+	mapper   *logfile.Mapper
+	fallback *logfile.Mapper
+	cfg      WriterConfig
+
+	mu      sync.Mutex
+	partial []byte // a line not yet ended
+	pending []models.Log
+	dropped int64
+	closed  bool
+
+	wake    chan struct{}
+	flushes chan chan error
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewWriter returns a Writer sending through c; Close it to send what it
+// buffers
+func (c *Client) NewWriter(cfg WriterConfig) (*Writer, error) {
+	if cfg.BatchSize <= 0 || cfg.BatchSize > MaxBatchEntries {
+		cfg.BatchSize = 100
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	if cfg.MaxBuffered <= 0 {
+		cfg.MaxBuffered = 10000
+	}
+	mapper, err := logfile.NewMapper(cfg.Mapping)
+	if err != nil {
+		return nil, err
+	}
+	fallback := cfg.Mapping
+	fallback.Format, fallback.Template, fallback.Keys = logfile.Text, "", nil
+	fallbackMapper, err := logfile.NewMapper(fallback)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Writer{
+		client:   c,
+		mapper:   mapper,
+		fallback: fallbackMapper,
+		cfg:      cfg,
+		wake:     make(chan struct{}, 1),
+		flushes:  make(chan chan error),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go w.run()
+	return w, nil
+}
+
+// Write buffers the complete lines of p as entries; the rest of p waits for
+// its line break, or Flush or Close. It fails only once the Writer is
+// closed.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, ErrWriterClosed
+	}
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		w.add(string(w.partial[:i]))
+		w.partial = w.partial[i+1:]
+	}
+	if len(w.pending) >= w.cfg.BatchSize {
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+	}
+	return len(p), nil
+}
+
+// add maps a line to an entry and buffers it; w.mu must be held
+func (w *Writer) add(line string) {
+	line = strings.TrimRight(line, "\r")
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if len(w.pending) >= w.cfg.MaxBuffered {
+		w.dropped++
+		return
+	}
+	entry, err := w.mapper.Map(line)
+	if err != nil {
+		if entry, err = w.fallback.Map(line); err != nil {
+			w.dropped++
+			return
+		}
+	}
+	if entry.Timestamp.IsZero() {
+		// Stamped when written, not when the batch is sent
+		entry.Timestamp = time.Now().UTC()
+	}
+	w.pending = append(w.pending, entry)
+}
+
+// Flush sends the buffered entries, including a line not yet ended, and
+// returns the error of the first failed request
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return ErrWriterClosed
+	}
+	w.endLine()
+	w.mu.Unlock()
+
+	reply := make(chan error)
+	w.flushes <- reply
+	return <-reply
+}
+
+// Close sends the buffered entries and stops the Writer
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.endLine()
+	w.mu.Unlock()
+
+	close(w.stop)
+	<-w.done
+	return nil
+}
+
+// Dropped returns how many lines were dropped because the buffer was full
+// or the service did not take them
+func (w *Writer) Dropped() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.dropped
+}
+
+// endLine buffers the line not yet ended; w.mu must be held
+func (w *Writer) endLine() {
+	if len(w.partial) > 0 {
+		w.add(string(w.partial))
+		w.partial = nil
+	}
+}
+
+// run sends batches when one is full, every FlushInterval and on Flush,
+// until Close
+func (w *Writer) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.cfg.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.wake:
+			w.send(true)
+		case <-ticker.C:
+			w.send(false)
+		case reply := <-w.flushes:
+			reply <- w.send(false)
+		case <-w.stop:
+			w.send(false)
+			return
+		}
+	}
+}
+
+// send sends the buffered entries, or with full only complete batches
+func (w *Writer) send(full bool) error {
+	var first error
+	for {
+		w.mu.Lock()
+		n := len(w.pending)
+		if n > w.cfg.BatchSize {
+			n = w.cfg.BatchSize
+		}
+		if n == 0 || full && n < w.cfg.BatchSize {
+			w.mu.Unlock()
+			return first
+		}
+		batch := make([]models.Log, n)
+		copy(batch, w.pending)
+		w.pending = append(w.pending[:0], w.pending[n:]...)
+		w.mu.Unlock()
+
+		result, err := w.client.SendBatch(context.Background(), batch)
+		if err == nil && len(result.Rejected) > 0 {
+			err = fmt.Errorf("%d of %d entries rejected: %s", len(result.Rejected), n, result.Rejected[0].Error)
+			n = len(result.Rejected)
+		}
+		if err != nil {
+			w.mu.Lock()
+			w.dropped += int64(n)
+			w.mu.Unlock()
+			if w.cfg.OnError != nil {
+				w.cfg.OnError(err)
+			}
+			if first == nil {
+				first = err
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/logfile"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+// recordBatches returns a handler accepting batches and the entries it got
+func recordBatches() (http.HandlerFunc, func() [][]models.Log) {
+	var mu sync.Mutex
+	var batches [][]models.Log
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		var entries []models.Log
+		json.NewDecoder(r.Body).Decode(&entries)
+		mu.Lock()
+		batches = append(batches, entries)
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{"accepted": len(entries), "rejected": []Rejection{}})
+	}
+	return handler, func() [][]models.Log {
+		mu.Lock()
+		defer mu.Unlock()
+		return batches
+	}
+}
+
+func TestWriter_StandardLog(t *testing.T) {
+	handler, batches := recordBatches()
+	c := newTestClient(t, handler, Config{})
+	w, err := c.NewWriter(WriterConfig{Mapping: logfile.Config{Level: "warn", Source: "billing"}, BatchSize: 2, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	logger := log.New(w, "", 0)
+	logger.Print("card declined")
+	logger.Print("retrying payment")
+	fmt.Fprint(w, "unfinished")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(batches()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := batches(); len(got) != 1 || len(got[0]) != 2 || got[0][0].Message != "card declined" || got[0][0].Level != "warn" || got[0][0].Source != "billing" || got[0][0].Timestamp.IsZero() {
+		t.Fatalf("Expected a full batch of two entries, got %+v", got)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to close writer: %v", err)
+	}
+	if got := batches(); len(got) != 2 || got[1][0].Message != "unfinished" {
+		t.Errorf("Expected the unfinished line to be sent on close, got %+v", got)
+	}
+	if _, err := w.Write([]byte("late\n")); err != ErrWriterClosed {
+		t.Errorf("Expected writes after close to fail, got %v", err)
+	}
+}
+
+func TestWriter_JSONLines(t *testing.T) {
+	handler, batches := recordBatches()
+	c := newTestClient(t, handler, Config{})
+	w, err := c.NewWriter(WriterConfig{Mapping: logfile.Config{
+		Format: logfile.NDJSON,
+		Keys:   map[string]string{"message": "msg", "timestamp": "time"},
+		Source: "checkout",
+	}})
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	defer w.Close()
+	fmt.Fprintln(w, `{"time":"2026-01-02T03:04:05Z","level":"ERROR","msg":"payment failed","order":42}`)
+	fmt.Fprintln(w, "panic: not JSON")
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Failed to flush: %v", err)
+	}
+
+	got := batches()
+	if len(got) != 1 || len(got[0]) != 2 {
+		t.Fatalf("Expected one batch of two entries, got %+v", got)
+	}
+	entry := got[0][0]
+	if entry.Message != "payment failed" || entry.Level != "error" || entry.Source != "checkout" || entry.Timestamp.Year() != 2026 || entry.Fields["order"] != float64(42) {
+		t.Errorf("Unexpected entry %+v", entry)
+	}
+	if got[0][1].Message != "panic: not JSON" || got[0][1].Level != "info" {
+		t.Errorf("Expected the line that is not JSON to be sent whole, got %+v", got[0][1])
+	}
+}
+
+func TestWriter_DropsWhenFull(t *testing.T) {
+	var errs []error
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid API key", http.StatusUnauthorized)
+	}, Config{})
+	w, _ := c.NewWriter(WriterConfig{MaxBuffered: 2, FlushInterval: time.Hour, OnError: func(err error) { errs = append(errs, err) }})
+	fmt.Fprint(w, "one\ntwo\nthree\n")
+	if err := w.Flush(); err == nil {
+		t.Error("Expected the refused batch to be reported")
+	}
+	w.Close()
+	if w.Dropped() != 3 || len(errs) != 1 {
+		t.Errorf("Expected three dropped lines and one error, got %d and %v", w.Dropped(), errs)
+	}
+}
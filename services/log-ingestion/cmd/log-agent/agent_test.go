@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func TestAgent_TailsRotatesAndRetries(t *testing.T) {
+	var mu sync.Mutex
+	var received []models.Log
+	failures := 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if failures > 0 {
+			failures--
+			http.Error(w, "maintenance", http.StatusServiceUnavailable)
+			return
+		}
+		var entries []models.Log
+		json.NewDecoder(r.Body).Decode(&entries)
+		received = append(received, entries...)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{"accepted": len(entries), "rejected": []interface{}{}})
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	os.WriteFile(path, []byte("2026-01-02T03:04:05Z ERROR first\n"), 0o644)
+	cfg := &agentConfig{
+		URL:           server.URL,
+		SpoolDir:      filepath.Join(dir, "spool"),
+		BatchInterval: 10 * time.Millisecond,
+		PollInterval:  10 * time.Millisecond,
+		MaxBackoff:    10 * time.Millisecond,
+		Inputs: []inputConfig{{
+			Paths:    []string{filepath.Join(dir, "*.log")},
+			Template: "{timestamp} {level} {message}",
+			StartAt:  "beginning",
+		}},
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("Invalid configuration: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- run(ctx, cfg, logger.New(logger.Config{Service: "log-agent", Component: "test"})) }()
+
+	// A partial line waits for its line break; then the file is rotated
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString("2026-01-02T03:04:06Z warning sec")
+	time.Sleep(50 * time.Millisecond)
+	f.WriteString("ond\n")
+	f.Close()
+	time.Sleep(50 * time.Millisecond)
+	os.Rename(path, filepath.Join(dir, "app.log.1"))
+	os.WriteFile(path, []byte("2026-01-02T03:04:07Z info third\n"), 0o644)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(received)
+		mu.Unlock()
+		if n >= 3 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Agent failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var got []string
+	for _, entry := range received {
+		got = append(got, fmt.Sprintf("%s %s %s", entry.Level, entry.Source, entry.Message))
+	}
+	want := []string{"error app.log first", "warn app.log second", "info app.log third"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	cfg := &agentConfig{Inputs: []inputConfig{{Format: "xml", StartAt: "middle"}}}
+	err := cfg.validate()
+	if err == nil {
+		t.Fatal("Expected an invalid configuration")
+	}
+	for _, problem := range []string{"url is required", "paths are required", "invalid format", "start_at"} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("Expected %q to be reported, got %v", problem, err)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.yml")
+	os.WriteFile(path, []byte(`
+url: http://localhost:8080
+api_key: ${LOG_AGENT_TEST_KEY}
+batch_interval: 5s
+inputs:
+  - paths: [/var/log/app/*.log]
+    format: ndjson
+    keys: {message: msg}
+    fields: {host: web-1}
+`), 0o644)
+	os.Setenv("LOG_AGENT_TEST_KEY", "lps_test")
+	defer os.Unsetenv("LOG_AGENT_TEST_KEY")
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+	if cfg.APIKey != "lps_test" || cfg.BatchInterval != 5*time.Second || cfg.BatchSize != 1000 || cfg.Inputs[0].Keys["message"] != "msg" || cfg.Inputs[0].Fields["host"] != "web-1" {
+		t.Errorf("Unexpected configuration: %+v", cfg)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"log-processing-system/services/log-ingestion/client"
+	"log-processing-system/services/log-ingestion/logfile"
+	"log-processing-system/services/log-ingestion/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+// agentConfig is the agent's YAML configuration file. ${VAR} references are
+// replaced with environment variables before parsing, so that keys need not
+// be written into it.
+type agentConfig struct {
+	URL           string        `yaml:"url"`
+	APIKey        string        `yaml:"api_key"`
+	APIKeyHeader  string        `yaml:"api_key_header"`
+	Gzip          bool          `yaml:"gzip"`
+	SpoolDir      string        `yaml:"spool_dir"`       // spooled entries and read offsets
+	SpoolMaxBytes int64         `yaml:"spool_max_bytes"` // unshipped entries kept before reading pauses
+	BatchSize     int           `yaml:"batch_size"`      // entries per request
+	BatchInterval time.Duration `yaml:"batch_interval"`  // longest wait for a batch to fill
+	PollInterval  time.Duration `yaml:"poll_interval"`   // how often files are checked for new lines
+	MaxBackoff    time.Duration `yaml:"max_backoff"`     // longest wait between failed requests
+	Inputs        []inputConfig `yaml:"inputs"`
+}
+
+// inputConfig is a set of files read the same way
+type inputConfig struct {
+	Paths      []string          `yaml:"paths"` // glob patterns
+	Format     string            `yaml:"format"`
+	Template   string            `yaml:"template"`
+	Keys       map[string]string `yaml:"keys"`
+	TimeFormat string            `yaml:"time_format"`
+	Level      string            `yaml:"level"`
+	Source     string            `yaml:"source"` // the file name by default
+	Fields     models.Fields     `yaml:"fields"`
+	StartAt    string            `yaml:"start_at"` // end or beginning, for files found at startup
+}
+
+// Defaults of the configuration
+const (
+	defaultSpoolDir      = "/var/lib/log-agent"
+	defaultSpoolMaxBytes = 256 << 20
+	defaultBatchInterval = 2 * time.Second
+	defaultPollInterval  = time.Second
+	defaultMaxBackoff    = time.Minute
+)
+
+// loadConfig reads and validates the configuration file at path
+func loadConfig(path string) (*agentConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &agentConfig{}
+	if err := yaml.Unmarshal([]byte(os.ExpandEnv(string(data))), cfg); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// validate fills in defaults and reports every invalid setting at once
+func (c *agentConfig) validate() error {
+	if c.SpoolDir == "" {
+		c.SpoolDir = defaultSpoolDir
+	}
+	if c.SpoolMaxBytes == 0 {
+		c.SpoolMaxBytes = defaultSpoolMaxBytes
+	}
+	if c.BatchSize == 0 {
+		c.BatchSize = client.MaxBatchEntries
+	}
+	if c.BatchInterval == 0 {
+		c.BatchInterval = defaultBatchInterval
+	}
+	if c.PollInterval == 0 {
+		c.PollInterval = defaultPollInterval
+	}
+	if c.MaxBackoff == 0 {
+		c.MaxBackoff = defaultMaxBackoff
+	}
+
+	var problems []string
+	if c.URL == "" {
+		problems = append(problems, "url is required")
+	}
+	if c.SpoolMaxBytes < 0 {
+		problems = append(problems, "spool_max_bytes must be positive")
+	}
+	if c.BatchSize < 0 || c.BatchSize > client.MaxBatchEntries {
+		problems = append(problems, fmt.Sprintf("batch_size must be between 1 and %d", client.MaxBatchEntries))
+	}
+	if c.BatchInterval < 0 || c.PollInterval < 0 || c.MaxBackoff < 0 {
+		problems = append(problems, "intervals must be positive")
+	}
+	if len(c.Inputs) == 0 {
+		problems = append(problems, "at least one input is required")
+	}
+	for i, input := range c.Inputs {
+		if len(input.Paths) == 0 {
+			problems = append(problems, fmt.Sprintf("inputs[%d]: paths are required", i))
+		}
+		for _, pattern := range input.Paths {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				problems = append(problems, fmt.Sprintf("inputs[%d]: invalid path %q", i, pattern))
+			}
+		}
+		if _, err := logfile.NewMapper(input.mapping()); err != nil {
+			problems = append(problems, fmt.Sprintf("inputs[%d]: %v", i, err))
+		}
+		switch input.StartAt {
+		case "", "end", "beginning":
+		default:
+			problems = append(problems, fmt.Sprintf("inputs[%d]: start_at must be end or beginning", i))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// mapping is how the lines of the input's files map to entries
+func (i inputConfig) mapping() logfile.Config {
+	return logfile.Config{
+		Format:     i.Format,
+		Template:   i.Template,
+		Keys:       i.Keys,
+		TimeFormat: i.TimeFormat,
+		Level:      i.Level,
+		Source:     i.Source,
+		Fields:     i.Fields,
+	}
+}
@@ -0,0 +1,95 @@
+// Command log-agent tails log files on a host and ships their lines to the
+// log ingestion service:
+//
+//	log-agent -config /etc/log-agent/agent.yml
+//
+// Lines are mapped to entries as logctl import maps them, then spooled to
+// disk before they are sent in batches, so entries survive restarts of the
+// agent and outages of the service. Read offsets are kept with the spool,
+// and a restarted agent resumes where it stopped.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"log-processing-system/services/log-ingestion/client"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/sdnotify"
+)
+
+func main() {
+	configPath := flag.String("config", "/etc/log-agent/agent.yml", "YAML configuration file")
+	flag.Parse()
+
+	appLogger := logger.NewFromEnv("log-agent", "main")
+	defer appLogger.Close()
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		appLogger.WithError(err).Fatal("Failed to load configuration")
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := run(ctx, cfg, appLogger); err != nil {
+		appLogger.WithError(err).Fatal("Log agent failed")
+	}
+}
+
+// run tails and ships until ctx is done
+func run(ctx context.Context, cfg *agentConfig, log *logger.Logger) error {
+	c, err := client.New(client.Config{
+		URL:          cfg.URL,
+		APIKey:       cfg.APIKey,
+		APIKeyHeader: cfg.APIKeyHeader,
+		Gzip:         cfg.Gzip,
+		// The shipper retries for as long as it takes
+		MaxRetries: -1,
+	})
+	if err != nil {
+		return err
+	}
+	s, err := openSpool(cfg.SpoolDir, cfg.SpoolMaxBytes)
+	if err != nil {
+		return fmt.Errorf("failed to open spool: %w", err)
+	}
+	defer s.close()
+	offsets, err := loadOffsets(cfg.SpoolDir)
+	if err != nil {
+		return err
+	}
+
+	t := newTailer(cfg.Inputs, s, offsets, cfg.PollInterval, log.WithComponent("tail"))
+	sh := &shipper{
+		client:     c,
+		spool:      s,
+		batchSize:  cfg.BatchSize,
+		interval:   cfg.BatchInterval,
+		maxBackoff: cfg.MaxBackoff,
+		log:        log.WithComponent("ship"),
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		t.run(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		sh.run(ctx)
+	}()
+	go sdnotify.RunWatchdog(ctx)
+	sdnotify.Notify(sdnotify.Ready, sdnotify.Status(fmt.Sprintf("Shipping to %s", cfg.URL)))
+	log.WithFields(map[string]interface{}{"url": cfg.URL, "spool_dir": cfg.SpoolDir, "inputs": len(cfg.Inputs)}).Info("Log agent started")
+
+	<-ctx.Done()
+	sdnotify.Notify(sdnotify.Stopping)
+	wg.Wait()
+	log.Info("Log agent stopped")
+	return nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+	"log-processing-system/services/log-ingestion/client"
+	"log-processing-system/services/log-ingestion/logger"
+)
+
+// initialBackoff is the wait after the first failed request, doubling after
+// each further one up to the configured maximum
+const initialBackoff = time.Second
+
+// shipper sends the spooled entries to the service in batches, committing
+// each batch only once the service answered for it. Failed requests are
+// retried until they succeed, so entries are delivered at least once.
+type shipper struct {
+	client     *client.Client
+	spool      *spool
+	batchSize  int
+	interval   time.Duration
+	maxBackoff time.Duration
+	log        *logger.Logger
+}
+
+// run ships entries until ctx is done
+func (s *shipper) run(ctx context.Context) {
+	first := initialBackoff
+	if first > s.maxBackoff {
+		first = s.maxBackoff
+	}
+	backoff := first
+	var waiting time.Time // since when a partial batch waits to fill
+	for ctx.Err() == nil {
+		entries, next, err := s.spool.read(s.batchSize)
+		if err != nil {
+			s.log.WithError(err).Error("Failed to read the spool")
+			s.sleep(ctx, backoff)
+			backoff = s.next(backoff)
+			continue
+		}
+		if len(entries) < s.batchSize {
+			if len(entries) > 0 && waiting.IsZero() {
+				waiting = time.Now()
+			}
+			if len(entries) == 0 || time.Since(waiting) < s.interval {
+				s.wait(ctx, waiting)
+				continue
+			}
+		}
+
+		result, err := s.client.SendBatch(ctx, entries)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			var apiErr *client.APIError
+			permanent := errors.As(err, &apiErr) && !apiErr.Temporary() &&
+				apiErr.StatusCode != http.StatusUnauthorized && apiErr.StatusCode != http.StatusForbidden
+			if !permanent {
+				// Outages, throttling and revoked keys are waited out
+				wait := backoff
+				if apiErr != nil && apiErr.RetryAfter > wait {
+					wait = apiErr.RetryAfter
+				}
+				s.log.WithError(err).WithFields(map[string]interface{}{"entries": len(entries), "retry_in": wait.String()}).Warn("Failed to ship entries, retrying")
+				s.sleep(ctx, wait)
+				backoff = s.next(backoff)
+				continue
+			}
+			// The service will not take this batch however often it is sent
+			s.log.WithError(err).WithField("entries", len(entries)).Error("Service refused entries, dropping them")
+		} else if len(result.Rejected) > 0 {
+			s.log.WithFields(map[string]interface{}{
+				"entries":  len(entries),
+				"rejected": len(result.Rejected),
+				"error":    result.Rejected[0].Error,
+			}).Warn("Service rejected entries")
+		}
+
+		if err := s.spool.commit(next); err != nil {
+			s.log.WithError(err).Error("Failed to record shipped entries")
+		}
+		backoff = first
+		waiting = time.Time{}
+	}
+}
+
+// wait waits for entries to be spooled, or until a partial batch that began
+// waiting at since is due
+func (s *shipper) wait(ctx context.Context, since time.Time) {
+	timeout := s.interval
+	if !since.IsZero() {
+		timeout = time.Until(since.Add(s.interval))
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-s.spool.ready:
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+func (s *shipper) sleep(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+func (s *shipper) next(backoff time.Duration) time.Duration {
+	if backoff *= 2; backoff > s.maxBackoff {
+		return s.maxBackoff
+	}
+	return backoff
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+// errSpoolFull is returned by append when the unshipped entries reached the
+// spool's limit; reading pauses until the shipper catches up
+var errSpoolFull = errors.New("spool is full")
+
+// segmentBytes is the size at which the spool starts a new segment file;
+// segments are removed once all their entries are shipped
+const segmentBytes = 8 << 20
+
+// spool is an on-disk queue of entries between the tailers and the shipper,
+// kept as NDJSON segment files, so that entries survive restarts of the
+// agent and outages of the service. The cursor file records the first entry
+// not yet shipped.
+type spool struct {
+	dir      string
+	maxBytes int64
+
+	mu       sync.Mutex
+	segments []int64 // sequence numbers of the segment files, oldest first
+	sizes    map[int64]int64
+	out      *os.File // the newest segment, appended to
+	cursor   spoolCursor
+	ready    chan struct{} // signalled when entries are appended
+}
+
+// spoolCursor is a position in the spool
+type spoolCursor struct {
+	Segment int64 `json:"segment"`
+	Offset  int64 `json:"offset"`
+}
+
+// openSpool opens the spool in dir, creating it if needed. Entries are
+// appended to a new segment, so a line torn by a crash ends its segment and
+// is skipped when read.
+func openSpool(dir string, maxBytes int64) (*spool, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &spool{dir: dir, maxBytes: maxBytes, sizes: make(map[int64]int64), ready: make(chan struct{}, 1)}
+	names, err := filepath.Glob(filepath.Join(dir, "*.ndjson"))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		seq, err := strconv.ParseInt(strings.TrimSuffix(filepath.Base(name), ".ndjson"), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			return nil, err
+		}
+		s.segments = append(s.segments, seq)
+		s.sizes[seq] = info.Size()
+	}
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i] < s.segments[j] })
+
+	data, err := os.ReadFile(s.cursorPath())
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &s.cursor); err != nil {
+			return nil, fmt.Errorf("invalid spool cursor %s: %w", s.cursorPath(), err)
+		}
+	case errors.Is(err, os.ErrNotExist):
+		if len(s.segments) > 0 {
+			s.cursor.Segment = s.segments[0]
+		}
+	default:
+		return nil, err
+	}
+	s.removeShipped()
+
+	next := s.cursor.Segment + 1
+	if n := len(s.segments); n > 0 && s.segments[n-1] >= next {
+		next = s.segments[n-1] + 1
+	}
+	if err := s.startSegment(next); err != nil {
+		return nil, err
+	}
+	if len(s.segments) == 1 {
+		// Nothing is left to ship: the cursor moves to the new segment
+		s.cursor = spoolCursor{Segment: next}
+	}
+	return s, nil
+}
+
+func (s *spool) segmentPath(seq int64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%012d.ndjson", seq))
+}
+
+func (s *spool) cursorPath() string {
+	return filepath.Join(s.dir, "cursor.json")
+}
+
+// startSegment makes a new segment the one appended to; s.mu must be held
+// once the spool is open
+func (s *spool) startSegment(seq int64) error {
+	f, err := os.OpenFile(s.segmentPath(seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if s.out != nil {
+		s.out.Close()
+	}
+	s.out = f
+	s.segments = append(s.segments, seq)
+	s.sizes[seq] = 0
+	return nil
+}
+
+// pending is the size of the entries not yet shipped; s.mu must be held
+func (s *spool) pending() int64 {
+	var total int64
+	for _, seq := range s.segments {
+		total += s.sizes[seq]
+	}
+	return total - s.cursor.Offset
+}
+
+// append adds entries to the spool, syncing them to disk before it returns
+func (s *spool) append(entries []models.Log) error {
+	var data []byte
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending()+int64(len(data)) > s.maxBytes && s.pending() > 0 {
+		return errSpoolFull
+	}
+	current := s.segments[len(s.segments)-1]
+	if s.sizes[current] >= segmentBytes {
+		if err := s.startSegment(current + 1); err != nil {
+			return err
+		}
+		current++
+	}
+	if _, err := s.out.Write(data); err != nil {
+		return err
+	}
+	if err := s.out.Sync(); err != nil {
+		return err
+	}
+	s.sizes[current] += int64(len(data))
+
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// read returns up to max entries from the cursor on, and the position after
+// them to commit once they are shipped
+func (s *spool) read(max int) ([]models.Log, spoolCursor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var entries []models.Log
+	pos := s.cursor
+	for len(entries) < max {
+		size, ok := s.sizes[pos.Segment]
+		if !ok || pos.Offset >= size {
+			// The segment is used up; move on unless it is the newest
+			i := sort.Search(len(s.segments), func(i int) bool { return s.segments[i] > pos.Segment })
+			if i == len(s.segments) {
+				break
+			}
+			pos = spoolCursor{Segment: s.segments[i]}
+			continue
+		}
+
+		f, err := os.Open(s.segmentPath(pos.Segment))
+		if err != nil {
+			return nil, s.cursor, err
+		}
+		if _, err := f.Seek(pos.Offset, io.SeekStart); err != nil {
+			f.Close()
+			return nil, s.cursor, err
+		}
+		r := bufio.NewReader(io.LimitReader(f, size-pos.Offset))
+		for len(entries) < max {
+			line, err := r.ReadBytes('\n')
+			if len(line) == 0 && err != nil {
+				break
+			}
+			pos.Offset += int64(len(line))
+			var entry models.Log
+			if json.Unmarshal(line, &entry) == nil {
+				entries = append(entries, entry)
+			}
+		}
+		f.Close()
+	}
+	return entries, pos, nil
+}
+
+// commit records that the entries before pos are shipped, and removes the
+// segments holding only shipped entries
+func (s *spool) commit(pos spoolCursor) error {
+	data, err := json.Marshal(pos)
+	if err != nil {
+		return err
+	}
+	tmp := s.cursorPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.cursorPath()); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cursor = pos
+	s.removeShipped()
+	return nil
+}
+
+// removeShipped removes the segments before the cursor's; s.mu must be held
+// once the spool is open
+func (s *spool) removeShipped() {
+	kept := s.segments[:0]
+	for _, seq := range s.segments {
+		if seq < s.cursor.Segment {
+			os.Remove(s.segmentPath(seq))
+			delete(s.sizes, seq)
+			continue
+		}
+		kept = append(kept, seq)
+	}
+	s.segments = kept
+}
+
+// close closes the segment appended to
+func (s *spool) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.out.Close()
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"log-processing-system/services/log-ingestion/client"
+	"log-processing-system/services/log-ingestion/logfile"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+// fingerprintBytes is how much of the start of a file identifies it, so that
+// a recorded offset is not applied to a different file at the same path
+const fingerprintBytes = 1024
+
+// tailer follows the files matching the inputs' globs and appends their
+// complete lines to the spool. Rotation by renaming is followed by reading
+// the rest of the old file before the new one; a truncated file is read
+// again from its start.
+type tailer struct {
+	inputs   []inputConfig
+	spool    *spool
+	offsets  *offsetStore
+	interval time.Duration
+	log      *logger.Logger
+
+	files   map[string]*tailedFile // by path
+	started bool                   // whether the first poll ran
+	full    bool                   // whether reading is paused for the spool
+}
+
+// tailedFile is a file being followed
+type tailedFile struct {
+	path   string
+	f      *os.File
+	mapper *logfile.Mapper
+	offset int64 // of the first byte not yet spooled
+}
+
+func newTailer(inputs []inputConfig, s *spool, offsets *offsetStore, interval time.Duration, log *logger.Logger) *tailer {
+	return &tailer{
+		inputs:   inputs,
+		spool:    s,
+		offsets:  offsets,
+		interval: interval,
+		log:      log,
+		files:    make(map[string]*tailedFile),
+	}
+}
+
+// run polls the files until ctx is done
+func (t *tailer) run(ctx context.Context) {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		t.poll()
+		select {
+		case <-ctx.Done():
+			t.closeAll()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll picks up new files, follows rotations and spools new lines
+func (t *tailer) poll() {
+	for _, input := range t.inputs {
+		for _, pattern := range input.Paths {
+			matches, _ := filepath.Glob(pattern)
+			for _, path := range matches {
+				if _, ok := t.files[path]; !ok {
+					t.open(path, input, t.started)
+				}
+			}
+		}
+	}
+	t.started = true
+
+	paths := make([]string, 0, len(t.files))
+	for path := range t.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if t.full {
+			break
+		}
+		t.follow(t.files[path])
+	}
+	if t.full {
+		// Retried on the next poll, once the shipper made room
+		t.full = false
+	}
+	if err := t.offsets.save(); err != nil {
+		t.log.WithError(err).Error("Failed to save read offsets")
+	}
+}
+
+// open starts following the file at path. A file seen before resumes at its
+// recorded offset; otherwise files found at startup start at their end
+// unless the input says beginning, and files appearing later at their start.
+func (t *tailer) open(path string, input inputConfig, appeared bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		t.log.WithError(err).WithField("path", path).Warn("Failed to open log file")
+		return
+	}
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		f.Close()
+		return
+	}
+	mapper, err := t.mapper(path, f, input)
+	if err != nil {
+		f.Close()
+		t.log.WithError(err).WithField("path", path).Error("Failed to set up log file")
+		return
+	}
+
+	file := &tailedFile{path: path, f: f, mapper: mapper}
+	if saved, ok := t.offsets.Files[path]; ok && saved.Offset <= info.Size() && saved.Fingerprint == fingerprint(f, saved.FingerprintLen) {
+		file.offset = saved.Offset
+	} else if !appeared && input.StartAt != "beginning" {
+		file.offset = info.Size()
+	}
+	t.files[path] = file
+	t.log.WithFields(map[string]interface{}{"path": path, "offset": file.offset}).Info("Following log file")
+}
+
+// mapper returns the mapper of the lines of f, with the format told by its
+// name and content unless the input sets it
+func (t *tailer) mapper(path string, f *os.File, input inputConfig) (*logfile.Mapper, error) {
+	mapping := input.mapping()
+	if mapping.Format == "" {
+		mapping.Format = logfile.DetectFormat(path, bufio.NewReader(f))
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+	if mapping.Source == "" {
+		mapping.Source = filepath.Base(path)
+	}
+	return logfile.NewMapper(mapping)
+}
+
+// follow spools the new lines of file, then checks whether its path now
+// names another file or the file was truncated
+func (t *tailer) follow(file *tailedFile) {
+	if !t.read(file) {
+		return
+	}
+	info, statErr := os.Stat(file.path)
+	current, err := file.f.Stat()
+	if err != nil {
+		return
+	}
+	switch {
+	case statErr != nil || !os.SameFile(info, current):
+		// Rotated or removed: the old file was read to its end above
+		file.f.Close()
+		delete(t.files, file.path)
+		t.offsets.forget(file.path)
+		if statErr == nil {
+			for _, input := range t.inputs {
+				if matchesAny(input.Paths, file.path) {
+					t.open(file.path, input, true)
+					if next, ok := t.files[file.path]; ok {
+						t.follow(next)
+					}
+					break
+				}
+			}
+		}
+	case current.Size() < file.offset:
+		t.log.WithField("path", file.path).Info("Log file was truncated, reading it from the start")
+		file.offset = 0
+		t.read(file)
+	}
+}
+
+// read spools the complete lines of file after its offset, and records the
+// offset after them. It returns false when the spool is full.
+func (t *tailer) read(file *tailedFile) bool {
+	if _, err := file.f.Seek(file.offset, io.SeekStart); err != nil {
+		t.log.WithError(err).WithField("path", file.path).Warn("Failed to read log file")
+		return true
+	}
+	r := bufio.NewReaderSize(file.f, 64*1024)
+	var batch []models.Log
+	var consumed int64
+	skipped := 0
+	flush := func() bool {
+		if consumed == 0 {
+			return true
+		}
+		if len(batch) > 0 {
+			if err := t.spool.append(batch); err != nil {
+				if errors.Is(err, errSpoolFull) {
+					t.log.Warn("Spool is full, pausing reading until entries are shipped")
+				} else {
+					t.log.WithError(err).Error("Failed to spool entries")
+				}
+				t.full = true
+				return false
+			}
+			batch = batch[:0]
+		}
+		file.offset += consumed
+		consumed = 0
+		t.offsets.record(file)
+		return true
+	}
+
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			// A line without its line break may still be being written
+			break
+		}
+		consumed += int64(len(line))
+		text := strings.TrimRight(string(line), "\r\n")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		entry, err := file.mapper.Map(text)
+		if err != nil {
+			skipped++
+			continue
+		}
+		batch = append(batch, entry)
+		if len(batch) >= client.MaxBatchEntries && !flush() {
+			return false
+		}
+	}
+	if skipped > 0 {
+		t.log.WithFields(map[string]interface{}{"path": file.path, "lines": skipped}).Warn("Skipped log lines that could not be mapped")
+	}
+	return flush()
+}
+
+func (t *tailer) closeAll() {
+	for _, file := range t.files {
+		file.f.Close()
+	}
+	if err := t.offsets.save(); err != nil {
+		t.log.WithError(err).Error("Failed to save read offsets")
+	}
+}
+
+func matchesAny(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// fingerprint hashes the first n bytes of f, or "" when it is shorter
+func fingerprint(f *os.File, n int) string {
+	buf := make([]byte, n)
+	if _, err := f.ReadAt(buf, 0); err != nil && n > 0 {
+		return ""
+	}
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:])
+}
+
+// offsetStore records how far each file was spooled, in offsets.json in the
+// spool directory
+type offsetStore struct {
+	path    string
+	Files   map[string]fileOffset `json:"files"`
+	changed bool
+}
+
+// fileOffset is how far a file was spooled, with a fingerprint of its start
+type fileOffset struct {
+	Offset         int64  `json:"offset"`
+	Fingerprint    string `json:"fingerprint"`
+	FingerprintLen int    `json:"fingerprint_len"`
+}
+
+func loadOffsets(dir string) (*offsetStore, error) {
+	store := &offsetStore{path: filepath.Join(dir, "offsets.json"), Files: make(map[string]fileOffset)}
+	data, err := os.ReadFile(store.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(bytes.TrimSpace(data), store); err != nil {
+		return nil, fmt.Errorf("invalid read offsets %s: %w", store.path, err)
+	}
+	if store.Files == nil {
+		store.Files = make(map[string]fileOffset)
+	}
+	return store, nil
+}
+
+// record records the offset of file, to be saved after the poll
+func (s *offsetStore) record(file *tailedFile) {
+	n := fingerprintBytes
+	if file.offset < int64(n) {
+		n = int(file.offset)
+	}
+	s.Files[file.path] = fileOffset{Offset: file.offset, Fingerprint: fingerprint(file.f, n), FingerprintLen: n}
+	s.changed = true
+}
+
+// forget drops the offset of a file that is gone
+func (s *offsetStore) forget(path string) {
+	delete(s.Files, path)
+	s.changed = true
+}
+
+// save writes the offsets atomically if they changed
+func (s *offsetStore) save() error {
+	if !s.changed {
+		return nil
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	s.changed = false
+	return nil
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"log-processing-system/services/log-ingestion/client"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+const benchUsage = `bench [flags]
+
+Sends synthetic entries for -duration, or until interrupted, and reports
+request latency percentiles and error rates. Requests are not retried, so
+every failure counts.
+`
+
+func runBench(ctx context.Context, c *cli, args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	duration := fs.Duration("duration", 30*time.Second, "how long to send")
+	rate := fs.Float64("rate", 100, "entries per second across all workers; 0 sends as fast as possible")
+	concurrency := fs.Int("concurrency", 4, "requests in flight at once")
+	batch := fs.Int("batch", 1, "entries per request; above 1 uses the batch endpoint")
+	size := fs.Int("size", 200, "message size in bytes")
+	levels := fs.String("levels", "info=70,warn=20,error=10", "level mix as comma-separated level=weight")
+	source := fs.String("source", "logctl-bench", "source of the entries")
+	interval := fs.Duration("report-interval", 5*time.Second, "interval of progress reports on standard error; 0 disables them")
+	if err := parseFlags(fs, c, benchUsage, args); err != nil {
+		return err
+	}
+	switch {
+	case *duration <= 0:
+		return usageError(fs, "-duration must be positive")
+	case *rate < 0:
+		return usageError(fs, "-rate must not be negative")
+	case *concurrency <= 0:
+		return usageError(fs, "-concurrency must be positive")
+	case *batch <= 0 || *batch > client.MaxBatchEntries:
+		return usageError(fs, "-batch must be between 1 and %d", client.MaxBatchEntries)
+	case *size <= 0:
+		return usageError(fs, "-size must be positive")
+	}
+	mix, err := parseLevelMix(*levels)
+	if err != nil {
+		return usageError(fs, "invalid -levels: %v", err)
+	}
+
+	// Retries would hide failures and skew latencies; the pool keeps a
+	// connection per worker
+	cfg := c.config
+	cfg.MaxRetries = -1
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = *concurrency
+	cfg.HTTPClient = &http.Client{Timeout: cfg.Timeout, Transport: transport}
+	bc, err := client.New(cfg)
+	if err != nil {
+		return err
+	}
+	defer transport.CloseIdleConnections()
+
+	b := &bench{
+		client: bc,
+		batch:  *batch,
+		source: *source,
+		mix:    mix,
+		runID:  strconv.FormatInt(time.Now().UnixNano(), 36),
+		pad:    strings.Repeat("x", *size),
+		errors: make(map[string]int),
+	}
+	fmt.Fprintf(c.stderr, "Sending %s of entries of %d bytes to %s, %d at a time...\n", *duration, *size, cfg.URL, *concurrency)
+	b.run(ctx, *duration, *rate, *concurrency, *interval, c.stderr)
+	b.report(c.stdout)
+	return nil
+}
+
+// bench sends synthetic entries and records the outcome of each request
+type bench struct {
+	client *client.Client
+	batch  int
+	source string
+	mix    levelMix
+	runID  string // tags the entries of this run
+	pad    string
+	seq    int64
+
+	mu        sync.Mutex
+	start     time.Time
+	elapsed   time.Duration
+	latencies []time.Duration
+	requests  int
+	entries   int // entries of successful requests
+	rejected  int // entries successful requests did not ingest
+	errors    map[string]int
+}
+
+// run sends requests for duration, paced to rate entries per second
+// unless it is 0, and prints progress every interval
+func (b *bench) run(ctx context.Context, duration time.Duration, rate float64, concurrency int, interval time.Duration, progress io.Writer) {
+	ctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+
+	// Each value is a request due; pacing drops the ones no worker was free
+	// for, so an overloaded service shows as a lower achieved rate
+	due := make(chan struct{})
+	go func() {
+		defer close(due)
+		var tick <-chan time.Time
+		if every := time.Duration(float64(time.Second) * float64(b.batch) / rate); rate > 0 && every > 0 {
+			ticker := time.NewTicker(every)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for {
+			if tick != nil {
+				select {
+				case <-tick:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case due <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	b.start = time.Now()
+	if interval > 0 {
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					b.mu.Lock()
+					fmt.Fprintf(progress, "%6.0fs  requests %d, entries %d, errors %d\n", time.Since(b.start).Seconds(), b.requests, b.entries, b.failures())
+					b.mu.Unlock()
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+			for range due {
+				b.send(ctx, rng)
+			}
+		}()
+	}
+	wg.Wait()
+	b.elapsed = time.Since(b.start)
+}
+
+// send sends one request and records its outcome
+func (b *bench) send(ctx context.Context, rng *rand.Rand) {
+	entries := make([]models.Log, b.batch)
+	for i := range entries {
+		seq := atomic.AddInt64(&b.seq, 1)
+		entries[i] = models.Log{
+			Message:   b.pad,
+			Level:     b.mix.pick(rng),
+			Timestamp: time.Now().UTC(),
+			Source:    b.source,
+			Fields:    models.Fields{"bench_run": b.runID, "seq": seq},
+		}
+	}
+
+	start := time.Now()
+	rejected := 0
+	var err error
+	if b.batch == 1 {
+		var result *client.Result
+		if result, err = b.client.Send(ctx, entries[0]); err == nil && result.Status == "dead_lettered" {
+			rejected = 1
+		}
+	} else {
+		var result *client.BatchResult
+		if result, err = b.client.SendBatch(ctx, entries); err == nil {
+			rejected = len(result.Rejected)
+		}
+	}
+	latency := time.Since(start)
+	if err != nil && ctx.Err() != nil {
+		// Cut short by the end of the run
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.requests++
+	b.latencies = append(b.latencies, latency)
+	if err != nil {
+		b.errors[errorKind(err)]++
+		return
+	}
+	b.entries += len(entries)
+	b.rejected += rejected
+}
+
+// failures counts the failed requests; b.mu must be held
+func (b *bench) failures() int {
+	failed := 0
+	for _, n := range b.errors {
+		failed += n
+	}
+	return failed
+}
+
+// report prints the results of the run
+func (b *bench) report(w io.Writer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	seconds := b.elapsed.Seconds()
+	if seconds == 0 {
+		seconds = 1
+	}
+	fmt.Fprintf(w, "requests  %d in %.1fs (%.1f/s)\n", b.requests, b.elapsed.Seconds(), float64(b.requests)/seconds)
+	fmt.Fprintf(w, "entries   %d ingested (%.1f/s), %d rejected\n", b.entries-b.rejected, float64(b.entries-b.rejected)/seconds, b.rejected)
+
+	failed := b.failures()
+	kinds := make([]string, 0, len(b.errors))
+	for kind := range b.errors {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for i, kind := range kinds {
+		kinds[i] = fmt.Sprintf("%s x%d", kind, b.errors[kind])
+	}
+	rate := 0.0
+	if b.requests > 0 {
+		rate = float64(failed) / float64(b.requests) * 100
+	}
+	if failed > 0 {
+		fmt.Fprintf(w, "errors    %d (%.2f%%): %s\n", failed, rate, strings.Join(kinds, ", "))
+	} else {
+		fmt.Fprintf(w, "errors    0 (0.00%%)\n")
+	}
+
+	if len(b.latencies) == 0 {
+		return
+	}
+	sort.Slice(b.latencies, func(i, j int) bool { return b.latencies[i] < b.latencies[j] })
+	fmt.Fprintf(w, "latency   p50 %s  p90 %s  p99 %s  max %s\n",
+		percentile(b.latencies, 0.5), percentile(b.latencies, 0.9), percentile(b.latencies, 0.99), b.latencies[len(b.latencies)-1])
+}
+
+// percentile returns the p-th percentile of sorted latencies, rounded for
+// display
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i].Round(10 * time.Microsecond)
+}
+
+// errorKind names the kind of a failed request: the status the service
+// answered with, or timeout or network
+func errorKind(err error) string {
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) {
+		return strconv.Itoa(apiErr.StatusCode)
+	}
+	if errors.Is(err, context.DeadlineExceeded) || strings.Contains(err.Error(), "Client.Timeout") {
+		return "timeout"
+	}
+	return "network"
+}
+
+// levelMix picks levels at random with the given weights
+type levelMix struct {
+	levels  []string
+	weights []int // cumulative
+}
+
+// parseLevelMix parses a mix such as info=70,warn=20,error=10
+func parseLevelMix(value string) (levelMix, error) {
+	var mix levelMix
+	total := 0
+	for _, item := range splitList(value) {
+		level, weight, ok := strings.Cut(item, "=")
+		n, err := strconv.Atoi(weight)
+		if !ok || err != nil || n < 0 {
+			return mix, fmt.Errorf("expected level=weight, got %q", item)
+		}
+		if err := (&models.Log{Message: "-", Level: level}).Validate(); err != nil {
+			return mix, fmt.Errorf("%s: %v", level, err)
+		}
+		total += n
+		mix.levels = append(mix.levels, level)
+		mix.weights = append(mix.weights, total)
+	}
+	if total == 0 {
+		return mix, errors.New("weights must not all be zero")
+	}
+	return mix, nil
+}
+
+func (m levelMix) pick(rng *rand.Rand) string {
+	n := rng.Intn(m.weights[len(m.weights)-1])
+	i := sort.SearchInts(m.weights, n+1)
+	return m.levels[i]
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBench(t *testing.T) {
+	var requests int32
+	code, stdout, stderr := runLogctl(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1)%4 == 0 {
+			http.Error(w, "Failed to store log entries", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"status":"accepted","accepted":10,"rejected":[]}`))
+	}, "bench", "-duration", "200ms", "-rate", "0", "-concurrency", "2", "-batch", "10", "-report-interval", "0")
+
+	if code != 0 {
+		t.Fatalf("Expected success, got %d: %s", code, stderr)
+	}
+	for _, want := range []string{"requests  ", "entries   ", "errors    ", "503 x", "latency   p50 "} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected the report to contain %q, got:\n%s", want, stdout)
+		}
+	}
+}
+
+func TestParseLevelMix(t *testing.T) {
+	mix, err := parseLevelMix("info=3, error=1, debug=0")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	counts := map[string]int{}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 4000; i++ {
+		counts[mix.pick(rng)]++
+	}
+	if counts["debug"] != 0 || counts["info"] < 2700 || counts["info"] > 3300 || counts["error"] < 700 {
+		t.Errorf("Expected about 3 info per error and no debug, got %v", counts)
+	}
+
+	for _, value := range []string{"", "info", "info=x", "trace=1", "info=0"} {
+		if _, err := parseLevelMix(value); err == nil {
+			t.Errorf("Expected an error for %q", value)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := make([]time.Duration, 100)
+	for i := range sorted {
+		sorted[i] = time.Duration(i+1) * time.Millisecond
+	}
+	if p := percentile(sorted, 0.5); p != 50*time.Millisecond {
+		t.Errorf("Expected p50 of 50ms, got %s", p)
+	}
+	if p := percentile(sorted, 0.99); p != 99*time.Millisecond {
+		t.Errorf("Expected p99 of 99ms, got %s", p)
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"log-processing-system/services/log-ingestion/client"
+	"log-processing-system/services/log-ingestion/logfile"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+const importUsage = `import [flags] file...
+
+Bulk-loads log files through the batch API. Files may be plain text, with
+each line mapped by -template, or NDJSON, with keys mapped by -map; either
+may be gzip-compressed. Progress is recorded in the -state file after each
+batch, so an interrupted or failed import resumes where it stopped when run
+again.
+
+A template such as "{timestamp} {level} [{source}] {message}" matches each
+line against its literal text, with spaces matching any run of whitespace.
+{timestamp}, {level}, {source} and {message} fill the entry; any other
+{name} becomes a field. Lines that do not match are skipped and counted.
+Levels of other logging systems, such as WARNING or CRITICAL, are mapped
+to ours; unknown ones fall back to -level and are kept in original_level.
+`
+
+func runImport(ctx context.Context, c *cli, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := fs.String("format", "auto", "text, ndjson, or auto to tell by file name and content")
+	template := fs.String("template", "{message}", "template of text lines")
+	keyMap := fs.String("map", "", "NDJSON keys of entry fields, such as message=msg,level=severity,timestamp=@timestamp")
+	timeFormat := fs.String("time-format", time.RFC3339Nano, "Go layout of timestamps; RFC3339 is always accepted too")
+	level := fs.String("level", "info", "level of entries without a known one")
+	source := fs.String("source", "", "source of entries without one; the file name by default")
+	batch := fs.Int("batch", client.MaxBatchEntries, "entries per request")
+	statePath := fs.String("state", ".logctl-import.json", "file recording progress for resuming; empty disables resuming")
+	restart := fs.Bool("restart", false, "import the files from the start, ignoring recorded progress")
+	progress := fs.Duration("progress", 5*time.Second, "interval of progress reports on standard error; 0 disables them")
+	if err := parseFlags(fs, c, importUsage, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usageError(fs, "import needs at least one file")
+	}
+	if *format != "auto" && *format != logfile.Text && *format != logfile.NDJSON {
+		return usageError(fs, "invalid -format %q", *format)
+	}
+	if *batch <= 0 || *batch > client.MaxBatchEntries {
+		return usageError(fs, "-batch must be between 1 and %d", client.MaxBatchEntries)
+	}
+	keys, err := logfile.ParseKeys(*keyMap)
+	if err != nil {
+		return usageError(fs, "invalid -map: %v", err)
+	}
+	mapping := logfile.Config{Template: *template, Keys: keys, TimeFormat: *timeFormat, Level: *level, Source: *source}
+	if _, err := logfile.NewMapper(mapping); err != nil {
+		return usageError(fs, "%v", err)
+	}
+
+	state, err := loadImportState(*statePath)
+	if err != nil {
+		return err
+	}
+	im := &importer{
+		cli:        c,
+		format:     *format,
+		mapping:    mapping,
+		batch:      *batch,
+		state:      state,
+		restart:    *restart,
+		progress:   *progress,
+		lastReport: time.Now(),
+	}
+	for _, name := range fs.Args() {
+		if err := im.importFile(ctx, name); err != nil {
+			im.report()
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	im.report()
+	if im.rejected > 0 {
+		return errFailed
+	}
+	return nil
+}
+
+// importer loads files in batches, recording after each one how far it got
+type importer struct {
+	*cli
+	format     string
+	mapping    logfile.Config
+	batch      int
+	state      *importState
+	restart    bool
+	progress   time.Duration
+	lastReport time.Time
+
+	accepted int
+	sampled  int
+	rejected int
+	skipped  int // lines that could not be mapped
+}
+
+func (im *importer) importFile(ctx context.Context, name string) error {
+	key, err := filepath.Abs(name)
+	if err != nil {
+		return err
+	}
+	file := im.state.file(key)
+	if im.restart {
+		*file = fileProgress{}
+	}
+	if file.Done {
+		fmt.Fprintf(im.stderr, "%s: already imported, skipping\n", name)
+		return nil
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	raw := &countingReader{r: f}
+	r, err := decompressed(raw)
+	if err != nil {
+		return err
+	}
+	mapping := im.mapping
+	mapping.Format = im.format
+	if mapping.Format == "auto" {
+		mapping.Format = logfile.DetectFormat(name, r)
+	}
+	if mapping.Source == "" {
+		mapping.Source = strings.TrimSuffix(filepath.Base(name), ".gz")
+	}
+	mapper, err := logfile.NewMapper(mapping)
+	if err != nil {
+		return err
+	}
+	if file.Lines > 0 {
+		fmt.Fprintf(im.stderr, "%s: resuming after line %d\n", name, file.Lines)
+	}
+
+	var pending []models.Log
+	line := 0
+	flush := func() error {
+		if len(pending) > 0 {
+			result, err := im.client.SendBatch(ctx, pending)
+			if result != nil {
+				im.accepted += result.Accepted
+				im.sampled += result.Sampled
+				im.rejected += len(result.Rejected)
+				for _, rejection := range result.Rejected {
+					fmt.Fprintf(im.stderr, "%s: entry %q rejected: %s\n", name, truncate(pending[rejection.Index].Message, 60), rejection.Error)
+				}
+			}
+			if err != nil {
+				return err
+			}
+			pending = pending[:0]
+		}
+		file.Lines = line
+		file.UpdatedAt = time.Now().UTC()
+		if err := im.state.save(); err != nil {
+			return err
+		}
+		if im.progress > 0 && time.Since(im.lastReport) >= im.progress {
+			im.lastReport = time.Now()
+			fmt.Fprintf(im.stderr, "%s: line %d, %.0f%% read, %d entries accepted\n", name, line, float64(raw.n)/float64(info.Size())*100, im.accepted)
+		}
+		return nil
+	}
+
+	for {
+		text, readErr := r.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+		if text != "" {
+			line++
+			if line > file.Lines {
+				text = strings.TrimRight(text, "\r\n")
+				if strings.TrimSpace(text) != "" {
+					entry, err := mapper.Map(text)
+					if err != nil {
+						im.skipped++
+						if im.skipped <= 10 {
+							fmt.Fprintf(im.stderr, "%s:%d: %v\n", name, line, err)
+						}
+					} else {
+						pending = append(pending, entry)
+					}
+				}
+				if len(pending) >= im.batch {
+					if err := flush(); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	file.Done = true
+	return im.state.save()
+}
+
+func (im *importer) report() {
+	fmt.Fprintf(im.stdout, "accepted %d, sampled %d, rejected %d, skipped %d lines\n", im.accepted, im.sampled, im.rejected, im.skipped)
+}
+
+// countingReader counts the bytes read from a file, to report progress
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// decompressed reads r, decompressing it when it starts like gzip
+func decompressed(r io.Reader) (*bufio.Reader, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return bufio.NewReaderSize(zr, 64*1024), nil
+	}
+	return br, nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+// importState records how far each file was imported
+type importState struct {
+	path  string
+	Files map[string]*fileProgress `json:"files"`
+}
+
+// fileProgress is how far a file was imported
+type fileProgress struct {
+	Lines     int       `json:"lines"` // lines sent, skipped or rejected
+	Done      bool      `json:"done"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// loadImportState reads the state file at path; a missing file, or an
+// empty path, starts afresh
+func loadImportState(path string) (*importState, error) {
+	state := &importState{path: path, Files: make(map[string]*fileProgress)}
+	if path == "" {
+		return state, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("invalid import state %s: %w", path, err)
+	}
+	if state.Files == nil {
+		state.Files = make(map[string]*fileProgress)
+	}
+	return state, nil
+}
+
+func (s *importState) file(key string) *fileProgress {
+	progress, ok := s.Files[key]
+	if !ok {
+		progress = &fileProgress{}
+		s.Files[key] = progress
+	}
+	return progress
+}
+
+// save writes the state atomically, so a crash leaves the previous one
+func (s *importState) save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
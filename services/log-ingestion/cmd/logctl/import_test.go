@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func TestImport_Resume(t *testing.T) {
+	dir := t.TempDir()
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	for _, line := range []string{"ERROR one", "INFO two", "garbage", "WARN three", "INFO four", "ERROR five"} {
+		zw.Write([]byte(line + "\n"))
+	}
+	zw.Close()
+	logFile := filepath.Join(dir, "app.log.gz")
+	os.WriteFile(logFile, compressed.Bytes(), 0o644)
+	state := filepath.Join(dir, "state.json")
+
+	var requests int32
+	var received []string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		// The second batch fails the first time
+		if atomic.AddInt32(&requests, 1) == 2 {
+			http.Error(w, "Failed to store log entries", http.StatusServiceUnavailable)
+			return
+		}
+		var entries []models.Log
+		json.NewDecoder(r.Body).Decode(&entries)
+		for _, entry := range entries {
+			received = append(received, entry.Level+":"+entry.Message+"@"+entry.Source)
+		}
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{"accepted": len(entries), "rejected": []interface{}{}})
+	}
+	args := []string{"import", "-template", "{level} {message}", "-batch", "2", "-state", state, "-progress", "0", logFile}
+
+	code, _, stderr := runLogctl(t, handler, args...)
+	if code != 1 || !strings.Contains(stderr, "503") {
+		t.Fatalf("Expected the failed batch to fail the import, got %d: %s", code, stderr)
+	}
+	code, stdout, stderr := runLogctl(t, handler, args...)
+	if code != 0 || !strings.Contains(stderr, "resuming after line 2") {
+		t.Fatalf("Expected the import to resume, got %d: %s%s", code, stdout, stderr)
+	}
+
+	want := []string{"error:one@app.log", "info:two@app.log", "warn:three@app.log", "info:four@app.log", "error:five@app.log"}
+	if strings.Join(received, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected each entry once, got %v", received)
+	}
+
+	code, stdout, stderr = runLogctl(t, handler, args...)
+	if code != 0 || !strings.Contains(stderr, "already imported") || !strings.Contains(stdout, "accepted 0") {
+		t.Errorf("Expected an imported file to be skipped, got %d: %s%s", code, stdout, stderr)
+	}
+}
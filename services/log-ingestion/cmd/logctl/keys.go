@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+const keysUsage = `keys <create|list|revoke> [flags]
+
+  keys create -name NAME -scopes SCOPES [-sources SOURCES] [-rate-limit N]
+  keys list
+  keys revoke ID
+
+Managing keys needs an API key with the admin scope.
+`
+
+func runKeys(ctx context.Context, c *cli, args []string) error {
+	fs := flag.NewFlagSet("keys", flag.ContinueOnError)
+	if err := parseFlags(fs, c, keysUsage, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usageError(fs, "keys needs a subcommand")
+	}
+
+	switch fs.Arg(0) {
+	case "create":
+		return createKey(ctx, c, fs.Args()[1:])
+	case "list":
+		return listKeys(ctx, c)
+	case "revoke":
+		if fs.NArg() != 2 {
+			return usageError(fs, "keys revoke needs the id of the key")
+		}
+		id, err := strconv.ParseInt(fs.Arg(1), 10, 64)
+		if err != nil || id <= 0 {
+			return usageError(fs, "invalid key id %q", fs.Arg(1))
+		}
+		if err := c.client.RevokeAPIKey(ctx, id); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.stdout, "revoked key %d\n", id)
+		return nil
+	default:
+		return usageError(fs, "unknown keys subcommand %q", fs.Arg(0))
+	}
+}
+
+func createKey(ctx context.Context, c *cli, args []string) error {
+	fs := flag.NewFlagSet("keys create", flag.ContinueOnError)
+	name := fs.String("name", "", "name of the key")
+	scopes := fs.String("scopes", "", "comma-separated scopes: ingest, read, admin")
+	sources := fs.String("sources", "", "comma-separated sources the key may submit; all when empty")
+	rateLimit := fs.Int("rate-limit", 0, "requests per minute; unlimited when 0")
+	if err := parseFlags(fs, c, "keys create [flags]", args); err != nil {
+		return err
+	}
+
+	key := models.APIKey{
+		Name:      *name,
+		Scopes:    splitList(*scopes),
+		Sources:   splitList(*sources),
+		RateLimit: *rateLimit,
+	}
+	if err := key.Validate(); err != nil {
+		return usageError(fs, "%v", err)
+	}
+	created, err := c.client.CreateAPIKey(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.stdout, "created key %d (%s)\n%s\n", created.ID, created.Name, created.Key)
+	fmt.Fprintln(c.stderr, "Store the key now; the service does not show it again.")
+	return nil
+}
+
+func listKeys(ctx context.Context, c *cli) error {
+	keys, err := c.client.ListAPIKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tPREFIX\tSCOPES\tSOURCES\tRATE LIMIT\tCREATED\tREVOKED")
+	for _, key := range keys {
+		sources, rateLimit, revoked := "*", "-", "-"
+		if len(key.Sources) > 0 {
+			sources = strings.Join(key.Sources, ",")
+		}
+		if key.RateLimit > 0 {
+			rateLimit = strconv.Itoa(key.RateLimit) + "/min"
+		}
+		if key.RevokedAt != nil {
+			revoked = key.RevokedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", key.ID, key.Name, key.Prefix,
+			strings.Join(key.Scopes, ","), sources, rateLimit, key.CreatedAt.Format(time.RFC3339), revoked)
+	}
+	return tw.Flush()
+}
+
+// splitList splits a comma-separated list, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
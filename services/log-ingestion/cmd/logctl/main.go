@@ -0,0 +1,166 @@
+// Command logctl sends, queries and follows the logs of a running log
+// ingestion service and manages its API keys:
+//
+//	logctl [flags] <command> [command flags] [arguments]
+//
+// The service URL and API key default to the LOGCTL_URL and LOGCTL_API_KEY
+// environment variables. Run a command with -h for its flags.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	"log-processing-system/services/log-ingestion/client"
+)
+
+// cli is what a command runs with: a client of the service, its
+// configuration and the output streams
+type cli struct {
+	client *client.Client
+	config client.Config
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// command is a logctl subcommand; run gets the arguments after its name
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, c *cli, args []string) error
+}
+
+var commands = []command{
+	{"send", "send one log entry, the message given as arguments", runSend},
+	{"batch", "send NDJSON or JSON array entries from files or standard input", runBatch},
+	{"query", "print stored entries matching a filter", runQuery},
+	{"tail", "follow newly stored entries", runTail},
+	{"keys", "create, list or revoke API keys", runKeys},
+	{"import", "bulk-load text or NDJSON log files, resuming where it stopped", runImport},
+	{"bench", "send synthetic traffic and report latency and errors", runBench},
+}
+
+// errFailed reports a failure that was already printed
+var errFailed = errors.New("failed")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// run runs logctl with args and returns its exit code: 0 on success, 1 when
+// the command failed and 2 on invalid usage
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("logctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	url := fs.String("url", envOr("LOGCTL_URL", "http://localhost:8080"), "base URL of the service (LOGCTL_URL)")
+	apiKey := fs.String("api-key", os.Getenv("LOGCTL_API_KEY"), "API key (LOGCTL_API_KEY)")
+	header := fs.String("api-key-header", "X-API-Key", "request header carrying the API key")
+	timeout := fs.Duration("timeout", 10*time.Second, "timeout of each request")
+	retries := fs.Int("retries", 3, "retries of failed requests; 0 disables them")
+	gzip := fs.Bool("gzip", false, "compress request bodies")
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintf(out, "Usage: logctl [flags] <command> [command flags] [arguments]\n\nCommands:\n")
+		for _, cmd := range commands {
+			fmt.Fprintf(out, "  %-7s %s\n", cmd.name, cmd.summary)
+		}
+		fmt.Fprintf(out, "\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == fs.Arg(0) {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(stderr, "logctl: unknown command %q\n", fs.Arg(0))
+		fs.Usage()
+		return 2
+	}
+
+	if *retries == 0 {
+		// The client takes a negative count to disable retries
+		*retries = -1
+	}
+	cfg := client.Config{
+		URL:          *url,
+		APIKey:       *apiKey,
+		APIKeyHeader: *header,
+		Timeout:      *timeout,
+		MaxRetries:   *retries,
+		Gzip:         *gzip,
+	}
+	c, err := client.New(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "logctl: %v\n", err)
+		return 2
+	}
+
+	err = cmd.run(ctx, &cli{client: c, config: cfg, stdout: stdout, stderr: stderr}, fs.Args()[1:])
+	switch {
+	case err == nil:
+		return 0
+	case err == flag.ErrHelp:
+		return 0
+	case errors.Is(err, errUsage):
+		return 2
+	case err != errFailed:
+		fmt.Fprintf(stderr, "logctl %s: %v\n", cmd.name, err)
+	}
+	return 1
+}
+
+// errUsage reports invalid command arguments; the flag set has printed why
+var errUsage = errors.New("invalid usage")
+
+// parseFlags parses the flags of a command, which prints its usage and
+// errors to stderr
+func parseFlags(fs *flag.FlagSet, c *cli, usage string, args []string) error {
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: logctl %s\n", usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return err
+		}
+		return errUsage
+	}
+	return nil
+}
+
+// usageError prints a usage error of the command of fs
+func usageError(fs *flag.FlagSet, format string, args ...interface{}) error {
+	fmt.Fprintf(fs.Output(), format+"\n", args...)
+	fs.Usage()
+	return errUsage
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+// runLogctl runs logctl against handler and returns its exit code and output
+func runLogctl(t *testing.T, handler http.HandlerFunc, args ...string) (int, string, string) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), append([]string{"-url", server.URL, "-retries", "0"}, args...), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestSend(t *testing.T) {
+	var received models.Log
+	code, stdout, stderr := runLogctl(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprint(w, `{"status":"accepted","request_id":"req-1"}`)
+	}, "send", "-level", "error", "-source", "billing", "-field", "order=42", "payment", "declined")
+
+	if code != 0 || stdout != "accepted (request req-1)\n" {
+		t.Fatalf("Expected success, got %d: %s%s", code, stdout, stderr)
+	}
+	if received.Message != "payment declined" || received.Level != "error" || received.Source != "billing" || received.Fields["order"] != "42" {
+		t.Errorf("Unexpected entry sent: %+v", received)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	dir := t.TempDir()
+	ndjson := filepath.Join(dir, "entries.ndjson")
+	array := filepath.Join(dir, "entries.json")
+	os.WriteFile(ndjson, []byte("{\"message\":\"one\",\"level\":\"info\"}\n{\"message\":\"two\",\"level\":\"info\"}\n"), 0o644)
+	os.WriteFile(array, []byte(`[{"message":"three","level":"info"},{"message":"","level":"info"}]`), 0o644)
+
+	var batches []int
+	code, stdout, stderr := runLogctl(t, func(w http.ResponseWriter, r *http.Request) {
+		var entries []models.Log
+		json.NewDecoder(r.Body).Decode(&entries)
+		batches = append(batches, len(entries))
+		rejected := []map[string]interface{}{}
+		accepted := 0
+		for i, entry := range entries {
+			if entry.Message == "" {
+				rejected = append(rejected, map[string]interface{}{"index": i, "error": "message cannot be empty"})
+			} else {
+				accepted++
+			}
+		}
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{"accepted": accepted, "rejected": rejected})
+	}, "batch", "-size", "3", ndjson, array)
+
+	if code != 1 || stdout != "accepted 3, sampled 0, rejected 1\n" || stderr != "entry 4: message cannot be empty\n" {
+		t.Fatalf("Expected one rejection, got %d: %s%s", code, stdout, stderr)
+	}
+	if len(batches) != 2 || batches[0] != 3 || batches[1] != 1 {
+		t.Errorf("Expected batches of 3 and 1 entries, got %v", batches)
+	}
+}
+
+func TestQuery_Pages(t *testing.T) {
+	code, stdout, stderr := runLogctl(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("level") != "error" || q.Get("limit") != "1000" {
+			http.Error(w, "unexpected query "+r.URL.RawQuery, http.StatusBadRequest)
+			return
+		}
+		if q.Get("before") == "" {
+			fmt.Fprint(w, `{"logs":[{"id":9,"message":"a","level":"error","source":"api"},{"id":8,"message":"b","level":"error","source":"api"},{"id":7,"message":"c","level":"error","source":"api","fields":{"order":42}}],"count":3,"next_before":7}`)
+			return
+		}
+		fmt.Fprint(w, `{"logs":[{"id":6,"message":"d","level":"error","source":"api"}],"count":1}`)
+	}, "query", "-level", "error", "-limit", "0")
+
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if code != 0 || len(lines) != 4 {
+		t.Fatalf("Expected four entries over two pages, got %d: %s%s", code, stdout, stderr)
+	}
+	if !strings.HasSuffix(lines[2], "ERROR [api] c order=42") {
+		t.Errorf("Unexpected line %q", lines[2])
+	}
+}
+
+func TestKeys(t *testing.T) {
+	code, stdout, stderr := runLogctl(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/admin/api-keys":
+			var key models.APIKey
+			json.NewDecoder(r.Body).Decode(&key)
+			if key.Name != "shipper" || len(key.Scopes) != 2 {
+				http.Error(w, "unexpected key", http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"id":3,"name":"shipper","key":"lps_secret"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}, "keys", "create", "-name", "shipper", "-scopes", "ingest, read")
+	if code != 0 || !strings.Contains(stdout, "lps_secret") {
+		t.Errorf("Expected the created key, got %d: %s%s", code, stdout, stderr)
+	}
+
+	code, _, stderr = runLogctl(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "API key not found", http.StatusNotFound)
+	}, "keys", "revoke", "7")
+	if code != 1 || !strings.Contains(stderr, "API key not found") {
+		t.Errorf("Expected the service's error, got %d: %s", code, stderr)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	for _, args := range [][]string{{}, {"frobnicate"}, {"send"}, {"keys", "revoke", "x"}} {
+		var stdout, stderr bytes.Buffer
+		if code := run(context.Background(), args, &stdout, &stderr); code != 2 {
+			t.Errorf("Expected exit code 2 for %v, got %d", args, code)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"log-processing-system/services/log-ingestion/client"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func runQuery(ctx context.Context, c *cli, args []string) error {
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	source := fs.String("source", "", "only entries from this source")
+	level := fs.String("level", "", "only entries with this level")
+	from := fs.String("from", "", "only entries logged at or after this RFC3339 time, or this long ago, such as 1h")
+	to := fs.String("to", "", "only entries logged before this RFC3339 time, or this long ago")
+	limit := fs.Int("limit", 100, "most entries to print; 0 prints all")
+	jsonOutput := fs.Bool("json", false, "print entries as NDJSON")
+	if err := parseFlags(fs, c, "query [flags]", args); err != nil {
+		return err
+	}
+	if *limit < 0 {
+		return usageError(fs, "-limit must not be negative")
+	}
+
+	q := client.Query{Source: *source, Level: *level}
+	var err error
+	if q.From, err = parseTime(*from); err != nil {
+		return usageError(fs, "invalid -from: %v", err)
+	}
+	if q.To, err = parseTime(*to); err != nil {
+		return usageError(fs, "invalid -to: %v", err)
+	}
+
+	printed := 0
+	for {
+		q.Limit = 1000
+		if *limit > 0 && *limit-printed < q.Limit {
+			q.Limit = *limit - printed
+		}
+		page, err := c.client.Query(ctx, q)
+		if err != nil {
+			return err
+		}
+		for _, entry := range page.Logs {
+			printEntry(c.stdout, entry, *jsonOutput)
+		}
+		printed += len(page.Logs)
+		if page.NextBefore == 0 || printed == *limit {
+			return nil
+		}
+		q.Before = page.NextBefore
+	}
+}
+
+// parseTime parses an RFC3339 time or a duration before now; empty is the
+// zero time
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// printEntry prints an entry as a line of text, or of JSON
+func printEntry(w io.Writer, entry models.Log, jsonOutput bool) {
+	if jsonOutput {
+		data, _ := json.Marshal(entry)
+		fmt.Fprintf(w, "%s\n", data)
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %-5s [%s] %s", entry.Timestamp.Format(time.RFC3339), strings.ToUpper(entry.Level), entry.Source, entry.Message)
+	keys := make([]string, 0, len(entry.Fields))
+	for key := range entry.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&b, " %s=%v", key, entry.Fields[key])
+	}
+	fmt.Fprintln(w, b.String())
+}
+
+func runTail(ctx context.Context, c *cli, args []string) error {
+	fs := flag.NewFlagSet("tail", flag.ContinueOnError)
+	source := fs.String("source", "", "only entries from this source")
+	level := fs.String("level", "", "only entries with this level")
+	includeNoise := fs.Bool("include-noise", false, "include entries classified as noise")
+	jsonOutput := fs.Bool("json", false, "print entries as NDJSON")
+	if err := parseFlags(fs, c, "tail [flags]\n\nFollows newly stored entries until interrupted.\n", args); err != nil {
+		return err
+	}
+
+	err := c.client.Tail(ctx, client.TailOptions{
+		Level:        *level,
+		Source:       *source,
+		IncludeNoise: *includeNoise,
+	}, func(entry models.Log) error {
+		printEntry(c.stdout, entry, *jsonOutput)
+		return nil
+	})
+	if ctx.Err() != nil {
+		// Interrupted
+		return nil
+	}
+	return err
+}
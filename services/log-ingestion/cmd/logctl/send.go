@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"log-processing-system/services/log-ingestion/client"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+// fieldFlags collects repeated -field key=value flags
+type fieldFlags models.Fields
+
+func (f fieldFlags) String() string {
+	keys := make([]string, 0, len(f))
+	for key := range f {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+func (f fieldFlags) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("expected key=value, got %q", value)
+	}
+	f[key] = val
+	return nil
+}
+
+func runSend(ctx context.Context, c *cli, args []string) error {
+	fs := flag.NewFlagSet("send", flag.ContinueOnError)
+	level := fs.String("level", "info", "log level")
+	source := fs.String("source", "logctl", "source of the entry")
+	fields := fieldFlags{}
+	fs.Var(fields, "field", "structured field as key=value; repeatable")
+	if err := parseFlags(fs, c, "send [flags] message...", args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usageError(fs, "send needs a message")
+	}
+
+	entry := models.Log{
+		Message: strings.Join(fs.Args(), " "),
+		Level:   *level,
+		Source:  *source,
+	}
+	if len(fields) > 0 {
+		entry.Fields = models.Fields(fields)
+	}
+	result, err := c.client.Send(ctx, entry)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.stdout, "%s (request %s)\n", result.Status, result.RequestID)
+	if result.Status == "dead_lettered" {
+		fmt.Fprintf(c.stderr, "%s; dead letters %v\n", result.Message, result.DeadLetterIDs)
+		return errFailed
+	}
+	return nil
+}
+
+func runBatch(ctx context.Context, c *cli, args []string) error {
+	fs := flag.NewFlagSet("batch", flag.ContinueOnError)
+	size := fs.Int("size", client.MaxBatchEntries, "entries per request")
+	if err := parseFlags(fs, c, "batch [flags] [file...]\n\nSends the entries of the files, or of standard input when none or - is\ngiven, as NDJSON or JSON arrays of log entries.\n", args); err != nil {
+		return err
+	}
+	if *size <= 0 || *size > client.MaxBatchEntries {
+		return usageError(fs, "-size must be between 1 and %d", client.MaxBatchEntries)
+	}
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	sender := &batchSender{cli: c, size: *size}
+	for _, name := range files {
+		if err := sender.sendFile(ctx, name); err != nil {
+			sender.report()
+			return err
+		}
+	}
+	if err := sender.flush(ctx); err != nil {
+		sender.report()
+		return err
+	}
+
+	sender.report()
+	if sender.rejected > 0 {
+		return errFailed
+	}
+	return nil
+}
+
+// batchSender sends entries in batches of size, numbering them across all
+// files so rejections name the entry
+type batchSender struct {
+	*cli
+	size     int
+	pending  []models.Log
+	sent     int
+	accepted int
+	sampled  int
+	rejected int
+}
+
+func (s *batchSender) sendFile(ctx context.Context, name string) error {
+	var r io.Reader = os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		var entries []models.Log
+		if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '[' {
+			if err := json.Unmarshal(raw, &entries); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		} else {
+			var entry models.Log
+			if err := json.Unmarshal(raw, &entry); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			entries = []models.Log{entry}
+		}
+
+		for _, entry := range entries {
+			s.pending = append(s.pending, entry)
+			if len(s.pending) >= s.size {
+				if err := s.flush(ctx); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+func (s *batchSender) flush(ctx context.Context) error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	result, err := s.client.SendBatch(ctx, s.pending)
+	if result != nil {
+		s.accepted += result.Accepted
+		s.sampled += result.Sampled
+		s.rejected += len(result.Rejected)
+		for _, rejection := range result.Rejected {
+			fmt.Fprintf(s.stderr, "entry %d: %s\n", s.sent+rejection.Index+1, rejection.Error)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	s.sent += len(s.pending)
+	s.pending = s.pending[:0]
+	return nil
+}
+
+func (s *batchSender) report() {
+	fmt.Fprintf(s.stdout, "accepted %d, sampled %d, rejected %d\n", s.accepted, s.sampled, s.rejected)
+}
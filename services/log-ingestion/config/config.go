@@ -5,48 +5,395 @@ import (
     "os"
     "path/filepath"
     "strconv"
+    "strings"
+    "time"
 
     "github.com/joho/godotenv"
 )
 
 type Config struct {
-    Server   ServerConfig
-    Database DatabaseConfig
-    Log      LogConfig
+    Server      ServerConfig
+    Database    DatabaseConfig
+    Log         LogConfig
+    Rollup      RollupConfig
+    Stats       StatsConfig
+    Integrity   IntegrityConfig
+    Pipeline    PipelineConfig
+    Anomaly     AnomalyConfig
+    Patterns    PatternsConfig
+    DeadLetter  DeadLetterConfig
+    Queue       QueueConfig
+    Processor   ProcessorConfig
+    Archive     ArchiveConfig
+    Throttle    ThrottleConfig
+    Auth        AuthConfig
+    Audit       AuditConfig
+    Quota       QuotaConfig
+    Scanner     ScannerConfig
+    Profiling   ProfilingConfig
+    Health      HealthConfig
+    Crash       CrashConfig
+    SLO         SLOConfig
+    Maintenance MaintenanceConfig
+    WriteQueue  WriteQueueConfig
+    QueryCache  QueryCacheConfig
+
+    // Feature flags a deployment sets, by name; see package features
+    Features map[string]bool
 }
 
+// ServerConfig controls the HTTP listener. With TLSCertFile and TLSKeyFile
+// it serves TLS, and with TLSClientCAFile it also verifies client
+// certificates (mutual TLS).
 type ServerConfig struct {
     Host string
     Port int
+
+    TLSCertFile           string
+    TLSKeyFile            string
+    TLSClientCAFile       string
+    TLSClientCertOptional bool
+
+    // Proxies whose X-Forwarded-For and X-Real-IP headers are believed
+    TrustedProxies []string
+
+    // How long handlers may run, by default and per route path template;
+    // zero leaves a route unbounded
+    RouteTimeout  time.Duration
+    RouteTimeouts map[string]time.Duration
+
+    // HTTP server timeouts, and how long shutdown waits for requests in
+    // flight
+    ReadTimeout       time.Duration
+    ReadHeaderTimeout time.Duration
+    WriteTimeout      time.Duration
+    IdleTimeout       time.Duration
+    ShutdownTimeout   time.Duration
+
+    // Listener tuning: the request header size limit, whether connections
+    // are kept open between requests, the TCP keep-alive probe interval
+    // (zero disables probes) and whether HTTP/2 is offered over TLS
+    MaxHeaderBytes int64
+    KeepAlive      bool
+    TCPKeepAlive   time.Duration
+    HTTP2          bool
+
+    // Requests slower than SlowRequest are logged as warnings. A client
+    // may send RateLimit requests per RateLimitWindow; zero is unlimited.
+    SlowRequest     time.Duration
+    RateLimit       int
+    RateLimitWindow time.Duration
 }
 
+// DatabaseConfig points at PostgreSQL and sizes the connection pool.
+// Operations slower than SlowQuery are logged as warnings.
 type DatabaseConfig struct {
     Host     string
     Port     int
     User     string
-    Password string
+    Password string `redact:"true"`
     DBName   string
-    URL      string
+    URL      string `redact:"url"`
+
+    // Databases log entries are spread over by the hash of their source;
+    // the one at URL keeps every other table. Empty stores them at URL.
+    Shards []string `redact:"url"`
+
+    MaxOpenConns    int
+    MaxIdleConns    int
+    ConnMaxLifetime time.Duration
+    SlowQuery       time.Duration
 }
 
 type LogConfig struct {
     Level  string
     Format string
+
+    // What ingestion does with the system's own logs, marked self_source:
+    // "store" them without logging about it, or "drop" them
+    SelfLogs string
+}
+
+// RollupConfig controls downsampling of aged raw logs into hourly summaries
+type RollupConfig struct {
+    Enabled    bool
+    After      time.Duration
+    NoiseAfter time.Duration
+    Interval   time.Duration
+    Levels     []string
+}
+
+// IntegrityConfig controls the tamper-evident hash chain over stored logs
+type IntegrityConfig struct {
+    HashChain bool
+}
+
+// PipelineConfig points at the declarative processing pipeline definition
+type PipelineConfig struct {
+    ConfigPath    string
+    FlushInterval time.Duration
+}
+
+// AnomalyConfig controls log volume anomaly detection per source and level
+type AnomalyConfig struct {
+    Enabled        bool
+    Window         time.Duration
+    Alpha          float64
+    Threshold      float64
+    WarmupWindows  int
+    SilenceWindows int
+    MinRate        float64
+}
+
+// PatternsConfig controls Drain-style message template mining
+type PatternsConfig struct {
+    Enabled     bool
+    Depth       int
+    Similarity  float64
+    MaxChildren int
+    MaxPatterns int
+}
+
+// DeadLetterConfig controls the dead-letter queue for submissions that fail
+// parsing, validation, the pipeline or storage. Backend is "table" or "file".
+type DeadLetterConfig struct {
+    Enabled      bool
+    Backend      string
+    File         string
+    StoreRetries int
+    RetryBackoff time.Duration
 }
 
-// LoadConfig loads configuration from .env file and environment variables
+// QueueConfig points ingestion and the log-processor service at the queue
+// raw entries travel through. An empty Backend processes entries in-process.
+type QueueConfig struct {
+    Backend string
+    Addrs   []string
+    Topic   string
+    Group   string
+    MaxLen  int64
+}
+
+// ProcessorConfig controls the standalone log-processor service
+type ProcessorConfig struct {
+    Port int
+}
+
+// ArchiveConfig points archive replay at the archives written by the s3
+// output sink: a bucket, or a directory such as a cold tier mount
+type ArchiveConfig struct {
+    Bucket   string
+    Region   string
+    Endpoint string
+    Dir      string
+}
+
+// ThrottleConfig controls per-source ingest budgets. Rates are entries per
+// second; SourceRates overrides Rate for individual sources.
+type ThrottleConfig struct {
+    Enabled       bool
+    Rate          float64
+    SourceRates   map[string]float64
+    SampleUntil   float64
+    Window        time.Duration
+    ProtectLevels []string
+}
+
+// AuthConfig controls API key and bearer token authentication. AdminKey is
+// a key with the admin scope that is not stored, used to create the first
+// keys. Setting JWKSURL accepts JWTs signed by the identity provider.
+// AdminUsername and AdminPassword, or AdminToken, are a separate credential
+// that the admin endpoints then require instead.
+type AuthConfig struct {
+    Enabled  bool
+    Header   string
+    AdminKey string `redact:"true"`
+    CacheTTL time.Duration
+
+    JWKSURL     string
+    JWKSRefresh time.Duration
+    Issuer      string
+    Audience    string
+    ClockSkew   time.Duration
+    UserClaim   string
+    TenantClaim string
+    ScopeClaim  string
+
+    HMACSecrets []string `redact:"true"`
+    HMACWindow  time.Duration
+
+    ClientCNs map[string][]string
+
+    AdminUsername string
+    AdminPassword string `redact:"true"`
+    AdminToken    string `redact:"true"`
+}
+
+// AuditConfig controls the audit trail of administrative actions, kept in
+// the audit_log table
+type AuditConfig struct {
+    Enabled bool
+}
+
+// QuotaConfig controls per-tenant ingestion quotas: a daily volume of
+// request bytes and a request rate, zero meaning unlimited. TenantBytes and
+// TenantRates override the defaults for individual tenants.
+type QuotaConfig struct {
+    Enabled           bool
+    DailyBytes        int64
+    RequestsPerMinute int
+    TenantBytes       map[string]int64
+    TenantRates       map[string]int
+    SoftLimit         float64
+}
+
+// ScannerConfig controls the detection of vulnerability scanners and bots
+// by probed paths, User-Agent and 404 rate, and whether they are blocked
+type ScannerConfig struct {
+    Enabled       bool
+    Paths         []string
+    UserAgents    []string
+    NotFoundLimit int
+    Window        time.Duration
+    Block         bool
+    BlockFor      time.Duration
+}
+
+// ProfilingConfig controls the pprof endpoints. With Addr they are served
+// on a listener of their own, without authentication, so it belongs on
+// localhost or a private network; otherwise under /admin/debug/pprof/,
+// behind the admin credentials.
+type ProfilingConfig struct {
+    Enabled              bool
+    Addr                 string
+    BlockProfileRate     int
+    MutexProfileFraction int
+}
+
+// HealthConfig tunes GET /health/details: how long each dependency check
+// may take, and how long a report is reused before dependencies are checked
+// again. ReadinessInterval is how often the startup checks are retried
+// until the service reports ready.
+type HealthConfig struct {
+    CheckTimeout      time.Duration
+    CacheTTL          time.Duration
+    ReadinessInterval time.Duration
+}
+
+// CrashConfig controls crash reports: with Dir set, each recovered panic
+// and fatal exit writes a report file there with the stack, the request and
+// the last RecentLogs entries logged; the newest MaxReports are kept
+type CrashConfig struct {
+    Dir        string
+    MaxReports int
+    RecentLogs int
+}
+
+// SLOConfig configures the per-route latency histograms and the
+// availability and latency SLOs measured against them
+type SLOConfig struct {
+    LatencyBuckets        []time.Duration
+    LatencyTarget         time.Duration
+    LatencyObjective      float64
+    AvailabilityObjective float64
+    NativeHistograms      bool
+    ExcludeRoutes         []string
+}
+
+// MaintenanceConfig controls maintenance mode, turned on through the admin
+// API while the database is migrated or failed over, or from the start
+// with Enabled. Mode "buffer" keeps entries in BufferFile, up to
+// BufferMaxBytes, and stores them afterwards; "reject" refuses submissions
+// with 503 and a Retry-After of RetryAfter.
+type MaintenanceConfig struct {
+    Enabled        bool
+    Mode           string
+    BufferFile     string
+    BufferMaxBytes int64
+    RetryAfter     time.Duration
+}
+
+// WriteQueueConfig controls storing entries after ingestion responded:
+// validated entries wait in a queue of Size entries that Workers store.
+// Once HighWater of it is used, requests are refused with 503 and a
+// Retry-After of RetryAfter.
+type WriteQueueConfig struct {
+    Enabled    bool
+    Size       int
+    Workers    int
+    HighWater  float64
+    RetryAfter time.Duration
+}
+
+// QueryCacheConfig controls caching the responses of bounded queries and
+// aggregations for TTL, in memory or in Redis; an empty Backend disables
+// it. Responses larger than MaxEntryBytes are not cached, and the memory
+// backend keeps at most MaxEntries responses of MaxBytes in total.
+type QueryCacheConfig struct {
+    Backend       string
+    TTL           time.Duration
+    MaxEntryBytes int64
+    MaxEntries    int
+    MaxBytes      int64
+    RedisAddr     string
+    KeyPrefix     string
+}
+
+// StatsConfig controls the incrementally maintained aggregate stats tables
+type StatsConfig struct {
+    RefreshInterval time.Duration
+}
+
+// envPath is the .env file in the project root (two levels up from the
+// service directory)
+var envPath = filepath.Join("..", "..", ".env")
+
+// LoadConfig loads configuration from the .env file, the YAML or TOML file
+// named by CONFIG_FILE, environment variables and the flags ParseFlags
+// parsed, in increasing precedence,
+// and validates it; the error then lists every invalid setting
 func LoadConfig() (*Config, error) {
-    // Load .env file from project root (two levels up from current directory)
-    envPath := filepath.Join("..", "..", ".env")
+    snapshotEnv()
+    malformed = nil
+
+    // Load .env file from project root
     if err := godotenv.Load(envPath); err != nil {
         // If .env file doesn't exist, that's okay - we'll use system env vars
-        fmt.Printf("Warning: Could not load .env file from %s: %v\n", envPath, err)
+        fmt.Fprintf(os.Stderr, "Warning: Could not load .env file from %s: %v\n", envPath, err)
+    }
+    if err := applyConfigFile(); err != nil {
+        return nil, err
     }
 
     config := &Config{
         Server: ServerConfig{
             Host: getEnv("SERVER_HOST", "0.0.0.0"),
             Port: getEnvAsInt("SERVER_PORT", 8080),
+
+            TLSCertFile:           getEnv("TLS_CERT_FILE", ""),
+            TLSKeyFile:            getEnv("TLS_KEY_FILE", ""),
+            TLSClientCAFile:       getEnv("TLS_CLIENT_CA_FILE", ""),
+            TLSClientCertOptional: getEnvAsBool("TLS_CLIENT_CERT_OPTIONAL", false),
+
+            TrustedProxies: getEnvAsSlice("TRUSTED_PROXIES", nil),
+
+            RouteTimeout:  getEnvAsDuration("ROUTE_TIMEOUT", 10*time.Second),
+            RouteTimeouts: getEnvAsDurationMap("ROUTE_TIMEOUTS"),
+
+            ReadTimeout:       getEnvAsDuration("SERVER_READ_TIMEOUT", 15*time.Second),
+            ReadHeaderTimeout: getEnvAsDuration("SERVER_READ_HEADER_TIMEOUT", 5*time.Second),
+            WriteTimeout:      getEnvAsDuration("SERVER_WRITE_TIMEOUT", 15*time.Second),
+            IdleTimeout:       getEnvAsDuration("SERVER_IDLE_TIMEOUT", 60*time.Second),
+            ShutdownTimeout:   getEnvAsDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+
+            MaxHeaderBytes: getEnvAsBytes("SERVER_MAX_HEADER_BYTES", 1<<20),
+            KeepAlive:      getEnvAsBool("SERVER_KEEP_ALIVE", true),
+            TCPKeepAlive:   getEnvAsDuration("SERVER_TCP_KEEP_ALIVE", 15*time.Second),
+            HTTP2:          getEnvAsBool("SERVER_HTTP2", true),
+
+            SlowRequest:     getEnvAsDuration("SLOW_REQUEST_THRESHOLD", 5*time.Second),
+            RateLimit:       getEnvAsInt("RATE_LIMIT", 100),
+            RateLimitWindow: getEnvAsDuration("RATE_LIMIT_WINDOW", time.Minute),
         },
         Database: DatabaseConfig{
             Host:     getEnv("DB_HOST", "localhost"),
@@ -55,11 +402,193 @@ func LoadConfig() (*Config, error) {
             Password: getEnv("DB_PASSWORD", ""),
             DBName:   getEnv("DB_NAME", "log_processing_db"),
             URL:      getEnv("DATABASE_URL", ""),
+
+            Shards: getEnvAsSlice("DATABASE_SHARDS", nil),
+
+            MaxOpenConns:    getEnvAsInt("DB_MAX_OPEN_CONNS", 25),
+            MaxIdleConns:    getEnvAsInt("DB_MAX_IDLE_CONNS", 5),
+            ConnMaxLifetime: getEnvAsDuration("DB_CONN_MAX_LIFETIME", 5*time.Minute),
+            SlowQuery:       getEnvAsDuration("DB_SLOW_QUERY_THRESHOLD", 100*time.Millisecond),
         },
         Log: LogConfig{
             Level:  getEnv("LOG_LEVEL", "info"),
             Format: getEnv("LOG_FORMAT", "json"),
+
+            SelfLogs: getEnv("SELF_LOGS", "store"),
+        },
+        Rollup: RollupConfig{
+            Enabled:    getEnvAsBool("ROLLUP_ENABLED", false),
+            After:      getEnvAsDuration("ROLLUP_AFTER", 7*24*time.Hour),
+            NoiseAfter: getEnvAsDuration("ROLLUP_NOISE_AFTER", 24*time.Hour),
+            Interval:   getEnvAsDuration("ROLLUP_INTERVAL", time.Hour),
+            Levels:     getEnvAsSlice("ROLLUP_LEVELS", []string{"debug", "info"}),
+        },
+        Stats: StatsConfig{
+            RefreshInterval: getEnvAsDuration("STATS_REFRESH_INTERVAL", 30*time.Second),
+        },
+        Integrity: IntegrityConfig{
+            HashChain: getEnvAsBool("INTEGRITY_HASH_CHAIN", false),
+        },
+        Pipeline: PipelineConfig{
+            ConfigPath:    getEnv("PIPELINE_CONFIG", ""),
+            FlushInterval: getEnvAsDuration("PIPELINE_FLUSH_INTERVAL", time.Second),
+        },
+        Anomaly: AnomalyConfig{
+            Enabled:        getEnvAsBool("ANOMALY_ENABLED", false),
+            Window:         getEnvAsDuration("ANOMALY_WINDOW", time.Minute),
+            Alpha:          getEnvAsFloat("ANOMALY_ALPHA", 0.1),
+            Threshold:      getEnvAsFloat("ANOMALY_THRESHOLD", 4),
+            WarmupWindows:  getEnvAsInt("ANOMALY_WARMUP_WINDOWS", 30),
+            SilenceWindows: getEnvAsInt("ANOMALY_SILENCE_WINDOWS", 5),
+            MinRate:        getEnvAsFloat("ANOMALY_MIN_RATE", 5),
+        },
+        Patterns: PatternsConfig{
+            Enabled:     getEnvAsBool("PATTERNS_ENABLED", false),
+            Depth:       getEnvAsInt("PATTERNS_DEPTH", 4),
+            Similarity:  getEnvAsFloat("PATTERNS_SIMILARITY", 0.4),
+            MaxChildren: getEnvAsInt("PATTERNS_MAX_CHILDREN", 100),
+            MaxPatterns: getEnvAsInt("PATTERNS_MAX", 5000),
+        },
+        DeadLetter: DeadLetterConfig{
+            Enabled:      getEnvAsBool("DLQ_ENABLED", false),
+            Backend:      getEnv("DLQ_BACKEND", "table"),
+            File:         getEnv("DLQ_FILE", "dead-letters.jsonl"),
+            StoreRetries: getEnvAsInt("DLQ_STORE_RETRIES", 2),
+            RetryBackoff: getEnvAsDuration("DLQ_RETRY_BACKOFF", 100*time.Millisecond),
+        },
+        Queue: QueueConfig{
+            Backend: getEnv("QUEUE_BACKEND", ""),
+            Addrs:   getEnvAsSlice("QUEUE_ADDRS", []string{"localhost:9092"}),
+            Topic:   getEnv("QUEUE_TOPIC", "raw-logs"),
+            Group:   getEnv("QUEUE_GROUP", "log-processor"),
+            MaxLen:  int64(getEnvAsInt("QUEUE_MAX_LEN", 1000000)),
+        },
+        Processor: ProcessorConfig{
+            Port: getEnvAsInt("PROCESSOR_PORT", 8081),
+        },
+        Archive: ArchiveConfig{
+            Bucket:   getEnv("ARCHIVE_BUCKET", ""),
+            Region:   getEnv("ARCHIVE_REGION", ""),
+            Endpoint: getEnv("ARCHIVE_ENDPOINT", ""),
+            Dir:      getEnv("ARCHIVE_DIR", ""),
+        },
+        Throttle: ThrottleConfig{
+            Enabled:       getEnvAsBool("THROTTLE_ENABLED", false),
+            Rate:          getEnvAsFloat("THROTTLE_RATE", 100),
+            SourceRates:   getEnvAsFloatMap("THROTTLE_SOURCE_RATES"),
+            SampleUntil:   getEnvAsFloat("THROTTLE_SAMPLE_UNTIL", 5),
+            Window:        getEnvAsDuration("THROTTLE_WINDOW", 10*time.Second),
+            ProtectLevels: getEnvAsSlice("THROTTLE_PROTECT_LEVELS", []string{"warn", "error", "fatal"}),
+        },
+        Auth: AuthConfig{
+            Enabled:  getEnvAsBool("AUTH_ENABLED", false),
+            Header:   getEnv("AUTH_HEADER", "X-API-Key"),
+            AdminKey: getEnv("AUTH_ADMIN_KEY", ""),
+            CacheTTL: getEnvAsDuration("AUTH_CACHE_TTL", 30*time.Second),
+
+            JWKSURL:     getEnv("AUTH_JWT_JWKS_URL", ""),
+            JWKSRefresh: getEnvAsDuration("AUTH_JWT_JWKS_REFRESH", time.Hour),
+            Issuer:      getEnv("AUTH_JWT_ISSUER", ""),
+            Audience:    getEnv("AUTH_JWT_AUDIENCE", ""),
+            ClockSkew:   getEnvAsDuration("AUTH_JWT_CLOCK_SKEW", time.Minute),
+            UserClaim:   getEnv("AUTH_JWT_USER_CLAIM", "sub"),
+            TenantClaim: getEnv("AUTH_JWT_TENANT_CLAIM", "tenant_id"),
+            ScopeClaim:  getEnv("AUTH_JWT_SCOPE_CLAIM", "scope"),
+
+            HMACSecrets: getEnvAsSlice("AUTH_HMAC_SECRETS", nil),
+            HMACWindow:  getEnvAsDuration("AUTH_HMAC_WINDOW", 5*time.Minute),
+
+            ClientCNs: getEnvAsListMap("AUTH_CLIENT_CNS"),
+
+            AdminUsername: getEnv("AUTH_ADMIN_USERNAME", ""),
+            AdminPassword: getEnv("AUTH_ADMIN_PASSWORD", ""),
+            AdminToken:    getEnv("AUTH_ADMIN_TOKEN", ""),
         },
+        Audit: AuditConfig{
+            Enabled: getEnvAsBool("AUDIT_ENABLED", true),
+        },
+        Quota: QuotaConfig{
+            Enabled:           getEnvAsBool("QUOTA_ENABLED", false),
+            DailyBytes:        getEnvAsBytes("QUOTA_DAILY_BYTES", 0),
+            RequestsPerMinute: getEnvAsInt("QUOTA_REQUESTS_PER_MINUTE", 0),
+            TenantBytes:       getEnvAsBytesMap("QUOTA_TENANT_BYTES"),
+            TenantRates:       getEnvAsIntMap("QUOTA_TENANT_RATES"),
+            SoftLimit:         getEnvAsFloat("QUOTA_SOFT_LIMIT", 0.8),
+        },
+        Scanner: ScannerConfig{
+            Enabled:       getEnvAsBool("SCANNER_DETECTION_ENABLED", true),
+            Paths:         getEnvAsSlice("SCANNER_PROBE_PATHS", nil),
+            UserAgents:    getEnvAsSlice("SCANNER_USER_AGENTS", nil),
+            NotFoundLimit: getEnvAsInt("SCANNER_NOT_FOUND_LIMIT", 20),
+            Window:        getEnvAsDuration("SCANNER_WINDOW", time.Minute),
+            Block:         getEnvAsBool("SCANNER_BLOCK", false),
+            BlockFor:      getEnvAsDuration("SCANNER_BLOCK_DURATION", 15*time.Minute),
+        },
+        Profiling: ProfilingConfig{
+            Enabled:              getEnvAsBool("PPROF_ENABLED", false),
+            Addr:                 getEnv("PPROF_ADDR", ""),
+            BlockProfileRate:     getEnvAsInt("PPROF_BLOCK_PROFILE_RATE", 0),
+            MutexProfileFraction: getEnvAsInt("PPROF_MUTEX_PROFILE_FRACTION", 0),
+        },
+        Health: HealthConfig{
+            CheckTimeout:      getEnvAsDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+            CacheTTL:          getEnvAsDuration("HEALTH_CACHE_TTL", 5*time.Second),
+            ReadinessInterval: getEnvAsDuration("HEALTH_READINESS_INTERVAL", 2*time.Second),
+        },
+        Crash: CrashConfig{
+            Dir:        getEnv("CRASH_REPORT_DIR", ""),
+            MaxReports: getEnvAsInt("CRASH_REPORT_MAX", 50),
+            RecentLogs: getEnvAsInt("CRASH_RECENT_LOGS", 100),
+        },
+        SLO: SLOConfig{
+            LatencyBuckets:        getEnvAsDurations("SLO_LATENCY_BUCKETS", nil),
+            LatencyTarget:         getEnvAsDuration("SLO_LATENCY_TARGET", 500*time.Millisecond),
+            LatencyObjective:      getEnvAsFloat("SLO_LATENCY_OBJECTIVE", 0.99),
+            AvailabilityObjective: getEnvAsFloat("SLO_AVAILABILITY_OBJECTIVE", 0.999),
+            NativeHistograms:      getEnvAsBool("SLO_NATIVE_HISTOGRAMS", true),
+            // Streams are open for as long as the client wants
+            ExcludeRoutes: getEnvAsSlice("SLO_EXCLUDE_ROUTES", []string{"/logs/tail", "/admin/debug/pprof/"}),
+        },
+        Maintenance: MaintenanceConfig{
+            Enabled:        getEnvAsBool("MAINTENANCE_ENABLED", false),
+            Mode:           getEnv("MAINTENANCE_MODE", "buffer"),
+            BufferFile:     getEnv("MAINTENANCE_BUFFER_FILE", "maintenance-buffer.jsonl"),
+            BufferMaxBytes: getEnvAsBytes("MAINTENANCE_BUFFER_MAX", 1<<30),
+            RetryAfter:     getEnvAsDuration("MAINTENANCE_RETRY_AFTER", 30*time.Second),
+        },
+        WriteQueue: WriteQueueConfig{
+            Enabled:    getEnvAsBool("WRITE_QUEUE_ENABLED", true),
+            Size:       getEnvAsInt("WRITE_QUEUE_SIZE", 10000),
+            Workers:    getEnvAsInt("WRITE_QUEUE_WORKERS", 4),
+            // Leaves room for the entries of requests already admitted
+            HighWater:  getEnvAsFloat("WRITE_QUEUE_HIGH_WATER", 0.9),
+            RetryAfter: getEnvAsDuration("WRITE_QUEUE_RETRY_AFTER", 5*time.Second),
+        },
+        QueryCache: QueryCacheConfig{
+            Backend:       getEnv("QUERY_CACHE_BACKEND", ""),
+            TTL:           getEnvAsDuration("QUERY_CACHE_TTL", 30*time.Second),
+            MaxEntryBytes: getEnvAsBytes("QUERY_CACHE_MAX_ENTRY_BYTES", 1<<20),
+            MaxEntries:    getEnvAsInt("QUERY_CACHE_MAX_ENTRIES", 1000),
+            MaxBytes:      getEnvAsBytes("QUERY_CACHE_MAX_BYTES", 64<<20),
+            RedisAddr:     getEnv("QUERY_CACHE_REDIS_ADDR", "localhost:6379"),
+            KeyPrefix:     getEnv("QUERY_CACHE_KEY_PREFIX", "logquery:"),
+        },
+        Features: getEnvAsBoolMap("FEATURES"),
+    }
+
+    // Live tail streams until the client disconnects
+    if _, ok := config.Server.RouteTimeouts["/logs/tail"]; !ok {
+        config.Server.RouteTimeouts["/logs/tail"] = 0
+    }
+
+    // Profiles stream for as long as they are asked to record
+    if _, ok := config.Server.RouteTimeouts["/admin/debug/pprof/"]; !ok {
+        config.Server.RouteTimeouts["/admin/debug/pprof/"] = 0
+    }
+
+    // Before DATABASE_URL is filled in, so missing credentials are reported
+    if err := config.validate(malformed); err != nil {
+        return nil, err
     }
 
     // If DATABASE_URL is not provided, construct it from individual components
@@ -79,7 +608,7 @@ func LoadConfig() (*Config, error) {
 
 // getEnv gets an environment variable with a fallback value
 func getEnv(key, fallback string) string {
-    if value := os.Getenv(key); value != "" {
+    if value := lookupEnv(key); value != "" {
         return value
     }
     return fallback
@@ -87,10 +616,254 @@ func getEnv(key, fallback string) string {
 
 // getEnvAsInt gets an environment variable as integer with a fallback value
 func getEnvAsInt(key string, fallback int) int {
-    if value := os.Getenv(key); value != "" {
+    if value := lookupEnv(key); value != "" {
         if intVal, err := strconv.Atoi(value); err == nil {
             return intVal
         }
+        recordMalformed(key, value, "an integer")
+    }
+    return fallback
+}
+
+// getEnvAsFloat gets an environment variable as float with a fallback value
+func getEnvAsFloat(key string, fallback float64) float64 {
+    if value := lookupEnv(key); value != "" {
+        if floatVal, err := strconv.ParseFloat(value, 64); err == nil {
+            return floatVal
+        }
+        recordMalformed(key, value, "a number")
+    }
+    return fallback
+}
+
+// getEnvAsBool gets an environment variable as boolean with a fallback value
+func getEnvAsBool(key string, fallback bool) bool {
+    if value := lookupEnv(key); value != "" {
+        if boolVal, err := strconv.ParseBool(value); err == nil {
+            return boolVal
+        }
+        recordMalformed(key, value, "true or false")
     }
     return fallback
 }
+
+// getEnvAsDuration gets an environment variable as duration (e.g. "90s", "24h") with a fallback value
+func getEnvAsDuration(key string, fallback time.Duration) time.Duration {
+    if value := lookupEnv(key); value != "" {
+        if durationVal, err := time.ParseDuration(value); err == nil {
+            return durationVal
+        }
+        recordMalformed(key, value, "a duration such as 30s")
+    }
+    return fallback
+}
+
+// getEnvAsSlice gets a comma-separated environment variable as a slice with a fallback value
+func getEnvAsSlice(key string, fallback []string) []string {
+    value := lookupEnv(key)
+    if value == "" {
+        return fallback
+    }
+
+    var items []string
+    for _, item := range strings.Split(value, ",") {
+        if item = strings.TrimSpace(item); item != "" {
+            items = append(items, item)
+        }
+    }
+    if len(items) == 0 {
+        return fallback
+    }
+    return items
+}
+
+// getEnvAsDurations gets a comma-separated list of durations, e.g.
+// "100ms,500ms,1s", with a fallback value
+func getEnvAsDurations(key string, fallback []time.Duration) []time.Duration {
+    items := getEnvAsSlice(key, nil)
+    if len(items) == 0 {
+        return fallback
+    }
+
+    durations := make([]time.Duration, 0, len(items))
+    for _, item := range items {
+        d, err := time.ParseDuration(item)
+        if err != nil {
+            recordMalformed(key, item, "a duration such as 250ms")
+            return fallback
+        }
+        durations = append(durations, d)
+    }
+    return durations
+}
+
+// getEnvAsFloatMap gets a comma-separated list of key=value pairs with float
+// values, e.g. "payments=500,batch=20"; a malformed pair is reported by
+// Validate
+func getEnvAsFloatMap(key string) map[string]float64 {
+    values := make(map[string]float64)
+    for _, item := range getEnvAsSlice(key, nil) {
+        name, value, ok := strings.Cut(item, "=")
+        floatVal, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+        if !ok || err != nil {
+            recordMalformed(key, item, "name=number")
+            continue
+        }
+        values[strings.TrimSpace(name)] = floatVal
+    }
+    return values
+}
+
+// getEnvAsBoolMap gets a comma-separated list of key=value pairs with
+// boolean values, e.g. "live_tail=false,pipeline_stage.geoip=true"; a
+// malformed pair is reported by Validate
+func getEnvAsBoolMap(key string) map[string]bool {
+    values := make(map[string]bool)
+    for _, item := range getEnvAsSlice(key, nil) {
+        name, value, ok := strings.Cut(item, "=")
+        boolVal, err := strconv.ParseBool(strings.TrimSpace(value))
+        if !ok || err != nil {
+            recordMalformed(key, item, "name=true or name=false")
+            continue
+        }
+        values[strings.TrimSpace(name)] = boolVal
+    }
+    return values
+}
+
+// getEnvAsBytes gets an environment variable as a byte size (e.g. "512",
+// "64KB", "10GB") with a fallback value
+func getEnvAsBytes(key string, fallback int64) int64 {
+    if value := lookupEnv(key); value != "" {
+        if bytesVal, err := parseBytes(value); err == nil {
+            return bytesVal
+        }
+        recordMalformed(key, value, "a byte size such as 64KB")
+    }
+    return fallback
+}
+
+// getEnvAsIntMap gets a comma-separated list of key=value pairs with
+// integer values, e.g. "acme=600,key:batch=10"; a malformed pair is
+// reported by Validate
+func getEnvAsIntMap(key string) map[string]int {
+    values := make(map[string]int)
+    for _, item := range getEnvAsSlice(key, nil) {
+        name, value, ok := strings.Cut(item, "=")
+        intVal, err := strconv.Atoi(strings.TrimSpace(value))
+        if !ok || err != nil {
+            recordMalformed(key, item, "name=integer")
+            continue
+        }
+        values[strings.TrimSpace(name)] = intVal
+    }
+    return values
+}
+
+// getEnvAsBytesMap gets a comma-separated list of key=value pairs with
+// byte size values, e.g. "acme=50GB,key:batch=1GB"; a malformed pair is
+// reported by Validate
+func getEnvAsBytesMap(key string) map[string]int64 {
+    values := make(map[string]int64)
+    for _, item := range getEnvAsSlice(key, nil) {
+        name, value, ok := strings.Cut(item, "=")
+        bytesVal, err := parseBytes(value)
+        if !ok || err != nil {
+            recordMalformed(key, item, "name=size such as acme=50GB")
+            continue
+        }
+        values[strings.TrimSpace(name)] = bytesVal
+    }
+    return values
+}
+
+// byteUnits are the size suffixes parseBytes accepts, largest first
+var byteUnits = []struct {
+    suffix string
+    size   int64
+}{
+    {"TB", 1 << 40},
+    {"GB", 1 << 30},
+    {"MB", 1 << 20},
+    {"KB", 1 << 10},
+    {"B", 1},
+}
+
+// parseBytes parses a byte size with an optional binary unit suffix
+func parseBytes(value string) (int64, error) {
+    value = strings.ToUpper(strings.TrimSpace(value))
+    unit := int64(1)
+    for _, u := range byteUnits {
+        if strings.HasSuffix(value, u.suffix) {
+            value, unit = strings.TrimSpace(strings.TrimSuffix(value, u.suffix)), u.size
+            break
+        }
+    }
+    n, err := strconv.ParseInt(value, 10, 64)
+    if err != nil {
+        return 0, err
+    }
+    if n < 0 {
+        return 0, fmt.Errorf("negative byte size %d", n)
+    }
+    return n * unit, nil
+}
+
+// getEnvAsDurationMap gets a comma-separated list of key=value pairs with
+// duration values, e.g. "/logs/aggregate=30s,/ingest=5s"; a malformed
+// pair is reported by Validate
+func getEnvAsDurationMap(key string) map[string]time.Duration {
+    values := make(map[string]time.Duration)
+    for _, item := range getEnvAsSlice(key, nil) {
+        name, value, ok := strings.Cut(item, "=")
+        durationVal, err := time.ParseDuration(strings.TrimSpace(value))
+        if !ok || err != nil {
+            recordMalformed(key, item, "name=duration such as /ingest=5s")
+            continue
+        }
+        values[strings.TrimSpace(name)] = durationVal
+    }
+    return values
+}
+
+// getEnvAsListMap gets a comma-separated list of key=value pairs whose
+// values are lists joined with "+", e.g. "log-agent=ingest,ops=read+admin";
+// a malformed pair is reported by Validate. It returns nil when the variable
+// is not set.
+func getEnvAsListMap(key string) map[string][]string {
+    items := getEnvAsSlice(key, nil)
+    if len(items) == 0 {
+        return nil
+    }
+
+    values := make(map[string][]string)
+    for _, item := range items {
+        name, value, ok := strings.Cut(item, "=")
+        if !ok {
+            recordMalformed(key, item, "name=value+value")
+            continue
+        }
+        var list []string
+        for _, v := range strings.Split(value, "+") {
+            if v = strings.TrimSpace(v); v != "" {
+                list = append(list, v)
+            }
+        }
+        values[strings.TrimSpace(name)] = list
+    }
+    return values
+}
+
+// ReloadEnv re-reads the .env file, overriding variables already set, and
+// then the config file, so a running service can pick up changed settings
+// such as LOG_LEVEL; command-line flags still take precedence
+func ReloadEnv() error {
+    err := godotenv.Overload(envPath)
+    if flagErr := applyFlags(); flagErr != nil {
+        return flagErr
+    }
+    if fileErr := applyConfigFile(); fileErr != nil {
+        return fileErr
+    }
+    return err
+}
\ No newline at end of file
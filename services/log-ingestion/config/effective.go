@@ -0,0 +1,114 @@
+package config
+
+import (
+    "net/url"
+    "reflect"
+    "regexp"
+    "strings"
+    "time"
+    "log-processing-system/services/log-ingestion/logger"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// dsnPassword matches the password of a key=value connection string
+var dsnPassword = regexp.MustCompile(`(password\s*=\s*)('[^']*'|[^\s&]+)`)
+
+// Effective returns the configuration as a map of sections and settings,
+// ready to print as JSON: durations written as "30s", and the settings
+// tagged redact masked, so secrets never leave the process. A URL, or list
+// of URLs, tagged redact:"url" keeps everything but its password.
+func (c *Config) Effective() map[string]interface{} {
+    return effectiveStruct(reflect.ValueOf(*c))
+}
+
+// effectiveStruct converts a config section
+func effectiveStruct(v reflect.Value) map[string]interface{} {
+    section := make(map[string]interface{}, v.NumField())
+    for i := 0; i < v.NumField(); i++ {
+        field := v.Type().Field(i)
+        value := v.Field(i)
+        switch field.Tag.Get("redact") {
+        case "true":
+            section[field.Name] = redactValue(value)
+        case "url":
+            section[field.Name] = redactURLs(value)
+        default:
+            section[field.Name] = effectiveValue(value)
+        }
+    }
+    return section
+}
+
+// effectiveValue converts a setting
+func effectiveValue(v reflect.Value) interface{} {
+    if v.Type() == durationType {
+        return time.Duration(v.Int()).String()
+    }
+    switch v.Kind() {
+    case reflect.Struct:
+        return effectiveStruct(v)
+    case reflect.Map:
+        if v.IsNil() {
+            return nil
+        }
+        m := make(map[string]interface{}, v.Len())
+        iter := v.MapRange()
+        for iter.Next() {
+            m[iter.Key().String()] = effectiveValue(iter.Value())
+        }
+        return m
+    case reflect.Slice:
+        if v.Type().Elem() == durationType {
+            durations := make([]string, v.Len())
+            for i := range durations {
+                durations[i] = time.Duration(v.Index(i).Int()).String()
+            }
+            return durations
+        }
+    }
+    return v.Interface()
+}
+
+// redactValue masks a secret, or each of a list of secrets, leaving unset
+// ones empty so it still shows whether they are set
+func redactValue(v reflect.Value) interface{} {
+    if v.Kind() == reflect.Slice {
+        masked := make([]string, v.Len())
+        for i := range masked {
+            masked[i] = logger.RedactedValue
+        }
+        return masked
+    }
+    if v.IsZero() {
+        return ""
+    }
+    return logger.RedactedValue
+}
+
+// redactURLs masks the password of a URL, or of each of a list of URLs
+func redactURLs(v reflect.Value) interface{} {
+    if v.Kind() == reflect.Slice {
+        redacted := make([]string, v.Len())
+        for i := range redacted {
+            redacted[i] = redactURL(v.Index(i).String())
+        }
+        return redacted
+    }
+    return redactURL(v.String())
+}
+
+// redactURL masks the password of a URL or key=value connection string
+func redactURL(value string) string {
+    if u, err := url.Parse(value); err == nil && u.User != nil {
+        if _, ok := u.User.Password(); !ok {
+            return value
+        }
+        // Inserted after encoding, which would escape the brackets
+        u.User = url.User(u.User.Username())
+        s := u.String()
+        at := strings.Index(s, "@")
+        return s[:at] + ":" + logger.RedactedValue + s[at:]
+    }
+    return dsnPassword.ReplaceAllString(value, "${1}"+logger.RedactedValue)
+}
@@ -0,0 +1,246 @@
+package config
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/BurntSushi/toml"
+    "gopkg.in/yaml.v3"
+)
+
+// configFileEnv names the YAML or TOML config file to load
+const configFileEnv = "CONFIG_FILE"
+
+// mapSettings are the settings whose values are maps, written as a list of
+// key<separator>value pairs; a list value is joined with "+"
+var mapSettings = map[string]string{
+    "ROUTE_TIMEOUTS":             "=",
+    "THROTTLE_SOURCE_RATES":      "=",
+    "AUTH_CLIENT_CNS":            "=",
+    "QUOTA_TENANT_BYTES":         "=",
+    "QUOTA_TENANT_RATES":         "=",
+    "FEATURES":                   "=",
+    "OTEL_EXPORTER_OTLP_HEADERS": "=",
+    "LOG_LEVELS":                 ":",
+    "LOG_SAMPLING":               ":",
+}
+
+// initialEnv holds the variables set in the environment before any file
+// was read; file values never replace them
+var initialEnv map[string]bool
+
+// snapshotEnv records the variables the environment sets, once
+func snapshotEnv() {
+    if initialEnv != nil {
+        return
+    }
+    initialEnv = make(map[string]bool)
+    for _, kv := range os.Environ() {
+        if key, _, ok := strings.Cut(kv, "="); ok {
+            initialEnv[key] = true
+        }
+    }
+}
+
+// applyConfigFile reads the config file named by CONFIG_FILE, if any, and
+// sets each setting it holds as the environment variable of the same
+// name, unless the environment itself set that variable. File values thus
+// replace those of the .env file, and environment variables replace both.
+func applyConfigFile() error {
+    path := os.Getenv(configFileEnv)
+    if path == "" {
+        return nil
+    }
+    values, err := ReadConfigFile(path)
+    if err != nil {
+        return err
+    }
+    for key, value := range values {
+        if initialEnv[key] {
+            continue
+        }
+        if err := os.Setenv(key, value); err != nil {
+            return fmt.Errorf("config file %s: %s: %w", path, key, err)
+        }
+    }
+    return nil
+}
+
+// ReadConfigFile reads a YAML (.yaml, .yml) or TOML (.toml) config file
+// into the environment variables it sets. Nested sections are joined into
+// variable names with "_", so
+//
+//    server:
+//      port: 8080
+//    auth:
+//      jwt:
+//        jwks_url: https://idp.example.com/jwks
+//
+// sets SERVER_PORT and AUTH_JWT_JWKS_URL. Lists are joined with commas, and
+// map settings such as route_timeouts are written as key=value lists.
+func ReadConfigFile(path string) (map[string]string, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read config file: %w", err)
+    }
+
+    var doc map[string]interface{}
+    switch ext := strings.ToLower(filepath.Ext(path)); ext {
+    case ".yaml", ".yml":
+        err = yaml.Unmarshal(data, &doc)
+    case ".toml":
+        err = toml.Unmarshal(data, &doc)
+    default:
+        return nil, fmt.Errorf("config file %s: unsupported format %q, expected .yaml, .yml or .toml", path, ext)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+    }
+
+    values := make(map[string]string)
+    if err := flattenSettings("", doc, values); err != nil {
+        return nil, fmt.Errorf("config file %s: %w", path, err)
+    }
+    return values, nil
+}
+
+// flattenSettings adds the settings of a section to values, named after
+// the section prefix and their keys
+func flattenSettings(prefix string, section map[string]interface{}, values map[string]string) error {
+    for key, value := range section {
+        name := settingName(prefix, key)
+        if separator, ok := mapSettings[name]; ok {
+            formatted, err := formatMap(value, separator)
+            if err != nil {
+                return fmt.Errorf("%s: %w", name, err)
+            }
+            values[name] = formatted
+            continue
+        }
+
+        if nested, ok := asMap(value); ok {
+            if err := flattenSettings(name, nested, values); err != nil {
+                return err
+            }
+            continue
+        }
+
+        formatted, err := formatValue(value)
+        if err != nil {
+            return fmt.Errorf("%s: %w", name, err)
+        }
+        values[name] = formatted
+    }
+    return nil
+}
+
+// settingName joins a section prefix and a key into a variable name
+func settingName(prefix, key string) string {
+    name := strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(key))
+    if prefix == "" {
+        return name
+    }
+    return prefix + "_" + name
+}
+
+// asMap returns a YAML or TOML table as a map with string keys
+func asMap(value interface{}) (map[string]interface{}, bool) {
+    switch v := value.(type) {
+    case map[string]interface{}:
+        return v, true
+    case map[interface{}]interface{}:
+        m := make(map[string]interface{}, len(v))
+        for key, value := range v {
+            m[fmt.Sprint(key)] = value
+        }
+        return m, true
+    }
+    return nil, false
+}
+
+// formatMap writes a map setting as a sorted, comma-separated list of
+// key<separator>value pairs; a string is taken as already written so
+func formatMap(value interface{}, separator string) (string, error) {
+    if s, ok := value.(string); ok {
+        return s, nil
+    }
+    m, ok := asMap(value)
+    if !ok {
+        return "", fmt.Errorf("expected a map, got %T", value)
+    }
+
+    keys := make([]string, 0, len(m))
+    for key := range m {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+
+    pairs := make([]string, 0, len(keys))
+    for _, key := range keys {
+        var formatted string
+        if list, ok := m[key].([]interface{}); ok {
+            items := make([]string, 0, len(list))
+            for _, item := range list {
+                s, err := formatScalar(item)
+                if err != nil {
+                    return "", fmt.Errorf("%s: %w", key, err)
+                }
+                items = append(items, s)
+            }
+            formatted = strings.Join(items, "+")
+        } else {
+            s, err := formatScalar(m[key])
+            if err != nil {
+                return "", fmt.Errorf("%s: %w", key, err)
+            }
+            formatted = s
+        }
+        pairs = append(pairs, key+separator+formatted)
+    }
+    return strings.Join(pairs, ","), nil
+}
+
+// formatValue writes a scalar, or a list of scalars joined with commas
+func formatValue(value interface{}) (string, error) {
+    list, ok := value.([]interface{})
+    if !ok {
+        return formatScalar(value)
+    }
+    items := make([]string, 0, len(list))
+    for _, item := range list {
+        s, err := formatScalar(item)
+        if err != nil {
+            return "", err
+        }
+        items = append(items, s)
+    }
+    return strings.Join(items, ","), nil
+}
+
+// formatScalar writes a single value as an environment variable holds it
+func formatScalar(value interface{}) (string, error) {
+    switch v := value.(type) {
+    case nil:
+        return "", nil
+    case string:
+        return v, nil
+    case bool:
+        return strconv.FormatBool(v), nil
+    case int:
+        return strconv.Itoa(v), nil
+    case int64:
+        return strconv.FormatInt(v, 10), nil
+    case uint64:
+        return strconv.FormatUint(v, 10), nil
+    case float64:
+        return strconv.FormatFloat(v, 'f', -1, 64), nil
+    case time.Time:
+        return v.Format(time.RFC3339Nano), nil
+    }
+    return "", fmt.Errorf("unsupported value %v of type %T", value, value)
+}
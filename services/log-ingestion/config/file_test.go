@@ -0,0 +1,150 @@
+package config
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+    t.Helper()
+    path := filepath.Join(t.TempDir(), name)
+    if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+        t.Fatalf("Failed to write %s: %v", name, err)
+    }
+    return path
+}
+
+func TestReadConfigFile(t *testing.T) {
+    want := map[string]string{
+        "SERVER_PORT":           "9090",
+        "TRUSTED_PROXIES":       "10.0.0.0/8,127.0.0.1",
+        "ROUTE_TIMEOUTS":        "/logs/aggregate=30s,/logs/tail=0",
+        "DB_HOST":               "db.internal",
+        "LOG_LEVELS":            "database:DEBUG,http:WARN",
+        "AUTH_JWT_JWKS_URL":     "https://idp.example.com/jwks",
+        "AUTH_CLIENT_CNS":       "log-agent=ingest,ops=read+admin",
+        "THROTTLE_ENABLED":      "true",
+        "THROTTLE_SOURCE_RATES": "batch_jobs=20,payment_service=500",
+        "QUOTA_SOFT_LIMIT":      "0.75",
+        "PIPELINE_CONFIG":       "pipeline.yaml",
+    }
+
+    yamlPath := writeFile(t, "config.yaml", `
+server:
+  port: 9090
+trusted_proxies: [10.0.0.0/8, 127.0.0.1]
+route_timeouts:
+  /logs/aggregate: 30s
+  /logs/tail: 0
+db:
+  host: db.internal
+log:
+  levels:
+    database: DEBUG
+    http: WARN
+auth:
+  jwt:
+    jwks_url: https://idp.example.com/jwks
+  client_cns:
+    log-agent: [ingest]
+    ops: [read, admin]
+throttle:
+  enabled: true
+  source_rates:
+    payment_service: 500
+    batch_jobs: 20
+quota:
+  soft_limit: 0.75
+pipeline:
+  config: pipeline.yaml
+`)
+    tomlPath := writeFile(t, "config.toml", `
+trusted_proxies = ["10.0.0.0/8", "127.0.0.1"]
+
+[server]
+port = 9090
+
+[route_timeouts]
+"/logs/aggregate" = "30s"
+"/logs/tail" = 0
+
+[db]
+host = "db.internal"
+
+[log.levels]
+database = "DEBUG"
+http = "WARN"
+
+[auth.jwt]
+jwks_url = "https://idp.example.com/jwks"
+
+[auth.client_cns]
+log-agent = ["ingest"]
+ops = ["read", "admin"]
+
+[throttle]
+enabled = true
+source_rates = { payment_service = 500, batch_jobs = 20 }
+
+[quota]
+soft_limit = 0.75
+
+[pipeline]
+config = "pipeline.yaml"
+`)
+
+    for _, path := range []string{yamlPath, tomlPath} {
+        values, err := ReadConfigFile(path)
+        if err != nil {
+            t.Fatalf("%s: %v", filepath.Base(path), err)
+        }
+        for key, value := range want {
+            if values[key] != value {
+                t.Errorf("%s: expected %s=%q, got %q", filepath.Base(path), key, value, values[key])
+            }
+        }
+        if len(values) != len(want) {
+            t.Errorf("%s: expected %d settings, got %v", filepath.Base(path), len(want), values)
+        }
+    }
+}
+
+func TestReadConfigFile_Errors(t *testing.T) {
+    for name, content := range map[string]string{
+        "config.json": `{}`,
+        "bad.yaml":    "server: [port",
+        "map.yaml":    "route_timeouts: [30s]",
+    } {
+        if _, err := ReadConfigFile(writeFile(t, name, content)); err == nil {
+            t.Errorf("%s: expected an error", name)
+        }
+    }
+}
+
+func TestLoadConfig_Precedence(t *testing.T) {
+    path := writeFile(t, "config.yaml", "server:\n  port: 9090\n  host: 127.0.0.1\nroute_timeout: 20s\n")
+    t.Setenv(configFileEnv, path)
+    t.Setenv("SERVER_HOST", "10.1.2.3")
+    t.Setenv("DB_USER", "logs")
+    t.Setenv("DB_PASSWORD", "secret")
+    for _, key := range []string{"SERVER_PORT", "ROUTE_TIMEOUT"} {
+        // Restored after the test
+        t.Setenv(key, "")
+        os.Unsetenv(key)
+    }
+    initialEnv = nil
+    defer func() { initialEnv = nil }()
+
+    cfg, err := LoadConfig()
+    if err != nil {
+        t.Fatalf("Failed to load config: %v", err)
+    }
+    if cfg.Server.Port != 9090 || cfg.Server.RouteTimeout != 20*time.Second {
+        t.Errorf("Expected the file's values, got port %d and timeout %v", cfg.Server.Port, cfg.Server.RouteTimeout)
+    }
+    if cfg.Server.Host != "10.1.2.3" {
+        t.Errorf("Expected the environment to override the file, got host %s", cfg.Server.Host)
+    }
+}
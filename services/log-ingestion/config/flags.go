@@ -0,0 +1,90 @@
+package config
+
+import (
+    "flag"
+    "fmt"
+    "os"
+)
+
+// flagValues holds the settings given on the command line, by environment
+// variable; they take precedence over every other source
+var flagValues = make(map[string]string)
+
+// Flags are the command-line flags that are not settings
+type Flags struct {
+    // PrintConfig prints the effective configuration and exits
+    PrintConfig bool
+}
+
+// ParseFlags parses the command-line flags of a service:
+//
+//    -port          listening port, setting portEnv (SERVER_PORT or PROCESSOR_PORT)
+//    -config        YAML or TOML config file (CONFIG_FILE)
+//    -log-level     log level (LOG_LEVEL)
+//    -db-url        database connection URL (DATABASE_URL)
+//    -print-config  print the effective configuration, secrets masked, and exit
+//
+// Each setting flag given sets the environment variable it stands for, so
+// it overrides the environment, the config file and the .env file, and is
+// seen by the logger too; call it before creating the logger. With -help
+// it prints the usage and returns flag.ErrHelp; other errors are printed to
+// standard error as well.
+func ParseFlags(service, portEnv string, args []string) (Flags, error) {
+    var flags Flags
+    fs := flag.NewFlagSet(service, flag.ContinueOnError)
+    envs := map[string]string{
+        "port":      portEnv,
+        "config":    configFileEnv,
+        "log-level": "LOG_LEVEL",
+        "db-url":    "DATABASE_URL",
+    }
+    fs.Int("port", 0, "port to listen on (overrides "+portEnv+")")
+    fs.String("config", "", "YAML or TOML config file (overrides "+configFileEnv+")")
+    fs.String("log-level", "", "log level: DEBUG, INFO, WARN, ERROR or FATAL (overrides LOG_LEVEL)")
+    fs.String("db-url", "", "PostgreSQL connection URL (overrides DATABASE_URL)")
+    fs.BoolVar(&flags.PrintConfig, "print-config", false, "print the effective configuration as JSON, secrets masked, and exit")
+    fs.Usage = func() {
+        out := fs.Output()
+        fmt.Fprintf(out, "Usage: %s [flags]\n\n", service)
+        fmt.Fprintf(out, "Settings come from flags, environment variables, the config file and the\n")
+        fmt.Fprintf(out, ".env file, in decreasing precedence; see .env.example for all of them.\n\n")
+        fmt.Fprintf(out, "Flags:\n")
+        fs.PrintDefaults()
+    }
+    if err := fs.Parse(args); err != nil {
+        return flags, err
+    }
+    if fs.NArg() > 0 {
+        err := fmt.Errorf("unexpected argument %q", fs.Arg(0))
+        fmt.Fprintln(fs.Output(), err)
+        fs.Usage()
+        return flags, err
+    }
+
+    snapshotEnv()
+    var err error
+    fs.Visit(func(f *flag.Flag) {
+        env, ok := envs[f.Name]
+        if !ok {
+            return
+        }
+        flagValues[env] = f.Value.String()
+        initialEnv[env] = true
+        if setErr := os.Setenv(env, f.Value.String()); setErr != nil && err == nil {
+            err = fmt.Errorf("-%s: %w", f.Name, setErr)
+            fmt.Fprintln(fs.Output(), err)
+        }
+    })
+    return flags, err
+}
+
+// applyFlags sets the settings given on the command line again, after a
+// reload of the .env file replaced them
+func applyFlags() error {
+    for key, value := range flagValues {
+        if err := os.Setenv(key, value); err != nil {
+            return err
+        }
+    }
+    return nil
+}
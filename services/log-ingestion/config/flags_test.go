@@ -0,0 +1,64 @@
+package config
+
+import (
+    "flag"
+    "os"
+    "testing"
+)
+
+func TestParseFlags(t *testing.T) {
+    path := writeFile(t, "config.yaml", "server:\n  port: 9090\nlog:\n  level: info\n")
+    t.Setenv("LOG_LEVEL", "WARN")
+    t.Setenv("DB_USER", "logs")
+    t.Setenv("DB_PASSWORD", "secret")
+    for _, key := range []string{configFileEnv, "SERVER_PORT", "DATABASE_URL"} {
+        // Restored after the test
+        t.Setenv(key, "")
+        os.Unsetenv(key)
+    }
+    initialEnv = nil
+    defer func() {
+        initialEnv = nil
+        flagValues = make(map[string]string)
+    }()
+
+    flags, err := ParseFlags("log-ingestion", "SERVER_PORT", []string{
+        "-port", "9191", "--config", path, "-log-level=DEBUG", "-db-url", "postgres://logs@db.internal/logs", "-print-config",
+    })
+    if err != nil {
+        t.Fatalf("Failed to parse flags: %v", err)
+    }
+    if !flags.PrintConfig {
+        t.Error("Expected -print-config to be set")
+    }
+    cfg, err := LoadConfig()
+    if err != nil {
+        t.Fatalf("Failed to load config: %v", err)
+    }
+    if cfg.Server.Port != 9191 {
+        t.Errorf("Expected -port to override the config file, got %d", cfg.Server.Port)
+    }
+    if cfg.Log.Level != "DEBUG" {
+        t.Errorf("Expected -log-level to override the environment, got %s", cfg.Log.Level)
+    }
+    if cfg.Database.URL != "postgres://logs@db.internal/logs" {
+        t.Errorf("Expected -db-url to set the database URL, got %s", cfg.Database.URL)
+    }
+
+    // A reload of the .env file leaves flags in place
+    os.Setenv("LOG_LEVEL", "ERROR")
+    if err := applyFlags(); err != nil || os.Getenv("LOG_LEVEL") != "DEBUG" {
+        t.Errorf("Expected the flag to be applied again, got %s (%v)", os.Getenv("LOG_LEVEL"), err)
+    }
+}
+
+func TestParseFlags_Errors(t *testing.T) {
+    if _, err := ParseFlags("log-ingestion", "SERVER_PORT", []string{"-help"}); err != flag.ErrHelp {
+        t.Errorf("Expected flag.ErrHelp, got %v", err)
+    }
+    for _, args := range [][]string{{"-port", "http"}, {"-verbose"}, {"serve"}} {
+        if _, err := ParseFlags("log-ingestion", "SERVER_PORT", args); err == nil {
+            t.Errorf("%v: expected an error", args)
+        }
+    }
+}
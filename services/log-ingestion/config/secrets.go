@@ -0,0 +1,42 @@
+package config
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "time"
+    "log-processing-system/services/log-ingestion/secrets"
+)
+
+// secretResolver resolves the secret references settings hold, created when
+// the first one is met
+var secretResolver interface {
+    Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// lookupEnv returns an environment variable. A value referring to a secret,
+// aws-sm://name[#field] or ssm://path, is replaced by the secret; when that
+// fails the problem is recorded for Validate and the reference returned.
+func lookupEnv(key string) string {
+    value := os.Getenv(key)
+    if !secrets.IsReference(value) {
+        return value
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+    defer cancel()
+    if secretResolver == nil {
+        resolver, err := secrets.NewResolver(ctx)
+        if err != nil {
+            addMalformed(FieldError{Field: key, Message: fmt.Sprintf("cannot resolve secret: %v", err)})
+            return value
+        }
+        secretResolver = resolver
+    }
+    secret, err := secretResolver.Resolve(ctx, value)
+    if err != nil {
+        addMalformed(FieldError{Field: key, Message: fmt.Sprintf("cannot resolve secret: %v", err)})
+        return value
+    }
+    return secret
+}
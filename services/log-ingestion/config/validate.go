@@ -0,0 +1,387 @@
+package config
+
+import (
+    "fmt"
+    "net"
+    "net/url"
+    "strings"
+    "time"
+    "log-processing-system/services/log-ingestion/features"
+)
+
+// FieldError is a problem with one setting, named by its environment
+// variable
+type FieldError struct {
+    Field   string
+    Message string
+}
+
+func (e FieldError) Error() string {
+    return e.Field + ": " + e.Message
+}
+
+// ValidationError lists every problem found in a configuration, so they can
+// all be fixed at once
+type ValidationError struct {
+    Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+    if len(e.Errors) == 1 {
+        return "invalid configuration: " + e.Errors[0].Error()
+    }
+    problems := make([]string, len(e.Errors))
+    for i, fieldErr := range e.Errors {
+        problems[i] = fieldErr.Error()
+    }
+    return fmt.Sprintf("invalid configuration, %d problems: %s", len(e.Errors), strings.Join(problems, "; "))
+}
+
+// add records a problem with a setting
+func (e *ValidationError) add(field, format string, args ...interface{}) {
+    e.Errors = append(e.Errors, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// malformed holds the settings LoadConfig could not parse or resolve and
+// replaced with their defaults; it reports them with the other problems
+var malformed []FieldError
+
+// recordMalformed notes a setting whose value could not be parsed
+func recordMalformed(key, value, expected string) {
+    addMalformed(FieldError{Field: key, Message: fmt.Sprintf("expected %s, got %q", expected, value)})
+}
+
+// addMalformed notes a problem with a setting's value, the first one only
+func addMalformed(fieldErr FieldError) {
+    if !hasField(malformed, fieldErr.Field) {
+        malformed = append(malformed, fieldErr)
+    }
+}
+
+// Log levels and formats the logger understands
+var (
+    logLevels  = []string{"DEBUG", "INFO", "WARN", "WARNING", "ERROR", "FATAL"}
+    logFormats = []string{"AUTO", "JSON", "TEXT", "LOGFMT", "CONSOLE", "ECS", "GELF"}
+)
+
+// Validate checks the configuration for values that would otherwise fail,
+// or be silently ignored, once the service runs: out of range ports,
+// missing database credentials, malformed URLs and addresses, unknown
+// options and settings that must or must not be used together. It returns
+// a *ValidationError listing every problem, or nil.
+func (c *Config) Validate() error {
+    return c.validate(nil)
+}
+
+// validate checks the configuration, adding the problems with the settings
+// it was read from
+func (c *Config) validate(readErrs []FieldError) error {
+    v := &ValidationError{}
+
+    validatePort(v, "SERVER_PORT", c.Server.Port)
+    validatePort(v, "DB_PORT", c.Database.Port)
+    validatePort(v, "PROCESSOR_PORT", c.Processor.Port)
+
+    // Database: a URL, or the credentials to build one from
+    if c.Database.URL == "" {
+        if c.Database.User == "" {
+            v.add("DB_USER", "is required when DATABASE_URL is not set")
+        }
+        if c.Database.Password == "" {
+            v.add("DB_PASSWORD", "is required when DATABASE_URL is not set")
+        }
+    } else {
+        validateDatabaseURL(v, "DATABASE_URL", c.Database.URL)
+    }
+    seenShards := make(map[string]bool, len(c.Database.Shards))
+    for _, shard := range c.Database.Shards {
+        validateDatabaseURL(v, "DATABASE_SHARDS", shard)
+        if seenShards[shard] {
+            v.add("DATABASE_SHARDS", "lists a database twice")
+        }
+        seenShards[shard] = true
+    }
+
+    if c.Database.MaxOpenConns < 0 {
+        v.add("DB_MAX_OPEN_CONNS", "must not be negative, got %d", c.Database.MaxOpenConns)
+    }
+    if c.Database.MaxIdleConns < 0 {
+        v.add("DB_MAX_IDLE_CONNS", "must not be negative, got %d", c.Database.MaxIdleConns)
+    }
+    validateDuration(v, "DB_CONN_MAX_LIFETIME", c.Database.ConnMaxLifetime)
+    validateDuration(v, "DB_SLOW_QUERY_THRESHOLD", c.Database.SlowQuery)
+
+    // Server: TLS, client certificates and proxies
+    if (c.Server.TLSCertFile == "") != (c.Server.TLSKeyFile == "") {
+        v.add("TLS_CERT_FILE", "TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+    }
+    if c.Server.TLSClientCAFile != "" && c.Server.TLSCertFile == "" {
+        v.add("TLS_CLIENT_CA_FILE", "needs TLS_CERT_FILE and TLS_KEY_FILE")
+    }
+    for _, proxy := range c.Server.TrustedProxies {
+        if strings.Contains(proxy, "/") {
+            if _, _, err := net.ParseCIDR(proxy); err != nil {
+                v.add("TRUSTED_PROXIES", "invalid range %q", proxy)
+            }
+        } else if net.ParseIP(proxy) == nil {
+            v.add("TRUSTED_PROXIES", "invalid address %q", proxy)
+        }
+    }
+    validateDuration(v, "ROUTE_TIMEOUT", c.Server.RouteTimeout)
+    validateDuration(v, "SERVER_READ_TIMEOUT", c.Server.ReadTimeout)
+    validateDuration(v, "SERVER_READ_HEADER_TIMEOUT", c.Server.ReadHeaderTimeout)
+    validateDuration(v, "SERVER_WRITE_TIMEOUT", c.Server.WriteTimeout)
+    validateDuration(v, "SERVER_IDLE_TIMEOUT", c.Server.IdleTimeout)
+    validateDuration(v, "SHUTDOWN_TIMEOUT", c.Server.ShutdownTimeout)
+    validateDuration(v, "SERVER_TCP_KEEP_ALIVE", c.Server.TCPKeepAlive)
+    if c.Server.MaxHeaderBytes < 0 {
+        v.add("SERVER_MAX_HEADER_BYTES", "must not be negative, got %d", c.Server.MaxHeaderBytes)
+    }
+    validateDuration(v, "SLOW_REQUEST_THRESHOLD", c.Server.SlowRequest)
+    if c.Server.RateLimit < 0 {
+        v.add("RATE_LIMIT", "must not be negative, got %d", c.Server.RateLimit)
+    }
+    if c.Server.RateLimit > 0 && c.Server.RateLimitWindow <= 0 {
+        v.add("RATE_LIMIT_WINDOW", "must be positive, got %v", c.Server.RateLimitWindow)
+    }
+    for route, timeout := range c.Server.RouteTimeouts {
+        if timeout < 0 {
+            v.add("ROUTE_TIMEOUTS", "timeout of %s must not be negative, got %v", route, timeout)
+        }
+    }
+
+    if !containsFold(logLevels, c.Log.Level) {
+        v.add("LOG_LEVEL", "must be one of %s, got %q", strings.Join(logLevels, ", "), c.Log.Level)
+    }
+    if !containsFold(logFormats, c.Log.Format) {
+        v.add("LOG_FORMAT", "must be one of %s, got %q", strings.Join(logFormats, ", "), c.Log.Format)
+    }
+
+    if c.Log.SelfLogs != "store" && c.Log.SelfLogs != "drop" {
+        v.add("SELF_LOGS", "must be store or drop, got %q", c.Log.SelfLogs)
+    }
+
+    // Each of these drives a ticker, which panics on a zero or negative period
+    if c.Rollup.Enabled && c.Rollup.Interval <= 0 {
+        v.add("ROLLUP_INTERVAL", "must be positive, got %v", c.Rollup.Interval)
+    }
+    if c.Stats.RefreshInterval <= 0 {
+        v.add("STATS_REFRESH_INTERVAL", "must be positive, got %v", c.Stats.RefreshInterval)
+    }
+    if c.Pipeline.ConfigPath != "" && c.Pipeline.FlushInterval <= 0 {
+        v.add("PIPELINE_FLUSH_INTERVAL", "must be positive, got %v", c.Pipeline.FlushInterval)
+    }
+    if c.Anomaly.Enabled && c.Anomaly.Window <= 0 {
+        v.add("ANOMALY_WINDOW", "must be positive, got %v", c.Anomaly.Window)
+    }
+
+    if c.Anomaly.Alpha <= 0 || c.Anomaly.Alpha > 1 {
+        v.add("ANOMALY_ALPHA", "must be greater than 0 and at most 1, got %v", c.Anomaly.Alpha)
+    }
+    if c.Patterns.Similarity < 0 || c.Patterns.Similarity > 1 {
+        v.add("PATTERNS_SIMILARITY", "must be between 0 and 1, got %v", c.Patterns.Similarity)
+    }
+
+    if c.DeadLetter.Backend != "table" && c.DeadLetter.Backend != "file" {
+        v.add("DLQ_BACKEND", "must be table or file, got %q", c.DeadLetter.Backend)
+    }
+    if c.DeadLetter.Backend == "file" && c.DeadLetter.File == "" {
+        v.add("DLQ_FILE", "is required with the file backend")
+    }
+    if c.Queue.Backend != "" && c.Queue.Backend != "kafka" && c.Queue.Backend != "redis" {
+        v.add("QUEUE_BACKEND", "must be kafka, redis or empty, got %q", c.Queue.Backend)
+    }
+
+    // Archive replay reads a bucket or a directory, not both
+    if c.Archive.Bucket != "" && c.Archive.Dir != "" {
+        v.add("ARCHIVE_DIR", "cannot be used together with ARCHIVE_BUCKET")
+    }
+    if c.Archive.Endpoint != "" {
+        validateHTTPURL(v, "ARCHIVE_ENDPOINT", c.Archive.Endpoint)
+    }
+
+    if c.Throttle.Enabled && c.Throttle.Rate <= 0 {
+        v.add("THROTTLE_RATE", "must be positive, got %v", c.Throttle.Rate)
+    }
+
+    // Authentication
+    if c.Auth.JWKSURL != "" {
+        validateHTTPURL(v, "AUTH_JWT_JWKS_URL", c.Auth.JWKSURL)
+    }
+    if c.Auth.ClientCNs != nil && c.Server.TLSClientCAFile == "" {
+        v.add("AUTH_CLIENT_CNS", "needs TLS_CLIENT_CA_FILE to verify client certificates")
+    }
+    if (c.Auth.AdminUsername == "") != (c.Auth.AdminPassword == "") {
+        v.add("AUTH_ADMIN_USERNAME", "AUTH_ADMIN_USERNAME and AUTH_ADMIN_PASSWORD must be set together")
+    }
+
+    if c.Quota.SoftLimit <= 0 || c.Quota.SoftLimit > 1 {
+        v.add("QUOTA_SOFT_LIMIT", "must be greater than 0 and at most 1, got %v", c.Quota.SoftLimit)
+    }
+    if c.Quota.RequestsPerMinute < 0 {
+        v.add("QUOTA_REQUESTS_PER_MINUTE", "must not be negative, got %d", c.Quota.RequestsPerMinute)
+    }
+
+    if c.Profiling.Addr != "" {
+        if _, _, err := net.SplitHostPort(c.Profiling.Addr); err != nil {
+            v.add("PPROF_ADDR", "must be host:port, got %q", c.Profiling.Addr)
+        }
+    }
+    if c.Profiling.BlockProfileRate < 0 {
+        v.add("PPROF_BLOCK_PROFILE_RATE", "must not be negative, got %d", c.Profiling.BlockProfileRate)
+    }
+    if c.Profiling.MutexProfileFraction < 0 {
+        v.add("PPROF_MUTEX_PROFILE_FRACTION", "must not be negative, got %d", c.Profiling.MutexProfileFraction)
+    }
+
+    validateDuration(v, "HEALTH_CHECK_TIMEOUT", c.Health.CheckTimeout)
+    validateDuration(v, "HEALTH_CACHE_TTL", c.Health.CacheTTL)
+    if c.Health.ReadinessInterval <= 0 {
+        v.add("HEALTH_READINESS_INTERVAL", "must be positive, got %v", c.Health.ReadinessInterval)
+    }
+
+    if c.Crash.MaxReports < 0 {
+        v.add("CRASH_REPORT_MAX", "must not be negative, got %d", c.Crash.MaxReports)
+    }
+    if c.Crash.RecentLogs < 0 {
+        v.add("CRASH_RECENT_LOGS", "must not be negative, got %d", c.Crash.RecentLogs)
+    }
+
+    for i, bucket := range c.SLO.LatencyBuckets {
+        if bucket <= 0 || (i > 0 && bucket <= c.SLO.LatencyBuckets[i-1]) {
+            v.add("SLO_LATENCY_BUCKETS", "must be positive and increasing, got %v", c.SLO.LatencyBuckets)
+            break
+        }
+    }
+    validateDuration(v, "SLO_LATENCY_TARGET", c.SLO.LatencyTarget)
+    if c.SLO.LatencyObjective < 0 || c.SLO.LatencyObjective >= 1 {
+        v.add("SLO_LATENCY_OBJECTIVE", "must be a share below 1, such as 0.99, got %v", c.SLO.LatencyObjective)
+    }
+    if c.SLO.AvailabilityObjective < 0 || c.SLO.AvailabilityObjective >= 1 {
+        v.add("SLO_AVAILABILITY_OBJECTIVE", "must be a share below 1, such as 0.999, got %v", c.SLO.AvailabilityObjective)
+    }
+
+    if c.Maintenance.Mode != "buffer" && c.Maintenance.Mode != "reject" {
+        v.add("MAINTENANCE_MODE", "must be buffer or reject, got %q", c.Maintenance.Mode)
+    }
+    if c.Maintenance.BufferFile == "" {
+        v.add("MAINTENANCE_BUFFER_FILE", "is required")
+    }
+    if c.Maintenance.BufferMaxBytes < 0 {
+        v.add("MAINTENANCE_BUFFER_MAX", "must not be negative, got %d", c.Maintenance.BufferMaxBytes)
+    }
+    validateDuration(v, "MAINTENANCE_RETRY_AFTER", c.Maintenance.RetryAfter)
+
+    if c.WriteQueue.Enabled && c.WriteQueue.Size <= 0 {
+        v.add("WRITE_QUEUE_SIZE", "must be positive, got %d", c.WriteQueue.Size)
+    }
+    if c.WriteQueue.Enabled && c.WriteQueue.Workers <= 0 {
+        v.add("WRITE_QUEUE_WORKERS", "must be positive, got %d", c.WriteQueue.Workers)
+    }
+    if c.WriteQueue.Enabled && (c.WriteQueue.HighWater <= 0 || c.WriteQueue.HighWater > 1) {
+        v.add("WRITE_QUEUE_HIGH_WATER", "must be a share of the queue up to 1, such as 0.9, got %v", c.WriteQueue.HighWater)
+    }
+    validateDuration(v, "WRITE_QUEUE_RETRY_AFTER", c.WriteQueue.RetryAfter)
+
+    switch c.QueryCache.Backend {
+    case "":
+    case "memory", "redis":
+        if c.QueryCache.TTL <= 0 {
+            v.add("QUERY_CACHE_TTL", "must be positive, got %v", c.QueryCache.TTL)
+        }
+        if c.QueryCache.MaxEntryBytes <= 0 {
+            v.add("QUERY_CACHE_MAX_ENTRY_BYTES", "must be positive, got %d", c.QueryCache.MaxEntryBytes)
+        }
+    default:
+        v.add("QUERY_CACHE_BACKEND", "must be memory, redis or empty, got %q", c.QueryCache.Backend)
+    }
+    if c.QueryCache.Backend == "memory" && c.QueryCache.MaxEntries <= 0 {
+        v.add("QUERY_CACHE_MAX_ENTRIES", "must be positive, got %d", c.QueryCache.MaxEntries)
+    }
+    if c.QueryCache.Backend == "memory" && c.QueryCache.MaxBytes <= 0 {
+        v.add("QUERY_CACHE_MAX_BYTES", "must be positive, got %d", c.QueryCache.MaxBytes)
+    }
+    if c.QueryCache.Backend == "redis" && c.QueryCache.RedisAddr == "" {
+        v.add("QUERY_CACHE_REDIS_ADDR", "is required with the redis backend")
+    }
+
+    for name := range c.Features {
+        if !features.Known(name) {
+            v.add("FEATURES", "unknown feature %q, expected one of %s or %s<stage>", name, strings.Join(features.Names(), ", "), features.StagePrefix)
+        }
+    }
+
+    // A setting that could not be read is reported as such, not also for
+    // the default or reference left in its place
+    problems := append([]FieldError(nil), readErrs...)
+    for _, fieldErr := range v.Errors {
+        if !hasField(readErrs, fieldErr.Field) {
+            problems = append(problems, fieldErr)
+        }
+    }
+    if len(problems) == 0 {
+        return nil
+    }
+    return &ValidationError{Errors: problems}
+}
+
+// hasField reports whether errs holds a problem with field
+func hasField(errs []FieldError, field string) bool {
+    for _, fieldErr := range errs {
+        if fieldErr.Field == field {
+            return true
+        }
+    }
+    return false
+}
+
+// validatePort checks that a port is a valid TCP port
+func validatePort(v *ValidationError, field string, port int) {
+    if port < 1 || port > 65535 {
+        v.add(field, "must be between 1 and 65535, got %d", port)
+    }
+}
+
+// validateDuration checks that a duration is not negative
+func validateDuration(v *ValidationError, field string, d time.Duration) {
+    if d < 0 {
+        v.add(field, "must not be negative, got %v", d)
+    }
+}
+
+// validateHTTPURL checks that a value is an absolute http or https URL
+func validateHTTPURL(v *ValidationError, field, value string) {
+    u, err := url.Parse(value)
+    if err != nil {
+        v.add(field, "is not a valid URL: %v", err)
+        return
+    }
+    if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+        v.add(field, "must be an absolute http or https URL, got %q", value)
+    }
+}
+
+// validateDatabaseURL checks a PostgreSQL URL; key=value connection
+// strings, which lib/pq also takes, are left to it
+func validateDatabaseURL(v *ValidationError, field, value string) {
+    if !strings.Contains(value, "://") {
+        return
+    }
+    u, err := url.Parse(value)
+    switch {
+    case err != nil:
+        v.add(field, "is not a valid URL: %v", err)
+    case u.Scheme != "postgres" && u.Scheme != "postgresql":
+        v.add(field, "must use the postgres:// or postgresql:// scheme, got %q", u.Scheme+"://")
+    }
+}
+
+// containsFold reports whether values holds value, ignoring case
+func containsFold(values []string, value string) bool {
+    for _, v := range values {
+        if strings.EqualFold(v, value) {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+    "errors"
+    "os"
+    "sort"
+    "strings"
+    "testing"
+    "time"
+)
+
+func validConfig() *Config {
+    return &Config{
+        Server:      ServerConfig{Port: 8080, TrustedProxies: []string{"10.0.0.0/8", "127.0.0.1"}},
+        Database:    DatabaseConfig{Port: 5432, User: "logs", Password: "secret"},
+        Log:         LogConfig{Level: "info", Format: "json", SelfLogs: "store"},
+        Anomaly:     AnomalyConfig{Alpha: 0.1},
+        Patterns:    PatternsConfig{Similarity: 0.4},
+        DeadLetter:  DeadLetterConfig{Backend: "table"},
+        Processor:   ProcessorConfig{Port: 8081},
+        Quota:       QuotaConfig{SoftLimit: 0.8},
+        Health:      HealthConfig{ReadinessInterval: 2 * time.Second},
+        Stats:       StatsConfig{RefreshInterval: 30 * time.Second},
+        Maintenance: MaintenanceConfig{Mode: "buffer", BufferFile: "maintenance-buffer.jsonl"},
+    }
+}
+
+// fields returns the settings a validation error names, sorted
+func fields(t *testing.T, err error) []string {
+    t.Helper()
+    var validationErr *ValidationError
+    if !errors.As(err, &validationErr) {
+        t.Fatalf("Expected a *ValidationError, got %v", err)
+    }
+    var names []string
+    for _, fieldErr := range validationErr.Errors {
+        names = append(names, fieldErr.Field)
+    }
+    sort.Strings(names)
+    return names
+}
+
+func TestValidate_Valid(t *testing.T) {
+    if err := validConfig().Validate(); err != nil {
+        t.Errorf("Expected the config to be valid, got %v", err)
+    }
+
+    cfg := validConfig()
+    cfg.Database = DatabaseConfig{Port: 5432, URL: "host=db.internal user=logs dbname=logs"}
+    cfg.Database.Shards = []string{"postgres://logs@shard-0.internal/logs", "host=shard-1.internal user=logs"}
+    cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile, cfg.Server.TLSClientCAFile = "cert.pem", "key.pem", "ca.pem"
+    cfg.Auth.ClientCNs = map[string][]string{"log-agent": {"ingest"}}
+    cfg.Auth.JWKSURL = "https://idp.example.com/jwks"
+    if err := cfg.Validate(); err != nil {
+        t.Errorf("Expected the config to be valid, got %v", err)
+    }
+}
+
+func TestValidate_AllProblems(t *testing.T) {
+    cfg := validConfig()
+    cfg.Server.Port = 70000
+    cfg.Server.RateLimit = -1
+    cfg.Server.MaxHeaderBytes = -1
+    cfg.Database = DatabaseConfig{Port: 5432, URL: "mysql://logs@db/logs", Shards: []string{"host=shard-0", "host=shard-0"}}
+    cfg.Server.TLSCertFile = "cert.pem"
+    cfg.Server.TrustedProxies = []string{"10.0.0.0/33"}
+    cfg.Log.Level = "verbose"
+    cfg.DeadLetter.Backend = "s3"
+    cfg.Archive = ArchiveConfig{Bucket: "logs", Dir: "/mnt/archive"}
+    cfg.Auth.JWKSURL = "idp.example.com/jwks"
+    cfg.Auth.ClientCNs = map[string][]string{"log-agent": {"ingest"}}
+    cfg.Auth.AdminUsername = "admin"
+    cfg.Quota.SoftLimit = 1.5
+    cfg.Profiling.Addr = "localhost"
+    cfg.Features = map[string]bool{"live_tail": false, "time_travel": true}
+    cfg.SLO.LatencyBuckets = []time.Duration{time.Second, 500 * time.Millisecond}
+    cfg.SLO.AvailabilityObjective = 99.9
+    cfg.WriteQueue = WriteQueueConfig{Enabled: true, Size: 0, Workers: 4, HighWater: 0.9}
+    cfg.QueryCache = QueryCacheConfig{Backend: "redis", TTL: 30 * time.Second, MaxEntryBytes: 1 << 20}
+
+    err := cfg.Validate()
+    want := []string{
+        "ARCHIVE_DIR", "AUTH_ADMIN_USERNAME", "AUTH_CLIENT_CNS", "AUTH_JWT_JWKS_URL", "DATABASE_SHARDS", "DATABASE_URL",
+        "DLQ_BACKEND", "FEATURES", "LOG_LEVEL", "PPROF_ADDR", "QUERY_CACHE_REDIS_ADDR", "QUOTA_SOFT_LIMIT", "RATE_LIMIT",
+        "SERVER_MAX_HEADER_BYTES", "SERVER_PORT", "SLO_AVAILABILITY_OBJECTIVE", "SLO_LATENCY_BUCKETS", "TLS_CERT_FILE",
+        "TRUSTED_PROXIES", "WRITE_QUEUE_SIZE",
+    }
+    if got := fields(t, err); strings.Join(got, ",") != strings.Join(want, ",") {
+        t.Errorf("Expected problems with %v, got %v", want, got)
+    }
+    if !strings.Contains(err.Error(), "SERVER_PORT: must be between 1 and 65535, got 70000") {
+        t.Errorf("Expected the message to name the setting and value, got %q", err)
+    }
+}
+
+func TestValidate_TickerIntervals(t *testing.T) {
+    cfg := validConfig()
+    cfg.Rollup = RollupConfig{Enabled: true}
+    cfg.Stats.RefreshInterval = 0
+    cfg.Pipeline = PipelineConfig{ConfigPath: "pipeline.yaml", FlushInterval: -time.Second}
+    cfg.Anomaly = AnomalyConfig{Enabled: true, Alpha: 0.1}
+
+    err := cfg.Validate()
+    want := []string{"ANOMALY_WINDOW", "PIPELINE_FLUSH_INTERVAL", "ROLLUP_INTERVAL", "STATS_REFRESH_INTERVAL"}
+    if got := fields(t, err); strings.Join(got, ",") != strings.Join(want, ",") {
+        t.Errorf("Expected problems with %v, got %v", want, got)
+    }
+    if !strings.Contains(err.Error(), "STATS_REFRESH_INTERVAL: must be positive, got 0s") {
+        t.Errorf("Expected the zero interval to be reported, got %q", err)
+    }
+
+    // Intervals of disabled features are never used
+    cfg = validConfig()
+    cfg.Rollup.Interval = 0
+    cfg.Anomaly.Window = 0
+    if err := cfg.Validate(); err != nil {
+        t.Errorf("Expected the config to be valid, got %v", err)
+    }
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+    t.Setenv(configFileEnv, "")
+    t.Setenv("SERVER_PORT", "eighty")
+    t.Setenv("ANOMALY_ALPHA", "0")
+    t.Setenv("FEATURES", "live_tail=false,pipeline=maybe")
+    t.Setenv("SLO_LATENCY_BUCKETS", "100ms,fast")
+    t.Setenv("QUOTA_TENANT_RATES", "acme=600,beta")
+    for _, key := range []string{"DB_USER", "DB_PASSWORD", "DATABASE_URL"} {
+        // Restored after the test
+        t.Setenv(key, "")
+        os.Unsetenv(key)
+    }
+
+    cfg, err := LoadConfig()
+    if cfg != nil {
+        t.Fatalf("Expected no config, got %+v", cfg)
+    }
+    want := []string{"ANOMALY_ALPHA", "DB_PASSWORD", "DB_USER", "FEATURES", "QUOTA_TENANT_RATES", "SERVER_PORT", "SLO_LATENCY_BUCKETS"}
+    if got := fields(t, err); strings.Join(got, ",") != strings.Join(want, ",") {
+        t.Errorf("Expected problems with %v, got %v", want, got)
+    }
+    if !strings.Contains(err.Error(), `SERVER_PORT: expected an integer, got "eighty"`) {
+        t.Errorf("Expected the malformed value to be reported, got %q", err)
+    }
+    if !strings.Contains(err.Error(), `QUOTA_TENANT_RATES: expected name=integer, got "beta"`) {
+        t.Errorf("Expected the malformed pair to be reported, got %q", err)
+    }
+}
@@ -0,0 +1,215 @@
+// Package crash writes a report file for each recovered panic and fatal
+// exit, for postmortems: the panic value or fatal message, the stack of the
+// goroutine that crashed, the request it was serving and the last entries
+// the process logged. Each crash is also counted in the crashes_total
+// metric, whether or not reports are written.
+package crash
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"log-processing-system/services/log-ingestion/logger"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Kinds of crash
+const (
+	KindPanic = "panic"
+	KindFatal = "fatal"
+)
+
+// DefaultMaxReports is the number of reports kept when Config sets none
+const DefaultMaxReports = 50
+
+var crashes = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "crashes_total",
+	Help: "Recovered panics and fatal exits, by kind",
+}, []string{"kind"})
+
+func init() {
+	prometheus.MustRegister(crashes)
+}
+
+// Config configures crash reports. An empty Dir writes none.
+type Config struct {
+	Dir     string
+	Service string
+	// MaxReports is the number of reports kept; older ones are removed so
+	// a crash loop cannot fill the disk
+	MaxReports int
+	// RecentLogs is the number of entries logged before the crash that a
+	// report includes; 0 includes none
+	RecentLogs int
+}
+
+// Request describes the HTTP request a crashed handler was serving
+type Request struct {
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	Route      string `json:"route,omitempty"`
+	RemoteAddr string `json:"remote_addr,omitempty"`
+	UserAgent  string `json:"user_agent,omitempty"`
+	RequestID  string `json:"request_id,omitempty"`
+	TraceID    string `json:"trace_id,omitempty"`
+	UserID     string `json:"user_id,omitempty"`
+}
+
+// Process identifies the process that crashed
+type Process struct {
+	Hostname   string `json:"hostname"`
+	PID        int    `json:"pid"`
+	GoVersion  string `json:"go_version"`
+	Goroutines int    `json:"goroutines"`
+	Uptime     string `json:"uptime"`
+}
+
+// Report is the content of a crash report file
+type Report struct {
+	Kind       string                 `json:"kind"`
+	Service    string                 `json:"service"`
+	Time       time.Time              `json:"time"`
+	Message    string                 `json:"message"`
+	Fields     map[string]interface{} `json:"fields,omitempty"`
+	Stack      []string               `json:"stack"`
+	Request    *Request               `json:"request,omitempty"`
+	Process    Process                `json:"process"`
+	RecentLogs []logger.LogEntry      `json:"recent_logs"`
+}
+
+// Reporter writes crash reports. A nil Reporter only counts crashes.
+type Reporter struct {
+	cfg     Config
+	started time.Time
+	seq     uint64
+
+	// mu serializes writing and pruning reports
+	mu sync.Mutex
+}
+
+// New creates a reporter writing to cfg.Dir, or nil without a directory.
+// It makes the loggers keep the entries reports include.
+func New(cfg Config) (*Reporter, error) {
+	if cfg.Dir == "" {
+		return nil, nil
+	}
+	if cfg.MaxReports <= 0 {
+		cfg.MaxReports = DefaultMaxReports
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("crash report directory: %w", err)
+	}
+	logger.KeepRecent(cfg.RecentLogs)
+	return &Reporter{cfg: cfg, started: time.Now()}, nil
+}
+
+// Panic records a panic recovered while serving req, nil outside a request,
+// with the stack of the goroutine that panicked. It returns the report's
+// path, empty when no report is written.
+func (r *Reporter) Panic(req *Request, value interface{}, stack []byte) (string, error) {
+	crashes.WithLabelValues(KindPanic).Inc()
+	if r == nil {
+		return "", nil
+	}
+	return r.write(Report{
+		Kind:    KindPanic,
+		Message: fmt.Sprint(value),
+		Stack:   stackLines(stack),
+		Request: req,
+	})
+}
+
+// Fatal records a fatal exit; it has the signature logger.OnFatal expects.
+// Failures go to stderr, as the process is exiting.
+func (r *Reporter) Fatal(entry logger.LogEntry, stack []byte) {
+	crashes.WithLabelValues(KindFatal).Inc()
+	if r == nil {
+		return
+	}
+	report := Report{
+		Kind:    KindFatal,
+		Message: entry.Message,
+		Fields:  entry.Fields,
+		Stack:   stackLines(stack),
+	}
+	if entry.Error != "" {
+		report.Message += ": " + entry.Error
+	}
+	if _, err := r.write(report); err != nil {
+		fmt.Fprintf(os.Stderr, "crash: failed to write crash report: %v\n", err)
+	}
+}
+
+// write completes a report and writes it to a new file, removing the oldest
+// reports beyond MaxReports
+func (r *Reporter) write(report Report) (string, error) {
+	report.Service = r.cfg.Service
+	report.Time = time.Now().UTC()
+	report.Process = r.process()
+	report.RecentLogs = logger.Recent()
+	if report.RecentLogs == nil {
+		report.RecentLogs = []logger.LogEntry{}
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Names sort in the order the reports were written
+	name := fmt.Sprintf("crash-%s-%d-%04d.json", report.Time.Format("20060102T150405.000000000Z"), os.Getpid(), atomic.AddUint64(&r.seq, 1))
+	path := filepath.Join(r.cfg.Dir, name)
+	// Written under a temporary name so a partly written report is never
+	// taken for a complete one
+	tmp := filepath.Join(r.cfg.Dir, "."+name+".tmp")
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+
+	r.prune()
+	return path, nil
+}
+
+// prune removes the oldest reports beyond MaxReports
+func (r *Reporter) prune() {
+	reports, err := filepath.Glob(filepath.Join(r.cfg.Dir, "crash-*.json"))
+	if err != nil || len(reports) <= r.cfg.MaxReports {
+		return
+	}
+	sort.Strings(reports)
+	for _, path := range reports[:len(reports)-r.cfg.MaxReports] {
+		os.Remove(path)
+	}
+}
+
+func (r *Reporter) process() Process {
+	hostname, _ := os.Hostname()
+	return Process{
+		Hostname:   hostname,
+		PID:        os.Getpid(),
+		GoVersion:  runtime.Version(),
+		Goroutines: runtime.NumGoroutine(),
+		Uptime:     time.Since(r.started).Round(time.Second).String(),
+	}
+}
+
+// stackLines splits a stack as debug.Stack formats it into lines, so the
+// report reads well as JSON
+func stackLines(stack []byte) []string {
+	return strings.Split(strings.TrimRight(string(stack), "\n"), "\n")
+}
@@ -0,0 +1,119 @@
+package crash
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"testing"
+	"log-processing-system/services/log-ingestion/logger"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newTestReporter(t *testing.T, cfg Config) *Reporter {
+	cfg.Dir = filepath.Join(t.TempDir(), "crashes")
+	cfg.Service = "test-service"
+	r, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create reporter: %v", err)
+	}
+	t.Cleanup(func() { logger.KeepRecent(0) })
+	return r
+}
+
+func readReport(t *testing.T, path string) Report {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read report: %v", err)
+	}
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("Invalid report %s: %v", data, err)
+	}
+	return report
+}
+
+func TestReporter_Panic(t *testing.T) {
+	r := newTestReporter(t, Config{RecentLogs: 2})
+	log := logger.New(logger.Config{Service: "test-service", Component: "test", Level: "DEBUG"})
+	log.Debug("first")
+	log.Info("second")
+	log.Warn("third")
+	before := testutil.ToFloat64(crashes.WithLabelValues(KindPanic))
+
+	path, err := r.Panic(&Request{Method: "POST", Path: "/ingest", RequestID: "req-1"}, "boom", debug.Stack())
+	if err != nil {
+		t.Fatalf("Failed to write report: %v", err)
+	}
+
+	report := readReport(t, path)
+	if report.Kind != KindPanic || report.Service != "test-service" || report.Message != "boom" || report.Request.RequestID != "req-1" {
+		t.Errorf("Expected the panic and its request, got %+v", report)
+	}
+	if !strings.Contains(strings.Join(report.Stack, "\n"), "TestReporter_Panic") {
+		t.Errorf("Expected the stack given, got %v", report.Stack)
+	}
+	if len(report.RecentLogs) != 2 || report.RecentLogs[0].Message != "second" || report.RecentLogs[1].Message != "third" {
+		t.Errorf("Expected the last 2 entries logged, oldest first, got %+v", report.RecentLogs)
+	}
+	if report.Process.PID != os.Getpid() {
+		t.Errorf("Expected the process identified, got %+v", report.Process)
+	}
+	if n := testutil.ToFloat64(crashes.WithLabelValues(KindPanic)) - before; n != 1 {
+		t.Errorf("Expected the panic counted, got %v", n)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected the report readable only by its owner, got %v", info.Mode())
+	}
+}
+
+func TestReporter_Fatal(t *testing.T) {
+	r := newTestReporter(t, Config{})
+	r.Fatal(logger.LogEntry{Message: "Failed to connect to database", Error: "connection refused", Fields: map[string]interface{}{"host": "db"}}, debug.Stack())
+
+	reports, _ := filepath.Glob(filepath.Join(r.cfg.Dir, "crash-*.json"))
+	if len(reports) != 1 {
+		t.Fatalf("Expected one report, got %v", reports)
+	}
+	report := readReport(t, reports[0])
+	if report.Kind != KindFatal || report.Message != "Failed to connect to database: connection refused" || report.Fields["host"] != "db" || report.Request != nil {
+		t.Errorf("Expected the fatal entry without a request, got %+v", report)
+	}
+}
+
+func TestReporter_KeepsNewest(t *testing.T) {
+	r := newTestReporter(t, Config{MaxReports: 2})
+	var paths []string
+	for i := 0; i < 4; i++ {
+		path, err := r.Panic(nil, i, nil)
+		if err != nil {
+			t.Fatalf("Failed to write report: %v", err)
+		}
+		paths = append(paths, path)
+	}
+
+	reports, _ := filepath.Glob(filepath.Join(r.cfg.Dir, "crash-*.json"))
+	if len(reports) != 2 || reports[0] != paths[2] || reports[1] != paths[3] {
+		t.Errorf("Expected the 2 newest reports kept, got %v of %v", reports, paths)
+	}
+}
+
+func TestReporter_Nil(t *testing.T) {
+	r, err := New(Config{})
+	if r != nil || err != nil {
+		t.Fatalf("Expected no reporter without a directory, got %v, %v", r, err)
+	}
+	before := testutil.ToFloat64(crashes.WithLabelValues(KindFatal))
+
+	path, err := r.Panic(nil, "boom", nil)
+	r.Fatal(logger.LogEntry{Message: "exiting"}, nil)
+
+	if path != "" || err != nil {
+		t.Errorf("Expected no report, got %q, %v", path, err)
+	}
+	if n := testutil.ToFloat64(crashes.WithLabelValues(KindFatal)) - before; n != 1 {
+		t.Errorf("Expected the fatal exit counted, got %v", n)
+	}
+}
@@ -0,0 +1,196 @@
+package database
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+    "log-processing-system/services/log-ingestion/models"
+)
+
+// statsSettleDelay keeps the refresh behind in-flight inserts, whose serial
+// ids may commit out of order. The watermark only moves forward, so an entry
+// whose transaction commits more than statsSettleDelay after it began
+// (created_at is the transaction's start) while entries with higher ids are
+// already counted is never counted: it is stored and queryable, but missing
+// from the stats. Tracking committed ids exactly would take a lock every
+// insert must share, which the ingest path cannot afford.
+const statsSettleDelay = 5 * time.Second
+
+// RefreshLogStats folds log entries stored since the last refresh into
+// log_stats_minute and advances the watermark, in every shard. It returns
+// the number of (bucket, source, level) rows touched.
+func RefreshLogStats() (int64, error) {
+    var total int64
+    for _, shard := range logShards() {
+        rowsAffected, err := refreshShardStats(shard)
+        if err != nil {
+            return total, err
+        }
+        total += rowsAffected
+    }
+    return total, nil
+}
+
+// refreshShardStats refreshes the stats of one shard
+func refreshShardStats(shard logShard) (int64, error) {
+    start := time.Now()
+
+    tx, err := shard.db.Begin()
+    if err != nil {
+        dbLogger.WithError(err).Error("Failed to begin stats refresh transaction")
+        return 0, err
+    }
+    defer tx.Rollback()
+
+    var lastID int64
+    if err := tx.QueryRow(`SELECT last_log_id FROM log_stats_watermark FOR UPDATE`).Scan(&lastID); err != nil {
+        dbLogger.WithError(err).Error("Failed to read stats watermark")
+        return 0, err
+    }
+
+    var maxID int64
+    err = tx.QueryRow(
+        `SELECT COALESCE(MAX(id), $1) FROM logs WHERE id > $1 AND created_at < NOW() - $2::interval`,
+        lastID, fmt.Sprintf("%d seconds", int(statsSettleDelay.Seconds())),
+    ).Scan(&maxID)
+    if err != nil {
+        dbLogger.WithError(err).Error("Failed to determine stats refresh range")
+        return 0, err
+    }
+
+    if maxID == lastID {
+        return 0, nil
+    }
+
+    upsert := `
+        INSERT INTO log_stats_minute (bucket, source, level, count)
+        SELECT date_trunc('minute', timestamp), COALESCE(source, 'unknown'), lower(level), COUNT(*)
+        FROM logs
+        WHERE id > $1 AND id <= $2
+        GROUP BY 1, 2, 3
+        ON CONFLICT (bucket, source, level) DO UPDATE SET
+            count = log_stats_minute.count + EXCLUDED.count`
+
+    result, err := tx.Exec(upsert, lastID, maxID)
+    if err != nil {
+        dbLogger.WithFields(map[string]interface{}{
+            "operation": "INSERT",
+            "table":     "log_stats_minute",
+            "shard":     shard.index,
+            "from_id":   lastID,
+            "to_id":     maxID,
+            "error":     err.Error(),
+        }).Error("Failed to refresh log stats")
+        return 0, err
+    }
+    rowsAffected, _ := result.RowsAffected()
+
+    if _, err := tx.Exec(`UPDATE log_stats_watermark SET last_log_id = $1, refreshed_at = NOW()`, maxID); err != nil {
+        dbLogger.WithError(err).Error("Failed to advance stats watermark")
+        return 0, err
+    }
+
+    if err := tx.Commit(); err != nil {
+        dbLogger.WithError(err).Error("Failed to commit stats refresh transaction")
+        return 0, err
+    }
+
+    dbLogger.LogDatabaseOperation("REFRESH_STATS", "log_stats_minute", time.Since(start), rowsAffected)
+
+    return rowsAffected, nil
+}
+
+// GetLogAggregates returns log counts grouped by time bucket, source and level
+// from the pre-aggregated stats table. Sharded, the shards' counts are
+// merged; a source is counted in one shard, except entries without one,
+// which are counted as "unknown" alongside a source of that name.
+func GetLogAggregates(filter models.AggregateFilter) ([]models.LogAggregate, error) {
+    start := time.Now()
+
+    if !models.IsValidAggregateInterval(filter.Interval) {
+        return nil, fmt.Errorf("invalid aggregate interval: %s", filter.Interval)
+    }
+
+    var mu sync.Mutex
+    var aggregates []models.LogAggregate
+    err := fanOut(queriedShards(filter.Source), func(shard logShard) error {
+        shardAggregates, err := getShardAggregates(shard, filter)
+        mu.Lock()
+        aggregates = append(aggregates, shardAggregates...)
+        mu.Unlock()
+        return err
+    })
+    if err != nil {
+        dbLogger.WithFields(map[string]interface{}{
+            "operation":   "SELECT",
+            "table":       "log_stats_minute",
+            "interval":    filter.Interval,
+            "duration_ms": time.Since(start).Milliseconds(),
+            "error":       err.Error(),
+        }).Error("Failed to retrieve log aggregates")
+        return nil, err
+    }
+    sort.Slice(aggregates, func(i, j int) bool {
+        a, b := aggregates[i], aggregates[j]
+        if !a.Bucket.Equal(b.Bucket) {
+            return a.Bucket.Before(b.Bucket)
+        }
+        if a.Source != b.Source {
+            return a.Source < b.Source
+        }
+        return a.Level < b.Level
+    })
+    merged := aggregates[:0]
+    for _, aggregate := range aggregates {
+        if last := len(merged) - 1; last >= 0 && merged[last].Bucket.Equal(aggregate.Bucket) &&
+            merged[last].Source == aggregate.Source && merged[last].Level == aggregate.Level {
+            merged[last].Count += aggregate.Count
+            continue
+        }
+        merged = append(merged, aggregate)
+    }
+    aggregates = merged
+
+    dbLogger.LogDatabaseOperation("SELECT_AGGREGATE", "log_stats_minute", time.Since(start), int64(len(aggregates)))
+
+    return aggregates, nil
+}
+
+// getShardAggregates runs GetLogAggregates against one shard
+func getShardAggregates(shard logShard, filter models.AggregateFilter) ([]models.LogAggregate, error) {
+    conditions := []string{"bucket >= $2", "bucket < $3"}
+    args := []interface{}{filter.Interval, filter.From, filter.To}
+    if filter.Source != "" {
+        args = append(args, filter.Source)
+        conditions = append(conditions, fmt.Sprintf("source = $%d", len(args)))
+    }
+    if filter.Level != "" {
+        args = append(args, strings.ToLower(filter.Level))
+        conditions = append(conditions, fmt.Sprintf("level = $%d", len(args)))
+    }
+
+    query := `SELECT date_trunc($1, bucket) AS b, source, level, SUM(count)
+        FROM log_stats_minute
+        WHERE ` + strings.Join(conditions, " AND ") + `
+        GROUP BY b, source, level
+        ORDER BY b, source, level`
+
+    rows, err := shard.db.Query(query, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var aggregates []models.LogAggregate
+    for rows.Next() {
+        var aggregate models.LogAggregate
+        if err := rows.Scan(&aggregate.Bucket, &aggregate.Source, &aggregate.Level, &aggregate.Count); err != nil {
+            dbLogger.WithError(err).Error("Failed to scan log aggregate")
+            return nil, err
+        }
+        aggregates = append(aggregates, aggregate)
+    }
+    return aggregates, rows.Err()
+}
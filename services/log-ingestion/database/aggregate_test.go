@@ -0,0 +1,63 @@
+package database
+
+import (
+    "fmt"
+    "testing"
+    "time"
+    "log-processing-system/services/log-ingestion/models"
+)
+
+func TestRefreshLogStats_CountsOnlySettledEntries(t *testing.T) {
+    connectTestDB(t)
+    source := fmt.Sprintf("stats-test-%d", time.Now().UnixNano())
+    t.Cleanup(func() {
+        db.Exec(`DELETE FROM logs WHERE source = $1`, source)
+        db.Exec(`DELETE FROM log_stats_minute WHERE source = $1`, source)
+    })
+    now := time.Now()
+
+    store := func(count int) {
+        for i := 0; i < count; i++ {
+            if err := StoreLog(models.Log{Level: "info", Message: "counted", Timestamp: now, Source: source}); err != nil {
+                t.Fatalf("Failed to store entry: %v", err)
+            }
+        }
+    }
+    // As if stored longer ago than the settle delay
+    settle := func() {
+        if _, err := db.Exec(`UPDATE logs SET created_at = NOW() - interval '1 minute' WHERE source = $1`, source); err != nil {
+            t.Fatalf("Failed to age entries: %v", err)
+        }
+    }
+    counted := func() int64 {
+        if _, err := RefreshLogStats(); err != nil {
+            t.Fatalf("Refresh failed: %v", err)
+        }
+        var count int64
+        if err := db.QueryRow(`SELECT COALESCE(SUM(count), 0) FROM log_stats_minute WHERE source = $1`, source).Scan(&count); err != nil {
+            t.Fatalf("Failed to read stats: %v", err)
+        }
+        return count
+    }
+
+    store(2)
+    if got := counted(); got != 0 {
+        t.Errorf("Expected entries within the settle delay left for later, got %d counted", got)
+    }
+    settle()
+    if got := counted(); got != 2 {
+        t.Errorf("Expected the settled entries counted, got %d", got)
+    }
+
+    store(1)
+    if got := counted(); got != 2 {
+        t.Errorf("Expected the new entry left for later, got %d counted", got)
+    }
+    settle()
+    if got := counted(); got != 3 {
+        t.Errorf("Expected each entry counted once, got %d", got)
+    }
+    if got := counted(); got != 3 {
+        t.Errorf("Expected a refresh without new entries to change nothing, got %d", got)
+    }
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+    "database/sql"
+    "time"
+    "log-processing-system/services/log-ingestion/models"
+
+    "github.com/lib/pq"
+)
+
+// InsertAPIKey stores a new API key and returns its id
+func InsertAPIKey(key models.APIKey) (int64, error) {
+    start := time.Now()
+
+    var id int64
+    query := `INSERT INTO api_keys (name, prefix, key_hash, scopes, sources, rate_limit, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+    err := db.QueryRow(query, key.Name, key.Prefix, key.KeyHash, pq.Array(key.Scopes), pq.Array(key.Sources), key.RateLimit, key.CreatedAt).Scan(&id)
+    if err != nil {
+        dbLogger.WithFields(map[string]interface{}{
+            "operation": "INSERT",
+            "table":     "api_keys",
+            "name":      key.Name,
+            "error":     err.Error(),
+        }).Error("Failed to store API key")
+        return 0, err
+    }
+
+    dbLogger.LogDatabaseOperation("INSERT", "api_keys", time.Since(start), 1)
+    return id, nil
+}
+
+// ListAPIKeys returns every API key, revoked ones included, oldest first
+func ListAPIKeys() ([]models.APIKey, error) {
+    start := time.Now()
+
+    rows, err := db.Query(`SELECT id, name, prefix, key_hash, scopes, sources, rate_limit, created_at, revoked_at
+        FROM api_keys ORDER BY id`)
+    if err != nil {
+        dbLogger.WithFields(map[string]interface{}{
+            "operation": "SELECT",
+            "table":     "api_keys",
+            "error":     err.Error(),
+        }).Error("Failed to list API keys")
+        return nil, err
+    }
+    defer rows.Close()
+
+    var keys []models.APIKey
+    for rows.Next() {
+        key, err := scanAPIKey(rows)
+        if err != nil {
+            dbLogger.WithError(err).Error("Failed to scan API key")
+            return nil, err
+        }
+        keys = append(keys, key)
+    }
+    if err := rows.Err(); err != nil {
+        return nil, err
+    }
+
+    dbLogger.LogDatabaseOperation("SELECT", "api_keys", time.Since(start), int64(len(keys)))
+    return keys, nil
+}
+
+// GetAPIKeyByHash returns the API key with the given hash; found is false
+// if there is none
+func GetAPIKeyByHash(hash string) (key models.APIKey, found bool, err error) {
+    row := db.QueryRow(`SELECT id, name, prefix, key_hash, scopes, sources, rate_limit, created_at, revoked_at
+        FROM api_keys WHERE key_hash = $1`, hash)
+
+    key, err = scanAPIKey(row)
+    if err == sql.ErrNoRows {
+        return key, false, nil
+    }
+    if err != nil {
+        dbLogger.WithFields(map[string]interface{}{
+            "operation": "SELECT",
+            "table":     "api_keys",
+            "error":     err.Error(),
+        }).Error("Failed to read API key")
+        return key, false, err
+    }
+    return key, true, nil
+}
+
+// RevokeAPIKey marks an API key as revoked; found is false if it does not
+// exist or was already revoked
+func RevokeAPIKey(id int64, revokedAt time.Time) (bool, error) {
+    result, err := db.Exec(`UPDATE api_keys SET revoked_at = $2 WHERE id = $1 AND revoked_at IS NULL`, id, revokedAt)
+    if err != nil {
+        dbLogger.WithFields(map[string]interface{}{
+            "operation": "UPDATE",
+            "table":     "api_keys",
+            "id":        id,
+            "error":     err.Error(),
+        }).Error("Failed to revoke API key")
+        return false, err
+    }
+    rowsAffected, _ := result.RowsAffected()
+    return rowsAffected > 0, nil
+}
+
+func scanAPIKey(row rowScanner) (models.APIKey, error) {
+    var (
+        key       models.APIKey
+        revokedAt sql.NullTime
+    )
+    err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.KeyHash, pq.Array(&key.Scopes), pq.Array(&key.Sources), &key.RateLimit, &key.CreatedAt, &revokedAt)
+    if revokedAt.Valid {
+        key.RevokedAt = &revokedAt.Time
+    }
+    return key, err
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+    "fmt"
+    "strings"
+    "time"
+    "log-processing-system/services/log-ingestion/models"
+)
+
+// InsertAuditEvent stores an audit event and returns its id
+func InsertAuditEvent(event models.AuditEvent) (int64, error) {
+    start := time.Now()
+
+    var id int64
+    query := `INSERT INTO audit_log (occurred_at, actor_type, actor, action, resource, method, path, status, request_id, client_ip, details)
+        VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, 0), NULLIF($9, ''), NULLIF($10, ''), $11) RETURNING id`
+    err := db.QueryRow(query, event.OccurredAt, event.ActorType, event.Actor, event.Action, event.Resource,
+        event.Method, event.Path, event.Status, event.RequestID, event.ClientIP, event.Details).Scan(&id)
+    if err != nil {
+        dbLogger.WithFields(map[string]interface{}{
+            "operation": "INSERT",
+            "table":     "audit_log",
+            "action":    event.Action,
+            "error":     err.Error(),
+        }).Error("Failed to store audit event")
+        return 0, err
+    }
+
+    dbLogger.LogDatabaseOperation("INSERT", "audit_log", time.Since(start), 1)
+    return id, nil
+}
+
+// ListAuditEvents returns audit events matching filter, newest first
+func ListAuditEvents(filter models.AuditFilter) ([]models.AuditEvent, error) {
+    start := time.Now()
+
+    var (
+        conditions []string
+        args       []interface{}
+    )
+    if filter.Actor != "" {
+        args = append(args, filter.Actor)
+        conditions = append(conditions, fmt.Sprintf("actor = $%d", len(args)))
+    }
+    if filter.Action != "" {
+        args = append(args, filter.Action)
+        conditions = append(conditions, fmt.Sprintf("action = $%d", len(args)))
+    }
+    if !filter.Since.IsZero() {
+        args = append(args, filter.Since)
+        conditions = append(conditions, fmt.Sprintf("occurred_at >= $%d", len(args)))
+    }
+    if !filter.Until.IsZero() {
+        args = append(args, filter.Until)
+        conditions = append(conditions, fmt.Sprintf("occurred_at < $%d", len(args)))
+    }
+    where := "TRUE"
+    if len(conditions) > 0 {
+        where = strings.Join(conditions, " AND ")
+    }
+
+    query := `SELECT id, occurred_at, actor_type, actor, action, COALESCE(resource, ''), COALESCE(method, ''), COALESCE(path, ''),
+        COALESCE(status, 0), COALESCE(request_id, ''), COALESCE(client_ip, ''), details
+        FROM audit_log WHERE ` + where + ` ORDER BY occurred_at DESC, id DESC`
+    if filter.Limit > 0 {
+        args = append(args, filter.Limit)
+        query += fmt.Sprintf(" LIMIT $%d", len(args))
+    }
+    if filter.Offset > 0 {
+        args = append(args, filter.Offset)
+        query += fmt.Sprintf(" OFFSET $%d", len(args))
+    }
+
+    rows, err := db.Query(query, args...)
+    if err != nil {
+        dbLogger.WithFields(map[string]interface{}{
+            "operation": "SELECT",
+            "table":     "audit_log",
+            "filter":    filter,
+            "error":     err.Error(),
+        }).Error("Failed to list audit events")
+        return nil, err
+    }
+    defer rows.Close()
+
+    var events []models.AuditEvent
+    for rows.Next() {
+        var event models.AuditEvent
+        err := rows.Scan(&event.ID, &event.OccurredAt, &event.ActorType, &event.Actor, &event.Action, &event.Resource,
+            &event.Method, &event.Path, &event.Status, &event.RequestID, &event.ClientIP, &event.Details)
+        if err != nil {
+            dbLogger.WithError(err).Error("Failed to scan audit event")
+            return nil, err
+        }
+        events = append(events, event)
+    }
+    if err := rows.Err(); err != nil {
+        return nil, err
+    }
+
+    dbLogger.LogDatabaseOperation("SELECT", "audit_log", time.Since(start), int64(len(events)))
+    return events, nil
+}
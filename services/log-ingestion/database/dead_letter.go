@@ -0,0 +1,162 @@
+package database
+
+import (
+    "database/sql"
+    "fmt"
+    "strings"
+    "time"
+    "log-processing-system/services/log-ingestion/models"
+)
+
+// InsertDeadLetter stores a failed submission and returns its id
+func InsertDeadLetter(letter models.DeadLetter) (int64, error) {
+    start := time.Now()
+
+    var id int64
+    query := `INSERT INTO dead_letters (received_at, reason, error, source, payload, attempts) VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6) RETURNING id`
+    err := db.QueryRow(query, letter.ReceivedAt, letter.Reason, letter.Error, letter.Source, letter.Payload, letter.Attempts).Scan(&id)
+    if err != nil {
+        dbLogger.WithFields(map[string]interface{}{
+            "operation": "INSERT",
+            "table":     "dead_letters",
+            "reason":    letter.Reason,
+            "error":     err.Error(),
+        }).Error("Failed to store dead letter")
+        return 0, err
+    }
+
+    dbLogger.LogDatabaseOperation("INSERT", "dead_letters", time.Since(start), 1)
+    return id, nil
+}
+
+// ListDeadLetters returns dead letters matching filter, oldest first
+func ListDeadLetters(filter models.DeadLetterFilter) ([]models.DeadLetter, error) {
+    start := time.Now()
+
+    var (
+        conditions []string
+        args       []interface{}
+    )
+    if filter.Reason != "" {
+        args = append(args, filter.Reason)
+        conditions = append(conditions, fmt.Sprintf("reason = $%d", len(args)))
+    }
+    if filter.Source != "" {
+        args = append(args, filter.Source)
+        conditions = append(conditions, fmt.Sprintf("source = $%d", len(args)))
+    }
+    if !filter.IncludeReplayed {
+        conditions = append(conditions, "replayed_at IS NULL")
+    }
+    where := "TRUE"
+    if len(conditions) > 0 {
+        where = strings.Join(conditions, " AND ")
+    }
+
+    query := `SELECT id, received_at, reason, error, COALESCE(source, ''), payload, attempts, replayed_at
+        FROM dead_letters WHERE ` + where + ` ORDER BY id`
+    if filter.Limit > 0 {
+        args = append(args, filter.Limit)
+        query += fmt.Sprintf(" LIMIT $%d", len(args))
+    }
+    if filter.Offset > 0 {
+        args = append(args, filter.Offset)
+        query += fmt.Sprintf(" OFFSET $%d", len(args))
+    }
+
+    rows, err := db.Query(query, args...)
+    if err != nil {
+        dbLogger.WithFields(map[string]interface{}{
+            "operation": "SELECT",
+            "table":     "dead_letters",
+            "filter":    filter,
+            "error":     err.Error(),
+        }).Error("Failed to list dead letters")
+        return nil, err
+    }
+    defer rows.Close()
+
+    var letters []models.DeadLetter
+    for rows.Next() {
+        letter, err := scanDeadLetter(rows)
+        if err != nil {
+            dbLogger.WithError(err).Error("Failed to scan dead letter")
+            return nil, err
+        }
+        letters = append(letters, letter)
+    }
+    if err := rows.Err(); err != nil {
+        return nil, err
+    }
+
+    dbLogger.LogDatabaseOperation("SELECT", "dead_letters", time.Since(start), int64(len(letters)))
+    return letters, nil
+}
+
+// GetDeadLetter returns one dead letter; found is false if it does not exist
+func GetDeadLetter(id int64) (letter models.DeadLetter, found bool, err error) {
+    row := db.QueryRow(`SELECT id, received_at, reason, error, COALESCE(source, ''), payload, attempts, replayed_at
+        FROM dead_letters WHERE id = $1`, id)
+
+    letter, err = scanDeadLetter(row)
+    if err == sql.ErrNoRows {
+        return letter, false, nil
+    }
+    if err != nil {
+        dbLogger.WithFields(map[string]interface{}{
+            "operation": "SELECT",
+            "table":     "dead_letters",
+            "id":        id,
+            "error":     err.Error(),
+        }).Error("Failed to read dead letter")
+        return letter, false, err
+    }
+    return letter, true, nil
+}
+
+// UpdateDeadLetter records the outcome of a replay attempt
+func UpdateDeadLetter(letter models.DeadLetter) error {
+    _, err := db.Exec(`UPDATE dead_letters SET error = $2, attempts = $3, replayed_at = $4 WHERE id = $1`,
+        letter.ID, letter.Error, letter.Attempts, letter.ReplayedAt)
+    if err != nil {
+        dbLogger.WithFields(map[string]interface{}{
+            "operation": "UPDATE",
+            "table":     "dead_letters",
+            "id":        letter.ID,
+            "error":     err.Error(),
+        }).Error("Failed to update dead letter")
+    }
+    return err
+}
+
+// DeleteDeadLetter removes a dead letter; it returns false if it did not exist
+func DeleteDeadLetter(id int64) (bool, error) {
+    result, err := db.Exec(`DELETE FROM dead_letters WHERE id = $1`, id)
+    if err != nil {
+        dbLogger.WithFields(map[string]interface{}{
+            "operation": "DELETE",
+            "table":     "dead_letters",
+            "id":        id,
+            "error":     err.Error(),
+        }).Error("Failed to delete dead letter")
+        return false, err
+    }
+    rowsAffected, _ := result.RowsAffected()
+    return rowsAffected > 0, nil
+}
+
+type rowScanner interface {
+    Scan(dest ...interface{}) error
+}
+
+func scanDeadLetter(row rowScanner) (models.DeadLetter, error) {
+    var (
+        letter     models.DeadLetter
+        replayedAt sql.NullTime
+    )
+    err := row.Scan(&letter.ID, &letter.ReceivedAt, &letter.Reason, &letter.Error, &letter.Source, &letter.Payload, &letter.Attempts, &replayedAt)
+    if replayedAt.Valid {
+        letter.ReplayedAt = &replayedAt.Time
+    }
+    return letter, err
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+    "context"
+    "fmt"
+    "strings"
+    "time"
+    "log-processing-system/services/log-ingestion/models"
+)
+
+// logFilterConditions builds a WHERE clause for filter, numbering parameters after existing args
+func logFilterConditions(filter models.LogFilter, args []interface{}) (string, []interface{}) {
+    var conditions []string
+    if filter.Source != "" {
+        args = append(args, filter.Source)
+        conditions = append(conditions, fmt.Sprintf("source = $%d", len(args)))
+    }
+    if filter.Level != "" {
+        args = append(args, strings.ToLower(filter.Level))
+        conditions = append(conditions, fmt.Sprintf("lower(level) = $%d", len(args)))
+    }
+    if filter.From != nil {
+        args = append(args, *filter.From)
+        conditions = append(conditions, fmt.Sprintf("timestamp >= $%d", len(args)))
+    }
+    if filter.To != nil {
+        args = append(args, *filter.To)
+        conditions = append(conditions, fmt.Sprintf("timestamp < $%d", len(args)))
+    }
+    if len(conditions) == 0 {
+        return "TRUE", args
+    }
+    return strings.Join(conditions, " AND "), args
+}
+
+// DeleteLogsBatch deletes up to batchSize log entries matching filter and
+// returns the number of rows removed. Each shard's share is deleted in a
+// single transaction; shards are visited in order until batchSize is
+// reached, so fewer rows than batchSize means none are left. Cancelling
+// ctx rolls back the batch being deleted.
+func DeleteLogsBatch(ctx context.Context, filter models.LogFilter, batchSize int) (int64, error) {
+    var total int64
+    for _, shard := range queriedShards(filter.Source) {
+        if total >= int64(batchSize) {
+            break
+        }
+        rowsAffected, err := deleteShardLogsBatch(ctx, shard, filter, batchSize-int(total))
+        if err != nil {
+            return total, err
+        }
+        total += rowsAffected
+    }
+    return total, nil
+}
+
+// deleteShardLogsBatch deletes a batch of entries from one shard. Entries
+// in the integrity chain are append-only and skipped.
+func deleteShardLogsBatch(ctx context.Context, shard logShard, filter models.LogFilter, batchSize int) (int64, error) {
+    start := time.Now()
+
+    where, args := logFilterConditions(filter, []interface{}{batchSize})
+    query := `DELETE FROM logs WHERE id IN (SELECT id FROM logs WHERE (` + where + `) AND entry_hash IS NULL LIMIT $1)`
+
+    result, err := shard.db.ExecContext(ctx, query, args...)
+    duration := time.Since(start)
+
+    if err != nil {
+        dbLogger.WithFields(map[string]interface{}{
+            "operation":   "DELETE",
+            "table":       "logs",
+            "shard":       shard.index,
+            "filter":      filter,
+            "batch_size":  batchSize,
+            "duration_ms": duration.Milliseconds(),
+            "error":       err.Error(),
+        }).Error("Failed to delete log batch")
+        return 0, err
+    }
+
+    rowsAffected, _ := result.RowsAffected()
+    dbLogger.LogDatabaseOperation("DELETE_BATCH", "logs", duration, rowsAffected)
+
+    return rowsAffected, nil
+}
@@ -0,0 +1,150 @@
+package database
+
+import (
+    "context"
+    "time"
+    "log-processing-system/services/log-ingestion/integrity"
+    "log-processing-system/services/log-ingestion/models"
+)
+
+// hashChainEnabled makes StoreLog append entries to the integrity chain
+var hashChainEnabled bool
+
+// verifyPageSize is the number of chained rows read per verification query
+const verifyPageSize = 1000
+
+// EnableHashChain switches StoreLog to tamper-evident chained inserts
+func EnableHashChain(enabled bool) {
+    hashChainEnabled = enabled
+    dbLogger.WithField("hash_chain_enabled", enabled).Info("Log integrity mode configured")
+}
+
+// storeChainedLog inserts logEntry linked to the current chain head. The head
+// row is locked for the duration of the transaction so appends are serialized.
+func storeChainedLog(ctx context.Context, logEntry models.Log) (err error) {
+    ctx, span := startSpan(ctx, "INSERT_CHAINED", "logs")
+    var rowsAffected int64
+    defer func() { endSpan(span, rowsAffected, err) }()
+
+    start := time.Now()
+
+    // Each shard keeps its own chain
+    tx, err := logShardFor(logEntry.Source).db.Begin()
+    if err != nil {
+        dbLogger.WithError(err).Error("Failed to begin chained insert transaction")
+        return err
+    }
+    defer tx.Rollback()
+
+    var prevHash string
+    if err := tx.QueryRow(`SELECT last_hash FROM log_chain_head FOR UPDATE`).Scan(&prevHash); err != nil {
+        dbLogger.WithError(err).Error("Failed to read integrity chain head")
+        return err
+    }
+
+    logEntry.Timestamp = integrity.NormalizeTimestamp(logEntry.Timestamp)
+    entryHash := integrity.ComputeHash(prevHash, logEntry)
+
+    var id int64
+    query := `INSERT INTO logs (level, message, timestamp, source, fields, prev_hash, entry_hash) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+    if err := tx.QueryRow(query, logEntry.Level, logEntry.Message, logEntry.Timestamp, logEntry.Source, logEntry.Fields, prevHash, entryHash).Scan(&id); err != nil {
+        dbLogger.WithFields(map[string]interface{}{
+            "operation": "INSERT",
+            "table":     "logs",
+            "level":     logEntry.Level,
+            "source":    logEntry.Source,
+            "error":     err.Error(),
+        }).ErrorContext(ctx, "Failed to store chained log entry")
+        return err
+    }
+
+    if _, err := tx.Exec(`UPDATE log_chain_head SET last_log_id = $1, last_hash = $2`, id, entryHash); err != nil {
+        dbLogger.WithError(err).Error("Failed to advance integrity chain head")
+        return err
+    }
+
+    if err := tx.Commit(); err != nil {
+        dbLogger.WithError(err).Error("Failed to commit chained insert")
+        return err
+    }
+
+    rowsAffected = 1
+    dbLogger.LogDatabaseOperation("INSERT_CHAINED", "logs", time.Since(start), 1)
+
+    return nil
+}
+
+// VerifyLogChain walks every chained entry in id order and reports any
+// entry whose hashes no longer verify. Each shard's chain is verified on its
+// own and the reports merged.
+func VerifyLogChain() (integrity.Report, error) {
+    start := time.Now()
+
+    var reports []integrity.Report
+    for _, shard := range logShards() {
+        report, err := verifyShardChain(shard)
+        if err != nil {
+            return integrity.Report{}, err
+        }
+        reports = append(reports, report)
+    }
+
+    report := integrity.MergeReports(reports...)
+    dbLogger.LogDatabaseOperation("VERIFY_CHAIN", "logs", time.Since(start), report.Checked)
+
+    return report, nil
+}
+
+// verifyShardChain verifies the chain of one shard
+func verifyShardChain(shard logShard) (integrity.Report, error) {
+    verifier := integrity.NewVerifier()
+
+    query := `SELECT id, level, message, timestamp, COALESCE(source, ''), fields, prev_hash, entry_hash
+        FROM logs WHERE entry_hash IS NOT NULL AND id > $1 ORDER BY id LIMIT $2`
+
+    lastID := 0
+    for {
+        rows, err := shard.db.Query(query, lastID, verifyPageSize)
+        if err != nil {
+            dbLogger.WithFields(map[string]interface{}{
+                "operation": "SELECT",
+                "table":     "logs",
+                "shard":     shard.index,
+                "after_id":  lastID,
+                "error":     err.Error(),
+            }).Error("Failed to read integrity chain")
+            return integrity.Report{}, err
+        }
+
+        count := 0
+        for rows.Next() {
+            var entry integrity.ChainedLog
+            var fields []byte
+            if err := rows.Scan(&entry.Log.ID, &entry.Log.Level, &entry.Log.Message, &entry.Log.Timestamp, &entry.Log.Source, &fields, &entry.PrevHash, &entry.EntryHash); err != nil {
+                rows.Close()
+                dbLogger.WithError(err).Error("Failed to scan chained log entry")
+                return integrity.Report{}, err
+            }
+            if entry.Log.Fields, err = integrity.DecodeFields(fields); err != nil {
+                rows.Close()
+                dbLogger.WithError(err).Error("Failed to decode chained log fields")
+                return integrity.Report{}, err
+            }
+            lastID = entry.Log.ID
+            entry.Log.ID = shard.globalID(entry.Log.ID)
+            verifier.Check(entry)
+            count++
+        }
+        err = rows.Err()
+        rows.Close()
+        if err != nil {
+            return integrity.Report{}, err
+        }
+
+        if count < verifyPageSize {
+            break
+        }
+    }
+
+    return verifier.Report(), nil
+}
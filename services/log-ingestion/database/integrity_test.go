@@ -0,0 +1,70 @@
+package database
+
+import (
+    "context"
+    "fmt"
+    "testing"
+    "time"
+    "log-processing-system/services/log-ingestion/models"
+)
+
+func TestChainedLogs_SkippedByRollupAndDelete(t *testing.T) {
+    connectTestDB(t)
+    source := fmt.Sprintf("chain-test-%d", time.Now().UnixNano())
+    t.Cleanup(func() {
+        // Chained rows are only removable with the trigger off
+        tx, err := db.Begin()
+        if err != nil {
+            return
+        }
+        defer tx.Rollback()
+        tx.Exec(`ALTER TABLE logs DISABLE TRIGGER logs_chain_append_only`)
+        tx.Exec(`DELETE FROM logs WHERE source = $1`, source)
+        tx.Exec(`ALTER TABLE logs ENABLE TRIGGER logs_chain_append_only`)
+        tx.Commit()
+        db.Exec(`DELETE FROM log_rollups WHERE source = $1`, source)
+    })
+    old := time.Now().Add(-48 * time.Hour)
+    cutoff := time.Now().Add(-24 * time.Hour)
+
+    store := func(chained bool, count int) {
+        EnableHashChain(chained)
+        defer EnableHashChain(false)
+        for i := 0; i < count; i++ {
+            if err := StoreLog(models.Log{Level: "debug", Message: "aged", Timestamp: old, Source: source}); err != nil {
+                t.Fatalf("Failed to store entry: %v", err)
+            }
+        }
+    }
+    store(true, 2)
+    store(false, 3)
+
+    result, err := RollupLogs(cutoff, []string{"debug"})
+    if err != nil {
+        t.Fatalf("Expected the rollup to leave chained entries alone, got %v", err)
+    }
+    var rolledUp int64
+    if err := db.QueryRow(`SELECT COALESCE(SUM(count), 0) FROM log_rollups WHERE source = $1`, source).Scan(&rolledUp); err != nil {
+        t.Fatalf("Failed to count rollups: %v", err)
+    }
+    if rolledUp != 3 || result.RowsRolledUp < 3 {
+        t.Errorf("Expected the 3 unchained entries rolled up, got %d (%d reported)", rolledUp, result.RowsRolledUp)
+    }
+
+    store(false, 3)
+    deleted, err := DeleteLogsBatch(context.Background(), models.LogFilter{Source: source}, 10)
+    if err != nil {
+        t.Fatalf("Expected the delete to skip chained entries, got %v", err)
+    }
+    if deleted != 3 {
+        t.Errorf("Expected the 3 unchained entries deleted, got %d", deleted)
+    }
+
+    var remaining int64
+    if err := db.QueryRow(`SELECT COUNT(*) FROM logs WHERE source = $1 AND entry_hash IS NOT NULL`, source).Scan(&remaining); err != nil {
+        t.Fatalf("Failed to count entries: %v", err)
+    }
+    if remaining != 2 {
+        t.Errorf("Expected both chained entries kept, got %d", remaining)
+    }
+}
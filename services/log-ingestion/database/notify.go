@@ -0,0 +1,149 @@
+package database
+
+import (
+    "context"
+    "encoding/json"
+    "time"
+    "log-processing-system/services/log-ingestion/models"
+
+    "github.com/lib/pq"
+)
+
+// NewLogsChannel is the Postgres NOTIFY channel populated by the logs insert trigger
+const NewLogsChannel = "new_logs"
+
+// newLogNotification mirrors the payload built by notify_new_log() in the migrations
+type newLogNotification struct {
+    ID        int           `json:"id"`
+    Level     string        `json:"level"`
+    Message   string        `json:"message"`
+    Timestamp time.Time     `json:"timestamp"`
+    Source    string        `json:"source"`
+    Fields    models.Fields `json:"fields"`
+    Truncated bool          `json:"truncated"`
+}
+
+// ListenForNewLogs subscribes to NewLogsChannel and hands every newly stored
+// entry to publish until ctx is cancelled. Rows too large for a NOTIFY payload
+// are announced by id only and fetched from the logs table. With log shards,
+// it listens on each of them rather than on connStr.
+func ListenForNewLogs(ctx context.Context, connStr string, publish func(models.Log)) error {
+    if len(shards) == 0 {
+        return listenForNewLogs(ctx, logShard{index: 0, db: db, connStr: connStr}, publish)
+    }
+    return fanOut(shards, func(shard logShard) error {
+        return listenForNewLogs(ctx, shard, publish)
+    })
+}
+
+// listenForNewLogs listens for the entries stored in one shard
+func listenForNewLogs(ctx context.Context, shard logShard, publish func(models.Log)) error {
+    listener := pq.NewListener(shard.connStr, 10*time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+        switch event {
+        case pq.ListenerEventDisconnected:
+            dbLogger.WithError(err).Warn("Notification listener disconnected")
+        case pq.ListenerEventReconnected:
+            dbLogger.Info("Notification listener reconnected")
+        case pq.ListenerEventConnectionAttemptFailed:
+            dbLogger.WithError(err).Warn("Notification listener connection attempt failed")
+        }
+    })
+    defer listener.Close()
+
+    if err := listener.Listen(NewLogsChannel); err != nil {
+        dbLogger.WithFields(map[string]interface{}{
+            "channel": NewLogsChannel,
+            "shard":   shard.index,
+            "error":   err.Error(),
+        }).Error("Failed to listen for new log notifications")
+        return err
+    }
+
+    dbLogger.WithField("channel", NewLogsChannel).Info("Listening for new log notifications")
+
+    for {
+        select {
+        case <-ctx.Done():
+            return nil
+        case notification := <-listener.Notify:
+            // A nil notification means the connection was re-established and
+            // notifications sent while disconnected may have been missed
+            if notification == nil {
+                dbLogger.WithField("channel", NewLogsChannel).Warn("Notifications may have been lost during reconnect")
+                continue
+            }
+
+            logEntry, err := decodeNewLogNotification(shard, notification.Extra)
+            if err != nil {
+                dbLogger.WithFields(map[string]interface{}{
+                    "channel":        NewLogsChannel,
+                    "payload_length": len(notification.Extra),
+                    "error":          err.Error(),
+                }).Warn("Failed to decode new log notification")
+                continue
+            }
+
+            publish(logEntry)
+        case <-time.After(90 * time.Second):
+            // Make sure the connection is still alive when the table is quiet
+            if err := listener.Ping(); err != nil {
+                dbLogger.WithError(err).Warn("Notification listener ping failed")
+            }
+        }
+    }
+}
+
+func decodeNewLogNotification(shard logShard, payload string) (models.Log, error) {
+    var n newLogNotification
+    if err := json.Unmarshal([]byte(payload), &n); err != nil {
+        return models.Log{}, err
+    }
+
+    if n.Truncated {
+        return getShardLogByID(shard, n.ID)
+    }
+
+    return models.Log{
+        ID:        shard.globalID(n.ID),
+        Level:     n.Level,
+        Message:   n.Message,
+        Timestamp: n.Timestamp,
+        Source:    n.Source,
+        Fields:    n.Fields,
+    }, nil
+}
+
+// GetLogByID retrieves a single log entry by its primary key, or by its id
+// across shards when they are used
+func GetLogByID(id int) (models.Log, error) {
+    shard, localID := shardForID(id)
+    return getShardLogByID(shard, localID)
+}
+
+// getShardLogByID retrieves the entry with id in shard
+func getShardLogByID(shard logShard, id int) (models.Log, error) {
+    start := time.Now()
+
+    var logEntry models.Log
+    query := `SELECT id, level, message, timestamp, source, fields FROM logs WHERE id = $1`
+    err := shard.db.QueryRow(query, id).Scan(&logEntry.ID, &logEntry.Level, &logEntry.Message, &logEntry.Timestamp, &logEntry.Source, &logEntry.Fields)
+
+    duration := time.Since(start)
+
+    if err != nil {
+        dbLogger.WithFields(map[string]interface{}{
+            "operation":   "SELECT",
+            "table":       "logs",
+            "shard":       shard.index,
+            "id":          id,
+            "duration_ms": duration.Milliseconds(),
+            "error":       err.Error(),
+        }).Error("Failed to retrieve log entry by id")
+        return models.Log{}, err
+    }
+
+    dbLogger.LogDatabaseOperation("SELECT_BY_ID", "logs", duration, 1)
+
+    logEntry.ID = shard.globalID(logEntry.ID)
+    return logEntry, nil
+}
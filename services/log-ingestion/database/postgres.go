@@ -1,6 +1,7 @@
 package database
 
 import (
+    "context"
     "database/sql"
     "time"
     "log-processing-system/services/log-ingestion/models"
@@ -12,6 +13,28 @@ import (
 var db *sql.DB
 var dbLogger = logger.NewFromEnv("log-ingestion", "database")
 
+// Tuning sizes the connection pool Connect opens, with the values as
+// database/sql takes them, and sets how slow an operation must be to be
+// logged as a warning
+type Tuning struct {
+    MaxOpenConns    int
+    MaxIdleConns    int
+    ConnMaxLifetime time.Duration
+    SlowQuery       time.Duration
+}
+
+var tuning = Tuning{
+    MaxOpenConns:    25,
+    MaxIdleConns:    5,
+    ConnMaxLifetime: 5 * time.Minute,
+    SlowQuery:       100 * time.Millisecond,
+}
+
+// Tune replaces the default tuning; call it before Connect
+func Tune(t Tuning) {
+    tuning = t
+}
+
 // Connect initializes the connection to the PostgreSQL database
 func Connect(connStr string) error {
     start := time.Now()
@@ -24,9 +47,9 @@ func Connect(connStr string) error {
     }
 
     // Configure connection pool
-    db.SetMaxOpenConns(25)
-    db.SetMaxIdleConns(5)
-    db.SetConnMaxLifetime(5 * time.Minute)
+    db.SetMaxOpenConns(tuning.MaxOpenConns)
+    db.SetMaxIdleConns(tuning.MaxIdleConns)
+    db.SetConnMaxLifetime(tuning.ConnMaxLifetime)
 
     if err = db.Ping(); err != nil {
         dbLogger.WithError(err).Error("Failed to ping database")
@@ -36,9 +59,9 @@ func Connect(connStr string) error {
     duration := time.Since(start)
     dbLogger.WithFields(map[string]interface{}{
         "duration_ms":     duration.Milliseconds(),
-        "max_open_conns":  25,
-        "max_idle_conns":  5,
-        "conn_max_lifetime": "5m",
+        "max_open_conns":  tuning.MaxOpenConns,
+        "max_idle_conns":  tuning.MaxIdleConns,
+        "conn_max_lifetime": tuning.ConnMaxLifetime.String(),
     }).Info("Successfully connected to database")
 
     return nil
@@ -46,10 +69,26 @@ func Connect(connStr string) error {
 
 // StoreLog stores a log entry into the logs table
 func StoreLog(logEntry models.Log) error {
+    return StoreLogContext(context.Background(), logEntry)
+}
+
+// StoreLogContext stores a log entry like StoreLog, traced as part of the
+// request in ctx. The insert itself does not stop when ctx is done: an
+// accepted entry is stored even if its client goes away.
+func StoreLogContext(ctx context.Context, logEntry models.Log) (err error) {
+    if hashChainEnabled {
+        return storeChainedLog(ctx, logEntry)
+    }
+
+    ctx, span := startSpan(ctx, "INSERT", "logs")
+    var rowsAffected int64
+    defer func() { endSpan(span, rowsAffected, err) }()
+
     start := time.Now()
     
-    query := `INSERT INTO logs (level, message, timestamp, source) VALUES ($1, $2, $3, $4)`
-    result, err := db.Exec(query, logEntry.Level, logEntry.Message, logEntry.Timestamp, logEntry.Source)
+    shard := logShardFor(logEntry.Source)
+    query := `INSERT INTO logs (level, message, timestamp, source, fields) VALUES ($1, $2, $3, $4, $5)`
+    result, err := shard.db.Exec(query, logEntry.Level, logEntry.Message, logEntry.Timestamp, logEntry.Source, logEntry.Fields)
     
     duration := time.Since(start)
     
@@ -57,24 +96,25 @@ func StoreLog(logEntry models.Log) error {
         dbLogger.WithFields(map[string]interface{}{
             "operation":    "INSERT",
             "table":        "logs",
+            "shard":        shard.index,
             "level":        logEntry.Level,
             "source":       logEntry.Source,
             "duration_ms":  duration.Milliseconds(),
             "error":        err.Error(),
-        }).Error("Failed to store log entry")
+        }).ErrorContext(ctx, "Failed to store log entry")
         return err
     }
 
-    rowsAffected, _ := result.RowsAffected()
+    rowsAffected, _ = result.RowsAffected()
     
     dbLogger.LogDatabaseOperation("INSERT", "logs", duration, rowsAffected)
     
-    if duration > 100*time.Millisecond {
+    if duration > tuning.SlowQuery {
         dbLogger.WithFields(map[string]interface{}{
             "operation":   "INSERT",
             "table":       "logs",
             "duration_ms": duration.Milliseconds(),
-        }).Warn("Slow database operation detected")
+        }).WarnContext(ctx, "Slow database operation detected")
     }
 
     return nil
@@ -131,8 +171,22 @@ func Ping() error {
     return nil
 }
 
-// Close closes the database connection
+// PingContext checks the connection like Ping, and those to the log
+// shards, giving up when ctx is done. Failures are left to the caller to
+// log.
+func PingContext(ctx context.Context) error {
+    if db == nil {
+        return sql.ErrConnDone
+    }
+    if err := db.PingContext(ctx); err != nil {
+        return err
+    }
+    return pingShards(ctx)
+}
+
+// Close closes the database connection and those to the log shards
 func Close() {
+    closeShards()
     if db != nil {
         if err := db.Close(); err != nil {
             dbLogger.WithError(err).Error("Error closing database connection")
@@ -148,7 +202,7 @@ func GetRecentLogs(limit int) ([]models.Log, error) {
     
     dbLogger.WithField("limit", limit).Debug("Retrieving recent logs")
     
-    query := `SELECT id, level, message, timestamp, source FROM logs ORDER BY timestamp DESC LIMIT $1`
+    query := `SELECT id, level, message, timestamp, source, fields FROM logs ORDER BY timestamp DESC LIMIT $1`
     rows, err := db.Query(query, limit)
     if err != nil {
         duration := time.Since(start)
@@ -166,7 +220,7 @@ func GetRecentLogs(limit int) ([]models.Log, error) {
     var logs []models.Log
     for rows.Next() {
         var logEntry models.Log
-        err := rows.Scan(&logEntry.ID, &logEntry.Level, &logEntry.Message, &logEntry.Timestamp, &logEntry.Source)
+        err := rows.Scan(&logEntry.ID, &logEntry.Level, &logEntry.Message, &logEntry.Timestamp, &logEntry.Source, &logEntry.Fields)
         if err != nil {
             dbLogger.WithError(err).Error("Failed to scan log entry")
             return nil, err
@@ -189,7 +243,7 @@ func GetLogsByTimeRange(startTime, endTime string) ([]models.Log, error) {
         "end_time":   endTime,
     }).Debug("Retrieving logs by time range")
     
-    query := `SELECT id, level, message, timestamp, source FROM logs WHERE timestamp BETWEEN $1 AND $2 ORDER BY timestamp DESC`
+    query := `SELECT id, level, message, timestamp, source, fields FROM logs WHERE timestamp BETWEEN $1 AND $2 ORDER BY timestamp DESC`
     rows, err := db.Query(query, startTime, endTime)
     if err != nil {
         duration := time.Since(start)
@@ -208,7 +262,7 @@ func GetLogsByTimeRange(startTime, endTime string) ([]models.Log, error) {
     var logs []models.Log
     for rows.Next() {
         var logEntry models.Log
-        err := rows.Scan(&logEntry.ID, &logEntry.Level, &logEntry.Message, &logEntry.Timestamp, &logEntry.Source)
+        err := rows.Scan(&logEntry.ID, &logEntry.Level, &logEntry.Message, &logEntry.Timestamp, &logEntry.Source, &logEntry.Fields)
         if err != nil {
             dbLogger.WithError(err).Error("Failed to scan log entry")
             return nil, err
@@ -228,7 +282,7 @@ func GetLogsByLevel(level string) ([]models.Log, error) {
     
     dbLogger.WithField("level", level).Debug("Retrieving logs by level")
     
-    query := `SELECT id, level, message, timestamp, source FROM logs WHERE level = $1 ORDER BY timestamp DESC`
+    query := `SELECT id, level, message, timestamp, source, fields FROM logs WHERE level = $1 ORDER BY timestamp DESC`
     rows, err := db.Query(query, level)
     if err != nil {
         duration := time.Since(start)
@@ -246,7 +300,7 @@ func GetLogsByLevel(level string) ([]models.Log, error) {
     var logs []models.Log
     for rows.Next() {
         var logEntry models.Log
-        err := rows.Scan(&logEntry.ID, &logEntry.Level, &logEntry.Message, &logEntry.Timestamp, &logEntry.Source)
+        err := rows.Scan(&logEntry.ID, &logEntry.Level, &logEntry.Message, &logEntry.Timestamp, &logEntry.Source, &logEntry.Fields)
         if err != nil {
             dbLogger.WithError(err).Error("Failed to scan log entry")
             return nil, err
@@ -0,0 +1,75 @@
+package database
+
+import (
+    "context"
+    "fmt"
+    "sync"
+    "time"
+    "log-processing-system/services/log-ingestion/models"
+)
+
+// QueryLogs returns up to limit entries matching filter, most recently
+// stored first. A positive before returns only entries stored before the
+// one with that id, for paging through results that keeps its place while
+// new entries arrive. Sharded, a query for a source reads its shard and
+// other queries read every shard, merging what they return.
+func QueryLogs(ctx context.Context, filter models.LogFilter, before, limit int) ([]models.Log, error) {
+    start := time.Now()
+
+    var mu sync.Mutex
+    logs := []models.Log{}
+    err := fanOut(queriedShards(filter.Source), func(shard logShard) error {
+        shardLogs, err := queryShardLogs(ctx, shard, filter, before, limit)
+        mu.Lock()
+        logs = append(logs, shardLogs...)
+        mu.Unlock()
+        return err
+    })
+    if err != nil {
+        dbLogger.WithFields(map[string]interface{}{
+            "operation":   "SELECT",
+            "table":       "logs",
+            "filter":      filter,
+            "duration_ms": time.Since(start).Milliseconds(),
+            "error":       err.Error(),
+        }).Error("Failed to query logs")
+        return nil, err
+    }
+    sortLogsByIDDesc(logs)
+    if len(logs) > limit {
+        logs = logs[:limit]
+    }
+
+    dbLogger.LogDatabaseOperation("SELECT", "logs", time.Since(start), int64(len(logs)))
+    return logs, nil
+}
+
+// queryShardLogs runs QueryLogs against one shard
+func queryShardLogs(ctx context.Context, shard logShard, filter models.LogFilter, before, limit int) ([]models.Log, error) {
+    where, args := logFilterConditions(filter, nil)
+    if before > 0 {
+        args = append(args, shard.localBefore(before))
+        where += fmt.Sprintf(" AND id < $%d", len(args))
+    }
+    args = append(args, limit)
+    query := `SELECT id, level, message, timestamp, source, fields FROM logs WHERE ` + where +
+        fmt.Sprintf(` ORDER BY id DESC LIMIT $%d`, len(args))
+
+    rows, err := shard.db.QueryContext(ctx, query, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var logs []models.Log
+    for rows.Next() {
+        var logEntry models.Log
+        if err := rows.Scan(&logEntry.ID, &logEntry.Level, &logEntry.Message, &logEntry.Timestamp, &logEntry.Source, &logEntry.Fields); err != nil {
+            dbLogger.WithError(err).Error("Failed to scan log entry")
+            return nil, err
+        }
+        logEntry.ID = shard.globalID(logEntry.ID)
+        logs = append(logs, logEntry)
+    }
+    return logs, rows.Err()
+}
@@ -0,0 +1,106 @@
+package database
+
+import (
+    "strings"
+    "time"
+
+    "github.com/lib/pq"
+)
+
+// RollupResult summarizes a single downsampling run
+type RollupResult struct {
+    RowsRolledUp   int64
+    BucketsWritten int64
+    Duration       time.Duration
+}
+
+// RollupLogs replaces raw log entries older than cutoff whose level is in
+// levels with per-source, per-level hourly rows in log_rollups. Both steps are
+// one statement, so a failed run leaves the raw rows untouched and every row
+// deleted is counted.
+func RollupLogs(cutoff time.Time, levels []string) (RollupResult, error) {
+    dbLogger.WithFields(map[string]interface{}{
+        "cutoff": cutoff,
+        "levels": levels,
+    }).Debug("Rolling up aged logs")
+
+    return rollupWhere(`timestamp < $1 AND lower(level) = ANY($2)`, cutoff, pq.Array(lowerAll(levels)))
+}
+
+// RollupNoiseLogs rolls up entries older than cutoff that the classify_noise
+// stage tagged as noise, whatever their level, so noise can be kept raw for
+// less time than other logs
+func RollupNoiseLogs(cutoff time.Time) (RollupResult, error) {
+    dbLogger.WithField("cutoff", cutoff).Debug("Rolling up aged noise logs")
+
+    return rollupWhere(`timestamp < $1 AND fields @> '{"noise": true}'`, cutoff)
+}
+
+// rollupWhere rolls up and deletes the raw rows selected by where, in every
+// shard
+func rollupWhere(where string, args ...interface{}) (RollupResult, error) {
+    start := time.Now()
+    total := RollupResult{}
+    for _, shard := range logShards() {
+        result, err := rollupShard(shard, where, args...)
+        if err != nil {
+            return total, err
+        }
+        total.RowsRolledUp += result.RowsRolledUp
+        total.BucketsWritten += result.BucketsWritten
+    }
+    total.Duration = time.Since(start)
+    return total, nil
+}
+
+// rollupShard rolls up the rows selected by where in one shard. Deleting
+// the rows and counting them is one statement, so an entry committed
+// meanwhile, such as an old one being replayed, is either deleted and
+// counted or left alone, never deleted without being counted. Entries in
+// the integrity chain are append-only and kept as they are.
+func rollupShard(shard logShard, where string, args ...interface{}) (RollupResult, error) {
+    start := time.Now()
+    result := RollupResult{}
+
+    query := `
+        WITH deleted AS (
+            DELETE FROM logs
+            WHERE (` + where + `) AND entry_hash IS NULL
+            RETURNING timestamp, source, level, message
+        ), upserted AS (
+            INSERT INTO log_rollups (bucket, source, level, count, first_seen, last_seen, sample_message)
+            SELECT date_trunc('hour', timestamp), COALESCE(source, 'unknown'), lower(level),
+                   COUNT(*), MIN(timestamp), MAX(timestamp), MIN(message)
+            FROM deleted
+            GROUP BY 1, 2, 3
+            ON CONFLICT (bucket, source, level) DO UPDATE SET
+                count = log_rollups.count + EXCLUDED.count,
+                first_seen = LEAST(log_rollups.first_seen, EXCLUDED.first_seen),
+                last_seen = GREATEST(log_rollups.last_seen, EXCLUDED.last_seen)
+            RETURNING 1
+        )
+        SELECT (SELECT COUNT(*) FROM deleted), (SELECT COUNT(*) FROM upserted)`
+
+    if err := shard.db.QueryRow(query, args...).Scan(&result.RowsRolledUp, &result.BucketsWritten); err != nil {
+        dbLogger.WithFields(map[string]interface{}{
+            "operation": "ROLLUP",
+            "table":     "logs",
+            "shard":     shard.index,
+            "error":     err.Error(),
+        }).Error("Failed to roll up logs")
+        return result, err
+    }
+
+    result.Duration = time.Since(start)
+    dbLogger.LogDatabaseOperation("ROLLUP", "logs", result.Duration, result.RowsRolledUp)
+
+    return result, nil
+}
+
+func lowerAll(values []string) []string {
+    lowered := make([]string, len(values))
+    for i, v := range values {
+        lowered[i] = strings.ToLower(v)
+    }
+    return lowered
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "sync"
+    "testing"
+    "time"
+    "log-processing-system/services/log-ingestion/models"
+)
+
+// connectTestDB connects to the database in TEST_DATABASE_URL, which needs
+// the migrations applied, and skips the test without one
+func connectTestDB(t *testing.T) {
+    t.Helper()
+    connStr := os.Getenv("TEST_DATABASE_URL")
+    if connStr == "" {
+        t.Skip("TEST_DATABASE_URL not set")
+    }
+    if err := Connect(connStr); err != nil {
+        t.Fatalf("Failed to connect to the test database: %v", err)
+    }
+    if err := CheckSchema(context.Background()); err != nil {
+        t.Fatalf("Test database not migrated: %v", err)
+    }
+    t.Cleanup(Close)
+}
+
+func TestRollupLogs_CountsEveryDeletedRow(t *testing.T) {
+    connectTestDB(t)
+    source := fmt.Sprintf("rollup-test-%d", time.Now().UnixNano())
+    t.Cleanup(func() {
+        db.Exec(`DELETE FROM logs WHERE source = $1`, source)
+        db.Exec(`DELETE FROM log_rollups WHERE source = $1`, source)
+    })
+    old := time.Now().Add(-48 * time.Hour)
+    cutoff := time.Now().Add(-24 * time.Hour)
+
+    // Old entries keep arriving while the rollups run, as replays store them
+    stop := make(chan struct{})
+    var wg sync.WaitGroup
+    var mu sync.Mutex
+    stored := 0
+    for w := 0; w < 4; w++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for {
+                select {
+                case <-stop:
+                    return
+                default:
+                }
+                if err := StoreLog(models.Log{Level: "debug", Message: "replayed", Timestamp: old, Source: source}); err != nil {
+                    t.Errorf("Failed to store entry: %v", err)
+                    return
+                }
+                mu.Lock()
+                stored++
+                mu.Unlock()
+            }
+        }()
+    }
+
+    var rolledUp int64
+    for i := 0; i < 20; i++ {
+        result, err := RollupLogs(cutoff, []string{"debug"})
+        if err != nil {
+            t.Fatalf("Rollup failed: %v", err)
+        }
+        rolledUp += result.RowsRolledUp
+    }
+    close(stop)
+    wg.Wait()
+    result, err := RollupLogs(cutoff, []string{"debug"})
+    if err != nil {
+        t.Fatalf("Rollup failed: %v", err)
+    }
+    rolledUp += result.RowsRolledUp
+
+    var remaining, counted int64
+    if err := db.QueryRow(`SELECT COUNT(*) FROM logs WHERE source = $1`, source).Scan(&remaining); err != nil {
+        t.Fatalf("Failed to count entries: %v", err)
+    }
+    if err := db.QueryRow(`SELECT COALESCE(SUM(count), 0) FROM log_rollups WHERE source = $1`, source).Scan(&counted); err != nil {
+        t.Fatalf("Failed to count rollups: %v", err)
+    }
+    if remaining != 0 || counted != int64(stored) || rolledUp != int64(stored) {
+        t.Errorf("Expected all %d entries rolled up and counted, got %d remaining, %d counted, %d reported", stored, remaining, counted, rolledUp)
+    }
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "strings"
+)
+
+// schemaTables are the tables created by the migrations in
+// database/migrations that this version uses
+var schemaTables = []string{
+    "logs",
+    "log_rollups",
+    "log_stats_minute",
+    "log_stats_watermark",
+    "log_chain_head",
+    "dead_letters",
+    "sessions",
+    "api_keys",
+    "audit_log",
+}
+
+// schemaColumns are the columns later migrations add to earlier tables
+var schemaColumns = []struct{ table, column string }{
+    {"logs", "prev_hash"},
+    {"logs", "entry_hash"},
+    {"logs", "fields"},
+}
+
+// CheckSchema checks that the migrations this version needs have been
+// applied, to the database and every log shard, naming what is missing
+// otherwise. Migrations are applied outside the services, so during a
+// rollout a new version may start before they are.
+func CheckSchema(ctx context.Context) error {
+    if db == nil {
+        return sql.ErrConnDone
+    }
+    if err := checkSchema(ctx, db); err != nil {
+        return err
+    }
+    for _, shard := range shards {
+        if err := checkSchema(ctx, shard.db); err != nil {
+            return fmt.Errorf("log shard %d: %w", shard.index, err)
+        }
+    }
+    return nil
+}
+
+// checkSchema checks the migrations of one database
+func checkSchema(ctx context.Context, db *sql.DB) error {
+    var missing []string
+    for _, table := range schemaTables {
+        var exists bool
+        if err := db.QueryRowContext(ctx, `SELECT to_regclass($1) IS NOT NULL`, table).Scan(&exists); err != nil {
+            return err
+        }
+        if !exists {
+            missing = append(missing, "table "+table)
+        }
+    }
+    for _, c := range schemaColumns {
+        var exists bool
+        query := `SELECT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = $1 AND column_name = $2)`
+        if err := db.QueryRowContext(ctx, query, c.table, c.column).Scan(&exists); err != nil {
+            return err
+        }
+        if !exists {
+            missing = append(missing, "column "+c.table+"."+c.column)
+        }
+    }
+
+    if len(missing) > 0 {
+        return fmt.Errorf("database migrations not applied, missing %s", strings.Join(missing, ", "))
+    }
+    return nil
+}
@@ -0,0 +1,160 @@
+package database
+
+import (
+    "encoding/json"
+    "fmt"
+    "strings"
+    "time"
+    "log-processing-system/services/log-ingestion/models"
+
+    "github.com/lib/pq"
+)
+
+// UpsertSessions writes session summaries, replacing the stored summary of
+// a session that is still being tracked
+func UpsertSessions(sessions []models.Session) error {
+    start := time.Now()
+
+    tx, err := db.Begin()
+    if err != nil {
+        dbLogger.WithError(err).Error("Failed to begin session upsert transaction")
+        return err
+    }
+    defer tx.Rollback()
+
+    upsert := `
+        INSERT INTO sessions (session_id, started_at, ended_at, entry_count, error_count, levels, sources, status, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
+        ON CONFLICT (session_id, started_at) DO UPDATE SET
+            ended_at = EXCLUDED.ended_at,
+            entry_count = EXCLUDED.entry_count,
+            error_count = EXCLUDED.error_count,
+            levels = EXCLUDED.levels,
+            sources = EXCLUDED.sources,
+            status = EXCLUDED.status,
+            updated_at = NOW()`
+
+    for _, s := range sessions {
+        levels, err := json.Marshal(s.Levels)
+        if err != nil {
+            return err
+        }
+        if _, err := tx.Exec(upsert, s.ID, s.StartedAt, s.EndedAt, s.EntryCount, s.ErrorCount, levels, pq.Array(s.Sources), s.Status); err != nil {
+            dbLogger.WithFields(map[string]interface{}{
+                "operation":  "INSERT",
+                "table":      "sessions",
+                "session_id": s.ID,
+                "error":      err.Error(),
+            }).Error("Failed to upsert session")
+            return err
+        }
+    }
+
+    if err := tx.Commit(); err != nil {
+        dbLogger.WithError(err).Error("Failed to commit session upsert")
+        return err
+    }
+
+    dbLogger.LogDatabaseOperation("UPSERT", "sessions", time.Since(start), int64(len(sessions)))
+    return nil
+}
+
+// ListSessions returns sessions matching filter, most recently started first
+func ListSessions(filter models.SessionFilter) ([]models.Session, error) {
+    start := time.Now()
+
+    var (
+        conditions []string
+        args       []interface{}
+    )
+    addCondition := func(format string, value interface{}) {
+        args = append(args, value)
+        conditions = append(conditions, fmt.Sprintf(format, len(args)))
+    }
+    if filter.Source != "" {
+        addCondition("$%d = ANY(sources)", filter.Source)
+    }
+    if filter.Status != "" {
+        addCondition("status = $%d", filter.Status)
+    }
+    if filter.MinErrors > 0 {
+        addCondition("error_count >= $%d", filter.MinErrors)
+    }
+    if filter.From != nil {
+        addCondition("started_at >= $%d", *filter.From)
+    }
+    if filter.To != nil {
+        addCondition("started_at < $%d", *filter.To)
+    }
+    where := "TRUE"
+    if len(conditions) > 0 {
+        where = strings.Join(conditions, " AND ")
+    }
+
+    query := `SELECT session_id, started_at, ended_at, entry_count, error_count, levels, sources, status
+        FROM sessions WHERE ` + where + ` ORDER BY started_at DESC, session_id`
+    if filter.Limit > 0 {
+        args = append(args, filter.Limit)
+        query += fmt.Sprintf(" LIMIT $%d", len(args))
+    }
+    if filter.Offset > 0 {
+        args = append(args, filter.Offset)
+        query += fmt.Sprintf(" OFFSET $%d", len(args))
+    }
+
+    sessions, err := querySessions(query, args...)
+    if err != nil {
+        dbLogger.WithFields(map[string]interface{}{
+            "operation": "SELECT",
+            "table":     "sessions",
+            "filter":    filter,
+            "error":     err.Error(),
+        }).Error("Failed to list sessions")
+        return nil, err
+    }
+
+    dbLogger.LogDatabaseOperation("SELECT", "sessions", time.Since(start), int64(len(sessions)))
+    return sessions, nil
+}
+
+// GetSessions returns every session recorded under id, most recent first;
+// an ID seen again after its session closed has one summary per session
+func GetSessions(id string) ([]models.Session, error) {
+    sessions, err := querySessions(`SELECT session_id, started_at, ended_at, entry_count, error_count, levels, sources, status
+        FROM sessions WHERE session_id = $1 ORDER BY started_at DESC`, id)
+    if err != nil {
+        dbLogger.WithFields(map[string]interface{}{
+            "operation":  "SELECT",
+            "table":      "sessions",
+            "session_id": id,
+            "error":      err.Error(),
+        }).Error("Failed to read session")
+        return nil, err
+    }
+    return sessions, nil
+}
+
+func querySessions(query string, args ...interface{}) ([]models.Session, error) {
+    rows, err := db.Query(query, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    sessions := []models.Session{}
+    for rows.Next() {
+        var (
+            s      models.Session
+            levels []byte
+        )
+        if err := rows.Scan(&s.ID, &s.StartedAt, &s.EndedAt, &s.EntryCount, &s.ErrorCount, &levels, pq.Array(&s.Sources), &s.Status); err != nil {
+            return nil, err
+        }
+        if err := json.Unmarshal(levels, &s.Levels); err != nil {
+            return nil, err
+        }
+        s.DurationMs = s.EndedAt.Sub(s.StartedAt).Milliseconds()
+        sessions = append(sessions, s)
+    }
+    return sessions, rows.Err()
+}
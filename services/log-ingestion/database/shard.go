@@ -0,0 +1,170 @@
+package database
+
+import (
+    "context"
+    "database/sql"
+    "hash/fnv"
+    "sort"
+    "sync"
+    "time"
+    "log-processing-system/services/log-ingestion/models"
+)
+
+// logShard is a database holding the log entries of the sources hashed to
+// its index, with their stats, rollups and integrity chain
+type logShard struct {
+    index   int
+    db      *sql.DB
+    connStr string
+}
+
+// shards are the databases log entries are spread over by ConnectShards;
+// without them every entry is stored in db
+var shards []logShard
+
+// ConnectShards opens the databases log entries are sharded over, in order,
+// tuned like the one Connect opens, which keeps every other table. Each
+// needs the same migrations, and neither their number nor their order may
+// change once entries are stored: sources would be looked for elsewhere.
+func ConnectShards(connStrs []string) error {
+    for i, connStr := range connStrs {
+        start := time.Now()
+        shardDB, err := sql.Open("postgres", connStr)
+        if err == nil {
+            shardDB.SetMaxOpenConns(tuning.MaxOpenConns)
+            shardDB.SetMaxIdleConns(tuning.MaxIdleConns)
+            shardDB.SetConnMaxLifetime(tuning.ConnMaxLifetime)
+            err = shardDB.Ping()
+        }
+        if err != nil {
+            dbLogger.WithFields(map[string]interface{}{
+                "shard": i,
+                "error": err.Error(),
+            }).Error("Failed to connect to log shard")
+            if shardDB != nil {
+                shardDB.Close()
+            }
+            closeShards()
+            return err
+        }
+        shards = append(shards, logShard{index: i, db: shardDB, connStr: connStr})
+
+        dbLogger.WithFields(map[string]interface{}{
+            "shard":       i,
+            "duration_ms": time.Since(start).Milliseconds(),
+        }).Info("Successfully connected to log shard")
+    }
+    return nil
+}
+
+// ShardCount returns how many databases log entries are spread over
+func ShardCount() int {
+    if len(shards) == 0 {
+        return 1
+    }
+    return len(shards)
+}
+
+// ShardFor returns the index of the shard storing the entries of source,
+// by its FNV-1a hash
+func ShardFor(source string) int {
+    h := fnv.New32a()
+    h.Write([]byte(source))
+    return int(h.Sum32() % uint32(ShardCount()))
+}
+
+// logShards returns every database holding log entries
+func logShards() []logShard {
+    if len(shards) == 0 {
+        return []logShard{{index: 0, db: db}}
+    }
+    return shards
+}
+
+// logShardFor returns the shard storing the entries of source
+func logShardFor(source string) logShard {
+    return logShards()[ShardFor(source)]
+}
+
+// queriedShards returns the shards a query for source reads: the one
+// holding source, or every shard when the query is not for a source
+func queriedShards(source string) []logShard {
+    if source != "" {
+        return []logShard{logShardFor(source)}
+    }
+    return logShards()
+}
+
+// Entries are identified across shards by their id in their shard times the
+// number of shards plus the shard's index. Unsharded, that is the id itself;
+// sharded, ordering by it orders entries by their id in their shard first,
+// which keeps paging with QueryLogs stable.
+
+// globalID returns the id of the entry with id in s
+func (s logShard) globalID(id int) int {
+    return id*ShardCount() + s.index
+}
+
+// localBefore returns the id in s below which entries have a global id below
+// before, which is positive; 0 when none do
+func (s logShard) localBefore(before int) int {
+    if before <= s.index {
+        return 0
+    }
+    return (before-s.index-1)/ShardCount() + 1
+}
+
+// shardForID returns the shard of the entry with a global id, and its id there
+func shardForID(id int) (logShard, int) {
+    n := ShardCount()
+    return logShards()[id%n], id / n
+}
+
+// fanOut runs fn on each of shards concurrently and returns the first error
+func fanOut(shards []logShard, fn func(s logShard) error) error {
+    if len(shards) == 1 {
+        return fn(shards[0])
+    }
+    errs := make([]error, len(shards))
+    var wg sync.WaitGroup
+    for i, s := range shards {
+        wg.Add(1)
+        go func(i int, s logShard) {
+            defer wg.Done()
+            errs[i] = fn(s)
+        }(i, s)
+    }
+    wg.Wait()
+    for _, err := range errs {
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// pingShards checks the connection to every shard
+func pingShards(ctx context.Context) error {
+    return fanOut(shards, func(s logShard) error {
+        return s.db.PingContext(ctx)
+    })
+}
+
+// closeShards closes the connections ConnectShards opened
+func closeShards() {
+    for _, s := range shards {
+        if err := s.db.Close(); err != nil {
+            dbLogger.WithFields(map[string]interface{}{
+                "shard": s.index,
+                "error": err.Error(),
+            }).Error("Error closing log shard connection")
+        }
+    }
+    shards = nil
+}
+
+// sortLogsByIDDesc orders entries merged from several shards like a single
+// database returns them
+func sortLogsByIDDesc(logs []models.Log) {
+    sort.Slice(logs, func(i, j int) bool { return logs[i].ID > logs[j].ID })
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+    "testing"
+)
+
+// withShards replaces the log shards by n unconnected ones for a test
+func withShards(t *testing.T, n int) {
+    t.Helper()
+    saved := shards
+    shards = nil
+    for i := 0; i < n; i++ {
+        shards = append(shards, logShard{index: i})
+    }
+    t.Cleanup(func() { shards = saved })
+}
+
+func TestShardFor(t *testing.T) {
+    if ShardCount() != 1 || ShardFor("payment_service") != 0 {
+        t.Fatalf("Expected every source in the only database, got shard %d of %d", ShardFor("payment_service"), ShardCount())
+    }
+
+    withShards(t, 4)
+    seen := make(map[int]bool)
+    for _, source := range []string{"api", "auth_service", "billing", "payment_service", "worker", "scheduler", "gateway", "search"} {
+        shard := ShardFor(source)
+        if shard < 0 || shard >= 4 || ShardFor(source) != shard {
+            t.Fatalf("Expected %q in a stable shard below 4, got %d", source, shard)
+        }
+        seen[shard] = true
+    }
+    if len(seen) < 2 {
+        t.Errorf("Expected sources spread over the shards, got %v", seen)
+    }
+}
+
+func TestShardIDs(t *testing.T) {
+    withShards(t, 3)
+
+    for _, shard := range shards {
+        for id := 1; id <= 10; id++ {
+            global := shard.globalID(id)
+            if got, local := shardForID(global); got.index != shard.index || local != id {
+                t.Fatalf("Expected global id %d to be %d in shard %d, got %d in shard %d", global, id, shard.index, local, got.index)
+            }
+        }
+    }
+
+    // Paging with before returns exactly the entries with a lower global id
+    for before := 1; before <= 40; before++ {
+        for _, shard := range shards {
+            limit := shard.localBefore(before)
+            for id := 1; id <= 12; id++ {
+                if (id < limit) != (shard.globalID(id) < before) {
+                    t.Fatalf("Shard %d before %d: local bound %d disagrees for id %d", shard.index, before, limit, id)
+                }
+            }
+        }
+    }
+}
+
+func TestShardIDs_Unsharded(t *testing.T) {
+    shard := logShards()[0]
+    if shard.globalID(42) != 42 || shard.localBefore(42) != 42 {
+        t.Errorf("Expected ids unchanged without shards, got %d and %d", shard.globalID(42), shard.localBefore(42))
+    }
+}
@@ -0,0 +1,20 @@
+package database
+
+import (
+    "context"
+    "log-processing-system/services/log-ingestion/models"
+)
+
+// Postgres is the connection opened by Connect as a store of the ingestion
+// handlers
+type Postgres struct{}
+
+// StoreLog stores an entry with StoreLogContext
+func (Postgres) StoreLog(ctx context.Context, entry models.Log) error {
+    return StoreLogContext(ctx, entry)
+}
+
+// Ping checks the connection with PingContext
+func (Postgres) Ping(ctx context.Context) error {
+    return PingContext(ctx)
+}
@@ -0,0 +1,23 @@
+package database
+
+import (
+    "context"
+    "log-processing-system/services/log-ingestion/tracing"
+)
+
+// startSpan starts the span of a database operation within the request in
+// ctx, named like "INSERT logs"; outside a traced request it returns nil,
+// which endSpan ignores
+func startSpan(ctx context.Context, operation, table string) (context.Context, *tracing.Span) {
+    ctx, span := tracing.Start(ctx, operation+" "+table, tracing.KindClient)
+    span.SetAttribute("db.system", "postgresql")
+    span.SetAttribute("db.operation", operation)
+    span.SetAttribute("db.sql.table", table)
+    return ctx, span
+}
+
+// endSpan records the rows an operation affected and its error
+func endSpan(span *tracing.Span, rows int64, err error) {
+    span.SetAttribute("db.rows_affected", rows)
+    span.End(err)
+}
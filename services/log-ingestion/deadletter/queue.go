@@ -0,0 +1,205 @@
+// Package deadletter keeps submissions that could not be ingested, with the
+// error that stopped them, so they can be inspected and replayed instead of
+// being lost with a 4xx or 5xx response.
+package deadletter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+var (
+	// ErrNotFound is returned when a dead letter does not exist
+	ErrNotFound = errors.New("dead letter not found")
+	// ErrAlreadyReplayed is returned when replaying a dead letter that was replayed successfully before
+	ErrAlreadyReplayed = errors.New("dead letter already replayed")
+	// ErrReplayFailed wraps the error of a replay that failed again
+	ErrReplayFailed = errors.New("replay failed")
+)
+
+// ReplayFunc re-ingests a dead letter
+type ReplayFunc func(ctx context.Context, letter models.DeadLetter) error
+
+// Queue records failed submissions in a Store and replays them on request
+type Queue struct {
+	store   Store
+	retries int
+	backoff time.Duration
+	logger  *logger.Logger
+	now     func() time.Time
+}
+
+// NewQueue creates a dead-letter queue. Storage operations run through
+// Retry are attempted retries more times, waiting backoff, 2*backoff, ...
+// between attempts, before the entry is dead-lettered.
+func NewQueue(store Store, retries int, backoff time.Duration, log *logger.Logger) *Queue {
+	if retries < 0 {
+		retries = 0
+	}
+	return &Queue{
+		store:   store,
+		retries: retries,
+		backoff: backoff,
+		logger:  log,
+		now:     time.Now,
+	}
+}
+
+// Retry calls fn until it succeeds, the retries are used up or ctx is done,
+// and returns the last error
+func (q *Queue) Retry(ctx context.Context, fn func() error) error {
+	err := fn()
+	for attempt := 1; err != nil && attempt <= q.retries; attempt++ {
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(attempt) * q.backoff):
+		}
+		err = fn()
+	}
+	return err
+}
+
+// Add records a failed submission and returns it with its id
+func (q *Queue) Add(ctx context.Context, reason, source string, payload []byte, cause error) (models.DeadLetter, error) {
+	letter := models.DeadLetter{
+		ReceivedAt: q.now().UTC(),
+		Reason:     reason,
+		Error:      cause.Error(),
+		Source:     source,
+		Payload:    string(payload),
+	}
+
+	id, err := q.store.Add(letter)
+	if err != nil {
+		q.logger.WithFields(map[string]interface{}{
+			"reason": reason,
+			"source": source,
+			"cause":  cause.Error(),
+			"error":  err.Error(),
+		}).ErrorContext(ctx, "Failed to record dead letter")
+		return letter, err
+	}
+	letter.ID = id
+
+	q.logger.WithFields(map[string]interface{}{
+		"dead_letter_id": id,
+		"reason":         reason,
+		"source":         source,
+		"error":          letter.Error,
+	}).WarnContext(ctx, "Submission dead-lettered")
+	return letter, nil
+}
+
+// List returns dead letters matching filter, oldest first
+func (q *Queue) List(filter models.DeadLetterFilter) ([]models.DeadLetter, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	letters, err := q.store.List(filter)
+	if letters == nil && err == nil {
+		letters = []models.DeadLetter{}
+	}
+	return letters, err
+}
+
+// Get returns one dead letter or ErrNotFound
+func (q *Queue) Get(id int64) (models.DeadLetter, error) {
+	letter, found, err := q.store.Get(id)
+	if err != nil {
+		return letter, err
+	}
+	if !found {
+		return letter, ErrNotFound
+	}
+	return letter, nil
+}
+
+// Delete discards a dead letter or returns ErrNotFound
+func (q *Queue) Delete(id int64) error {
+	found, err := q.store.Delete(id)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Replay passes a dead letter to fn. On success it is marked replayed; on
+// failure its error is replaced and ErrReplayFailed is returned. Either way
+// the attempt is counted and the updated dead letter returned.
+func (q *Queue) Replay(ctx context.Context, id int64, fn ReplayFunc) (models.DeadLetter, error) {
+	letter, err := q.Get(id)
+	if err != nil {
+		return letter, err
+	}
+	if letter.ReplayedAt != nil {
+		return letter, ErrAlreadyReplayed
+	}
+
+	letter.Attempts++
+	replayErr := fn(ctx, letter)
+	if replayErr != nil {
+		letter.Error = replayErr.Error()
+	} else {
+		replayedAt := q.now().UTC()
+		letter.ReplayedAt = &replayedAt
+	}
+
+	if err := q.store.Update(letter); err != nil {
+		q.logger.WithFields(map[string]interface{}{
+			"dead_letter_id": id,
+			"error":          err.Error(),
+		}).ErrorContext(ctx, "Failed to record dead letter replay")
+		return letter, err
+	}
+
+	logEntry := q.logger.WithFields(map[string]interface{}{
+		"dead_letter_id": id,
+		"reason":         letter.Reason,
+		"attempts":       letter.Attempts,
+	})
+	if replayErr != nil {
+		logEntry.WithField("error", replayErr.Error()).WarnContext(ctx, "Dead letter replay failed")
+		return letter, fmt.Errorf("%w: %v", ErrReplayFailed, replayErr)
+	}
+	logEntry.InfoContext(ctx, "Dead letter replayed")
+	return letter, nil
+}
+
+// ReplayResult reports the outcome of replaying one dead letter in a batch
+type ReplayResult struct {
+	ID       int64  `json:"id"`
+	Replayed bool   `json:"replayed"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ReplayAll replays every pending dead letter matching filter, in order,
+// and reports each outcome. It stops early only when ctx is done.
+func (q *Queue) ReplayAll(ctx context.Context, filter models.DeadLetterFilter, fn ReplayFunc) ([]ReplayResult, error) {
+	filter.IncludeReplayed = false
+	letters, err := q.List(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]ReplayResult, 0, len(letters))
+	for _, letter := range letters {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		result := ReplayResult{ID: letter.ID, Replayed: true}
+		if _, err := q.Replay(ctx, letter.ID, fn); err != nil {
+			result.Replayed = false
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
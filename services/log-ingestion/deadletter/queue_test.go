@@ -0,0 +1,153 @@
+package deadletter
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func newTestQueue(t *testing.T, path string) *Queue {
+	store, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	return NewQueue(store, 2, time.Millisecond, logger.New(logger.Config{Service: "test-service", Component: "deadletter"}))
+}
+
+func TestQueue_AddListAndReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead-letters.jsonl")
+	q := newTestQueue(t, path)
+
+	first, err := q.Add(context.Background(), models.DeadLetterParse, "", []byte(`{"message":`), errors.New("unexpected EOF"))
+	if err != nil {
+		t.Fatalf("Failed to add dead letter: %v", err)
+	}
+	second, _ := q.Add(context.Background(), models.DeadLetterValidation, "api", []byte(`{"message":"m","level":"loud"}`), errors.New("invalid log level"))
+	if first.ID != 1 || second.ID != 2 {
+		t.Errorf("Expected ids 1 and 2, got %d and %d", first.ID, second.ID)
+	}
+
+	letters, err := q.List(models.DeadLetterFilter{Reason: models.DeadLetterValidation})
+	if err != nil || len(letters) != 1 || letters[0].Source != "api" {
+		t.Errorf("Expected the validation dead letter, got %+v (err %v)", letters, err)
+	}
+
+	// A reopened store keeps its letters and continues the id sequence
+	q = newTestQueue(t, path)
+	letters, _ = q.List(models.DeadLetterFilter{})
+	if len(letters) != 2 || letters[0].Payload != `{"message":` || letters[1].Error != "invalid log level" {
+		t.Errorf("Expected both dead letters after reopening, got %+v", letters)
+	}
+	third, _ := q.Add(context.Background(), models.DeadLetterStorage, "api", []byte(`{}`), errors.New("connection refused"))
+	if third.ID != 3 {
+		t.Errorf("Expected id 3 after reopening, got %d", third.ID)
+	}
+
+	if _, err := q.List(models.DeadLetterFilter{Reason: "bogus"}); err == nil {
+		t.Error("Expected invalid reason to be rejected")
+	}
+}
+
+func TestQueue_Replay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead-letters.jsonl")
+	q := newTestQueue(t, path)
+	letter, _ := q.Add(context.Background(), models.DeadLetterStorage, "api", []byte(`{"message":"m"}`), errors.New("connection refused"))
+
+	failing := func(ctx context.Context, l models.DeadLetter) error { return errors.New("still down") }
+	updated, err := q.Replay(context.Background(), letter.ID, failing)
+	if !errors.Is(err, ErrReplayFailed) {
+		t.Fatalf("Expected replay failure, got %v", err)
+	}
+	if updated.Attempts != 1 || updated.Error != "still down" || updated.ReplayedAt != nil {
+		t.Errorf("Expected one failed attempt, got %+v", updated)
+	}
+
+	var replayed string
+	updated, err = q.Replay(context.Background(), letter.ID, func(ctx context.Context, l models.DeadLetter) error {
+		replayed = l.Payload
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected replay to succeed, got %v", err)
+	}
+	if replayed != `{"message":"m"}` || updated.Attempts != 2 || updated.ReplayedAt == nil {
+		t.Errorf("Expected payload replayed on the second attempt, got %+v", updated)
+	}
+
+	if _, err := q.Replay(context.Background(), letter.ID, failing); !errors.Is(err, ErrAlreadyReplayed) {
+		t.Errorf("Expected already replayed error, got %v", err)
+	}
+	if _, err := q.Replay(context.Background(), 99, failing); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+
+	// Replayed letters are hidden by default and survive a reopen
+	q = newTestQueue(t, path)
+	if letters, _ := q.List(models.DeadLetterFilter{}); len(letters) != 0 {
+		t.Errorf("Expected no pending dead letters, got %+v", letters)
+	}
+	letters, _ := q.List(models.DeadLetterFilter{IncludeReplayed: true})
+	if len(letters) != 1 || letters[0].Attempts != 2 || letters[0].ReplayedAt == nil {
+		t.Errorf("Expected the replayed dead letter, got %+v", letters)
+	}
+}
+
+func TestQueue_ReplayAllAndDelete(t *testing.T) {
+	q := newTestQueue(t, filepath.Join(t.TempDir(), "dead-letters.jsonl"))
+	for _, payload := range []string{"a", "b", "c"} {
+		q.Add(context.Background(), models.DeadLetterPipeline, "api", []byte(payload), errors.New("rejected"))
+	}
+
+	results, err := q.ReplayAll(context.Background(), models.DeadLetterFilter{Limit: 2}, func(ctx context.Context, l models.DeadLetter) error {
+		if l.Payload == "b" {
+			return errors.New("rejected again")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(results) != 2 || !results[0].Replayed || results[1].Replayed || results[1].Error == "" {
+		t.Errorf("Expected first replayed and second failed, got %+v", results)
+	}
+
+	if err := q.Delete(2); err != nil {
+		t.Fatalf("Failed to delete dead letter: %v", err)
+	}
+	if err := q.Delete(2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected not found on second delete, got %v", err)
+	}
+	letters, _ := q.List(models.DeadLetterFilter{})
+	if len(letters) != 1 || letters[0].Payload != "c" {
+		t.Errorf("Expected only c pending, got %+v", letters)
+	}
+}
+
+func TestQueue_Retry(t *testing.T) {
+	q := newTestQueue(t, filepath.Join(t.TempDir(), "dead-letters.jsonl"))
+
+	calls := 0
+	err := q.Retry(context.Background(), func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("transient")
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("Expected success on the third call, got %v after %d calls", err, calls)
+	}
+
+	calls = 0
+	err = q.Retry(context.Background(), func() error {
+		calls++
+		return errors.New("down")
+	})
+	if err == nil || calls != 3 {
+		t.Errorf("Expected failure after 3 calls, got %v after %d calls", err, calls)
+	}
+}
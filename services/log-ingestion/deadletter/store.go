@@ -0,0 +1,231 @@
+package deadletter
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"log-processing-system/services/log-ingestion/database"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+// Store persists dead letters
+type Store interface {
+	Add(letter models.DeadLetter) (int64, error)
+	List(filter models.DeadLetterFilter) ([]models.DeadLetter, error)
+	Get(id int64) (models.DeadLetter, bool, error)
+	Update(letter models.DeadLetter) error
+	Delete(id int64) (bool, error)
+}
+
+// tableStore keeps dead letters in the dead_letters table
+type tableStore struct{}
+
+// NewTableStore returns a store backed by the dead_letters table
+func NewTableStore() Store {
+	return tableStore{}
+}
+
+func (tableStore) Add(letter models.DeadLetter) (int64, error) {
+	return database.InsertDeadLetter(letter)
+}
+
+func (tableStore) List(filter models.DeadLetterFilter) ([]models.DeadLetter, error) {
+	return database.ListDeadLetters(filter)
+}
+
+func (tableStore) Get(id int64) (models.DeadLetter, bool, error) {
+	return database.GetDeadLetter(id)
+}
+
+func (tableStore) Update(letter models.DeadLetter) error {
+	return database.UpdateDeadLetter(letter)
+}
+
+func (tableStore) Delete(id int64) (bool, error) {
+	return database.DeleteDeadLetter(id)
+}
+
+// fileStore keeps dead letters as JSON lines in a local file, so failures
+// are kept even when the database is the thing that is down. The file is
+// loaded into memory on open; additions are appended and synced, updates
+// and deletes rewrite it.
+type fileStore struct {
+	path string
+
+	mu      sync.Mutex
+	letters []models.DeadLetter
+	nextID  int64
+}
+
+// OpenFileStore loads or creates a JSON lines dead-letter file
+func OpenFileStore(path string) (Store, error) {
+	s := &fileStore{path: path, nextID: 1}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var letter models.DeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &letter); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		s.letters = append(s.letters, letter)
+		if letter.ID >= s.nextID {
+			s.nextID = letter.ID + 1
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *fileStore) Add(letter models.DeadLetter) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	letter.ID = s.nextID
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return 0, err
+	}
+	if err := f.Sync(); err != nil {
+		return 0, err
+	}
+
+	s.letters = append(s.letters, letter)
+	s.nextID++
+	return letter.ID, nil
+}
+
+func (s *fileStore) List(filter models.DeadLetterFilter) ([]models.DeadLetter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var letters []models.DeadLetter
+	skipped := 0
+	for _, letter := range s.letters {
+		if !filter.Matches(letter) {
+			continue
+		}
+		if skipped < filter.Offset {
+			skipped++
+			continue
+		}
+		letters = append(letters, letter)
+		if filter.Limit > 0 && len(letters) == filter.Limit {
+			break
+		}
+	}
+	return letters, nil
+}
+
+func (s *fileStore) Get(id int64) (models.DeadLetter, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i := s.index(id); i >= 0 {
+		return s.letters[i], true, nil
+	}
+	return models.DeadLetter{}, false, nil
+}
+
+func (s *fileStore) Update(letter models.DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(letter.ID)
+	if i < 0 {
+		return fmt.Errorf("dead letter %d not found", letter.ID)
+	}
+	previous := s.letters[i]
+	s.letters[i] = letter
+	if err := s.rewrite(); err != nil {
+		s.letters[i] = previous
+		return err
+	}
+	return nil
+}
+
+func (s *fileStore) Delete(id int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(id)
+	if i < 0 {
+		return false, nil
+	}
+	previous := s.letters
+	s.letters = append(append([]models.DeadLetter{}, s.letters[:i]...), s.letters[i+1:]...)
+	if err := s.rewrite(); err != nil {
+		s.letters = previous
+		return false, err
+	}
+	return true, nil
+}
+
+// index returns the position of a dead letter, or -1
+func (s *fileStore) index(id int64) int {
+	for i, letter := range s.letters {
+		if letter.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// rewrite replaces the file with the current letters via a temporary file
+func (s *fileStore) rewrite() error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, letter := range s.letters {
+		if err := enc.Encode(letter); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,101 @@
+// Package features holds the feature flags of a deployment, so risky
+// features can be rolled out, or switched off, per deployment without a
+// release. Flags are set from the FEATURES setting on startup.
+package features
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Feature flags
+const (
+	// LegacyLogsEndpoint serves POST /logs, the compatibility alias of
+	// POST /ingest
+	LegacyLogsEndpoint = "legacy_logs_endpoint"
+	// LiveTail serves GET /logs/tail
+	LiveTail = "live_tail"
+	// Pipeline runs the processing pipeline of PIPELINE_CONFIG
+	Pipeline = "pipeline"
+)
+
+// StagePrefix starts the flags of individual pipeline stages, named after
+// a stage's name or type: pipeline_stage.geoip
+const StagePrefix = "pipeline_stage."
+
+// defaults are the flags' values when a deployment does not set them;
+// every pipeline stage is enabled by default too
+var defaults = map[string]bool{
+	LegacyLogsEndpoint: true,
+	LiveTail:           true,
+	Pipeline:           true,
+}
+
+var (
+	mu        sync.RWMutex
+	overrides = map[string]bool{}
+)
+
+// Set replaces the flags a deployment sets
+func Set(flags map[string]bool) {
+	m := make(map[string]bool, len(flags))
+	for name, enabled := range flags {
+		m[name] = enabled
+	}
+	mu.Lock()
+	overrides = m
+	mu.Unlock()
+}
+
+// Enabled reports whether a feature is enabled. Unknown features are
+// disabled.
+func Enabled(name string) bool {
+	mu.RLock()
+	enabled, ok := overrides[name]
+	mu.RUnlock()
+	if ok {
+		return enabled
+	}
+	if strings.HasPrefix(name, StagePrefix) {
+		return true
+	}
+	return defaults[name]
+}
+
+// StageEnabled reports whether a pipeline stage runs; its flag may name
+// either the stage or its type
+func StageEnabled(name, stageType string) bool {
+	return Enabled(StagePrefix+name) && Enabled(StagePrefix+stageType)
+}
+
+// Known reports whether name is a feature flag
+func Known(name string) bool {
+	_, ok := defaults[name]
+	return ok || (strings.HasPrefix(name, StagePrefix) && len(name) > len(StagePrefix))
+}
+
+// All returns every flag with its current value: the known features and
+// the pipeline stage flags a deployment set
+func All() map[string]bool {
+	all := make(map[string]bool, len(defaults))
+	for name := range defaults {
+		all[name] = Enabled(name)
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	for name, enabled := range overrides {
+		all[name] = enabled
+	}
+	return all
+}
+
+// Names returns the known features, sorted
+func Names() []string {
+	names := make([]string, 0, len(defaults))
+	for name := range defaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,41 @@
+package features
+
+import "testing"
+
+func TestEnabled(t *testing.T) {
+	defer Set(nil)
+
+	if !Enabled(LiveTail) || !Enabled(Pipeline) || !StageEnabled("geo", "geoip") {
+		t.Error("Expected known features and stages to be enabled by default")
+	}
+	if Enabled("time_travel") {
+		t.Error("Expected unknown features to be disabled")
+	}
+
+	Set(map[string]bool{LiveTail: false, StagePrefix + "geoip": false, StagePrefix + "mask-ips": false})
+	if Enabled(LiveTail) || !Enabled(LegacyLogsEndpoint) {
+		t.Error("Expected only the features set to change")
+	}
+	if StageEnabled("geo", "geoip") || StageEnabled("mask-ips", "anonymize") || !StageEnabled("sev", "severity") {
+		t.Error("Expected stages to be disabled by name or type")
+	}
+
+	all := All()
+	if len(all) != 5 || all[LiveTail] || !all[Pipeline] || all[StagePrefix+"geoip"] {
+		t.Errorf("Unexpected flags %v", all)
+	}
+}
+
+func TestKnown(t *testing.T) {
+	for name, want := range map[string]bool{
+		LiveTail:                true,
+		StagePrefix + "geoip":   true,
+		StagePrefix:             false,
+		"pipeline_stages.geoip": false,
+		"live-tail":             false,
+	} {
+		if got := Known(name); got != want {
+			t.Errorf("%s: expected %v, got %v", name, want, got)
+		}
+	}
+}
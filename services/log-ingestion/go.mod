@@ -7,4 +7,40 @@ require (
     github.com/gorilla/mux v1.8.0
     github.com/joho/godotenv v1.4.0
     github.com/google/uuid v1.3.0
+    github.com/oschwald/maxminddb-golang v1.3.1
+    github.com/prometheus/client_golang v1.14.0
+    github.com/segmentio/kafka-go v0.4.47
+    github.com/redis/go-redis/v9 v9.0.5
+    github.com/aws/aws-sdk-go-v2 v1.16.16
+    github.com/aws/aws-sdk-go-v2/config v1.15.9
+    github.com/aws/aws-sdk-go-v2/service/s3 v1.27.11
+    github.com/klauspost/compress v1.15.9
+    github.com/BurntSushi/toml v1.2.1
+    gopkg.in/yaml.v3 v3.0.1
+    github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream v1.4.8 // indirect
+    github.com/aws/aws-sdk-go-v2/credentials v1.12.4 // indirect
+    github.com/aws/aws-sdk-go-v2/feature/ec2/imds v1.12.5 // indirect
+    github.com/aws/aws-sdk-go-v2/internal/configsources v1.1.23 // indirect
+    github.com/aws/aws-sdk-go-v2/internal/endpoints/v2 v2.4.17 // indirect
+    github.com/aws/aws-sdk-go-v2/internal/ini v1.3.12 // indirect
+    github.com/aws/aws-sdk-go-v2/internal/v4a v1.0.14 // indirect
+    github.com/aws/aws-sdk-go-v2/service/internal/accept-encoding v1.9.9 // indirect
+    github.com/aws/aws-sdk-go-v2/service/internal/checksum v1.1.18 // indirect
+    github.com/aws/aws-sdk-go-v2/service/internal/presigned-url v1.9.17 // indirect
+    github.com/aws/aws-sdk-go-v2/service/internal/s3shared v1.13.17 // indirect
+    github.com/aws/aws-sdk-go-v2/service/sso v1.11.7 // indirect
+    github.com/aws/aws-sdk-go-v2/service/sts v1.16.6 // indirect
+    github.com/aws/smithy-go v1.13.3 // indirect
+    github.com/beorn7/perks v1.0.1 // indirect
+    github.com/cespare/xxhash/v2 v2.2.0 // indirect
+    github.com/davecgh/go-spew v1.1.1 // indirect
+    github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+    github.com/golang/protobuf v1.5.2 // indirect
+    github.com/matttproud/golang_protobuf_extensions v1.0.1 // indirect
+    github.com/pierrec/lz4/v4 v4.1.15 // indirect
+    github.com/prometheus/client_model v0.3.0 // indirect
+    github.com/prometheus/common v0.37.0 // indirect
+    github.com/prometheus/procfs v0.8.0 // indirect
+    golang.org/x/sys v0.13.0 // indirect
+    google.golang.org/protobuf v1.28.1 // indirect
 )
\ No newline at end of file
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"log-processing-system/services/log-ingestion/audit"
+	"log-processing-system/services/log-ingestion/models"
+	"log-processing-system/services/log-ingestion/database"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/retention"
+
+	"github.com/gorilla/mux"
+)
+
+// bulkDeleteRequest is the body accepted by HandleBulkDelete
+type bulkDeleteRequest struct {
+	models.LogFilter
+	BatchSize int `json:"batch_size"`
+}
+
+// HandleBulkDelete starts a background delete of all logs matching the
+// filter in the request body and returns the job for progress polling
+func (h *Handler) HandleBulkDelete(deleter *retention.BulkDeleter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := logger.GetRequestID(r.Context())
+
+		var req bulkDeleteRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.log.WithFields(map[string]interface{}{
+				"request_id": requestID,
+				"error":      err.Error(),
+			}).WarnContext(r.Context(), "Failed to decode bulk delete request")
+
+			http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+			return
+		}
+
+		job, err := deleter.Start(req.LogFilter, req.BatchSize)
+		if err != nil {
+			h.log.WithFields(map[string]interface{}{
+				"request_id": requestID,
+				"filter":     req.LogFilter,
+				"error":      err.Error(),
+			}).WarnContext(r.Context(), "Bulk delete request rejected")
+
+			status := http.StatusBadRequest
+			if errors.Is(err, retention.ErrDeleteStopped) {
+				status = http.StatusServiceUnavailable
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		h.log.WithFields(map[string]interface{}{
+			"request_id": requestID,
+			"job_id":     job.ID,
+			"filter":     job.Filter,
+		}).InfoContext(r.Context(), "Bulk delete job accepted")
+		audit.Annotate(r.Context(), "job_id", job.ID)
+		audit.Annotate(r.Context(), "filter", job.Filter)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/admin/logs/delete/"+job.ID)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(job)
+	}
+}
+
+// HandleBulkDeleteStatus reports the progress of a bulk delete job
+func (h *Handler) HandleBulkDeleteStatus(deleter *retention.BulkDeleter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, ok := deleter.Get(mux.Vars(r)["id"])
+		if !ok {
+			http.Error(w, "Delete job not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(job)
+	}
+}
+
+// HandleIntegrityVerify walks the tamper-evident hash chain and reports any breaks
+func (h *Handler) HandleIntegrityVerify(w http.ResponseWriter, r *http.Request) {
+	requestID := logger.GetRequestID(r.Context())
+
+	report, err := database.VerifyLogChain()
+	if err != nil {
+		h.log.WithFields(map[string]interface{}{
+			"request_id": requestID,
+			"error":      err.Error(),
+		}).ErrorContext(r.Context(), "Failed to verify log integrity chain")
+
+		http.Error(w, "Failed to verify log integrity chain", http.StatusInternalServerError)
+		return
+	}
+
+	logEntry := h.log.WithFields(map[string]interface{}{
+		"request_id":  requestID,
+		"checked":     report.Checked,
+		"break_count": report.BreakCount,
+	})
+	if report.Valid {
+		logEntry.InfoContext(r.Context(), "Log integrity chain verified")
+	} else {
+		logEntry.ErrorContext(r.Context(), "Log integrity chain verification found breaks")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
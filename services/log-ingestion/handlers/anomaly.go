@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+	"log-processing-system/services/log-ingestion/anomaly"
+	"log-processing-system/services/log-ingestion/logger"
+)
+
+// defaultAnomalyWindow is how far back alerts are returned when `since` is not given
+const defaultAnomalyWindow = 24 * time.Hour
+
+// HandleVolumeAnomalies returns recent log volume anomalies together with
+// the baselines the detector has learned
+func (h *Handler) HandleVolumeAnomalies(detector *anomaly.Detector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := logger.GetRequestID(r.Context())
+
+		since := time.Now().Add(-defaultAnomalyWindow)
+		if s := r.URL.Query().Get("since"); s != "" {
+			parsed, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				http.Error(w, "Invalid 'since' timestamp: must be RFC3339", http.StatusBadRequest)
+				return
+			}
+			since = parsed
+		}
+
+		alerts := detector.Alerts(since)
+		baselines := detector.Baselines()
+
+		h.log.WithFields(map[string]interface{}{
+			"request_id": requestID,
+			"alerts":     len(alerts),
+			"series":     len(baselines),
+		}).DebugContext(r.Context(), "Volume anomalies retrieved")
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"since":     since,
+			"alerts":    alerts,
+			"baselines": baselines,
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"log-processing-system/services/log-ingestion/audit"
+	"log-processing-system/services/log-ingestion/auth"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/models"
+
+	"github.com/gorilla/mux"
+)
+
+// createdAPIKey is the response to HandleCreateAPIKey: the stored record and
+// the key itself, which is only ever shown here
+type createdAPIKey struct {
+	models.APIKey
+	Key string `json:"key"`
+}
+
+// HandleCreateAPIKey creates an API key with the name, scopes, sources and
+// rate limit in the request body
+func (h *Handler) HandleCreateAPIKey(a *auth.Authenticator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := logger.GetRequestID(r.Context())
+
+		var req models.APIKey
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+			return
+		}
+		if err := req.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		secret, key, err := a.Create(req)
+		if err != nil {
+			h.log.WithFields(map[string]interface{}{
+				"request_id": requestID,
+				"key_name":   req.Name,
+				"error":      err.Error(),
+			}).ErrorContext(r.Context(), "Failed to create API key")
+
+			http.Error(w, "Failed to create API key", http.StatusInternalServerError)
+			return
+		}
+
+		audit.Annotate(r.Context(), "key_id", key.ID)
+		audit.Annotate(r.Context(), "key_name", key.Name)
+		audit.Annotate(r.Context(), "key_prefix", key.Prefix)
+		audit.Annotate(r.Context(), "scopes", key.Scopes)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(createdAPIKey{APIKey: key, Key: secret})
+	}
+}
+
+// HandleListAPIKeys lists the API keys, revoked ones included, without the
+// keys themselves
+func (h *Handler) HandleListAPIKeys(a *auth.Authenticator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keys, err := a.List()
+		if err != nil {
+			h.log.WithFields(map[string]interface{}{
+				"request_id": logger.GetRequestID(r.Context()),
+				"error":      err.Error(),
+			}).ErrorContext(r.Context(), "Failed to list API keys")
+
+			http.Error(w, "Failed to list API keys", http.StatusInternalServerError)
+			return
+		}
+		if keys == nil {
+			keys = []models.APIKey{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"api_keys": keys,
+			"count":    len(keys),
+		})
+	}
+}
+
+// HandleRevokeAPIKey revokes an API key so it no longer authenticates
+func (h *Handler) HandleRevokeAPIKey(a *auth.Authenticator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil || id <= 0 {
+			http.Error(w, "Invalid API key id", http.StatusBadRequest)
+			return
+		}
+
+		err = a.Revoke(id)
+		switch {
+		case errors.Is(err, auth.ErrNotFound):
+			http.Error(w, "API key not found", http.StatusNotFound)
+			return
+		case err != nil:
+			h.log.WithFields(map[string]interface{}{
+				"request_id": logger.GetRequestID(r.Context()),
+				"key_id":     id,
+				"error":      err.Error(),
+			}).ErrorContext(r.Context(), "Failed to revoke API key")
+
+			http.Error(w, "Failed to revoke API key", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// authorizeSource checks that the request's API key may write entries of
+// the entry's source. An entry without a source gets the key's source when
+// the key is restricted to exactly one.
+func (h *Handler) authorizeSource(ctx context.Context, entry *models.Log) error {
+	key, ok := auth.KeyFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	if entry.Source == "" && len(key.Sources) == 1 {
+		entry.Source = key.Sources[0]
+	}
+	if key.AllowsSource(entry.Source) {
+		return nil
+	}
+
+	h.log.WithFields(map[string]interface{}{
+		"request_id": logger.GetRequestID(ctx),
+		"key_id":     key.ID,
+		"log_source": entry.Source,
+	}).WarnContext(ctx, "Log entry source not allowed for API key")
+	return fmt.Errorf("API key may not write entries of source %q", entry.Source)
+}
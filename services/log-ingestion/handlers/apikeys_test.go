@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"log-processing-system/services/log-ingestion/auth"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func TestHandleLogIngestion_SourceNotAllowed(t *testing.T) {
+	h, _ := setupTest()
+	key := models.APIKey{ID: 1, Name: "billing", Scopes: []string{models.ScopeIngest}, Sources: []string{"billing"}}
+	newRequest := func(path, body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader([]byte(body)))
+		return req.WithContext(auth.WithKey(req.Context(), key))
+	}
+
+	rec := httptest.NewRecorder()
+	h.HandleLogIngestion(rec, newRequest("/ingest", `{"message":"login","level":"info","source":"auth"}`))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("Expected status 403, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.HandleBatchIngestion(rec, newRequest("/ingest/batch", `[{"message":"login","level":"info","source":"auth"}]`))
+	var payload struct {
+		Accepted int              `json:"accepted"`
+		Rejected []batchRejection `json:"rejected"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("Expected a JSON payload, got %s", rec.Body.String())
+	}
+	if payload.Accepted != 0 || len(payload.Rejected) != 1 || payload.Rejected[0].Index != 0 {
+		t.Errorf("Expected the entry to be rejected, got %+v", payload)
+	}
+
+	entry := models.Log{Message: "invoice sent", Level: "info"}
+	if err := h.authorizeSource(newRequest("/ingest", "").Context(), &entry); err != nil || entry.Source != "billing" {
+		t.Errorf("Expected an entry without a source to get the key's source, got %q (err %v)", entry.Source, err)
+	}
+}
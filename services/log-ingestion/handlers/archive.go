@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"log-processing-system/services/log-ingestion/archive"
+	"log-processing-system/services/log-ingestion/audit"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/models"
+
+	"github.com/gorilla/mux"
+)
+
+// ReplayArchivedEntry re-ingests an archived entry through the current
+// pipeline, or hands it to the log-processor when a queue is configured. It
+// is the ingest function of the archive replayer.
+func (h *Handler) ReplayArchivedEntry(ctx context.Context, entry *models.Log) error {
+	if h.queue != nil {
+		return h.publishLogEntry(ctx, entry)
+	}
+
+	entries, ingestErr := h.processLogEntry(ctx, logger.GetRequestID(ctx), entry)
+	if ingestErr != nil {
+		return fmt.Errorf("%w: %v", archive.ErrRejected, ingestErr)
+	}
+
+	for _, processed := range entries {
+		if err := h.storeLogEntry(ctx, processed); err != nil {
+			if _, ok := h.deadLetterEntry(ctx, processed, err); !ok {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// HandleArchiveReplay starts a background replay of archived logs selected
+// by the prefix and filter in the request body and returns the job for
+// progress polling
+func (h *Handler) HandleArchiveReplay(replayer *archive.Replayer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := logger.GetRequestID(r.Context())
+
+		var req archive.ReplayRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.log.WithFields(map[string]interface{}{
+				"request_id": requestID,
+				"error":      err.Error(),
+			}).WarnContext(r.Context(), "Failed to decode archive replay request")
+
+			http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+			return
+		}
+
+		job, err := replayer.Start(req)
+		if err != nil {
+			h.log.WithFields(map[string]interface{}{
+				"request_id": requestID,
+				"prefix":     req.Prefix,
+				"filter":     req.LogFilter,
+				"error":      err.Error(),
+			}).WarnContext(r.Context(), "Archive replay request rejected")
+
+			status := http.StatusBadRequest
+			if errors.Is(err, archive.ErrStopped) {
+				status = http.StatusServiceUnavailable
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		h.log.WithFields(map[string]interface{}{
+			"request_id": requestID,
+			"job_id":     job.ID,
+			"prefix":     job.Prefix,
+			"filter":     job.Filter,
+		}).InfoContext(r.Context(), "Archive replay job accepted")
+		audit.Annotate(r.Context(), "job_id", job.ID)
+		audit.Annotate(r.Context(), "prefix", job.Prefix)
+		audit.Annotate(r.Context(), "filter", job.Filter)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/admin/archive/replay/"+job.ID)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(job)
+	}
+}
+
+// HandleArchiveReplayStatus reports the progress of an archive replay job
+func (h *Handler) HandleArchiveReplayStatus(replayer *archive.Replayer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, ok := replayer.Get(mux.Vars(r)["id"])
+		if !ok {
+			http.Error(w, "Replay job not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(job)
+	}
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+	"log-processing-system/services/log-ingestion/audit"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+// defaultAuditLimit caps audit listings that give no limit
+const defaultAuditLimit = 100
+
+// HandleListAuditEvents lists audit events newest first, filtered by the
+// actor, action, since, until (RFC 3339), limit and offset query parameters
+func (h *Handler) HandleListAuditEvents(rec *audit.Recorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := logger.GetRequestID(r.Context())
+		query := r.URL.Query()
+
+		filter := models.AuditFilter{
+			Actor:  query.Get("actor"),
+			Action: query.Get("action"),
+			Limit:  defaultAuditLimit,
+		}
+		var err error
+		if v := query.Get("since"); v != "" {
+			if filter.Since, err = time.Parse(time.RFC3339, v); err != nil {
+				http.Error(w, "Invalid since parameter", http.StatusBadRequest)
+				return
+			}
+		}
+		if v := query.Get("until"); v != "" {
+			if filter.Until, err = time.Parse(time.RFC3339, v); err != nil {
+				http.Error(w, "Invalid until parameter", http.StatusBadRequest)
+				return
+			}
+		}
+		if v := query.Get("limit"); v != "" {
+			if filter.Limit, err = strconv.Atoi(v); err != nil {
+				http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+				return
+			}
+		}
+		if v := query.Get("offset"); v != "" {
+			if filter.Offset, err = strconv.Atoi(v); err != nil {
+				http.Error(w, "Invalid offset parameter", http.StatusBadRequest)
+				return
+			}
+		}
+		if err := filter.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		events, err := rec.List(filter)
+		if err != nil {
+			h.log.WithFields(map[string]interface{}{
+				"request_id": requestID,
+				"filter":     filter,
+				"error":      err.Error(),
+			}).ErrorContext(r.Context(), "Failed to list audit events")
+
+			http.Error(w, "Failed to list audit events", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"events": events,
+			"count":  len(events),
+		})
+	}
+}
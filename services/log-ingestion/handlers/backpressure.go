@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/metrics"
+)
+
+// Buffers whose saturation refuses ingestion requests
+const (
+	resourceWriteQueue        = "write_queue"
+	resourceMaintenanceBuffer = "maintenance_buffer"
+)
+
+// rejectWhenSaturated answers 503 with a Retry-After while the write queue
+// is past its high-water mark, so clients back off instead of piling up
+// requests against a database that cannot keep up, and reports whether it
+// did. Requests admitted before have their entries stored.
+func (h *Handler) rejectWhenSaturated(w http.ResponseWriter, r *http.Request) bool {
+	if h.writes == nil || !h.writes.Saturated() {
+		return false
+	}
+	writeSaturated(w, r, resourceWriteQueue, int64(h.writes.Depth()), int64(h.writes.Capacity()), "entries", h.writes.RetryAfter())
+	return true
+}
+
+// writeSaturated answers a request refused because resource holds used of
+// its limit, in unit
+func writeSaturated(w http.ResponseWriter, r *http.Request, resource string, used, limit int64, unit string, retryAfter time.Duration) {
+	metrics.Saturated(resource)
+
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":              "saturated",
+		"message":             "Ingestion is saturated, retry later",
+		"resource":            resource,
+		"used":                used,
+		"limit":               limit,
+		"unit":                unit,
+		"retry_after_seconds": seconds,
+		"request_id":          logger.GetRequestID(r.Context()),
+	})
+}
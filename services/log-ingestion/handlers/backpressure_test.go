@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/maintenance"
+	"log-processing-system/services/log-ingestion/models"
+	"log-processing-system/services/log-ingestion/writequeue"
+)
+
+// saturatedBody decodes the body of a request refused for saturation
+func saturatedBody(t *testing.T, rec *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("Expected status 503 with a Retry-After, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil || payload["status"] != "saturated" {
+		t.Fatalf("Expected a saturation payload, got %s", rec.Body.String())
+	}
+	return payload
+}
+
+func TestHandleLogIngestion_WriteQueueSaturated(t *testing.T) {
+	h, _ := setupTest()
+	release := make(chan struct{})
+	q := writequeue.New(writequeue.Config{Size: 2, Workers: 1, HighWater: 0.5, RetryAfter: 3 * time.Second},
+		func(ctx context.Context, entry *models.Log) error {
+			<-release
+			return nil
+		})
+	h.SetWriteQueue(q)
+	defer q.Close(context.Background())
+	defer close(release)
+
+	body := []byte(`{"message":"slow database","level":"info","source":"api"}`)
+	// The worker holds the first entry and the second waits, at the high-water mark
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		h.HandleLogIngestion(rec, httptest.NewRequest(http.MethodPost, "/ingest", bytes.NewReader(body)))
+		if rec.Code != http.StatusAccepted {
+			t.Fatalf("Expected status 202, got %d: %s", rec.Code, rec.Body.String())
+		}
+		for i == 0 && q.Depth() != 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.HandleLogIngestion(rec, httptest.NewRequest(http.MethodPost, "/ingest", bytes.NewReader(body)))
+	payload := saturatedBody(t, rec)
+	if payload["resource"] != "write_queue" || payload["used"] != float64(1) || payload["limit"] != float64(2) || rec.Header().Get("Retry-After") != "3" {
+		t.Errorf("Expected the write queue reported with a Retry-After of 3, got %v, %q", payload, rec.Header().Get("Retry-After"))
+	}
+
+	rec = httptest.NewRecorder()
+	h.HandleBatchIngestion(rec, httptest.NewRequest(http.MethodPost, "/ingest/batch", bytes.NewReader(append(append([]byte("["), body...), ']'))))
+	saturatedBody(t, rec)
+}
+
+func TestHandleLogIngestion_MaintenanceBufferFull(t *testing.T) {
+	h, _ := setupTest()
+	s, err := maintenance.New(maintenance.Config{
+		BufferFile: filepath.Join(t.TempDir(), "buffer.jsonl"),
+		MaxBytes:   1,
+	}, h.StoreBufferedLog, logger.New(logger.Config{Service: "test-service", Component: "maintenance"}))
+	if err != nil {
+		t.Fatalf("Failed to create switch: %v", err)
+	}
+	h.SetMaintenance(s)
+	s.Enable(maintenance.Buffer, "failover")
+
+	body := []byte(`{"message":"during failover","level":"info","source":"api"}`)
+	rec := httptest.NewRecorder()
+	h.HandleLogIngestion(rec, httptest.NewRequest(http.MethodPost, "/ingest", bytes.NewReader(body)))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected the first entry buffered, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.HandleLogIngestion(rec, httptest.NewRequest(http.MethodPost, "/ingest", bytes.NewReader(body)))
+	payload := saturatedBody(t, rec)
+	if payload["resource"] != "maintenance_buffer" || payload["unit"] != "bytes" || payload["limit"] != float64(1) {
+		t.Errorf("Expected the maintenance buffer reported, got %v", payload)
+	}
+}
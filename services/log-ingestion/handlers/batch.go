@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/metrics"
+	"log-processing-system/services/log-ingestion/throttle"
+)
+
+// maxBatchEntries caps the entries accepted in one batch request
+const maxBatchEntries = 1000
+
+// batchRejection reports an entry of a batch that was not ingested
+type batchRejection struct {
+	Index             int    `json:"index"`
+	Error             string `json:"error"`
+	DeadLetterID      int64  `json:"dead_letter_id,omitempty"`
+	RetryAfterSeconds int    `json:"retry_after_seconds,omitempty"`
+	// Retryable marks an entry that could not be stored or queued and was
+	// not dead-lettered, which the sender should send again
+	Retryable bool `json:"retryable,omitempty"`
+}
+
+// HandleBatchIngestion ingests a JSON array of entries, each in the format
+// accepted by /ingest, as used by the http_forward sink of an edge instance.
+// Entries that fail parsing, the pipeline or validation are reported by
+// index while the rest are stored. Entries over their source's ingest budget
+// are sampled out or rejected with a retry hint; when that rejects the whole
+// batch the request fails with 429 so the sender backs off. Entries that
+// cannot be stored or queued are dead-lettered, or else reported as
+// retryable, and the request answers 207 so the sender resends only those;
+// when every entry failed to store it fails with 503 and the batch can be
+// sent again as is. Entries handed to the write queue are stored after the
+// response, and dead-lettered when that fails. While the write queue or
+// maintenance buffer is full, requests are refused with 503 and a
+// Retry-After, see rejectWhenSaturated. Entries carrying the self_source
+// marker silence the request's logging, see guardSelfLog.
+func (h *Handler) HandleBatchIngestion(w http.ResponseWriter, r *http.Request) {
+	requestID := logger.GetRequestID(r.Context())
+	// Storing the entries observes the ingest latency from here
+	r = r.WithContext(metrics.WithReceived(r.Context(), time.Now()))
+
+	if h.rejectForMaintenance(w, r) {
+		return
+	}
+	if h.rejectWhenSaturated(w, r) {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(body, &items); err != nil {
+		h.log.WithFields(map[string]interface{}{
+			"request_id": requestID,
+			"error":      err.Error(),
+		}).WarnContext(r.Context(), "Failed to decode batch request body")
+
+		http.Error(w, "Invalid JSON format: expected an array of log entries", http.StatusBadRequest)
+		return
+	}
+	if len(items) == 0 {
+		http.Error(w, "Batch must contain at least one log entry", http.StatusBadRequest)
+		return
+	}
+	if len(items) > maxBatchEntries {
+		http.Error(w, "Batch exceeds the maximum of 1000 log entries", http.StatusRequestEntityTooLarge)
+		return
+	}
+	metrics.Batch(len(items))
+
+	accepted, sampled, throttled, failed := 0, 0, 0, 0
+	rejected := []batchRejection{}
+	for i, item := range items {
+		rejection, sampledOut := h.ingestBatchItem(r.Context(), requestID, i, item)
+		if sampledOut {
+			sampled++
+			continue
+		}
+		if rejection != nil {
+			if rejection.RetryAfterSeconds > 0 {
+				throttled++
+			}
+			if rejection.Retryable {
+				failed++
+			}
+			rejected = append(rejected, *rejection)
+			continue
+		}
+		accepted++
+	}
+
+	if failed > 0 {
+		h.log.WithFields(map[string]interface{}{
+			"request_id": requestID,
+			"entries":    len(items),
+			"accepted":   accepted,
+			"failed":     failed,
+		}).ErrorContext(r.Context(), "Failed to store log batch entries")
+	}
+	if failed == len(items) {
+		http.Error(w, "Failed to store log entries", http.StatusServiceUnavailable)
+		return
+	}
+
+	if throttled == len(items) {
+		retryAfter := 0
+		for _, rejection := range rejected {
+			if rejection.RetryAfterSeconds > retryAfter {
+				retryAfter = rejection.RetryAfterSeconds
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":              "throttled",
+			"message":             "Every entry exceeded its source's ingest budget",
+			"rejected":            rejected,
+			"retry_after_seconds": retryAfter,
+			"request_id":          requestID,
+		})
+		return
+	}
+
+	h.log.WithFields(map[string]interface{}{
+		"request_id": requestID,
+		"entries":    len(items),
+		"accepted":   accepted,
+		"sampled":    sampled,
+		"rejected":   len(rejected),
+	}).InfoContext(r.Context(), "Log batch ingested")
+
+	status, code := "accepted", http.StatusAccepted
+	if failed > 0 {
+		status, code = "partial", http.StatusMultiStatus
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":     status,
+		"accepted":   accepted,
+		"sampled":    sampled,
+		"rejected":   rejected,
+		"request_id": requestID,
+	})
+}
+
+// ingestBatchItem ingests one entry of a batch. It returns a rejection for an
+// entry that is invalid, of a source its API key may not write, throttled,
+// dead-lettered or that could not be queued or stored, and whether the entry
+// was sampled out.
+func (h *Handler) ingestBatchItem(ctx context.Context, requestID string, index int, item []byte) (*batchRejection, bool) {
+	logEntry, ingestErr := h.parseLogEntry(ctx, requestID, item)
+	if ingestErr == nil {
+		var drop bool
+		// Self logs dropped by policy count as sampled out
+		if ctx, drop = h.guardSelfLog(ctx, &logEntry); drop {
+			return nil, true
+		}
+		if err := h.authorizeSource(ctx, &logEntry); err != nil {
+			return &batchRejection{Index: index, Error: err.Error()}, false
+		}
+		switch decision := h.throttleEntry(ctx, &logEntry); decision.Action {
+		case throttle.Sample:
+			return nil, true
+		case throttle.Reject:
+			return &batchRejection{
+				Index:             index,
+				Error:             throttledMessage(logEntry.Source, decision),
+				RetryAfterSeconds: int(math.Ceil(decision.RetryAfter.Seconds())),
+			}, false
+		}
+		metrics.Accepted(logEntry.Source)
+	}
+	if ingestErr == nil && h.queue != nil {
+		if err := h.publishLogEntry(ctx, &logEntry); err != nil {
+			h.log.WithFields(map[string]interface{}{
+				"request_id": requestID,
+				"error":      err.Error(),
+				"index":      index,
+				"log_source": logEntry.Source,
+			}).ErrorContext(ctx, "Failed to queue log entry")
+			return &batchRejection{Index: index, Error: "Failed to queue log entry", Retryable: true}, false
+		}
+		return nil, false
+	}
+
+	var entries []*models.Log
+	if ingestErr == nil {
+		entries, ingestErr = h.processLogEntry(ctx, requestID, &logEntry)
+	}
+	if ingestErr != nil {
+		rejection := &batchRejection{Index: index, Error: ingestErr.message}
+		if h.deadLetters != nil {
+			if letter, err := h.deadLetters.Add(ctx, ingestErr.reason, logEntry.Source, item, ingestErr.err); err == nil {
+				rejection.DeadLetterID = letter.ID
+			}
+		}
+		return rejection, false
+	}
+
+	var rejection *batchRejection
+	for _, entry := range entries {
+		if h.queueLogEntry(ctx, entry) {
+			continue
+		}
+		if err := h.storeLogEntry(ctx, entry); err != nil {
+			id, ok := h.deadLetterEntry(ctx, entry, err)
+			h.log.WithFields(map[string]interface{}{
+				"request_id":    requestID,
+				"error":         err.Error(),
+				"index":         index,
+				"log_source":    entry.Source,
+				"dead_lettered": ok,
+			}).ErrorContext(ctx, "Failed to store log entry")
+			if !ok {
+				// Entries the pipeline split this one into that were stored
+				// are stored again if the sender resends it
+				return &batchRejection{Index: index, Error: "Failed to store log entry", Retryable: true}, false
+			}
+			rejection = &batchRejection{Index: index, Error: "Failed to store log entry", DeadLetterID: id}
+		}
+	}
+	return rejection, false
+}
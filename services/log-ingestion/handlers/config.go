@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"log-processing-system/services/log-ingestion/config"
+	"log-processing-system/services/log-ingestion/features"
+)
+
+// HandleGetConfig returns the configuration the service is running with,
+// secrets masked
+func (h *Handler) HandleGetConfig(cfg *config.Config) http.HandlerFunc {
+	effective := cfg.Effective()
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(effective)
+	}
+}
+
+// HandleListFeatures returns the feature flags and whether each is enabled
+func (h *Handler) HandleListFeatures(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"features": features.All(),
+	})
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"log-processing-system/services/log-ingestion/audit"
+	"log-processing-system/services/log-ingestion/deadletter"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/models"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultDeadLetterLimit caps listings and bulk replays that give no limit
+const defaultDeadLetterLimit = 100
+
+// HandleListDeadLetters lists dead letters, filtered by the reason, source,
+// include_replayed, limit and offset query parameters
+func (h *Handler) HandleListDeadLetters(q *deadletter.Queue) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := logger.GetRequestID(r.Context())
+		query := r.URL.Query()
+
+		filter := models.DeadLetterFilter{
+			Reason: query.Get("reason"),
+			Source: query.Get("source"),
+			Limit:  defaultDeadLetterLimit,
+		}
+		var err error
+		if v := query.Get("include_replayed"); v != "" {
+			if filter.IncludeReplayed, err = strconv.ParseBool(v); err != nil {
+				http.Error(w, "Invalid include_replayed parameter", http.StatusBadRequest)
+				return
+			}
+		}
+		if v := query.Get("limit"); v != "" {
+			if filter.Limit, err = strconv.Atoi(v); err != nil {
+				http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+				return
+			}
+		}
+		if v := query.Get("offset"); v != "" {
+			if filter.Offset, err = strconv.Atoi(v); err != nil {
+				http.Error(w, "Invalid offset parameter", http.StatusBadRequest)
+				return
+			}
+		}
+		if err := filter.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		letters, err := q.List(filter)
+		if err != nil {
+			h.log.WithFields(map[string]interface{}{
+				"request_id": requestID,
+				"filter":     filter,
+				"error":      err.Error(),
+			}).ErrorContext(r.Context(), "Failed to list dead letters")
+
+			http.Error(w, "Failed to list dead letters", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"dead_letters": letters,
+			"count":        len(letters),
+		})
+	}
+}
+
+// HandleGetDeadLetter returns one dead letter with its payload
+func (h *Handler) HandleGetDeadLetter(q *deadletter.Queue) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := deadLetterID(w, r)
+		if !ok {
+			return
+		}
+
+		letter, err := q.Get(id)
+		if err != nil {
+			h.writeDeadLetterError(w, r, id, err, "Failed to read dead letter")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(letter)
+	}
+}
+
+// HandleDeleteDeadLetter discards a dead letter that should not be replayed
+func (h *Handler) HandleDeleteDeadLetter(q *deadletter.Queue) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := deadLetterID(w, r)
+		if !ok {
+			return
+		}
+
+		if err := q.Delete(id); err != nil {
+			h.writeDeadLetterError(w, r, id, err, "Failed to delete dead letter")
+			return
+		}
+
+		h.log.WithFields(map[string]interface{}{
+			"request_id":     logger.GetRequestID(r.Context()),
+			"dead_letter_id": id,
+		}).InfoContext(r.Context(), "Dead letter deleted")
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// HandleReplayDeadLetter re-ingests one dead letter. A replay that fails
+// again answers 422 with the dead letter and its new error.
+func (h *Handler) HandleReplayDeadLetter(q *deadletter.Queue) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := deadLetterID(w, r)
+		if !ok {
+			return
+		}
+
+		letter, err := q.Replay(r.Context(), id, h.replayDeadLetter)
+		switch {
+		case errors.Is(err, deadletter.ErrReplayFailed):
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			json.NewEncoder(w).Encode(letter)
+			return
+		case err != nil:
+			h.writeDeadLetterError(w, r, id, err, "Failed to replay dead letter")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(letter)
+	}
+}
+
+// HandleReplayDeadLetters re-ingests the pending dead letters matching the
+// filter in the request body, at most 100 unless a limit is given
+func (h *Handler) HandleReplayDeadLetters(q *deadletter.Queue) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := logger.GetRequestID(r.Context())
+
+		filter := models.DeadLetterFilter{Limit: defaultDeadLetterLimit}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&filter); err != nil {
+				http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+				return
+			}
+		}
+		if err := filter.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		results, err := q.ReplayAll(r.Context(), filter, h.replayDeadLetter)
+		if err != nil && results == nil {
+			h.log.WithFields(map[string]interface{}{
+				"request_id": requestID,
+				"filter":     filter,
+				"error":      err.Error(),
+			}).ErrorContext(r.Context(), "Failed to replay dead letters")
+
+			http.Error(w, "Failed to replay dead letters", http.StatusInternalServerError)
+			return
+		}
+
+		replayed := 0
+		for _, result := range results {
+			if result.Replayed {
+				replayed++
+			}
+		}
+
+		h.log.WithFields(map[string]interface{}{
+			"request_id": requestID,
+			"filter":     filter,
+			"replayed":   replayed,
+			"failed":     len(results) - replayed,
+		}).InfoContext(r.Context(), "Dead letters replayed")
+		audit.Annotate(r.Context(), "filter", filter)
+		audit.Annotate(r.Context(), "replayed", replayed)
+		audit.Annotate(r.Context(), "failed", len(results)-replayed)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"results":  results,
+			"replayed": replayed,
+			"failed":   len(results) - replayed,
+		})
+	}
+}
+
+// deadLetterID parses the id route variable, answering 400 if it is invalid
+func deadLetterID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, "Invalid dead letter id", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// writeDeadLetterError maps a dead-letter queue error to a response
+func (h *Handler) writeDeadLetterError(w http.ResponseWriter, r *http.Request, id int64, err error, message string) {
+	switch {
+	case errors.Is(err, deadletter.ErrNotFound):
+		http.Error(w, "Dead letter not found", http.StatusNotFound)
+	case errors.Is(err, deadletter.ErrAlreadyReplayed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		h.log.WithFields(map[string]interface{}{
+			"request_id":     logger.GetRequestID(r.Context()),
+			"dead_letter_id": id,
+			"error":          err.Error(),
+		}).ErrorContext(r.Context(), message)
+
+		http.Error(w, message, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+	"log-processing-system/services/log-ingestion/deadletter"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/maintenance"
+	"log-processing-system/services/log-ingestion/models"
+	"log-processing-system/services/log-ingestion/pipeline"
+	"log-processing-system/services/log-ingestion/querycache"
+	"log-processing-system/services/log-ingestion/queue"
+	"log-processing-system/services/log-ingestion/throttle"
+	"log-processing-system/services/log-ingestion/writequeue"
+)
+
+// Store is where the ingestion handlers write entries; database.Postgres
+// is the production one
+type Store interface {
+	// StoreLog stores an entry. It should finish even when ctx is done, so
+	// an accepted entry is stored if its client goes away.
+	StoreLog(ctx context.Context, entry models.Log) error
+	// Ping checks that the store can be written to
+	Ping(ctx context.Context) error
+}
+
+// Handler serves the HTTP API of the service. The optional stages entries
+// pass through are installed with the Set methods before it serves
+// requests; unset ones are skipped.
+type Handler struct {
+	store Store
+	log   *logger.Logger
+
+	// pipeline processes entries between ingestion and storage
+	pipeline *pipeline.Pipeline
+	// queue hands parsed entries to the log-processor service, which then
+	// processes and stores them instead of this one
+	queue queue.Publisher
+	// writes stores validated entries after the response was sent
+	writes *writequeue.Queue
+	// deadLetters records submissions that could not be ingested
+	deadLetters *deadletter.Queue
+	// maintenance puts ingestion into maintenance mode; nil never does
+	maintenance *maintenance.Switch
+	// limiter enforces per-source ingest budgets
+	limiter *throttle.Limiter
+	// queryCache answers repeated bounded queries and aggregations
+	queryCache querycache.Cache
+	// dropSelfLogs discards the system's own logs instead of storing them
+	dropSelfLogs bool
+}
+
+// New returns a Handler storing entries in store and logging to log
+func New(store Store, log *logger.Logger) *Handler {
+	return &Handler{store: store, log: log}
+}
@@ -1,144 +1,491 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"time"
 	"log-processing-system/services/log-ingestion/models"
-	"log-processing-system/services/log-ingestion/database"
+	"log-processing-system/services/log-ingestion/deadletter"
 	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/metrics"
+	"log-processing-system/services/log-ingestion/pipeline"
+	"log-processing-system/services/log-ingestion/queue"
+	"log-processing-system/services/log-ingestion/throttle"
 )
 
-var handlerLogger = logger.NewFromEnv("log-ingestion", "handlers")
+// SetPipeline installs the processing pipeline used by the ingestion handlers
+func (h *Handler) SetPipeline(p *pipeline.Pipeline) {
+	h.pipeline = p
+}
+
+// SetQueue makes the ingestion handlers publish entries to the queue instead
+// of processing and storing them
+func (h *Handler) SetQueue(p queue.Publisher) {
+	h.queue = p
+}
+
+// rawFieldsAccepted reports whether entries may arrive without a message and
+// keep their extra top-level keys, for a pipeline here or in log-processor
+func (h *Handler) rawFieldsAccepted() bool {
+	return h.pipeline != nil || h.queue != nil
+}
+
+// SetDeadLetterQueue installs the queue failed submissions are recorded in
+func (h *Handler) SetDeadLetterQueue(q *deadletter.Queue) {
+	h.deadLetters = q
+}
+
+// ingestError is a failed submission with its dead-letter reason and the
+// response sent when it is not dead-lettered
+type ingestError struct {
+	reason  string
+	status  int
+	message string
+	err     error
+}
+
+func (e *ingestError) Error() string {
+	return e.err.Error()
+}
+
+// StoreFlushedLogs validates and stores entries the pipeline released outside
+// of a request, such as summaries of grouped repeats
+func (h *Handler) StoreFlushedLogs(ctx context.Context, entries []*models.Log) {
+	for _, entry := range entries {
+		if err := entry.Validate(); err != nil {
+			h.log.WithFields(map[string]interface{}{
+				"validation_error": err.Error(),
+				"log_entry":        entry,
+			}).WarnContext(ctx, "Flushed log entry validation failed")
+			continue
+		}
+
+		if err := h.storeLogEntry(ctx, entry); err != nil {
+			h.log.WithFields(map[string]interface{}{
+				"error":     err.Error(),
+				"log_entry": entry,
+			}).ErrorContext(ctx, "Failed to store flushed log entry in database")
+			h.deadLetterEntry(ctx, entry, err)
+			continue
+		}
+	}
+
+	h.log.WithField("entries", len(entries)).DebugContext(ctx, "Flushed log entries stored")
+}
+
+// collectExtraFields moves the top-level keys of body that are not part of
+// the log envelope into the entry's fields, where pipeline stages can see
+// them. Keys already present in fields win.
+func collectExtraFields(body []byte, entry *models.Log) error {
+	var rawData map[string]json.RawMessage
+	if err := json.Unmarshal(body, &rawData); err != nil {
+		return err
+	}
+	for key, raw := range rawData {
+		switch key {
+		case "id", "message", "level", "timestamp", "source", "fields":
+			continue
+		}
+		if _, exists := entry.Fields[key]; exists {
+			continue
+		}
+		var value interface{}
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return err
+		}
+		if entry.Fields == nil {
+			entry.Fields = make(models.Fields)
+		}
+		entry.Fields[key] = value
+	}
+	return nil
+}
 
-func HandleLogIngestion(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) HandleLogIngestion(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 	requestID := logger.GetRequestID(r.Context())
+	// Storing the entry observes the ingest latency from here
+	r = r.WithContext(metrics.WithReceived(r.Context(), start))
 	
-	handlerLogger.WithFields(map[string]interface{}{
+	h.log.WithFields(map[string]interface{}{
 		"request_id":    requestID,
 		"content_type":  r.Header.Get("Content-Type"),
 		"content_length": r.ContentLength,
 	}).InfoContext(r.Context(), "Processing log ingestion request")
 
-	// Read the request body
-	var rawData map[string]interface{}
-	
-	if err := json.NewDecoder(r.Body).Decode(&rawData); err != nil {
-		handlerLogger.WithFields(map[string]interface{}{
+	if h.rejectForMaintenance(w, r) {
+		return
+	}
+	if h.rejectWhenSaturated(w, r) {
+		return
+	}
+
+	// Read the request body; it is kept as received for the dead-letter queue
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.log.WithFields(map[string]interface{}{
 			"request_id": requestID,
 			"error":      err.Error(),
-		}).WarnContext(r.Context(), "Failed to decode JSON request body")
-		
-		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		}).WarnContext(r.Context(), "Failed to read request body")
+
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
 		return
 	}
 
-	var logEntry models.Log
+	logEntry, ingestErr := h.parseLogEntry(r.Context(), requestID, body)
+	if ingestErr != nil {
+		h.rejectSubmission(w, r, ingestErr, "", body)
+		return
+	}
 
-	// Check if this is the new structured format or legacy format
-	if message, hasMessage := rawData["message"]; hasMessage {
-		// New structured format
-		handlerLogger.WithField("request_id", requestID).DebugContext(r.Context(), "Processing structured log format")
-		
-		logData, _ := json.Marshal(rawData)
-		if err := json.Unmarshal(logData, &logEntry); err != nil {
-			handlerLogger.WithFields(map[string]interface{}{
-				"request_id": requestID,
-				"error":      err.Error(),
-				"raw_data":   rawData,
-			}).WarnContext(r.Context(), "Failed to unmarshal structured log entry")
-			
-			http.Error(w, "Invalid structured log entry", http.StatusBadRequest)
-			return
-		}
-	} else if logText, hasLog := rawData["log"]; hasLog {
-		// Legacy format - convert to structured format
-		handlerLogger.WithField("request_id", requestID).DebugContext(r.Context(), "Processing legacy log format")
-		
-		logEntry = models.Log{
-			Message:   logText.(string),
-			Level:     "info", // default level for legacy entries
-			Timestamp: time.Now(),
-			Source:    "legacy_api",
-		}
-		
-		handlerLogger.WithFields(map[string]interface{}{
-			"request_id":    requestID,
-			"message_length": len(logEntry.Message),
-			"source":        logEntry.Source,
-		}).InfoContext(r.Context(), "Converted legacy log entry to structured format")
-	} else {
-		handlerLogger.WithFields(map[string]interface{}{
+	selfCtx, drop := h.guardSelfLog(r.Context(), &logEntry)
+	r = r.WithContext(selfCtx)
+	if drop {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{
+			"status":     "dropped",
+			"message":    "Self log dropped",
 			"request_id": requestID,
-			"raw_data":   rawData,
-		}).WarnContext(r.Context(), "Request missing required fields")
-		
-		http.Error(w, "Missing required fields: either 'message' or 'log' field required", http.StatusBadRequest)
+		})
 		return
 	}
 
-	// Validate the log entry
-	if err := logEntry.Validate(); err != nil {
-		handlerLogger.WithFields(map[string]interface{}{
-			"request_id":     requestID,
-			"validation_error": err.Error(),
-			"log_entry":      logEntry,
-		}).WarnContext(r.Context(), "Log entry validation failed")
-		
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := h.authorizeSource(r.Context(), &logEntry); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if decision := h.throttleEntry(r.Context(), &logEntry); decision.Action != throttle.Accept {
+		writeThrottled(w, requestID, logEntry.Source, decision)
+		return
+	}
+	metrics.Accepted(logEntry.Source)
+
+	// The log-processor service runs the pipeline and stores the entry
+	if h.queue != nil {
+		h.enqueueLogEntry(w, r, &logEntry)
+		return
+	}
+
+	entries, ingestErr := h.processLogEntry(r.Context(), requestID, &logEntry)
+	if ingestErr != nil {
+		h.rejectSubmission(w, r, ingestErr, logEntry.Source, body)
+		return
+	}
+
+	if len(entries) == 0 {
+		h.log.WithFields(map[string]interface{}{
+			"request_id": requestID,
+			"log_source": logEntry.Source,
+		}).DebugContext(r.Context(), "Log entry held or dropped by processing pipeline")
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{
+			"status":     "accepted",
+			"message":    "Log entry held or dropped by processing pipeline",
+			"request_id": requestID,
+		})
 		return
 	}
 
-	// Store the log entry in the database
-	dbStart := time.Now()
-	if err := database.StoreLog(logEntry); err != nil {
+	// Store the log entries in the database, or have the write queue store
+	// them after responding
+	var deadLettered []int64
+	queued := 0
+	for _, entry := range entries {
+		if h.queueLogEntry(r.Context(), entry) {
+			queued++
+			continue
+		}
+		dbStart := time.Now()
+		if err := h.storeLogEntry(r.Context(), entry); err != nil {
+			dbDuration := time.Since(dbStart)
+
+			h.log.WithFields(map[string]interface{}{
+				"request_id":    requestID,
+				"error":         err.Error(),
+				"log_entry":     entry,
+				"db_duration_ms": dbDuration.Milliseconds(),
+			}).ErrorContext(r.Context(), "Failed to store log entry in database")
+
+			// Keep the processed entry so a replay can store it directly
+			id, ok := h.deadLetterEntry(r.Context(), entry, err)
+			if !ok {
+				http.Error(w, "Failed to store log entry", http.StatusInternalServerError)
+				return
+			}
+			deadLettered = append(deadLettered, id)
+			continue
+		}
 		dbDuration := time.Since(dbStart)
-		
-		handlerLogger.WithFields(map[string]interface{}{
-			"request_id":    requestID,
-			"error":         err.Error(),
-			"log_entry":     logEntry,
+
+		// Log successful storage
+		h.log.WithFields(map[string]interface{}{
+			"request_id":     requestID,
+			"log_level":      entry.Level,
+			"log_source":     entry.Source,
+			"message_length": len(entry.Message),
 			"db_duration_ms": dbDuration.Milliseconds(),
-		}).ErrorContext(r.Context(), "Failed to store log entry in database")
-		
-		http.Error(w, "Failed to store log entry", http.StatusInternalServerError)
+			"total_duration_ms": time.Since(start).Milliseconds(),
+		}).InfoContext(r.Context(), "Log entry stored successfully")
+
+		// Log business event
+		h.log.LogBusinessEvent("log_ingested", requestID, map[string]interface{}{
+			"log_level":  entry.Level,
+			"log_source": entry.Source,
+			"timestamp":  entry.Timestamp,
+		})
+	}
+
+	if len(deadLettered) > 0 {
+		writeDeadLettered(w, requestID, "Failed to store log entry", deadLettered)
 		return
 	}
-	dbDuration := time.Since(dbStart)
-
-	// Log successful storage
-	handlerLogger.WithFields(map[string]interface{}{
-		"request_id":     requestID,
-		"log_level":      logEntry.Level,
-		"log_source":     logEntry.Source,
-		"message_length": len(logEntry.Message),
-		"db_duration_ms": dbDuration.Milliseconds(),
-		"total_duration_ms": time.Since(start).Milliseconds(),
-	}).InfoContext(r.Context(), "Log entry stored successfully")
-
-	// Log business event
-	handlerLogger.LogBusinessEvent("log_ingested", requestID, map[string]interface{}{
-		"log_level":  logEntry.Level,
-		"log_source": logEntry.Source,
-		"timestamp":  logEntry.Timestamp,
+
+	status, message := "accepted", "Log entry stored successfully"
+	if queued > 0 {
+		message = "Log entry accepted for storage"
+	}
+	if h.maintenance.Buffering() {
+		status, message = "buffered", "Log entry buffered during maintenance, to be stored afterwards"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":     status,
+		"message":    message,
+		"request_id": requestID,
 	})
+}
+
+// enqueueLogEntry publishes a parsed entry for the log-processor service,
+// keyed by source so each source's entries stay in order
+func (h *Handler) enqueueLogEntry(w http.ResponseWriter, r *http.Request, logEntry *models.Log) {
+	requestID := logger.GetRequestID(r.Context())
+
+	if err := h.publishLogEntry(r.Context(), logEntry); err != nil {
+		h.log.WithFields(map[string]interface{}{
+			"request_id": requestID,
+			"error":      err.Error(),
+			"log_source": logEntry.Source,
+		}).ErrorContext(r.Context(), "Failed to queue log entry")
+
+		http.Error(w, "Failed to queue log entry", http.StatusServiceUnavailable)
+		return
+	}
+
+	h.log.WithFields(map[string]interface{}{
+		"request_id": requestID,
+		"log_source": logEntry.Source,
+	}).DebugContext(r.Context(), "Log entry queued for processing")
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
 	json.NewEncoder(w).Encode(map[string]string{
-		"status":     "accepted", 
-		"message":    "Log entry stored successfully",
+		"status":     "queued",
+		"message":    "Log entry queued for processing",
 		"request_id": requestID,
 	})
 }
 
-func HandleHealthCheck(w http.ResponseWriter, r *http.Request) {
+// publishLogEntry hands an entry to the queue, keyed by source
+func (h *Handler) publishLogEntry(ctx context.Context, logEntry *models.Log) error {
+	data, _ := json.Marshal(logEntry)
+	return h.queue.Publish(ctx, queue.Message{Key: logEntry.Source, Value: data, Received: metrics.Received(ctx)})
+}
+
+// parseLogEntry decodes a request body in the structured or legacy format
+func (h *Handler) parseLogEntry(ctx context.Context, requestID string, body []byte) (models.Log, *ingestError) {
+	logEntry, format, err := models.DecodeLog(body)
+	if err != nil {
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) && typeErr.Field == "" {
+			h.log.WithFields(map[string]interface{}{
+				"request_id": requestID,
+				"error":      err.Error(),
+			}).WarnContext(ctx, "Failed to decode JSON request body")
+
+			return models.Log{}, &ingestError{models.DeadLetterParse, http.StatusBadRequest, "Invalid JSON format", err}
+		}
+
+		h.log.WithFields(map[string]interface{}{
+			"request_id": requestID,
+			"error":      err.Error(),
+		}).WarnContext(ctx, "Failed to unmarshal structured log entry")
+
+		return models.Log{}, &ingestError{models.DeadLetterParse, http.StatusBadRequest, "Invalid structured log entry", err}
+	}
+
+	// With a pipeline configured, entries without a message are also
+	// accepted so that a mapping stage can rename the producer's own keys
+	switch {
+	case format == models.LegacyLog:
+		h.log.WithFields(map[string]interface{}{
+			"request_id":     requestID,
+			"message_length": len(logEntry.Message),
+			"source":         logEntry.Source,
+		}).InfoContext(ctx, "Converted legacy log entry to structured format")
+	case format == models.StructuredLog || h.rawFieldsAccepted():
+		h.log.WithField("request_id", requestID).DebugContext(ctx, "Processing structured log format")
+
+		if h.rawFieldsAccepted() {
+			if err := collectExtraFields(body, &logEntry); err != nil {
+				return models.Log{}, &ingestError{models.DeadLetterParse, http.StatusBadRequest, "Invalid structured log entry", err}
+			}
+		}
+	default:
+		h.log.WithField("request_id", requestID).WarnContext(ctx, "Request missing required fields")
+
+		message := "Missing required fields: either 'message' or 'log' field required"
+		return models.Log{}, &ingestError{models.DeadLetterParse, http.StatusBadRequest, message, errors.New(message)}
+	}
+
+	return logEntry, nil
+}
+
+// processLogEntry runs the processing pipeline, which may rewrite, split or
+// drop the entry, and validates the entries that come out of it
+func (h *Handler) processLogEntry(ctx context.Context, requestID string, logEntry *models.Log) ([]*models.Log, *ingestError) {
+	entries := []*models.Log{logEntry}
+	if h.pipeline != nil {
+		processed, err := h.pipeline.Process(ctx, logEntry)
+		if err != nil {
+			h.log.WithFields(map[string]interface{}{
+				"request_id": requestID,
+				"error":      err.Error(),
+				"log_entry":  logEntry,
+			}).WarnContext(ctx, "Log entry rejected by processing pipeline")
+
+			return nil, &ingestError{models.DeadLetterPipeline, http.StatusUnprocessableEntity, err.Error(), err}
+		}
+		entries = processed
+	}
+
+	// Validate the log entries
+	for _, entry := range entries {
+		if err := entry.Validate(); err != nil {
+			h.log.WithFields(map[string]interface{}{
+				"request_id":     requestID,
+				"validation_error": err.Error(),
+				"log_entry":      entry,
+			}).WarnContext(ctx, "Log entry validation failed")
+
+			return nil, &ingestError{models.DeadLetterValidation, http.StatusBadRequest, err.Error(), err}
+		}
+	}
+
+	return entries, nil
+}
+
+// storeLogEntry stores an entry; with the dead-letter queue enabled failed
+// writes are retried before the entry is given up on. During maintenance in
+// buffer mode the entry is buffered instead, and stored afterwards.
+func (h *Handler) storeLogEntry(ctx context.Context, entry *models.Log) error {
+	if buffered, err := h.maintenance.Add(entry); buffered || err != nil {
+		return err
+	}
+
+	var err error
+	if h.deadLetters == nil {
+		err = h.store.StoreLog(ctx, *entry)
+	} else {
+		err = h.deadLetters.Retry(ctx, func() error {
+			return h.store.StoreLog(ctx, *entry)
+		})
+	}
+	if err == nil {
+		metrics.Stored(ctx, entry.Source)
+	}
+	return err
+}
+
+// deadLetterEntry records a processed entry that could not be stored. It
+// returns false if the dead-letter queue is disabled or the record failed.
+func (h *Handler) deadLetterEntry(ctx context.Context, entry *models.Log, cause error) (int64, bool) {
+	if h.deadLetters == nil {
+		return 0, false
+	}
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return 0, false
+	}
+	letter, err := h.deadLetters.Add(ctx, models.DeadLetterStorage, entry.Source, payload, cause)
+	return letter.ID, err == nil
+}
+
+// rejectSubmission dead-letters a request body that failed parsing, the
+// pipeline or validation, and falls back to an error response when the
+// dead-letter queue is disabled or cannot record it
+func (h *Handler) rejectSubmission(w http.ResponseWriter, r *http.Request, ingestErr *ingestError, source string, body []byte) {
+	if h.deadLetters != nil {
+		letter, err := h.deadLetters.Add(r.Context(), ingestErr.reason, source, body, ingestErr.err)
+		if err == nil {
+			writeDeadLettered(w, logger.GetRequestID(r.Context()), ingestErr.message, []int64{letter.ID})
+			return
+		}
+	}
+	http.Error(w, ingestErr.message, ingestErr.status)
+}
+
+// writeDeadLettered tells the client its submission was kept for replay
+func writeDeadLettered(w http.ResponseWriter, requestID, message string, ids []int64) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":          "dead_lettered",
+		"message":         message,
+		"dead_letter_ids": ids,
+		"request_id":      requestID,
+	})
+}
+
+// replayDeadLetter re-ingests a dead letter. Storage failures hold the
+// processed entry and are stored directly; any other dead letter holds the
+// original request body, which is parsed and processed again.
+func (h *Handler) replayDeadLetter(ctx context.Context, letter models.DeadLetter) error {
+	requestID := logger.GetRequestID(ctx)
+
+	var entries []*models.Log
+	if letter.Reason == models.DeadLetterStorage {
+		var entry models.Log
+		if err := json.Unmarshal([]byte(letter.Payload), &entry); err != nil {
+			return err
+		}
+		if err := entry.Validate(); err != nil {
+			return err
+		}
+		entries = []*models.Log{&entry}
+	} else {
+		entry, ingestErr := h.parseLogEntry(ctx, requestID, []byte(letter.Payload))
+		if ingestErr != nil {
+			return ingestErr
+		}
+		if entries, ingestErr = h.processLogEntry(ctx, requestID, &entry); ingestErr != nil {
+			return ingestErr
+		}
+	}
+
+	for _, entry := range entries {
+		if err := h.store.StoreLog(ctx, *entry); err != nil {
+			return err
+		}
+		metrics.Stored(ctx, entry.Source)
+	}
+	return nil
+}
+
+func (h *Handler) HandleHealthCheck(w http.ResponseWriter, r *http.Request) {
 	requestID := logger.GetRequestID(r.Context())
 	
 	// Check database connectivity
-	if err := database.Ping(); err != nil {
-		handlerLogger.WithFields(map[string]interface{}{
+	if err := h.store.Ping(r.Context()); err != nil {
+		h.log.WithFields(map[string]interface{}{
 			"request_id": requestID,
 			"error":      err.Error(),
 		}).ErrorContext(r.Context(), "Health check failed - database connectivity issue")
@@ -153,7 +500,7 @@ func HandleHealthCheck(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	handlerLogger.WithField("request_id", requestID).DebugContext(r.Context(), "Health check passed")
+	h.log.WithField("request_id", requestID).DebugContext(r.Context(), "Health check passed")
 	
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -4,13 +4,13 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
 	"log-processing-system/services/log-ingestion/models"
-	"log-processing-system/services/log-ingestion/database"
 	"log-processing-system/services/log-ingestion/logger"
 )
 
@@ -19,17 +19,19 @@ type mockDB struct {
 	logs      []models.Log
 	connected bool
 	shouldErr bool
+	// failMessage makes storing entries with that message fail
+	failMessage string
 }
 
-func (m *mockDB) StoreLog(log models.Log) error {
-	if m.shouldErr {
+func (m *mockDB) StoreLog(ctx context.Context, log models.Log) error {
+	if m.shouldErr || m.failMessage != "" && log.Message == m.failMessage {
 		return &testError{"database error"}
 	}
 	m.logs = append(m.logs, log)
 	return nil
 }
 
-func (m *mockDB) Ping() error {
+func (m *mockDB) Ping(ctx context.Context) error {
 	if !m.connected {
 		return &testError{"database not connected"}
 	}
@@ -51,35 +53,19 @@ func (e *testError) Error() string {
 	return e.message
 }
 
-// Setup test environment
-func setupTest() (*mockDB, func()) {
-	// Save original database functions
-	originalStoreLog := database.StoreLog
-	originalPing := database.Ping
-	
-	// Create mock
+// Setup test environment: a handler storing in a mock database
+func setupTest() (*Handler, *mockDB) {
 	mockDB := &mockDB{
 		logs:      []models.Log{},
 		connected: true,
 		shouldErr: false,
 	}
-	
-	// Replace database functions
-	database.StoreLog = mockDB.StoreLog
-	database.Ping = mockDB.Ping
-	
-	// Return cleanup function
-	cleanup := func() {
-		database.StoreLog = originalStoreLog
-		database.Ping = originalPing
-	}
-	
-	return mockDB, cleanup
+	h := New(mockDB, logger.New(logger.Config{Service: "test-service", Component: "handlers"}))
+	return h, mockDB
 }
 
 func TestHandleLogIngestion_StructuredFormat(t *testing.T) {
-	mockDB, cleanup := setupTest()
-	defer cleanup()
+	h, mockDB := setupTest()
 	
 	// Prepare request body with structured format
 	logData := map[string]interface{}{
@@ -99,7 +85,7 @@ func TestHandleLogIngestion_StructuredFormat(t *testing.T) {
 	
 	rr := httptest.NewRecorder()
 	
-	HandleLogIngestion(rr, req)
+	h.HandleLogIngestion(rr, req)
 	
 	// Check response
 	if rr.Code != http.StatusAccepted {
@@ -135,8 +121,7 @@ func TestHandleLogIngestion_StructuredFormat(t *testing.T) {
 }
 
 func TestHandleLogIngestion_LegacyFormat(t *testing.T) {
-	mockDB, cleanup := setupTest()
-	defer cleanup()
+	h, mockDB := setupTest()
 	
 	// Prepare request body with legacy format
 	logData := map[string]interface{}{
@@ -149,7 +134,7 @@ func TestHandleLogIngestion_LegacyFormat(t *testing.T) {
 	
 	rr := httptest.NewRecorder()
 	
-	HandleLogIngestion(rr, req)
+	h.HandleLogIngestion(rr, req)
 	
 	// Check response
 	if rr.Code != http.StatusAccepted {
@@ -174,8 +159,7 @@ func TestHandleLogIngestion_LegacyFormat(t *testing.T) {
 }
 
 func TestHandleLogIngestion_InvalidJSON(t *testing.T) {
-	mockDB, cleanup := setupTest()
-	defer cleanup()
+	h, mockDB := setupTest()
 	
 	// Send invalid JSON
 	req := httptest.NewRequest("POST", "/logs", strings.NewReader("invalid json"))
@@ -183,7 +167,7 @@ func TestHandleLogIngestion_InvalidJSON(t *testing.T) {
 	
 	rr := httptest.NewRecorder()
 	
-	HandleLogIngestion(rr, req)
+	h.HandleLogIngestion(rr, req)
 	
 	// Check response
 	if rr.Code != http.StatusBadRequest {
@@ -197,8 +181,7 @@ func TestHandleLogIngestion_InvalidJSON(t *testing.T) {
 }
 
 func TestHandleLogIngestion_MissingFields(t *testing.T) {
-	mockDB, cleanup := setupTest()
-	defer cleanup()
+	h, _ := setupTest()
 	
 	// Send JSON without required fields
 	logData := map[string]interface{}{
@@ -211,7 +194,7 @@ func TestHandleLogIngestion_MissingFields(t *testing.T) {
 	
 	rr := httptest.NewRecorder()
 	
-	HandleLogIngestion(rr, req)
+	h.HandleLogIngestion(rr, req)
 	
 	// Check response
 	if rr.Code != http.StatusBadRequest {
@@ -225,8 +208,7 @@ func TestHandleLogIngestion_MissingFields(t *testing.T) {
 }
 
 func TestHandleLogIngestion_ValidationError(t *testing.T) {
-	mockDB, cleanup := setupTest()
-	defer cleanup()
+	h, _ := setupTest()
 	
 	// Send log with invalid data that will fail validation
 	logData := map[string]interface{}{
@@ -241,7 +223,7 @@ func TestHandleLogIngestion_ValidationError(t *testing.T) {
 	
 	rr := httptest.NewRecorder()
 	
-	HandleLogIngestion(rr, req)
+	h.HandleLogIngestion(rr, req)
 	
 	// Check response (assuming empty message fails validation)
 	if rr.Code != http.StatusBadRequest {
@@ -250,8 +232,7 @@ func TestHandleLogIngestion_ValidationError(t *testing.T) {
 }
 
 func TestHandleLogIngestion_DatabaseError(t *testing.T) {
-	mockDB, cleanup := setupTest()
-	defer cleanup()
+	h, mockDB := setupTest()
 	
 	// Set mock to return error
 	mockDB.shouldErr = true
@@ -268,7 +249,7 @@ func TestHandleLogIngestion_DatabaseError(t *testing.T) {
 	
 	rr := httptest.NewRecorder()
 	
-	HandleLogIngestion(rr, req)
+	h.HandleLogIngestion(rr, req)
 	
 	// Check response
 	if rr.Code != http.StatusInternalServerError {
@@ -281,14 +262,54 @@ func TestHandleLogIngestion_DatabaseError(t *testing.T) {
 	}
 }
 
+func TestHandleBatchIngestion_StorageFailure(t *testing.T) {
+	h, mockDB := setupTest()
+	mockDB.failMessage = "unstorable"
+	batch := `[{"message":"first","level":"info","source":"api"},{"message":"unstorable","level":"info","source":"api"},{"message":"third","level":"info","source":"api"}]`
+
+	rr := httptest.NewRecorder()
+	h.HandleBatchIngestion(rr, httptest.NewRequest(http.MethodPost, "/ingest/batch", strings.NewReader(batch)))
+	if rr.Code != http.StatusMultiStatus {
+		t.Fatalf("Expected status 207, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var response struct {
+		Accepted int              `json:"accepted"`
+		Rejected []batchRejection `json:"rejected"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Accepted != 2 || len(response.Rejected) != 1 || response.Rejected[0].Index != 1 || !response.Rejected[0].Retryable {
+		t.Errorf("Expected entry 1 reported as retryable and the others accepted, got %s", rr.Body.String())
+	}
+	if len(mockDB.logs) != 2 || mockDB.logs[1].Message != "third" {
+		t.Errorf("Expected the entries around the failure stored, got %+v", mockDB.logs)
+	}
+
+	// Nothing stored: the batch can be sent again as is
+	mockDB.Reset()
+	mockDB.shouldErr = true
+	rr = httptest.NewRecorder()
+	h.HandleBatchIngestion(rr, httptest.NewRequest(http.MethodPost, "/ingest/batch", strings.NewReader(batch)))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 with nothing stored, got %d", rr.Code)
+	}
+
+	// Nothing stored, but resending as is would resend the invalid entry
+	rr = httptest.NewRecorder()
+	h.HandleBatchIngestion(rr, httptest.NewRequest(http.MethodPost, "/ingest/batch", strings.NewReader(`[{"message":"","level":"info"},{"message":"first","level":"info","source":"api"}]`)))
+	if rr.Code != http.StatusMultiStatus {
+		t.Errorf("Expected status 207 with an invalid entry in the batch, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
 func TestHandleHealthCheck_Healthy(t *testing.T) {
-	mockDB, cleanup := setupTest()
-	defer cleanup()
+	h, _ := setupTest()
 	
 	req := httptest.NewRequest("GET", "/health", nil)
 	rr := httptest.NewRecorder()
 	
-	HandleHealthCheck(rr, req)
+	h.HandleHealthCheck(rr, req)
 	
 	// Check response
 	if rr.Code != http.StatusOK {
@@ -311,8 +332,7 @@ func TestHandleHealthCheck_Healthy(t *testing.T) {
 }
 
 func TestHandleHealthCheck_Unhealthy(t *testing.T) {
-	mockDB, cleanup := setupTest()
-	defer cleanup()
+	h, mockDB := setupTest()
 	
 	// Set database as disconnected
 	mockDB.connected = false
@@ -320,7 +340,7 @@ func TestHandleHealthCheck_Unhealthy(t *testing.T) {
 	req := httptest.NewRequest("GET", "/health", nil)
 	rr := httptest.NewRecorder()
 	
-	HandleHealthCheck(rr, req)
+	h.HandleHealthCheck(rr, req)
 	
 	// Check response
 	if rr.Code != http.StatusServiceUnavailable {
@@ -343,8 +363,7 @@ func TestHandleHealthCheck_Unhealthy(t *testing.T) {
 }
 
 func TestHandleLogIngestion_WithContext(t *testing.T) {
-	mockDB, cleanup := setupTest()
-	defer cleanup()
+	h, _ := setupTest()
 	
 	logData := map[string]interface{}{
 		"message": "Test with context",
@@ -365,7 +384,7 @@ func TestHandleLogIngestion_WithContext(t *testing.T) {
 	
 	rr := httptest.NewRecorder()
 	
-	HandleLogIngestion(rr, req)
+	h.HandleLogIngestion(rr, req)
 	
 	// Check response
 	if rr.Code != http.StatusAccepted {
@@ -377,8 +396,7 @@ func TestHandleLogIngestion_WithContext(t *testing.T) {
 }
 
 func TestHandleLogIngestion_ContentTypes(t *testing.T) {
-	mockDB, cleanup := setupTest()
-	defer cleanup()
+	h, mockDB := setupTest()
 	
 	logData := map[string]interface{}{
 		"message": "Test message",
@@ -408,7 +426,7 @@ func TestHandleLogIngestion_ContentTypes(t *testing.T) {
 			}
 			
 			rr := httptest.NewRecorder()
-			HandleLogIngestion(rr, req)
+			h.HandleLogIngestion(rr, req)
 			
 			if rr.Code != tc.expectCode {
 				t.Errorf("Expected status code %d, got %d", tc.expectCode, rr.Code)
@@ -418,8 +436,7 @@ func TestHandleLogIngestion_ContentTypes(t *testing.T) {
 }
 
 func TestHandleLogIngestion_LargePayload(t *testing.T) {
-	mockDB, cleanup := setupTest()
-	defer cleanup()
+	h, mockDB := setupTest()
 	
 	// Create a large message
 	largeMessage := strings.Repeat("A", 10000)
@@ -436,7 +453,7 @@ func TestHandleLogIngestion_LargePayload(t *testing.T) {
 	
 	rr := httptest.NewRecorder()
 	
-	HandleLogIngestion(rr, req)
+	h.HandleLogIngestion(rr, req)
 	
 	// Should handle large payloads
 	if rr.Code != http.StatusAccepted {
@@ -455,8 +472,7 @@ func TestHandleLogIngestion_LargePayload(t *testing.T) {
 
 // Integration test for complete log processing flow
 func TestLogIngestionFlow_Integration(t *testing.T) {
-	mockDB, cleanup := setupTest()
-	defer cleanup()
+	h, mockDB := setupTest()
 	
 	// Test complete flow with multiple log entries
 	testLogs := []map[string]interface{}{
@@ -485,7 +501,7 @@ func TestLogIngestionFlow_Integration(t *testing.T) {
 		req = req.WithContext(ctx)
 		
 		rr := httptest.NewRecorder()
-		HandleLogIngestion(rr, req)
+		h.HandleLogIngestion(rr, req)
 		
 		if rr.Code != http.StatusAccepted {
 			t.Errorf("Request %d: Expected status code 202, got %d", i, rr.Code)
@@ -513,8 +529,7 @@ func TestLogIngestionFlow_Integration(t *testing.T) {
 
 // Benchmark tests
 func BenchmarkHandleLogIngestion_StructuredFormat(b *testing.B) {
-	mockDB, cleanup := setupTest()
-	defer cleanup()
+	h, mockDB := setupTest()
 	
 	logData := map[string]interface{}{
 		"message": "Benchmark test message",
@@ -532,13 +547,12 @@ func BenchmarkHandleLogIngestion_StructuredFormat(b *testing.B) {
 		req.Header.Set("Content-Type", "application/json")
 		
 		rr := httptest.NewRecorder()
-		HandleLogIngestion(rr, req)
+		h.HandleLogIngestion(rr, req)
 	}
 }
 
 func BenchmarkHandleLogIngestion_LegacyFormat(b *testing.B) {
-	mockDB, cleanup := setupTest()
-	defer cleanup()
+	h, mockDB := setupTest()
 	
 	logData := map[string]interface{}{
 		"log": "Benchmark legacy message",
@@ -554,6 +568,53 @@ func BenchmarkHandleLogIngestion_LegacyFormat(b *testing.B) {
 		req.Header.Set("Content-Type", "application/json")
 		
 		rr := httptest.NewRecorder()
-		HandleLogIngestion(rr, req)
+		h.HandleLogIngestion(rr, req)
+	}
+}
+
+func TestParseLogEntry(t *testing.T) {
+	h, _ := setupTest()
+	tests := []struct {
+		name    string
+		body    string
+		raw     bool // with a queue, which keeps extra keys
+		want    models.Log
+		wantErr string
+	}{
+		{name: "numeric level", body: `{"message":"disk full","level":3,"source":"syslog","host":"db-1"}`,
+			want: models.Log{Message: "disk full", Level: "3", Source: "syslog"}},
+		{name: "extra keys", body: `{"message":"disk full","level":"error","host":"db-1","fields":{"host":"db-2"},"pid":7}`, raw: true,
+			want: models.Log{Message: "disk full", Level: "error", Fields: models.Fields{"host": "db-2", "pid": float64(7)}}},
+		{name: "no message with a queue", body: `{"msg":"disk full"}`, raw: true,
+			want: models.Log{Fields: models.Fields{"msg": "disk full"}}},
+		{name: "legacy", body: `{"log":"disk full","level":"error"}`,
+			want: models.Log{Message: "disk full", Level: "info", Source: models.LegacySource}},
+		{name: "not an object", body: `["disk full"]`, wantErr: "Invalid JSON format"},
+		{name: "malformed", body: `{"message":`, wantErr: "Invalid JSON format"},
+		{name: "legacy log not text", body: `{"log":42}`, wantErr: "Invalid structured log entry"},
+		{name: "invalid level", body: `{"message":"disk full","level":true}`, wantErr: "Invalid structured log entry"},
+		{name: "missing message", body: `{"msg":"disk full"}`, wantErr: "Missing required fields"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h.SetQueue(nil)
+			if tt.raw {
+				h.SetQueue(&depthPublisher{})
+			}
+			got, ingestErr := h.parseLogEntry(context.Background(), "", []byte(tt.body))
+			if tt.wantErr != "" {
+				if ingestErr == nil || !strings.HasPrefix(ingestErr.message, tt.wantErr) {
+					t.Fatalf("Expected %q, got %+v", tt.wantErr, ingestErr)
+				}
+				return
+			}
+			if ingestErr != nil {
+				t.Fatalf("Unexpected error: %v", ingestErr)
+			}
+			got.Timestamp = time.Time{}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
 	}
 }
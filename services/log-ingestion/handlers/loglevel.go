@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"log-processing-system/services/log-ingestion/audit"
+	"log-processing-system/services/log-ingestion/logger"
+)
+
+// logLevels is the body of the log level endpoints
+type logLevels struct {
+	Level      string            `json:"level"`
+	Components map[string]string `json:"components"`
+}
+
+// currentLogLevels reports the levels of the service's loggers
+func currentLogLevels() logLevels {
+	level, components := logger.Levels()
+	current := logLevels{Level: level.String(), Components: make(map[string]string, len(components))}
+	for component, level := range components {
+		current.Components[component] = level.String()
+	}
+	return current
+}
+
+// HandleGetLogLevel reports the active log level and component overrides
+func (h *Handler) HandleGetLogLevel(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(currentLogLevels())
+}
+
+// HandleSetLogLevel changes the active log level of the running service.
+// Components, when present, replaces the component overrides; an empty
+// level keeps the current one.
+func (h *Handler) HandleSetLogLevel(w http.ResponseWriter, r *http.Request) {
+	requestID := logger.GetRequestID(r.Context())
+
+	var req logLevels
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		return
+	}
+
+	level, _ := logger.Levels()
+	if req.Level != "" {
+		parsed, err := logger.ParseLevel(req.Level)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		level = parsed
+	}
+
+	var overrides map[string]logger.LogLevel
+	if req.Components != nil {
+		overrides = make(map[string]logger.LogLevel, len(req.Components))
+		for component, name := range req.Components {
+			parsed, err := logger.ParseLevel(name)
+			if err != nil {
+				http.Error(w, component+": "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			overrides[component] = parsed
+		}
+	}
+
+	previous := currentLogLevels()
+	logger.SetLevels(level, overrides)
+	current := currentLogLevels()
+
+	// Logged as a warning so the change shows up under most levels
+	h.log.WithFields(map[string]interface{}{
+		"request_id":          requestID,
+		"previous_level":      previous.Level,
+		"previous_components": previous.Components,
+		"level":               current.Level,
+		"components":          current.Components,
+	}).WarnContext(r.Context(), "Log level changed")
+	audit.Annotate(r.Context(), "previous", previous)
+	audit.Annotate(r.Context(), "current", current)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(current)
+}
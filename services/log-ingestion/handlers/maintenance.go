@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"log-processing-system/services/log-ingestion/audit"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/maintenance"
+	"log-processing-system/services/log-ingestion/metrics"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+// SetMaintenance installs the maintenance switch used by the ingestion
+// handlers
+func (h *Handler) SetMaintenance(s *maintenance.Switch) {
+	h.maintenance = s
+}
+
+// StoreBufferedLog stores an entry that was buffered during maintenance; it
+// is the maintenance.StoreFunc of the switch
+func (h *Handler) StoreBufferedLog(ctx context.Context, entry *models.Log) error {
+	if err := h.store.StoreLog(ctx, *entry); err != nil {
+		return err
+	}
+	metrics.Stored(ctx, entry.Source)
+	return nil
+}
+
+// rejectForMaintenance answers 503 with a Retry-After while maintenance
+// refuses submissions, and reports whether it did. A full buffer is
+// reported as saturation.
+func (h *Handler) rejectForMaintenance(w http.ResponseWriter, r *http.Request) bool {
+	retryAfter, rejecting := h.maintenance.Rejecting()
+	if !rejecting {
+		return false
+	}
+	if state := h.maintenance.State(); state.Mode == maintenance.Buffer && state.BufferFull {
+		writeSaturated(w, r, resourceMaintenanceBuffer, state.BufferBytes, state.BufferMaxBytes, "bytes", retryAfter)
+		return true
+	}
+
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":              "maintenance",
+		"message":             "Ingestion is in maintenance mode, retry later",
+		"retry_after_seconds": seconds,
+		"request_id":          logger.GetRequestID(r.Context()),
+	})
+	return true
+}
+
+// maintenanceRequest is the body of PUT /admin/maintenance
+type maintenanceRequest struct {
+	Enabled bool   `json:"enabled"`
+	Mode    string `json:"mode"`
+	Reason  string `json:"reason"`
+}
+
+// HandleGetMaintenance reports whether ingestion is in maintenance mode and
+// how many entries are buffered
+func (h *Handler) HandleGetMaintenance(s *maintenance.Switch) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(s.State())
+	}
+}
+
+// HandleSetMaintenance turns maintenance mode on, in buffer or reject mode,
+// or off, which starts storing the buffered entries
+func (h *Handler) HandleSetMaintenance(s *maintenance.Switch) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req maintenanceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+			return
+		}
+
+		previous := s.State()
+		var state maintenance.State
+		if req.Enabled {
+			var err error
+			if state, err = s.Enable(req.Mode, req.Reason); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		} else {
+			state = s.Disable()
+		}
+
+		// Logged as a warning so the change shows up under most levels
+		h.log.WithFields(map[string]interface{}{
+			"request_id": logger.GetRequestID(r.Context()),
+			"enabled":    state.Enabled,
+			"mode":       state.Mode,
+			"reason":     state.Reason,
+			"buffered":   state.Buffered,
+		}).WarnContext(r.Context(), "Maintenance mode changed")
+		audit.Annotate(r.Context(), "previous", previous.Enabled)
+		audit.Annotate(r.Context(), "mode", state.Mode)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(state)
+	}
+}
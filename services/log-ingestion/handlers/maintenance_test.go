@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/maintenance"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func TestMaintenance(t *testing.T) {
+	h, _ := setupTest()
+	var stored []string
+	s, err := maintenance.New(maintenance.Config{
+		BufferFile: filepath.Join(t.TempDir(), "buffer.jsonl"),
+		RetryAfter: time.Minute,
+	}, func(ctx context.Context, entry *models.Log) error {
+		stored = append(stored, entry.Message)
+		return nil
+	}, logger.New(logger.Config{Service: "test-service", Component: "maintenance"}))
+	if err != nil {
+		t.Fatalf("Failed to create switch: %v", err)
+	}
+	h.SetMaintenance(s)
+
+	setMaintenance := func(body string) maintenance.State {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h.HandleSetMaintenance(s)(rec, httptest.NewRequest(http.MethodPut, "/admin/maintenance", strings.NewReader(body)))
+		var state maintenance.State
+		if err := json.Unmarshal(rec.Body.Bytes(), &state); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 with the state, got %d: %s", rec.Code, rec.Body.String())
+		}
+		return state
+	}
+	body := []byte(`{"message":"during failover","level":"info","source":"api"}`)
+
+	setMaintenance(`{"enabled":true,"mode":"reject","reason":"failover"}`)
+	rec := httptest.NewRecorder()
+	h.HandleBatchIngestion(rec, httptest.NewRequest(http.MethodPost, "/ingest/batch", bytes.NewReader(append(append([]byte("["), body...), ']'))))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "60" {
+		t.Errorf("Expected status 503 with a Retry-After of 60, got %d, %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	setMaintenance(`{"enabled":true,"mode":"buffer"}`)
+	rec = httptest.NewRecorder()
+	h.HandleLogIngestion(rec, httptest.NewRequest(http.MethodPost, "/ingest", bytes.NewReader(body)))
+	if rec.Code != http.StatusAccepted || !strings.Contains(rec.Body.String(), `"buffered"`) {
+		t.Fatalf("Expected the entry buffered, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if state := setMaintenance(`{"enabled":false}`); state.Enabled || state.Buffered != 1 {
+		t.Errorf("Expected maintenance off with one entry to store, got %+v", state)
+	}
+	for deadline := time.Now().Add(5 * time.Second); s.State().Draining && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if state := s.State(); state.Buffered != 0 || len(stored) != 1 || stored[0] != "during failover" {
+		t.Errorf("Expected the buffered entry stored, got %v and %+v", stored, state)
+	}
+
+	rec = httptest.NewRecorder()
+	h.HandleSetMaintenance(s)(rec, httptest.NewRequest(http.MethodPut, "/admin/maintenance", strings.NewReader(`{"enabled":true,"mode":"pause"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown mode, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/patterns"
+)
+
+// defaultPatternLimit caps the number of patterns returned when `limit` is not given
+const defaultPatternLimit = 100
+
+// HandleLogPatterns returns the message templates mined from stored logs,
+// most frequent first. `new_since` restricts the result to patterns first
+// seen after that time, e.g. the error patterns that appeared today.
+func (h *Handler) HandleLogPatterns(miner *patterns.Miner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := logger.GetRequestID(r.Context())
+		query := r.URL.Query()
+
+		q := patterns.Query{
+			Level:  query.Get("level"),
+			Source: query.Get("source"),
+			Limit:  defaultPatternLimit,
+		}
+
+		for param, target := range map[string]*time.Time{"since": &q.Since, "new_since": &q.NewSince} {
+			value := query.Get(param)
+			if value == "" {
+				continue
+			}
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				http.Error(w, "Invalid '"+param+"' timestamp: must be RFC3339", http.StatusBadRequest)
+				return
+			}
+			*target = parsed
+		}
+
+		if limit := query.Get("limit"); limit != "" {
+			parsed, err := strconv.Atoi(limit)
+			if err != nil || parsed <= 0 {
+				http.Error(w, "Invalid limit: must be a positive integer", http.StatusBadRequest)
+				return
+			}
+			q.Limit = parsed
+		}
+
+		// Count before applying the limit so callers see the full total
+		limit := q.Limit
+		q.Limit = 0
+		result := miner.Patterns(q)
+		total := len(result)
+		if len(result) > limit {
+			result = result[:limit]
+		}
+
+		h.log.WithFields(map[string]interface{}{
+			"request_id": requestID,
+			"patterns":   total,
+		}).DebugContext(r.Context(), "Log patterns retrieved")
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"total":    total,
+			"patterns": result,
+		})
+	}
+}
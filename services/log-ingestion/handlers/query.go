@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"log-processing-system/services/log-ingestion/database"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+// Limits of log queries
+const (
+	defaultQueryLimit = 100
+	maxQueryLimit     = 1000
+)
+
+// HandleQueryLogs returns stored log entries matching the source, level,
+// from and to parameters, most recently stored first. A full page carries
+// next_before, the `before` parameter that returns the next page. With a
+// query cache, pages bounded by a `to` in the past are served from it while
+// fresh.
+func (h *Handler) HandleQueryLogs(w http.ResponseWriter, r *http.Request) {
+	requestID := logger.GetRequestID(r.Context())
+	query := r.URL.Query()
+
+	filter := models.LogFilter{
+		Source: query.Get("source"),
+		Level:  query.Get("level"),
+	}
+	for name, target := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := query.Get(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				http.Error(w, "Invalid '"+name+"' timestamp: must be RFC3339", http.StatusBadRequest)
+				return
+			}
+			*target = &parsed
+		}
+	}
+	if err := filter.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit, before := defaultQueryLimit, 0
+	for name, target := range map[string]*int{"limit": &limit, "before": &before} {
+		if value := query.Get(name); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 0 {
+				http.Error(w, "Invalid "+name+" parameter", http.StatusBadRequest)
+				return
+			}
+			*target = parsed
+		}
+	}
+	if limit == 0 || limit > maxQueryLimit {
+		http.Error(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+		return
+	}
+
+	// Pages bounded by a past `to` are historical and cached; the newest
+	// entries, which change with every entry stored, are always queried
+	cacheable := cacheableRange(filter.To)
+	if cacheable && h.serveCachedQuery(w, r) {
+		return
+	}
+
+	logs, err := database.QueryLogs(r.Context(), filter, before, limit)
+	if err != nil {
+		h.log.WithFields(map[string]interface{}{
+			"request_id": requestID,
+			"error":      err.Error(),
+		}).ErrorContext(r.Context(), "Failed to query logs")
+
+		http.Error(w, "Failed to query logs", http.StatusInternalServerError)
+		return
+	}
+
+	h.log.WithFields(map[string]interface{}{
+		"request_id": requestID,
+		"logs":       len(logs),
+	}).DebugContext(r.Context(), "Logs queried")
+
+	response := map[string]interface{}{
+		"logs":  logs,
+		"count": len(logs),
+	}
+	if len(logs) == limit {
+		response["next_before"] = logs[len(logs)-1].ID
+	}
+	h.writeQueryResponse(w, r, response, cacheable)
+}
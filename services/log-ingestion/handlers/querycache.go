@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/querycache"
+)
+
+// SetQueryCache makes queries and aggregations over past ranges answer
+// from cache while their responses are fresh
+func (h *Handler) SetQueryCache(c querycache.Cache) {
+	h.queryCache = c
+}
+
+// queryCacheKey identifies a query by its path and parameters, sorted so
+// that the order a dashboard sends them in does not matter
+func queryCacheKey(r *http.Request) string {
+	return r.URL.Path + "?" + r.URL.Query().Encode()
+}
+
+// cacheableRange reports whether the response to a query ending at to may
+// be cached: only a range that has already ended stops changing
+func cacheableRange(to *time.Time) bool {
+	return to != nil && to.Before(time.Now())
+}
+
+// serveCachedQuery answers r with a cached response and reports whether it
+// did. A failing cache is logged and the query run against the database.
+func (h *Handler) serveCachedQuery(w http.ResponseWriter, r *http.Request) bool {
+	if h.queryCache == nil {
+		return false
+	}
+	body, ok, err := querycache.Lookup(r.Context(), h.queryCache, queryCacheKey(r))
+	if err != nil {
+		h.log.WithFields(map[string]interface{}{
+			"request_id": logger.GetRequestID(r.Context()),
+			"error":      err.Error(),
+		}).WarnContext(r.Context(), "Failed to read query cache")
+	}
+	if !ok {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Cache", "HIT")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+	return true
+}
+
+// writeQueryResponse answers r with response, caching it for the requests
+// serveCachedQuery answers when cache is set
+func (h *Handler) writeQueryResponse(w http.ResponseWriter, r *http.Request, response interface{}, cache bool) {
+	body, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	body = append(body, '\n')
+
+	w.Header().Set("Content-Type", "application/json")
+	if cache && h.queryCache != nil {
+		w.Header().Set("X-Cache", "MISS")
+		if err := querycache.Store(r.Context(), h.queryCache, queryCacheKey(r), body); err != nil {
+			h.log.WithFields(map[string]interface{}{
+				"request_id": logger.GetRequestID(r.Context()),
+				"error":      err.Error(),
+			}).WarnContext(r.Context(), "Failed to write query cache")
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
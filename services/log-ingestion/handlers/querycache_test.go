@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/querycache"
+)
+
+func TestQueryCache(t *testing.T) {
+	h, _ := setupTest()
+	cache, err := querycache.New(querycache.Config{Backend: "memory"})
+	if err != nil {
+		t.Fatalf("Failed to create query cache: %v", err)
+	}
+	h.SetQueryCache(cache)
+
+	req := httptest.NewRequest(http.MethodGet, "/logs/aggregate?source=api&interval=hour", nil)
+	if h.serveCachedQuery(httptest.NewRecorder(), req) {
+		t.Fatal("Expected nothing cached yet")
+	}
+	rec := httptest.NewRecorder()
+	h.writeQueryResponse(rec, req, map[string]interface{}{"aggregates": []int{1, 2}}, true)
+	if rec.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("Expected X-Cache MISS, got %q", rec.Header().Get("X-Cache"))
+	}
+
+	// The same query with its parameters in another order
+	cached := httptest.NewRecorder()
+	if !h.serveCachedQuery(cached, httptest.NewRequest(http.MethodGet, "/logs/aggregate?interval=hour&source=api", nil)) {
+		t.Fatal("Expected the response served from the cache")
+	}
+	if cached.Header().Get("X-Cache") != "HIT" || cached.Body.String() != rec.Body.String() {
+		t.Errorf("Expected the cached response %q, got %q", rec.Body.String(), cached.Body.String())
+	}
+
+	// Responses not meant to be cached are not
+	req = httptest.NewRequest(http.MethodGet, "/logs?source=api", nil)
+	rec = httptest.NewRecorder()
+	h.writeQueryResponse(rec, req, map[string]interface{}{"count": 0}, false)
+	if rec.Header().Get("X-Cache") != "" || h.serveCachedQuery(httptest.NewRecorder(), req) {
+		t.Error("Expected an unbounded query not cached")
+	}
+}
+
+func TestCacheableRange(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	if !cacheableRange(&past) {
+		t.Error("Expected a range that ended to be cacheable")
+	}
+	if cacheableRange(&future) {
+		t.Error("Expected a range still filling up not cached")
+	}
+	if cacheableRange(nil) {
+		t.Error("Expected an unbounded range not cached")
+	}
+}
+
+func TestHandleLogAggregate_ServesPastWindowsFromCache(t *testing.T) {
+	h, _ := setupTest()
+	cache, err := querycache.New(querycache.Config{Backend: "memory"})
+	if err != nil {
+		t.Fatalf("Failed to create query cache: %v", err)
+	}
+	h.SetQueryCache(cache)
+
+	req := httptest.NewRequest(http.MethodGet, "/logs/aggregate?interval=hour&to=2025-08-29T12:00:00Z", nil)
+	h.writeQueryResponse(httptest.NewRecorder(), req, map[string]interface{}{"aggregates": []int{1}}, true)
+
+	rec := httptest.NewRecorder()
+	h.HandleLogAggregate(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("Expected the past window served from the cache, got %d with X-Cache %q", rec.Code, rec.Header().Get("X-Cache"))
+	}
+}
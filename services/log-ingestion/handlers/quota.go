@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"log-processing-system/services/log-ingestion/quota"
+)
+
+// HandleGetQuota returns the quota usage of the tenant making the request
+func (h *Handler) HandleGetQuota(e *quota.Enforcer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant := quota.Tenant(r.Context())
+		if tenant == "" {
+			http.Error(w, "Request is not made on behalf of a tenant", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(e.Usage(tenant))
+	}
+}
+
+// HandleListQuotas returns the quota usage of every tenant seen today, or
+// of the one named by the tenant query parameter
+func (h *Handler) HandleListQuotas(e *quota.Enforcer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		usages := e.All()
+		if tenant := r.URL.Query().Get("tenant"); tenant != "" {
+			usages = []quota.Usage{e.Usage(tenant)}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"quotas": usages,
+			"count":  len(usages),
+		})
+	}
+}
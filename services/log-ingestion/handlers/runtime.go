@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+	"log-processing-system/services/log-ingestion/audit"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/middleware"
+	"log-processing-system/services/log-ingestion/queue"
+	"log-processing-system/services/log-ingestion/retention"
+	"log-processing-system/services/log-ingestion/throttle"
+)
+
+// HandleFlush stores the entries pipeline stages hold back, sends what the
+// pipeline's outputs buffer and writes out the service's own buffered logs,
+// rather than waiting for the next flush interval
+func (h *Handler) HandleFlush(w http.ResponseWriter, r *http.Request) {
+	requestID := logger.GetRequestID(r.Context())
+
+	released := 0
+	var outputErr error
+	if h.pipeline != nil {
+		entries := h.pipeline.Flush(r.Context(), time.Now(), true)
+		released = len(entries)
+		if released > 0 {
+			h.StoreFlushedLogs(r.Context(), entries)
+		}
+		outputErr = h.pipeline.FlushOutputs()
+	}
+	h.log.Flush()
+
+	audit.Annotate(r.Context(), "released", released)
+	if outputErr != nil {
+		h.log.WithFields(map[string]interface{}{
+			"request_id": requestID,
+			"released":   released,
+			"error":      outputErr.Error(),
+		}).ErrorContext(r.Context(), "Failed to flush pipeline outputs")
+
+		http.Error(w, "Failed to flush pipeline outputs: "+outputErr.Error(), http.StatusBadGateway)
+		return
+	}
+
+	h.log.WithFields(map[string]interface{}{
+		"request_id": requestID,
+		"released":   released,
+	}).InfoContext(r.Context(), "Write buffers flushed")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   "flushed",
+		"released": released,
+	})
+}
+
+// queueDepths is the body of the queue depth endpoint
+type queueDepths struct {
+	Queue      *int64         `json:"queue,omitempty"`       // messages in the Redis stream
+	QueueError string         `json:"queue_error,omitempty"` // why the queue depth is missing
+	WriteQueue *int           `json:"write_queue,omitempty"` // accepted entries waiting to be stored
+	Outputs    map[string]int `json:"outputs"`               // entries buffered by each route stage's sinks
+}
+
+// HandleQueueDepth reports how much accepted work is waiting: the messages
+// in the queue to the log-processor, when the backend can tell, the entries
+// in the write queue and the entries buffered by the pipeline's outputs
+func (h *Handler) HandleQueueDepth(w http.ResponseWriter, r *http.Request) {
+	depths := queueDepths{Outputs: map[string]int{}}
+	if reporter, ok := h.queue.(queue.DepthReporter); ok {
+		depth, err := reporter.Depth(r.Context())
+		if err != nil {
+			h.log.WithFields(map[string]interface{}{
+				"request_id": logger.GetRequestID(r.Context()),
+				"error":      err.Error(),
+			}).WarnContext(r.Context(), "Failed to read queue depth")
+			depths.QueueError = err.Error()
+		} else {
+			depths.Queue = &depth
+		}
+	}
+	if h.writes != nil {
+		depth := h.writes.Depth()
+		depths.WriteQueue = &depth
+	}
+	if h.pipeline != nil {
+		depths.Outputs = h.pipeline.Buffered()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(depths)
+}
+
+// HandleRetentionRun runs the rollup job now and reports what it rolled up.
+// It waits for a scheduled run in progress to finish first.
+func (h *Handler) HandleRetentionRun(job *retention.RollupJob) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		result, err := job.RunOnce()
+		if err != nil {
+			h.log.WithFields(map[string]interface{}{
+				"request_id": logger.GetRequestID(r.Context()),
+				"error":      err.Error(),
+			}).ErrorContext(r.Context(), "Requested retention run failed")
+
+			http.Error(w, "Retention run failed", http.StatusInternalServerError)
+			return
+		}
+		audit.Annotate(r.Context(), "rows_rolled_up", result.RowsRolledUp)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"rows_rolled_up":  result.RowsRolledUp,
+			"buckets_written": result.BucketsWritten,
+			"duration_ms":     result.Duration.Milliseconds(),
+			"last_run":        job.LastRun(),
+		})
+	}
+}
+
+// HandleReload does what SIGHUP does: reload re-reads the configuration
+// files and resets log levels. The levels in effect afterwards are returned.
+func (h *Handler) HandleReload(reload func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := reload(); err != nil {
+			h.log.WithFields(map[string]interface{}{
+				"request_id": logger.GetRequestID(r.Context()),
+				"error":      err.Error(),
+			}).WarnContext(r.Context(), "Failed to reload configuration")
+
+			http.Error(w, "Failed to reload configuration: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		current := currentLogLevels()
+		audit.Annotate(r.Context(), "levels", current)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(current)
+	}
+}
+
+// rateLimits is the body of the rate limit endpoint
+type rateLimits struct {
+	HTTP    middleware.RateLimitState `json:"http"`
+	Sources []throttle.SourceState    `json:"sources"` // per-source ingest budgets; empty when disabled
+}
+
+// HandleRateLimits reports the per-client HTTP rate limiter and the sources
+// held to their ingest budget
+func (h *Handler) HandleRateLimits(lm *middleware.LoggingMiddleware) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limits := rateLimits{HTTP: lm.RateLimitState(), Sources: []throttle.SourceState{}}
+		if h.limiter != nil {
+			limits.Sources = h.limiter.State()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(limits)
+	}
+}
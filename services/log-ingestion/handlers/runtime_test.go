@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/middleware"
+	"log-processing-system/services/log-ingestion/models"
+	"log-processing-system/services/log-ingestion/queue"
+	"log-processing-system/services/log-ingestion/throttle"
+)
+
+// depthPublisher is a queue publisher that reports a fixed depth
+type depthPublisher struct {
+	depth int64
+}
+
+func (p *depthPublisher) Publish(ctx context.Context, msg queue.Message) error { return nil }
+func (p *depthPublisher) Close() error                                       { return nil }
+func (p *depthPublisher) Depth(ctx context.Context) (int64, error)           { return p.depth, nil }
+
+func TestHandleQueueDepth(t *testing.T) {
+	h, _ := setupTest()
+	h.SetQueue(&depthPublisher{depth: 42})
+
+	rec := httptest.NewRecorder()
+	h.HandleQueueDepth(rec, httptest.NewRequest(http.MethodGet, "/admin/queues", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	var depths queueDepths
+	if err := json.Unmarshal(rec.Body.Bytes(), &depths); err != nil || depths.Queue == nil || *depths.Queue != 42 {
+		t.Errorf("Expected a queue depth of 42, got %s", rec.Body.String())
+	}
+}
+
+func TestHandleReload(t *testing.T) {
+	h, _ := setupTest()
+	reloads := 0
+	rec := httptest.NewRecorder()
+	h.HandleReload(func() error { reloads++; return nil })(rec, httptest.NewRequest(http.MethodPost, "/admin/reload", nil))
+	if rec.Code != http.StatusOK || reloads != 1 {
+		t.Fatalf("Expected one reload and status 200, got %d reloads, status %d", reloads, rec.Code)
+	}
+	var levels logLevels
+	if err := json.Unmarshal(rec.Body.Bytes(), &levels); err != nil || levels.Level == "" {
+		t.Errorf("Expected the current log levels, got %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.HandleReload(func() error { return errors.New("open .env: permission denied") })(rec, httptest.NewRequest(http.MethodPost, "/admin/reload", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500 when the reload fails, got %d", rec.Code)
+	}
+}
+
+func TestHandleRateLimits(t *testing.T) {
+	h, _ := setupTest()
+	testLogger := logger.New(logger.Config{Service: "test-service", Component: "test-component"})
+	h.SetThrottle(throttle.NewLimiter(throttle.Config{Rate: 100}, testLogger))
+	h.throttleEntry(context.Background(), &models.Log{Message: "m", Level: "info", Source: "api"})
+
+	lm := middleware.NewLoggingMiddleware(testLogger, middleware.LoggingConfig{RateLimit: 50})
+	rec := httptest.NewRecorder()
+	h.HandleRateLimits(lm)(rec, httptest.NewRequest(http.MethodGet, "/admin/rate-limits", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	var limits rateLimits
+	if err := json.Unmarshal(rec.Body.Bytes(), &limits); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !limits.HTTP.Enabled || limits.HTTP.Limit != 50 {
+		t.Errorf("Expected the HTTP limit of 50, got %+v", limits.HTTP)
+	}
+	if len(limits.Sources) != 1 || limits.Sources[0].Source != "api" || limits.Sources[0].Limit != 100 {
+		t.Errorf("Expected the api source within its budget, got %+v", limits.Sources)
+	}
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"context"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+// SetDropSelfLogs makes the ingestion handlers discard entries carrying the
+// self_source marker
+func (h *Handler) SetDropSelfLogs(drop bool) {
+	h.dropSelfLogs = drop
+}
+
+// guardSelfLog recognises an entry the system's own services logged. The
+// request is then handled without logging about it, so that ingesting it
+// produces nothing to ship back, and the entry is dropped if self logs are
+// not stored. It returns the context to handle the entry with and whether
+// to drop it.
+func (h *Handler) guardSelfLog(ctx context.Context, entry *models.Log) (context.Context, bool) {
+	if !logger.IsSelfSource(entry.Fields) {
+		return ctx, false
+	}
+	return logger.MarkSelfLog(ctx), h.dropSelfLogs
+}
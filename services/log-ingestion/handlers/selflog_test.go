@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"log-processing-system/services/log-ingestion/logger"
+)
+
+func TestHandleLogIngestion_DropsSelfLogs(t *testing.T) {
+	h, _ := setupTest()
+	h.SetDropSelfLogs(true)
+
+	// As the logging middleware prepares every request
+	ctx := logger.WithSelfLogMark(logger.WithRequestID(httptest.NewRequest(http.MethodPost, "/", nil).Context(), "req-1"))
+	body := []byte(`{"message":"HTTP request completed","level":"info","source":"log-ingestion","fields":{"self_source":"log-ingestion"}}`)
+	rec := httptest.NewRecorder()
+	h.HandleLogIngestion(rec, httptest.NewRequest(http.MethodPost, "/ingest", bytes.NewReader(body)).WithContext(ctx))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var payload map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil || payload["status"] != "dropped" {
+		t.Errorf("Expected the entry to be dropped, got %s", rec.Body.String())
+	}
+	if !logger.IsSelfLog(ctx) {
+		t.Error("Expected the request to be marked, silencing the middleware's request log")
+	}
+
+	// In a batch, self logs count as sampled out
+	batch := []byte(`[{"message":"Log batch ingested","level":"info","source":"log-processor","fields":{"self_source":"log-processor"}}]`)
+	rec = httptest.NewRecorder()
+	h.HandleBatchIngestion(rec, httptest.NewRequest(http.MethodPost, "/ingest/batch", bytes.NewReader(batch)))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected batch status 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var batchPayload map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &batchPayload)
+	if batchPayload["sampled"] != float64(1) || batchPayload["accepted"] != float64(0) {
+		t.Errorf("Expected the self log to be sampled out, got %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+	"log-processing-system/services/log-ingestion/database"
+	"log-processing-system/services/log-ingestion/logger"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultSessionLimit caps session listings that give no limit
+const defaultSessionLimit = 100
+
+// HandleListSessions returns session summaries written by the correlate
+// pipeline stage, most recently started first
+func (h *Handler) HandleListSessions(w http.ResponseWriter, r *http.Request) {
+	requestID := logger.GetRequestID(r.Context())
+	query := r.URL.Query()
+
+	filter := models.SessionFilter{
+		Source: query.Get("source"),
+		Status: query.Get("status"),
+		Limit:  defaultSessionLimit,
+	}
+	for name, target := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := query.Get(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				http.Error(w, "Invalid '"+name+"' timestamp: must be RFC3339", http.StatusBadRequest)
+				return
+			}
+			*target = &parsed
+		}
+	}
+	if value := query.Get("min_errors"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid min_errors parameter", http.StatusBadRequest)
+			return
+		}
+		filter.MinErrors = parsed
+	}
+	for name, target := range map[string]*int{"limit": &filter.Limit, "offset": &filter.Offset} {
+		if value := query.Get(name); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				http.Error(w, "Invalid "+name+" parameter", http.StatusBadRequest)
+				return
+			}
+			*target = parsed
+		}
+	}
+	if err := filter.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sessions, err := database.ListSessions(filter)
+	if err != nil {
+		h.log.WithFields(map[string]interface{}{
+			"request_id": requestID,
+			"error":      err.Error(),
+		}).ErrorContext(r.Context(), "Failed to retrieve sessions")
+
+		http.Error(w, "Failed to retrieve sessions", http.StatusInternalServerError)
+		return
+	}
+
+	h.log.WithFields(map[string]interface{}{
+		"request_id": requestID,
+		"sessions":   len(sessions),
+	}).DebugContext(r.Context(), "Sessions retrieved")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"sessions": sessions,
+		"count":    len(sessions),
+	})
+}
+
+// HandleGetSession returns the summaries recorded under one trace or session ID
+func (h *Handler) HandleGetSession(w http.ResponseWriter, r *http.Request) {
+	requestID := logger.GetRequestID(r.Context())
+	id := mux.Vars(r)["id"]
+
+	sessions, err := database.GetSessions(id)
+	if err != nil {
+		h.log.WithFields(map[string]interface{}{
+			"request_id": requestID,
+			"session_id": id,
+			"error":      err.Error(),
+		}).ErrorContext(r.Context(), "Failed to retrieve session")
+
+		http.Error(w, "Failed to retrieve session", http.StatusInternalServerError)
+		return
+	}
+	if len(sessions) == 0 {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":       id,
+		"sessions": sessions,
+	})
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/pubsub"
+)
+
+// tailHeartbeatInterval keeps idle live-tail connections open through proxies
+const tailHeartbeatInterval = 15 * time.Second
+
+// HandleLiveTail streams newly stored log entries to the client as
+// Server-Sent Events. Optional `level` and `source` query parameters
+// restrict the stream to matching entries. Entries tagged as noise are left
+// out unless `include_noise=true` is given.
+func (h *Handler) HandleLiveTail(hub *pubsub.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := logger.GetRequestID(r.Context())
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			h.log.WithField("request_id", requestID).ErrorContext(r.Context(), "Streaming not supported by response writer")
+			http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+			return
+		}
+
+		level := r.URL.Query().Get("level")
+		source := r.URL.Query().Get("source")
+		includeNoise := r.URL.Query().Get("include_noise") == "true"
+
+		sub := hub.Subscribe(pubsub.DefaultBufferSize, func(entry models.Log) bool {
+			if level != "" && !strings.EqualFold(entry.Level, level) {
+				return false
+			}
+			if source != "" && entry.Source != source {
+				return false
+			}
+			return includeNoise || !entry.Fields.IsNoise()
+		})
+		defer sub.Close()
+
+		h.log.WithFields(map[string]interface{}{
+			"request_id":    requestID,
+			"level":         level,
+			"source":        source,
+			"include_noise": includeNoise,
+			"subscribers":   hub.SubscriberCount(),
+		}).InfoContext(r.Context(), "Live tail subscriber connected")
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(tailHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		sent := 0
+		for {
+			select {
+			case <-r.Context().Done():
+				h.log.WithFields(map[string]interface{}{
+					"request_id":    requestID,
+					"entries_sent":  sent,
+					"entries_dropped": sub.Dropped(),
+				}).InfoContext(r.Context(), "Live tail subscriber disconnected")
+				return
+			case entry, ok := <-sub.C:
+				if !ok {
+					// The hub was closed as the server shuts down
+					h.log.WithFields(map[string]interface{}{
+						"request_id":   requestID,
+						"entries_sent": sent,
+					}).InfoContext(r.Context(), "Live tail stream ended by shutdown")
+					return
+				}
+				data, err := json.Marshal(entry)
+				if err != nil {
+					h.log.WithFields(map[string]interface{}{
+						"request_id": requestID,
+						"error":      err.Error(),
+					}).WarnContext(r.Context(), "Failed to encode live tail entry")
+					continue
+				}
+				if _, err := fmt.Fprintf(w, "id: %d\nevent: log\ndata: %s\n\n", entry.ID, data); err != nil {
+					return
+				}
+				flusher.Flush()
+				sent++
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+			}
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+	"log-processing-system/services/log-ingestion/pubsub"
+)
+
+func TestHandleLiveTail_EndsOnShutdown(t *testing.T) {
+	h, _ := setupTest()
+	hub := pubsub.NewHub()
+	server := httptest.NewUnstartedServer(h.HandleLiveTail(hub))
+	// As main registers it, so Shutdown does not wait on open streams
+	server.Config.RegisterOnShutdown(hub.Close)
+	server.Start()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/logs/tail")
+	if err != nil {
+		t.Fatalf("Failed to open the stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+
+	hub.Publish(models.Log{ID: 7, Message: "streamed", Level: "info"})
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadString('\n')
+	if err != nil || line != "id: 7\n" {
+		t.Fatalf("Expected the published entry, got %q (%v)", line, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := server.Config.Shutdown(ctx); err != nil {
+		t.Fatalf("Expected shutdown to end the open stream, got %v", err)
+	}
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		t.Errorf("Expected the stream to end cleanly, got %v", err)
+	}
+}
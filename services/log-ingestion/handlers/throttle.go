@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/models"
+	"log-processing-system/services/log-ingestion/throttle"
+)
+
+// SetThrottle enables per-source ingest budgets
+func (h *Handler) SetThrottle(l *throttle.Limiter) {
+	h.limiter = l
+}
+
+// throttleEntry applies the source's ingest budget to a parsed entry, tagging
+// entries kept by sampling with the number of entries they stand for
+func (h *Handler) throttleEntry(ctx context.Context, entry *models.Log) throttle.Decision {
+	if h.limiter == nil {
+		return throttle.Decision{Action: throttle.Accept}
+	}
+
+	decision := h.limiter.Check(entry.Source, entry.Level)
+	if decision.Action == throttle.Accept && decision.SampleRate > 0 {
+		if entry.Fields == nil {
+			entry.Fields = make(models.Fields)
+		}
+		entry.Fields[models.SampleRateField] = math.Round(decision.SampleRate*100) / 100
+	}
+	if decision.Action != throttle.Accept {
+		h.log.WithFields(map[string]interface{}{
+			"request_id": logger.GetRequestID(ctx),
+			"log_source": entry.Source,
+			"action":     string(decision.Action),
+		}).DebugContext(ctx, "Log entry throttled")
+	}
+	return decision
+}
+
+// throttledMessage explains a throttled entry
+func throttledMessage(source string, decision throttle.Decision) string {
+	if decision.Action == throttle.Sample {
+		return fmt.Sprintf("Source %q is over its ingest budget of %g entries/s and is being sampled", source, decision.Limit)
+	}
+	return fmt.Sprintf("Source %q exceeded its ingest budget of %g entries/s", source, decision.Limit)
+}
+
+// writeThrottled answers a throttled submission: 202 when the entry was
+// sampled out, 429 with a Retry-After when the source is rejected
+func writeThrottled(w http.ResponseWriter, requestID, source string, decision throttle.Decision) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if decision.Action == throttle.Sample {
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":     "sampled_out",
+			"message":    throttledMessage(source, decision),
+			"request_id": requestID,
+		})
+		return
+	}
+
+	retryAfter := int(math.Ceil(decision.RetryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":              "throttled",
+		"message":             throttledMessage(source, decision),
+		"source":              source,
+		"limit":               decision.Limit,
+		"rate":                math.Round(decision.Rate*100) / 100,
+		"retry_after_seconds": retryAfter,
+		"request_id":          requestID,
+	})
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/throttle"
+)
+
+func TestHandleLogIngestion_Throttled(t *testing.T) {
+	h, _ := setupTest()
+	h.SetThrottle(throttle.NewLimiter(throttle.Config{Rate: 0.01, SampleUntil: 1, Window: time.Minute},
+		logger.New(logger.Config{Service: "test-service", Component: "throttle"})))
+
+	body := []byte(`{"message":"flood","level":"info","source":"noisy"}`)
+	// A single entry a minute is already over a budget of 0.01 entries/s
+	rec := httptest.NewRecorder()
+	h.HandleBatchIngestion(rec, httptest.NewRequest(http.MethodPost, "/ingest/batch", bytes.NewReader(append(append([]byte("["), body...), ']'))))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected batch status 429, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.HandleLogIngestion(rec, httptest.NewRequest(http.MethodPost, "/ingest", bytes.NewReader(body)))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected a Retry-After header")
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("Expected a JSON payload, got %s", rec.Body.String())
+	}
+	if payload["status"] != "throttled" || payload["source"] != "noisy" || payload["limit"] != 0.01 {
+		t.Errorf("Unexpected payload: %v", payload)
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/models"
+	"log-processing-system/services/log-ingestion/writequeue"
+)
+
+// SetWriteQueue makes the ingestion handlers answer once entries are
+// validated, and store them from the queue
+func (h *Handler) SetWriteQueue(q *writequeue.Queue) {
+	h.writes = q
+}
+
+// queueLogEntry hands a validated entry to the write queue. It returns false
+// when the entry must be stored in the request instead: without a write
+// queue, or when it is closed or filled up after the request was admitted,
+// so the entries of admitted requests are not dropped.
+func (h *Handler) queueLogEntry(ctx context.Context, entry *models.Log) bool {
+	if h.writes == nil {
+		return false
+	}
+	err := h.writes.Enqueue(ctx, entry)
+	if errors.Is(err, writequeue.ErrFull) {
+		h.log.WithFields(map[string]interface{}{
+			"request_id": logger.GetRequestID(ctx),
+			"log_source": entry.Source,
+			"capacity":   h.writes.Capacity(),
+		}).WarnContext(ctx, "Write queue is full, storing log entry in the request")
+	}
+	return err == nil
+}
+
+// StoreQueuedLog stores an entry from the write queue, dead-lettering it
+// when that fails, as the request that submitted it would have
+func (h *Handler) StoreQueuedLog(ctx context.Context, entry *models.Log) error {
+	requestID := logger.GetRequestID(ctx)
+	if err := h.storeLogEntry(ctx, entry); err != nil {
+		_, deadLettered := h.deadLetterEntry(ctx, entry, err)
+		h.log.WithFields(map[string]interface{}{
+			"request_id":    requestID,
+			"error":         err.Error(),
+			"log_entry":     entry,
+			"dead_lettered": deadLettered,
+		}).ErrorContext(ctx, "Failed to store queued log entry in database")
+		return err
+	}
+
+	h.log.WithFields(map[string]interface{}{
+		"request_id":     requestID,
+		"log_level":      entry.Level,
+		"log_source":     entry.Source,
+		"message_length": len(entry.Message),
+	}).InfoContext(ctx, "Log entry stored successfully")
+
+	h.log.LogBusinessEvent("log_ingested", requestID, map[string]interface{}{
+		"log_level":  entry.Level,
+		"log_source": entry.Source,
+		"timestamp":  entry.Timestamp,
+	})
+	return nil
+}
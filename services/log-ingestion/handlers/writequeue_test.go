@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"log-processing-system/services/log-ingestion/writequeue"
+)
+
+func TestHandleLogIngestion_WriteQueue(t *testing.T) {
+	h, db := setupTest()
+	q := writequeue.New(writequeue.Config{Size: 10, Workers: 1}, h.StoreQueuedLog)
+	h.SetWriteQueue(q)
+
+	body := []byte(`{"message":"queued","level":"info","source":"api"}`)
+	rec := httptest.NewRecorder()
+	h.HandleLogIngestion(rec, httptest.NewRequest(http.MethodPost, "/ingest", bytes.NewReader(body)))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var payload map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil || payload["message"] != "Log entry accepted for storage" {
+		t.Errorf("Expected the entry accepted for storage, got %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.HandleBatchIngestion(rec, httptest.NewRequest(http.MethodPost, "/ingest/batch", bytes.NewReader(append(append([]byte("["), body...), ']'))))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected batch status 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// Draining the queue stores what the requests accepted
+	if err := q.Close(context.Background()); err != nil {
+		t.Fatalf("Failed to drain the write queue: %v", err)
+	}
+	if len(db.logs) != 2 || db.logs[0].Message != "queued" {
+		t.Errorf("Expected both entries stored by the queue, got %+v", db.logs)
+	}
+
+	// A closed queue leaves storing to the request
+	rec = httptest.NewRecorder()
+	h.HandleLogIngestion(rec, httptest.NewRequest(http.MethodPost, "/ingest", bytes.NewReader(body)))
+	if rec.Code != http.StatusAccepted || len(db.logs) != 3 {
+		t.Errorf("Expected the entry stored in the request, got %d with %d stored", rec.Code, len(db.logs))
+	}
+}
@@ -0,0 +1,163 @@
+// Package health checks the dependencies of a service, such as Postgres,
+// the queue broker and object storage, and reports each one's status rather
+// than a single up or down.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CheckFunc checks one dependency. It should give up when ctx is done; a
+// check that does not is abandoned at the timeout.
+type CheckFunc func(ctx context.Context) error
+
+// Dependency statuses
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Overall statuses of a Report
+const (
+	Healthy   = "healthy"   // every dependency is up
+	Degraded  = "degraded"  // a dependency the service can work without is down
+	Unhealthy = "unhealthy" // a dependency the service needs is down
+)
+
+// Result is the outcome of one dependency's last check
+type Result struct {
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Critical  bool      `json:"critical"`
+	LatencyMs float64   `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Report is the status of every dependency, in the order they were added
+type Report struct {
+	Status    string    `json:"status"`
+	Checks    []Result  `json:"checks"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type check struct {
+	name     string
+	critical bool
+	fn       CheckFunc
+}
+
+// Checker runs the checks of a service's dependencies concurrently, each
+// within a timeout. A report is reused for cacheFor, so frequent probes do
+// not put load on the dependencies.
+type Checker struct {
+	timeout  time.Duration
+	cacheFor time.Duration
+	now      func() time.Time
+
+	checks []check
+
+	mu   sync.Mutex
+	last *Report
+}
+
+// NewChecker creates a checker without checks; zero values fall back to a
+// timeout of two seconds and no caching
+func NewChecker(timeout, cacheFor time.Duration) *Checker {
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	return &Checker{timeout: timeout, cacheFor: cacheFor, now: time.Now}
+}
+
+// Add registers a dependency. While a critical one is down the service is
+// unhealthy; any other is reported as degraded. Add all checks before the
+// first Check.
+func (c *Checker) Add(name string, critical bool, fn CheckFunc) {
+	c.checks = append(c.checks, check{name: name, critical: critical, fn: fn})
+}
+
+// Check returns the status of every dependency, checking them unless the
+// last report is recent enough
+func (c *Checker) Check(ctx context.Context) Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.last != nil && c.now().Sub(c.last.Timestamp) < c.cacheFor {
+		return *c.last
+	}
+
+	report := Report{Status: Healthy, Checks: make([]Result, len(c.checks)), Timestamp: c.now()}
+	var wg sync.WaitGroup
+	for i, ch := range c.checks {
+		wg.Add(1)
+		go func(i int, ch check) {
+			defer wg.Done()
+			report.Checks[i] = c.run(ctx, ch)
+		}(i, ch)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status == StatusUp {
+			continue
+		}
+		if result.Critical {
+			report.Status = Unhealthy
+		} else if report.Status == Healthy {
+			report.Status = Degraded
+		}
+	}
+
+	c.last = &report
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, ch check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	checkedAt, start := c.now(), time.Now()
+	// A check that does not give up with ctx is abandoned at the timeout
+	done := make(chan error, 1)
+	go func() { done <- ch.fn(ctx) }()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("check did not finish within %s: %w", c.timeout, ctx.Err())
+	}
+	result := Result{
+		Name:      ch.name,
+		Status:    StatusUp,
+		Critical:  ch.critical,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		CheckedAt: checkedAt,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Handler serves the report as JSON, with status 503 while the service is
+// unhealthy so load balancers and probes can act on it
+func Handler(c *Checker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := c.Check(r.Context())
+
+		status := http.StatusOK
+		if report.Status == Unhealthy {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(report)
+	}
+}
@@ -0,0 +1,172 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChecker_Statuses(t *testing.T) {
+	c := NewChecker(time.Second, 0)
+	c.Add("postgres", true, func(ctx context.Context) error { return nil })
+	c.Add("archive", false, func(ctx context.Context) error { return errors.New("bucket not found") })
+
+	report := c.Check(context.Background())
+	if report.Status != Degraded {
+		t.Errorf("Expected degraded with an optional dependency down, got %s", report.Status)
+	}
+	if len(report.Checks) != 2 || report.Checks[0].Name != "postgres" || report.Checks[0].Status != StatusUp {
+		t.Fatalf("Expected the checks in order, got %+v", report.Checks)
+	}
+	if archive := report.Checks[1]; archive.Status != StatusDown || archive.Error != "bucket not found" || archive.Critical {
+		t.Errorf("Expected the archive down with its error, got %+v", archive)
+	}
+
+	c.Add("queue", true, func(ctx context.Context) error { return errors.New("connection refused") })
+	if report := c.Check(context.Background()); report.Status != Unhealthy {
+		t.Errorf("Expected unhealthy with a critical dependency down, got %s", report.Status)
+	}
+}
+
+func TestChecker_Timeout(t *testing.T) {
+	c := NewChecker(20*time.Millisecond, 0)
+	release := make(chan struct{})
+	defer close(release)
+	// Ignores its context
+	c.Add("stuck", true, func(ctx context.Context) error { <-release; return nil })
+
+	start := time.Now()
+	report := c.Check(context.Background())
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected the stuck check to be abandoned, took %s", elapsed)
+	}
+	if result := report.Checks[0]; result.Status != StatusDown || !strings.Contains(result.Error, "did not finish") {
+		t.Errorf("Expected the stuck check reported down, got %+v", result)
+	}
+}
+
+func TestChecker_Cache(t *testing.T) {
+	c := NewChecker(time.Second, 5*time.Second)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	calls := 0
+	c.Add("postgres", true, func(ctx context.Context) error { calls++; return nil })
+
+	c.Check(context.Background())
+	now = now.Add(time.Second)
+	c.Check(context.Background())
+	if calls != 1 {
+		t.Errorf("Expected a recent report to be reused, got %d checks", calls)
+	}
+
+	now = now.Add(5 * time.Second)
+	c.Check(context.Background())
+	if calls != 2 {
+		t.Errorf("Expected a new check once the report is stale, got %d checks", calls)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	c := NewChecker(time.Second, 0)
+	up := true
+	c.Add("postgres", true, func(ctx context.Context) error {
+		if !up {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+
+	rec := httptest.NewRecorder()
+	Handler(c)(rec, httptest.NewRequest(http.MethodGet, "/health/details", nil))
+	var report Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil || rec.Code != http.StatusOK || report.Status != Healthy {
+		t.Errorf("Expected status 200 and a healthy report, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	up = false
+	rec = httptest.NewRecorder()
+	Handler(c)(rec, httptest.NewRequest(http.MethodGet, "/health/details", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 while unhealthy, got %d", rec.Code)
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	c := NewChecker(time.Second, 0)
+	var calls int
+	c.Add("schema", true, func(ctx context.Context) error {
+		if calls++; calls < 3 {
+			return errors.New("missing table logs")
+		}
+		return nil
+	})
+	r := NewReadiness()
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), Starting) {
+		t.Errorf("Expected status 503 while starting, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var waited int
+	if err := r.Await(context.Background(), c, time.Millisecond, func(Report) { waited++ }); err != nil {
+		t.Fatalf("Await failed: %v", err)
+	}
+	if !r.Ready() || waited != 2 {
+		t.Errorf("Expected ready after 2 failed rounds, got %s after %d", r.State(), waited)
+	}
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200 once ready, got %d", rec.Code)
+	}
+
+	r.Stop()
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable || r.State() != Stopping {
+		t.Errorf("Expected status 503 once stopping, got %d", rec.Code)
+	}
+}
+
+func TestReadiness_AwaitCancelled(t *testing.T) {
+	c := NewChecker(time.Second, 0)
+	c.Add("postgres", true, func(ctx context.Context) error { return errors.New("connection refused") })
+	r := NewReadiness()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := r.Await(ctx, c, time.Millisecond, nil); err == nil || r.Ready() {
+		t.Errorf("Expected Await to give up with its context, got %v", err)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	readiness := NewReadiness()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"healthy"}`))
+	})
+	mux.Handle("/readyz", readiness)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	statuses, err := Probe(context.Background(), server.Client(), server.URL, "/healthz", "/readyz")
+	if err == nil || err.Error() != "/readyz: 503 starting" || len(statuses) != 2 || statuses[0] != "healthy" {
+		t.Errorf("Expected readiness to fail while starting, got %v, %v", statuses, err)
+	}
+
+	readiness.Await(context.Background(), NewChecker(0, 0), time.Millisecond, nil)
+	if statuses, err := Probe(context.Background(), server.Client(), server.URL+"/", "/healthz", "/readyz"); err != nil || statuses[1] != "ready" {
+		t.Errorf("Expected the service to be ready, got %v, %v", statuses, err)
+	}
+
+	if _, err := Probe(context.Background(), server.Client(), "http://127.0.0.1:1", "/healthz"); err == nil {
+		t.Error("Expected an error when nothing listens")
+	}
+}
@@ -0,0 +1,43 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Probe asks a running service at baseURL for each of paths, such as
+// /healthz and /readyz, and returns the status each one answered with. It
+// returns an error for the first that did not answer with a 2xx status.
+func Probe(ctx context.Context, client *http.Client, baseURL string, paths ...string) ([]string, error) {
+	var statuses []string
+	for _, path := range paths {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(baseURL, "/")+path, nil)
+		if err != nil {
+			return statuses, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return statuses, fmt.Errorf("%s: %w", path, err)
+		}
+		// The JSON health endpoints answer with a status field, such as
+		// unhealthy or starting
+		var body struct {
+			Status string `json:"status"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&body)
+		resp.Body.Close()
+		status := body.Status
+		if status == "" {
+			status = http.StatusText(resp.StatusCode)
+		}
+		statuses = append(statuses, status)
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return statuses, fmt.Errorf("%s: %d %s", path, resp.StatusCode, status)
+		}
+	}
+	return statuses, nil
+}
@@ -0,0 +1,86 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Readiness states
+const (
+	Starting = "starting" // the startup checks have not passed yet
+	Ready    = "ready"
+	Stopping = "stopping" // shutting down; finishing what was accepted
+)
+
+var readinessStates = []string{Starting, Ready, Stopping}
+
+// Readiness tells whether a service should be sent traffic: not until its
+// startup checks have passed once, and no longer once it is shutting down.
+// Unlike the health reports it does not change while a dependency is down
+// for a moment, so a brief outage does not take every instance out of the
+// load balancer at once.
+type Readiness struct {
+	state int32 // index into readinessStates
+}
+
+// NewReadiness creates a readiness that is starting
+func NewReadiness() *Readiness {
+	return &Readiness{}
+}
+
+// State returns Starting, Ready or Stopping
+func (r *Readiness) State() string {
+	return readinessStates[atomic.LoadInt32(&r.state)]
+}
+
+// Ready reports whether the service should be sent traffic
+func (r *Readiness) Ready() bool {
+	return r.State() == Ready
+}
+
+// Await runs the checks of c every interval until none of the critical
+// ones fails, then marks the service ready. Each failed round is passed to
+// waiting, for logging. It returns ctx's error if ctx is done first. c
+// should not cache reports.
+func (r *Readiness) Await(ctx context.Context, c *Checker, interval time.Duration, waiting func(Report)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		report := c.Check(ctx)
+		if report.Status != Unhealthy {
+			// Not ready once stopping, even if the checks pass only then
+			atomic.CompareAndSwapInt32(&r.state, 0, 1)
+			return nil
+		}
+		if waiting != nil {
+			waiting(report)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop marks the service as shutting down, so probes take it out of the
+// load balancer while it drains
+func (r *Readiness) Stop() {
+	atomic.StoreInt32(&r.state, 2)
+}
+
+// ServeHTTP answers readiness probes with the state as JSON, with status
+// 503 unless the service is ready
+func (r *Readiness) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	state := r.State()
+	status := http.StatusOK
+	if state != Ready {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"status": state})
+}
@@ -0,0 +1,231 @@
+package integrity
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+// GenesisHash is the previous hash of the first entry in a chain
+var GenesisHash = strings.Repeat("0", sha256.Size*2)
+
+// maxReportedBreaks bounds the size of a verification report
+const maxReportedBreaks = 100
+
+// ChainedLog is a stored log entry together with its chain hashes
+type ChainedLog struct {
+	Log       models.Log
+	PrevHash  string
+	EntryHash string
+}
+
+// Break describes a point where the stored chain does not verify
+type Break struct {
+	ID       int    `json:"id"`
+	Reason   string `json:"reason"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// Report summarizes a chain verification
+type Report struct {
+	Valid      bool      `json:"valid"`
+	Checked    int64     `json:"checked"`
+	FirstID    int       `json:"first_id,omitempty"`
+	LastID     int       `json:"last_id,omitempty"`
+	BreakCount int64     `json:"break_count"`
+	Breaks     []Break   `json:"breaks,omitempty"`
+	VerifiedAt time.Time `json:"verified_at"`
+}
+
+// NormalizeTimestamp truncates to the precision Postgres stores so hashes
+// computed before insert match hashes recomputed from stored rows
+func NormalizeTimestamp(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Microsecond)
+}
+
+// ComputeHash returns the hash of an entry linked to the previous entry's hash
+func ComputeHash(prevHash string, entry models.Log) string {
+	h := sha256.New()
+	h.Write([]byte(prevHash))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(NormalizeTimestamp(entry.Timestamp).Format(time.RFC3339Nano)))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(entry.Level))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(entry.Source))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(entry.Message))
+	if len(entry.Fields) > 0 {
+		if fields, err := canonicalFields(entry.Fields); err == nil {
+			h.Write([]byte{'\n'})
+			h.Write(fields)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// DecodeFields decodes fields read back from the database keeping numbers
+// exact: decoding into float64, as models.Fields does, rounds integers
+// above 2^53, which would no longer hash as they did when stored
+func DecodeFields(data []byte) (models.Fields, error) {
+	if data == nil {
+		return nil, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var fields models.Fields
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// canonicalFields marshals fields so that the same values hash the same
+// before they are stored and after Postgres hands them back. Map keys are
+// marshaled in sorted order; numbers are written as their exact decimal
+// value, as JSONB rewrites 1e+21 as 1000000000000000000000 and keeps 1.0.
+func canonicalFields(fields models.Fields) ([]byte, error) {
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(canonicalValue(value))
+}
+
+// canonicalValue rewrites the numbers in a decoded JSON value
+func canonicalValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = canonicalValue(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = canonicalValue(item)
+		}
+	case json.Number:
+		return canonicalNumber(v)
+	}
+	return value
+}
+
+// canonicalNumber returns n as an exact decimal without exponent or
+// trailing zeros
+func canonicalNumber(n json.Number) json.Number {
+	r, ok := new(big.Rat).SetString(string(n))
+	if !ok {
+		return n
+	}
+	if r.IsInt() {
+		return json.Number(r.Num().String())
+	}
+	// A JSON number is a finite decimal, so some power of ten is a
+	// multiple of its denominator; that power gives the digits needed
+	digits := 0
+	scale := big.NewInt(1)
+	ten := big.NewInt(10)
+	for new(big.Int).Mod(scale, r.Denom()).Sign() != 0 {
+		scale.Mul(scale, ten)
+		digits++
+	}
+	return json.Number(r.FloatString(digits))
+}
+
+// Verifier checks chained entries one at a time in id order
+type Verifier struct {
+	prevHash string
+	report   Report
+}
+
+// NewVerifier creates a verifier for a chain starting at GenesisHash
+func NewVerifier() *Verifier {
+	return &Verifier{
+		prevHash: GenesisHash,
+		report:   Report{Valid: true},
+	}
+}
+
+// Check verifies the next entry in the chain
+func (v *Verifier) Check(entry ChainedLog) {
+	if v.report.Checked == 0 {
+		v.report.FirstID = entry.Log.ID
+	}
+	v.report.Checked++
+	v.report.LastID = entry.Log.ID
+
+	if entry.PrevHash != v.prevHash {
+		v.addBreak(Break{
+			ID:       entry.Log.ID,
+			Reason:   "previous hash mismatch (entry removed or reordered)",
+			Expected: v.prevHash,
+			Actual:   entry.PrevHash,
+		})
+	}
+
+	if expected := ComputeHash(entry.PrevHash, entry.Log); entry.EntryHash != expected {
+		v.addBreak(Break{
+			ID:       entry.Log.ID,
+			Reason:   "entry hash mismatch (entry modified)",
+			Expected: expected,
+			Actual:   entry.EntryHash,
+		})
+	}
+
+	// Continue from the stored hash so one tampered entry is reported once
+	v.prevHash = entry.EntryHash
+}
+
+// Report returns the verification result so far
+func (v *Verifier) Report() Report {
+	report := v.report
+	report.VerifiedAt = time.Now().UTC()
+	return report
+}
+
+// MergeReports combines the reports of separately verified chains, such as
+// those of several shards, into one that is valid if all of them are
+func MergeReports(reports ...Report) Report {
+	merged := Report{Valid: true}
+	for _, report := range reports {
+		merged.Valid = merged.Valid && report.Valid
+		if report.Checked > 0 {
+			if merged.Checked == 0 || report.FirstID < merged.FirstID {
+				merged.FirstID = report.FirstID
+			}
+			if report.LastID > merged.LastID {
+				merged.LastID = report.LastID
+			}
+		}
+		merged.Checked += report.Checked
+		merged.BreakCount += report.BreakCount
+		for _, b := range report.Breaks {
+			if len(merged.Breaks) < maxReportedBreaks {
+				merged.Breaks = append(merged.Breaks, b)
+			}
+		}
+		if report.VerifiedAt.After(merged.VerifiedAt) {
+			merged.VerifiedAt = report.VerifiedAt
+		}
+	}
+	return merged
+}
+
+func (v *Verifier) addBreak(b Break) {
+	v.report.Valid = false
+	v.report.BreakCount++
+	if len(v.report.Breaks) < maxReportedBreaks {
+		v.report.Breaks = append(v.report.Breaks, b)
+	}
+}
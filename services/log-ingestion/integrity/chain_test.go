@@ -0,0 +1,136 @@
+package integrity
+
+import (
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func buildChain(entries []models.Log) []ChainedLog {
+	prev := GenesisHash
+	chain := make([]ChainedLog, len(entries))
+	for i, entry := range entries {
+		hash := ComputeHash(prev, entry)
+		chain[i] = ChainedLog{Log: entry, PrevHash: prev, EntryHash: hash}
+		prev = hash
+	}
+	return chain
+}
+
+func testEntries() []models.Log {
+	now := time.Date(2025, 8, 29, 10, 15, 30, 123456789, time.UTC)
+	return []models.Log{
+		{ID: 1, Message: "first", Level: "info", Source: "auth", Timestamp: now},
+		{ID: 2, Message: "second", Level: "warn", Source: "auth", Timestamp: now.Add(time.Second)},
+		{ID: 3, Message: "third", Level: "error", Source: "billing", Timestamp: now.Add(2 * time.Second)},
+	}
+}
+
+func verify(chain []ChainedLog) Report {
+	verifier := NewVerifier()
+	for _, entry := range chain {
+		verifier.Check(entry)
+	}
+	return verifier.Report()
+}
+
+func TestVerifier_ValidChain(t *testing.T) {
+	report := verify(buildChain(testEntries()))
+
+	if !report.Valid {
+		t.Errorf("Expected valid chain, got breaks %+v", report.Breaks)
+	}
+	if report.Checked != 3 || report.FirstID != 1 || report.LastID != 3 {
+		t.Errorf("Unexpected report counters: %+v", report)
+	}
+}
+
+func TestVerifier_DetectsModifiedEntry(t *testing.T) {
+	chain := buildChain(testEntries())
+	chain[1].Log.Message = "tampered"
+
+	report := verify(chain)
+
+	if report.Valid {
+		t.Fatalf("Expected modified entry to break the chain")
+	}
+	if report.BreakCount != 1 || report.Breaks[0].ID != 2 {
+		t.Errorf("Expected a single break at entry 2, got %+v", report.Breaks)
+	}
+}
+
+func TestVerifier_DetectsRemovedEntry(t *testing.T) {
+	chain := buildChain(testEntries())
+	chain = append(chain[:1], chain[2:]...)
+
+	report := verify(chain)
+
+	if report.Valid {
+		t.Fatalf("Expected removed entry to break the chain")
+	}
+	if report.Breaks[0].ID != 3 {
+		t.Errorf("Expected break reported at entry 3, got %d", report.Breaks[0].ID)
+	}
+}
+
+func TestComputeHash_TimestampPrecision(t *testing.T) {
+	entry := testEntries()[0]
+	stored := entry
+	stored.Timestamp = NormalizeTimestamp(entry.Timestamp).In(time.FixedZone("CEST", 2*3600))
+
+	if ComputeHash(GenesisHash, entry) != ComputeHash(GenesisHash, stored) {
+		t.Errorf("Expected hash to be stable across storage precision and time zone")
+	}
+}
+
+func TestMergeReports(t *testing.T) {
+	entries := testEntries()
+	first := verify(buildChain(entries[:2]))
+	tampered := buildChain(entries[2:])
+	tampered[0].Log.ID = 7
+	tampered[0].EntryHash = "tampered"
+	second := verify(tampered)
+
+	merged := MergeReports(first, second, verify(nil))
+	if merged.Valid || merged.Checked != 3 || merged.BreakCount != 1 || merged.Breaks[0].ID != 7 {
+		t.Errorf("Expected the break of the second chain in the merged report, got %+v", merged)
+	}
+	if merged.FirstID != 1 || merged.LastID != 7 {
+		t.Errorf("Expected ids 1 to 7 checked, got %d to %d", merged.FirstID, merged.LastID)
+	}
+	if single := MergeReports(first); !single.Valid || single.Checked != 2 {
+		t.Errorf("Expected a single report unchanged, got %+v", single)
+	}
+}
+
+func TestComputeHash_FieldsRoundTrip(t *testing.T) {
+	entry := testEntries()[0]
+	entry.Fields = models.Fields{
+		"trace_id": int64(9007199254740993),
+		"ratio":    1.5e-7,
+		"bytes":    1e21,
+		"retry":    map[string]interface{}{"attempt": 2.0, "delays": []interface{}{0.5, 1}},
+	}
+	stored := ComputeHash(GenesisHash, entry)
+
+	// As Postgres returns the JSONB column: keys reordered, numbers rewritten
+	fields, err := DecodeFields([]byte(`{"bytes": 1000000000000000000000, "ratio": 0.00000015, "retry": {"delays": [0.50, 1.0], "attempt": 2}, "trace_id": 9007199254740993}`))
+	if err != nil {
+		t.Fatalf("Failed to decode fields: %v", err)
+	}
+	read := entry
+	read.Fields = fields
+	if got := ComputeHash(GenesisHash, read); got != stored {
+		t.Errorf("Expected the stored fields to hash as before they were stored, got %s and %s", got, stored)
+	}
+
+	// Fields are covered by the hash, down to integers float64 cannot hold
+	read.Fields = models.Fields{}
+	for k, v := range fields {
+		read.Fields[k] = v
+	}
+	read.Fields["trace_id"] = int64(9007199254740992)
+	if ComputeHash(GenesisHash, read) == stored {
+		t.Error("Expected a changed field to change the hash")
+	}
+}
@@ -0,0 +1,174 @@
+// Package lexer splits the source of the transform and script languages
+// into tokens. Both languages share their literals, paths and comments and
+// differ in their operators, which each passes to Tokenize.
+package lexer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Kind is the kind of a token
+type Kind int
+
+// Token kinds
+const (
+	EOF Kind = iota
+	Newline
+	Ident
+	Path
+	String
+	Number
+	Op
+)
+
+// Token is a token and where it starts in the source
+type Token struct {
+	Kind Kind
+	// Text is the token as written, a string's unquoted value or a path
+	// without its dot
+	Text string
+	Line int
+	Col  int
+}
+
+func (t Token) String() string {
+	switch t.Kind {
+	case EOF:
+		return "end of script"
+	case Newline:
+		return "end of line"
+	case Path:
+		return "." + t.Text
+	case String:
+		return strconv.Quote(t.Text)
+	}
+	return t.Text
+}
+
+// Error is a malformed token
+type Error struct {
+	Line int
+	Col  int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Col, e.Msg)
+}
+
+// Tokenize splits source into tokens ending with an EOF token. operators
+// are matched in order, so longer ones must come first. Newlines are tokens
+// and a # starts a comment that runs to the end of the line. A - directly
+// before a digit starts a negative number unless - is an operator.
+func Tokenize(source string, operators []string) ([]Token, error) {
+	var tokens []Token
+	line, lineStart := 1, 0
+	i := 0
+	minusOp := false
+	for _, op := range operators {
+		minusOp = minusOp || op == "-"
+	}
+
+	emit := func(kind Kind, text string, start int) {
+		tokens = append(tokens, Token{Kind: kind, Text: text, Line: line, Col: start - lineStart + 1})
+	}
+	errorf := func(at int, format string, args ...interface{}) error {
+		return &Error{Line: line, Col: at - lineStart + 1, Msg: fmt.Sprintf(format, args...)}
+	}
+
+	for i < len(source) {
+		c := rune(source[i])
+
+		switch {
+		case c == '\n':
+			emit(Newline, "\n", i)
+			i++
+			line, lineStart = line+1, i
+
+		case unicode.IsSpace(c):
+			i++
+
+		case c == '#':
+			for i < len(source) && source[i] != '\n' {
+				i++
+			}
+
+		case c == '"':
+			end := i + 1
+			for end < len(source) && source[end] != '"' && source[end] != '\n' {
+				if source[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(source) || source[end] != '"' {
+				return nil, errorf(i, "unterminated string")
+			}
+			value, err := strconv.Unquote(source[i : end+1])
+			if err != nil {
+				return nil, errorf(i, "invalid string: %v", err)
+			}
+			emit(String, value, i)
+			i = end + 1
+
+		case c == '.':
+			end := i + 1
+			if end < len(source) && isPathStart(rune(source[end])) {
+				for end < len(source) && isPathChar(rune(source[end])) {
+					end++
+				}
+			}
+			if end == i+1 {
+				return nil, errorf(i, "empty path")
+			}
+			emit(Path, source[i+1:end], i)
+			i = end
+
+		case unicode.IsDigit(c) || c == '-' && !minusOp && i+1 < len(source) && unicode.IsDigit(rune(source[i+1])):
+			end := i + 1
+			for end < len(source) && (unicode.IsDigit(rune(source[end])) || source[end] == '.') {
+				end++
+			}
+			emit(Number, source[i:end], i)
+			i = end
+
+		case unicode.IsLetter(c) || c == '_':
+			end := i + 1
+			for end < len(source) && (unicode.IsLetter(rune(source[end])) || unicode.IsDigit(rune(source[end])) || source[end] == '_') {
+				end++
+			}
+			emit(Ident, source[i:end], i)
+			i = end
+
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(source[i:], op) {
+					emit(Op, op, i)
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, errorf(i, "unexpected character %q", c)
+			}
+		}
+	}
+
+	tokens = append(tokens, Token{Kind: EOF, Line: line, Col: i - lineStart + 1})
+	return tokens, nil
+}
+
+func isPathStart(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_'
+}
+
+// isPathChar allows dotted names such as .http.status, matching the keys
+// json_flatten produces
+func isPathChar(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == '.' || c == '-'
+}
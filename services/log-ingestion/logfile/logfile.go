@@ -0,0 +1,294 @@
+// Package logfile maps the lines of log files to log entries: plain text
+// lines through a template, NDJSON lines through a mapping of their keys.
+// It is shared by the logctl importer, the log agent and client.Writer.
+package logfile
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+// Line formats
+const (
+	Text   = "text"
+	NDJSON = "ndjson"
+)
+
+// Config says how lines map to entries. Zero values select the defaults.
+type Config struct {
+	Format     string            // Text or NDJSON, Text by default
+	Template   string            // template of text lines, {message} by default
+	Keys       map[string]string // NDJSON keys of the message, level, source and timestamp
+	TimeFormat string            // Go layout of timestamps, RFC3339 by default
+	Level      string            // level of entries without a known one, info by default
+	Source     string            // source of entries without one
+	Fields     models.Fields     // added to every entry, unless the line sets them
+}
+
+// Mapper maps lines to entries
+type Mapper struct {
+	format     string
+	pattern    *regexp.Regexp
+	keys       map[string]string
+	timeFormat string
+	level      string
+	source     string
+	fields     models.Fields
+}
+
+// NewMapper returns a mapper for cfg.
+//
+// A template such as "{timestamp} {level} [{source}] {message}" matches
+// each line against its literal text, with spaces matching any run of
+// whitespace. {timestamp}, {level}, {source} and {message} fill the entry;
+// any other {name} becomes a field.
+func NewMapper(cfg Config) (*Mapper, error) {
+	m := &Mapper{
+		format:     cfg.Format,
+		keys:       cfg.Keys,
+		timeFormat: cfg.TimeFormat,
+		level:      cfg.Level,
+		source:     cfg.Source,
+		fields:     cfg.Fields,
+	}
+	if m.format == "" {
+		m.format = Text
+	}
+	if m.format != Text && m.format != NDJSON {
+		return nil, fmt.Errorf("invalid format %q: expected text or ndjson", cfg.Format)
+	}
+	template := cfg.Template
+	if template == "" {
+		template = "{message}"
+	}
+	var err error
+	if m.pattern, err = compileTemplate(template); err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	for field := range m.keys {
+		switch field {
+		case "message", "level", "source", "timestamp":
+		default:
+			return nil, fmt.Errorf("unknown field %q: expected message, level, source or timestamp", field)
+		}
+	}
+	if m.timeFormat == "" {
+		m.timeFormat = time.RFC3339Nano
+	}
+	if m.level == "" {
+		m.level = "info"
+	}
+	if NormalizeLevel(m.level) == "" {
+		return nil, fmt.Errorf("invalid level %q", m.level)
+	}
+	m.level = NormalizeLevel(m.level)
+	return m, nil
+}
+
+// Map maps a line, without its line break, to an entry
+func (m *Mapper) Map(line string) (models.Log, error) {
+	values := make(map[string]interface{})
+	if m.format == NDJSON {
+		if err := json.Unmarshal([]byte(line), &values); err != nil {
+			return models.Log{}, fmt.Errorf("invalid JSON: %v", err)
+		}
+	} else {
+		match := m.pattern.FindStringSubmatch(line)
+		if match == nil {
+			return models.Log{}, errors.New("line does not match the template")
+		}
+		for i, name := range m.pattern.SubexpNames() {
+			if i > 0 && name != "" {
+				values[name] = match[i]
+			}
+		}
+	}
+	return m.entry(values)
+}
+
+// entry builds an entry from mapped values; values of keys that are not
+// entry fields become fields
+func (m *Mapper) entry(values map[string]interface{}) (models.Log, error) {
+	take := func(field string) (interface{}, bool) {
+		key := field
+		if mapped, ok := m.keys[field]; ok {
+			key = mapped
+		}
+		value, ok := values[key]
+		delete(values, key)
+		return value, ok && value != nil
+	}
+
+	entry := models.Log{Level: m.level, Source: m.source}
+	message, ok := take("message")
+	if !ok {
+		return entry, errors.New("no message")
+	}
+	entry.Message = fmt.Sprint(message)
+	if level, ok := take("level"); ok {
+		if normalized := NormalizeLevel(fmt.Sprint(level)); normalized != "" {
+			entry.Level = normalized
+		} else {
+			values["original_level"] = level
+		}
+	}
+	if source, ok := take("source"); ok && fmt.Sprint(source) != "" {
+		entry.Source = fmt.Sprint(source)
+	}
+	if timestamp, ok := take("timestamp"); ok {
+		t, err := m.parseTime(timestamp)
+		if err != nil {
+			return entry, err
+		}
+		entry.Timestamp = t
+	}
+	if fields, ok := values["fields"].(map[string]interface{}); ok {
+		delete(values, "fields")
+		for key, value := range fields {
+			if _, exists := values[key]; !exists {
+				values[key] = value
+			}
+		}
+	}
+	for key, value := range m.fields {
+		if _, exists := values[key]; !exists {
+			values[key] = value
+		}
+	}
+	if len(values) > 0 {
+		entry.Fields = models.Fields(values)
+	}
+	return entry, nil
+}
+
+// parseTime parses a timestamp in the configured layout or RFC3339, or
+// as Unix seconds or milliseconds
+func (m *Mapper) parseTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case float64:
+		if v > 1e12 {
+			return time.UnixMilli(int64(v)).UTC(), nil
+		}
+		sec, frac := int64(v), v-float64(int64(v))
+		return time.Unix(sec, int64(frac*1e9)).UTC(), nil
+	case string:
+		for _, layout := range []string{m.timeFormat, time.RFC3339Nano} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, nil
+			}
+		}
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return m.parseTime(n)
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %v", value)
+}
+
+// levelAliases maps level names of other logging systems to ours
+var levelAliases = map[string]string{
+	"trace":       "debug",
+	"information": "info",
+	"notice":      "info",
+	"warning":     "warn",
+	"err":         "error",
+	"critical":    "fatal",
+	"crit":        "fatal",
+	"alert":       "fatal",
+	"emerg":       "fatal",
+	"panic":       "fatal",
+}
+
+// NormalizeLevel returns the lowercase level named by level, which may be
+// the name another logging system uses, or "" if it names none
+func NormalizeLevel(level string) string {
+	level = strings.ToLower(strings.TrimSpace(level))
+	if alias, ok := levelAliases[level]; ok {
+		return alias
+	}
+	if (&models.Log{Message: "-", Level: level}).Validate() == nil {
+		return level
+	}
+	return ""
+}
+
+// DetectFormat tells NDJSON by the file name, or by r starting with an
+// object
+func DetectFormat(name string, r *bufio.Reader) string {
+	switch filepath.Ext(strings.TrimSuffix(name, ".gz")) {
+	case ".ndjson", ".jsonl", ".json":
+		return NDJSON
+	}
+	if start, _ := r.Peek(1); len(start) == 1 && start[0] == '{' {
+		return NDJSON
+	}
+	return Text
+}
+
+// ParseKeys parses field=key pairs, such as message=msg,level=severity,
+// naming the NDJSON keys of entry fields
+func ParseKeys(value string) (map[string]string, error) {
+	keys := make(map[string]string)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		field, key, ok := strings.Cut(item, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("expected field=key, got %q", item)
+		}
+		keys[field] = key
+	}
+	return keys, nil
+}
+
+// templatePlaceholder matches the {name} placeholders of a template
+var templatePlaceholder = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+var whitespace = regexp.MustCompile(`\s+`)
+
+// compileTemplate turns a template into a regular expression matching whole
+// lines, with a named group per placeholder
+func compileTemplate(template string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	literal := func(text string) {
+		for i, part := range whitespace.Split(text, -1) {
+			if i > 0 {
+				b.WriteString(`\s+`)
+			}
+			b.WriteString(regexp.QuoteMeta(part))
+		}
+	}
+
+	b.WriteString("^")
+	seen := make(map[string]bool)
+	last := 0
+	for _, match := range templatePlaceholder.FindAllStringSubmatchIndex(template, -1) {
+		literal(template[last:match[0]])
+		name := template[match[2]:match[3]]
+		if seen[name] {
+			return nil, fmt.Errorf("{%s} appears twice", name)
+		}
+		seen[name] = true
+		if match[1] == len(template) {
+			// The last placeholder takes the rest of the line
+			fmt.Fprintf(&b, "(?P<%s>.*)", name)
+		} else {
+			fmt.Fprintf(&b, "(?P<%s>.+?)", name)
+		}
+		last = match[1]
+	}
+	if !seen["message"] {
+		return nil, errors.New("the template needs a {message}")
+	}
+	literal(template[last:])
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
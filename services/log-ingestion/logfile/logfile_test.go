@@ -0,0 +1,93 @@
+package logfile
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func TestMapper_Template(t *testing.T) {
+	m, err := NewMapper(Config{
+		Template:   "{timestamp} {level} [{source}] {message}",
+		TimeFormat: "2006-01-02T15:04:05Z07:00",
+		Source:     "app.log",
+		Fields:     models.Fields{"host": "edge-1"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create mapper: %v", err)
+	}
+
+	entry, err := m.Map("2024-03-01T10:00:00Z  WARNING [billing]  Payment declined: card expired")
+	if err != nil {
+		t.Fatalf("Failed to map: %v", err)
+	}
+	if entry.Level != "warn" || entry.Source != "billing" || entry.Message != "Payment declined: card expired" ||
+		!entry.Timestamp.Equal(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)) || entry.Fields["host"] != "edge-1" {
+		t.Errorf("Unexpected entry %+v", entry)
+	}
+
+	if _, err := m.Map("no timestamp here"); err == nil {
+		t.Error("Expected a line not matching the template to fail")
+	}
+}
+
+func TestMapper_NDJSON(t *testing.T) {
+	keys, err := ParseKeys("message=msg, level=severity,timestamp=ts")
+	if err != nil {
+		t.Fatalf("Failed to parse keys: %v", err)
+	}
+	m, err := NewMapper(Config{Format: NDJSON, Keys: keys, Source: "app.ndjson"})
+	if err != nil {
+		t.Fatalf("Failed to create mapper: %v", err)
+	}
+
+	entry, err := m.Map(`{"msg":"started","severity":"NOTICE","ts":1700000000,"pid":42,"fields":{"region":"eu"}}`)
+	if err != nil {
+		t.Fatalf("Failed to map: %v", err)
+	}
+	if entry.Message != "started" || entry.Level != "info" || entry.Source != "app.ndjson" || entry.Timestamp.Unix() != 1700000000 {
+		t.Errorf("Unexpected entry %+v", entry)
+	}
+	if entry.Fields["pid"] != float64(42) || entry.Fields["region"] != "eu" {
+		t.Errorf("Expected the other keys as fields, got %v", entry.Fields)
+	}
+
+	entry, _ = m.Map(`{"msg":"odd","severity":"verbose"}`)
+	if entry.Level != "info" || entry.Fields["original_level"] != "verbose" {
+		t.Errorf("Expected an unknown level kept as a field, got %+v", entry)
+	}
+	if _, err := m.Map(`{"severity":"info"}`); err == nil {
+		t.Error("Expected an entry without a message to fail")
+	}
+}
+
+func TestNewMapper_Invalid(t *testing.T) {
+	for name, cfg := range map[string]Config{
+		"no message":         {Template: "{level}"},
+		"repeated name":      {Template: "{message} {message}"},
+		"unknown format":     {Format: "csv"},
+		"unknown level":      {Level: "verbose"},
+		"unknown mapped key": {Format: NDJSON, Keys: map[string]string{"host": "hostname"}},
+	} {
+		if _, err := NewMapper(cfg); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name, content, format string
+	}{
+		{"events.jsonl.gz", "", NDJSON},
+		{"app.log", `{"message":"x"}`, NDJSON},
+		{"app.log", "plain line", Text},
+	}
+	for _, tt := range tests {
+		if format := DetectFormat(tt.name, bufio.NewReader(strings.NewReader(tt.content))); format != tt.format {
+			t.Errorf("%s %q: expected %s, got %s", tt.name, tt.content, tt.format, format)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package logger
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// OverflowPolicy decides what an asynchronous logger does when its buffer is full
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits for room in the buffer, so no entry is lost
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDrop discards the entry and counts it, so logging never waits
+	OverflowDrop
+)
+
+// DefaultBufferSize is the number of entries an asynchronous logger buffers
+// when none is given
+const DefaultBufferSize = 1024
+
+// asyncRecord is a formatted line, or with done set a flush marker
+type asyncRecord struct {
+	line []byte
+	done chan struct{}
+}
+
+// asyncWriter hands lines to a background goroutine writing them to out, so
+// a slow file or network output does not stall the caller. Lines dropped on
+// overflow are reported with a line built by notice once the writer catches up.
+type asyncWriter struct {
+	out     io.Writer
+	policy  OverflowPolicy
+	notice  func(dropped uint64) []byte
+	records chan asyncRecord
+	dropped uint64
+
+	closeOnce sync.Once
+	closed    chan struct{}
+	stopped   chan struct{}
+}
+
+func newAsyncWriter(out io.Writer, size int, policy OverflowPolicy, notice func(dropped uint64) []byte) *asyncWriter {
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+	w := &asyncWriter{
+		out:     out,
+		policy:  policy,
+		notice:  notice,
+		records: make(chan asyncRecord, size),
+		closed:  make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Write queues a copy of p, which callers such as fmt.Fprintln reuse
+func (w *asyncWriter) Write(p []byte) (int, error) {
+	record := asyncRecord{line: append([]byte(nil), p...)}
+
+	select {
+	case <-w.closed:
+		return 0, io.ErrClosedPipe
+	default:
+	}
+
+	if w.policy == OverflowDrop {
+		select {
+		case w.records <- record:
+		default:
+			atomic.AddUint64(&w.dropped, 1)
+		}
+		return len(p), nil
+	}
+
+	select {
+	case w.records <- record:
+	case <-w.closed:
+		return 0, io.ErrClosedPipe
+	}
+	return len(p), nil
+}
+
+// Flush waits until every line queued so far has been written
+func (w *asyncWriter) Flush() {
+	done := make(chan struct{})
+	select {
+	case w.records <- asyncRecord{done: done}:
+	case <-w.closed:
+		return
+	}
+	select {
+	case <-done:
+	case <-w.stopped:
+	}
+}
+
+// Close writes the queued lines and stops the background goroutine
+func (w *asyncWriter) Close() {
+	w.closeOnce.Do(func() {
+		w.Flush()
+		close(w.closed)
+	})
+	<-w.stopped
+}
+
+func (w *asyncWriter) run() {
+	defer close(w.stopped)
+
+	for {
+		select {
+		case record := <-w.records:
+			w.write(record)
+		case <-w.closed:
+			return
+		}
+	}
+}
+
+func (w *asyncWriter) write(record asyncRecord) {
+	if record.done != nil {
+		w.reportDropped()
+		close(record.done)
+		return
+	}
+	w.out.Write(record.line)
+
+	// Report drops once the buffer has drained, when there is room again
+	if len(w.records) == 0 {
+		w.reportDropped()
+	}
+}
+
+func (w *asyncWriter) reportDropped() {
+	if dropped := atomic.SwapUint64(&w.dropped, 0); dropped > 0 && w.notice != nil {
+		w.out.Write(w.notice(dropped))
+	}
+}
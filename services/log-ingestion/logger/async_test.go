@@ -0,0 +1,121 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowWriter blocks every write until released
+type slowWriter struct {
+	release chan struct{}
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	<-w.release
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *slowWriter) lines() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return strings.Split(strings.TrimSpace(w.buf.String()), "\n")
+}
+
+func newAsyncTestLogger(out *slowWriter, size int, policy OverflowPolicy) *Logger {
+	logger := New(Config{Level: "INFO", Format: "JSON", Service: "test-service", Component: "test"})
+	logger.async = newAsyncWriter(out, size, policy, logger.droppedNotice)
+	logger.output = logger.async
+	return logger
+}
+
+func TestAsyncLogger_DoesNotWaitForOutput(t *testing.T) {
+	out := &slowWriter{release: make(chan struct{})}
+	logger := newAsyncTestLogger(out, 10, OverflowBlock)
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		logger.WithField("i", i).Info("queued")
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Expected logging not to wait for the output, took %v", elapsed)
+	}
+
+	close(out.release)
+	logger.Flush()
+	if lines := out.lines(); len(lines) != 5 {
+		t.Errorf("Expected 5 lines after flush, got %d", len(lines))
+	}
+}
+
+func TestAsyncLogger_DropPolicy(t *testing.T) {
+	out := &slowWriter{release: make(chan struct{})}
+	logger := newAsyncTestLogger(out, 2, OverflowDrop)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 20; i++ {
+			logger.Info("flood")
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the drop policy never to block")
+	}
+
+	close(out.release)
+	logger.Flush()
+
+	written, dropped := 0, 0.0
+	for _, line := range out.lines() {
+		var entry LogEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Expected JSON lines, got %s", line)
+		}
+		if n, ok := entry.Fields["dropped"].(float64); ok {
+			dropped += n
+			continue
+		}
+		written++
+	}
+	if dropped == 0 || float64(written)+dropped != 20 {
+		t.Errorf("Expected every entry to be written or reported dropped, got %d written and %v dropped", written, dropped)
+	}
+}
+
+func TestAsyncLogger_BlockPolicy(t *testing.T) {
+	out := &slowWriter{release: make(chan struct{})}
+	logger := newAsyncTestLogger(out, 2, OverflowBlock)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 20; i++ {
+			logger.Info("wait")
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("Expected the block policy to wait for room in the buffer")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(out.release)
+	<-done
+	logger.async.Close()
+	if lines := out.lines(); len(lines) != 20 {
+		t.Errorf("Expected all 20 lines to be written, got %d", len(lines))
+	}
+}
@@ -0,0 +1,117 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// envOutputs holds the loggers that opened the outputs of NewFromEnv
+// loggers, by output configuration, so loggers from the same environment
+// share one file, connection and buffer, and closing one of them drains
+// them all
+var envOutputs struct {
+	sync.Mutex
+	loggers map[string]*Logger
+}
+
+// envOutputsKey identifies the output configuration of a NewFromEnv logger
+func envOutputsKey(config Config) string {
+	return fmt.Sprintf("%q %q %v %d %q %q %q %q",
+		config.Output, config.Format, config.Async, config.BufferSize, config.Overflow,
+		config.SyslogFacility, config.TimeKey, config.TimeFormat)
+}
+
+// newWithEnvOutputs creates a logger from the environment, reusing the
+// outputs of an earlier one with the same output configuration
+func newWithEnvOutputs(config Config) *Logger {
+	envOutputs.Lock()
+	defer envOutputs.Unlock()
+
+	key := envOutputsKey(config)
+	opened, ok := envOutputs.loggers[key]
+	if !ok {
+		logger := New(config)
+		logger.envKey = key
+		if envOutputs.loggers == nil {
+			envOutputs.loggers = make(map[string]*Logger)
+		}
+		envOutputs.loggers[key] = logger
+		return logger
+	}
+
+	config.Output, config.Outputs, config.Async = "", nil, false
+	logger := New(config)
+	logger.output, logger.format, logger.color = opened.output, opened.format, opened.color
+	logger.async, logger.outputs = opened.async, opened.outputs
+	logger.envKey = key
+	return logger
+}
+
+// Flush waits until the entries logged so far are written: those buffered
+// by asynchronous outputs or the sink and those batched for the OTLP
+// collector
+func (l *Logger) Flush() {
+	if l.async != nil {
+		l.async.Flush()
+	}
+	for _, d := range l.outputs {
+		if d.async != nil {
+			d.async.Flush()
+		}
+	}
+	if l.otlp != nil {
+		l.otlp.Flush()
+	}
+	if l.sink != nil {
+		l.sink.Sync()
+	}
+}
+
+// Close flushes the logger and closes its outputs: files, network
+// connections and the goroutines of asynchronous outputs. Loggers derived
+// with WithFields and the like share the outputs, as do loggers created
+// by NewFromEnv with the same output settings, so Close belongs at the
+// very end of shutdown; entries logged afterwards are discarded. Stdout
+// and stderr stay open, as does a sink, which belongs to its creator.
+func (l *Logger) Close() error {
+	l.Flush()
+
+	if l.envKey != "" {
+		// Later loggers open their outputs anew
+		envOutputs.Lock()
+		delete(envOutputs.loggers, l.envKey)
+		envOutputs.Unlock()
+	}
+
+	err := closeOutput(l.output, l.async)
+	for _, d := range l.outputs {
+		if derr := closeOutput(d.output, d.async); err == nil {
+			err = derr
+		}
+	}
+	return err
+}
+
+// closeOutput stops an asynchronous output and closes the output it writes
+// to, leaving the standard streams alone; closing it a second time is not
+// an error
+func closeOutput(output io.Writer, async *asyncWriter) error {
+	if async != nil {
+		async.Close()
+		output = async.out
+	}
+	if output == os.Stdout || output == os.Stderr {
+		return nil
+	}
+	closer, ok := output.(io.Closer)
+	if !ok {
+		return nil
+	}
+	if err := closer.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
+		return err
+	}
+	return nil
+}
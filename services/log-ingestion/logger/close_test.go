@@ -0,0 +1,84 @@
+package logger
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogger_CloseWritesBufferedEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+
+	logger := New(Config{Level: "INFO", Format: "JSON", Service: "test-service", Component: "test", Output: path, Async: true})
+	for i := 0; i < 100; i++ {
+		logger.Info("buffered")
+	}
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 100 {
+		t.Errorf("Expected 100 lines, got %d", lines)
+	}
+
+	// Closing twice and logging after Close are harmless
+	if err := logger.Close(); err != nil {
+		t.Errorf("Second Close failed: %v", err)
+	}
+	logger.Info("after close")
+}
+
+func TestNewFromEnv_SharesOutputs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	os.Setenv("LOG_OUTPUT", path)
+	os.Setenv("LOG_ASYNC", "true")
+	defer func() {
+		os.Unsetenv("LOG_OUTPUT")
+		os.Unsetenv("LOG_ASYNC")
+	}()
+
+	app := NewFromEnv("test-service", "main")
+	handlers := NewFromEnv("test-service", "handlers")
+	if app.async == nil || app.async != handlers.async {
+		t.Fatal("Expected loggers from the same environment to share their output")
+	}
+
+	handlers.Info("last words")
+	if err := app.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	if !strings.Contains(string(data), "last words") {
+		t.Errorf("Expected the handlers entry to be written on Close, got %q", data)
+	}
+
+	// Loggers created after Close get open outputs
+	later := NewFromEnv("test-service", "main")
+	defer later.Close()
+	if later.async == app.async {
+		t.Error("Expected a new output after Close")
+	}
+}
+
+func TestNetWriter_Close(t *testing.T) {
+	w := &netWriter{network: "udp", address: "127.0.0.1:9"}
+	if _, err := w.Write([]byte("before close\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := w.Write([]byte("after close\n")); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Expected net.ErrClosed after Close, got %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ANSI escape sequences used by the console format
+const (
+	ansiReset   = "\x1b[0m"
+	ansiBold    = "\x1b[1m"
+	ansiFaint   = "\x1b[2m"
+	ansiRed     = "\x1b[31m"
+	ansiGreen   = "\x1b[32m"
+	ansiYellow  = "\x1b[33m"
+	ansiBlue    = "\x1b[34m"
+	ansiMagenta = "\x1b[35m"
+	ansiCyan    = "\x1b[36m"
+)
+
+// Column widths the console format pads to, so the fields of consecutive
+// entries line up
+const (
+	consoleComponentWidth = 12
+	consoleMessageWidth   = 40
+)
+
+var consoleLevelColors = map[string]string{
+	"DEBUG": ansiBlue,
+	"INFO":  ansiGreen,
+	"WARN":  ansiYellow,
+	"ERROR": ansiRed,
+	"FATAL": ansiBold + ansiMagenta,
+}
+
+// formatConsoleEntry formats a log entry for reading in a terminal: time,
+// level, component and message in aligned columns followed by key=value
+// fields, with ANSI colors when the logger writes to a terminal
+func (f formatter) formatConsoleEntry(entry LogEntry) string {
+	var b strings.Builder
+
+	b.WriteString(f.colorize(ansiFaint, entry.Timestamp.Local().Format("15:04:05.000")))
+	b.WriteByte(' ')
+	b.WriteString(f.colorize(consoleLevelColors[entry.Level], fmt.Sprintf("%-5s", entry.Level)))
+	b.WriteByte(' ')
+	component := entry.Component
+	if entry.Logger != "" {
+		component += "/" + entry.Logger
+	}
+	b.WriteString(f.colorize(ansiCyan, fmt.Sprintf("%-*s", consoleComponentWidth, component)))
+	b.WriteByte(' ')
+
+	message := entry.Message
+	pairs := f.consolePairs(entry)
+	if len(pairs) > 0 && len(message) < consoleMessageWidth {
+		message += strings.Repeat(" ", consoleMessageWidth-len(message))
+	}
+	if entry.Level == "ERROR" || entry.Level == "FATAL" {
+		message = f.colorize(ansiBold, message)
+	}
+	b.WriteString(message)
+
+	for _, pair := range pairs {
+		b.WriteByte(' ')
+		b.WriteString(pair)
+	}
+
+	if entry.File != "" {
+		b.WriteByte(' ')
+		b.WriteString(f.colorize(ansiFaint, entry.File+":"+strconv.Itoa(entry.Line)))
+	}
+	if lines := formatStackLines(entry); lines != "" {
+		b.WriteString(f.colorize(ansiFaint, lines))
+	}
+
+	return b.String()
+}
+
+// consolePairs renders the context, error and custom fields of an entry as
+// key=value pairs, custom fields in key order
+func (f formatter) consolePairs(entry LogEntry) []string {
+	var pairs []string
+	// Keys are faint so values stand out; valueColor highlights a value
+	add := func(key, value, valueColor string) {
+		if logfmtNeedsQuoting(value) {
+			value = strconv.Quote(value)
+		}
+		pairs = append(pairs, f.colorize(ansiFaint, logfmtKey(key)+"=")+f.colorize(valueColor, value))
+	}
+
+	for _, pair := range []struct{ key, value string }{
+		{"trace_id", entry.TraceID},
+		{"span_id", entry.SpanID},
+		{"request_id", entry.RequestID},
+		{"user_id", entry.UserID},
+		{"tenant_id", entry.TenantID},
+	} {
+		if pair.value != "" {
+			add(pair.key, pair.value, "")
+		}
+	}
+	if entry.Duration != nil {
+		add("duration", entry.Duration.String(), "")
+	}
+	if entry.Error != "" {
+		add("error", entry.Error, ansiRed)
+	}
+
+	keys := make([]string, 0, len(entry.Fields))
+	for k := range entry.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		// WithError stores the error as a field
+		color := ""
+		if k == "error" {
+			color = ansiRed
+		}
+		add(k, logfmtValue(entry.Fields[k]), color)
+	}
+	return pairs
+}
+
+// colorize wraps s in an ANSI color when the output takes colors
+func (f formatter) colorize(color, s string) string {
+	if !f.color || color == "" {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+// isTerminal reports whether w is a terminal
+func isTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// useColor reports whether output to w should be colored: only for a
+// terminal, and not when the NO_COLOR convention asks otherwise
+func useColor(w io.Writer) bool {
+	return isTerminal(w) && os.Getenv("NO_COLOR") == ""
+}
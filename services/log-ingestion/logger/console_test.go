@@ -0,0 +1,75 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLogger_ConsoleOutput(t *testing.T) {
+	var buffer bytes.Buffer
+
+	logger := New(Config{Level: "INFO", Format: "CONSOLE", Service: "test-service", Component: "http"})
+	logger.output = &buffer
+
+	logger.WithField("status", 200).Info("request completed")
+	logger.WithError(errors.New("connection refused")).Error("request failed")
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %q", buffer.String())
+	}
+	if strings.Contains(buffer.String(), "\x1b[") {
+		t.Errorf("Expected no colors for a non-terminal output, got %q", buffer.String())
+	}
+
+	if !strings.Contains(lines[0], " INFO  http         request completed ") {
+		t.Errorf("Expected aligned level, component and message, got %q", lines[0])
+	}
+	if !strings.Contains(lines[1], ` error="connection refused"`) {
+		t.Errorf("Expected quoted error field, got %q", lines[1])
+	}
+	if !strings.Contains(lines[0], "console_test.go:") {
+		t.Errorf("Expected caller, got %q", lines[0])
+	}
+
+	// Fields start in the same column whatever the message length
+	if strings.Index(lines[0], "status=") != strings.Index(lines[1], "error=") {
+		t.Errorf("Expected fields to line up:\n%s\n%s", lines[0], lines[1])
+	}
+}
+
+func TestLogger_ConsoleColors(t *testing.T) {
+	var buffer bytes.Buffer
+
+	logger := New(Config{Level: "INFO", Format: "CONSOLE", Service: "test-service", Component: "test"})
+	logger.output = &buffer
+	logger.color = true
+
+	logger.Warn("disk almost full")
+
+	if !strings.Contains(buffer.String(), ansiYellow+"WARN ") {
+		t.Errorf("Expected yellow level, got %q", buffer.String())
+	}
+}
+
+func TestNew_AutoFormat(t *testing.T) {
+	file, err := os.CreateTemp(t.TempDir(), "log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	// A file is not a terminal, so AUTO falls back to JSON
+	logger := New(Config{Format: "AUTO", Output: file.Name()})
+	if logger.format != JSON || logger.color {
+		t.Errorf("Expected JSON without colors for a file, got format %v, color %v", logger.format, logger.color)
+	}
+
+	logger = New(Config{Format: "CONSOLE", Output: file.Name()})
+	if logger.format != CONSOLE || logger.color {
+		t.Errorf("Expected console format without colors for a file, got format %v, color %v", logger.format, logger.color)
+	}
+}
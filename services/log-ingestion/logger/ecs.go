@@ -0,0 +1,141 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ECSVersion is the Elastic Common Schema version ECS entries follow
+const ECSVersion = "8.11.0"
+
+// ecsFieldNames maps the field names used across the services to their ECS
+// names
+var ecsFieldNames = map[string]string{
+	"http_method":      "http.request.method",
+	"http_path":        "url.path",
+	"http_query":       "url.query",
+	"http_host":        "url.domain",
+	"http_user_agent":  "user_agent.original",
+	"http_remote_addr": "client.address",
+	"http_status_code": "http.response.status_code",
+	"response_size":    "http.response.body.bytes",
+	"content_length":   "http.request.body.bytes",
+	"request_id":       "http.request.id",
+	"business_event":   "event.action",
+	"error":            "error.message",
+	"error_type":       "error.type",
+	"error_code":       "error.code",
+	"hostname":         "host.hostname",
+	"pid":              "process.pid",
+	"environment":      "service.environment",
+	"version":          "service.version",
+	"container_id":     "container.id",
+	"pod_name":         "kubernetes.pod.name",
+	"pod_namespace":    "kubernetes.namespace",
+	"node_name":        "kubernetes.node.name",
+}
+
+// formatECSEntry formats a log entry as JSON with Elastic Common Schema
+// field names. Fields without an ECS name stay under "fields", a custom
+// namespace, so they never collide with ECS fields.
+func (f formatter) formatECSEntry(entry LogEntry) string {
+	doc := map[string]interface{}{
+		"@timestamp":   entry.Timestamp.Format(time.RFC3339Nano),
+		"log.level":    strings.ToLower(entry.Level),
+		"message":      entry.Message,
+		"service.name": entry.Service,
+		"log.logger":   entry.Component,
+		"ecs.version":  ECSVersion,
+	}
+
+	optional := []struct{ key, value string }{
+		{"trace.id", entry.TraceID},
+		{"span.id", entry.SpanID},
+		{"user.id", entry.UserID},
+		{"organization.id", entry.TenantID},
+		{"http.request.id", entry.RequestID},
+		{"log.origin.file.name", entry.File},
+		{"log.origin.function", entry.Function},
+		{"error.message", entry.Error},
+	}
+	for _, field := range optional {
+		if field.value != "" {
+			doc[field.key] = field.value
+		}
+	}
+	if entry.Line > 0 {
+		doc["log.origin.file.line"] = entry.Line
+	}
+	if len(entry.ErrorChain) > 0 {
+		doc["error.type"] = entry.ErrorChain[0].Type
+	}
+	if len(entry.Stack) > 0 {
+		doc["error.stack_trace"] = strings.Join(entry.Stack, "\n")
+	}
+	if len(entry.Tags) > 0 {
+		doc["tags"] = entry.Tags
+	}
+
+	custom := make(map[string]interface{})
+	// log.logger is the logger's name when it has one
+	if entry.Logger != "" {
+		doc["log.logger"] = entry.Logger
+		custom["component"] = entry.Component
+	}
+	for k, v := range entry.Fields {
+		if name, ok := ecsFieldNames[k]; ok {
+			doc[name] = v
+			continue
+		}
+		if _, ok := ecsDuration(k, v); ok {
+			continue
+		}
+		custom[k] = v
+	}
+	if len(custom) > 0 {
+		doc["fields"] = custom
+	}
+
+	// The most precise duration wins
+	if nanos, ok := ecsDuration("duration_ms", entry.Fields["duration_ms"]); ok {
+		doc["event.duration"] = nanos
+	}
+	if nanos, ok := ecsDuration("duration", entry.Fields["duration"]); ok {
+		doc["event.duration"] = nanos
+	}
+	if entry.Duration != nil {
+		doc["event.duration"] = entry.Duration.Nanoseconds()
+	}
+
+	jsonBytes, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Sprintf(`{"@timestamp":"%s","log.level":"error","message":"Failed to marshal log entry: %s","ecs.version":"%s"}`,
+			time.Now().UTC().Format(time.RFC3339), err.Error(), ECSVersion)
+	}
+	return string(jsonBytes)
+}
+
+// ecsDuration converts the duration fields written by WithDuration and the
+// HTTP middleware to the nanoseconds ECS uses for event.duration
+func ecsDuration(key string, value interface{}) (int64, bool) {
+	switch key {
+	case "duration":
+		if s, ok := value.(string); ok {
+			if d, err := time.ParseDuration(s); err == nil {
+				return d.Nanoseconds(), true
+			}
+		}
+	case "duration_ms":
+		switch ms := value.(type) {
+		case int64:
+			return ms * int64(time.Millisecond), true
+		case int:
+			return int64(ms) * int64(time.Millisecond), true
+		case float64:
+			return int64(ms * float64(time.Millisecond)), true
+		}
+	}
+	return 0, false
+}
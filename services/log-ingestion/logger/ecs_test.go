@@ -0,0 +1,87 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLogger_ECSOutput(t *testing.T) {
+	var buffer bytes.Buffer
+
+	logger := New(Config{Level: "INFO", Format: "ECS", Service: "log-ingestion", Component: "http"})
+	logger.output = &buffer
+
+	ctx := WithTraceID(context.Background(), "trace-1")
+	logger.WithFields(map[string]interface{}{"custom": "value", "duration_ms": int64(150)}).
+		WithError(errors.New("timeout")).
+		LogHTTPRequest("POST", "/logs", "curl/8.0", "10.0.0.1:5000", 504, 2*time.Second)
+	logger.WithField("tenant", "acme").InfoContext(ctx, "with context")
+
+	lines := bytes.Split(bytes.TrimSpace(buffer.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 entries, got %q", buffer.String())
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(lines[0], &doc); err != nil {
+		t.Fatalf("Failed to parse entry: %v", err)
+	}
+	expected := map[string]interface{}{
+		"log.level":                 "info",
+		"message":                   "HTTP request processed",
+		"service.name":              "log-ingestion",
+		"log.logger":                "http",
+		"ecs.version":               ECSVersion,
+		"http.request.method":       "POST",
+		"url.path":                  "/logs",
+		"user_agent.original":       "curl/8.0",
+		"client.address":            "10.0.0.1:5000",
+		"http.response.status_code": float64(504),
+		"error.message":             "timeout",
+		// LogHTTPRequest's duration string overrides the middleware's duration_ms
+		"event.duration": float64(2 * time.Second),
+	}
+	for key, want := range expected {
+		if doc[key] != want {
+			t.Errorf("Expected %s = %v, got %v", key, want, doc[key])
+		}
+	}
+	if _, ok := doc["@timestamp"]; !ok {
+		t.Error("Expected @timestamp")
+	}
+	if custom, ok := doc["fields"].(map[string]interface{}); !ok || custom["custom"] != "value" || len(custom) != 1 {
+		t.Errorf("Expected unmapped fields under fields, got %v", doc["fields"])
+	}
+
+	if err := json.Unmarshal(lines[1], &doc); err != nil {
+		t.Fatalf("Failed to parse entry: %v", err)
+	}
+	if doc["trace.id"] != "trace-1" {
+		t.Errorf("Expected trace.id, got %v", doc["trace.id"])
+	}
+}
+
+func TestECSDuration(t *testing.T) {
+	tests := []struct {
+		key   string
+		value interface{}
+		nanos int64
+		ok    bool
+	}{
+		{"duration", "1.5s", int64(1500 * time.Millisecond), true},
+		{"duration_ms", int64(20), int64(20 * time.Millisecond), true},
+		{"duration_ms", 2.5, int64(2500 * time.Microsecond), true},
+		{"duration", "soon", 0, false},
+		{"latency", "1s", 0, false},
+	}
+	for _, test := range tests {
+		nanos, ok := ecsDuration(test.key, test.value)
+		if nanos != test.nanos || ok != test.ok {
+			t.Errorf("ecsDuration(%s, %v) = %d, %v, want %d, %v", test.key, test.value, nanos, ok, test.nanos, test.ok)
+		}
+	}
+}
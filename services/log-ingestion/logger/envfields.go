@@ -0,0 +1,94 @@
+package logger
+
+import (
+	"os"
+	"regexp"
+	"runtime/debug"
+	"sync"
+)
+
+// Version is the version NewFromEnv loggers report when SERVICE_VERSION is
+// not set, e.g. set at build time with
+// -ldflags "-X log-processing-system/services/log-ingestion/logger.Version=1.4.0"
+var Version string
+
+// containerIDPatterns find the 64 hex digit ID Docker and containerd give a
+// container in the process's cgroup, or among its mounts the files Docker
+// keeps per container (overlay layers have IDs of the same form)
+var containerIDPatterns = []struct {
+	path    string
+	pattern *regexp.Regexp
+}{
+	{"/proc/self/cgroup", regexp.MustCompile(`([0-9a-f]{64})`)},
+	{"/proc/self/mountinfo", regexp.MustCompile(`/containers/([0-9a-f]{64})/`)},
+}
+
+var container struct {
+	sync.Once
+	id string
+}
+
+// environmentFields returns the fields NewFromEnv loggers add to every
+// entry so it can be traced to the process that wrote it: host, pid,
+// environment, version, commit and, when running in one, the container
+// and Kubernetes pod. Fields without a value are left out.
+func environmentFields() map[string]interface{} {
+	fields := map[string]interface{}{"pid": os.Getpid()}
+
+	hostname, _ := os.Hostname()
+	values := []struct{ key, value string }{
+		{"hostname", hostname},
+		{"environment", os.Getenv("ENVIRONMENT")},
+		{"version", getEnv("SERVICE_VERSION", Version)},
+		{"commit", getEnv("GIT_COMMIT", buildRevision())},
+		{"container_id", containerID()},
+		// Kubernetes exposes these through the downward API
+		{"pod_name", os.Getenv("POD_NAME")},
+		{"pod_namespace", os.Getenv("POD_NAMESPACE")},
+		{"node_name", os.Getenv("NODE_NAME")},
+	}
+	for _, field := range values {
+		if field.value != "" {
+			fields[field.key] = field.value
+		}
+	}
+
+	// A pod's hostname is its name unless the pod spec overrides it
+	if _, ok := fields["pod_name"]; !ok && os.Getenv("KUBERNETES_SERVICE_HOST") != "" && hostname != "" {
+		fields["pod_name"] = hostname
+	}
+	return fields
+}
+
+// buildRevision returns the VCS revision the binary was built from, which
+// go build records since Go 1.18
+func buildRevision() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			return setting.Value
+		}
+	}
+	return ""
+}
+
+// containerID returns the ID of the container the process runs in, found
+// in its cgroup (cgroup v1) or mounts (cgroup v2), or "" outside one
+func containerID() string {
+	container.Do(func() {
+		for _, source := range containerIDPatterns {
+			data, err := os.ReadFile(source.path)
+			if err != nil {
+				continue
+			}
+			if match := source.pattern.FindSubmatch(data); match != nil {
+				container.id = string(match[1])
+				return
+			}
+		}
+	})
+	return container.id
+}
@@ -0,0 +1,52 @@
+package logger
+
+import (
+	"os"
+	"testing"
+)
+
+func TestNewFromEnv_EnvironmentFields(t *testing.T) {
+	env := map[string]string{
+		"ENVIRONMENT":     "staging",
+		"SERVICE_VERSION": "1.4.0",
+		"GIT_COMMIT":      "3f2c1e9",
+		"POD_NAME":        "log-ingestion-7d9f8-x2k4p",
+		"POD_NAMESPACE":   "logging",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+	defer func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}()
+
+	logger := NewFromEnv("test-service", "test-component")
+	expected := map[string]interface{}{
+		"pid":           os.Getpid(),
+		"environment":   "staging",
+		"version":       "1.4.0",
+		"commit":        "3f2c1e9",
+		"pod_name":      "log-ingestion-7d9f8-x2k4p",
+		"pod_namespace": "logging",
+	}
+	for key, want := range expected {
+		if logger.fields[key] != want {
+			t.Errorf("Expected %s = %v, got %v", key, want, logger.fields[key])
+		}
+	}
+	if hostname, _ := os.Hostname(); hostname != "" && logger.fields["hostname"] != hostname {
+		t.Errorf("Expected hostname %s, got %v", hostname, logger.fields["hostname"])
+	}
+	if _, ok := logger.fields["node_name"]; ok {
+		t.Errorf("Expected no node_name without NODE_NAME, got %v", logger.fields["node_name"])
+	}
+
+	os.Setenv("LOG_ENV_FIELDS", "false")
+	defer os.Unsetenv("LOG_ENV_FIELDS")
+	// Only the self_source marker, which LOG_SELF_SOURCE controls
+	if fields := NewFromEnv("test-service", "test-component").fields; len(fields) != 1 || fields[SelfSourceField] != "test-service" {
+		t.Errorf("Expected no environment fields with LOG_ENV_FIELDS=false, got %v", fields)
+	}
+}
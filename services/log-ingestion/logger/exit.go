@@ -0,0 +1,111 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// ExitHookTimeout bounds the time Fatal waits for exit hooks, so a hung
+// hook cannot keep a failing process alive
+var ExitHookTimeout = 10 * time.Second
+
+// exit ends the process; replaced in tests
+var exit = os.Exit
+
+var exitHooks struct {
+	sync.Mutex
+	hooks []func()
+}
+
+// RegisterExitHook adds a function Fatal runs before exiting, since
+// os.Exit skips deferred calls: closing the database, flushing buffered
+// sinks or emitting a final metric. Hooks run in reverse order of
+// registration, like deferred calls, and a panicking hook does not stop
+// the others.
+func RegisterExitHook(hook func()) {
+	exitHooks.Lock()
+	defer exitHooks.Unlock()
+	exitHooks.hooks = append(exitHooks.hooks, hook)
+}
+
+var fatalHook struct {
+	sync.Mutex
+	hook func(entry LogEntry, stack []byte)
+}
+
+// OnFatal sets a function Fatal calls before the exit hooks, with the fatal
+// entry and the stack of the goroutine that called Fatal, such as one
+// writing a crash report. A later call replaces the function; nil removes
+// it.
+func OnFatal(hook func(entry LogEntry, stack []byte)) {
+	fatalHook.Lock()
+	defer fatalHook.Unlock()
+	fatalHook.hook = hook
+}
+
+// runFatalHook calls the OnFatal function, if any, for a Fatal call
+func (l *Logger) runFatalHook(message string) {
+	fatalHook.Lock()
+	hook := fatalHook.hook
+	fatalHook.Unlock()
+	if hook == nil {
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(os.Stderr, "logger: fatal hook panicked: %v\n", r)
+		}
+	}()
+	hook(l.newEntry(context.Background(), FATAL, message, nil), debug.Stack())
+}
+
+// runExitHooks runs the registered hooks once, giving up after
+// ExitHookTimeout
+func runExitHooks() {
+	exitHooks.Lock()
+	hooks := exitHooks.hooks
+	exitHooks.hooks = nil
+	exitHooks.Unlock()
+
+	if len(hooks) == 0 {
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := len(hooks) - 1; i >= 0; i-- {
+			runExitHook(hooks[i])
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(ExitHookTimeout):
+		fmt.Fprintf(os.Stderr, "logger: exit hooks did not finish within %s\n", ExitHookTimeout)
+	}
+}
+
+func runExitHook(hook func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(os.Stderr, "logger: exit hook panicked: %v\n", r)
+		}
+	}()
+	hook()
+}
+
+// fatalExit runs the fatal hook and the exit hooks and exits, writing out
+// buffered entries before and after the hooks, which may log themselves
+func (l *Logger) fatalExit(message string) {
+	l.Flush()
+	l.runFatalHook(message)
+	runExitHooks()
+	l.Flush()
+	exit(1)
+}
@@ -0,0 +1,94 @@
+package logger
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogger_FatalRunsExitHooks(t *testing.T) {
+	var buffer bytes.Buffer
+
+	logger := New(Config{Level: "INFO", Format: "JSON", Service: "test-service", Component: "test"})
+	logger.output = &buffer
+
+	code := -1
+	exit = func(c int) { code = c }
+	defer func() { exit = os.Exit }()
+
+	var order []string
+	RegisterExitHook(func() { order = append(order, "first") })
+	RegisterExitHook(func() { panic("broken hook") })
+	RegisterExitHook(func() {
+		order = append(order, "last")
+		logger.Info("closing database")
+	})
+
+	logger.Fatal("cannot continue")
+
+	if code != 1 {
+		t.Errorf("Expected exit code 1, got %d", code)
+	}
+	if strings.Join(order, ",") != "last,first" {
+		t.Errorf("Expected hooks in reverse order despite the panic, got %v", order)
+	}
+	output := buffer.String()
+	if !strings.Contains(output, "cannot continue") || !strings.Contains(output, "closing database") {
+		t.Errorf("Expected the fatal entry and the hook's entry to be written, got %q", output)
+	}
+
+	// Hooks run once
+	order = nil
+	logger.Fatal("again")
+	if len(order) != 0 {
+		t.Errorf("Expected hooks to run only once, got %v", order)
+	}
+}
+
+func TestLogger_FatalRunsFatalHook(t *testing.T) {
+	logger := New(Config{Level: "INFO", Service: "test-service", Component: "test"})
+	logger.output = &bytes.Buffer{}
+
+	exit = func(int) {}
+	defer func() { exit = os.Exit }()
+
+	var got LogEntry
+	var stack string
+	var order []string
+	OnFatal(func(entry LogEntry, s []byte) {
+		got, stack = entry, string(s)
+		order = append(order, "fatal hook")
+	})
+	defer OnFatal(nil)
+	RegisterExitHook(func() { order = append(order, "exit hook") })
+
+	logger.WithField("host", "db").Fatalf("cannot reach %s", "database")
+
+	if got.Message != "cannot reach database" || got.Level != "FATAL" || got.Fields["host"] != "db" {
+		t.Errorf("Expected the fatal entry, got %+v", got)
+	}
+	if !strings.Contains(stack, "TestLogger_FatalRunsFatalHook") {
+		t.Errorf("Expected the stack of the Fatal call, got %s", stack)
+	}
+	if strings.Join(order, ",") != "fatal hook,exit hook" {
+		t.Errorf("Expected the fatal hook before the exit hooks, got %v", order)
+	}
+}
+
+func TestRunExitHooks_Timeout(t *testing.T) {
+	timeout := ExitHookTimeout
+	ExitHookTimeout = 20 * time.Millisecond
+	defer func() { ExitHookTimeout = timeout }()
+
+	release := make(chan struct{})
+	defer close(release)
+	RegisterExitHook(func() { <-release })
+
+	start := time.Now()
+	runExitHooks()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected a hung hook to be abandoned, waited %s", elapsed)
+	}
+}
@@ -0,0 +1,129 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GELFVersion is the Graylog Extended Log Format version GELF entries follow
+const GELFVersion = "1.1"
+
+var gelfHost struct {
+	sync.Once
+	name string
+}
+
+// gelfHostname returns the host GELF entries are sent from
+func gelfHostname() string {
+	gelfHost.Do(func() {
+		gelfHost.name, _ = os.Hostname()
+		if gelfHost.name == "" {
+			gelfHost.name = "unknown"
+		}
+	})
+	return gelfHost.name
+}
+
+// formatGELFEntry formats a log entry as a GELF message: the message, the
+// syslog severity as level and everything else as additional fields
+// prefixed with an underscore. The stack trace, when captured, is the
+// full_message.
+func (f formatter) formatGELFEntry(entry LogEntry) string {
+	doc := map[string]interface{}{
+		"version":       GELFVersion,
+		"host":          gelfHostname(),
+		"short_message": entry.Message,
+		"timestamp":     float64(entry.Timestamp.UnixNano()/int64(time.Millisecond)) / 1000,
+		"level":         syslogSeverity(entry.Level),
+	}
+	if len(entry.Stack) > 0 {
+		doc["full_message"] = entry.Message + "\n" + strings.Join(entry.Stack, "\n")
+	}
+
+	// Fields first, so they cannot replace the entry's own attributes
+	for k, v := range entry.Fields {
+		if value, ok := gelfValue(v); ok {
+			doc[gelfFieldName(k)] = value
+		}
+	}
+
+	optional := []struct{ key, value string }{
+		{"_service", entry.Service},
+		{"_component", entry.Component},
+		{"_logger", entry.Logger},
+		{"_trace_id", entry.TraceID},
+		{"_span_id", entry.SpanID},
+		{"_user_id", entry.UserID},
+		{"_tenant_id", entry.TenantID},
+		{"_request_id", entry.RequestID},
+		{"_file", entry.File},
+		{"_function", entry.Function},
+		{"_error", entry.Error},
+	}
+	for _, field := range optional {
+		if field.value != "" {
+			doc[field.key] = field.value
+		}
+	}
+	if entry.Line > 0 {
+		doc["_line"] = entry.Line
+	}
+	if entry.Duration != nil {
+		doc["_duration"] = entry.Duration.String()
+	}
+	if len(entry.ErrorChain) > 0 {
+		doc["_error_type"] = entry.ErrorChain[0].Type
+	}
+	if len(entry.Tags) > 0 {
+		doc["_tags"] = strings.Join(entry.Tags, ",")
+	}
+
+	jsonBytes, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Sprintf(`{"version":"%s","host":"%s","short_message":"Failed to marshal log entry: %s","level":3}`,
+			GELFVersion, gelfHostname(), err.Error())
+	}
+	return string(jsonBytes)
+}
+
+// frameGELF makes a TCP output of GELF entries end each message with a NUL
+// byte, the framing Graylog's GELF TCP input expects
+func frameGELF(output io.Writer, format LogFormat) {
+	if w, ok := output.(*netWriter); ok && format == GELF && w.network == "tcp" {
+		w.nullDelimited = true
+	}
+}
+
+// gelfFieldName returns the additional field name of a field: prefixed
+// with an underscore, with characters GELF does not allow replaced. The
+// reserved _id becomes __id.
+func gelfFieldName(key string) string {
+	name := []byte("_" + key)
+	for i := 1; i < len(name); i++ {
+		c := name[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '.' || c == '-') {
+			name[i] = '_'
+		}
+	}
+	if string(name) == "_id" {
+		return "__id"
+	}
+	return string(name)
+}
+
+// gelfValue converts a field value to a string or number, the only types
+// GELF allows; nil values are left out
+func gelfValue(v interface{}) (interface{}, bool) {
+	switch value := v.(type) {
+	case nil:
+		return nil, false
+	case string, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return value, true
+	}
+	return logfmtValue(v), true
+}
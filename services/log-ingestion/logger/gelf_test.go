@@ -0,0 +1,99 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLogger_GELFOutput(t *testing.T) {
+	var buffer bytes.Buffer
+
+	logger := New(Config{Level: "INFO", Format: "GELF", Service: "log-ingestion", Component: "http"})
+	logger.output = &buffer
+
+	logger.WithFields(map[string]interface{}{
+		"http_status_code": 504,
+		"id":               "abc",
+		"user agent":       "curl/8.0",
+		"ok":               false,
+		"nested":           map[string]int{"a": 1},
+		"service":          "spoofed",
+	}).WithError(errors.New("timeout")).Error("request failed")
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(buffer.Bytes(), &doc); err != nil {
+		t.Fatalf("Failed to parse entry: %v", err)
+	}
+	expected := map[string]interface{}{
+		"version":           GELFVersion,
+		"short_message":     "request failed",
+		"level":             float64(3),
+		"_service":          "log-ingestion",
+		"_component":        "http",
+		"_http_status_code": float64(504),
+		"__id":              "abc",
+		"_user_agent":       "curl/8.0",
+		"_ok":               "false",
+		"_nested":           `{"a":1}`,
+		"_error":            "timeout",
+		"_file":             "gelf_test.go",
+	}
+	for key, want := range expected {
+		if doc[key] != want {
+			t.Errorf("Expected %s = %v, got %v", key, want, doc[key])
+		}
+	}
+	if host, ok := doc["host"].(string); !ok || host == "" {
+		t.Errorf("Expected a host, got %v", doc["host"])
+	}
+	if ts, ok := doc["timestamp"].(float64); !ok || time.Since(time.Unix(int64(ts), 0)) > time.Minute {
+		t.Errorf("Expected a Unix timestamp in seconds, got %v", doc["timestamp"])
+	}
+}
+
+func TestLogger_GELFOverTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	received := make(chan string, 2)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		for i := 0; i < 2; i++ {
+			message, err := reader.ReadString(0)
+			if err != nil {
+				return
+			}
+			received <- message
+		}
+	}()
+
+	logger := New(Config{Level: "INFO", Format: "GELF", Service: "test-service", Component: "test", Output: "tcp://" + listener.Addr().String()})
+	defer logger.Close()
+	logger.Info("first")
+	logger.Info("second")
+
+	for _, want := range []string{"first", "second"} {
+		select {
+		case message := <-received:
+			var doc map[string]interface{}
+			if err := json.Unmarshal([]byte(message[:len(message)-1]), &doc); err != nil || doc["short_message"] != want {
+				t.Errorf("Expected a NUL-terminated GELF message %q, got %q", want, message)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timed out waiting for the GELF message")
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package logger
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// maxPooledBuffer is the largest buffer kept for reuse, so one huge entry
+// does not pin its memory
+const maxPooledBuffer = 64 << 10
+
+// bufferPool holds the buffers entries are encoded into
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 1024)
+		return &b
+	},
+}
+
+func getBuffer() *[]byte {
+	return bufferPool.Get().(*[]byte)
+}
+
+func putBuffer(b *[]byte) {
+	if cap(*b) > maxPooledBuffer {
+		return
+	}
+	*b = (*b)[:0]
+	bufferPool.Put(b)
+}
+
+// appendJSONEntry appends an entry encoded as json.Marshal encodes a
+// LogEntry, with the configured timestamp, without building intermediate
+// values. Fields of types without a fast path go through json.Marshal.
+func appendJSONEntry(b []byte, entry LogEntry, t timestampFormat) ([]byte, error) {
+	b = append(b, '{')
+	b = appendJSONString(b, t.keyOr("timestamp"))
+	b = append(b, ':')
+	if t.epoch() {
+		b = append(b, t.text(entry.Timestamp, "")...)
+	} else if t.layout == "" {
+		b = append(b, '"')
+		b = entry.Timestamp.AppendFormat(b, time.RFC3339Nano)
+		b = append(b, '"')
+	} else {
+		b = appendJSONString(b, t.text(entry.Timestamp, ""))
+	}
+
+	b = appendJSONStringField(b, "level", entry.Level)
+	b = appendJSONStringField(b, "message", entry.Message)
+	b = appendJSONStringField(b, "service", entry.Service)
+	b = appendJSONStringField(b, "component", entry.Component)
+	if entry.Logger != "" {
+		b = appendJSONStringField(b, "logger", entry.Logger)
+	}
+
+	optional := [...]struct{ key, value string }{
+		{"trace_id", entry.TraceID},
+		{"span_id", entry.SpanID},
+		{"user_id", entry.UserID},
+		{"tenant_id", entry.TenantID},
+		{"request_id", entry.RequestID},
+		{"file", entry.File},
+	}
+	for _, field := range optional {
+		if field.value != "" {
+			b = appendJSONStringField(b, field.key, field.value)
+		}
+	}
+	if entry.Line != 0 {
+		b = append(b, `,"line":`...)
+		b = strconv.AppendInt(b, int64(entry.Line), 10)
+	}
+	if entry.Function != "" {
+		b = appendJSONStringField(b, "function", entry.Function)
+	}
+	if entry.Duration != nil {
+		b = append(b, `,"duration":`...)
+		b = strconv.AppendInt(b, int64(*entry.Duration), 10)
+	}
+	if entry.Error != "" {
+		b = appendJSONStringField(b, "error", entry.Error)
+	}
+	if len(entry.ErrorChain) > 0 {
+		b = append(b, `,"error_chain":[`...)
+		for i, cause := range entry.ErrorChain {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = append(b, `{"type":`...)
+			b = appendJSONString(b, cause.Type)
+			b = appendJSONStringField(b, "message", cause.Message)
+			b = append(b, '}')
+		}
+		b = append(b, ']')
+	}
+	if len(entry.Stack) > 0 {
+		b = append(b, `,"stack":`...)
+		b = appendJSONStrings(b, entry.Stack)
+	}
+	if len(entry.Fields) > 0 {
+		var err error
+		b = append(b, `,"fields":`...)
+		if b, err = appendJSONFields(b, entry.Fields); err != nil {
+			return b, err
+		}
+	}
+	if len(entry.Tags) > 0 {
+		b = append(b, `,"tags":`...)
+		b = appendJSONStrings(b, entry.Tags)
+	}
+	return append(b, '}'), nil
+}
+
+func appendJSONStringField(b []byte, key, value string) []byte {
+	b = append(b, ',')
+	b = appendJSONString(b, key)
+	b = append(b, ':')
+	return appendJSONString(b, value)
+}
+
+func appendJSONStrings(b []byte, values []string) []byte {
+	b = append(b, '[')
+	for i, s := range values {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = appendJSONString(b, s)
+	}
+	return append(b, ']')
+}
+
+// appendJSONFields appends fields as an object with sorted keys, as
+// json.Marshal writes maps
+func appendJSONFields(b []byte, fields map[string]interface{}) ([]byte, error) {
+	var array [16]string
+	keys := array[:0]
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	// Insertion sort; entries carry few fields
+	for i := 1; i < len(keys); i++ {
+		for j := i; j > 0 && keys[j] < keys[j-1]; j-- {
+			keys[j], keys[j-1] = keys[j-1], keys[j]
+		}
+	}
+
+	b = append(b, '{')
+	for i, k := range keys {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = appendJSONString(b, k)
+		b = append(b, ':')
+		var err error
+		if b, err = appendJSONValue(b, fields[k]); err != nil {
+			return b, err
+		}
+	}
+	return append(b, '}'), nil
+}
+
+// appendJSONValue appends common field values directly and the others as
+// json.Marshal encodes them
+func appendJSONValue(b []byte, value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return append(b, "null"...), nil
+	case string:
+		return appendJSONString(b, v), nil
+	case bool:
+		return strconv.AppendBool(b, v), nil
+	case int:
+		return strconv.AppendInt(b, int64(v), 10), nil
+	case int32:
+		return strconv.AppendInt(b, int64(v), 10), nil
+	case int64:
+		return strconv.AppendInt(b, v, 10), nil
+	case uint:
+		return strconv.AppendUint(b, uint64(v), 10), nil
+	case uint32:
+		return strconv.AppendUint(b, uint64(v), 10), nil
+	case uint64:
+		return strconv.AppendUint(b, v, 10), nil
+	case float64:
+		if !math.IsInf(v, 0) && !math.IsNaN(v) {
+			return appendJSONFloat(b, v, 64), nil
+		}
+	case float32:
+		if f := float64(v); !math.IsInf(f, 0) && !math.IsNaN(f) {
+			return appendJSONFloat(b, f, 32), nil
+		}
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return b, err
+	}
+	return append(b, data...), nil
+}
+
+// appendJSONFloat formats a float as encoding/json does: without an
+// exponent unless the number is very small or large
+func appendJSONFloat(b []byte, f float64, bits int) []byte {
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	b = strconv.AppendFloat(b, f, format, -1, bits)
+	if format == 'e' {
+		// Clean up e-09 to e-9
+		if n := len(b); b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString appends s quoted as encoding/json quotes strings,
+// escaping <, > and & for HTML safety and replacing invalid UTF-8
+func appendJSONString(b []byte, s string) []byte {
+	b = append(b, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' && c != '<' && c != '>' && c != '&' {
+				i++
+				continue
+			}
+			b = append(b, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				b = append(b, '\\', c)
+			case '\b':
+				b = append(b, '\\', 'b')
+			case '\f':
+				b = append(b, '\\', 'f')
+			case '\n':
+				b = append(b, '\\', 'n')
+			case '\r':
+				b = append(b, '\\', 'r')
+			case '\t':
+				b = append(b, '\\', 't')
+			default:
+				b = append(b, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b = append(b, s[start:i]...)
+			b = append(b, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		// Line and paragraph separators break JavaScript string literals
+		if r == '\u2028' || r == '\u2029' {
+			b = append(b, s[start:i]...)
+			b = append(b, '\\', 'u', '2', '0', '2', hexDigits[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	b = append(b, s[start:]...)
+	return append(b, '"')
+}
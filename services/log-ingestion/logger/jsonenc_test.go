@@ -0,0 +1,126 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestAppendJSONEntry_MatchesMarshal(t *testing.T) {
+	duration := 1500 * time.Millisecond
+	entries := []LogEntry{
+		{Timestamp: time.Date(2024, 1, 15, 10, 30, 45, 0, time.UTC), Level: "INFO", Message: "plain"},
+		{
+			Timestamp:  time.Date(2024, 1, 15, 10, 30, 45, 120000000, time.UTC),
+			Level:      "ERROR",
+			Message:    "quotes \" backslash \\ <html> & \n\t\r\x01 \u2028 ünïcode",
+			Service:    "svc",
+			Component:  "comp",
+			Logger:     "ingest.batch",
+			TraceID:    "trace",
+			SpanID:     "span",
+			UserID:     "user",
+			TenantID:   "tenant",
+			RequestID:  "request",
+			File:       "file.go",
+			Line:       42,
+			Function:   "pkg.Func",
+			Duration:   &duration,
+			Error:      "boom",
+			ErrorChain: []ErrorCause{{Type: "*errors.errorString", Message: "boom"}},
+			Stack:      []string{"main.main (main.go:1)"},
+			Fields: map[string]interface{}{
+				"string":   "value",
+				"int":      42,
+				"int64":    int64(-7),
+				"uint64":   uint64(math.MaxUint64),
+				"float":    3.25,
+				"tiny":     1e-9,
+				"huge":     1e22,
+				"float32":  float32(0.1),
+				"bool":     true,
+				"nil":      nil,
+				"duration": time.Second,
+				"level":    WARN,
+				"nested":   map[string]interface{}{"b": []int{1, 2}, "a": "<x>"},
+				"z":        "last",
+			},
+			Tags: []string{"a", "b"},
+		},
+	}
+
+	for _, entry := range entries {
+		expected, err := json.Marshal(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := appendJSONEntry(nil, entry, timestampFormat{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, expected) {
+			t.Errorf("Encoding differs from json.Marshal:\ngot  %s\nwant %s", got, expected)
+		}
+	}
+}
+
+func TestAppendJSONEntry_UnsupportedValue(t *testing.T) {
+	f := formatter{format: JSON}
+	output := f.formatEntry(LogEntry{Level: "INFO", Message: "message", Fields: map[string]interface{}{"nan": math.NaN()}})
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(output), &doc); err != nil {
+		t.Fatalf("Expected valid JSON, got %s", output)
+	}
+	if doc["level"] != "ERROR" {
+		t.Errorf("Expected a marshal failure entry, got %s", output)
+	}
+}
+
+func TestLogger_CallerWithoutAllocations(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := New(Config{Level: "INFO", Format: "JSON", Service: "test-service", Component: "test"})
+	logger.output = &buffer
+	logger.WithError(errors.New("boom")).Info("caller")
+
+	var entry LogEntry
+	if err := json.Unmarshal(buffer.Bytes(), &entry); err != nil {
+		t.Fatalf("Failed to parse entry: %v", err)
+	}
+	if entry.File != "jsonenc_test.go" || entry.Function != "logger.TestLogger_CallerWithoutAllocations" {
+		t.Errorf("Expected this test as the caller, got %s %s", entry.File, entry.Function)
+	}
+
+	logger.output = io.Discard
+	if allocs := testing.AllocsPerRun(100, func() { logger.Info("message") }); allocs != 0 {
+		t.Errorf("Expected no allocations per entry, got %v", allocs)
+	}
+}
+
+func BenchmarkLogger_JSONEncode(b *testing.B) {
+	entry := LogEntry{
+		Timestamp: time.Now().UTC(),
+		Level:     "INFO",
+		Message:   "benchmark message",
+		Service:   "bench-service",
+		Component: "bench-component",
+		File:      "test.go",
+		Line:      42,
+		Function:  "TestFunction",
+		Fields: map[string]interface{}{
+			"user_id": "123",
+			"action":  "test",
+			"count":   42,
+		},
+	}
+
+	buf := make([]byte, 0, 1024)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf, _ = appendJSONEntry(buf[:0], entry, timestampFormat{})
+	}
+}
@@ -0,0 +1,137 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// levelState holds the minimum levels shared by a logger and every logger
+// derived from it, so a change is seen by all of them at once
+type levelState struct {
+	base       int32
+	components atomic.Value // map[string]LogLevel, replaced as a whole
+}
+
+func newLevelState(base LogLevel, components map[string]LogLevel) *levelState {
+	s := &levelState{}
+	s.set(base, components)
+	return s
+}
+
+// get returns the minimum level of a logger: the override of its name or
+// the nearest parent name, else of its component
+func (s *levelState) get(component, name string) LogLevel {
+	overrides := s.components.Load().(map[string]LogLevel)
+	for name != "" {
+		if level, ok := overrides[name]; ok {
+			return level
+		}
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	if level, ok := overrides[component]; ok {
+		return level
+	}
+	return LogLevel(atomic.LoadInt32(&s.base))
+}
+
+// set replaces the base level and the component overrides; a nil map keeps
+// the current overrides
+func (s *levelState) set(base LogLevel, components map[string]LogLevel) {
+	atomic.StoreInt32(&s.base, int32(base))
+	if components != nil || s.components.Load() == nil {
+		copied := make(map[string]LogLevel, len(components))
+		for component, level := range components {
+			copied[component] = level
+		}
+		s.components.Store(copied)
+	}
+}
+
+// snapshot returns the base level and a copy of the component overrides
+func (s *levelState) snapshot() (LogLevel, map[string]LogLevel) {
+	current := s.components.Load().(map[string]LogLevel)
+	components := make(map[string]LogLevel, len(current))
+	for component, level := range current {
+		components[component] = level
+	}
+	return LogLevel(atomic.LoadInt32(&s.base)), components
+}
+
+// envLevels is the level state shared by all loggers created by NewFromEnv,
+// with the LOG_LEVEL and LOG_LEVELS values it was last loaded from
+var envLevels struct {
+	sync.Mutex
+	state  *levelState
+	level  string
+	levels string
+}
+
+// envLevelState returns the shared level state of NewFromEnv loggers. It is
+// reloaded only when the environment values differ from the last load, so
+// levels changed at runtime survive the creation of further loggers.
+func envLevelState(level, levels string, force bool) *levelState {
+	envLevels.Lock()
+	defer envLevels.Unlock()
+
+	base, components := parseLogLevel(level), parseComponentLevels(parseLevelList(levels))
+	switch {
+	case envLevels.state == nil:
+		envLevels.state = newLevelState(base, components)
+	case force || level != envLevels.level || levels != envLevels.levels:
+		envLevels.state.set(base, components)
+	}
+	envLevels.level, envLevels.levels = level, levels
+	return envLevels.state
+}
+
+// MarshalText writes the level by name, e.g. in JSON fields
+func (l LogLevel) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// ParseLevel parses a level name case-insensitively
+func ParseLevel(name string) (LogLevel, error) {
+	level, ok := lookupLevel(name)
+	if !ok {
+		return INFO, fmt.Errorf("unknown log level %q", name)
+	}
+	return level, nil
+}
+
+// Levels returns the level and component overrides of the loggers created
+// by NewFromEnv
+func Levels() (LogLevel, map[string]LogLevel) {
+	return envLevelState(getEnv("LOG_LEVEL", "INFO"), getEnv("LOG_LEVELS", ""), false).snapshot()
+}
+
+// SetLevels changes the level of every logger created by NewFromEnv, and of
+// the loggers derived from them, while they are in use. A nil components map
+// keeps the current component overrides.
+func SetLevels(level LogLevel, components map[string]LogLevel) {
+	envLevelState(getEnv("LOG_LEVEL", "INFO"), getEnv("LOG_LEVELS", ""), false).set(level, components)
+}
+
+// ReloadLevels resets the levels of the loggers created by NewFromEnv to
+// LOG_LEVEL and LOG_LEVELS, e.g. after the environment was reloaded
+func ReloadLevels() {
+	envLevelState(getEnv("LOG_LEVEL", "INFO"), getEnv("LOG_LEVELS", ""), true)
+}
+
+// Level returns the minimum level of the logger's component
+func (l *Logger) Level() LogLevel {
+	return l.levels.get(l.component, l.name)
+}
+
+// SetLevels changes the level of the logger and of every logger sharing its
+// levels: the loggers derived from it and, for a logger created by
+// NewFromEnv, all other NewFromEnv loggers. A nil components map keeps the
+// current component overrides.
+func (l *Logger) SetLevels(level LogLevel, components map[string]LogLevel) {
+	l.levels.set(level, components)
+}
@@ -0,0 +1,118 @@
+package logger
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLogger_SetLevelsReachesDerivedLoggers(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := New(Config{Level: "WARN", Service: "test-service", Component: "test-component"})
+	logger.output = &buffer
+	derived := logger.WithField("k", "v").WithComponent("database")
+
+	derived.Info("hidden")
+	logger.SetLevels(DEBUG, map[string]LogLevel{"database": INFO})
+	derived.Debug("hidden database debug")
+	derived.Info("database info")
+	logger.Debug("debug")
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "database info") || !strings.Contains(lines[1], `"message":"debug"`) {
+		t.Errorf("Expected the new levels to apply to both loggers, got %v", lines)
+	}
+
+	// A nil map keeps the component overrides
+	logger.SetLevels(ERROR, nil)
+	if derived.Level() != INFO || logger.Level() != ERROR {
+		t.Errorf("Expected database INFO and base ERROR, got %v and %v", derived.Level(), logger.Level())
+	}
+}
+
+func TestLogger_NameLevels(t *testing.T) {
+	logger := New(Config{
+		Level:     "WARN",
+		Service:   "test-service",
+		Component: "handlers",
+		Levels:    map[string]string{"handlers": "ERROR", "ingest": "INFO", "ingest.batch": "DEBUG"},
+	})
+
+	tests := []struct {
+		logger   *Logger
+		expected LogLevel
+	}{
+		{logger, ERROR},
+		{logger.WithName("query"), ERROR},
+		{logger.WithName("ingest"), INFO},
+		{logger.WithName("ingest").WithName("batch"), DEBUG},
+		{logger.WithName("ingest.batch").WithName("flush"), DEBUG},
+		{logger.WithName("ingest.stream"), INFO},
+		{logger.WithComponent("database").WithName("ingester"), WARN},
+	}
+	for _, test := range tests {
+		if level := test.logger.Level(); level != test.expected {
+			t.Errorf("Expected %v for %q in %q, got %v", test.expected, test.logger.name, test.logger.component, level)
+		}
+	}
+}
+
+func TestSetLevels_EnvLoggers(t *testing.T) {
+	os.Setenv("LOG_LEVEL", "WARN")
+	os.Setenv("LOG_LEVELS", "database:DEBUG")
+	defer func() {
+		os.Unsetenv("LOG_LEVEL")
+		os.Unsetenv("LOG_LEVELS")
+		ReloadLevels()
+	}()
+
+	first := NewFromEnv("test-service", "handlers")
+	if first.Level() != WARN {
+		t.Fatalf("Expected level WARN, got %v", first.Level())
+	}
+
+	SetLevels(DEBUG, map[string]LogLevel{"http": ERROR})
+	second := NewFromEnv("test-service", "http")
+	if first.Level() != DEBUG || second.Level() != ERROR {
+		t.Errorf("Expected runtime levels to reach existing and new loggers, got %v and %v", first.Level(), second.Level())
+	}
+
+	level, components := Levels()
+	if level != DEBUG || len(components) != 1 || components["http"] != ERROR {
+		t.Errorf("Unexpected levels: %v %v", level, components)
+	}
+
+	ReloadLevels()
+	if first.Level() != WARN || first.WithComponent("database").Level() != DEBUG {
+		t.Errorf("Expected reload to restore the environment levels")
+	}
+}
+
+func TestSetLevels_Concurrent(t *testing.T) {
+	logger := New(Config{Level: "INFO", Service: "test-service", Component: "test-component"})
+	logger.output = &bytes.Buffer{}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				logger.SetLevels(LogLevel(j%4), map[string]LogLevel{"x": LogLevel(i)})
+				logger.WithComponent("x").Level()
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestParseLevel(t *testing.T) {
+	if level, err := ParseLevel("warning"); err != nil || level != WARN {
+		t.Errorf("Expected WARN, got %v %v", level, err)
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Errorf("Expected an unknown level to fail")
+	}
+}
@@ -0,0 +1,136 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// formatLogfmtEntry formats a log entry as logfmt key=value pairs. Custom
+// fields follow the standard keys in key order, flattened to the top level
+// since logfmt has no nesting.
+func (f formatter) formatLogfmtEntry(entry LogEntry) string {
+	var b strings.Builder
+
+	writeLogfmtPair(&b, f.time.keyOr("timestamp"), f.time.text(entry.Timestamp, time.RFC3339Nano))
+	writeLogfmtPair(&b, "level", entry.Level)
+	writeLogfmtPair(&b, "message", entry.Message)
+	writeLogfmtPair(&b, "service", entry.Service)
+	writeLogfmtPair(&b, "component", entry.Component)
+	if entry.Logger != "" {
+		writeLogfmtPair(&b, "logger", entry.Logger)
+	}
+
+	optional := []struct{ key, value string }{
+		{"trace_id", entry.TraceID},
+		{"span_id", entry.SpanID},
+		{"user_id", entry.UserID},
+		{"tenant_id", entry.TenantID},
+		{"request_id", entry.RequestID},
+	}
+	for _, pair := range optional {
+		if pair.value != "" {
+			writeLogfmtPair(&b, pair.key, pair.value)
+		}
+	}
+
+	if entry.File != "" {
+		writeLogfmtPair(&b, "file", entry.File)
+		writeLogfmtPair(&b, "line", strconv.Itoa(entry.Line))
+		writeLogfmtPair(&b, "function", entry.Function)
+	}
+	if entry.Duration != nil {
+		writeLogfmtPair(&b, "duration", entry.Duration.String())
+	}
+	if entry.Error != "" {
+		writeLogfmtPair(&b, "error", entry.Error)
+	}
+
+	keys := make([]string, 0, len(entry.Fields))
+	for k := range entry.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		writeLogfmtPair(&b, k, logfmtValue(entry.Fields[k]))
+	}
+
+	if len(entry.Tags) > 0 {
+		writeLogfmtPair(&b, "tags", strings.Join(entry.Tags, ","))
+	}
+	if len(entry.ErrorChain) > 0 {
+		writeLogfmtPair(&b, "error_chain", logfmtValue(entry.ErrorChain))
+	}
+	if len(entry.Stack) > 0 {
+		writeLogfmtPair(&b, "stack", strings.Join(entry.Stack, "\n"))
+	}
+
+	return b.String()
+}
+
+// writeLogfmtPair appends key=value, quoting the value when needed
+func writeLogfmtPair(b *strings.Builder, key, value string) {
+	if b.Len() > 0 {
+		b.WriteByte(' ')
+	}
+	b.WriteString(logfmtKey(key))
+	b.WriteByte('=')
+	if logfmtNeedsQuoting(value) {
+		b.WriteString(strconv.Quote(value))
+		return
+	}
+	b.WriteString(value)
+}
+
+// logfmtValue renders a field value: scalars as text, errors by message, and
+// maps, slices and structs as JSON
+func logfmtValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(v)
+	default:
+		if data, err := json.Marshal(v); err == nil {
+			return string(data)
+		}
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// logfmtKey replaces characters a logfmt key cannot contain
+func logfmtKey(key string) string {
+	if key == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' || r == unicode.ReplacementChar || !unicode.IsPrint(r) {
+			return '_'
+		}
+		return r
+	}, key)
+}
+
+// logfmtNeedsQuoting reports whether a value must be quoted: when empty or
+// containing spaces, '=', quotes or unprintable characters
+func logfmtNeedsQuoting(value string) bool {
+	if value == "" {
+		return true
+	}
+	for _, r := range value {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || r == unicode.ReplacementChar || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
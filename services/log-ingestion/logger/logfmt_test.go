@@ -0,0 +1,83 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogger_LogfmtOutput(t *testing.T) {
+	var buffer bytes.Buffer
+
+	logger := New(Config{Level: "INFO", Format: "LOGFMT", Service: "test-service", Component: "test-component"})
+	logger.output = &buffer
+
+	ctx := WithRequestID(context.Background(), "req-1")
+	logger.WithFields(map[string]interface{}{
+		"status": 200,
+		"path":   "/logs",
+		"tags":   []string{"a", "b"},
+	}).WithDuration(1500*time.Millisecond).InfoContext(ctx, "request completed")
+
+	output := strings.TrimSpace(buffer.String())
+	if strings.Count(buffer.String(), "\n") != 1 {
+		t.Fatalf("Expected a single line, got %q", buffer.String())
+	}
+
+	expected := []string{
+		"level=INFO",
+		`message="request completed"`,
+		"service=test-service",
+		"component=test-component",
+		"request_id=req-1",
+		"file=logfmt_test.go",
+	}
+	for _, want := range expected {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q, got %v", want, output)
+		}
+	}
+	if !strings.HasPrefix(output, "timestamp=") {
+		t.Errorf("Expected output to start with the timestamp, got %v", output)
+	}
+	if !strings.Contains(output, `duration=1.5s path=/logs status=200 tags="[\"a\",\"b\"]"`) {
+		t.Errorf("Expected fields in key order, got %v", output)
+	}
+}
+
+func TestLogfmtValues(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected string
+	}{
+		{"plain", "k=plain"},
+		{"", `k=""`},
+		{"two words", `k="two words"`},
+		{`say "hi"`, `k="say \"hi\""`},
+		{"a=b", `k="a=b"`},
+		{"line\nbreak", `k="line\nbreak"`},
+		{42, "k=42"},
+		{true, "k=true"},
+		{nil, "k=null"},
+		{errors.New("boom failed"), `k="boom failed"`},
+		{2 * time.Second, "k=2s"},
+		{map[string]int{"a": 1}, `k="{\"a\":1}"`},
+	}
+
+	for _, test := range tests {
+		var b strings.Builder
+		writeLogfmtPair(&b, "k", logfmtValue(test.value))
+		if b.String() != test.expected {
+			t.Errorf("logfmt value %#v = %s, want %s", test.value, b.String(), test.expected)
+		}
+	}
+
+	var b strings.Builder
+	writeLogfmtPair(&b, "bad key=", "v")
+	if b.String() != "bad_key_=v" {
+		t.Errorf("Expected key to be sanitized, got %s", b.String())
+	}
+}
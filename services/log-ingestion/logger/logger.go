@@ -3,11 +3,14 @@ package logger
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -47,26 +50,45 @@ type LogEntry struct {
 	Message      string                 `json:"message"`
 	Service      string                 `json:"service"`
 	Component    string                 `json:"component"`
+	Logger       string                 `json:"logger,omitempty"`
 	TraceID      string                 `json:"trace_id,omitempty"`
+	SpanID       string                 `json:"span_id,omitempty"`
 	UserID       string                 `json:"user_id,omitempty"`
+	TenantID     string                 `json:"tenant_id,omitempty"`
 	RequestID    string                 `json:"request_id,omitempty"`
-	File         string                 `json:"file"`
-	Line         int                    `json:"line"`
-	Function     string                 `json:"function"`
+	File         string                 `json:"file,omitempty"`
+	Line         int                    `json:"line,omitempty"`
+	Function     string                 `json:"function,omitempty"`
 	Duration     *time.Duration         `json:"duration,omitempty"`
 	Error        string                 `json:"error,omitempty"`
+	ErrorChain   []ErrorCause           `json:"error_chain,omitempty"`
+	Stack        []string               `json:"stack,omitempty"`
 	Fields       map[string]interface{} `json:"fields,omitempty"`
 	Tags         []string               `json:"tags,omitempty"`
 }
 
 // Logger represents the structured logger
 type Logger struct {
-	level     LogLevel
+	levels    *levelState
 	service   string
 	component string
+	name      string
 	output    io.Writer
 	format    LogFormat
+	color     bool
 	fields    map[string]interface{}
+	async     *asyncWriter
+	sink      Sink
+	sampler   *sampler
+
+	outputs     []*destination
+	otlp        *otlpExporter
+	redactor    *redactor
+	timestamps  timestampFormat
+	noCaller    bool
+	stackTraces bool
+	errorChain  []ErrorCause
+	envKey      string
 }
 
 // LogFormat represents the output format
@@ -75,6 +97,10 @@ type LogFormat int
 const (
 	JSON LogFormat = iota
 	TEXT
+	LOGFMT
+	CONSOLE
+	ECS
+	GELF
 )
 
 // Config represents logger configuration
@@ -85,6 +111,59 @@ type Config struct {
 	Component string            `json:"component"`
 	Output    string            `json:"output"`
 	Fields    map[string]interface{} `json:"fields"`
+
+	// Levels overrides Level for loggers of the named components, e.g.
+	// {"database": "DEBUG", "http": "WARN"}
+	Levels map[string]string `json:"levels"`
+
+	// Async hands entries to a background writer through a buffer of
+	// BufferSize entries; Overflow ("block" or "drop") decides what happens
+	// when the buffer is full
+	Async      bool   `json:"async"`
+	BufferSize int    `json:"buffer_size"`
+	Overflow   string `json:"overflow"`
+
+	// Sampling thins out repeated entries per level name, with counters
+	// reset every SamplingTick (default one second)
+	Sampling     map[string]SamplingRule `json:"sampling"`
+	SamplingTick time.Duration           `json:"sampling_tick"`
+
+	// StackTraces attaches a stack trace to ERROR and FATAL entries and to
+	// entries logged with WithError, which also records the error chain
+	StackTraces bool `json:"stack_traces"`
+
+	// Outputs writes every entry to several destinations, each with its own
+	// format and minimum level, instead of Output in Format
+	Outputs []OutputConfig `json:"outputs"`
+
+	// OTLP also exports every entry to an OpenTelemetry collector when its
+	// Endpoint is set
+	OTLP OTLPConfig `json:"otlp"`
+
+	// RedactFields masks fields whose names contain one of these names,
+	// case-insensitively, including keys of nested maps; nil masks
+	// DefaultRedactFields. DisableRedaction turns masking off.
+	RedactFields     []string `json:"redact_fields"`
+	DisableRedaction bool     `json:"disable_redaction"`
+
+	// TimeKey renames the timestamp in JSON and logfmt output, and
+	// TimeFormat sets how JSON, logfmt and text output write it: RFC3339,
+	// RFC3339Nano, epoch, epoch_millis, epoch_micros, epoch_nanos or a Go
+	// time layout
+	TimeKey    string `json:"time_key"`
+	TimeFormat string `json:"time_format"`
+
+	// SyslogFacility is the facility of syslog outputs, e.g. "local0";
+	// the default is "user"
+	SyslogFacility string `json:"syslog_facility"`
+
+	// DisableCaller leaves out the file, line and function of the logging
+	// call, saving a runtime.Caller lookup on every entry
+	DisableCaller bool `json:"disable_caller"`
+
+	// Sink writes entries in place of Output and Outputs, e.g. with zap or
+	// zerolog
+	Sink Sink `json:"-"`
 }
 
 // contextKey is a custom type for context keys
@@ -92,26 +171,38 @@ type contextKey string
 
 const (
 	traceIDKey   contextKey = "trace_id"
+	spanIDKey    contextKey = "span_id"
 	userIDKey    contextKey = "user_id"
+	tenantIDKey  contextKey = "tenant_id"
 	requestIDKey contextKey = "request_id"
 )
 
 // New creates a new structured logger
 func New(config Config) *Logger {
 	logger := &Logger{
-		level:     parseLogLevel(config.Level),
+		levels:    newLevelState(parseLogLevel(config.Level), parseComponentLevels(config.Levels)),
 		service:   config.Service,
 		component: config.Component,
-		format:    parseLogFormat(config.Format),
 		fields:    make(map[string]interface{}),
 		output:    os.Stdout,
 	}
 
-	// Set output destination
-	if config.Output != "" && config.Output != "stdout" {
-		if file, err := os.OpenFile(config.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666); err == nil {
-			logger.output = file
-		}
+	// A sink replaces the outputs
+	if config.Sink != nil {
+		logger.sink = config.Sink
+		config.Output, config.Outputs, config.Async = "", nil, false
+	}
+
+	// Syslog frames each entry by level, so it is written as an output
+	if len(config.Outputs) == 0 && isSyslogOutput(config.Output) {
+		config.Outputs = []OutputConfig{{Output: config.Output, Format: config.Format}}
+	}
+
+	// Set output destination; Outputs replace Output and Format
+	if len(config.Outputs) == 0 {
+		logger.output = openOutput(config.Output)
+		logger.format, logger.color = resolveFormat(config.Format, logger.output)
+		frameGELF(logger.output, logger.format)
 	}
 
 	// Add default fields
@@ -121,6 +212,31 @@ func New(config Config) *Logger {
 		}
 	}
 
+	logger.sampler = newSampler(config.Sampling, config.SamplingTick)
+	logger.stackTraces = config.StackTraces
+	logger.noCaller = config.DisableCaller
+	logger.timestamps = newTimestampFormat(config.TimeKey, config.TimeFormat)
+	if !config.DisableRedaction {
+		if config.RedactFields == nil {
+			config.RedactFields = DefaultRedactFields
+		}
+		logger.redactor = newRedactor(config.RedactFields)
+		logger.redactor.redactFields(logger.fields)
+	}
+
+	if config.Async && len(config.Outputs) == 0 {
+		logger.async = newAsyncWriter(logger.output, config.BufferSize, parseOverflowPolicy(config.Overflow), logger.droppedNotice)
+		logger.output = logger.async
+	}
+
+	for _, output := range config.Outputs {
+		logger.outputs = append(logger.outputs, logger.newDestination(output, config))
+	}
+
+	if config.OTLP.Endpoint != "" {
+		logger.otlp = sharedOTLPExporter(config.OTLP)
+	}
+
 	return logger
 }
 
@@ -128,37 +244,77 @@ func New(config Config) *Logger {
 func NewFromEnv(service, component string) *Logger {
 	config := Config{
 		Level:     getEnv("LOG_LEVEL", "INFO"),
-		Format:    getEnv("LOG_FORMAT", "JSON"),
+		Format:    getEnv("LOG_FORMAT", "AUTO"),
 		Service:   service,
 		Component: component,
 		Output:    getEnv("LOG_OUTPUT", "stdout"),
+		Levels:    parseLevelList(getEnv("LOG_LEVELS", "")),
+		Overflow:  getEnv("LOG_OVERFLOW", "block"),
+		Sampling:  parseSampling(getEnv("LOG_SAMPLING", "")),
+	}
+	config.Async, _ = strconv.ParseBool(getEnv("LOG_ASYNC", "false"))
+	config.BufferSize, _ = strconv.Atoi(getEnv("LOG_BUFFER_SIZE", strconv.Itoa(DefaultBufferSize)))
+	config.SamplingTick, _ = time.ParseDuration(getEnv("LOG_SAMPLING_TICK", DefaultSamplingTick.String()))
+	config.StackTraces, _ = strconv.ParseBool(getEnv("LOG_STACK_TRACES", "false"))
+	config.SyslogFacility = getEnv("LOG_SYSLOG_FACILITY", "user")
+	config.TimeKey = getEnv("LOG_TIME_KEY", "")
+	config.TimeFormat = getEnv("LOG_TIME_FORMAT", "")
+	if fields := getEnv("LOG_REDACT_FIELDS", ""); fields != "" {
+		config.RedactFields = strings.Split(fields, ",")
+	}
+	if redact, err := strconv.ParseBool(getEnv("LOG_REDACT", "true")); err == nil {
+		config.DisableRedaction = !redact
 	}
 
-	return New(config)
+	// The standard OpenTelemetry variables; the generic endpoint is the
+	// collector's base URL
+	config.OTLP.Endpoint = getEnv("OTEL_EXPORTER_OTLP_LOGS_ENDPOINT", "")
+	if base := getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""); config.OTLP.Endpoint == "" && base != "" {
+		config.OTLP.Endpoint = strings.TrimRight(base, "/") + "/v1/logs"
+	}
+	config.OTLP.Headers = parseOTLPHeaders(getEnv("OTEL_EXPORTER_OTLP_HEADERS", ""))
+	if caller, err := strconv.ParseBool(getEnv("LOG_CALLER", "true")); err == nil {
+		config.DisableCaller = !caller
+	}
+
+	if envFields, err := strconv.ParseBool(getEnv("LOG_ENV_FIELDS", "true")); err != nil || envFields {
+		config.Fields = environmentFields()
+	}
+	if selfSource, err := strconv.ParseBool(getEnv("LOG_SELF_SOURCE", "true")); err != nil || selfSource {
+		if config.Fields == nil {
+			config.Fields = make(map[string]interface{})
+		}
+		config.Fields[SelfSourceField] = service
+	}
+
+	logger := newWithEnvOutputs(config)
+
+	// Loggers from the environment share their levels so they can be
+	// changed at runtime with SetLevels
+	logger.levels = envLevelState(config.Level, getEnv("LOG_LEVELS", ""), false)
+
+	return logger
 }
 
 // WithFields adds fields to the logger context
 func (l *Logger) WithFields(fields map[string]interface{}) *Logger {
-	newLogger := &Logger{
-		level:     l.level,
-		service:   l.service,
-		component: l.component,
-		output:    l.output,
-		format:    l.format,
-		fields:    make(map[string]interface{}),
-	}
+	newLogger := *l
+	newLogger.fields = make(map[string]interface{}, len(l.fields)+len(fields))
 
 	// Copy existing fields
 	for k, v := range l.fields {
 		newLogger.fields[k] = v
 	}
 
-	// Add new fields
+	// Add new fields, masked once here rather than in every entry
 	for k, v := range fields {
+		if l.redactor != nil {
+			v = l.redactor.redact(k, v)
+		}
 		newLogger.fields[k] = v
 	}
 
-	return newLogger
+	return &newLogger
 }
 
 // WithField adds a single field to the logger context
@@ -166,12 +322,29 @@ func (l *Logger) WithField(key string, value interface{}) *Logger {
 	return l.WithFields(map[string]interface{}{key: value})
 }
 
-// WithError adds an error to the logger context
+// WithError adds an error to the logger context: its message as error, its
+// type as error_type and, when an error in its chain implements Coder, the
+// code as error_code. The chain of wrapped errors is recorded when there is
+// one, or always when the logger captures stack traces.
 func (l *Logger) WithError(err error) *Logger {
-	if err != nil {
-		return l.WithField("error", err.Error())
+	if err == nil {
+		return l
+	}
+
+	fields := map[string]interface{}{
+		"error":      err.Error(),
+		"error_type": fmt.Sprintf("%T", err),
 	}
-	return l
+	var coder Coder
+	if errors.As(err, &coder) {
+		fields["error_code"] = coder.Code()
+	}
+
+	newLogger := l.WithFields(fields)
+	if chain := errorChain(err); len(chain) > 1 || l.stackTraces {
+		newLogger.errorChain = chain
+	}
+	return newLogger
 }
 
 // WithDuration adds duration to the logger context
@@ -179,6 +352,18 @@ func (l *Logger) WithDuration(duration time.Duration) *Logger {
 	return l.WithField("duration", duration.String())
 }
 
+// WithName returns a logger whose entries carry a hierarchical name: the
+// name of l, if any, and name joined by a dot, as with zap's Named. Level
+// overrides apply by name, the most specific first, before the component.
+func (l *Logger) WithName(name string) *Logger {
+	newLogger := *l
+	if l.name != "" {
+		name = l.name + "." + name
+	}
+	newLogger.name = name
+	return &newLogger
+}
+
 // WithComponent sets the component for this log entry
 func (l *Logger) WithComponent(component string) *Logger {
 	newLogger := *l
@@ -246,16 +431,17 @@ func (l *Logger) ErrorContext(ctx context.Context, message string) {
 	l.logWithContext(ctx, ERROR, message, nil)
 }
 
-// Fatal logs a fatal message and exits
+// Fatal logs a fatal message, runs the exit hooks and exits
 func (l *Logger) Fatal(message string) {
 	l.log(FATAL, message, nil)
-	os.Exit(1)
+	l.fatalExit(message)
 }
 
-// Fatalf logs a formatted fatal message and exits
+// Fatalf logs a formatted fatal message, runs the exit hooks and exits
 func (l *Logger) Fatalf(format string, args ...interface{}) {
-	l.log(FATAL, fmt.Sprintf(format, args...), nil)
-	os.Exit(1)
+	message := fmt.Sprintf(format, args...)
+	l.log(FATAL, message, nil)
+	l.fatalExit(message)
 }
 
 // LogHTTPRequest logs HTTP request details
@@ -296,40 +482,19 @@ func (l *Logger) LogBusinessEvent(event string, entityID string, fields map[stri
 
 // log writes a log entry
 func (l *Logger) log(level LogLevel, message string, extraFields map[string]interface{}) {
-	if level < l.level {
+	if !l.enabled(level, message) {
 		return
 	}
 
-	// Get caller information
-	file, line, function := getCaller()
-
-	entry := LogEntry{
-		Timestamp: time.Now().UTC(),
-		Level:     level.String(),
-		Message:   message,
-		Service:   l.service,
-		Component: l.component,
-		File:      file,
-		Line:      line,
-		Function:  function,
-		Fields:    make(map[string]interface{}),
-	}
-
-	// Add logger fields
-	for k, v := range l.fields {
-		entry.Fields[k] = v
-	}
+	entry := l.newEntry(context.Background(), level, message, extraFields)
 
-	// Add extra fields
-	if extraFields != nil {
-		for k, v := range extraFields {
-			entry.Fields[k] = v
-		}
+	// Get caller information
+	if !l.noCaller {
+		entry.File, entry.Line, entry.Function = getCaller()
 	}
-
-	// Remove empty fields map if no fields
-	if len(entry.Fields) == 0 {
-		entry.Fields = nil
+	if l.captureStack(level) {
+		// Skip log/logWithContext and the public logging method
+		entry.Stack = stackTrace(2)
 	}
 
 	l.writeEntry(entry)
@@ -337,92 +502,221 @@ func (l *Logger) log(level LogLevel, message string, extraFields map[string]inte
 
 // logWithContext writes a log entry with context information
 func (l *Logger) logWithContext(ctx context.Context, level LogLevel, message string, extraFields map[string]interface{}) {
-	if level < l.level {
+	// Handling the system's own logs logs nothing but errors
+	if level < ERROR && IsSelfLog(ctx) {
+		return
+	}
+	if !l.enabled(level, message) {
 		return
 	}
 
+	entry := l.newEntry(ctx, level, message, extraFields)
+
 	// Get caller information
-	file, line, function := getCaller()
+	if !l.noCaller {
+		entry.File, entry.Line, entry.Function = getCaller()
+	}
+	if l.captureStack(level) {
+		// Skip log/logWithContext and the public logging method
+		entry.Stack = stackTrace(2)
+	}
 
+	l.writeEntry(entry)
+}
+
+// enabled reports whether an entry passes the level and sampling
+func (l *Logger) enabled(level LogLevel, message string) bool {
+	if level < l.Level() {
+		return false
+	}
+	return l.sampler == nil || l.sampler.allow(level, message)
+}
+
+// newEntry builds a log entry carrying the logger's fields, the extra fields
+// and the IDs found in ctx
+func (l *Logger) newEntry(ctx context.Context, level LogLevel, message string, extraFields map[string]interface{}) LogEntry {
 	entry := LogEntry{
-		Timestamp: time.Now().UTC(),
-		Level:     level.String(),
-		Message:   message,
-		Service:   l.service,
-		Component: l.component,
-		File:      file,
-		Line:      line,
-		Function:  function,
-		Fields:    make(map[string]interface{}),
+		Timestamp:  time.Now().UTC(),
+		Level:      level.String(),
+		Message:    message,
+		Service:    l.service,
+		Component:  l.component,
+		Logger:     l.name,
+		ErrorChain: l.errorChain,
 	}
 
 	// Extract context values
 	if traceID := getFromContext(ctx, traceIDKey); traceID != "" {
 		entry.TraceID = traceID
 	}
+	if spanID := getFromContext(ctx, spanIDKey); spanID != "" {
+		entry.SpanID = spanID
+	}
+	// An active OpenTelemetry span wins, as its IDs match the exported traces
+	if activeSpan != nil {
+		if traceID, spanID, ok := activeSpan(ctx); ok {
+			entry.TraceID, entry.SpanID = traceID, spanID
+		}
+	}
 	if userID := getFromContext(ctx, userIDKey); userID != "" {
 		entry.UserID = userID
 	}
+	if tenantID := getFromContext(ctx, tenantIDKey); tenantID != "" {
+		entry.TenantID = tenantID
+	}
 	if requestID := getFromContext(ctx, requestIDKey); requestID != "" {
 		entry.RequestID = requestID
 	}
 
-	// Add logger fields
+	// The logger's fields were masked when added and are never changed
+	// afterwards, so entries share them unless there are extra fields
+	if len(extraFields) == 0 {
+		if len(l.fields) > 0 {
+			entry.Fields = l.fields
+		}
+		return entry
+	}
+
+	entry.Fields = make(map[string]interface{}, len(l.fields)+len(extraFields))
 	for k, v := range l.fields {
 		entry.Fields[k] = v
 	}
-
-	// Add extra fields
-	if extraFields != nil {
-		for k, v := range extraFields {
-			entry.Fields[k] = v
+	for k, v := range extraFields {
+		if l.redactor != nil {
+			v = l.redactor.redact(k, v)
 		}
+		entry.Fields[k] = v
 	}
 
-	// Remove empty fields map if no fields
-	if len(entry.Fields) == 0 {
-		entry.Fields = nil
-	}
-
-	l.writeEntry(entry)
+	return entry
 }
 
-// writeEntry writes the log entry to the output
+// writeEntry writes the log entry to the output, or to each of the outputs
+// it is configured with, or hands it to the sink when the logger is backed
+// by another logging engine
 func (l *Logger) writeEntry(entry LogEntry) {
-	var output string
-
-	switch l.format {
-	case JSON:
-		if jsonBytes, err := json.Marshal(entry); err == nil {
-			output = string(jsonBytes)
-		} else {
-			output = fmt.Sprintf(`{"level":"ERROR","message":"Failed to marshal log entry: %s","timestamp":"%s"}`, 
-				err.Error(), time.Now().UTC().Format(time.RFC3339))
+	keepRecent(entry)
+	if l.otlp != nil {
+		l.otlp.export(entry)
+	}
+	if l.sink != nil {
+		l.sink.Write(entry)
+		return
+	}
+	if l.outputs == nil {
+		f := formatter{format: l.format, color: l.color, time: l.timestamps}
+		buf := getBuffer()
+		*buf = append(f.appendEntry(*buf, entry), '\n')
+		l.output.Write(*buf)
+		putBuffer(buf)
+		return
+	}
+
+	level := parseLogLevel(entry.Level)
+	for _, d := range l.outputs {
+		if level >= d.level {
+			buf := getBuffer()
+			*buf = d.appendLine(*buf, entry)
+			d.output.Write(*buf)
+			putBuffer(buf)
 		}
+	}
+}
+
+// formatter formats entries in one output format
+type formatter struct {
+	format LogFormat
+	color  bool
+	time   timestampFormat
+}
+
+// formatEntry formats a log entry in the logger's format
+func (l *Logger) formatEntry(entry LogEntry) string {
+	return formatter{format: l.format, color: l.color, time: l.timestamps}.formatEntry(entry)
+}
+
+// formatEntry formats a log entry
+func (f formatter) formatEntry(entry LogEntry) string {
+	switch f.format {
 	case TEXT:
-		output = l.formatTextEntry(entry)
+		return f.formatTextEntry(entry)
+	case LOGFMT:
+		return f.formatLogfmtEntry(entry)
+	case CONSOLE:
+		return f.formatConsoleEntry(entry)
+	case ECS:
+		return f.formatECSEntry(entry)
+	case GELF:
+		return f.formatGELFEntry(entry)
+	default:
+		return string(f.appendJSON(nil, entry))
+	}
+}
+
+// appendEntry appends a log entry formatted in the formatter's format,
+// encoding JSON straight into b
+func (f formatter) appendEntry(b []byte, entry LogEntry) []byte {
+	if f.format != JSON {
+		return append(b, f.formatEntry(entry)...)
+	}
+	return f.appendJSON(b, entry)
+}
+
+func (f formatter) appendJSON(b []byte, entry LogEntry) []byte {
+	out, err := appendJSONEntry(b, entry, f.time)
+	if err != nil {
+		return append(b, fmt.Sprintf(`{"level":"ERROR","message":"Failed to marshal log entry: %s","timestamp":"%s"}`,
+			err.Error(), time.Now().UTC().Format(time.RFC3339))...)
 	}
+	return out
+}
+
+// droppedNotice formats the warning an asynchronous logger writes after
+// dropping entries on a full buffer
+func (l *Logger) droppedNotice(dropped uint64) []byte {
+	return []byte(l.formatEntry(l.droppedEntry(dropped)) + "\n")
+}
 
-	fmt.Fprintln(l.output, output)
+// droppedEntry is the warning written after dropping entries
+func (l *Logger) droppedEntry(dropped uint64) LogEntry {
+	return LogEntry{
+		Timestamp: time.Now().UTC(),
+		Level:     WARN.String(),
+		Message:   "Log entries dropped: asynchronous log buffer full",
+		Service:   l.service,
+		Component: l.component,
+		Fields:    map[string]interface{}{"dropped": dropped},
+	}
 }
 
 // formatTextEntry formats a log entry as human-readable text
-func (l *Logger) formatTextEntry(entry LogEntry) string {
-	timestamp := entry.Timestamp.Format("2006-01-02 15:04:05")
+func (f formatter) formatTextEntry(entry LogEntry) string {
+	timestamp := f.time.text(entry.Timestamp, "2006-01-02 15:04:05")
 	
-	baseMsg := fmt.Sprintf("[%s] %s [%s/%s] %s:%d %s - %s",
-		timestamp, entry.Level, entry.Service, entry.Component,
-		entry.File, entry.Line, entry.Function, entry.Message)
+	baseMsg := fmt.Sprintf("[%s] %s [%s/%s]", timestamp, entry.Level, entry.Service, entry.Component)
+	if entry.Logger != "" {
+		baseMsg = fmt.Sprintf("[%s] %s [%s/%s/%s]", timestamp, entry.Level, entry.Service, entry.Component, entry.Logger)
+	}
+	if entry.File != "" {
+		baseMsg += fmt.Sprintf(" %s:%d %s", entry.File, entry.Line, entry.Function)
+	}
+	baseMsg += " - " + entry.Message
 
 	if entry.TraceID != "" {
 		baseMsg += fmt.Sprintf(" [trace=%s]", entry.TraceID)
 	}
+	if entry.SpanID != "" {
+		baseMsg += fmt.Sprintf(" [span=%s]", entry.SpanID)
+	}
 	if entry.RequestID != "" {
 		baseMsg += fmt.Sprintf(" [request=%s]", entry.RequestID)
 	}
 	if entry.UserID != "" {
 		baseMsg += fmt.Sprintf(" [user=%s]", entry.UserID)
 	}
+	if entry.TenantID != "" {
+		baseMsg += fmt.Sprintf(" [tenant=%s]", entry.TenantID)
+	}
 
 	if entry.Fields != nil && len(entry.Fields) > 0 {
 		if fieldsJSON, err := json.Marshal(entry.Fields); err == nil {
@@ -430,26 +724,27 @@ func (l *Logger) formatTextEntry(entry LogEntry) string {
 		}
 	}
 
-	return baseMsg
+	return baseMsg + formatStackLines(entry)
 }
 
 // getCaller returns information about the calling function
 func getCaller() (file string, line int, function string) {
-	// Skip 3 frames: getCaller, log/logWithContext, public logging method
-	pc, fullFile, line, ok := runtime.Caller(3)
-	if !ok {
+	// Skip 4 frames: runtime.Callers, getCaller, log/logWithContext, public
+	// logging method. Unlike runtime.Caller, this does not allocate.
+	var pcs [1]uintptr
+	if runtime.Callers(4, pcs[:]) == 0 {
 		return "unknown", 0, "unknown"
 	}
 
-	file = filepath.Base(fullFile)
-	
-	if fn := runtime.FuncForPC(pc); fn != nil {
-		function = filepath.Base(fn.Name())
-	} else {
-		function = "unknown"
+	// The return address; the call is the instruction before it
+	pc := pcs[0] - 1
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return "unknown", 0, "unknown"
 	}
+	fullFile, line := fn.FileLine(pc)
 
-	return file, line, function
+	return filepath.Base(fullFile), line, filepath.Base(fn.Name())
 }
 
 // Helper functions
@@ -471,12 +766,60 @@ func parseLogLevel(level string) LogLevel {
 	}
 }
 
+// lookupLevel parses a level name case-insensitively, reporting whether it
+// is known
+func lookupLevel(name string) (LogLevel, bool) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	level := parseLogLevel(name)
+	return level, level.String() == name || name == "WARNING"
+}
+
+// parseComponentLevels resolves per-component level names, skipping
+// unknown ones rather than defaulting them to INFO
+func parseComponentLevels(levels map[string]string) map[string]LogLevel {
+	parsed := make(map[string]LogLevel, len(levels))
+	for component, name := range levels {
+		if level, ok := lookupLevel(name); ok {
+			parsed[component] = level
+		}
+	}
+	return parsed
+}
+
+// parseLevelList parses component:LEVEL pairs separated by commas, e.g.
+// "database:DEBUG,http:WARN"
+func parseLevelList(value string) map[string]string {
+	levels := make(map[string]string)
+	for _, item := range strings.Split(value, ",") {
+		component, level, ok := strings.Cut(strings.TrimSpace(item), ":")
+		if ok && component != "" {
+			levels[component] = level
+		}
+	}
+	return levels
+}
+
+func parseOverflowPolicy(policy string) OverflowPolicy {
+	if strings.EqualFold(policy, "drop") {
+		return OverflowDrop
+	}
+	return OverflowBlock
+}
+
 func parseLogFormat(format string) LogFormat {
 	switch format {
 	case "JSON":
 		return JSON
 	case "TEXT":
 		return TEXT
+	case "LOGFMT":
+		return LOGFMT
+	case "CONSOLE":
+		return CONSOLE
+	case "ECS":
+		return ECS
+	case "GELF":
+		return GELF
 	default:
 		return JSON
 	}
@@ -489,7 +832,13 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
-func getFromContext(ctx context.Context, key contextKey) string {
+// activeSpan returns the trace and span IDs of the OpenTelemetry span
+// active in ctx, if any; set when built with the otel tag
+var activeSpan func(ctx context.Context) (traceID, spanID string, ok bool)
+
+// getFromContext takes the key as an interface so the constant keys are not
+// boxed on every call
+func getFromContext(ctx context.Context, key interface{}) string {
 	if value := ctx.Value(key); value != nil {
 		if str, ok := value.(string); ok {
 			return str
@@ -505,11 +854,21 @@ func WithTraceID(ctx context.Context, traceID string) context.Context {
 	return context.WithValue(ctx, traceIDKey, traceID)
 }
 
+// WithSpanID adds a span ID to the context
+func WithSpanID(ctx context.Context, spanID string) context.Context {
+	return context.WithValue(ctx, spanIDKey, spanID)
+}
+
 // WithUserID adds a user ID to the context
 func WithUserID(ctx context.Context, userID string) context.Context {
 	return context.WithValue(ctx, userIDKey, userID)
 }
 
+// WithTenantID adds a tenant ID to the context
+func WithTenantID(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantIDKey, tenantID)
+}
+
 // WithRequestID adds a request ID to the context
 func WithRequestID(ctx context.Context, requestID string) context.Context {
 	return context.WithValue(ctx, requestIDKey, requestID)
@@ -520,11 +879,21 @@ func GetTraceID(ctx context.Context) string {
 	return getFromContext(ctx, traceIDKey)
 }
 
+// GetSpanID retrieves the span ID from context
+func GetSpanID(ctx context.Context) string {
+	return getFromContext(ctx, spanIDKey)
+}
+
 // GetUserID retrieves the user ID from context
 func GetUserID(ctx context.Context) string {
 	return getFromContext(ctx, userIDKey)
 }
 
+// GetTenantID retrieves the tenant ID from context
+func GetTenantID(ctx context.Context) string {
+	return getFromContext(ctx, tenantIDKey)
+}
+
 // GetRequestID retrieves the request ID from context
 func GetRequestID(ctx context.Context) string {
 	return getFromContext(ctx, requestIDKey)
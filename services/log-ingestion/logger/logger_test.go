@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"os"
 	"strings"
 	"testing"
@@ -63,6 +64,11 @@ func TestParseLogFormat(t *testing.T) {
 	}{
 		{"JSON", JSON},
 		{"TEXT", TEXT},
+		{"LOGFMT", LOGFMT},
+		{"CONSOLE", CONSOLE},
+		{"ECS", ECS},
+		{"GELF", GELF},
+		{"AUTO", JSON}, // resolved against the output by New
 		{"INVALID", JSON}, // default case
 		{"", JSON},        // default case
 	}
@@ -90,8 +96,8 @@ func TestNew(t *testing.T) {
 
 	logger := New(config)
 
-	if logger.level != DEBUG {
-		t.Errorf("Expected level DEBUG, got %v", logger.level)
+	if logger.Level() != DEBUG {
+		t.Errorf("Expected level DEBUG, got %v", logger.Level())
 	}
 	if logger.service != "test-service" {
 		t.Errorf("Expected service 'test-service', got %v", logger.service)
@@ -120,8 +126,8 @@ func TestNewFromEnv(t *testing.T) {
 
 	logger := NewFromEnv("test-service", "test-component")
 
-	if logger.level != ERROR {
-		t.Errorf("Expected level ERROR, got %v", logger.level)
+	if logger.Level() != ERROR {
+		t.Errorf("Expected level ERROR, got %v", logger.Level())
 	}
 	if logger.format != TEXT {
 		t.Errorf("Expected format TEXT, got %v", logger.format)
@@ -136,8 +142,8 @@ func TestNewFromEnvDefaults(t *testing.T) {
 
 	logger := NewFromEnv("test-service", "test-component")
 
-	if logger.level != INFO {
-		t.Errorf("Expected default level INFO, got %v", logger.level)
+	if logger.Level() != INFO {
+		t.Errorf("Expected default level INFO, got %v", logger.Level())
 	}
 	if logger.format != JSON {
 		t.Errorf("Expected default format JSON, got %v", logger.format)
@@ -162,8 +168,8 @@ func TestLogger_WithFields(t *testing.T) {
 	}
 	
 	// Original logger should be unchanged
-	if len(logger.fields) != 0 {
-		t.Errorf("Original logger fields should be empty, got %v", logger.fields)
+	if _, ok := logger.fields["user_id"]; ok {
+		t.Errorf("Original logger fields should be unchanged, got %v", logger.fields)
 	}
 }
 
@@ -194,6 +200,38 @@ func TestLogger_WithError(t *testing.T) {
 	}
 }
 
+// codedError is an error implementing Coder
+type codedError struct{ code string }
+
+func (e *codedError) Error() string { return "rejected" }
+func (e *codedError) Code() string  { return e.code }
+
+func TestLogger_WithErrorFields(t *testing.T) {
+	logger := New(Config{Level: "INFO", Service: "test-service", Component: "test-component"})
+
+	err := fmt.Errorf("store batch: %w", &codedError{code: "QUOTA_EXCEEDED"})
+	newLogger := logger.WithError(err)
+
+	if newLogger.fields["error_type"] != "*fmt.wrapError" {
+		t.Errorf("Expected error_type *fmt.wrapError, got %v", newLogger.fields["error_type"])
+	}
+	if newLogger.fields["error_code"] != "QUOTA_EXCEEDED" {
+		t.Errorf("Expected the wrapped error's code, got %v", newLogger.fields["error_code"])
+	}
+	if len(newLogger.errorChain) != 2 || newLogger.errorChain[1].Type != "*logger.codedError" {
+		t.Errorf("Expected the chain of both errors, got %+v", newLogger.errorChain)
+	}
+
+	// A plain error has no code, and its chain would only repeat it
+	plain := logger.WithError(&testError{"test error"})
+	if _, exists := plain.fields["error_code"]; exists {
+		t.Errorf("Expected no error_code, got %v", plain.fields["error_code"])
+	}
+	if plain.errorChain != nil {
+		t.Errorf("Expected no chain for an error wrapping nothing, got %+v", plain.errorChain)
+	}
+}
+
 func TestLogger_WithDuration(t *testing.T) {
 	logger := NewFromEnv("test-service", "test-component")
 	
@@ -220,6 +258,33 @@ func TestLogger_WithComponent(t *testing.T) {
 	}
 }
 
+func TestLogger_WithName(t *testing.T) {
+	var buffer bytes.Buffer
+
+	logger := New(Config{Level: "INFO", Format: "JSON", Service: "test-service", Component: "handlers"})
+	logger.output = &buffer
+
+	ingest := logger.WithName("ingest")
+	ingest.WithName("batch").Info("named")
+	logger.Info("unnamed")
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 entries, got %v", lines)
+	}
+	var entry LogEntry
+	json.Unmarshal([]byte(lines[0]), &entry)
+	if entry.Logger != "ingest.batch" || entry.Component != "handlers" {
+		t.Errorf("Expected logger ingest.batch in component handlers, got %q in %q", entry.Logger, entry.Component)
+	}
+	if strings.Contains(lines[1], `"logger"`) {
+		t.Errorf("Expected no logger name on an unnamed logger, got %s", lines[1])
+	}
+	if ingest.name != "ingest" {
+		t.Errorf("Expected WithName to leave the parent unchanged, got %q", ingest.name)
+	}
+}
+
 func TestLogger_JSONOutput(t *testing.T) {
 	var buffer bytes.Buffer
 	
@@ -325,6 +390,33 @@ func TestLogger_LogLevels(t *testing.T) {
 	}
 }
 
+func TestLogger_ComponentLevels(t *testing.T) {
+	var buffer bytes.Buffer
+
+	logger := New(Config{
+		Level:     "INFO",
+		Format:    "JSON",
+		Service:   "test-service",
+		Component: "test-component",
+		Levels:    parseLevelList("database:debug, http:WARN,broken,cache:LOUD"),
+	})
+	logger.output = &buffer
+
+	logger.Debug("hidden default debug")
+	logger.WithComponent("database").Debug("database debug")
+	logger.WithComponent("http").WithField("k", "v").Info("hidden http info")
+	logger.WithComponent("http").Warn("http warn")
+	logger.WithComponent("cache").Debug("hidden cache debug")
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "database debug") || !strings.Contains(lines[1], "http warn") {
+		t.Errorf("Expected only the database debug and http warn entries, got %v", lines)
+	}
+	if logger.WithComponent("cache").Level() != INFO {
+		t.Errorf("Expected an unknown level name to be ignored")
+	}
+}
+
 func TestLogger_WithContext(t *testing.T) {
 	var buffer bytes.Buffer
 	
@@ -341,6 +433,7 @@ func TestLogger_WithContext(t *testing.T) {
 	ctx := context.Background()
 	ctx = WithTraceID(ctx, "trace-123")
 	ctx = WithUserID(ctx, "user-456")
+	ctx = WithTenantID(ctx, "tenant-1")
 	ctx = WithRequestID(ctx, "request-789")
 	
 	logger.InfoContext(ctx, "test message")
@@ -358,6 +451,9 @@ func TestLogger_WithContext(t *testing.T) {
 	if entry.UserID != "user-456" {
 		t.Errorf("Expected user_id 'user-456', got %v", entry.UserID)
 	}
+	if entry.TenantID != "tenant-1" {
+		t.Errorf("Expected tenant_id 'tenant-1', got %v", entry.TenantID)
+	}
 	if entry.RequestID != "request-789" {
 		t.Errorf("Expected request_id 'request-789', got %v", entry.RequestID)
 	}
@@ -450,6 +546,12 @@ func TestContextHelpers(t *testing.T) {
 		t.Errorf("Expected user ID 'user-456', got %v", got)
 	}
 	
+	// Test WithTenantID and GetTenantID
+	ctx = WithTenantID(ctx, "tenant-1")
+	if got := GetTenantID(ctx); got != "tenant-1" {
+		t.Errorf("Expected tenant ID 'tenant-1', got %v", got)
+	}
+	
 	// Test WithRequestID and GetRequestID
 	ctx = WithRequestID(ctx, "request-789")
 	if got := GetRequestID(ctx); got != "request-789" {
@@ -508,6 +610,25 @@ func TestGetCaller(t *testing.T) {
 	}
 }
 
+func TestLogger_DisableCaller(t *testing.T) {
+	for _, format := range []string{"JSON", "TEXT"} {
+		var buffer bytes.Buffer
+
+		logger := New(Config{Level: "INFO", Format: format, Service: "test-service", Component: "test", DisableCaller: true})
+		logger.output = &buffer
+
+		logger.Info("no caller")
+
+		output := buffer.String()
+		if strings.Contains(output, "logger_test.go") || strings.Contains(output, `"line"`) {
+			t.Errorf("%s: expected no caller information, got %v", format, output)
+		}
+		if !strings.Contains(output, "no caller") {
+			t.Errorf("%s: expected the message, got %v", format, output)
+		}
+	}
+}
+
 // Test helper types
 type testError struct {
 	message string
@@ -544,6 +665,16 @@ func BenchmarkLogger_InfoWithFields(b *testing.B) {
 	}
 }
 
+func BenchmarkLogger_InfoWithoutCaller(b *testing.B) {
+	logger := New(Config{Level: "INFO", Service: "bench-service", Component: "bench-component", DisableCaller: true})
+	logger.output = &bytes.Buffer{} // Discard output
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Info("benchmark message")
+	}
+}
+
 func BenchmarkLogger_JSONMarshal(b *testing.B) {
 	entry := LogEntry{
 		Timestamp: time.Now().UTC(),
@@ -0,0 +1,19 @@
+//go:build otel
+
+package logger
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func init() {
+	activeSpan = func(ctx context.Context) (string, string, bool) {
+		sc := trace.SpanContextFromContext(ctx)
+		if !sc.IsValid() {
+			return "", "", false
+		}
+		return sc.TraceID().String(), sc.SpanID().String(), true
+	}
+}
@@ -0,0 +1,44 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+type testSpanKey struct{}
+
+func TestLogger_ActiveSpanIDs(t *testing.T) {
+	// Stands in for the OpenTelemetry lookup of the otel build tag
+	defer func(saved func(context.Context) (string, string, bool)) { activeSpan = saved }(activeSpan)
+	activeSpan = func(ctx context.Context) (string, string, bool) {
+		if ctx.Value(testSpanKey{}) == nil {
+			return "", "", false
+		}
+		return "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", true
+	}
+
+	var buffer bytes.Buffer
+	logger := New(Config{Level: "INFO", Format: "JSON", Service: "test-service", Component: "test"})
+	logger.output = &buffer
+
+	ctx := WithTraceID(context.Background(), "request-trace")
+	logger.InfoContext(ctx, "without span")
+	logger.InfoContext(context.WithValue(ctx, testSpanKey{}, true), "with span")
+
+	lines := bytes.Split(bytes.TrimSpace(buffer.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 entries, got %q", buffer.String())
+	}
+
+	var entry LogEntry
+	json.Unmarshal(lines[0], &entry)
+	if entry.TraceID != "request-trace" || entry.SpanID != "" {
+		t.Errorf("Expected the context trace ID without a span, got %q/%q", entry.TraceID, entry.SpanID)
+	}
+	json.Unmarshal(lines[1], &entry)
+	if entry.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || entry.SpanID != "00f067aa0ba902b7" {
+		t.Errorf("Expected the span's IDs, got %q/%q", entry.TraceID, entry.SpanID)
+	}
+}
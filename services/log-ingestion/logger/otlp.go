@@ -0,0 +1,413 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Defaults of the OTLP exporter
+const (
+	DefaultOTLPBatchSize     = 512
+	DefaultOTLPFlushInterval = 5 * time.Second
+	otlpTimeout              = 10 * time.Second
+	otlpScope                = "log-processing-system/logger"
+)
+
+// OTLPConfig configures the export of entries to an OpenTelemetry collector
+// over OTLP/HTTP with JSON encoding
+type OTLPConfig struct {
+	// Endpoint is the collector's logs URL, e.g.
+	// http://otel-collector:4318/v1/logs; /v1/logs is added to a URL
+	// without a path
+	Endpoint string            `json:"endpoint"`
+	Headers  map[string]string `json:"headers"`
+	// BatchSize entries are sent together, and a partial batch after
+	// FlushInterval
+	BatchSize     int           `json:"batch_size"`
+	FlushInterval time.Duration `json:"flush_interval"`
+}
+
+// otlpExporter batches entries in the background and posts them to the
+// collector. Entries arriving while its queue is full are dropped, so an
+// unreachable collector never stalls logging.
+type otlpExporter struct {
+	url      string
+	headers  map[string]string
+	batch    int
+	interval time.Duration
+	client   *http.Client
+
+	records chan otlpQueued
+	flushes chan chan struct{}
+	dropped uint64
+}
+
+// otlpExporters holds one exporter per endpoint, shared by every logger
+// exporting there
+var otlpExporters struct {
+	sync.Mutex
+	byURL map[string]*otlpExporter
+}
+
+// sharedOTLPExporter returns the exporter for config's endpoint, starting
+// it on first use
+func sharedOTLPExporter(config OTLPConfig) *otlpExporter {
+	url := otlpLogsURL(config.Endpoint)
+
+	otlpExporters.Lock()
+	defer otlpExporters.Unlock()
+
+	if e, ok := otlpExporters.byURL[url]; ok {
+		return e
+	}
+	if otlpExporters.byURL == nil {
+		otlpExporters.byURL = make(map[string]*otlpExporter)
+	}
+	e := newOTLPExporter(url, config)
+	otlpExporters.byURL[url] = e
+	return e
+}
+
+func newOTLPExporter(url string, config OTLPConfig) *otlpExporter {
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultOTLPBatchSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = DefaultOTLPFlushInterval
+	}
+
+	e := &otlpExporter{
+		url:      url,
+		headers:  config.Headers,
+		batch:    config.BatchSize,
+		interval: config.FlushInterval,
+		client:   &http.Client{Timeout: otlpTimeout},
+		records:  make(chan otlpQueued, config.BatchSize*4),
+		flushes:  make(chan chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// otlpLogsURL adds the OTLP/HTTP logs path to an endpoint without a path
+func otlpLogsURL(endpoint string) string {
+	endpoint = strings.TrimRight(endpoint, "/")
+	if i := strings.Index(endpoint, "://"); i >= 0 && !strings.Contains(endpoint[i+3:], "/") {
+		return endpoint + "/v1/logs"
+	}
+	return endpoint
+}
+
+// otlpQueued is a converted entry waiting to be sent
+type otlpQueued struct {
+	service string
+	record  otlpLogRecord
+}
+
+// export queues an entry without waiting. It is converted right away, so
+// field values the caller changes later are not read concurrently.
+func (e *otlpExporter) export(entry LogEntry) {
+	select {
+	case e.records <- otlpQueued{service: entry.Service, record: otlpLogRecordOf(entry)}:
+	default:
+		atomic.AddUint64(&e.dropped, 1)
+	}
+}
+
+// Flush waits until the entries queued so far have been sent
+func (e *otlpExporter) Flush() {
+	done := make(chan struct{})
+	e.flushes <- done
+	<-done
+}
+
+func (e *otlpExporter) run() {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	pending := make([]otlpQueued, 0, e.batch)
+	send := func() {
+		if len(pending) > 0 {
+			e.send(pending)
+			pending = pending[:0]
+		}
+	}
+
+	for {
+		select {
+		case record := <-e.records:
+			pending = append(pending, record)
+			if len(pending) >= e.batch {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case done := <-e.flushes:
+			// Take what was queued before the flush was asked for
+			for n := len(e.records); n > 0; n-- {
+				pending = append(pending, <-e.records)
+				if len(pending) >= e.batch {
+					send()
+				}
+			}
+			send()
+			close(done)
+		}
+	}
+}
+
+// send posts a batch. Failures go to stderr: logging them through a
+// logger would export them again.
+func (e *otlpExporter) send(entries []otlpQueued) {
+	if dropped := atomic.SwapUint64(&e.dropped, 0); dropped > 0 {
+		fmt.Fprintf(os.Stderr, "otlp: dropped %d log entries: export queue full\n", dropped)
+	}
+
+	body, err := json.Marshal(otlpRequest(entries))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "otlp: failed to encode %d log entries: %v\n", len(entries), err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), otlpTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "otlp: invalid endpoint %q: %v\n", e.url, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range e.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "otlp: failed to export %d log entries: %v\n", len(entries), err)
+		return
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		fmt.Fprintf(os.Stderr, "otlp: collector rejected %d log entries with status %d\n", len(entries), resp.StatusCode)
+	}
+}
+
+// OTLP/JSON messages, as defined by the OpenTelemetry protocol's
+// ExportLogsServiceRequest
+type otlpExportRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScopeInfo   `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpScopeInfo struct {
+	Name string `json:"name"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano         string         `json:"timeUnixNano"`
+	ObservedTimeUnixNano string         `json:"observedTimeUnixNano"`
+	SeverityNumber       int            `json:"severityNumber"`
+	SeverityText         string         `json:"severityText"`
+	Body                 otlpAnyValue   `json:"body"`
+	Attributes           []otlpKeyValue `json:"attributes,omitempty"`
+	TraceID              string         `json:"traceId,omitempty"`
+	SpanID               string         `json:"spanId,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+// otlpRequest groups entries by service, the resource they come from
+func otlpRequest(entries []otlpQueued) otlpExportRequest {
+	var request otlpExportRequest
+	byService := make(map[string]int)
+
+	for _, entry := range entries {
+		i, ok := byService[entry.service]
+		if !ok {
+			i = len(request.ResourceLogs)
+			byService[entry.service] = i
+			request.ResourceLogs = append(request.ResourceLogs, otlpResourceLogs{
+				Resource:  otlpResource{Attributes: []otlpKeyValue{otlpString("service.name", entry.service)}},
+				ScopeLogs: []otlpScopeLogs{{Scope: otlpScopeInfo{Name: otlpScope}}},
+			})
+		}
+		scope := &request.ResourceLogs[i].ScopeLogs[0]
+		scope.LogRecords = append(scope.LogRecords, entry.record)
+	}
+	return request
+}
+
+// otlpLogRecordOf converts an entry, using OpenTelemetry attribute names for
+// the caller and error. IDs that are not valid W3C trace or span IDs are
+// kept as attributes.
+func otlpLogRecordOf(entry LogEntry) otlpLogRecord {
+	record := otlpLogRecord{
+		TimeUnixNano:         strconv.FormatInt(entry.Timestamp.UnixNano(), 10),
+		ObservedTimeUnixNano: strconv.FormatInt(time.Now().UnixNano(), 10),
+		SeverityNumber:       otlpSeverity(entry.Level),
+		SeverityText:         entry.Level,
+		Body:                 otlpAnyValue{StringValue: &entry.Message},
+	}
+
+	attrs := []otlpKeyValue{otlpString("component", entry.Component)}
+	if entry.Logger != "" {
+		attrs = append(attrs, otlpString("logger", entry.Logger))
+	}
+	if id, ok := otlpID(entry.TraceID, 16); ok {
+		record.TraceID = id
+	} else if entry.TraceID != "" {
+		attrs = append(attrs, otlpString("trace_id", entry.TraceID))
+	}
+	if id, ok := otlpID(entry.SpanID, 8); ok {
+		record.SpanID = id
+	} else if entry.SpanID != "" {
+		attrs = append(attrs, otlpString("span_id", entry.SpanID))
+	}
+
+	optional := []struct{ key, value string }{
+		{"request_id", entry.RequestID},
+		{"enduser.id", entry.UserID},
+		{"tenant.id", entry.TenantID},
+		{"code.filepath", entry.File},
+		{"code.function", entry.Function},
+		{"exception.message", entry.Error},
+	}
+	for _, attr := range optional {
+		if attr.value != "" {
+			attrs = append(attrs, otlpString(attr.key, attr.value))
+		}
+	}
+	if entry.Line > 0 {
+		attrs = append(attrs, otlpValue("code.lineno", entry.Line))
+	}
+	if len(entry.Stack) > 0 {
+		attrs = append(attrs, otlpString("exception.stacktrace", strings.Join(entry.Stack, "\n")))
+	}
+
+	keys := make([]string, 0, len(entry.Fields))
+	for k := range entry.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		attrs = append(attrs, otlpValue(k, entry.Fields[k]))
+	}
+
+	record.Attributes = attrs
+	return record
+}
+
+// otlpID returns id as the lowercase hex of size bytes that OTLP expects,
+// accepting UUID-style dashes
+func otlpID(id string, size int) (string, bool) {
+	id = strings.ToLower(strings.ReplaceAll(id, "-", ""))
+	if len(id) != size*2 || strings.Trim(id, "0") == "" {
+		return "", false
+	}
+	if _, err := hex.DecodeString(id); err != nil {
+		return "", false
+	}
+	return id, true
+}
+
+// otlpSeverity maps a level name to its OpenTelemetry severity number
+func otlpSeverity(level string) int {
+	switch level {
+	case "DEBUG":
+		return 5
+	case "INFO":
+		return 9
+	case "WARN":
+		return 13
+	case "ERROR":
+		return 17
+	case "FATAL":
+		return 21
+	default:
+		return 0
+	}
+}
+
+func otlpString(key, value string) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{StringValue: &value}}
+}
+
+// otlpValue converts a field value: booleans, integers and floats keep
+// their type, anything else is written as text
+func otlpValue(key string, value interface{}) otlpKeyValue {
+	var v otlpAnyValue
+	switch x := value.(type) {
+	case bool:
+		v.BoolValue = &x
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		s := fmt.Sprint(x)
+		v.IntValue = &s
+	case float32:
+		f := float64(x)
+		v.DoubleValue = &f
+	case float64:
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			// JSON has no NaN or infinities
+			s := fmt.Sprint(x)
+			v.StringValue = &s
+			break
+		}
+		v.DoubleValue = &x
+	default:
+		s := logfmtValue(value)
+		v.StringValue = &s
+	}
+	return otlpKeyValue{Key: key, Value: v}
+}
+
+// parseOTLPHeaders parses headers written as key=value pairs separated by
+// commas, as in OTEL_EXPORTER_OTLP_HEADERS
+func parseOTLPHeaders(value string) map[string]string {
+	headers := make(map[string]string)
+	for _, item := range strings.Split(value, ",") {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) == 2 && strings.TrimSpace(parts[0]) != "" {
+			headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+	return headers
+}
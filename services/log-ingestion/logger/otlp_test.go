@@ -0,0 +1,104 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestLogger_OTLPExport(t *testing.T) {
+	var mu sync.Mutex
+	var requests []otlpExportRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/logs" || r.Header.Get("Content-Type") != "application/json" || r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("Unexpected export request %s %s", r.URL.Path, r.Header)
+		}
+		var request otlpExportRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Failed to decode export request: %v", err)
+		}
+		mu.Lock()
+		requests = append(requests, request)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	logger := New(Config{
+		Level:     "INFO",
+		Service:   "test-service",
+		Component: "http",
+		OTLP:      OTLPConfig{Endpoint: server.URL, Headers: map[string]string{"Authorization": "Bearer secret"}},
+	})
+	logger.output = &bytes.Buffer{}
+
+	ctx := WithTraceID(context.Background(), "4bf92f35-77b3-4da6-a3ce-929d0e0e4736")
+	ctx = WithSpanID(ctx, "00F067AA0BA902B7")
+	logger.WithFields(map[string]interface{}{"status": 503, "retry": true, "ratio": 0.5}).ErrorContext(ctx, "upstream failed")
+	logger.InfoContext(WithTraceID(context.Background(), "not-a-trace-id"), "plain")
+	logger.Flush()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(requests) != 1 || len(requests[0].ResourceLogs) != 1 {
+		t.Fatalf("Expected one request with one resource, got %+v", requests)
+	}
+	resource := requests[0].ResourceLogs[0]
+	if name := resource.Resource.Attributes[0]; name.Key != "service.name" || *name.Value.StringValue != "test-service" {
+		t.Errorf("Expected the service as resource, got %+v", name)
+	}
+	records := resource.ScopeLogs[0].LogRecords
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+
+	record := records[0]
+	if record.SeverityNumber != 17 || record.SeverityText != "ERROR" || *record.Body.StringValue != "upstream failed" {
+		t.Errorf("Unexpected record %+v", record)
+	}
+	if record.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || record.SpanID != "00f067aa0ba902b7" {
+		t.Errorf("Expected trace correlation, got trace %q span %q", record.TraceID, record.SpanID)
+	}
+	attrs := make(map[string]otlpAnyValue)
+	for _, attr := range record.Attributes {
+		attrs[attr.Key] = attr.Value
+	}
+	if v := attrs["status"]; v.IntValue == nil || *v.IntValue != "503" {
+		t.Errorf("Expected an integer status, got %+v", v)
+	}
+	if v := attrs["retry"]; v.BoolValue == nil || !*v.BoolValue {
+		t.Errorf("Expected a boolean retry, got %+v", v)
+	}
+	if v := attrs["ratio"]; v.DoubleValue == nil || *v.DoubleValue != 0.5 {
+		t.Errorf("Expected a double ratio, got %+v", v)
+	}
+	if v := attrs["code.lineno"]; v.IntValue == nil {
+		t.Errorf("Expected the caller line, got %+v", record.Attributes)
+	}
+
+	// An ID OTLP cannot carry stays an attribute
+	if records[1].TraceID != "" || records[1].Attributes[1].Key != "trace_id" {
+		t.Errorf("Expected an invalid trace ID as attribute, got %+v", records[1])
+	}
+}
+
+func TestOTLPLogsURL(t *testing.T) {
+	tests := map[string]string{
+		"http://collector:4318":            "http://collector:4318/v1/logs",
+		"http://collector:4318/":           "http://collector:4318/v1/logs",
+		"https://collector/custom/v1/logs": "https://collector/custom/v1/logs",
+	}
+	for endpoint, expected := range tests {
+		if got := otlpLogsURL(endpoint); got != expected {
+			t.Errorf("otlpLogsURL(%q) = %q, want %q", endpoint, got, expected)
+		}
+	}
+
+	headers := parseOTLPHeaders("api-key=abc, x-tenant = acme,invalid")
+	if len(headers) != 2 || headers["api-key"] != "abc" || headers["x-tenant"] != "acme" {
+		t.Errorf("Unexpected headers %v", headers)
+	}
+}
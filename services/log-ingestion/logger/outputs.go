@@ -0,0 +1,167 @@
+package logger
+
+import (
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OutputConfig is one destination of a logger writing to several at once
+type OutputConfig struct {
+	// Output is stdout, stderr, a file path, tcp://host:port or
+	// udp://host:port for a network collector, or a syslog target: syslog
+	// for the local socket, syslog+udp://host:port or syslog+tcp://host:port
+	Output string `json:"output"`
+	Format string `json:"format"`
+	// Level is the minimum level written to this output, on top of the
+	// logger's own; empty writes every entry the logger lets through
+	Level string `json:"level"`
+}
+
+// destination is an output of a logger with several outputs
+type destination struct {
+	formatter
+	output io.Writer
+	level  LogLevel
+	async  *asyncWriter
+	syslog *syslogFramer
+}
+
+// newDestination opens an output, asynchronous when the logger's config asks
+func (l *Logger) newDestination(output OutputConfig, config Config) *destination {
+	d := &destination{level: DEBUG}
+	d.time = l.timestamps
+	if isSyslogOutput(output.Output) {
+		d.output, d.syslog = openSyslog(output.Output, config.SyslogFacility)
+	} else {
+		d.output = openOutput(output.Output)
+	}
+	d.format, d.color = resolveFormat(output.Format, d.output)
+	frameGELF(d.output, d.format)
+	if output.Level != "" {
+		d.level = parseLogLevel(strings.ToUpper(output.Level))
+	}
+
+	if config.Async {
+		notice := func(dropped uint64) []byte {
+			return d.line(l.droppedEntry(dropped))
+		}
+		d.async = newAsyncWriter(d.output, config.BufferSize, parseOverflowPolicy(config.Overflow), notice)
+		d.output = d.async
+	}
+	return d
+}
+
+// line formats an entry as written to the output: a line, or a syslog
+// message
+func (d *destination) line(entry LogEntry) []byte {
+	return d.appendLine(nil, entry)
+}
+
+// appendLine appends the line of an entry to b
+func (d *destination) appendLine(b []byte, entry LogEntry) []byte {
+	if d.syslog != nil {
+		return append(b, d.syslog.frame(entry, d.formatEntry(entry))...)
+	}
+	return append(d.appendEntry(b, entry), '\n')
+}
+
+// openOutput opens an output by name: stdout, stderr, a file path appended
+// to, or tcp://host:port or udp://host:port. A file that cannot be opened
+// falls back to stdout.
+func openOutput(name string) io.Writer {
+	switch {
+	case name == "" || name == "stdout":
+		return os.Stdout
+	case name == "stderr":
+		return os.Stderr
+	case strings.HasPrefix(name, "tcp://"), strings.HasPrefix(name, "udp://"):
+		return &netWriter{network: name[:3], address: name[len("tcp://"):]}
+	}
+
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return os.Stdout
+	}
+	return file
+}
+
+// resolveFormat parses a format for an output, where AUTO picks the console
+// format for a terminal and JSON otherwise, and reports whether the output
+// takes colors
+func resolveFormat(name string, output io.Writer) (LogFormat, bool) {
+	format := parseLogFormat(name)
+	if strings.EqualFold(name, "AUTO") && isTerminal(output) {
+		format = CONSOLE
+	}
+	return format, format == CONSOLE && useColor(output)
+}
+
+// Timeouts of network outputs, so an unreachable collector delays a log
+// call by a bounded time
+const (
+	netDialTimeout  = 5 * time.Second
+	netWriteTimeout = 5 * time.Second
+)
+
+// netWriter writes lines to a TCP or UDP collector, connecting on first use
+// and reconnecting on the next write after a failure
+type netWriter struct {
+	network string
+	address string
+
+	// nullDelimited ends messages with a NUL byte instead of a newline, as
+	// GELF over TCP requires
+	nullDelimited bool
+
+	mu     sync.Mutex
+	conn   net.Conn
+	closed bool
+	buf    []byte
+}
+
+func (w *netWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, net.ErrClosed
+	}
+	if w.conn == nil {
+		conn, err := net.DialTimeout(w.network, w.address, netDialTimeout)
+		if err != nil {
+			return 0, err
+		}
+		w.conn = conn
+	}
+
+	if w.nullDelimited && len(p) > 0 && p[len(p)-1] == '\n' {
+		w.buf = append(append(w.buf[:0], p[:len(p)-1]...), 0)
+		p = w.buf
+	}
+
+	w.conn.SetWriteDeadline(time.Now().Add(netWriteTimeout))
+	n, err := w.conn.Write(p)
+	if err != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+	return n, err
+}
+
+// Close closes the connection; later writes fail instead of reconnecting
+func (w *netWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.closed = true
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
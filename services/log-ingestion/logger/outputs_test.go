@@ -0,0 +1,119 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogger_Outputs(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	received := make(chan string, 10)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			received <- scanner.Text()
+		}
+	}()
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	logger := New(Config{
+		Level:     "DEBUG",
+		Service:   "test-service",
+		Component: "test",
+		Outputs: []OutputConfig{
+			{Output: path, Format: "JSON"},
+			{Output: "tcp://" + listener.Addr().String(), Format: "LOGFMT", Level: "warn"},
+		},
+	})
+
+	logger.Debug("debug message")
+	logger.Warn("warn message")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected both entries in the file, got %q", data)
+	}
+	var entry LogEntry
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil || entry.Message != "debug message" {
+		t.Errorf("Expected a JSON debug entry, got %q (%v)", lines[0], err)
+	}
+
+	select {
+	case line := <-received:
+		if !strings.Contains(line, `level=WARN message="warn message"`) {
+			t.Errorf("Expected the warning in logfmt, got %q", line)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the warning on the network output")
+	}
+	select {
+	case line := <-received:
+		t.Errorf("Expected only the warning on the network output, also got %q", line)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestNetWriter_Reconnects(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	w := &netWriter{network: "tcp", address: address}
+	if _, err := w.Write([]byte("lost\n")); err == nil {
+		t.Fatal("Expected an error without a collector")
+	}
+
+	listener, err = net.Listen("tcp", address)
+	if err != nil {
+		t.Skipf("Cannot listen on %s again: %v", address, err)
+	}
+	defer listener.Close()
+
+	if _, err := w.Write([]byte("delivered\n")); err != nil {
+		t.Fatalf("Expected the writer to connect once the collector is up: %v", err)
+	}
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != "delivered\n" {
+		t.Errorf("Expected the line after reconnecting, got %q (%v)", line, err)
+	}
+}
+
+func TestOpenOutput(t *testing.T) {
+	if openOutput("") != os.Stdout || openOutput("stdout") != os.Stdout {
+		t.Error("Expected stdout by default")
+	}
+	if openOutput("stderr") != os.Stderr {
+		t.Error("Expected stderr")
+	}
+	if w, ok := openOutput("udp://127.0.0.1:514").(*netWriter); !ok || w.network != "udp" || w.address != "127.0.0.1:514" {
+		t.Errorf("Expected a UDP writer, got %#v", w)
+	}
+}
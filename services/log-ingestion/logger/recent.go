@@ -0,0 +1,56 @@
+package logger
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// recentLog keeps the last entries written by every logger of the process,
+// whatever their outputs, for crash reports to show what led up to a crash
+type recentLog struct {
+	mu      sync.Mutex
+	entries []LogEntry
+	next    int
+	full    bool
+}
+
+// recent holds the *recentLog in use, nil while none is kept
+var recent atomic.Value
+
+// KeepRecent keeps the last n entries written by any logger, from now on;
+// 0 stops keeping them
+func KeepRecent(n int) {
+	if n <= 0 {
+		recent.Store((*recentLog)(nil))
+		return
+	}
+	recent.Store(&recentLog{entries: make([]LogEntry, n)})
+}
+
+// Recent returns the entries KeepRecent keeps, oldest first
+func Recent() []LogEntry {
+	r, _ := recent.Load().(*recentLog)
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]LogEntry(nil), r.entries[:r.next]...)
+	}
+	return append(append([]LogEntry(nil), r.entries[r.next:]...), r.entries[:r.next]...)
+}
+
+// keepRecent records a written entry when recent entries are kept
+func keepRecent(entry LogEntry) {
+	r, _ := recent.Load().(*recentLog)
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.entries[r.next] = entry
+	if r.next++; r.next == len(r.entries) {
+		r.next, r.full = 0, true
+	}
+	r.mu.Unlock()
+}
@@ -0,0 +1,36 @@
+package logger
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestKeepRecent(t *testing.T) {
+	KeepRecent(3)
+	defer KeepRecent(0)
+
+	first := New(Config{Level: "INFO", Service: "test-service", Component: "a"})
+	second := New(Config{Level: "INFO", Service: "test-service", Component: "b"})
+	var buffer bytes.Buffer
+	first.output, second.output = &buffer, &buffer
+
+	first.Info("one")
+	second.Debug("filtered out")
+	second.Info("two")
+	if recent := Recent(); len(recent) != 2 || recent[0].Message != "one" || recent[1].Component != "b" {
+		t.Errorf("Expected the entries written so far, got %+v", recent)
+	}
+
+	first.Info("three")
+	second.Warn("four")
+	recent := Recent()
+	if len(recent) != 3 || recent[0].Message != "two" || recent[2].Message != "four" {
+		t.Errorf("Expected the last 3 entries of every logger, oldest first, got %+v", recent)
+	}
+
+	KeepRecent(0)
+	first.Info("five")
+	if recent := Recent(); recent != nil {
+		t.Errorf("Expected nothing kept once stopped, got %+v", recent)
+	}
+}
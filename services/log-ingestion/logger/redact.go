@@ -0,0 +1,121 @@
+package logger
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// DefaultRedactFields are the field names masked when no others are given
+var DefaultRedactFields = []string{"password", "passwd", "secret", "token", "authorization", "api_key", "apikey", "cookie", "credential"}
+
+// RedactedValue replaces the value of a sensitive field
+const RedactedValue = "[REDACTED]"
+
+// redactor masks fields whose names contain a denied name, so "token" also
+// covers "access_token" and "X-Auth-Token"
+type redactor struct {
+	names []string
+}
+
+// newRedactor returns a redactor for the names, or nil when there are none
+func newRedactor(names []string) *redactor {
+	r := &redactor{}
+	for _, name := range names {
+		if name = normalizeFieldName(name); name != "" {
+			r.names = append(r.names, name)
+		}
+	}
+	if len(r.names) == 0 {
+		return nil
+	}
+	return r
+}
+
+// normalizeFieldName lowercases a name and treats dashes as underscores
+func normalizeFieldName(name string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), "-", "_")
+}
+
+func (r *redactor) sensitive(key string) bool {
+	key = normalizeFieldName(key)
+	for _, name := range r.names {
+		if strings.Contains(key, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactFields masks the sensitive fields of a logger's own fields map in
+// place; nested maps and slices are copied rather than changed, since they
+// may belong to the caller
+func (r *redactor) redactFields(fields map[string]interface{}) {
+	for k, v := range fields {
+		fields[k] = r.redact(k, v)
+	}
+}
+
+// redact returns the value logged for a field
+func (r *redactor) redact(key string, value interface{}) interface{} {
+	if r.sensitive(key) {
+		return RedactedValue
+	}
+	return r.redactValue(value)
+}
+
+// redactValue returns value with the sensitive keys of nested maps masked
+func (r *redactor) redactValue(value interface{}) interface{} {
+	switch value.(type) {
+	case nil, string, bool, int, int64, float64, error, time.Time, time.Duration, []byte:
+		return value
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return value
+		}
+		redacted := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			if r.sensitive(key) {
+				redacted[key] = RedactedValue
+				continue
+			}
+			redacted[key] = r.redactValue(iter.Value().Interface())
+		}
+		return redacted
+	case reflect.Slice, reflect.Array:
+		if !mayHoldMaps(rv.Type().Elem()) {
+			return value
+		}
+		redacted := make([]interface{}, rv.Len())
+		for i := range redacted {
+			redacted[i] = r.redactValue(rv.Index(i).Interface())
+		}
+		return redacted
+	case reflect.Ptr:
+		if rv.IsNil() || !mayHoldMaps(rv.Type().Elem()) {
+			return value
+		}
+		return r.redactValue(rv.Elem().Interface())
+	default:
+		return value
+	}
+}
+
+// mayHoldMaps reports whether values of type t can contain maps, so slices
+// of plain values are not copied
+func mayHoldMaps(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Map, reflect.Interface:
+		return true
+	case reflect.Slice, reflect.Array, reflect.Ptr:
+		return mayHoldMaps(t.Elem())
+	default:
+		return false
+	}
+}
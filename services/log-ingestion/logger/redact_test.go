@@ -0,0 +1,75 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestLogger_RedactsSensitiveFields(t *testing.T) {
+	var buffer bytes.Buffer
+
+	logger := New(Config{Level: "INFO", Format: "JSON", Service: "test-service", Component: "test"})
+	logger.output = &buffer
+
+	headers := map[string]string{"Authorization": "Bearer abc", "Accept": "application/json"}
+	logger.WithFields(map[string]interface{}{
+		"user":         "alice",
+		"password":     "hunter2",
+		"access_token": "xyz",
+		"headers":      headers,
+		"requests":     []map[string]interface{}{{"X-Api-Key": "k1", "path": "/logs"}},
+		"ids":          []int{1, 2},
+	}).Info("login")
+
+	var entry LogEntry
+	if err := json.Unmarshal(buffer.Bytes(), &entry); err != nil {
+		t.Fatalf("Failed to parse entry: %v", err)
+	}
+
+	if entry.Fields["user"] != "alice" {
+		t.Errorf("Expected user to be kept, got %v", entry.Fields["user"])
+	}
+	for _, key := range []string{"password", "access_token"} {
+		if entry.Fields[key] != RedactedValue {
+			t.Errorf("Expected %s to be redacted, got %v", key, entry.Fields[key])
+		}
+	}
+	nested := entry.Fields["headers"].(map[string]interface{})
+	if nested["Authorization"] != RedactedValue || nested["Accept"] != "application/json" {
+		t.Errorf("Expected only the nested Authorization to be redacted, got %v", nested)
+	}
+	inSlice := entry.Fields["requests"].([]interface{})[0].(map[string]interface{})
+	if inSlice["X-Api-Key"] != RedactedValue || inSlice["path"] != "/logs" {
+		t.Errorf("Expected the key inside the slice to be redacted, got %v", inSlice)
+	}
+
+	// The caller's map is left alone
+	if headers["Authorization"] != "Bearer abc" {
+		t.Errorf("Expected the caller's map to be unchanged, got %v", headers)
+	}
+}
+
+func TestLogger_RedactionConfig(t *testing.T) {
+	var buffer bytes.Buffer
+
+	logger := New(Config{Level: "INFO", Format: "JSON", Service: "test-service", Component: "test", RedactFields: []string{"SSN"}})
+	logger.output = &buffer
+	logger.WithFields(map[string]interface{}{"customer_ssn": "123", "password": "kept"}).Info("custom list")
+
+	var entry LogEntry
+	json.Unmarshal(buffer.Bytes(), &entry)
+	if entry.Fields["customer_ssn"] != RedactedValue || entry.Fields["password"] != "kept" {
+		t.Errorf("Expected only the configured names to be redacted, got %v", entry.Fields)
+	}
+
+	buffer.Reset()
+	logger = New(Config{Level: "INFO", Format: "JSON", Service: "test-service", Component: "test", DisableRedaction: true})
+	logger.output = &buffer
+	logger.WithField("password", "visible").Info("disabled")
+
+	json.Unmarshal(buffer.Bytes(), &entry)
+	if entry.Fields["password"] != "visible" {
+		t.Errorf("Expected no redaction when disabled, got %v", entry.Fields)
+	}
+}
@@ -0,0 +1,127 @@
+package logger
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// SamplingRule keeps the first Initial entries with the same level and
+// message in every sampling tick, then every Thereafter-th one; a zero
+// Thereafter drops the rest of the tick
+type SamplingRule struct {
+	Initial    int `json:"initial"`
+	Thereafter int `json:"thereafter"`
+}
+
+// DefaultSamplingTick is the sampling period when none is given
+const DefaultSamplingTick = time.Second
+
+// samplerBuckets is the number of counters per level; messages sharing a
+// bucket share their budget, which bounds memory for any message variety
+const samplerBuckets = 4096
+
+type samplerCounter struct {
+	resetAt int64
+	count   uint64
+}
+
+// incr counts an entry, starting a new tick when the current one is over.
+// Racing resets can let a few extra entries through, which is acceptable
+// for sampling and keeps the hot path lock-free.
+func (c *samplerCounter) incr(now int64, tick time.Duration) uint64 {
+	if atomic.LoadInt64(&c.resetAt) > now {
+		return atomic.AddUint64(&c.count, 1)
+	}
+	atomic.StoreUint64(&c.count, 1)
+	atomic.StoreInt64(&c.resetAt, now+tick.Nanoseconds())
+	return 1
+}
+
+type levelSampler struct {
+	rule     SamplingRule
+	counters [samplerBuckets]samplerCounter
+}
+
+// sampler thins out repeated entries per level, shared by a logger and the
+// loggers derived from it
+type sampler struct {
+	tick   time.Duration
+	levels [FATAL + 1]*levelSampler
+	now    func() time.Time
+}
+
+// newSampler returns a sampler for the rules keyed by level name, or nil
+// when no rule applies. FATAL entries are never sampled.
+func newSampler(rules map[string]SamplingRule, tick time.Duration) *sampler {
+	if tick <= 0 {
+		tick = DefaultSamplingTick
+	}
+	s := &sampler{tick: tick, now: time.Now}
+
+	enabled := false
+	for name, rule := range rules {
+		level, ok := lookupLevel(name)
+		if !ok || level == FATAL || rule.Initial < 0 || rule.Thereafter < 0 {
+			continue
+		}
+		s.levels[level] = &levelSampler{rule: rule}
+		enabled = true
+	}
+	if !enabled {
+		return nil
+	}
+	return s
+}
+
+// allow reports whether an entry is kept
+func (s *sampler) allow(level LogLevel, message string) bool {
+	if level < 0 || int(level) >= len(s.levels) || s.levels[level] == nil {
+		return true
+	}
+	ls := s.levels[level]
+
+	n := ls.counters[hashMessage(message)%samplerBuckets].incr(s.now().UnixNano(), s.tick)
+
+	if n <= uint64(ls.rule.Initial) {
+		return true
+	}
+	if ls.rule.Thereafter == 0 {
+		return false
+	}
+	return (n-uint64(ls.rule.Initial))%uint64(ls.rule.Thereafter) == 0
+}
+
+// hashMessage is 32-bit FNV-1a, inlined to keep the hot path allocation-free
+func hashMessage(message string) uint32 {
+	h := uint32(2166136261)
+	for i := 0; i < len(message); i++ {
+		h ^= uint32(message[i])
+		h *= 16777619
+	}
+	return h
+}
+
+// parseSampling parses rules written as level:initial:thereafter pairs
+// separated by commas, e.g. "DEBUG:10:100,INFO:100:10"; malformed rules are
+// skipped
+func parseSampling(value string) map[string]SamplingRule {
+	rules := make(map[string]SamplingRule)
+	for _, item := range strings.Split(value, ",") {
+		parts := strings.Split(strings.TrimSpace(item), ":")
+		if len(parts) != 3 {
+			continue
+		}
+		initial, err := strconv.Atoi(parts[1])
+		if err != nil {
+			continue
+		}
+		thereafter, err := strconv.Atoi(parts[2])
+		if err != nil {
+			continue
+		}
+		rules[strings.ToUpper(parts[0])] = SamplingRule{Initial: initial, Thereafter: thereafter}
+	}
+	return rules
+}
@@ -0,0 +1,84 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSampler_FirstThenEveryNth(t *testing.T) {
+	s := newSampler(map[string]SamplingRule{"debug": {Initial: 3, Thereafter: 5}}, time.Second)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	kept := 0
+	for i := 0; i < 23; i++ {
+		if s.allow(DEBUG, "cache miss") {
+			kept++
+		}
+	}
+	// The first 3, then the 8th, 13th, 18th and 23rd
+	if kept != 7 {
+		t.Errorf("Expected 7 entries kept, got %d", kept)
+	}
+
+	if !s.allow(DEBUG, "another message") {
+		t.Errorf("Expected a different message to have its own budget")
+	}
+	if !s.allow(INFO, "cache miss") {
+		t.Errorf("Expected levels without a rule not to be sampled")
+	}
+
+	now = now.Add(time.Second)
+	if !s.allow(DEBUG, "cache miss") {
+		t.Errorf("Expected the budget to reset after a tick")
+	}
+}
+
+func TestSampler_ZeroThereafterDropsRest(t *testing.T) {
+	s := newSampler(map[string]SamplingRule{"INFO": {Initial: 2}}, time.Minute)
+
+	kept := 0
+	for i := 0; i < 10; i++ {
+		if s.allow(INFO, "polling") {
+			kept++
+		}
+	}
+	if kept != 2 {
+		t.Errorf("Expected 2 entries kept, got %d", kept)
+	}
+}
+
+func TestNewSampler_IgnoresInvalidRules(t *testing.T) {
+	if s := newSampler(map[string]SamplingRule{"TRACE": {Initial: 1}, "FATAL": {Initial: 1}}, 0); s != nil {
+		t.Errorf("Expected no sampler for unknown levels and FATAL")
+	}
+	if s := newSampler(nil, 0); s != nil {
+		t.Errorf("Expected no sampler without rules")
+	}
+}
+
+func TestParseSampling(t *testing.T) {
+	rules := parseSampling("DEBUG:10:100, info:100:10,broken,WARN:x:1")
+	if len(rules) != 2 || rules["DEBUG"] != (SamplingRule{10, 100}) || rules["INFO"] != (SamplingRule{100, 10}) {
+		t.Errorf("Unexpected rules: %+v", rules)
+	}
+}
+
+func TestLogger_Sampling(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(Config{Level: "DEBUG", Service: "test-service", Component: "test",
+		Sampling: map[string]SamplingRule{"DEBUG": {Initial: 2, Thereafter: 0}}, SamplingTick: time.Minute})
+	logger.output = &buf
+
+	for i := 0; i < 5; i++ {
+		logger.WithField("i", i).Debug("hot path")
+		logger.Info("kept")
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 7 {
+		t.Errorf("Expected 2 debug and 5 info entries, got %d", len(lines))
+	}
+}
@@ -0,0 +1,54 @@
+package logger
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// SelfSourceField is the field NewFromEnv loggers mark their entries with,
+// naming the service that wrote them. When those entries are shipped into
+// the ingestion service, it recognises them as its own, or those of its
+// sibling services, and handles them without logging about them, which
+// would feed back into itself.
+const SelfSourceField = "self_source"
+
+// SelfSourceHeader marks a request that ships the system's own logs, so the
+// request is not logged either, before its body has been read
+const SelfSourceHeader = "X-Self-Source"
+
+type selfLogKey struct{}
+
+// selfLogMark is shared by every context of a request, so marking it deep
+// in a handler also silences what is logged after the handler returns
+type selfLogMark struct {
+	marked int32
+}
+
+// WithSelfLogMark prepares the context of a request for MarkSelfLog
+func WithSelfLogMark(ctx context.Context) context.Context {
+	return context.WithValue(ctx, selfLogKey{}, &selfLogMark{})
+}
+
+// MarkSelfLog marks ctx as the handling of the system's own logs: entries
+// logged with it, below ERROR, are dropped. The whole request is marked if
+// ctx was prepared with WithSelfLogMark; otherwise the returned context is.
+func MarkSelfLog(ctx context.Context) context.Context {
+	if mark, ok := ctx.Value(selfLogKey{}).(*selfLogMark); ok {
+		atomic.StoreInt32(&mark.marked, 1)
+		return ctx
+	}
+	return context.WithValue(ctx, selfLogKey{}, &selfLogMark{marked: 1})
+}
+
+// IsSelfLog reports whether ctx was marked by MarkSelfLog
+func IsSelfLog(ctx context.Context) bool {
+	mark, ok := ctx.Value(selfLogKey{}).(*selfLogMark)
+	return ok && atomic.LoadInt32(&mark.marked) == 1
+}
+
+// IsSelfSource reports whether the fields of an entry carry the
+// self_source marker
+func IsSelfSource(fields map[string]interface{}) bool {
+	source, ok := fields[SelfSourceField].(string)
+	return ok && source != ""
+}
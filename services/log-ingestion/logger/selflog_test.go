@@ -0,0 +1,76 @@
+package logger
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestNewFromEnv_SelfSource(t *testing.T) {
+	if source := NewFromEnv("log-ingestion", "handlers").fields[SelfSourceField]; source != "log-ingestion" {
+		t.Errorf("Expected entries marked with the service, got %v", source)
+	}
+
+	os.Setenv("LOG_SELF_SOURCE", "false")
+	defer os.Unsetenv("LOG_SELF_SOURCE")
+	if _, ok := NewFromEnv("log-ingestion", "handlers").fields[SelfSourceField]; ok {
+		t.Error("Expected no self_source with LOG_SELF_SOURCE=false")
+	}
+}
+
+func TestMarkSelfLog(t *testing.T) {
+	sink := &recordingSink{}
+	logger := New(Config{Level: "DEBUG", Service: "test-service", Component: "test", Sink: sink})
+
+	// Marked deep in a handler, the request stays marked for the
+	// middleware that prepared it
+	request := WithSelfLogMark(context.Background())
+	if IsSelfLog(request) {
+		t.Fatal("Expected a prepared context not to be marked yet")
+	}
+	handler := WithRequestID(request, "req-1")
+	if ctx := MarkSelfLog(handler); ctx != handler {
+		t.Error("Expected a prepared context to be marked in place")
+	}
+	if !IsSelfLog(request) {
+		t.Fatal("Expected the whole request to be marked")
+	}
+
+	logger.InfoContext(request, "HTTP request completed")
+	logger.WarnContext(handler, "slow")
+	logger.ErrorContext(handler, "failed")
+	logger.Info("not a request")
+	logger.Flush()
+
+	var messages []string
+	for _, entry := range sink.entries {
+		messages = append(messages, entry.Message)
+	}
+	if len(messages) != 2 || messages[0] != "failed" || messages[1] != "not a request" {
+		t.Errorf("Expected only errors logged for a self log, got %v", messages)
+	}
+
+	// Without a prepared request, only the returned context is marked
+	plain := context.Background()
+	if ctx := MarkSelfLog(plain); !IsSelfLog(ctx) || IsSelfLog(plain) {
+		t.Error("Expected only the returned context to be marked")
+	}
+}
+
+func TestIsSelfSource(t *testing.T) {
+	tests := []struct {
+		fields map[string]interface{}
+		want   bool
+	}{
+		{map[string]interface{}{SelfSourceField: "log-processor"}, true},
+		{map[string]interface{}{SelfSourceField: ""}, false},
+		{map[string]interface{}{SelfSourceField: true}, false},
+		{map[string]interface{}{"source": "api"}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsSelfSource(tt.fields); got != tt.want {
+			t.Errorf("IsSelfSource(%v) = %v, want %v", tt.fields, got, tt.want)
+		}
+	}
+}
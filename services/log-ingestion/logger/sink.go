@@ -0,0 +1,72 @@
+package logger
+
+import (
+	"sort"
+)
+
+// Sink writes the entries of a logger in place of its own formatting and
+// outputs, so another logging engine such as zap or zerolog can encode
+// them. The logger still filters levels, samples, redacts and adds the
+// caller, IDs and fields before an entry reaches the sink.
+type Sink interface {
+	// Write writes an entry; it is called from concurrent goroutines. The
+	// entry's Fields may be shared with the logger and must not be changed.
+	Write(entry LogEntry) error
+	// Sync writes out entries the sink buffers; Flush and Close call it
+	Sync() error
+}
+
+// sinkField is a named value of an entry written by a sink
+type sinkField struct {
+	key   string
+	value interface{}
+}
+
+// sinkFields returns the attributes of an entry besides its timestamp,
+// level, message and caller, which sinks write in their own way, with the
+// entry's fields in key order
+func sinkFields(entry LogEntry) []sinkField {
+	fields := []sinkField{
+		{"service", entry.Service},
+		{"component", entry.Component},
+	}
+	if entry.Logger != "" {
+		fields = append(fields, sinkField{"logger", entry.Logger})
+	}
+
+	optional := []struct{ key, value string }{
+		{"trace_id", entry.TraceID},
+		{"span_id", entry.SpanID},
+		{"user_id", entry.UserID},
+		{"tenant_id", entry.TenantID},
+		{"request_id", entry.RequestID},
+		{"error", entry.Error},
+	}
+	for _, field := range optional {
+		if field.value != "" {
+			fields = append(fields, sinkField{field.key, field.value})
+		}
+	}
+	if entry.Duration != nil {
+		fields = append(fields, sinkField{"duration", entry.Duration.String()})
+	}
+	if len(entry.ErrorChain) > 0 {
+		fields = append(fields, sinkField{"error_chain", entry.ErrorChain})
+	}
+	if len(entry.Stack) > 0 {
+		fields = append(fields, sinkField{"stack", entry.Stack})
+	}
+	if len(entry.Tags) > 0 {
+		fields = append(fields, sinkField{"tags", entry.Tags})
+	}
+
+	keys := make([]string, 0, len(entry.Fields))
+	for k := range entry.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fields = append(fields, sinkField{k, entry.Fields[k]})
+	}
+	return fields
+}
@@ -0,0 +1,71 @@
+package logger
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// recordingSink keeps the entries written to it
+type recordingSink struct {
+	mu      sync.Mutex
+	entries []LogEntry
+	syncs   int
+}
+
+func (s *recordingSink) Write(entry LogEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func (s *recordingSink) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.syncs++
+	return nil
+}
+
+func TestLogger_Sink(t *testing.T) {
+	sink := &recordingSink{}
+	logger := New(Config{Level: "INFO", Service: "test-service", Component: "test", Sink: sink})
+
+	logger.Debug("filtered")
+	logger.WithField("password", "secret").WithError(errors.New("boom")).Error("failed")
+	logger.Flush()
+
+	if len(sink.entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(sink.entries))
+	}
+	entry := sink.entries[0]
+	if entry.Message != "failed" || entry.Level != "ERROR" || entry.Fields["error"] != "boom" {
+		t.Errorf("Unexpected entry %+v", entry)
+	}
+	if entry.Fields["password"] != RedactedValue {
+		t.Errorf("Expected the logger to redact before the sink, got %v", entry.Fields["password"])
+	}
+	if sink.syncs != 1 {
+		t.Errorf("Expected Flush to sync the sink once, got %d", sink.syncs)
+	}
+}
+
+func TestSinkFields(t *testing.T) {
+	entry := LogEntry{
+		Service:   "svc",
+		Component: "comp",
+		TraceID:   "trace-1",
+		Fields:    map[string]interface{}{"b": 2, "a": "1"},
+	}
+	expected := []sinkField{
+		{"service", "svc"},
+		{"component", "comp"},
+		{"trace_id", "trace-1"},
+		{"a", "1"},
+		{"b", 2},
+	}
+	if fields := sinkFields(entry); !reflect.DeepEqual(fields, expected) {
+		t.Errorf("Expected %v, got %v", expected, fields)
+	}
+}
@@ -0,0 +1,202 @@
+//go:build go1.21
+
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"sort"
+)
+
+// slogHandler is a slog.Handler writing records through a Logger
+type slogHandler struct {
+	logger *Logger
+	group  string
+}
+
+// NewSlogHandler returns a slog.Handler that writes records through l, so
+// libraries logging with log/slog share its format, output, fields and
+// context extraction. Attributes become fields, with group names joined to
+// their keys by dots.
+func NewSlogHandler(l *Logger) slog.Handler {
+	return &slogHandler{logger: l}
+}
+
+func (h *slogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return fromSlogLevel(level) >= h.logger.Level()
+}
+
+func (h *slogHandler) Handle(ctx context.Context, r slog.Record) error {
+	if !h.logger.enabled(fromSlogLevel(r.Level), r.Message) {
+		return nil
+	}
+
+	fields := make(map[string]interface{}, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		addSlogAttr(fields, h.group, a)
+		return true
+	})
+
+	entry := h.logger.newEntry(ctx, fromSlogLevel(r.Level), r.Message, fields)
+	if !r.Time.IsZero() {
+		entry.Timestamp = r.Time.UTC()
+	}
+	if r.PC != 0 && !h.logger.noCaller {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		entry.File = filepath.Base(frame.File)
+		entry.Line = frame.Line
+		entry.Function = filepath.Base(frame.Function)
+	}
+
+	h.logger.writeEntry(entry)
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := make(map[string]interface{}, len(attrs))
+	for _, a := range attrs {
+		addSlogAttr(fields, h.group, a)
+	}
+	return &slogHandler{logger: h.logger.WithFields(fields), group: h.group}
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &slogHandler{logger: h.logger, group: h.group + name + "."}
+}
+
+// addSlogAttr adds an attribute to fields, flattening groups into dotted keys
+func addSlogAttr(fields map[string]interface{}, prefix string, a slog.Attr) {
+	value := a.Value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		group := prefix
+		// An inline group's attributes belong to the enclosing group
+		if a.Key != "" {
+			group += a.Key + "."
+		}
+		for _, attr := range value.Group() {
+			addSlogAttr(fields, group, attr)
+		}
+		return
+	}
+	if a.Key == "" {
+		return
+	}
+
+	key := prefix + a.Key
+	switch value.Kind() {
+	case slog.KindDuration:
+		// Durations are written as strings, as by WithDuration
+		fields[key] = value.Duration().String()
+	default:
+		// Errors would otherwise marshal to an empty JSON object
+		if err, ok := value.Any().(error); ok {
+			fields[key] = err.Error()
+			return
+		}
+		fields[key] = value.Any()
+	}
+}
+
+// NewSlogLogger returns a Logger that hands its entries to h instead of
+// formatting them itself, so code using Logger can run in a program whose
+// logging is built on log/slog. Whether an entry is written is decided by
+// h; service, component, IDs, caller and fields become attributes.
+func NewSlogLogger(h slog.Handler, service, component string) *Logger {
+	return New(Config{Level: "DEBUG", Service: service, Component: component, Sink: slogSink{h}})
+}
+
+// slogSink is a Sink handing entries to a slog.Handler
+type slogSink struct {
+	handler slog.Handler
+}
+
+func (s slogSink) Write(entry LogEntry) error {
+	level := toSlogLevel(parseLogLevel(entry.Level))
+	if !s.handler.Enabled(context.Background(), level) {
+		return nil
+	}
+	return s.handler.Handle(context.Background(), toSlogRecord(entry, level))
+}
+
+// Sync does nothing; slog handlers have no flush method
+func (s slogSink) Sync() error {
+	return nil
+}
+
+// toSlogRecord converts an entry to a record with its fields in key order
+func toSlogRecord(entry LogEntry, level slog.Level) slog.Record {
+	r := slog.NewRecord(entry.Timestamp, level, entry.Message, 0)
+	r.AddAttrs(slog.String("service", entry.Service), slog.String("component", entry.Component))
+	if entry.Logger != "" {
+		r.AddAttrs(slog.String("logger", entry.Logger))
+	}
+
+	optional := []struct{ key, value string }{
+		{"trace_id", entry.TraceID},
+		{"span_id", entry.SpanID},
+		{"user_id", entry.UserID},
+		{"tenant_id", entry.TenantID},
+		{"request_id", entry.RequestID},
+		{"error", entry.Error},
+	}
+	for _, attr := range optional {
+		if attr.value != "" {
+			r.AddAttrs(slog.String(attr.key, attr.value))
+		}
+	}
+	if entry.File != "" {
+		r.AddAttrs(slog.String("file", entry.File), slog.Int("line", entry.Line), slog.String("function", entry.Function))
+	}
+	if len(entry.ErrorChain) > 0 {
+		r.AddAttrs(slog.Any("error_chain", entry.ErrorChain))
+	}
+	if len(entry.Stack) > 0 {
+		r.AddAttrs(slog.Any("stack", entry.Stack))
+	}
+
+	keys := make([]string, 0, len(entry.Fields))
+	for k := range entry.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		r.AddAttrs(slog.Any(k, entry.Fields[k]))
+	}
+	return r
+}
+
+// fromSlogLevel maps a slog level onto the nearest Logger level at or below it
+func fromSlogLevel(level slog.Level) LogLevel {
+	switch {
+	case level < slog.LevelInfo:
+		return DEBUG
+	case level < slog.LevelWarn:
+		return INFO
+	case level < slog.LevelError:
+		return WARN
+	case level < slog.LevelError+4:
+		return ERROR
+	default:
+		return FATAL
+	}
+}
+
+func toSlogLevel(level LogLevel) slog.Level {
+	switch level {
+	case DEBUG:
+		return slog.LevelDebug
+	case WARN:
+		return slog.LevelWarn
+	case ERROR:
+		return slog.LevelError
+	case FATAL:
+		return slog.LevelError + 4
+	default:
+		return slog.LevelInfo
+	}
+}
@@ -0,0 +1,96 @@
+//go:build go1.21
+
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlogHandler_WritesThroughLogger(t *testing.T) {
+	var buf bytes.Buffer
+	base := New(Config{Level: "INFO", Format: "JSON", Service: "test-service", Component: "test"})
+	base.output = &buf
+
+	log := slog.New(NewSlogHandler(base.WithField("region", "eu")))
+	ctx := WithRequestID(context.Background(), "req-1")
+
+	log.DebugContext(ctx, "hidden")
+	log.With("user", "alice").WithGroup("http").InfoContext(ctx, "request served",
+		"status", 200, "duration", 1500*time.Millisecond, slog.Group("client", "ip", "10.0.0.1"))
+	log.Error("failed", "err", errors.New("boom"))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 entries with debug filtered out, got %d: %s", len(lines), buf.String())
+	}
+
+	var entry LogEntry
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("Failed to parse entry: %v", err)
+	}
+	if entry.Level != "INFO" || entry.Message != "request served" || entry.RequestID != "req-1" {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+	expected := map[string]interface{}{
+		"region":         "eu",
+		"user":           "alice",
+		"http.status":    float64(200),
+		"http.duration":  "1.5s",
+		"http.client.ip": "10.0.0.1",
+	}
+	for k, v := range expected {
+		if entry.Fields[k] != v {
+			t.Errorf("Expected field %s = %v, got %v", k, v, entry.Fields[k])
+		}
+	}
+	if entry.File != "slog_test.go" {
+		t.Errorf("Expected the caller to be the slog call site, got %s", entry.File)
+	}
+
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatalf("Failed to parse entry: %v", err)
+	}
+	if entry.Level != "ERROR" || entry.Fields["err"] != "boom" {
+		t.Errorf("Expected an error entry with the error message, got %+v", entry)
+	}
+}
+
+func TestSlogLogger_WritesThroughHandler(t *testing.T) {
+	var buf bytes.Buffer
+	h := slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})
+	log := NewSlogLogger(h, "test-service", "test")
+
+	log.Debug("hidden")
+	log.WithField("attempt", 2).WarnContext(WithTraceID(context.Background(), "trace-1"), "retrying")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected 1 record with debug filtered out, got %d: %s", len(lines), buf.String())
+	}
+
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("Failed to parse record: %v", err)
+	}
+	expected := map[string]interface{}{
+		"level":     "WARN",
+		"msg":       "retrying",
+		"service":   "test-service",
+		"component": "test",
+		"trace_id":  "trace-1",
+		"attempt":   float64(2),
+		"file":      "slog_test.go",
+	}
+	for k, v := range expected {
+		if record[k] != v {
+			t.Errorf("Expected %s = %v, got %v", k, v, record[k])
+		}
+	}
+}
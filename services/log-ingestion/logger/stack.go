@@ -0,0 +1,88 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// maxStackDepth bounds the number of frames a captured stack trace holds
+const maxStackDepth = 32
+
+// ErrorCause is one error in the chain of an error logged with WithError
+type ErrorCause struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// Coder is implemented by errors carrying a machine-readable code, such as
+// a validation or upstream API error, which WithError records as error_code
+type Coder interface {
+	Code() string
+}
+
+// errorChain returns err followed by the errors it wraps, walking Unwrap
+// depth-first through errors wrapping several others
+func errorChain(err error) []ErrorCause {
+	var chain []ErrorCause
+	var walk func(err error)
+	walk = func(err error) {
+		for err != nil && len(chain) < maxStackDepth {
+			chain = append(chain, ErrorCause{Type: fmt.Sprintf("%T", err), Message: err.Error()})
+			if multi, ok := err.(interface{ Unwrap() []error }); ok {
+				for _, wrapped := range multi.Unwrap() {
+					walk(wrapped)
+				}
+				return
+			}
+			err = errors.Unwrap(err)
+		}
+	}
+	walk(err)
+	return chain
+}
+
+// stackTrace returns the calling goroutine's stack as "function (file:line)"
+// frames, skipping skip frames above its caller and ending before the
+// runtime's own frames
+func stackTrace(skip int) []string {
+	pcs := make([]uintptr, maxStackDepth)
+	// Skip runtime.Callers and stackTrace itself
+	n := runtime.Callers(skip+2, pcs)
+
+	stack := make([]string, 0, n)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if strings.HasPrefix(frame.Function, "runtime.") {
+			break
+		}
+		stack = append(stack, fmt.Sprintf("%s (%s:%d)", filepath.Base(frame.Function), frame.File, frame.Line))
+		if !more {
+			break
+		}
+	}
+	return stack
+}
+
+// captureStack reports whether an entry gets a stack trace: when the logger
+// captures them and the entry is an error or carries one from WithError
+func (l *Logger) captureStack(level LogLevel) bool {
+	return l.stackTraces && (level >= ERROR || l.errorChain != nil)
+}
+
+// formatStackLines renders the error chain and stack trace of an entry as
+// indented lines following the entry, for the human-readable formats
+func formatStackLines(entry LogEntry) string {
+	var b strings.Builder
+	// The chain starts with the logged error itself, already in the entry
+	for i := 1; i < len(entry.ErrorChain); i++ {
+		fmt.Fprintf(&b, "\n\tcaused by: %s (%s)", entry.ErrorChain[i].Message, entry.ErrorChain[i].Type)
+	}
+	for _, frame := range entry.Stack {
+		b.WriteString("\n\t" + frame)
+	}
+	return b.String()
+}
@@ -0,0 +1,91 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestLogger_StackTraces(t *testing.T) {
+	var buffer bytes.Buffer
+
+	logger := New(Config{Level: "DEBUG", Format: "JSON", Service: "test-service", Component: "test", StackTraces: true})
+	logger.output = &buffer
+
+	cause := &fs.PathError{Op: "open", Path: "config.yaml", Err: fs.ErrNotExist}
+	err := fmt.Errorf("load config: %w", cause)
+	logger.WithError(err).Warn("using defaults")
+	logger.Error("plain error")
+	logger.Info("no stack")
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(lines))
+	}
+	var entries [3]LogEntry
+	for i, line := range lines {
+		if err := json.Unmarshal([]byte(line), &entries[i]); err != nil {
+			t.Fatalf("Failed to parse entry: %v", err)
+		}
+	}
+
+	chain := entries[0].ErrorChain
+	if len(chain) != 3 {
+		t.Fatalf("Expected 3 errors in the chain, got %+v", chain)
+	}
+	if chain[0].Message != err.Error() || chain[1].Type != "*fs.PathError" || chain[2].Message != fs.ErrNotExist.Error() {
+		t.Errorf("Unexpected error chain %+v", chain)
+	}
+
+	// The stack starts at the logging call, not inside the logger
+	for i, entry := range entries[:2] {
+		if len(entry.Stack) == 0 || !strings.HasPrefix(entry.Stack[0], "logger.TestLogger_StackTraces (") {
+			t.Errorf("Entry %d: expected stack starting at the test, got %v", i, entry.Stack)
+		}
+	}
+	if entries[1].ErrorChain != nil {
+		t.Errorf("Expected no error chain without WithError, got %+v", entries[1].ErrorChain)
+	}
+	if entries[2].Stack != nil {
+		t.Errorf("Expected no stack for an INFO entry, got %v", entries[2].Stack)
+	}
+}
+
+func TestLogger_StackTracesDisabled(t *testing.T) {
+	var buffer bytes.Buffer
+
+	logger := New(Config{Level: "DEBUG", Format: "JSON", Service: "test-service", Component: "test"})
+	logger.output = &buffer
+
+	logger.WithError(fmt.Errorf("wrapped: %w", errors.New("cause"))).Error("failed")
+
+	var entry LogEntry
+	if err := json.Unmarshal(buffer.Bytes(), &entry); err != nil {
+		t.Fatalf("Failed to parse entry: %v", err)
+	}
+	// The chain of a wrapping error is recorded without stack traces too
+	if entry.Stack != nil || len(entry.ErrorChain) != 2 {
+		t.Errorf("Expected a chain without a stack, got %v %+v", entry.Stack, entry.ErrorChain)
+	}
+}
+
+func TestLogger_StackTracesText(t *testing.T) {
+	var buffer bytes.Buffer
+
+	logger := New(Config{Level: "DEBUG", Format: "TEXT", Service: "test-service", Component: "test", StackTraces: true})
+	logger.output = &buffer
+
+	logger.WithError(fmt.Errorf("wrapped: %w", errors.New("cause"))).Error("failed")
+
+	output := buffer.String()
+	if !strings.Contains(output, "\n\tcaused by: cause (*errors.errorString)") {
+		t.Errorf("Expected the cause on its own line, got %q", output)
+	}
+	if !strings.Contains(output, "\n\tlogger.TestLogger_StackTracesText (") {
+		t.Errorf("Expected stack frames on their own lines, got %q", output)
+	}
+}
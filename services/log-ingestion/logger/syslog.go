@@ -0,0 +1,122 @@
+package logger
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// syslogSockets are the local syslog sockets tried in order
+var syslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// syslogFacilities maps facility names to their RFC 5424 codes
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// syslogFramer wraps formatted entries in RFC 5424 messages
+type syslogFramer struct {
+	facility int
+	hostname string
+	procID   string
+	// octetCounting prefixes each message with its length, as RFC 6587
+	// requires to delimit messages on a stream
+	octetCounting bool
+}
+
+// isSyslogOutput reports whether an output name is a syslog target
+func isSyslogOutput(name string) bool {
+	return name == "syslog" || strings.HasPrefix(name, "syslog+")
+}
+
+// openSyslog opens a syslog target: "syslog" for the local socket, or
+// syslog+udp://host:port or syslog+tcp://host:port for a remote server
+func openSyslog(name, facility string) (io.Writer, *syslogFramer) {
+	framer := &syslogFramer{facility: parseSyslogFacility(facility), procID: strconv.Itoa(os.Getpid())}
+	framer.hostname, _ = os.Hostname()
+
+	switch {
+	case strings.HasPrefix(name, "syslog+tcp://"):
+		framer.octetCounting = true
+		return &netWriter{network: "tcp", address: strings.TrimPrefix(name, "syslog+tcp://")}, framer
+	case strings.HasPrefix(name, "syslog+udp://"):
+		return &netWriter{network: "udp", address: strings.TrimPrefix(name, "syslog+udp://")}, framer
+	}
+
+	socket := syslogSockets[0]
+	for _, path := range syslogSockets {
+		if _, err := os.Stat(path); err == nil {
+			socket = path
+			break
+		}
+	}
+	return &netWriter{network: "unixgram", address: socket}, framer
+}
+
+// frame returns the syslog message of an entry formatted as msg. The
+// service is the APP-NAME and the component the MSGID.
+func (s *syslogFramer) frame(entry LogEntry, msg string) []byte {
+	var b strings.Builder
+	b.WriteString("<" + strconv.Itoa(s.facility*8+syslogSeverity(entry.Level)) + ">1 ")
+	b.WriteString(entry.Timestamp.Format("2006-01-02T15:04:05.000000Z07:00") + " ")
+	b.WriteString(syslogHeaderField(s.hostname, 255) + " ")
+	b.WriteString(syslogHeaderField(entry.Service, 48) + " ")
+	b.WriteString(syslogHeaderField(s.procID, 128) + " ")
+	b.WriteString(syslogHeaderField(entry.Component, 32) + " ")
+	// No structured data; the entry's fields are part of msg
+	b.WriteString("- ")
+	b.WriteString(msg)
+
+	if s.octetCounting {
+		return []byte(strconv.Itoa(b.Len()) + " " + b.String())
+	}
+	return []byte(b.String())
+}
+
+// syslogSeverity maps a level name to its RFC 5424 severity
+func syslogSeverity(level string) int {
+	switch level {
+	case "DEBUG":
+		return 7
+	case "INFO":
+		return 6
+	case "WARN":
+		return 4
+	case "ERROR":
+		return 3
+	case "FATAL":
+		return 2
+	default:
+		return 5
+	}
+}
+
+// syslogHeaderField makes a header field valid: printable ASCII without
+// spaces, at most max characters, and "-" when empty
+func syslogHeaderField(value string, max int) string {
+	if value == "" {
+		return "-"
+	}
+	field := strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, value)
+	if len(field) > max {
+		field = field[:max]
+	}
+	return field
+}
+
+// parseSyslogFacility parses a facility name, defaulting to user
+func parseSyslogFacility(name string) int {
+	if facility, ok := syslogFacilities[strings.ToLower(name)]; ok {
+		return facility
+	}
+	return syslogFacilities["user"]
+}
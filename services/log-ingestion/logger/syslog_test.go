@@ -0,0 +1,140 @@
+package logger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogger_SyslogUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	logger := New(Config{
+		Level:          "INFO",
+		Format:         "LOGFMT",
+		Service:        "test-service",
+		Component:      "http",
+		Output:         "syslog+udp://" + conn.LocalAddr().String(),
+		SyslogFacility: "local0",
+	})
+	logger.Warn("disk almost full")
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 4096)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("Expected a syslog message: %v", err)
+	}
+
+	// local0 (16) * 8 + warning (4) = 132
+	header := regexp.MustCompile(`^<132>1 \d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{6}Z \S+ test-service \d+ http - `)
+	message := string(buf[:n])
+	if !header.MatchString(message) {
+		t.Fatalf("Unexpected syslog header in %q", message)
+	}
+	if !strings.Contains(message, `level=WARN message="disk almost full"`) || strings.HasSuffix(message, "\n") {
+		t.Errorf("Expected the logfmt entry as the message, got %q", message)
+	}
+}
+
+func TestLogger_SyslogTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		var length int
+		if _, err := fmt.Fscanf(reader, "%d ", &length); err != nil {
+			return
+		}
+		message := make([]byte, length)
+		if _, err := io.ReadFull(reader, message); err == nil {
+			received <- string(message)
+		}
+	}()
+
+	logger := New(Config{
+		Level:     "INFO",
+		Service:   "test-service",
+		Component: "http",
+		Outputs:   []OutputConfig{{Output: "syslog+tcp://" + listener.Addr().String(), Format: "JSON"}},
+	})
+	logger.Error("request failed")
+
+	select {
+	case message := <-received:
+		// user (1) * 8 + error (3) = 11
+		if !strings.HasPrefix(message, "<11>1 ") || !strings.HasSuffix(message, "}") {
+			t.Errorf("Expected one octet-counted message, got %q", message)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a syslog message")
+	}
+}
+
+func TestLogger_SyslogLocal(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "log")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("Unix datagram sockets unavailable: %v", err)
+	}
+	defer conn.Close()
+
+	sockets := syslogSockets
+	syslogSockets = []string{filepath.Join(t.TempDir(), "missing"), socket}
+	defer func() { syslogSockets = sockets }()
+
+	logger := New(Config{Level: "INFO", Service: "test-service", Component: "test", Output: "syslog"})
+	logger.Info("hello syslog")
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("Expected a message on the local socket: %v", err)
+	}
+	if message := string(buf[:n]); !strings.HasPrefix(message, "<14>1 ") || !strings.Contains(message, "hello syslog") {
+		t.Errorf("Unexpected local syslog message %q", message)
+	}
+}
+
+func TestSyslogHeaderField(t *testing.T) {
+	tests := []struct {
+		value    string
+		max      int
+		expected string
+	}{
+		{"", 48, "-"},
+		{"log ingestion", 48, "log_ingestion"},
+		{"héllo", 48, "h_llo"},
+		{"abcdef", 4, "abcd"},
+	}
+
+	for _, test := range tests {
+		if got := syslogHeaderField(test.value, test.max); got != test.expected {
+			t.Errorf("syslogHeaderField(%q, %d) = %q, want %q", test.value, test.max, got, test.expected)
+		}
+	}
+	if parseSyslogFacility("LOCAL7") != 23 || parseSyslogFacility("bogus") != 1 {
+		t.Error("Unexpected facility parsing")
+	}
+}
@@ -0,0 +1,70 @@
+package logger
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Timestamp formats besides Go layouts
+const (
+	TimeRFC3339     = "RFC3339"
+	TimeRFC3339Nano = "RFC3339Nano"
+	TimeEpoch       = "epoch"
+	TimeEpochMillis = "epoch_millis"
+	TimeEpochMicros = "epoch_micros"
+	TimeEpochNanos  = "epoch_nanos"
+)
+
+// timestampFormat is how entries write their timestamp; the zero value
+// keeps each format's own
+type timestampFormat struct {
+	key    string
+	layout string
+}
+
+// newTimestampFormat resolves a format name to a layout, keeping Go layouts
+// such as "2006-01-02 15:04:05.000" as given
+func newTimestampFormat(key, format string) timestampFormat {
+	switch strings.ToLower(format) {
+	case "":
+	case "rfc3339":
+		format = time.RFC3339
+	case "rfc3339nano":
+		format = time.RFC3339Nano
+	case TimeEpoch, TimeEpochMillis, TimeEpochMicros, TimeEpochNanos:
+		format = strings.ToLower(format)
+	}
+	return timestampFormat{key: key, layout: format}
+}
+
+// keyOr returns the configured key, or def
+func (t timestampFormat) keyOr(def string) string {
+	if t.key == "" {
+		return def
+	}
+	return t.key
+}
+
+// epoch reports whether timestamps are written as numbers
+func (t timestampFormat) epoch() bool {
+	return strings.HasPrefix(t.layout, TimeEpoch)
+}
+
+// text formats ts in the configured layout, or in def when none is set
+func (t timestampFormat) text(ts time.Time, def string) string {
+	switch t.layout {
+	case "":
+		return ts.Format(def)
+	case TimeEpoch:
+		return strconv.FormatInt(ts.Unix(), 10)
+	case TimeEpochMillis:
+		return strconv.FormatInt(ts.UnixNano()/int64(time.Millisecond), 10)
+	case TimeEpochMicros:
+		return strconv.FormatInt(ts.UnixNano()/int64(time.Microsecond), 10)
+	case TimeEpochNanos:
+		return strconv.FormatInt(ts.UnixNano(), 10)
+	default:
+		return ts.Format(t.layout)
+	}
+}
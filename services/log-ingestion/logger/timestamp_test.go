@@ -0,0 +1,60 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogger_TimestampFormat(t *testing.T) {
+	ts := time.Date(2024, 1, 15, 10, 30, 45, 123456789, time.UTC)
+
+	tests := []struct {
+		key, format string
+		expected    string
+	}{
+		{"", "", `{"timestamp":"2024-01-15T10:30:45.123456789Z",`},
+		{"@timestamp", "", `{"@timestamp":"2024-01-15T10:30:45.123456789Z",`},
+		{"", "RFC3339", `{"timestamp":"2024-01-15T10:30:45Z",`},
+		{"ts", "epoch_millis", `{"ts":1705314645123,`},
+		{"", "epoch", `{"timestamp":1705314645,`},
+		{"", "epoch_nanos", `{"timestamp":1705314645123456789,`},
+		{"time", "2006-01-02 15:04:05.000", `{"time":"2024-01-15 10:30:45.123",`},
+	}
+
+	for _, test := range tests {
+		f := formatter{format: JSON, time: newTimestampFormat(test.key, test.format)}
+		output := f.formatEntry(LogEntry{Timestamp: ts, Level: "INFO", Message: "message"})
+		if !strings.HasPrefix(output, test.expected) {
+			t.Errorf("key %q format %q: expected %s..., got %s", test.key, test.format, test.expected, output)
+		}
+		var doc map[string]interface{}
+		if err := json.Unmarshal([]byte(output), &doc); err != nil || doc["message"] != "message" {
+			t.Errorf("key %q format %q: invalid JSON %s: %v", test.key, test.format, output, err)
+		}
+	}
+}
+
+func TestLogger_TimestampFormatText(t *testing.T) {
+	var buffer bytes.Buffer
+
+	logger := New(Config{Level: "INFO", Format: "TEXT", Service: "test-service", Component: "test", TimeFormat: "epoch_millis"})
+	logger.output = &buffer
+	logger.Info("epoch")
+
+	if !regexp.MustCompile(`^\[\d{13}\] INFO `).MatchString(buffer.String()) {
+		t.Errorf("Expected an epoch timestamp, got %q", buffer.String())
+	}
+
+	buffer.Reset()
+	logger = New(Config{Level: "INFO", Format: "LOGFMT", Service: "test-service", Component: "test", TimeKey: "ts", TimeFormat: "epoch"})
+	logger.output = &buffer
+	logger.Info("epoch")
+
+	if !strings.HasPrefix(buffer.String(), "ts=1") {
+		t.Errorf("Expected ts with an epoch timestamp, got %q", buffer.String())
+	}
+}
@@ -0,0 +1,75 @@
+//go:build zap
+
+package logger
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ZapSink is a Sink encoding entries with zap, built with the zap tag
+type ZapSink struct {
+	core zapcore.Core
+}
+
+// NewZapSink returns a sink writing through the core of l. Entries keep
+// their timestamp and caller; a FATAL entry does not make zap exit, as
+// Fatal exits itself after running the exit hooks.
+func NewZapSink(l *zap.Logger) *ZapSink {
+	return &ZapSink{core: l.Core()}
+}
+
+func (s *ZapSink) Write(entry LogEntry) error {
+	ent := zapcore.Entry{
+		Level:      zapLevel(parseLogLevel(entry.Level)),
+		Time:       entry.Timestamp,
+		LoggerName: entry.Logger,
+		Message:    entry.Message,
+		Caller: zapcore.EntryCaller{
+			Defined:  entry.File != "",
+			File:     entry.File,
+			Line:     entry.Line,
+			Function: entry.Function,
+		},
+	}
+	ce := s.core.Check(ent, nil)
+	if ce == nil {
+		return nil
+	}
+
+	attrs := sinkFields(entry)
+	fields := make([]zap.Field, 0, len(attrs))
+	for _, attr := range attrs {
+		// zap writes the name itself
+		if attr.key == "logger" {
+			continue
+		}
+		if value, ok := attr.value.(string); ok {
+			fields = append(fields, zap.String(attr.key, value))
+			continue
+		}
+		fields = append(fields, zap.Any(attr.key, attr.value))
+	}
+	ce.Write(fields...)
+	return nil
+}
+
+// Sync flushes the zap core
+func (s *ZapSink) Sync() error {
+	return s.core.Sync()
+}
+
+func zapLevel(level LogLevel) zapcore.Level {
+	switch level {
+	case DEBUG:
+		return zapcore.DebugLevel
+	case WARN:
+		return zapcore.WarnLevel
+	case ERROR:
+		return zapcore.ErrorLevel
+	case FATAL:
+		return zapcore.FatalLevel
+	default:
+		return zapcore.InfoLevel
+	}
+}
@@ -0,0 +1,65 @@
+//go:build zerolog
+
+package logger
+
+import (
+	"strconv"
+
+	"github.com/rs/zerolog"
+)
+
+// ZerologSink is a Sink encoding entries with zerolog, built with the
+// zerolog tag
+type ZerologSink struct {
+	logger zerolog.Logger
+}
+
+// NewZerologSink returns a sink writing through l, which should not add
+// its own timestamp or caller: entries carry theirs. A FATAL entry does
+// not make zerolog exit, as Fatal exits itself after running the exit
+// hooks.
+func NewZerologSink(l zerolog.Logger) *ZerologSink {
+	return &ZerologSink{logger: l}
+}
+
+func (s *ZerologSink) Write(entry LogEntry) error {
+	// WithLevel, unlike Fatal, neither exits nor panics
+	event := s.logger.WithLevel(zerologLevel(parseLogLevel(entry.Level)))
+	if event == nil {
+		return nil
+	}
+
+	event = event.Time(zerolog.TimestampFieldName, entry.Timestamp)
+	if entry.File != "" {
+		event = event.Str(zerolog.CallerFieldName, entry.File+":"+strconv.Itoa(entry.Line))
+	}
+	for _, attr := range sinkFields(entry) {
+		if value, ok := attr.value.(string); ok {
+			event = event.Str(attr.key, value)
+			continue
+		}
+		event = event.Interface(attr.key, attr.value)
+	}
+	event.Msg(entry.Message)
+	return nil
+}
+
+// Sync does nothing; zerolog writes every event as it is logged
+func (s *ZerologSink) Sync() error {
+	return nil
+}
+
+func zerologLevel(level LogLevel) zerolog.Level {
+	switch level {
+	case DEBUG:
+		return zerolog.DebugLevel
+	case WARN:
+		return zerolog.WarnLevel
+	case ERROR:
+		return zerolog.ErrorLevel
+	case FATAL:
+		return zerolog.FatalLevel
+	default:
+		return zerolog.InfoLevel
+	}
+}
@@ -2,33 +2,112 @@ package main
 
 import (
     "context"
+    "crypto/tls"
+    "encoding/json"
+    "flag"
     "fmt"
+    "net"
     "net/http"
     "os"
     "os/signal"
     "syscall"
     "time"
+    "log-processing-system/services/log-ingestion/anomaly"
+    "log-processing-system/services/log-ingestion/archive"
+    "log-processing-system/services/log-ingestion/audit"
+    "log-processing-system/services/log-ingestion/auth"
     "log-processing-system/services/log-ingestion/config"
+    "log-processing-system/services/log-ingestion/crash"
     "log-processing-system/services/log-ingestion/database"
+    "log-processing-system/services/log-ingestion/deadletter"
+    "log-processing-system/services/log-ingestion/features"
     "log-processing-system/services/log-ingestion/handlers"
+    "log-processing-system/services/log-ingestion/health"
     "log-processing-system/services/log-ingestion/logger"
+    "log-processing-system/services/log-ingestion/maintenance"
     "log-processing-system/services/log-ingestion/middleware"
+    "log-processing-system/services/log-ingestion/models"
+    "log-processing-system/services/log-ingestion/patterns"
+    "log-processing-system/services/log-ingestion/pipeline"
+    "log-processing-system/services/log-ingestion/profiling"
+    "log-processing-system/services/log-ingestion/pubsub"
+    "log-processing-system/services/log-ingestion/querycache"
+    "log-processing-system/services/log-ingestion/queue"
+    "log-processing-system/services/log-ingestion/quota"
+    "log-processing-system/services/log-ingestion/retention"
+    "log-processing-system/services/log-ingestion/sdnotify"
+    "log-processing-system/services/log-ingestion/shutdown"
+    "log-processing-system/services/log-ingestion/stats"
+    "log-processing-system/services/log-ingestion/throttle"
+    "log-processing-system/services/log-ingestion/tracing"
+    "log-processing-system/services/log-ingestion/writequeue"
     "github.com/gorilla/mux"
+    "github.com/prometheus/client_golang/prometheus"
+    "github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
+    // "log-ingestion status" probes a running instance and exits non-zero
+    // unless it is healthy, for container HEALTHCHECKs in images without curl
+    if len(os.Args) > 1 && os.Args[1] == "status" {
+        os.Exit(runStatus(os.Args[2:]))
+    }
+
+    // Flags override every other configuration source; parsed before the
+    // logger is created so that -log-level applies to it
+    flags, err := config.ParseFlags("log-ingestion", "SERVER_PORT", os.Args[1:])
+    if err != nil {
+        if err == flag.ErrHelp {
+            os.Exit(0)
+        }
+        os.Exit(2)
+    }
+
     // Initialize structured logger
     appLogger := logger.NewFromEnv("log-ingestion", "main")
+    // Deferred first so it runs last: writes out buffered entries, including
+    // those logged during shutdown, and closes the log outputs
+    defer appLogger.Close()
     
     // Set up global context
     ctx, cancel := context.WithCancel(context.Background())
     defer cancel()
 
-    // Load configuration from .env file
+    // Load configuration from the .env file, config file and environment;
+    // every invalid setting is reported at once
     cfg, err := config.LoadConfig()
     if err != nil {
         appLogger.WithError(err).Fatal("Failed to load configuration")
     }
+    // The files may set LOG_LEVEL and LOG_LEVELS
+    logger.ReloadLevels()
+    features.Set(cfg.Features)
+    // Spans of database operations, as children of the request span, go to
+    // the OTLP collector the files or environment name
+    tracing.Init(tracing.ConfigFromEnv("log-ingestion"))
+
+    // -print-config shows what this instance would run with, and stops
+    if flags.PrintConfig {
+        encoder := json.NewEncoder(os.Stdout)
+        encoder.SetIndent("", "  ")
+        if err := encoder.Encode(cfg.Effective()); err != nil {
+            appLogger.WithError(err).Fatal("Failed to print configuration")
+        }
+        return
+    }
+
+    // Panics and fatal exits are counted, and with CRASH_REPORT_DIR set
+    // written up for postmortems
+    crashReporter, err := crash.New(crash.Config{
+        Dir:        cfg.Crash.Dir,
+        Service:    "log-ingestion",
+        MaxReports: cfg.Crash.MaxReports,
+        RecentLogs: cfg.Crash.RecentLogs,
+    })
+    if err != nil {
+        appLogger.WithError(err).Fatal("Failed to set up crash reports")
+    }
+    logger.OnFatal(crashReporter.Fatal)
 
     appLogger.WithFields(map[string]interface{}{
         "host":     cfg.Server.Host,
@@ -38,52 +117,603 @@ func main() {
     }).Info("Configuration loaded successfully")
 
     // Initialize database connection
+    database.Tune(database.Tuning{
+        MaxOpenConns:    cfg.Database.MaxOpenConns,
+        MaxIdleConns:    cfg.Database.MaxIdleConns,
+        ConnMaxLifetime: cfg.Database.ConnMaxLifetime,
+        SlowQuery:       cfg.Database.SlowQuery,
+    })
     if err := database.Connect(cfg.Database.URL); err != nil {
         appLogger.WithError(err).Fatal("Failed to connect to database")
     }
-    defer database.Close()
+    // Closed last when shutting down; Fatal exits without shutting down
+    logger.RegisterExitHook(database.Close)
 
     appLogger.WithField("db_host", cfg.Database.Host).Info("Database connection established")
 
+    // Log entries are spread over the shards by source, and queries for
+    // them fan out to the shards
+    if len(cfg.Database.Shards) > 0 {
+        if err := database.ConnectShards(cfg.Database.Shards); err != nil {
+            appLogger.WithError(err).Fatal("Failed to connect to log shards")
+        }
+        appLogger.WithField("shards", database.ShardCount()).Info("Sharding log entries by source")
+    }
+
+    // The API handlers store entries in Postgres; the stages entries pass
+    // through are installed on them below
+    apiHandler := handlers.New(database.Postgres{}, appLogger.WithComponent("handlers"))
+
+    // Dependencies reported by /health/details; ingestion needs the
+    // database and, when configured, the queue
+    healthChecker := health.NewChecker(cfg.Health.CheckTimeout, cfg.Health.CacheTTL)
+    healthChecker.Add("postgres", true, database.PingContext)
+
+    if cfg.Integrity.HashChain {
+        database.EnableHashChain(true)
+    }
+
+    // Fan out newly stored entries from Postgres NOTIFY to live subscribers
+    logHub := pubsub.NewHub()
+    go func() {
+        if err := database.ListenForNewLogs(ctx, cfg.Database.URL, logHub.Publish); err != nil {
+            appLogger.WithError(err).Error("New log listener stopped")
+        }
+    }()
+
+    // Downsample aged low-severity logs into hourly summaries
+    var rollupJob *retention.RollupJob
+    if cfg.Rollup.Enabled {
+        rollupJob = retention.NewRollupJob(cfg.Rollup.After, cfg.Rollup.NoiseAfter, cfg.Rollup.Interval, cfg.Rollup.Levels, appLogger.WithComponent("retention"))
+        go rollupJob.Start(ctx)
+    }
+
+    // Keep per-minute aggregate stats current for /logs/aggregate
+    statsRefresher := stats.NewRefresher(cfg.Stats.RefreshInterval, appLogger.WithComponent("stats"))
+    go statsRefresher.Start(ctx)
+
+    // Learn per-source ingest rates and alert on spikes, drops and silence
+    var volumeDetector *anomaly.Detector
+    if cfg.Anomaly.Enabled {
+        volumeDetector = anomaly.NewDetector(anomaly.Config{
+            Window:         cfg.Anomaly.Window,
+            Alpha:          cfg.Anomaly.Alpha,
+            Threshold:      cfg.Anomaly.Threshold,
+            WarmupWindows:  cfg.Anomaly.WarmupWindows,
+            SilenceWindows: cfg.Anomaly.SilenceWindows,
+            MinRate:        cfg.Anomaly.MinRate,
+        }, appLogger.WithComponent("anomaly"))
+        go volumeDetector.Start(ctx, logHub)
+    }
+
+    // Mine message templates so operators can spot new kinds of errors
+    var patternMiner *patterns.Miner
+    if cfg.Patterns.Enabled {
+        patternMiner = patterns.NewMiner(patterns.Config{
+            Depth:       cfg.Patterns.Depth,
+            Similarity:  cfg.Patterns.Similarity,
+            MaxChildren: cfg.Patterns.MaxChildren,
+            MaxPatterns: cfg.Patterns.MaxPatterns,
+        }, appLogger.WithComponent("patterns"))
+        go patternMiner.Start(ctx, logHub)
+    }
+
+    // Per-source ingest budgets sample or reject entries from flooding sources
+    if cfg.Throttle.Enabled {
+        apiHandler.SetThrottle(throttle.NewLimiter(throttle.Config{
+            Rate:          cfg.Throttle.Rate,
+            SourceRates:   cfg.Throttle.SourceRates,
+            SampleUntil:   cfg.Throttle.SampleUntil,
+            Window:        cfg.Throttle.Window,
+            ProtectLevels: cfg.Throttle.ProtectLevels,
+        }, appLogger.WithComponent("throttle")))
+    }
+
+    // With a queue configured, entries are published for the log-processor
+    // service, which runs the pipeline and stores them
+    var publisher queue.Publisher
+    if cfg.Queue.Backend != "" {
+        publisher, err = queue.NewPublisher(queue.Config{
+            Backend: cfg.Queue.Backend,
+            Addrs:   cfg.Queue.Addrs,
+            Topic:   cfg.Queue.Topic,
+            MaxLen:  cfg.Queue.MaxLen,
+        })
+        if err != nil {
+            appLogger.WithError(err).Fatal("Failed to create queue publisher")
+        }
+        apiHandler.SetQueue(publisher)
+        if pinger, ok := publisher.(queue.Pinger); ok {
+            healthChecker.Add(cfg.Queue.Backend, true, pinger.Ping)
+        }
+
+        appLogger.WithFields(map[string]interface{}{
+            "backend": cfg.Queue.Backend,
+            "topic":   cfg.Queue.Topic,
+        }).Info("Publishing log entries to queue for log-processor")
+    }
+
+    // The system's own logs, shipped back into ingestion, are stored
+    // without logging about them or dropped
+    apiHandler.SetDropSelfLogs(cfg.Log.SelfLogs == "drop")
+
+    // Build the processing pipeline between ingestion and storage
+    pipeline.SetSessionStore(database.UpsertSessions)
+    var logPipeline *pipeline.Pipeline
+    // Closed when the timed flushes of the pipeline stop
+    pipelineDone := make(chan struct{})
+    if cfg.Pipeline.ConfigPath != "" && cfg.Queue.Backend == "" && features.Enabled(features.Pipeline) {
+        pipelineCfg, err := pipeline.LoadConfig(cfg.Pipeline.ConfigPath)
+        if err != nil {
+            appLogger.WithError(err).Fatal("Failed to load pipeline configuration")
+        }
+        logPipeline, err = pipeline.Build(pipelineCfg, appLogger.WithComponent("pipeline"))
+        if err != nil {
+            appLogger.WithError(err).Fatal("Failed to build processing pipeline")
+        }
+        apiHandler.SetPipeline(logPipeline)
+        logger.RegisterExitHook(logPipeline.Close)
+
+        // Stages such as group_repeats release held entries on a timer
+        if logPipeline.HasFlushers() {
+            go func() {
+                logPipeline.Run(ctx, cfg.Pipeline.FlushInterval, apiHandler.StoreFlushedLogs)
+                close(pipelineDone)
+            }()
+        }
+    }
+
+    // Keep submissions that fail parsing, validation, the pipeline or storage
+    // for inspection and replay instead of rejecting them
+    var deadLetterQueue *deadletter.Queue
+    if cfg.DeadLetter.Enabled {
+        var store deadletter.Store
+        switch cfg.DeadLetter.Backend {
+        case "table":
+            store = deadletter.NewTableStore()
+        case "file":
+            store, err = deadletter.OpenFileStore(cfg.DeadLetter.File)
+            if err != nil {
+                appLogger.WithError(err).Fatal("Failed to open dead-letter file")
+            }
+        default:
+            appLogger.WithField("backend", cfg.DeadLetter.Backend).Fatal("Unknown dead-letter backend")
+        }
+        deadLetterQueue = deadletter.NewQueue(store, cfg.DeadLetter.StoreRetries, cfg.DeadLetter.RetryBackoff, appLogger.WithComponent("deadletter"))
+        apiHandler.SetDeadLetterQueue(deadLetterQueue)
+    }
+
+    // Maintenance mode, switched through /admin/maintenance while the
+    // database is migrated or failed over. Entries buffered then, or left
+    // buffered by an earlier run, are stored once it is off.
+    maintenanceSwitch, err := maintenance.New(maintenance.Config{
+        Mode:       cfg.Maintenance.Mode,
+        BufferFile: cfg.Maintenance.BufferFile,
+        MaxBytes:   cfg.Maintenance.BufferMaxBytes,
+        RetryAfter: cfg.Maintenance.RetryAfter,
+    }, apiHandler.StoreBufferedLog, appLogger.WithComponent("maintenance"))
+    if err != nil {
+        appLogger.WithError(err).Fatal("Failed to open maintenance buffer")
+    }
+    apiHandler.SetMaintenance(maintenanceSwitch)
+    if cfg.Maintenance.Enabled {
+        maintenanceSwitch.Enable("", "MAINTENANCE_ENABLED")
+        appLogger.WithField("mode", cfg.Maintenance.Mode).Warn("Starting in maintenance mode")
+    } else {
+        maintenanceSwitch.Drain()
+    }
+
+    // Entries are stored after ingestion answered, unless the log-processor
+    // stores them
+    var writeQueue *writequeue.Queue
+    if cfg.WriteQueue.Enabled && publisher == nil {
+        writeQueue = writequeue.New(writequeue.Config{
+            Size:       cfg.WriteQueue.Size,
+            Workers:    cfg.WriteQueue.Workers,
+            HighWater:  cfg.WriteQueue.HighWater,
+            RetryAfter: cfg.WriteQueue.RetryAfter,
+        }, apiHandler.StoreQueuedLog)
+        apiHandler.SetWriteQueue(writeQueue)
+    }
+
+    // Dashboards refreshing the same panels are answered from the query
+    // cache rather than PostgreSQL while the responses are fresh
+    var queryCache querycache.Cache
+    if cfg.QueryCache.Backend != "" {
+        queryCache, err = querycache.New(querycache.Config{
+            Backend:       cfg.QueryCache.Backend,
+            TTL:           cfg.QueryCache.TTL,
+            MaxEntryBytes: cfg.QueryCache.MaxEntryBytes,
+            MaxEntries:    cfg.QueryCache.MaxEntries,
+            MaxBytes:      cfg.QueryCache.MaxBytes,
+            RedisAddr:     cfg.QueryCache.RedisAddr,
+            KeyPrefix:     cfg.QueryCache.KeyPrefix,
+        })
+        if err != nil {
+            appLogger.WithError(err).Fatal("Failed to create query cache")
+        }
+        apiHandler.SetQueryCache(queryCache)
+        // Queries fall back to the database, so the cache is not critical
+        if pinger, ok := queryCache.(querycache.Pinger); ok {
+            healthChecker.Add("query_cache", false, pinger.Ping)
+        }
+
+        appLogger.WithFields(map[string]interface{}{
+            "backend": cfg.QueryCache.Backend,
+            "ttl":     cfg.QueryCache.TTL.String(),
+        }).Info("Caching query responses")
+    }
+
+    bulkDeleter := retention.NewBulkDeleter(appLogger.WithComponent("retention"))
+
+    // Archive replay re-ingests archived logs through the current pipeline
+    var archiveReplayer *archive.Replayer
+    if cfg.Archive.Bucket != "" || cfg.Archive.Dir != "" {
+        var source archive.Source
+        if cfg.Archive.Bucket != "" {
+            source, err = archive.NewS3Source(cfg.Archive.Bucket, cfg.Archive.Region, cfg.Archive.Endpoint)
+            if err != nil {
+                appLogger.WithError(err).Fatal("Failed to create archive source")
+            }
+        } else {
+            source = archive.NewDirSource(cfg.Archive.Dir)
+        }
+        // Only replays need the archive
+        if pinger, ok := source.(archive.Pinger); ok {
+            healthChecker.Add("archive", false, pinger.Ping)
+        }
+        archiveReplayer = archive.NewReplayer(source, apiHandler.ReplayArchivedEntry, appLogger.WithComponent("archive"))
+    }
+
+    // API keys, identity provider tokens, request signatures and client
+    // certificates restrict who may ingest, read and administer logs; an
+    // admin credential, when set, is the only way into the admin endpoints
+    adminCredential := cfg.Auth.AdminUsername != "" || cfg.Auth.AdminToken != ""
+    var authenticator *auth.Authenticator
+    if cfg.Auth.Enabled || cfg.Auth.JWKSURL != "" || len(cfg.Auth.HMACSecrets) > 0 || cfg.Auth.ClientCNs != nil || adminCredential {
+        var keyStore auth.Store
+        if cfg.Auth.Enabled {
+            keyStore = auth.NewTableStore()
+        }
+        authenticator = auth.NewAuthenticator(keyStore, auth.Config{
+            Header:   cfg.Auth.Header,
+            AdminKey: cfg.Auth.AdminKey,
+            CacheTTL: cfg.Auth.CacheTTL,
+            JWT: auth.JWTConfig{
+                JWKSURL:         cfg.Auth.JWKSURL,
+                Issuer:          cfg.Auth.Issuer,
+                Audience:        cfg.Auth.Audience,
+                ClockSkew:       cfg.Auth.ClockSkew,
+                UserClaim:       cfg.Auth.UserClaim,
+                TenantClaim:     cfg.Auth.TenantClaim,
+                ScopeClaim:      cfg.Auth.ScopeClaim,
+                RefreshInterval: cfg.Auth.JWKSRefresh,
+            },
+            HMAC: auth.HMACConfig{
+                Secrets: cfg.Auth.HMACSecrets,
+                Window:  cfg.Auth.HMACWindow,
+            },
+            ClientCNs: cfg.Auth.ClientCNs,
+            Admin: auth.AdminConfig{
+                Username: cfg.Auth.AdminUsername,
+                Password: cfg.Auth.AdminPassword,
+                Token:    cfg.Auth.AdminToken,
+            },
+        }, appLogger.WithComponent("auth"))
+        appLogger.WithFields(map[string]interface{}{
+            "api_keys":         cfg.Auth.Enabled,
+            "jwks_url":         cfg.Auth.JWKSURL,
+            "signed_requests":  len(cfg.Auth.HMACSecrets) > 0,
+            "client_cns":       len(cfg.Auth.ClientCNs),
+            "admin_credential": adminCredential,
+        }).Info("Request authentication enabled")
+    }
+
+    // Administrative actions are recorded in the audit trail
+    var auditRecorder *audit.Recorder
+    if cfg.Audit.Enabled {
+        auditRecorder = audit.NewRecorder(audit.NewTableStore(), appLogger.WithComponent("audit"))
+    }
+
+    // Ingestion is counted against the quotas of the authenticated tenant
+    var quotaEnforcer *quota.Enforcer
+    if cfg.Quota.Enabled {
+        if authenticator == nil {
+            appLogger.Warn("Ingestion quotas need request authentication to identify tenants; quotas are not enforced")
+        } else {
+            quotaEnforcer = quota.NewEnforcer(quota.Config{
+                Default: quota.Limits{
+                    DailyBytes:        cfg.Quota.DailyBytes,
+                    RequestsPerMinute: cfg.Quota.RequestsPerMinute,
+                },
+                DailyBytes:        cfg.Quota.TenantBytes,
+                RequestsPerMinute: cfg.Quota.TenantRates,
+                SoftLimit:         cfg.Quota.SoftLimit,
+            }, appLogger.WithComponent("quota"))
+            appLogger.WithFields(map[string]interface{}{
+                "daily_bytes":         cfg.Quota.DailyBytes,
+                "requests_per_minute": cfg.Quota.RequestsPerMinute,
+                "tenant_overrides":    len(cfg.Quota.TenantBytes) + len(cfg.Quota.TenantRates),
+                "soft_limit":          cfg.Quota.SoftLimit,
+            }).Info("Ingestion quotas enabled")
+        }
+    }
+
     // Initialize middleware
-    loggingMiddleware := middleware.NewLoggingMiddleware(appLogger.WithComponent("http"))
+    rateLimit := cfg.Server.RateLimit
+    if rateLimit == 0 {
+        // Unlimited
+        rateLimit = -1
+    }
+    loggingMiddleware := middleware.NewLoggingMiddleware(appLogger.WithComponent("http"), middleware.LoggingConfig{
+        SlowRequest:     cfg.Server.SlowRequest,
+        RateLimit:       rateLimit,
+        RateLimitWindow: cfg.Server.RateLimitWindow,
+        Crashes:         crashReporter,
+    })
+    sloMiddleware, err := middleware.NewSLOMiddleware(middleware.SLOConfig{
+        Buckets:               cfg.SLO.LatencyBuckets,
+        LatencyTarget:         cfg.SLO.LatencyTarget,
+        LatencyObjective:      cfg.SLO.LatencyObjective,
+        AvailabilityObjective: cfg.SLO.AvailabilityObjective,
+        NativeHistograms:      cfg.SLO.NativeHistograms,
+        ExcludeRoutes:         cfg.SLO.ExcludeRoutes,
+    }, prometheus.DefaultRegisterer)
+    if err != nil {
+        appLogger.WithError(err).Fatal("Failed to register SLO metrics")
+    }
+    timeoutMiddleware := middleware.NewTimeoutMiddleware(appLogger.WithComponent("http"), cfg.Server.RouteTimeout, cfg.Server.RouteTimeouts)
+    trustedProxies, err := middleware.ParseTrustedProxies(cfg.Server.TrustedProxies)
+    if err != nil {
+        appLogger.WithError(err).Fatal("Invalid TRUSTED_PROXIES")
+    }
 
     // Setup router
     router := mux.NewRouter()
     
     // Apply middleware
     router.Use(loggingMiddleware.RecoveryMiddleware)
+    // Inside the recovery middleware, to count panics as the 500s they
+    // become, and outside the others, to count requests they reject
+    router.Use(sloMiddleware.Handler)
     router.Use(loggingMiddleware.SecurityHeadersMiddleware)
     router.Use(loggingMiddleware.CORSMiddleware)
     router.Use(loggingMiddleware.RateLimitMiddleware)
     router.Use(loggingMiddleware.HealthCheckMiddleware)
+    if authenticator != nil {
+        // After the logging middleware, so rejected requests are logged
+        // with their request ID
+        router.Use(authenticator.Middleware)
+    }
+    // After authentication, so unauthenticated bodies are never inflated,
+    // and before the quotas, which count the decompressed bytes
+    router.Use(middleware.DecompressRequest(middleware.DefaultMaxDecompressedBody))
+    if quotaEnforcer != nil {
+        // After authentication, which identifies the tenant
+        router.Use(quotaEnforcer.Middleware)
+    }
+    if auditRecorder != nil {
+        // After authentication, to know who made the request
+        router.Use(auditRecorder.Middleware)
+    }
+    router.Use(timeoutMiddleware.Handler)
+
+    // Re-reads the .env and config files; log levels are reset even when
+    // that fails
+    reloadConfig := func() error {
+        err := config.ReloadEnv()
+        logger.ReloadLevels()
+        return err
+    }
 
     // Setup routes
-    router.HandleFunc("/ingest", handlers.HandleLogIngestion).Methods("POST")
-    router.HandleFunc("/logs", handlers.HandleLogIngestion).Methods("POST") // Compatibility endpoint
-    router.HandleFunc("/health", handlers.HandleHealthCheck).Methods("GET")
-    router.HandleFunc("/healthz", handlers.HandleHealthCheck).Methods("GET")
+    router.HandleFunc("/ingest", apiHandler.HandleLogIngestion).Methods("POST")
+    router.HandleFunc("/ingest/batch", apiHandler.HandleBatchIngestion).Methods("POST")
+    if features.Enabled(features.LegacyLogsEndpoint) {
+        router.HandleFunc("/logs", apiHandler.HandleLogIngestion).Methods("POST") // Compatibility endpoint
+    }
+    router.HandleFunc("/logs", apiHandler.HandleQueryLogs).Methods("GET")
+    if features.Enabled(features.LiveTail) {
+        router.HandleFunc("/logs/tail", apiHandler.HandleLiveTail(logHub)).Methods("GET")
+    }
+    router.HandleFunc("/logs/aggregate", apiHandler.HandleLogAggregate).Methods("GET")
+    if quotaEnforcer != nil {
+        router.HandleFunc("/quota", apiHandler.HandleGetQuota(quotaEnforcer)).Methods("GET")
+        router.HandleFunc("/admin/quotas", apiHandler.HandleListQuotas(quotaEnforcer)).Methods("GET")
+    }
+    router.HandleFunc("/logs/sessions", apiHandler.HandleListSessions).Methods("GET")
+    router.HandleFunc("/logs/sessions/{id}", apiHandler.HandleGetSession).Methods("GET")
+    if volumeDetector != nil {
+        router.HandleFunc("/logs/anomalies", apiHandler.HandleVolumeAnomalies(volumeDetector)).Methods("GET")
+    }
+    if patternMiner != nil {
+        router.HandleFunc("/logs/patterns", apiHandler.HandleLogPatterns(patternMiner)).Methods("GET")
+    }
+    router.HandleFunc("/admin/logs/delete", apiHandler.HandleBulkDelete(bulkDeleter)).Methods("POST")
+    router.HandleFunc("/admin/logs/delete/{id}", apiHandler.HandleBulkDeleteStatus(bulkDeleter)).Methods("GET")
+    if deadLetterQueue != nil {
+        router.HandleFunc("/admin/dead-letters", apiHandler.HandleListDeadLetters(deadLetterQueue)).Methods("GET")
+        router.HandleFunc("/admin/dead-letters/replay", apiHandler.HandleReplayDeadLetters(deadLetterQueue)).Methods("POST")
+        router.HandleFunc("/admin/dead-letters/{id}", apiHandler.HandleGetDeadLetter(deadLetterQueue)).Methods("GET")
+        router.HandleFunc("/admin/dead-letters/{id}", apiHandler.HandleDeleteDeadLetter(deadLetterQueue)).Methods("DELETE")
+        router.HandleFunc("/admin/dead-letters/{id}/replay", apiHandler.HandleReplayDeadLetter(deadLetterQueue)).Methods("POST")
+    }
+    if archiveReplayer != nil {
+        router.HandleFunc("/admin/archive/replay", apiHandler.HandleArchiveReplay(archiveReplayer)).Methods("POST")
+        router.HandleFunc("/admin/archive/replay/{id}", apiHandler.HandleArchiveReplayStatus(archiveReplayer)).Methods("GET")
+    }
+    if authenticator != nil && cfg.Auth.Enabled {
+        router.HandleFunc("/admin/api-keys", apiHandler.HandleListAPIKeys(authenticator)).Methods("GET")
+        router.HandleFunc("/admin/api-keys", apiHandler.HandleCreateAPIKey(authenticator)).Methods("POST")
+        router.HandleFunc("/admin/api-keys/{id}", apiHandler.HandleRevokeAPIKey(authenticator)).Methods("DELETE")
+    }
+    if auditRecorder != nil {
+        router.HandleFunc("/admin/audit", apiHandler.HandleListAuditEvents(auditRecorder)).Methods("GET")
+    }
+    router.HandleFunc("/admin/integrity/verify", apiHandler.HandleIntegrityVerify).Methods("GET")
+    router.HandleFunc("/admin/log-level", apiHandler.HandleGetLogLevel).Methods("GET")
+    router.HandleFunc("/admin/log-level", apiHandler.HandleSetLogLevel).Methods("PUT")
+    router.HandleFunc("/admin/config", apiHandler.HandleGetConfig(cfg)).Methods("GET")
+    router.HandleFunc("/admin/features", apiHandler.HandleListFeatures).Methods("GET")
+    // Runtime actions on the running instance
+    router.HandleFunc("/admin/flush", apiHandler.HandleFlush).Methods("POST")
+    router.HandleFunc("/admin/queues", apiHandler.HandleQueueDepth).Methods("GET")
+    router.HandleFunc("/admin/rate-limits", apiHandler.HandleRateLimits(loggingMiddleware)).Methods("GET")
+    router.HandleFunc("/admin/reload", apiHandler.HandleReload(reloadConfig)).Methods("POST")
+    router.HandleFunc("/admin/maintenance", apiHandler.HandleGetMaintenance(maintenanceSwitch)).Methods("GET")
+    router.HandleFunc("/admin/maintenance", apiHandler.HandleSetMaintenance(maintenanceSwitch)).Methods("PUT")
+    if rollupJob != nil {
+        router.HandleFunc("/admin/retention/run", apiHandler.HandleRetentionRun(rollupJob)).Methods("POST")
+    }
+    // Runtime profiles, behind the admin credentials unless they have a
+    // listener of their own
+    var profilingServer *http.Server
+    if cfg.Profiling.Enabled {
+        profiling.Configure(profiling.Config{
+            BlockProfileRate:     cfg.Profiling.BlockProfileRate,
+            MutexProfileFraction: cfg.Profiling.MutexProfileFraction,
+        })
+        if cfg.Profiling.Addr != "" {
+            profilingServer = profiling.NewServer(cfg.Profiling.Addr)
+        } else {
+            router.PathPrefix("/admin/debug/pprof/").Handler(http.StripPrefix("/admin", profiling.Handler())).Methods("GET", "POST")
+            if authenticator == nil {
+                appLogger.Warn("Profiling endpoints are served without authentication; set an admin credential or PPROF_ADDR")
+            }
+        }
+    }
+    router.Handle("/metrics", promhttp.Handler()).Methods("GET")
+    router.HandleFunc("/health", apiHandler.HandleHealthCheck).Methods("GET")
+    router.HandleFunc("/healthz", apiHandler.HandleHealthCheck).Methods("GET")
+    router.HandleFunc("/health/details", health.Handler(healthChecker)).Methods("GET")
+    // 503 until the startup checks pass and again once shutting down, for
+    // readiness probes
+    readiness := health.NewReadiness()
+    router.Handle("/readyz", readiness).Methods("GET")
 
     // Create HTTP server
     serverAddr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
+    // The scanner detector wraps the router so that probes of paths it
+    // does not serve are seen too; the client IP is resolved first, for
+    // the middleware to log and rate limit by
+    var handler http.Handler = router
+    if cfg.Scanner.Enabled {
+        scannerDetector := middleware.NewScannerDetector(appLogger.WithComponent("security"), middleware.ScannerConfig{
+            Paths:         cfg.Scanner.Paths,
+            UserAgents:    cfg.Scanner.UserAgents,
+            NotFoundLimit: cfg.Scanner.NotFoundLimit,
+            Window:        cfg.Scanner.Window,
+            Block:         cfg.Scanner.Block,
+            BlockFor:      cfg.Scanner.BlockFor,
+        })
+        handler = scannerDetector.Handler(handler)
+    }
+    handler = middleware.RealIP(trustedProxies)(handler)
+
     server := &http.Server{
-        Addr:         serverAddr,
-        Handler:      router,
-        ReadTimeout:  15 * time.Second,
-        WriteTimeout: 15 * time.Second,
-        IdleTimeout:  60 * time.Second,
+        Addr:              serverAddr,
+        Handler:           handler,
+        ReadTimeout:       cfg.Server.ReadTimeout,
+        ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+        WriteTimeout:      cfg.Server.WriteTimeout,
+        IdleTimeout:       cfg.Server.IdleTimeout,
+        MaxHeaderBytes:    int(cfg.Server.MaxHeaderBytes),
+    }
+    server.SetKeepAlivesEnabled(cfg.Server.KeepAlive)
+    // Live tail streams only end when their client leaves, so end them
+    // when shutting down instead of letting Shutdown wait for them
+    server.RegisterOnShutdown(logHub.Close)
+    if !cfg.Server.HTTP2 {
+        // A non-nil, empty map keeps net/http from offering h2 over TLS
+        server.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+    }
+    useTLS := cfg.Server.TLSCertFile != "" || cfg.Server.TLSKeyFile != ""
+    if useTLS {
+        tlsConfig, err := auth.NewServerTLSConfig(auth.TLSConfig{
+            CertFile:           cfg.Server.TLSCertFile,
+            KeyFile:            cfg.Server.TLSKeyFile,
+            ClientCAFile:       cfg.Server.TLSClientCAFile,
+            ClientCertOptional: cfg.Server.TLSClientCertOptional,
+        })
+        if err != nil {
+            appLogger.WithError(err).Fatal("Failed to configure TLS")
+        }
+        server.TLSConfig = tlsConfig
     }
 
     // Start server in a goroutine
     go func() {
         appLogger.WithFields(map[string]interface{}{
-            "address": serverAddr,
-            "env":     os.Getenv("ENVIRONMENT"),
+            "address":     serverAddr,
+            "tls":         useTLS,
+            "client_auth": cfg.Server.TLSClientCAFile != "",
+            "http2":       useTLS && cfg.Server.HTTP2,
+            "keep_alive":  cfg.Server.KeepAlive,
         }).Info("Starting log ingestion service")
 
-        if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+        // net.ListenConfig disables TCP keep-alive probes with a negative
+        // interval and uses its own default for zero
+        tcpKeepAlive := cfg.Server.TCPKeepAlive
+        if tcpKeepAlive == 0 {
+            tcpKeepAlive = -1
+        }
+        listenConfig := net.ListenConfig{KeepAlive: tcpKeepAlive}
+        listener, err := listenConfig.Listen(context.Background(), "tcp", serverAddr)
+        if err != nil {
             appLogger.WithError(err).Fatal("Could not start server")
         }
+
+        // The certificate and key are already loaded into server.TLSConfig
+        serve := func() error { return server.Serve(listener) }
+        if useTLS {
+            serve = func() error { return server.ServeTLS(listener, "", "") }
+        }
+        if err := serve(); err != nil && err != http.ErrServerClosed {
+            appLogger.WithError(err).Fatal("Could not start server")
+        }
+    }()
+
+    // Ready once the database answers and the migrations this version
+    // needs are applied; until then /readyz answers 503 and, with
+    // Type=notify, systemd keeps the unit activating
+    startupChecker := health.NewChecker(cfg.Health.CheckTimeout, 0)
+    startupChecker.Add("postgres", true, database.PingContext)
+    startupChecker.Add("schema", true, database.CheckSchema)
+    go awaitReady(ctx, readiness, startupChecker, cfg.Health.ReadinessInterval, appLogger)
+    go sdnotify.RunWatchdog(ctx)
+
+    if profilingServer != nil {
+        go func() {
+            appLogger.WithField("address", cfg.Profiling.Addr).Info("Starting profiling server")
+            if err := profilingServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+                appLogger.WithError(err).Error("Profiling server failed")
+            }
+        }()
+    }
+
+    // SIGHUP, like POST /admin/reload, re-reads the .env and config files
+    // and resets log levels to LOG_LEVEL and LOG_LEVELS without a restart
+    reload := make(chan os.Signal, 1)
+    signal.Notify(reload, syscall.SIGHUP)
+    go func() {
+        for range reload {
+            if err := reloadConfig(); err != nil {
+                appLogger.WithError(err).Warn("Failed to reload configuration")
+            }
+            level, components := logger.Levels()
+            appLogger.WithFields(map[string]interface{}{
+                "level":      level,
+                "components": components,
+            }).Warn("Log levels reloaded")
+            if auditRecorder != nil {
+                auditRecorder.Record(ctx, models.AuditEvent{
+                    ActorType: models.ActorSystem,
+                    Actor:     "SIGHUP",
+                    Action:    "config.reload",
+                    Details:   models.Fields{"level": level.String(), "components": components},
+                })
+            }
+        }
     }()
 
     // Wait for interrupt signal to gracefully shutdown the server
@@ -92,15 +722,159 @@ func main() {
     <-quit
 
     appLogger.Info("Shutting down server...")
+    readiness.Stop()
+    sdnotify.Notify(sdnotify.Stopping, sdnotify.Status("Shutting down"))
 
-    // Create context with timeout for graceful shutdown
-    shutdownCtx, shutdownCancel := context.WithTimeout(ctx, 30*time.Second)
+    // Drain in order within SHUTDOWN_TIMEOUT: stop accepting requests,
+    // finish and store what was already accepted, and only then close the
+    // database, so accepted logs are not dropped
+    shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
     defer shutdownCancel()
+    drain := shutdown.New(appLogger.WithComponent("shutdown"))
+    // Waits for requests in flight, including their writes
+    drain.Add("http server", server.Shutdown)
+    if profilingServer != nil {
+        // Profiles being recorded are of no use any more
+        drain.AddFunc("profiling server", func() { profilingServer.Close() })
+    }
+    if archiveReplayer != nil {
+        drain.Add("archive replays", archiveReplayer.Stop)
+    }
+    // Stops bulk deletes in the batch they are deleting
+    drain.Add("bulk deletes", bulkDeleter.Stop)
+    // Stops rollups, stats refreshes and detectors, and the pipeline's
+    // timed flushes, waiting for one in progress
+    drain.Add("background jobs", func(ctx context.Context) error {
+        cancel()
+        if logPipeline == nil || !logPipeline.HasFlushers() {
+            return nil
+        }
+        select {
+        case <-pipelineDone:
+            return nil
+        case <-ctx.Done():
+            return ctx.Err()
+        }
+    })
+    if logPipeline != nil {
+        drain.Add("pipeline", func(ctx context.Context) error {
+            // Store entries still held back by pipeline stages
+            if logPipeline.HasFlushers() {
+                if entries := logPipeline.Flush(ctx, time.Now(), true); len(entries) > 0 {
+                    apiHandler.StoreFlushedLogs(ctx, entries)
+                }
+            }
+            // Send anything still buffered by output sinks
+            logPipeline.Close()
+            return nil
+        })
+    }
+    if publisher != nil {
+        // Sends the messages still batched
+        drain.Add("queue publisher", func(ctx context.Context) error {
+            return publisher.Close()
+        })
+    }
+    if writeQueue != nil {
+        // Stores the entries accepted before the server stopped, keeping a
+        // third of the deadline however long the steps before it take
+        drain.AddReserved("write queue", cfg.Server.ShutdownTimeout/3, writeQueue.Close)
+    }
+    if queryCache != nil {
+        drain.Add("query cache", func(ctx context.Context) error {
+            return queryCache.Close()
+        })
+    }
+    // Entries not stored yet stay buffered for the next start
+    drain.Add("maintenance buffer", maintenanceSwitch.Close)
+    drain.AddFunc("database", database.Close)
+    // Sends the spans of the work drained above
+    drain.AddFunc("trace export", tracing.Flush)
+    drain.Run(shutdownCtx)
+}
 
-    // Shutdown server
-    if err := server.Shutdown(shutdownCtx); err != nil {
-        appLogger.WithError(err).Error("Server forced to shutdown")
-    } else {
-        appLogger.Info("Server shutdown completed")
+// awaitReady retries the startup checks until they pass, then marks the
+// service ready and tells systemd, which is waiting for it with
+// Type=notify
+func awaitReady(ctx context.Context, readiness *health.Readiness, checker *health.Checker, interval time.Duration, appLogger *logger.Logger) {
+    sdnotify.Notify(sdnotify.Status("Waiting for the database"))
+    err := readiness.Await(ctx, checker, interval, func(report health.Report) {
+        for _, result := range report.Checks {
+            if result.Status == health.StatusDown {
+                appLogger.WithFields(map[string]interface{}{
+                    "check": result.Name,
+                    "error": result.Error,
+                }).Warn("Not ready yet")
+            }
+        }
+    })
+    if err != nil {
+        return
+    }
+
+    appLogger.Info("Service ready")
+    if _, err := sdnotify.Notify(sdnotify.Ready, sdnotify.Status("Serving")); err != nil {
+        appLogger.WithError(err).Warn("Failed to notify systemd")
+    }
+}
+
+// runStatus asks the instance this configuration describes, or the one at
+// -url, for /healthz and, unless -live, /readyz. It returns 0 when both
+// answer 2xx, 1 when not and 2 on invalid usage.
+func runStatus(args []string) int {
+    fs := flag.NewFlagSet("log-ingestion status", flag.ContinueOnError)
+    url := fs.String("url", "", "base URL of the instance; by default the configured port on the loopback address")
+    timeout := fs.Duration("timeout", 5*time.Second, "timeout of the whole check")
+    live := fs.Bool("live", false, "only check liveness (/healthz), not readiness (/readyz)")
+    quiet := fs.Bool("quiet", false, "print nothing")
+    fs.Usage = func() {
+        fmt.Fprintf(fs.Output(), "Usage: log-ingestion status [flags]\n\nExits 0 when the service is healthy and ready, 1 when not.\n\nFlags:\n")
+        fs.PrintDefaults()
+    }
+    if err := fs.Parse(args); err != nil {
+        if err == flag.ErrHelp {
+            return 0
+        }
+        return 2
+    }
+
+    client := &http.Client{Timeout: *timeout}
+    if *url == "" {
+        cfg, err := config.LoadConfig()
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "status: %v\n", err)
+            return 1
+        }
+        host := cfg.Server.Host
+        if host == "" || host == "0.0.0.0" || host == "::" {
+            host = "127.0.0.1"
+        }
+        scheme := "http"
+        if cfg.Server.TLSCertFile != "" {
+            // The certificate names the service, not the loopback address
+            scheme = "https"
+            client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+        }
+        *url = fmt.Sprintf("%s://%s:%d", scheme, host, cfg.Server.Port)
+    }
+
+    paths := []string{"/healthz", "/readyz"}
+    if *live {
+        paths = paths[:1]
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+    defer cancel()
+    statuses, err := health.Probe(ctx, client, *url, paths...)
+    if !*quiet {
+        for i, status := range statuses {
+            fmt.Printf("%s %s\n", paths[i], status)
+        }
+    }
+    if err != nil {
+        if !*quiet {
+            fmt.Fprintf(os.Stderr, "status: %v\n", err)
+        }
+        return 1
     }
-}
\ No newline at end of file
+    return 0
+}
@@ -0,0 +1,411 @@
+// Package maintenance puts ingestion into maintenance mode while the
+// database is migrated or failed over. Submissions are then either kept in
+// a disk buffer and stored once maintenance ends, or refused with 503 and a
+// Retry-After so clients send them again later.
+package maintenance
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Modes
+const (
+	Buffer = "buffer" // entries are written to the disk buffer
+	Reject = "reject" // submissions are refused with 503
+)
+
+var (
+	enabledGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "maintenance_enabled",
+		Help: "1 while ingestion is in maintenance mode",
+	})
+	bufferedGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "maintenance_buffered_entries",
+		Help: "Log entries in the maintenance buffer waiting to be stored",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(enabledGauge, bufferedGauge)
+}
+
+// Config configures maintenance mode; zero values fall back to defaults
+type Config struct {
+	Mode       string        // mode when enabled without one, Buffer by default
+	BufferFile string        // JSON lines file entries are buffered in
+	MaxBytes   int64         // buffer size beyond which submissions are refused; 0 for no limit
+	RetryAfter time.Duration // Retry-After of refused submissions, 30s by default
+}
+
+// StoreFunc stores a buffered entry once maintenance ends
+type StoreFunc func(ctx context.Context, entry *models.Log) error
+
+// State is the maintenance state as the admin API reports it
+type State struct {
+	Enabled     bool       `json:"enabled"`
+	Mode        string     `json:"mode"`
+	Reason      string     `json:"reason,omitempty"`
+	Since       *time.Time `json:"since,omitempty"`
+	Buffered    int        `json:"buffered"`
+	BufferBytes int64      `json:"buffer_bytes"`
+	Draining    bool       `json:"draining"`
+	LastError   string     `json:"last_error,omitempty"`
+
+	// The buffer size beyond which submissions are refused, and whether it
+	// was reached
+	BufferMaxBytes int64 `json:"buffer_max_bytes,omitempty"`
+	BufferFull     bool  `json:"buffer_full"`
+}
+
+// Switch turns maintenance mode on and off. Disabling it stores the
+// buffered entries in the background, oldest first; an entry that fails
+// stops the drain and stays buffered with the ones after it, for the next
+// one. A nil Switch is never in maintenance.
+type Switch struct {
+	cfg   Config
+	store StoreFunc
+	log   *logger.Logger
+
+	mu        sync.Mutex
+	enabled   bool
+	mode      string
+	reason    string
+	since     time.Time
+	buffered  int
+	size      int64
+	lastError string
+
+	cancelDrain context.CancelFunc // set while draining
+	drained     chan struct{}      // closed when the drain in progress ends
+}
+
+// New creates a switch that is off, storing buffered entries with store.
+// Entries left in the buffer by an earlier run are counted; Drain stores
+// them.
+func New(cfg Config, store StoreFunc, log *logger.Logger) (*Switch, error) {
+	if cfg.Mode == "" {
+		cfg.Mode = Buffer
+	}
+	if cfg.Mode != Buffer && cfg.Mode != Reject {
+		return nil, fmt.Errorf("unknown maintenance mode %q", cfg.Mode)
+	}
+	if cfg.RetryAfter <= 0 {
+		cfg.RetryAfter = 30 * time.Second
+	}
+	s := &Switch{cfg: cfg, store: store, log: log, mode: cfg.Mode}
+
+	f, err := os.Open(cfg.BufferFile)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.buffered++
+		s.size += int64(len(line))
+	}
+	bufferedGauge.Set(float64(s.buffered))
+	return s, nil
+}
+
+// Enable turns maintenance on in mode, the configured one when empty. A
+// drain in progress stops after the entry it is storing.
+func (s *Switch) Enable(mode, reason string) (State, error) {
+	if mode == "" {
+		mode = s.cfg.Mode
+	}
+	if mode != Buffer && mode != Reject {
+		return State{}, fmt.Errorf("unknown maintenance mode %q, expected %s or %s", mode, Buffer, Reject)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.enabled {
+		s.since = time.Now().UTC()
+	}
+	s.enabled, s.mode, s.reason = true, mode, reason
+	enabledGauge.Set(1)
+	return s.state(), nil
+}
+
+// Disable turns maintenance off and starts storing the buffered entries
+func (s *Switch) Disable() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enabled, s.reason = false, ""
+	enabledGauge.Set(0)
+	s.startDrain()
+	return s.state()
+}
+
+// State returns the current state
+func (s *Switch) State() State {
+	if s == nil {
+		return State{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state()
+}
+
+func (s *Switch) state() State {
+	state := State{
+		Enabled:     s.enabled,
+		Mode:        s.mode,
+		Reason:      s.reason,
+		Buffered:    s.buffered,
+		BufferBytes: s.size,
+		Draining:    s.cancelDrain != nil,
+		LastError:   s.lastError,
+
+		BufferMaxBytes: s.cfg.MaxBytes,
+		BufferFull:     s.full(),
+	}
+	if s.enabled {
+		since := s.since
+		state.Since = &since
+	}
+	return state
+}
+
+// Rejecting reports whether submissions are refused, with the Retry-After
+// to send: in reject mode, and in buffer mode once the buffer is full
+func (s *Switch) Rejecting() (time.Duration, bool) {
+	if s == nil {
+		return 0, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.enabled {
+		return 0, false
+	}
+	return s.cfg.RetryAfter, s.mode == Reject || s.full()
+}
+
+// full reports whether the buffer reached its size limit; s.mu must be held
+func (s *Switch) full() bool {
+	return s.cfg.MaxBytes > 0 && s.size >= s.cfg.MaxBytes
+}
+
+// Buffering reports whether entries go to the buffer instead of storage
+func (s *Switch) Buffering() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enabled && s.mode == Buffer
+}
+
+// Add writes an entry to the buffer while in buffer mode, returning false
+// when not buffering so the caller stores it as usual
+func (s *Switch) Add(entry *models.Log) (bool, error) {
+	if s == nil {
+		return false, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.enabled || s.mode != Buffer {
+		return false, nil
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return true, err
+	}
+	data = append(data, '\n')
+	f, err := os.OpenFile(s.cfg.BufferFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return true, err
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		return true, err
+	}
+	if err := f.Sync(); err != nil {
+		return true, err
+	}
+
+	s.buffered++
+	s.size += int64(len(data))
+	bufferedGauge.Set(float64(s.buffered))
+	return true, nil
+}
+
+// Drain starts storing the buffered entries in the background, unless
+// maintenance is on or they are being stored already
+func (s *Switch) Drain() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.startDrain()
+}
+
+// startDrain starts a drain; s.mu must be held
+func (s *Switch) startDrain() {
+	if s.enabled || s.cancelDrain != nil || s.buffered == 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancelDrain, s.drained = cancel, make(chan struct{})
+	go s.drain(ctx, s.drained)
+}
+
+// Close stops a drain in progress and waits for it, keeping the entries not
+// stored yet for the next run
+func (s *Switch) Close(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	cancel, drained := s.cancelDrain, s.drained
+	s.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Switch) drain(ctx context.Context, drained chan struct{}) {
+	defer close(drained)
+	start := time.Now()
+
+	stored, handled, offset, storeErr := s.storeBuffered(ctx)
+	stopped := ctx.Err() != nil
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cancelDrain()
+	s.cancelDrain = nil
+	// Entries buffered during the drain are after offset, and are kept
+	if err := s.compact(offset); err != nil {
+		s.log.WithError(err).Error("Failed to remove stored entries from the maintenance buffer; they will be stored again")
+		s.lastError = err.Error()
+		return
+	}
+	s.buffered -= handled
+	s.size -= offset
+	bufferedGauge.Set(float64(s.buffered))
+
+	fields := map[string]interface{}{
+		"stored":      stored,
+		"remaining":   s.buffered,
+		"duration_ms": time.Since(start).Milliseconds(),
+	}
+	if storeErr != nil && !stopped {
+		s.lastError = storeErr.Error()
+		s.log.WithFields(fields).WithError(storeErr).Error("Failed to store buffered entries; the rest stay buffered")
+		return
+	}
+	s.lastError = ""
+	s.log.WithFields(fields).Info("Maintenance buffer drained")
+}
+
+// storeBuffered stores entries from the start of the buffer until one
+// fails, maintenance is turned on again or ctx is done. It returns the
+// entries stored, the entries handled including unreadable ones that were
+// skipped, and the offset after the last one handled.
+func (s *Switch) storeBuffered(ctx context.Context) (int, int, int64, error) {
+	f, err := os.Open(s.cfg.BufferFile)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	stored, handled := 0, 0
+	var offset int64
+	for ctx.Err() == nil && !s.State().Enabled {
+		// A line without its newline is still being written
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return stored, handled, offset, nil
+		}
+		if err != nil {
+			return stored, handled, offset, err
+		}
+
+		var entry models.Log
+		if err := json.Unmarshal(line, &entry); err != nil {
+			s.log.WithError(err).WithField("offset", offset).Warn("Skipping unreadable entry in the maintenance buffer")
+		} else if err := s.store(ctx, &entry); err != nil {
+			return stored, handled, offset, err
+		} else {
+			stored++
+		}
+		handled++
+		offset += int64(len(line))
+	}
+	return stored, handled, offset, ctx.Err()
+}
+
+// compact removes the first offset bytes of the buffer through a temporary
+// file; s.mu must be held so no entry is appended meanwhile
+func (s *Switch) compact(offset int64) error {
+	if offset == 0 {
+		return nil
+	}
+	if offset == s.size {
+		return os.Remove(s.cfg.BufferFile)
+	}
+
+	src, err := os.Open(s.cfg.BufferFile)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if _, err := src.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.cfg.BufferFile), filepath.Base(s.cfg.BufferFile)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.cfg.BufferFile)
+}
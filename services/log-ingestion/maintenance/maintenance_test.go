@@ -0,0 +1,167 @@
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+// recorder stores entries in memory, failing the one with failOn as its
+// message
+type recorder struct {
+	mu     sync.Mutex
+	stored []string
+	failOn string
+}
+
+func (r *recorder) store(ctx context.Context, entry *models.Log) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if entry.Message == r.failOn {
+		return errors.New("connection refused")
+	}
+	r.stored = append(r.stored, entry.Message)
+	return nil
+}
+
+func (r *recorder) messages() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.stored...)
+}
+
+func newSwitch(t *testing.T, cfg Config, rec *recorder) *Switch {
+	t.Helper()
+	if cfg.BufferFile == "" {
+		cfg.BufferFile = filepath.Join(t.TempDir(), "buffer.jsonl")
+	}
+	s, err := New(cfg, rec.store, logger.New(logger.Config{Service: "test-service", Component: "maintenance"}))
+	if err != nil {
+		t.Fatalf("Failed to create switch: %v", err)
+	}
+	return s
+}
+
+func buffer(t *testing.T, s *Switch, messages ...string) {
+	t.Helper()
+	for _, message := range messages {
+		if buffered, err := s.Add(&models.Log{Message: message, Level: "info", Source: "test"}); !buffered || err != nil {
+			t.Fatalf("Expected %q buffered, got %v, %v", message, buffered, err)
+		}
+	}
+}
+
+// waitDrained waits for the drain in progress to end
+func waitDrained(t *testing.T, s *Switch) State {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s.mu.Lock()
+	drained := s.drained
+	s.mu.Unlock()
+	if drained != nil {
+		select {
+		case <-drained:
+		case <-ctx.Done():
+			t.Fatal("Drain did not finish")
+		}
+	}
+	return s.State()
+}
+
+func TestSwitch_BufferAndDrain(t *testing.T) {
+	rec := &recorder{}
+	s := newSwitch(t, Config{}, rec)
+
+	if buffered, _ := s.Add(&models.Log{Message: "before"}); buffered {
+		t.Fatal("Expected entries stored as usual outside maintenance")
+	}
+	if _, err := s.Enable("", "failover"); err != nil {
+		t.Fatalf("Enable failed: %v", err)
+	}
+	if _, rejecting := s.Rejecting(); rejecting || !s.Buffering() {
+		t.Fatal("Expected buffer mode by default")
+	}
+	buffer(t, s, "first", "second", "third")
+
+	state := s.Disable()
+	if state.Enabled || state.Buffered != 3 {
+		t.Errorf("Expected maintenance off with 3 entries to drain, got %+v", state)
+	}
+	state = waitDrained(t, s)
+	if got := rec.messages(); len(got) != 3 || got[0] != "first" || got[2] != "third" {
+		t.Errorf("Expected the entries stored in order, got %v", got)
+	}
+	if state.Buffered != 0 || state.BufferBytes != 0 || state.Draining {
+		t.Errorf("Expected an empty buffer, got %+v", state)
+	}
+}
+
+func TestSwitch_DrainFailureKeepsRest(t *testing.T) {
+	rec := &recorder{failOn: "second"}
+	s := newSwitch(t, Config{}, rec)
+	s.Enable(Buffer, "")
+	buffer(t, s, "first", "second", "third")
+
+	s.Disable()
+	state := waitDrained(t, s)
+	if got := rec.messages(); len(got) != 1 || got[0] != "first" {
+		t.Errorf("Expected only the entry before the failure stored, got %v", got)
+	}
+	if state.Buffered != 2 || state.LastError == "" {
+		t.Errorf("Expected 2 entries kept with the error, got %+v", state)
+	}
+
+	// A later run finds them and stores them
+	rec.failOn = ""
+	restarted := newSwitch(t, Config{BufferFile: s.cfg.BufferFile}, rec)
+	if state := restarted.State(); state.Buffered != 2 {
+		t.Fatalf("Expected the 2 entries found on start, got %+v", state)
+	}
+	restarted.Drain()
+	waitDrained(t, restarted)
+	if got := rec.messages(); len(got) != 3 || got[1] != "second" || got[2] != "third" {
+		t.Errorf("Expected the remaining entries stored in order, got %v", got)
+	}
+}
+
+func TestSwitch_Reject(t *testing.T) {
+	rec := &recorder{}
+	s := newSwitch(t, Config{RetryAfter: time.Minute, MaxBytes: 1}, rec)
+
+	if _, err := s.Enable("drop", ""); err == nil {
+		t.Error("Expected an unknown mode refused")
+	}
+	s.Enable(Reject, "migration")
+	if retryAfter, rejecting := s.Rejecting(); !rejecting || retryAfter != time.Minute {
+		t.Errorf("Expected submissions refused with a Retry-After of 1m, got %v, %v", rejecting, retryAfter)
+	}
+	if buffered, _ := s.Add(&models.Log{Message: "refused"}); buffered {
+		t.Error("Expected nothing buffered in reject mode")
+	}
+
+	// A full buffer refuses submissions too
+	s.Enable(Buffer, "")
+	if _, rejecting := s.Rejecting(); rejecting {
+		t.Error("Expected submissions buffered while the buffer has room")
+	}
+	buffer(t, s, "fills the buffer")
+	if _, rejecting := s.Rejecting(); !rejecting {
+		t.Error("Expected submissions refused once the buffer is full")
+	}
+}
+
+func TestSwitch_Nil(t *testing.T) {
+	var s *Switch
+	if _, rejecting := s.Rejecting(); rejecting || s.Buffering() {
+		t.Error("Expected a nil switch never in maintenance")
+	}
+	if buffered, err := s.Add(&models.Log{}); buffered || err != nil {
+		t.Errorf("Expected nothing buffered, got %v, %v", buffered, err)
+	}
+}
@@ -0,0 +1,138 @@
+// Package metrics exposes Prometheus metrics of the ingestion path itself:
+// how many entries each source sends, how long an entry takes from being
+// received to being stored, how large batches are and how long entries wait
+// in the queue. The ingestion service and the log-processor record them the
+// same way, so the metrics of both are read together.
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MaxSources caps the distinct source labels; entries of further sources are
+// counted under OtherSource so a client inventing sources cannot grow the
+// metrics without bound
+const (
+	MaxSources  = 200
+	OtherSource = "other"
+)
+
+var (
+	accepted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ingest_entries_accepted_total",
+		Help: "Log entries accepted for ingestion, by source",
+	}, []string{"source"})
+
+	stored = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ingest_entries_stored_total",
+		Help: "Log entries stored in the database, by source",
+	}, []string{"source"})
+
+	latency = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "ingest_latency_seconds",
+		Help:    "Time from receiving a log entry to storing it, including the time spent queued",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	})
+
+	batchSize = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "ingest_batch_size",
+		Help:    "Log entries per batch ingestion request",
+		Buckets: []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000},
+	})
+
+	queueWait = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "ingest_queue_wait_seconds",
+		Help:    "Time log entries spent in the queue before the log-processor read them",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	})
+
+	saturated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ingest_saturated_requests_total",
+		Help: "Ingestion requests refused because a buffer was full, by the buffer",
+	}, []string{"resource"})
+)
+
+func init() {
+	prometheus.MustRegister(accepted, stored, latency, batchSize, queueWait, saturated)
+}
+
+var (
+	sourcesMu sync.Mutex
+	sources   = make(map[string]bool)
+)
+
+// sourceLabel returns the label to count a source under
+func sourceLabel(source string) string {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	if sources[source] {
+		return source
+	}
+	if len(sources) >= MaxSources {
+		return OtherSource
+	}
+	sources[source] = true
+	return source
+}
+
+type receivedKey struct{}
+
+// WithReceived records in ctx when the entries handled with it were
+// received, so that storing them observes the ingest latency
+func WithReceived(ctx context.Context, t time.Time) context.Context {
+	if t.IsZero() {
+		return ctx
+	}
+	return context.WithValue(ctx, receivedKey{}, t)
+}
+
+// Received returns the receive time WithReceived recorded, or the zero time
+func Received(ctx context.Context) time.Time {
+	t, _ := ctx.Value(receivedKey{}).(time.Time)
+	return t
+}
+
+// Accepted counts an entry accepted for ingestion, after authorization and
+// throttling
+func Accepted(source string) {
+	accepted.WithLabelValues(sourceLabel(source)).Inc()
+}
+
+// Stored counts a stored entry and observes its ingest latency when ctx
+// carries the receive time. Entries stored outside of ingestion, such as
+// replays, are counted without latency.
+func Stored(ctx context.Context, source string) {
+	stored.WithLabelValues(sourceLabel(source)).Inc()
+	if received := Received(ctx); !received.IsZero() {
+		latency.Observe(time.Since(received).Seconds())
+	}
+}
+
+// Batch observes the size of a batch ingestion request
+func Batch(entries int) {
+	batchSize.Observe(float64(entries))
+}
+
+// Saturated counts a request refused because resource, such as the write
+// queue, was full
+func Saturated(resource string) {
+	saturated.WithLabelValues(resource).Inc()
+}
+
+// QueueWait observes how long a message waited in the queue, given when the
+// broker took it; an unknown time is ignored
+func QueueWait(enqueued time.Time) {
+	if enqueued.IsZero() {
+		return
+	}
+	wait := time.Since(enqueued)
+	if wait < 0 {
+		// Clocks of the broker and this host differ
+		wait = 0
+	}
+	queueWait.Observe(wait.Seconds())
+}
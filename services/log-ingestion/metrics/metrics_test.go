@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// histogram returns the sample count and sum of h
+func histogram(t *testing.T, h prometheus.Histogram) (uint64, float64) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(h)
+	families, err := registry.Gather()
+	if err != nil || len(families) != 1 {
+		t.Fatalf("Failed to gather histogram: %v", err)
+	}
+	hist := families[0].GetMetric()[0].GetHistogram()
+	return hist.GetSampleCount(), hist.GetSampleSum()
+}
+
+func TestStored(t *testing.T) {
+	storedBefore := testutil.ToFloat64(stored.WithLabelValues("stored-test"))
+	countBefore, sumBefore := histogram(t, latency)
+
+	ctx := WithReceived(context.Background(), time.Now().Add(-2*time.Second))
+	Stored(ctx, "stored-test")
+	// A replay, received by no request
+	Stored(context.Background(), "stored-test")
+
+	if n := testutil.ToFloat64(stored.WithLabelValues("stored-test")) - storedBefore; n != 2 {
+		t.Errorf("Expected 2 entries counted as stored, got %v", n)
+	}
+	count, sum := histogram(t, latency)
+	if count-countBefore != 1 || sum-sumBefore < 2 {
+		t.Errorf("Expected one latency of at least 2s observed, got %d observations summing to %v", count-countBefore, sum-sumBefore)
+	}
+}
+
+func TestAccepted_CapsSources(t *testing.T) {
+	sourcesMu.Lock()
+	previous := sources
+	sources = make(map[string]bool)
+	for i := 0; i < MaxSources-1; i++ {
+		sources[fmt.Sprintf("source-%d", i)] = true
+	}
+	sourcesMu.Unlock()
+	t.Cleanup(func() {
+		sourcesMu.Lock()
+		sources = previous
+		sourcesMu.Unlock()
+	})
+
+	otherBefore := testutil.ToFloat64(accepted.WithLabelValues(OtherSource))
+	Accepted("last-source")
+	Accepted("one-too-many")
+	Accepted("last-source")
+
+	if n := testutil.ToFloat64(accepted.WithLabelValues("last-source")); n != 2 {
+		t.Errorf("Expected the last source within the cap counted under its name, got %v", n)
+	}
+	if n := testutil.ToFloat64(accepted.WithLabelValues(OtherSource)) - otherBefore; n != 1 {
+		t.Errorf("Expected the source over the cap counted as %q, got %v", OtherSource, n)
+	}
+}
+
+func TestQueueWait(t *testing.T) {
+	countBefore, _ := histogram(t, queueWait)
+
+	QueueWait(time.Time{})
+	QueueWait(time.Now().Add(-100 * time.Millisecond))
+	// The broker's clock is ahead
+	QueueWait(time.Now().Add(time.Minute))
+
+	if count, _ := histogram(t, queueWait); count-countBefore != 2 {
+		t.Errorf("Expected 2 waits observed, got %d", count-countBefore)
+	}
+}
@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TrustedProxies are the networks of the reverse proxies and load balancers
+// whose X-Forwarded-For and X-Real-IP headers are believed
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies parses CIDR ranges and single addresses, e.g.
+// "10.0.0.0/8" or "127.0.0.1"
+func ParseTrustedProxies(values []string) (TrustedProxies, error) {
+	var proxies TrustedProxies
+	for _, value := range values {
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy address %q", value)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy range %q: %w", value, err)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// Contains reports whether ip belongs to a trusted proxy
+func (t TrustedProxies) Contains(ip net.IP) bool {
+	for _, network := range t {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns the IP of the client that made a request. Forwarding
+// headers are only read when the connection comes from a trusted proxy:
+// X-Forwarded-For is walked from the right, past the trusted proxies that
+// appended to it, to the first address that is not one; without it,
+// X-Real-IP is used. Otherwise the connection's peer is the client.
+func (t TrustedProxies) Resolve(r *http.Request) string {
+	peer := parseHostIP(r.RemoteAddr)
+	if peer == nil {
+		return r.RemoteAddr
+	}
+	if !t.Contains(peer) {
+		return peer.String()
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	if len(hops) > 0 {
+		client := peer
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := parseHostIP(strings.TrimSpace(hops[i]))
+			if ip == nil {
+				// A client may send anything; stop at the last hop a
+				// trusted proxy vouched for
+				break
+			}
+			client = ip
+			if !t.Contains(ip) {
+				break
+			}
+		}
+		return client.String()
+	}
+
+	if ip := parseHostIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+	return peer.String()
+}
+
+// parseHostIP parses an IP with or without a port, such as a RemoteAddr
+func parseHostIP(value string) net.IP {
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+	return net.ParseIP(strings.Trim(value, "[]"))
+}
+
+type clientIPKey struct{}
+
+// RealIP returns middleware resolving the client IP of each request with
+// trusted (see TrustedProxies.Resolve) for ClientIP. It goes first, so the
+// other middleware logs and limits by the resolved IP.
+func RealIP(trusted TrustedProxies) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), clientIPKey{}, trusted.Resolve(r))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// ClientIP returns the client IP RealIP resolved for a request, or the
+// IP of the connection's peer without RealIP
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	if ip := parseHostIP(r.RemoteAddr); ip != nil {
+		return ip.String()
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrustedProxies_Resolve(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1", "fd00::/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		realIP     string
+		expected   string
+	}{
+		{"direct client", "203.0.113.7:51234", nil, "", "203.0.113.7"},
+		{"untrusted peer sending headers", "203.0.113.7:51234", []string{"198.51.100.1"}, "198.51.100.2", "203.0.113.7"},
+		{"trusted proxy", "10.0.0.5:443", []string{"198.51.100.1"}, "", "198.51.100.1"},
+		{"chain of trusted proxies", "10.0.0.5:443", []string{"198.51.100.1, 10.1.1.1", "192.168.1.1"}, "", "198.51.100.1"},
+		{"spoofed leftmost entry", "10.0.0.5:443", []string{"1.2.3.4, 198.51.100.1"}, "", "198.51.100.1"},
+		{"only trusted hops", "10.0.0.5:443", []string{"10.2.2.2"}, "", "10.2.2.2"},
+		{"malformed hop", "10.0.0.5:443", []string{"198.51.100.1, garbage, 10.1.1.1"}, "", "10.1.1.1"},
+		{"hop with port", "10.0.0.5:443", []string{"[2001:db8::1]:8443"}, "", "2001:db8::1"},
+		{"real IP header", "192.168.1.1:443", nil, "198.51.100.9", "198.51.100.9"},
+		{"forwarded header wins over real IP", "10.0.0.5:443", []string{"198.51.100.1"}, "198.51.100.9", "198.51.100.1"},
+		{"invalid real IP", "10.0.0.5:443", nil, "unknown", "10.0.0.5"},
+		{"IPv6 trusted proxy", "[fd00::1]:443", []string{"2001:db8::2"}, "", "2001:db8::2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/logs", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if got := trusted.Resolve(req); got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies_Invalid(t *testing.T) {
+	for _, value := range []string{"10.0.0.0/33", "proxy.internal", ""} {
+		if _, err := ParseTrustedProxies([]string{value}); err == nil {
+			t.Errorf("Expected an error for %q", value)
+		}
+	}
+}
+
+func TestRealIP_ClientIP(t *testing.T) {
+	trusted, _ := ParseTrustedProxies([]string{"127.0.0.1"})
+	var seen string
+	handler := RealIP(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = ClientIP(r)
+	}))
+
+	req := httptest.NewRequest("GET", "/logs", nil)
+	req.RemoteAddr = "127.0.0.1:40000"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if seen != "198.51.100.1" {
+		t.Errorf("Expected the forwarded client IP, got %s", seen)
+	}
+
+	// Without RealIP, the peer's IP without its port
+	if got := ClientIP(req); got != "127.0.0.1" {
+		t.Errorf("Expected the peer IP, got %s", got)
+	}
+}
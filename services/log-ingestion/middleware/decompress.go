@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxDecompressedBody is the size a compressed request body may
+// expand to, so a small request cannot make the service inflate gigabytes
+const DefaultMaxDecompressedBody = 64 << 20
+
+// DecompressRequest decodes request bodies sent with Content-Encoding:
+// gzip, so clients can compress large batches. Reading a body that expands
+// beyond maxBytes fails; other encodings are refused with 415. The length
+// of a decoded body is unknown until it is read, so ContentLength is -1.
+func DecompressRequest(maxBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))); encoding {
+			case "", "identity":
+			case "gzip":
+				zr, err := gzip.NewReader(r.Body)
+				if err != nil {
+					http.Error(w, "Invalid gzip request body", http.StatusBadRequest)
+					return
+				}
+				r.Body = gzipBody{Reader: http.MaxBytesReader(w, io.NopCloser(zr), maxBytes), zr: zr, body: r.Body}
+				r.ContentLength = -1
+				r.Header.Del("Content-Encoding")
+				r.Header.Del("Content-Length")
+			default:
+				http.Error(w, "Unsupported Content-Encoding "+encoding+": only gzip is accepted", http.StatusUnsupportedMediaType)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// gzipBody reads a decompressed body, closing the decompressor and the
+// original body together
+type gzipBody struct {
+	io.Reader
+	zr   *gzip.Reader
+	body io.ReadCloser
+}
+
+func (b gzipBody) Close() error {
+	b.zr.Close()
+	return b.body.Close()
+}
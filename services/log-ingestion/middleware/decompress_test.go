@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func gzipped(t *testing.T, data string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(data)); err != nil {
+		t.Fatalf("Failed to compress: %v", err)
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestDecompressRequest(t *testing.T) {
+	handler := DecompressRequest(64)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("Content-Encoding") != "" || r.ContentLength != -1 && r.ContentLength != int64(len(body)) {
+			http.Error(w, "stale headers", http.StatusInternalServerError)
+			return
+		}
+		w.Write(body)
+	}))
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		status   int
+		response string
+	}{
+		{"plain", "", []byte(`{"message":"plain"}`), http.StatusOK, `{"message":"plain"}`},
+		{"gzip", "gzip", gzipped(t, `{"message":"compressed"}`), http.StatusOK, `{"message":"compressed"}`},
+		{"invalid gzip", "gzip", []byte("not gzip"), http.StatusBadRequest, "Invalid gzip"},
+		{"too large", "gzip", gzipped(t, strings.Repeat("a", 65)), http.StatusBadRequest, "Failed to read"},
+		{"unsupported", "br", []byte("..."), http.StatusUnsupportedMediaType, "only gzip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/ingest", bytes.NewReader(tt.body))
+			if tt.encoding != "" {
+				req.Header.Set("Content-Encoding", tt.encoding)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status || !strings.Contains(rec.Body.String(), tt.response) {
+				t.Errorf("Expected %d containing %q, got %d: %s", tt.status, tt.response, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
@@ -4,21 +4,61 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"runtime/debug"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"log-processing-system/services/log-ingestion/crash"
 	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/tracing"
 )
 
+// LoggingConfig tunes the logging middleware; zero values fall back to
+// defaults, except RateLimit, which a negative value turns off
+type LoggingConfig struct {
+	SlowRequest     time.Duration // requests logged as slow, 5 seconds by default
+	RateLimit       int           // requests per client within RateLimitWindow, 100 by default
+	RateLimitWindow time.Duration // one minute by default
+	Crashes         *crash.Reporter // writes a report of each recovered panic; nil only counts them
+}
+
 // LoggingMiddleware wraps HTTP handlers with structured logging
 type LoggingMiddleware struct {
 	logger *logger.Logger
+	cfg    LoggingConfig
+
+	// Rate limiter state, shared by every RateLimitMiddleware handler
+	mu            sync.Mutex
+	requestCounts map[string]int
+	lastReset     time.Time
+}
+
+// RateLimitState is a snapshot of the per-client rate limiter
+type RateLimitState struct {
+	Enabled     bool           `json:"enabled"`
+	Limit       int            `json:"limit,omitempty"`
+	Window      string         `json:"window,omitempty"`
+	WindowStart time.Time      `json:"window_start,omitempty"`
+	Clients     map[string]int `json:"clients,omitempty"` // requests per client in the current window
 }
 
 // NewLoggingMiddleware creates a new logging middleware
-func NewLoggingMiddleware(log *logger.Logger) *LoggingMiddleware {
+func NewLoggingMiddleware(log *logger.Logger, cfg LoggingConfig) *LoggingMiddleware {
+	if cfg.SlowRequest <= 0 {
+		cfg.SlowRequest = 5 * time.Second
+	}
+	if cfg.RateLimit == 0 {
+		cfg.RateLimit = 100
+	}
+	if cfg.RateLimitWindow <= 0 {
+		cfg.RateLimitWindow = time.Minute
+	}
 	return &LoggingMiddleware{
-		logger: log,
+		logger:        log,
+		cfg:           cfg,
+		requestCounts: make(map[string]int),
+		lastReset:     time.Now(),
 	}
 }
 
@@ -47,6 +87,13 @@ func (rw *responseWriter) Write(data []byte) (int, error) {
 	return n, err
 }
 
+// Flush forwards to the underlying writer so streaming handlers keep working
+func (rw *responseWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
 // Handler wraps an HTTP handler with logging
 func (lm *LoggingMiddleware) Handler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -58,27 +105,53 @@ func (lm *LoggingMiddleware) Handler(next http.Handler) http.Handler {
 			requestID = uuid.New().String()
 		}
 
-		// Add request ID to context
-		ctx := logger.WithRequestID(r.Context(), requestID)
+		// Continue the caller's W3C trace with a span for this request, or
+		// start a new trace
+		tc := newTraceContext(r.Header)
+
+		// Add request ID and trace context to context
+		ctx := withTraceContext(logger.WithRequestID(r.Context(), requestID), tc)
+		// A request shipping the system's own logs is not logged; handlers
+		// mark the request when they find such entries in the body
+		ctx = logger.WithSelfLogMark(ctx)
+		if r.Header.Get(logger.SelfSourceHeader) != "" {
+			ctx = logger.MarkSelfLog(ctx)
+		}
+		// The request's span, the parent of the spans of its database
+		// operations; recorded when tracing is enabled and the trace sampled
+		ctx, span := tracing.StartServer(ctx, r.Method+" "+routeTemplate(r), tc.TraceID, tc.SpanID, tc.ParentID, tc.Flags&0x01 != 0)
 		r = r.WithContext(ctx)
+		recordPanicContext(ctx)
 
-		// Add request ID to response headers
+		// Add request ID and trace context to response headers
 		w.Header().Set("X-Request-ID", requestID)
+		w.Header().Set(TraceparentHeader, tc.Traceparent())
+		if tc.State != "" {
+			w.Header().Set(TracestateHeader, tc.State)
+		}
 
 		// Wrap response writer
 		wrapped := newResponseWriter(w)
 
 		// Log incoming request
-		lm.logger.WithFields(map[string]interface{}{
+		fields := map[string]interface{}{
 			"http_method":      r.Method,
 			"http_path":        r.URL.Path,
 			"http_query":       r.URL.RawQuery,
 			"http_user_agent":  r.UserAgent(),
-			"http_remote_addr": r.RemoteAddr,
+			"http_remote_addr": ClientIP(r),
 			"http_host":        r.Host,
 			"request_id":       requestID,
 			"content_length":   r.ContentLength,
-		}).InfoContext(ctx, "HTTP request started")
+		}
+		if tc.ParentID != "" {
+			fields["parent_span_id"] = tc.ParentID
+		}
+		// The proxy the request came through, when it is not the client
+		if peer := parseHostIP(r.RemoteAddr); peer != nil && peer.String() != ClientIP(r) {
+			fields["http_peer_addr"] = r.RemoteAddr
+		}
+		lm.logger.WithFields(fields).InfoContext(ctx, "HTTP request started")
 
 		// Process request
 		next.ServeHTTP(wrapped, r)
@@ -86,19 +159,30 @@ func (lm *LoggingMiddleware) Handler(next http.Handler) http.Handler {
 		// Calculate duration
 		duration := time.Since(start)
 
+		span.SetAttribute("http.method", r.Method)
+		span.SetAttribute("http.route", routeTemplate(r))
+		span.SetAttribute("http.target", r.URL.RequestURI())
+		span.SetAttribute("http.status_code", wrapped.statusCode)
+		span.SetAttribute("http.response_content_length", wrapped.written)
+		var spanErr error
+		if wrapped.statusCode >= 500 {
+			spanErr = fmt.Errorf("HTTP %d %s", wrapped.statusCode, http.StatusText(wrapped.statusCode))
+		}
+		span.End(spanErr)
+
 		// Log response
 		lm.logger.WithFields(map[string]interface{}{
 			"http_method":       r.Method,
 			"http_path":         r.URL.Path,
 			"http_status_code":  wrapped.statusCode,
-			"http_remote_addr":  r.RemoteAddr,
+			"http_remote_addr":  ClientIP(r),
 			"request_id":        requestID,
 			"duration_ms":       duration.Milliseconds(),
 			"response_size":     wrapped.written,
 		}).InfoContext(ctx, "HTTP request completed")
 
 		// Log slow requests as warnings
-		if duration > 5*time.Second {
+		if duration > lm.cfg.SlowRequest {
 			lm.logger.WithFields(map[string]interface{}{
 				"http_method":      r.Method,
 				"http_path":        r.URL.Path,
@@ -134,7 +218,7 @@ func (lm *LoggingMiddleware) Handler(next http.Handler) http.Handler {
 func (lm *LoggingMiddleware) HealthCheckMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Skip detailed logging for health checks to reduce noise
-		if r.URL.Path == "/health" || r.URL.Path == "/healthz" {
+		if r.URL.Path == "/health" || r.URL.Path == "/healthz" || r.URL.Path == "/readyz" || r.URL.Path == "/health/details" {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -143,20 +227,73 @@ func (lm *LoggingMiddleware) HealthCheckMiddleware(next http.Handler) http.Handl
 	})
 }
 
-// RecoveryMiddleware provides panic recovery with structured logging
+// panicInfo is what the middlewares inside RecoveryMiddleware learn about a
+// request for the report of a panic: the context carrying its IDs, and the
+// stack of a panic recovered on a handler goroutine, which is gone once the
+// panic is re-raised on the request's goroutine
+type panicInfo struct {
+	ctx   context.Context
+	stack []byte
+}
+
+type panicInfoKey struct{}
+
+// recordPanicContext keeps ctx, which carries more of the request's IDs
+// than the context RecoveryMiddleware sees
+func recordPanicContext(ctx context.Context) {
+	if info, ok := ctx.Value(panicInfoKey{}).(*panicInfo); ok {
+		info.ctx = ctx
+	}
+}
+
+// recordPanicStack keeps the stack of a panic recovered on another
+// goroutine than the request's, with the context it ran with
+func recordPanicStack(ctx context.Context) {
+	if info, ok := ctx.Value(panicInfoKey{}).(*panicInfo); ok {
+		info.ctx, info.stack = ctx, debug.Stack()
+	}
+}
+
+// RecoveryMiddleware provides panic recovery with structured logging, and
+// writes a crash report when the middleware has a crash reporter
 func (lm *LoggingMiddleware) RecoveryMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := &panicInfo{ctx: r.Context()}
+		r = r.WithContext(context.WithValue(r.Context(), panicInfoKey{}, info))
+
 		defer func() {
 			if err := recover(); err != nil {
-				requestID := logger.GetRequestID(r.Context())
-				
-				lm.logger.WithFields(map[string]interface{}{
+				ctx := info.ctx
+				requestID := logger.GetRequestID(ctx)
+				stack := info.stack
+				if stack == nil {
+					stack = debug.Stack()
+				}
+
+				fields := map[string]interface{}{
 					"http_method":      r.Method,
 					"http_path":        r.URL.Path,
-					"http_remote_addr": r.RemoteAddr,
+					"http_remote_addr": ClientIP(r),
 					"request_id":       requestID,
 					"panic":            fmt.Sprintf("%v", err),
-				}).ErrorContext(r.Context(), "HTTP handler panic recovered")
+				}
+				report, reportErr := lm.cfg.Crashes.Panic(&crash.Request{
+					Method:     r.Method,
+					Path:       r.URL.Path,
+					Route:      routeTemplate(r),
+					RemoteAddr: ClientIP(r),
+					UserAgent:  r.UserAgent(),
+					RequestID:  requestID,
+					TraceID:    logger.GetTraceID(ctx),
+					UserID:     logger.GetUserID(ctx),
+				}, err, stack)
+				if report != "" {
+					fields["crash_report"] = report
+				}
+				if reportErr != nil {
+					fields["crash_report_error"] = reportErr.Error()
+				}
+				lm.logger.WithFields(fields).ErrorContext(ctx, "HTTP handler panic recovered")
 
 				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			}
@@ -183,7 +320,7 @@ func (lm *LoggingMiddleware) CORSMiddleware(next http.Handler) http.Handler {
 		// Set CORS headers
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID, traceparent, tracestate")
 
 		// Handle preflight requests
 		if r.Method == "OPTIONS" {
@@ -216,7 +353,7 @@ func (lm *LoggingMiddleware) SecurityHeadersMiddleware(next http.Handler) http.H
 			lm.logger.WithFields(map[string]interface{}{
 				"http_method":      r.Method,
 				"http_path":        r.URL.Path,
-				"http_remote_addr": r.RemoteAddr,
+				"http_remote_addr": ClientIP(r),
 				"request_id":       logger.GetRequestID(r.Context()),
 			}).WarnContext(r.Context(), "Request with empty User-Agent detected")
 		}
@@ -227,7 +364,7 @@ func (lm *LoggingMiddleware) SecurityHeadersMiddleware(next http.Handler) http.H
 				"http_method":      r.Method,
 				"http_path":        r.URL.Path,
 				"escaped_path":     r.URL.EscapedPath(),
-				"http_remote_addr": r.RemoteAddr,
+				"http_remote_addr": ClientIP(r),
 				"request_id":       logger.GetRequestID(r.Context()),
 			}).WarnContext(r.Context(), "Request with URL encoding detected")
 		}
@@ -238,28 +375,24 @@ func (lm *LoggingMiddleware) SecurityHeadersMiddleware(next http.Handler) http.H
 
 // RateLimitMiddleware provides basic rate limiting with logging
 func (lm *LoggingMiddleware) RateLimitMiddleware(next http.Handler) http.Handler {
+	if lm.cfg.RateLimit < 0 {
+		return next
+	}
+	limit := lm.cfg.RateLimit
+
 	// Simple in-memory rate limiting (for demo purposes)
 	// In production, use Redis or similar
-	requestCounts := make(map[string]int)
-	lastReset := time.Now()
-	
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Reset counts every minute
-		if time.Since(lastReset) > time.Minute {
-			requestCounts = make(map[string]int)
-			lastReset = time.Now()
-		}
+		clientIP := ClientIP(r)
+		count := lm.countRequest(clientIP)
 
-		clientIP := r.RemoteAddr
-		requestCounts[clientIP]++
-
-		// Simple rate limit: 100 requests per minute
-		if requestCounts[clientIP] > 100 {
+		// Simple rate limit: limit requests per window
+		if count > limit {
 			lm.logger.WithFields(map[string]interface{}{
 				"http_method":      r.Method,
 				"http_path":        r.URL.Path,
-				"http_remote_addr": r.RemoteAddr,
-				"request_count":    requestCounts[clientIP],
+				"http_remote_addr": clientIP,
+				"request_count":    count,
 				"request_id":       logger.GetRequestID(r.Context()),
 			}).WarnContext(r.Context(), "Rate limit exceeded")
 
@@ -267,11 +400,11 @@ func (lm *LoggingMiddleware) RateLimitMiddleware(next http.Handler) http.Handler
 			return
 		}
 
-		// Log high request rates
-		if requestCounts[clientIP] > 50 {
+		// Log high request rates, past half the limit
+		if count > limit/2 {
 			lm.logger.WithFields(map[string]interface{}{
-				"http_remote_addr": r.RemoteAddr,
-				"request_count":    requestCounts[clientIP],
+				"http_remote_addr": clientIP,
+				"request_count":    count,
 				"request_id":       logger.GetRequestID(r.Context()),
 			}).InfoContext(r.Context(), "High request rate detected")
 		}
@@ -279,3 +412,47 @@ func (lm *LoggingMiddleware) RateLimitMiddleware(next http.Handler) http.Handler
 		next.ServeHTTP(w, r)
 	})
 }
+
+// countRequest counts a request from client and returns its count in the
+// current window
+func (lm *LoggingMiddleware) countRequest(client string) int {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	lm.resetExpired()
+	lm.requestCounts[client]++
+	return lm.requestCounts[client]
+}
+
+// resetExpired starts a new window once the current one is over; callers
+// must hold lm.mu
+func (lm *LoggingMiddleware) resetExpired() {
+	if time.Since(lm.lastReset) > lm.cfg.RateLimitWindow {
+		lm.requestCounts = make(map[string]int)
+		lm.lastReset = time.Now()
+	}
+}
+
+// RateLimitState returns the limit and the requests each client made in the
+// current window
+func (lm *LoggingMiddleware) RateLimitState() RateLimitState {
+	if lm.cfg.RateLimit < 0 {
+		return RateLimitState{}
+	}
+
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	lm.resetExpired()
+	clients := make(map[string]int, len(lm.requestCounts))
+	for client, count := range lm.requestCounts {
+		clients[client] = count
+	}
+	return RateLimitState{
+		Enabled:     true,
+		Limit:       lm.cfg.RateLimit,
+		Window:      lm.cfg.RateLimitWindow.String(),
+		WindowStart: lm.lastReset,
+		Clients:     clients,
+	}
+}
@@ -0,0 +1,258 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"log-processing-system/services/log-ingestion/logger"
+)
+
+// Security events the scanner detector logs, as the security_event field
+const (
+	EventPathProbe        = "path_probe"
+	EventScannerUserAgent = "scanner_user_agent"
+	EventNotFoundRate     = "not_found_rate"
+)
+
+// probePaths are paths vulnerability scanners try; this service serves
+// none of them. Matched as case-insensitive prefixes.
+var probePaths = []string{
+	"/wp-admin", "/wp-login.php", "/wp-content", "/wp-includes", "/xmlrpc.php",
+	"/.env", "/.git", "/.svn", "/.aws", "/.ssh", "/.ds_store",
+	"/phpmyadmin", "/pma", "/myadmin", "/phpinfo", "/admin.php", "/config.php",
+	"/cgi-bin/", "/vendor/phpunit", "/actuator", "/server-status",
+	"/manager/html", "/hnap1", "/boaform", "/solr/", "/console",
+	"/etc/passwd", "/owa/", "/autodiscover",
+}
+
+// probeSuffixes are file extensions of technologies this service does not
+// run
+var probeSuffixes = []string{".php", ".asp", ".aspx", ".jsp", ".cgi", ".bak", ".sql"}
+
+// scannerAgents are User-Agent substrings of common scanning tools, matched
+// case-insensitively
+var scannerAgents = []string{
+	"sqlmap", "nikto", "nmap", "masscan", "zgrab", "nuclei", "gobuster",
+	"dirbuster", "dirb/", "wpscan", "acunetix", "nessus", "openvas", "ffuf",
+	"feroxbuster", "whatweb", "jaeles", "censysinspect", "l9explore",
+}
+
+// ScannerConfig tunes the scanner detector; zero values fall back to
+// defaults
+type ScannerConfig struct {
+	Paths         []string      // probe path prefixes besides the built-in ones
+	UserAgents    []string      // scanner User-Agent substrings besides the built-in ones
+	NotFoundLimit int           // 404 answers per client within Window that mark it a scanner, 20 by default
+	Window        time.Duration // how long detections are counted, one minute by default
+	Block         bool          // reject every request of a detected client for BlockFor
+	BlockFor      time.Duration // 15 minutes by default
+}
+
+// scannerState is what the detector knows of a client
+type scannerState struct {
+	windowStart  time.Time
+	notFound     int
+	events       map[string]int
+	blockedUntil time.Time
+}
+
+// ScannerDetector recognizes vulnerability scanners and bots by the paths
+// they probe, their User-Agent and a high rate of 404 answers, and logs a
+// structured security event the first time a client shows each sign within
+// a window. With Block set, it rejects the client's requests for a while.
+type ScannerDetector struct {
+	cfg    ScannerConfig
+	logger *logger.Logger
+	now    func() time.Time
+
+	paths  []string
+	agents []string
+
+	mu     sync.Mutex
+	states map[string]*scannerState
+	pruned time.Time
+}
+
+// NewScannerDetector creates a detector logging to log
+func NewScannerDetector(log *logger.Logger, cfg ScannerConfig) *ScannerDetector {
+	if cfg.NotFoundLimit <= 0 {
+		cfg.NotFoundLimit = 20
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = time.Minute
+	}
+	if cfg.BlockFor <= 0 {
+		cfg.BlockFor = 15 * time.Minute
+	}
+	d := &ScannerDetector{
+		cfg:    cfg,
+		logger: log,
+		now:    time.Now,
+		paths:  append([]string(nil), probePaths...),
+		agents: append([]string(nil), scannerAgents...),
+		states: make(map[string]*scannerState),
+	}
+	for _, path := range cfg.Paths {
+		d.paths = append(d.paths, strings.ToLower(path))
+	}
+	for _, agent := range cfg.UserAgents {
+		d.agents = append(d.agents, strings.ToLower(agent))
+	}
+	return d
+}
+
+// Handler checks each request for signs of scanning. It wraps the router
+// rather than being added with Use, since probes for paths the router
+// does not serve never reach route middleware; it goes inside RealIP.
+func (d *ScannerDetector) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientIP := ClientIP(r)
+		if d.blocked(clientIP) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		if d.isProbe(r.URL.Path) {
+			d.detect(r, clientIP, EventPathProbe, nil)
+		}
+		if d.isScannerAgent(r.UserAgent()) {
+			d.detect(r, clientIP, EventScannerUserAgent, nil)
+		}
+
+		sw := NewStatusWriter(w)
+		next.ServeHTTP(sw, r)
+		if sw.Status() == http.StatusNotFound {
+			d.countNotFound(r, clientIP)
+		}
+	})
+}
+
+// isProbe reports whether path is one scanners probe for
+func (d *ScannerDetector) isProbe(path string) bool {
+	path = strings.ToLower(path)
+	if strings.Contains(path, "../") || strings.Contains(path, "..\\") {
+		return true
+	}
+	for _, prefix := range d.paths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	for _, suffix := range probeSuffixes {
+		if strings.HasSuffix(path, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isScannerAgent reports whether userAgent names a scanning tool
+func (d *ScannerDetector) isScannerAgent(userAgent string) bool {
+	if userAgent == "" {
+		return false
+	}
+	userAgent = strings.ToLower(userAgent)
+	for _, agent := range d.agents {
+		if strings.Contains(userAgent, agent) {
+			return true
+		}
+	}
+	return false
+}
+
+// state returns a client's state, starting a new window when the last one
+// has passed. The caller holds d.mu.
+func (d *ScannerDetector) state(clientIP string, now time.Time) *scannerState {
+	d.prune(now)
+	s, ok := d.states[clientIP]
+	if !ok {
+		s = &scannerState{windowStart: now, events: make(map[string]int)}
+		d.states[clientIP] = s
+	}
+	if now.Sub(s.windowStart) >= d.cfg.Window {
+		s.windowStart, s.notFound, s.events = now, 0, make(map[string]int)
+	}
+	return s
+}
+
+// prune forgets clients whose window and block have passed, at most once
+// per window. The caller holds d.mu.
+func (d *ScannerDetector) prune(now time.Time) {
+	if now.Sub(d.pruned) < d.cfg.Window {
+		return
+	}
+	d.pruned = now
+	for clientIP, s := range d.states {
+		if now.Sub(s.windowStart) >= d.cfg.Window && !now.Before(s.blockedUntil) {
+			delete(d.states, clientIP)
+		}
+	}
+}
+
+// blocked reports whether a client is blocked
+func (d *ScannerDetector) blocked(clientIP string) bool {
+	if !d.cfg.Block {
+		return false
+	}
+	now := d.now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s, ok := d.states[clientIP]
+	return ok && now.Before(s.blockedUntil)
+}
+
+// countNotFound counts a 404 answer to a client, detecting a scanner when
+// the client reaches the limit
+func (d *ScannerDetector) countNotFound(r *http.Request, clientIP string) {
+	d.mu.Lock()
+	s := d.state(clientIP, d.now())
+	s.notFound++
+	notFound := s.notFound
+	d.mu.Unlock()
+
+	if notFound >= d.cfg.NotFoundLimit {
+		d.detect(r, clientIP, EventNotFoundRate, map[string]interface{}{
+			"not_found_count": notFound,
+			"window_seconds":  d.cfg.Window.Seconds(),
+		})
+	}
+}
+
+// detect records a sign of scanning from a client, logging it the first
+// time in the window and blocking the client when blocking is on
+func (d *ScannerDetector) detect(r *http.Request, clientIP, event string, extra map[string]interface{}) {
+	now := d.now()
+	d.mu.Lock()
+	s := d.state(clientIP, now)
+	s.events[event]++
+	first := s.events[event] == 1
+	block := d.cfg.Block && !now.Before(s.blockedUntil)
+	if block {
+		s.blockedUntil = now.Add(d.cfg.BlockFor)
+	}
+	d.mu.Unlock()
+
+	if !first && !block {
+		return
+	}
+
+	fields := map[string]interface{}{
+		"security_event":   event,
+		"http_method":      r.Method,
+		"http_path":        r.URL.Path,
+		"http_user_agent":  r.UserAgent(),
+		"http_remote_addr": clientIP,
+		"request_id":       logger.GetRequestID(r.Context()),
+	}
+	for k, v := range extra {
+		fields[k] = v
+	}
+	if first {
+		d.logger.WithFields(fields).WarnContext(r.Context(), "Scanner activity detected")
+	}
+	if block {
+		fields["blocked_until"] = now.Add(d.cfg.BlockFor).UTC().Format(time.RFC3339)
+		d.logger.WithFields(fields).WarnContext(r.Context(), "Client blocked as a scanner")
+	}
+}
@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"log-processing-system/services/log-ingestion/logger"
+)
+
+// eventSink records the security events written through it
+type eventSink struct {
+	mu      sync.Mutex
+	entries []logger.LogEntry
+}
+
+func (s *eventSink) Write(entry logger.LogEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func (s *eventSink) Sync() error { return nil }
+
+func (s *eventSink) events(message string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var events []string
+	for _, entry := range s.entries {
+		if entry.Message == message {
+			events = append(events, entry.Fields["security_event"].(string))
+		}
+	}
+	return events
+}
+
+func newScannerHandler(cfg ScannerConfig, now *time.Time) (http.Handler, *eventSink) {
+	sink := &eventSink{}
+	d := NewScannerDetector(logger.New(logger.Config{Level: "INFO", Service: "test-service", Component: "security", Sink: sink}), cfg)
+	d.now = func() time.Time { return *now }
+
+	router := mux.NewRouter()
+	router.HandleFunc("/ingest", func(w http.ResponseWriter, r *http.Request) {}).Methods("POST")
+	return d.Handler(router), sink
+}
+
+func request(handler http.Handler, method, path, userAgent, remoteAddr string) int {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("User-Agent", userAgent)
+	req.RemoteAddr = remoteAddr
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestScannerDetector_Signs(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	handler, sink := newScannerHandler(ScannerConfig{Paths: []string{"/internal-probe"}}, &now)
+
+	request(handler, "GET", "/wp-admin/setup-config.php", "Mozilla/5.0", "198.51.100.1:4000")
+	request(handler, "GET", "/.env", "Mozilla/5.0", "198.51.100.1:4000")
+	request(handler, "GET", "/Internal-Probe/x", "Mozilla/5.0", "198.51.100.2:4000")
+	request(handler, "POST", "/ingest", "sqlmap/1.7.2#stable (https://sqlmap.org)", "198.51.100.3:4000")
+	request(handler, "POST", "/ingest", "log-agent/1.0", "198.51.100.4:4000")
+
+	got := sink.events("Scanner activity detected")
+	want := []string{EventPathProbe, EventPathProbe, EventScannerUserAgent}
+	if len(got) != len(want) {
+		t.Fatalf("Expected events %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Expected events %v, got %v", want, got)
+		}
+	}
+
+	// A new window logs the client's probes again
+	now = now.Add(time.Minute)
+	request(handler, "GET", "/.git/config", "Mozilla/5.0", "198.51.100.1:4000")
+	if n := len(sink.events("Scanner activity detected")); n != 4 {
+		t.Errorf("Expected the probe to be logged in the new window, got %d events", n)
+	}
+}
+
+func TestScannerDetector_NotFoundRate(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	handler, sink := newScannerHandler(ScannerConfig{NotFoundLimit: 3}, &now)
+
+	for i := 0; i < 5; i++ {
+		if status := request(handler, "GET", "/missing", "Mozilla/5.0", "198.51.100.1:4000"); status != http.StatusNotFound {
+			t.Fatalf("Expected 404 without blocking, got %d", status)
+		}
+	}
+	request(handler, "GET", "/missing", "Mozilla/5.0", "198.51.100.2:4000")
+
+	if got := sink.events("Scanner activity detected"); len(got) != 1 || got[0] != EventNotFoundRate {
+		t.Errorf("Expected one not found rate event, got %v", got)
+	}
+}
+
+func TestScannerDetector_Block(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	handler, sink := newScannerHandler(ScannerConfig{Block: true, BlockFor: 10 * time.Minute}, &now)
+
+	request(handler, "GET", "/phpmyadmin/", "Mozilla/5.0", "198.51.100.1:4000")
+	if status := request(handler, "POST", "/ingest", "Mozilla/5.0", "198.51.100.1:4000"); status != http.StatusForbidden {
+		t.Errorf("Expected the scanner to be blocked, got %d", status)
+	}
+	if status := request(handler, "POST", "/ingest", "Mozilla/5.0", "198.51.100.2:4000"); status != http.StatusOK {
+		t.Errorf("Expected other clients to be served, got %d", status)
+	}
+	if got := sink.events("Client blocked as a scanner"); len(got) != 1 {
+		t.Errorf("Expected one block event, got %v", got)
+	}
+
+	now = now.Add(10 * time.Minute)
+	if status := request(handler, "POST", "/ingest", "Mozilla/5.0", "198.51.100.1:4000"); status != http.StatusOK {
+		t.Errorf("Expected the block to expire, got %d", status)
+	}
+}
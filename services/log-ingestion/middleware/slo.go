@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultSLOBuckets are the latency buckets of the request histogram when
+// SLOConfig sets none
+var DefaultSLOBuckets = []time.Duration{
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// nativeBucketFactor bounds the growth between neighbouring native histogram
+// buckets, about 10% as the Prometheus documentation suggests
+const nativeBucketFactor = 1.1
+
+// SLOConfig tunes the SLO metrics; zero values fall back to defaults, except
+// NativeHistograms, which is off unless set
+type SLOConfig struct {
+	Buckets               []time.Duration // classic histogram buckets; LatencyTarget is added if missing
+	LatencyTarget         time.Duration   // slower requests count against the latency SLO, 500ms by default
+	LatencyObjective      float64         // share of requests meant to be within LatencyTarget, 0.99 by default
+	AvailabilityObjective float64         // share of requests meant not to fail with a 5xx, 0.999 by default
+	NativeHistograms      bool            // also record native histograms, for Prometheus scraping them
+	ExcludeRoutes         []string        // route templates not measured, such as streaming endpoints
+}
+
+// SLOMiddleware records the latency and outcome of each request per route,
+// for availability and latency SLOs. Besides the histogram it counts
+// requests and the ones that failed each SLO, and exposes the objectives,
+// so burn rates are a ratio of counter rates:
+//
+//	sum(rate(http_slo_errors_total{slo="availability"}[1h]))
+//	  / sum(rate(http_slo_requests_total[1h]))
+//	  / scalar(1 - http_slo_objective{slo="availability"})
+type SLOMiddleware struct {
+	cfg      SLOConfig
+	excluded map[string]bool
+
+	duration *prometheus.HistogramVec
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+}
+
+// NewSLOMiddleware creates the middleware and registers its metrics with reg
+func NewSLOMiddleware(cfg SLOConfig, reg prometheus.Registerer) (*SLOMiddleware, error) {
+	if len(cfg.Buckets) == 0 {
+		cfg.Buckets = DefaultSLOBuckets
+	}
+	if cfg.LatencyTarget <= 0 {
+		cfg.LatencyTarget = 500 * time.Millisecond
+	}
+	if cfg.LatencyObjective <= 0 {
+		cfg.LatencyObjective = 0.99
+	}
+	if cfg.AvailabilityObjective <= 0 {
+		cfg.AvailabilityObjective = 0.999
+	}
+
+	histogram := prometheus.HistogramOpts{
+		Name:    "http_server_request_duration_seconds",
+		Help:    "Time taken to handle HTTP requests, by route",
+		Buckets: sloBuckets(cfg.Buckets, cfg.LatencyTarget),
+	}
+	if cfg.NativeHistograms {
+		histogram.NativeHistogramBucketFactor = nativeBucketFactor
+	}
+
+	sm := &SLOMiddleware{
+		cfg:      cfg,
+		excluded: make(map[string]bool, len(cfg.ExcludeRoutes)),
+		duration: prometheus.NewHistogramVec(histogram, []string{"route", "method"}),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_slo_requests_total",
+			Help: "HTTP requests measured against the SLOs, by route and status code",
+		}, []string{"route", "method", "code"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_slo_errors_total",
+			Help: "HTTP requests that failed an SLO: availability for 5xx responses, latency for responses slower than the target",
+		}, []string{"route", "method", "slo"}),
+	}
+	for _, route := range cfg.ExcludeRoutes {
+		sm.excluded[route] = true
+	}
+
+	objective := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "http_slo_objective",
+		Help: "Share of requests meant to meet each SLO",
+	}, []string{"slo"})
+	objective.WithLabelValues("availability").Set(cfg.AvailabilityObjective)
+	objective.WithLabelValues("latency").Set(cfg.LatencyObjective)
+	target := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "http_slo_latency_target_seconds",
+		Help: "Latency requests must stay within to meet the latency SLO",
+	})
+	target.Set(cfg.LatencyTarget.Seconds())
+
+	for _, c := range []prometheus.Collector{sm.duration, sm.requests, sm.errors, objective, target} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return sm, nil
+}
+
+// sloBuckets converts the buckets to seconds, adding the latency target so
+// the share of requests within it is exact
+func sloBuckets(buckets []time.Duration, target time.Duration) []float64 {
+	seconds := make([]float64, 0, len(buckets)+1)
+	added := false
+	for _, b := range buckets {
+		if !added && b >= target {
+			if b > target {
+				seconds = append(seconds, target.Seconds())
+			}
+			added = true
+		}
+		seconds = append(seconds, b.Seconds())
+	}
+	if !added {
+		seconds = append(seconds, target.Seconds())
+	}
+	return seconds
+}
+
+// Handler measures each request of a route not excluded. A panicking
+// handler is counted as a 500, as the recovery middleware answers it.
+func (sm *SLOMiddleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeTemplate(r)
+		if sm.excluded[route] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		wrapped := newResponseWriter(w)
+		// Observed while a panic passes through, without recovering it
+		returned := false
+		defer func() {
+			code := wrapped.statusCode
+			if !returned {
+				code = http.StatusInternalServerError
+			}
+			sm.observe(route, r.Method, code, time.Since(start))
+		}()
+
+		next.ServeHTTP(wrapped, r)
+		returned = true
+	})
+}
+
+func (sm *SLOMiddleware) observe(route, method string, code int, elapsed time.Duration) {
+	sm.duration.WithLabelValues(route, method).Observe(elapsed.Seconds())
+	sm.requests.WithLabelValues(route, method, strconv.Itoa(code)).Inc()
+	if code >= 500 {
+		sm.errors.WithLabelValues(route, method, "availability").Inc()
+	}
+	if elapsed > sm.cfg.LatencyTarget {
+		sm.errors.WithLabelValues(route, method, "latency").Inc()
+	}
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newSLORouter(t *testing.T, cfg SLOConfig) (*SLOMiddleware, *mux.Router) {
+	sm, err := NewSLOMiddleware(cfg, prometheus.NewRegistry())
+	if err != nil {
+		t.Fatalf("Failed to create SLO middleware: %v", err)
+	}
+	router := mux.NewRouter()
+	router.Use(sm.Handler)
+	router.HandleFunc("/logs/sessions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		switch mux.Vars(r)["id"] {
+		case "slow":
+			time.Sleep(30 * time.Millisecond)
+		case "broken":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "panic":
+			panic("boom")
+		}
+	})
+	router.HandleFunc("/logs/tail", func(w http.ResponseWriter, r *http.Request) {})
+	return sm, router
+}
+
+func TestSLOMiddleware(t *testing.T) {
+	sm, router := newSLORouter(t, SLOConfig{LatencyTarget: 20 * time.Millisecond, ExcludeRoutes: []string{"/logs/tail"}})
+
+	for _, id := range []string{"ok", "slow", "broken", "missing", "panic"} {
+		func() {
+			defer func() { recover() }()
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/logs/sessions/"+id, nil))
+		}()
+	}
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/logs/tail", nil))
+
+	route := "/logs/sessions/{id}"
+	if n := testutil.CollectAndCount(sm.requests); n != 4 {
+		t.Errorf("Expected requests counted per code (200, 503, 404, 500) and the excluded route left out, got %d series", n)
+	}
+	if n := testutil.ToFloat64(sm.requests.WithLabelValues(route, "GET", "200")); n != 2 {
+		t.Errorf("Expected 2 successful requests, got %v", n)
+	}
+	if n := testutil.ToFloat64(sm.errors.WithLabelValues(route, "GET", "availability")); n != 2 {
+		t.Errorf("Expected the 503 and the panic to count against availability, got %v", n)
+	}
+	if n := testutil.ToFloat64(sm.errors.WithLabelValues(route, "GET", "latency")); n != 1 {
+		t.Errorf("Expected the slow request to count against latency, got %v", n)
+	}
+	if n := testutil.CollectAndCount(sm.duration); n != 1 {
+		t.Errorf("Expected one histogram for the route, got %d", n)
+	}
+}
+
+func TestSLOBuckets(t *testing.T) {
+	buckets := []time.Duration{100 * time.Millisecond, time.Second}
+	tests := []struct {
+		target time.Duration
+		want   []float64
+	}{
+		{300 * time.Millisecond, []float64{0.1, 0.3, 1}},
+		{time.Second, []float64{0.1, 1}},
+		{5 * time.Second, []float64{0.1, 1, 5}},
+	}
+	for _, tt := range tests {
+		if got := sloBuckets(buckets, tt.target); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("sloBuckets with target %v = %v, want %v", tt.target, got, tt.want)
+		}
+	}
+}
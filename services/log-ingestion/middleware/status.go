@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+)
+
+// StatusWriter captures the status code a handler answers with, for
+// middleware that acts on it after the handler returns. It keeps
+// http.Flusher working so streaming handlers, such as live tail, can be
+// wrapped.
+type StatusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+// NewStatusWriter wraps w; the status is 200 until the handler sets one
+func NewStatusWriter(w http.ResponseWriter) *StatusWriter {
+	return &StatusWriter{ResponseWriter: w, status: http.StatusOK}
+}
+
+// Status returns the status code the handler answered with
+func (sw *StatusWriter) Status() int {
+	return sw.status
+}
+
+func (sw *StatusWriter) WriteHeader(code int) {
+	if !sw.wroteHeader {
+		sw.status = code
+		sw.wroteHeader = true
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *StatusWriter) Write(data []byte) (int, error) {
+	sw.wroteHeader = true
+	return sw.ResponseWriter.Write(data)
+}
+
+// Flush forwards to the underlying writer so streaming handlers keep working
+func (sw *StatusWriter) Flush() {
+	if flusher, ok := sw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStatusWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	sw := NewStatusWriter(rec)
+	if sw.Status() != http.StatusOK {
+		t.Errorf("Expected 200 before the handler answers, got %d", sw.Status())
+	}
+
+	sw.WriteHeader(http.StatusNotFound)
+	sw.WriteHeader(http.StatusInternalServerError) // superfluous, as net/http ignores it
+	if sw.Status() != http.StatusNotFound {
+		t.Errorf("Expected the first status to be kept, got %d", sw.Status())
+	}
+
+	var flusher http.Flusher = sw
+	flusher.Flush()
+	if !rec.Flushed {
+		t.Error("Expected Flush to reach the underlying writer")
+	}
+}
+
+func TestStatusWriter_WriteImpliesOK(t *testing.T) {
+	sw := NewStatusWriter(httptest.NewRecorder())
+	sw.Write([]byte("ok"))
+	sw.WriteHeader(http.StatusTeapot)
+	if sw.Status() != http.StatusOK {
+		t.Errorf("Expected a write to fix the status at 200, got %d", sw.Status())
+	}
+}
@@ -0,0 +1,162 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"log-processing-system/services/log-ingestion/logger"
+)
+
+// TimeoutMiddleware bounds how long a handler may take, per route. The
+// client gets a 504 instead of waiting on a slow dependency, and the
+// request's context is cancelled at the deadline, so work that honours it
+// stops too.
+type TimeoutMiddleware struct {
+	logger         *logger.Logger
+	defaultTimeout time.Duration
+	routes         map[string]time.Duration
+}
+
+// NewTimeoutMiddleware creates a timeout middleware applying defaultTimeout
+// to every route except those in routes, keyed by route path template such
+// as "/logs/sessions/{id}". A zero timeout leaves a route unbounded, as
+// streaming routes must be.
+func NewTimeoutMiddleware(log *logger.Logger, defaultTimeout time.Duration, routes map[string]time.Duration) *TimeoutMiddleware {
+	return &TimeoutMiddleware{
+		logger:         log,
+		defaultTimeout: defaultTimeout,
+		routes:         routes,
+	}
+}
+
+// routeTemplate returns the path template of the route a request matched,
+// or its path outside the router
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return r.URL.Path
+}
+
+// timeout returns the timeout of the route a request matched
+func (tm *TimeoutMiddleware) timeout(r *http.Request) (string, time.Duration) {
+	path := routeTemplate(r)
+	if timeout, ok := tm.routes[path]; ok {
+		return path, timeout
+	}
+	return path, tm.defaultTimeout
+}
+
+// Handler runs the handler with a deadline, buffering its response. If the
+// handler has not finished by then, it answers 504 with a JSON body and
+// the handler's later writes fail with http.ErrHandlerTimeout.
+func (tm *TimeoutMiddleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, timeout := tm.timeout(r)
+		if timeout <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+
+		tw := &timeoutWriter{header: make(http.Header)}
+		done := make(chan struct{})
+		panicked := make(chan interface{}, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					recordPanicStack(r.Context())
+					panicked <- p
+				}
+			}()
+			next.ServeHTTP(tw, r)
+			close(done)
+		}()
+
+		select {
+		case p := <-panicked:
+			// Re-raised here, for the recovery middleware to handle
+			panic(p)
+		case <-done:
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			dst := w.Header()
+			for k, v := range tw.header {
+				dst[k] = v
+			}
+			if tw.code == 0 {
+				tw.code = http.StatusOK
+			}
+			w.WriteHeader(tw.code)
+			w.Write(tw.body.Bytes())
+		case <-ctx.Done():
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			tw.timedOut = true
+
+			requestID := logger.GetRequestID(r.Context())
+			tm.logger.WithFields(map[string]interface{}{
+				"http_method": r.Method,
+				"http_path":   r.URL.Path,
+				"route":       route,
+				"timeout_ms":  timeout.Milliseconds(),
+				"request_id":  requestID,
+			}).WarnContext(r.Context(), "HTTP request timed out")
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusGatewayTimeout)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status":     "timeout",
+				"message":    fmt.Sprintf("Request did not complete within %s", timeout),
+				"timeout_ms": timeout.Milliseconds(),
+				"request_id": requestID,
+			})
+		}
+	})
+}
+
+// timeoutWriter buffers a handler's response until it is known to have
+// finished in time
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	body     bytes.Buffer
+	code     int
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(data []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.code == 0 {
+		tw.code = http.StatusOK
+	}
+	return tw.body.Write(data)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.code != 0 {
+		return
+	}
+	tw.code = code
+}
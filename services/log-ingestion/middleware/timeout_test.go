@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"log-processing-system/services/log-ingestion/crash"
+	"log-processing-system/services/log-ingestion/logger"
+)
+
+func newTimeoutRouter(defaultTimeout time.Duration, routes map[string]time.Duration, handler http.HandlerFunc) *mux.Router {
+	tm := NewTimeoutMiddleware(logger.New(logger.Config{Service: "test-service", Component: "http"}), defaultTimeout, routes)
+	router := mux.NewRouter()
+	router.Use(tm.Handler)
+	router.HandleFunc("/logs/sessions/{id}", handler)
+	router.HandleFunc("/logs/tail", handler)
+	return router
+}
+
+func TestTimeoutMiddleware_TimesOut(t *testing.T) {
+	cancelled := make(chan struct{})
+	router := newTimeoutRouter(20*time.Millisecond, nil, func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		close(cancelled)
+		w.Write([]byte("too late"))
+	})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/logs/sessions/abc", nil))
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("Expected 504, got %d", rec.Code)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Expected a JSON body, got %s", rec.Body.String())
+	}
+	if body["status"] != "timeout" || body["timeout_ms"] != float64(20) {
+		t.Errorf("Unexpected body %v", body)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("Expected the handler's context to be cancelled")
+	}
+}
+
+func TestTimeoutMiddleware_CompletesInTime(t *testing.T) {
+	router := newTimeoutRouter(time.Second, nil, func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); !ok {
+			t.Error("Expected the request context to have a deadline")
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("done"))
+	})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/logs/sessions/abc", nil))
+	if rec.Code != http.StatusCreated || rec.Body.String() != "done" || rec.Header().Get("Content-Type") != "text/plain" {
+		t.Errorf("Expected the handler's response, got %d %q %v", rec.Code, rec.Body.String(), rec.Header())
+	}
+}
+
+func TestTimeoutMiddleware_PerRoute(t *testing.T) {
+	routes := map[string]time.Duration{
+		"/logs/sessions/{id}": time.Second,
+		"/logs/tail":          0,
+	}
+	router := newTimeoutRouter(time.Millisecond, routes, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/logs/tail" {
+			if _, ok := r.Context().Deadline(); ok {
+				t.Error("Expected an unbounded route to have no deadline")
+			}
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	})
+
+	for _, path := range []string{"/logs/sessions/abc", "/logs/tail"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("Expected %s to use its own timeout, got %d", path, rec.Code)
+		}
+	}
+}
+
+func TestTimeoutMiddleware_Panic(t *testing.T) {
+	router := newTimeoutRouter(time.Second, nil, func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	defer func() {
+		if p := recover(); p != "boom" {
+			t.Errorf("Expected the handler's panic to be re-raised, got %v", p)
+		}
+	}()
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/logs/sessions/abc", nil))
+}
+
+func TestTimeoutMiddleware_PanicReported(t *testing.T) {
+	dir := t.TempDir()
+	reporter, err := crash.New(crash.Config{Dir: dir, Service: "test-service"})
+	if err != nil {
+		t.Fatalf("Failed to create crash reporter: %v", err)
+	}
+	log := logger.New(logger.Config{Service: "test-service", Component: "http"})
+	lm := NewLoggingMiddleware(log, LoggingConfig{Crashes: reporter})
+	router := mux.NewRouter()
+	router.Use(lm.RecoveryMiddleware, lm.Handler, NewTimeoutMiddleware(log, time.Second, nil).Handler)
+	router.HandleFunc("/logs/sessions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/logs/sessions/abc", nil))
+
+	reports, _ := filepath.Glob(filepath.Join(dir, "crash-*.json"))
+	if rec.Code != http.StatusInternalServerError || len(reports) != 1 {
+		t.Fatalf("Expected a 500 and one crash report, got %d and %v", rec.Code, reports)
+	}
+	data, _ := os.ReadFile(reports[0])
+	var report crash.Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("Invalid crash report: %v", err)
+	}
+	if report.Request == nil || report.Request.Route != "/logs/sessions/{id}" || report.Request.RequestID != rec.Header().Get("X-Request-ID") {
+		t.Errorf("Expected the request with its route and ID, got %+v", report.Request)
+	}
+	// The stack of the handler's goroutine, not the one the panic was
+	// re-raised on
+	if stack := strings.Join(report.Stack, "\n"); !strings.Contains(stack, "TestTimeoutMiddleware_PanicReported.func1") {
+		t.Errorf("Expected the panicking handler in the stack, got %s", stack)
+	}
+}
@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"log-processing-system/services/log-ingestion/logger"
+)
+
+// W3C Trace Context headers (https://www.w3.org/TR/trace-context/)
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+// maxTracestateMembers is the most list members a tracestate may have;
+// longer ones are dropped
+const maxTracestateMembers = 32
+
+// TraceContext is the W3C trace context of a request
+type TraceContext struct {
+	TraceID  string // 32 lowercase hex digits
+	ParentID string // the caller's span ID; empty when the trace starts here
+	SpanID   string // the span ID of this request, 16 lowercase hex digits
+	Flags    byte   // trace flags; bit 0 is "sampled"
+	State    string // vendor tracestate, passed on unchanged
+}
+
+// Traceparent formats the traceparent header of calls made on behalf of
+// the request, with its span as their parent
+func (tc TraceContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", tc.TraceID, tc.SpanID, tc.Flags)
+}
+
+// ParseTraceparent parses a traceparent header value. Versions after 00
+// are read as far as 00 defines them, as the specification asks.
+func ParseTraceparent(value string) (traceID, parentID string, flags byte, ok bool) {
+	value = strings.TrimSpace(value)
+	if len(value) < 55 || value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return "", "", 0, false
+	}
+	version := value[:2]
+	if !isLowerHex(version) || version == "ff" {
+		return "", "", 0, false
+	}
+	if len(value) > 55 && (version == "00" || value[55] != '-') {
+		return "", "", 0, false
+	}
+
+	traceID, parentID = value[3:35], value[36:52]
+	if !isLowerHex(traceID) || isZero(traceID) || !isLowerHex(parentID) || isZero(parentID) {
+		return "", "", 0, false
+	}
+	flagBytes, err := hex.DecodeString(value[53:55])
+	if err != nil {
+		return "", "", 0, false
+	}
+	return traceID, parentID, flagBytes[0], true
+}
+
+// parseTracestate joins the tracestate headers of a request, dropping
+// empty members; a malformed or overlong list is dropped entirely
+func parseTracestate(values []string) string {
+	var members []string
+	for _, value := range values {
+		for _, member := range strings.Split(value, ",") {
+			member = strings.TrimSpace(member)
+			if member == "" {
+				continue
+			}
+			key, _, found := strings.Cut(member, "=")
+			if !found || key == "" || strings.ContainsAny(key, " \t") {
+				return ""
+			}
+			members = append(members, member)
+		}
+	}
+	if len(members) > maxTracestateMembers {
+		return ""
+	}
+	return strings.Join(members, ",")
+}
+
+// newTraceContext continues the trace of a request's traceparent with a
+// new span, or starts a new sampled trace when it has none or it is invalid
+func newTraceContext(h http.Header) TraceContext {
+	tc := TraceContext{SpanID: randomHex(8)}
+	if traceID, parentID, flags, ok := ParseTraceparent(h.Get(TraceparentHeader)); ok {
+		tc.TraceID, tc.ParentID, tc.Flags = traceID, parentID, flags
+		tc.State = parseTracestate(h.Values(TracestateHeader))
+		return tc
+	}
+	tc.TraceID = randomHex(16)
+	tc.Flags = 0x01
+	return tc
+}
+
+type traceContextKey struct{}
+
+// withTraceContext returns a context carrying tc, whose trace and span IDs
+// are also added to log entries
+func withTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	ctx = context.WithValue(ctx, traceContextKey{}, tc)
+	return logger.WithSpanID(logger.WithTraceID(ctx, tc.TraceID), tc.SpanID)
+}
+
+// TraceContextFromContext returns the trace context the logging middleware
+// gave a request
+func TraceContextFromContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceContextKey{}).(TraceContext)
+	return tc, ok
+}
+
+// randomHex returns n random bytes as lowercase hex, never all zeros
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil || isZero(hex.EncodeToString(b)) {
+		b[n-1] = 1
+	}
+	return hex.EncodeToString(b)
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func isZero(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"log-processing-system/services/log-ingestion/logger"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		value string
+		ok    bool
+		flags byte
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, 0x01},
+		{" 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00 ", true, 0x00},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-09-future", true, 0x09},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false, 0},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, 0},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, 0},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false, 0},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false, 0},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0g", false, 0},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", false, 0},
+		{"", false, 0},
+	}
+	for _, tt := range tests {
+		traceID, parentID, flags, ok := ParseTraceparent(tt.value)
+		if ok != tt.ok || flags != tt.flags {
+			t.Errorf("%q: expected ok=%v flags=%02x, got ok=%v flags=%02x", tt.value, tt.ok, tt.flags, ok, flags)
+		}
+		if ok && (traceID != "4bf92f3577b34da6a3ce929d0e0e4736" || parentID != "00f067aa0ba902b7") {
+			t.Errorf("%q: unexpected IDs %s %s", tt.value, traceID, parentID)
+		}
+	}
+}
+
+func serveTraced(header http.Header) (*httptest.ResponseRecorder, TraceContext, string) {
+	lm := NewLoggingMiddleware(logger.New(logger.Config{Service: "test-service", Component: "http"}), LoggingConfig{})
+	var (
+		seen      TraceContext
+		loggerIDs string
+	)
+	handler := lm.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = TraceContextFromContext(r.Context())
+		loggerIDs = logger.GetTraceID(r.Context()) + "/" + logger.GetSpanID(r.Context())
+	}))
+
+	req := httptest.NewRequest("POST", "/ingest", nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec, seen, loggerIDs
+}
+
+func TestHandler_ContinuesTrace(t *testing.T) {
+	header := http.Header{}
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	header.Add("tracestate", "congo=t61rcWkgMzE")
+	header.Add("tracestate", "rojo=00f067aa0ba902b7")
+
+	rec, tc, loggerIDs := serveTraced(header)
+	if tc.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || tc.ParentID != "00f067aa0ba902b7" || tc.Flags != 0x01 {
+		t.Fatalf("Expected the caller's trace, got %+v", tc)
+	}
+	if len(tc.SpanID) != 16 || tc.SpanID == tc.ParentID {
+		t.Errorf("Expected a new span ID, got %s", tc.SpanID)
+	}
+	if loggerIDs != tc.TraceID+"/"+tc.SpanID {
+		t.Errorf("Expected the IDs in the logger context, got %s", loggerIDs)
+	}
+	if got := rec.Header().Get("traceparent"); got != "00-4bf92f3577b34da6a3ce929d0e0e4736-"+tc.SpanID+"-01" {
+		t.Errorf("Unexpected traceparent response header %s", got)
+	}
+	if got := rec.Header().Get("tracestate"); got != "congo=t61rcWkgMzE,rojo=00f067aa0ba902b7" {
+		t.Errorf("Unexpected tracestate response header %s", got)
+	}
+}
+
+func TestHandler_StartsTrace(t *testing.T) {
+	header := http.Header{}
+	header.Set("traceparent", "00-00000000000000000000000000000000-00f067aa0ba902b7-01")
+	header.Set("tracestate", "congo=t61rcWkgMzE")
+
+	rec, tc, _ := serveTraced(header)
+	traceID, parentID, flags, ok := ParseTraceparent(rec.Header().Get("traceparent"))
+	if !ok || traceID != tc.TraceID || parentID != tc.SpanID || flags != 0x01 {
+		t.Fatalf("Expected a new valid trace, got %s for %+v", rec.Header().Get("traceparent"), tc)
+	}
+	if tc.ParentID != "" || tc.State != "" || rec.Header().Get("tracestate") != "" {
+		t.Errorf("Expected the invalid trace context to be ignored, got %+v", tc)
+	}
+
+	_, other, _ := serveTraced(http.Header{})
+	if other.TraceID == tc.TraceID {
+		t.Error("Expected each request to start its own trace")
+	}
+}
+
+func TestParseTracestate(t *testing.T) {
+	if got := parseTracestate([]string{"a=1, ,b=2"}); got != "a=1,b=2" {
+		t.Errorf("Expected empty members to be dropped, got %q", got)
+	}
+	if got := parseTracestate([]string{"a=1,invalid"}); got != "" {
+		t.Errorf("Expected a malformed list to be dropped, got %q", got)
+	}
+}
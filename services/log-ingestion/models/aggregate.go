@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// LogAggregate represents the number of log entries for a source and level within a time bucket
+type LogAggregate struct {
+	Bucket time.Time `json:"bucket"`
+	Source string    `json:"source"`
+	Level  string    `json:"level"`
+	Count  int64     `json:"count"`
+}
+
+// AggregateFilter restricts an aggregate query
+type AggregateFilter struct {
+	From     time.Time
+	To       time.Time
+	Interval string
+	Source   string
+	Level    string
+}
+
+// ValidAggregateIntervals are the bucket sizes supported by aggregate queries
+var ValidAggregateIntervals = []string{"minute", "hour", "day"}
+
+// IsValidAggregateInterval checks if the interval is a supported bucket size
+func IsValidAggregateInterval(interval string) bool {
+	for _, v := range ValidAggregateIntervals {
+		if interval == v {
+			return true
+		}
+	}
+	return false
+}
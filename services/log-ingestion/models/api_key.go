@@ -0,0 +1,75 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// Scopes an API key can be granted
+const (
+	ScopeIngest = "ingest" // submit log entries
+	ScopeRead   = "read"   // query, tail and export stored logs
+	ScopeAdmin  = "admin"  // the /admin endpoints; implies the other scopes
+)
+
+// APIKey is a key clients authenticate with. The key itself is only shown
+// when it is created; KeyHash is its SHA-256 hash and Prefix its first
+// characters, enough to recognise it.
+type APIKey struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	KeyHash   string     `json:"-"`
+	Scopes    []string   `json:"scopes"`
+	Sources   []string   `json:"sources,omitempty"`
+	RateLimit int        `json:"rate_limit,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Validate checks if the key's settings are well-formed
+func (k APIKey) Validate() error {
+	if k.Name == "" {
+		return errors.New("name cannot be empty")
+	}
+	if len(k.Name) > 100 {
+		return errors.New("name cannot be longer than 100 characters")
+	}
+	if len(k.Scopes) == 0 {
+		return errors.New("at least one scope is required")
+	}
+	for _, scope := range k.Scopes {
+		switch scope {
+		case ScopeIngest, ScopeRead, ScopeAdmin:
+		default:
+			return errors.New("invalid scope: " + scope)
+		}
+	}
+	if k.RateLimit < 0 {
+		return errors.New("rate_limit must not be negative")
+	}
+	return nil
+}
+
+// HasScope reports whether the key grants scope
+func (k APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsSource reports whether the key may write entries of source
+func (k APIKey) AllowsSource(source string) bool {
+	if len(k.Sources) == 0 {
+		return true
+	}
+	for _, s := range k.Sources {
+		if s == source {
+			return true
+		}
+	}
+	return false
+}
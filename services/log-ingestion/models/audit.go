@@ -0,0 +1,54 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// Kinds of actor an audit event is attributed to
+const (
+	ActorAPIKey      = "api_key"     // a stored API key, by name
+	ActorAdminKey    = "admin_key"   // the configured AUTH_ADMIN_KEY
+	ActorToken       = "token"       // a bearer token, by subject
+	ActorCertificate = "certificate" // a TLS client certificate, by common name
+	ActorAnonymous   = "anonymous"   // a request made without authentication
+	ActorSystem      = "system"      // the service itself, e.g. on a signal
+)
+
+// AuditEvent records an administrative action: who performed it, what it
+// was and its outcome. Events are never changed once stored.
+type AuditEvent struct {
+	ID         int64     `json:"id"`
+	OccurredAt time.Time `json:"occurred_at"`
+	ActorType  string    `json:"actor_type"`
+	Actor      string    `json:"actor"`
+	Action     string    `json:"action"`
+	Resource   string    `json:"resource,omitempty"`
+	Method     string    `json:"method,omitempty"`
+	Path       string    `json:"path,omitempty"`
+	Status     int       `json:"status,omitempty"`
+	RequestID  string    `json:"request_id,omitempty"`
+	ClientIP   string    `json:"client_ip,omitempty"`
+	Details    Fields    `json:"details,omitempty"`
+}
+
+// AuditFilter selects audit events, newest first
+type AuditFilter struct {
+	Actor  string    `json:"actor,omitempty"`
+	Action string    `json:"action,omitempty"`
+	Since  time.Time `json:"since,omitempty"`
+	Until  time.Time `json:"until,omitempty"`
+	Limit  int       `json:"limit,omitempty"`
+	Offset int       `json:"offset,omitempty"`
+}
+
+// Validate checks if the filter is well-formed
+func (f AuditFilter) Validate() error {
+	if f.Limit < 0 || f.Offset < 0 {
+		return errors.New("limit and offset must not be negative")
+	}
+	if !f.Since.IsZero() && !f.Until.IsZero() && f.Until.Before(f.Since) {
+		return errors.New("until must not be before since")
+	}
+	return nil
+}
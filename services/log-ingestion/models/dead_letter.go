@@ -0,0 +1,63 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// Reasons a submission ends up in the dead-letter queue
+const (
+	DeadLetterParse      = "parse"
+	DeadLetterValidation = "validation"
+	DeadLetterPipeline   = "pipeline"
+	DeadLetterStorage    = "storage"
+)
+
+// DeadLetter is a submission that could not be ingested, kept with its
+// error so it can be inspected and replayed. Payload holds the request body
+// as received, or for storage failures the processed entry as JSON.
+type DeadLetter struct {
+	ID         int64      `json:"id"`
+	ReceivedAt time.Time  `json:"received_at"`
+	Reason     string     `json:"reason"`
+	Error      string     `json:"error"`
+	Source     string     `json:"source,omitempty"`
+	Payload    string     `json:"payload"`
+	Attempts   int        `json:"attempts"`
+	ReplayedAt *time.Time `json:"replayed_at,omitempty"`
+}
+
+// DeadLetterFilter selects dead letters; replayed ones are skipped unless
+// IncludeReplayed is set
+type DeadLetterFilter struct {
+	Reason          string `json:"reason,omitempty"`
+	Source          string `json:"source,omitempty"`
+	IncludeReplayed bool   `json:"include_replayed,omitempty"`
+	Limit           int    `json:"limit,omitempty"`
+	Offset          int    `json:"offset,omitempty"`
+}
+
+// Validate checks if the filter is well-formed
+func (f DeadLetterFilter) Validate() error {
+	switch f.Reason {
+	case "", DeadLetterParse, DeadLetterValidation, DeadLetterPipeline, DeadLetterStorage:
+	default:
+		return errors.New("invalid dead letter reason")
+	}
+	if f.Limit < 0 || f.Offset < 0 {
+		return errors.New("limit and offset must not be negative")
+	}
+	return nil
+}
+
+// Matches reports whether a dead letter is selected by the filter, ignoring
+// limit and offset
+func (f DeadLetterFilter) Matches(l DeadLetter) bool {
+	if f.Reason != "" && l.Reason != f.Reason {
+		return false
+	}
+	if f.Source != "" && l.Source != f.Source {
+		return false
+	}
+	return f.IncludeReplayed || l.ReplayedAt == nil
+}
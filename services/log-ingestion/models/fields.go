@@ -0,0 +1,55 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+// Fields holds structured attributes attached to a log entry. It is stored
+// as a JSONB column.
+type Fields map[string]interface{}
+
+// Value implements driver.Valuer so Fields can be written to a JSONB column
+func (f Fields) Value() (driver.Value, error) {
+	if len(f) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(f)
+}
+
+// Scan implements sql.Scanner so Fields can be read from a JSONB column
+func (f *Fields) Scan(src interface{}) error {
+	if src == nil {
+		*f = nil
+		return nil
+	}
+
+	var data []byte
+	switch v := src.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("unsupported type for log fields")
+	}
+
+	return json.Unmarshal(data, f)
+}
+
+// Fields set on entries the classify_noise pipeline stage tags as likely noise
+const (
+	NoiseField       = "noise"
+	NoiseReasonField = "noise_reason"
+)
+
+// IsNoise reports whether the entry was classified as likely noise
+func (f Fields) IsNoise() bool {
+	noise, _ := f[NoiseField].(bool)
+	return noise
+}
+
+// SampleRateField is set on entries kept while their source was sampled down
+// to its ingest budget, holding the number of entries each one stands for
+const SampleRateField = "sample_rate"
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// LogFilter selects log entries by source, level and time range
+type LogFilter struct {
+	Source string     `json:"source,omitempty"`
+	Level  string     `json:"level,omitempty"`
+	From   *time.Time `json:"from,omitempty"`
+	To     *time.Time `json:"to,omitempty"`
+}
+
+// IsEmpty reports whether the filter would match every log entry
+func (f LogFilter) IsEmpty() bool {
+	return f.Source == "" && f.Level == "" && f.From == nil && f.To == nil
+}
+
+// Validate checks if the filter is well-formed
+func (f LogFilter) Validate() error {
+	if f.Level != "" && !isValidLogLevel(f.Level) {
+		return errors.New("invalid log level")
+	}
+	if f.From != nil && f.To != nil && !f.From.Before(*f.To) {
+		return errors.New("'from' must be before 'to'")
+	}
+	return nil
+}
+
+// Matches reports whether an entry is selected by the filter, with the same
+// semantics as the database queries: levels compare case-insensitively and
+// the time range includes From but not To
+func (f LogFilter) Matches(l Log) bool {
+	if f.Source != "" && l.Source != f.Source {
+		return false
+	}
+	if f.Level != "" && !strings.EqualFold(l.Level, f.Level) {
+		return false
+	}
+	if f.From != nil && l.Timestamp.Before(*f.From) {
+		return false
+	}
+	if f.To != nil && !l.Timestamp.Before(*f.To) {
+		return false
+	}
+	return true
+}
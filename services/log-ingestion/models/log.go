@@ -1,8 +1,11 @@
 package models
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"regexp"
+	"strconv"
 	"time"
 )
 
@@ -13,6 +16,96 @@ type Log struct {
 	Level     string    `json:"level"`
 	Timestamp time.Time `json:"timestamp"`
 	Source    string    `json:"source"`
+	Fields    Fields    `json:"fields,omitempty"`
+}
+
+// LogFormat is the format a log entry was submitted in
+type LogFormat int
+
+const (
+	// StructuredLog has a message, with a level, timestamp, source and fields
+	StructuredLog LogFormat = iota
+	// LegacyLog is {"log": "..."} without a message
+	LegacyLog
+	// MessagelessLog has neither a message nor a log; only a pipeline that
+	// maps the producer's own keys can make an entry of it
+	MessagelessLog
+)
+
+// LegacySource is the source of entries submitted in the legacy format
+const LegacySource = "legacy_api"
+
+// logJSON is the JSON of a log entry as submitted
+type logJSON struct {
+	ID        int             `json:"id"`
+	Message   *string         `json:"message"`
+	Level     json.RawMessage `json:"level"`
+	Timestamp time.Time       `json:"timestamp"`
+	Source    string          `json:"source"`
+	Fields    Fields          `json:"fields"`
+	Log       *string         `json:"log"`
+}
+
+// DecodeLog decodes an entry from JSON in one pass, and tells the format it
+// was in. A legacy entry becomes an info entry of LegacySource with its log
+// as message, stamped now. Numeric levels, such as syslog severities, are
+// kept as text.
+func DecodeLog(data []byte) (Log, LogFormat, error) {
+	var in logJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return Log{}, StructuredLog, err
+	}
+
+	if in.Message == nil && in.Log != nil {
+		return Log{
+			Message:   *in.Log,
+			Level:     "info",
+			Timestamp: time.Now(),
+			Source:    LegacySource,
+		}, LegacyLog, nil
+	}
+
+	entry := Log{ID: in.ID, Timestamp: in.Timestamp, Source: in.Source, Fields: in.Fields}
+	format := MessagelessLog
+	if in.Message != nil {
+		entry.Message, format = *in.Message, StructuredLog
+	}
+	level, err := decodeLevel(in.Level)
+	if err != nil {
+		return Log{}, format, err
+	}
+	entry.Level = level
+	return entry, format, nil
+}
+
+// decodeLevel decodes a level given as text or as a number
+func decodeLevel(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+	if raw[0] == '"' {
+		var level string
+		err := json.Unmarshal(raw, &level)
+		return level, err
+	}
+	number, err := strconv.ParseFloat(string(raw), 64)
+	if err != nil {
+		return "", fmt.Errorf("level must be text or a number, got %s", raw)
+	}
+	return strconv.FormatFloat(number, 'f', -1, 64), nil
+}
+
+// UnmarshalJSON decodes an entry as DecodeLog does; null leaves it as is
+func (l *Log) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	entry, _, err := DecodeLog(data)
+	if err != nil {
+		return err
+	}
+	*l = entry
+	return nil
 }
 
 // Validate checks if the log data is valid
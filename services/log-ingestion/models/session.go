@@ -0,0 +1,52 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// Session states
+const (
+	SessionOpen   = "open"
+	SessionClosed = "closed"
+)
+
+// Session summarizes the entries sharing a trace or session ID. A session
+// is open while entries keep arriving and closed once it has been idle for
+// the correlate stage's timeout.
+type Session struct {
+	ID         string           `json:"id"`
+	StartedAt  time.Time        `json:"started_at"`
+	EndedAt    time.Time        `json:"ended_at"`
+	DurationMs int64            `json:"duration_ms"`
+	EntryCount int64            `json:"entry_count"`
+	ErrorCount int64            `json:"error_count"`
+	Levels     map[string]int64 `json:"levels"`
+	Sources    []string         `json:"sources"`
+	Status     string           `json:"status"`
+}
+
+// SessionFilter selects sessions by source, status, errors and start time
+type SessionFilter struct {
+	Source    string     `json:"source,omitempty"`
+	Status    string     `json:"status,omitempty"`
+	MinErrors int64      `json:"min_errors,omitempty"`
+	From      *time.Time `json:"from,omitempty"`
+	To        *time.Time `json:"to,omitempty"`
+	Limit     int        `json:"limit,omitempty"`
+	Offset    int        `json:"offset,omitempty"`
+}
+
+// Validate checks if the filter is well-formed
+func (f SessionFilter) Validate() error {
+	if f.Status != "" && f.Status != SessionOpen && f.Status != SessionClosed {
+		return errors.New("invalid session status")
+	}
+	if f.From != nil && f.To != nil && !f.From.Before(*f.To) {
+		return errors.New("'from' must be before 'to'")
+	}
+	if f.MinErrors < 0 || f.Limit < 0 || f.Offset < 0 {
+		return errors.New("min_errors, limit and offset must not be negative")
+	}
+	return nil
+}
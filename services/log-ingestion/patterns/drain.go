@@ -0,0 +1,330 @@
+// Package patterns mines message templates from log entries using the Drain
+// algorithm: messages are routed through a fixed-depth tree keyed by token
+// count and leading tokens, and joined to the most similar cluster in the
+// leaf, whose template generalises differing tokens to a wildcard.
+package patterns
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/models"
+	"log-processing-system/services/log-ingestion/pubsub"
+)
+
+// Wildcard replaces variable tokens in templates
+const Wildcard = "<*>"
+
+// maskPatterns replace obviously variable values before clustering
+var maskPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`),
+	regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}(:\d+)?\b`),
+	regexp.MustCompile(`\b0x[0-9a-fA-F]+\b`),
+	regexp.MustCompile(`\b[0-9a-fA-F]{16,}\b`),
+	regexp.MustCompile(`\b\d+(\.\d+)?\b`),
+}
+
+// Config tunes the miner
+type Config struct {
+	Depth       int     // tree depth including the length layer and leaves
+	Similarity  float64 // minimum share of matching tokens to join a cluster
+	MaxChildren int     // children per tree node before tokens fall into a wildcard branch
+	MaxPatterns int     // least recently seen patterns are evicted beyond this
+}
+
+// Pattern is a snapshot of one mined template
+type Pattern struct {
+	ID        int              `json:"id"`
+	Template  string           `json:"template"`
+	Count     int64            `json:"count"`
+	Levels    map[string]int64 `json:"levels"`
+	Sources   map[string]int64 `json:"sources"`
+	Example   string           `json:"example"`
+	FirstSeen time.Time        `json:"first_seen"`
+	LastSeen  time.Time        `json:"last_seen"`
+}
+
+// Query selects patterns; zero fields match everything
+type Query struct {
+	Level    string
+	Source   string
+	NewSince time.Time // first seen after
+	Since    time.Time // last seen after
+	Limit    int
+}
+
+type cluster struct {
+	Pattern
+	tokens []string
+	leaf   *node
+}
+
+type node struct {
+	children map[string]*node
+	clusters []*cluster
+}
+
+func newNode() *node {
+	return &node{children: make(map[string]*node)}
+}
+
+// Miner groups similar messages into patterns
+type Miner struct {
+	cfg    Config
+	logger *logger.Logger
+
+	mu       sync.Mutex
+	root     map[int]*node
+	clusters map[int]*cluster
+	nextID   int
+}
+
+// NewMiner creates a miner; zero config values fall back to Drain's defaults
+func NewMiner(cfg Config, log *logger.Logger) *Miner {
+	if cfg.Depth < 3 {
+		cfg.Depth = 4
+	}
+	if cfg.Similarity <= 0 || cfg.Similarity > 1 {
+		cfg.Similarity = 0.4
+	}
+	if cfg.MaxChildren <= 0 {
+		cfg.MaxChildren = 100
+	}
+	if cfg.MaxPatterns <= 0 {
+		cfg.MaxPatterns = 5000
+	}
+
+	return &Miner{
+		cfg:      cfg,
+		logger:   log,
+		root:     make(map[int]*node),
+		clusters: make(map[int]*cluster),
+	}
+}
+
+// Start mines every entry published on the hub until ctx is cancelled
+func (m *Miner) Start(ctx context.Context, hub *pubsub.Hub) {
+	sub := hub.Subscribe(pubsub.DefaultBufferSize*4, nil)
+	defer sub.Close()
+
+	m.logger.WithFields(map[string]interface{}{
+		"depth":      m.cfg.Depth,
+		"similarity": m.cfg.Similarity,
+	}).Info("Log pattern miner started")
+
+	for {
+		select {
+		case <-ctx.Done():
+			m.logger.Info("Log pattern miner stopped")
+			return
+		case entry, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			m.Add(entry)
+		}
+	}
+}
+
+// Add assigns an entry to a pattern, creating one if no cluster is similar
+// enough. It returns the pattern ID and whether the pattern is new.
+func (m *Miner) Add(entry models.Log) (int, bool) {
+	tokens := tokenize(entry.Message)
+	if len(tokens) == 0 {
+		return 0, false
+	}
+	now := entry.Timestamp
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	leaf := m.leafFor(tokens)
+	c := m.bestMatch(leaf, tokens)
+	isNew := c == nil
+
+	if isNew {
+		m.nextID++
+		c = &cluster{
+			Pattern: Pattern{
+				ID:        m.nextID,
+				Levels:    make(map[string]int64),
+				Sources:   make(map[string]int64),
+				Example:   entry.Message,
+				FirstSeen: now,
+				LastSeen:  now,
+			},
+			tokens: append([]string(nil), tokens...),
+			leaf:   leaf,
+		}
+		leaf.clusters = append(leaf.clusters, c)
+		m.clusters[c.ID] = c
+		if len(m.clusters) > m.cfg.MaxPatterns {
+			m.evictOldest()
+		}
+	} else {
+		for i, token := range tokens {
+			if c.tokens[i] != token {
+				c.tokens[i] = Wildcard
+			}
+		}
+	}
+
+	c.Count++
+	c.Levels[strings.ToLower(entry.Level)]++
+	c.Sources[entry.Source]++
+	if now.After(c.LastSeen) {
+		c.LastSeen = now
+	}
+
+	return c.ID, isNew
+}
+
+// leafFor walks (and grows) the tree for a token sequence
+func (m *Miner) leafFor(tokens []string) *node {
+	n, ok := m.root[len(tokens)]
+	if !ok {
+		n = newNode()
+		m.root[len(tokens)] = n
+	}
+
+	for i := 0; i < m.cfg.Depth-2 && i < len(tokens); i++ {
+		key := tokens[i]
+		if hasDigit(key) {
+			key = Wildcard
+		}
+
+		child, ok := n.children[key]
+		if !ok {
+			if len(n.children) >= m.cfg.MaxChildren {
+				key = Wildcard
+				child = n.children[key]
+			}
+			if child == nil {
+				child = newNode()
+				n.children[key] = child
+			}
+		}
+		n = child
+	}
+	return n
+}
+
+// bestMatch returns the most similar cluster in a leaf above the threshold,
+// preferring the more general template on ties
+func (m *Miner) bestMatch(leaf *node, tokens []string) *cluster {
+	var (
+		best          *cluster
+		bestSim       = -1.0
+		bestWildcards int
+	)
+
+	for _, c := range leaf.clusters {
+		matches, wildcards := 0, 0
+		for i, token := range c.tokens {
+			if token == Wildcard {
+				wildcards++
+			} else if token == tokens[i] {
+				matches++
+			}
+		}
+		sim := float64(matches) / float64(len(tokens))
+		if sim > bestSim || (sim == bestSim && wildcards > bestWildcards) {
+			best, bestSim, bestWildcards = c, sim, wildcards
+		}
+	}
+
+	if best == nil || bestSim < m.cfg.Similarity {
+		return nil
+	}
+	return best
+}
+
+func (m *Miner) evictOldest() {
+	var oldest *cluster
+	for _, c := range m.clusters {
+		if oldest == nil || c.LastSeen.Before(oldest.LastSeen) {
+			oldest = c
+		}
+	}
+
+	delete(m.clusters, oldest.ID)
+	leaf := oldest.leaf
+	for i, c := range leaf.clusters {
+		if c == oldest {
+			leaf.clusters = append(leaf.clusters[:i], leaf.clusters[i+1:]...)
+			break
+		}
+	}
+}
+
+// Patterns returns the patterns matching q, most frequent first
+func (m *Miner) Patterns(q Query) []Pattern {
+	level := strings.ToLower(q.Level)
+
+	m.mu.Lock()
+	var patterns []Pattern
+	for _, c := range m.clusters {
+		if level != "" && c.Levels[level] == 0 {
+			continue
+		}
+		if q.Source != "" && c.Sources[q.Source] == 0 {
+			continue
+		}
+		if !q.NewSince.IsZero() && !c.FirstSeen.After(q.NewSince) {
+			continue
+		}
+		if !q.Since.IsZero() && !c.LastSeen.After(q.Since) {
+			continue
+		}
+		patterns = append(patterns, c.snapshot())
+	}
+	m.mu.Unlock()
+
+	sort.Slice(patterns, func(i, j int) bool {
+		if patterns[i].Count != patterns[j].Count {
+			return patterns[i].Count > patterns[j].Count
+		}
+		return patterns[i].ID < patterns[j].ID
+	})
+
+	if q.Limit > 0 && len(patterns) > q.Limit {
+		patterns = patterns[:q.Limit]
+	}
+	if patterns == nil {
+		patterns = []Pattern{}
+	}
+	return patterns
+}
+
+func (c *cluster) snapshot() Pattern {
+	p := c.Pattern
+	p.Template = strings.Join(c.tokens, " ")
+	p.Levels = make(map[string]int64, len(c.Levels))
+	for k, v := range c.Levels {
+		p.Levels[k] = v
+	}
+	p.Sources = make(map[string]int64, len(c.Sources))
+	for k, v := range c.Sources {
+		p.Sources[k] = v
+	}
+	return p
+}
+
+// tokenize masks variable values and splits a message on whitespace
+func tokenize(message string) []string {
+	for _, re := range maskPatterns {
+		message = re.ReplaceAllString(message, Wildcard)
+	}
+	return strings.Fields(message)
+}
+
+func hasDigit(s string) bool {
+	return strings.IndexAny(s, "0123456789") >= 0
+}
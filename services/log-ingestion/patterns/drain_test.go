@@ -0,0 +1,124 @@
+package patterns
+
+import (
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func testMiner(cfg Config) *Miner {
+	return NewMiner(cfg, logger.New(logger.Config{Level: "ERROR", Service: "test-service", Component: "patterns"}))
+}
+
+func TestMiner_GroupsSimilarMessages(t *testing.T) {
+	m := testMiner(Config{})
+
+	messages := []string{
+		"Login succeeded for alice from 10.0.0.1",
+		"Login succeeded for bob from 10.0.0.2",
+		"Login succeeded for carol from 192.168.1.20:443",
+		"Connection to db-primary timed out after 3000ms",
+		"Connection to db-replica timed out after 5000ms",
+	}
+	for _, msg := range messages {
+		m.Add(models.Log{Message: msg, Level: "info", Source: "auth"})
+	}
+
+	patterns := m.Patterns(Query{})
+	if len(patterns) != 2 {
+		t.Fatalf("Expected 2 patterns, got %d: %+v", len(patterns), patterns)
+	}
+	if patterns[0].Template != "Login succeeded for <*> from <*>" || patterns[0].Count != 3 {
+		t.Errorf("Expected login template with count 3, got %q (%d)", patterns[0].Template, patterns[0].Count)
+	}
+	if patterns[1].Template != "Connection to <*> timed out after <*>" {
+		t.Errorf("Expected timeout template, got %q", patterns[1].Template)
+	}
+	if patterns[0].Example != messages[0] {
+		t.Errorf("Expected first message as example, got %q", patterns[0].Example)
+	}
+}
+
+func TestMiner_DifferentLengthsNeverMerge(t *testing.T) {
+	m := testMiner(Config{})
+
+	m.Add(models.Log{Message: "cache miss", Level: "debug"})
+	m.Add(models.Log{Message: "cache miss for key", Level: "debug"})
+
+	if patterns := m.Patterns(Query{}); len(patterns) != 2 {
+		t.Errorf("Expected 2 patterns, got %d", len(patterns))
+	}
+}
+
+func TestMiner_NewPatternDetection(t *testing.T) {
+	m := testMiner(Config{})
+	yesterday := time.Now().Add(-24 * time.Hour)
+	today := time.Now()
+
+	if _, isNew := m.Add(models.Log{Message: "payment failed for order 1", Level: "error", Timestamp: yesterday}); !isNew {
+		t.Errorf("Expected first message to create a pattern")
+	}
+	if _, isNew := m.Add(models.Log{Message: "payment failed for order 2", Level: "error", Timestamp: today}); isNew {
+		t.Errorf("Expected second message to join the existing pattern")
+	}
+	m.Add(models.Log{Message: "refund service unavailable", Level: "error", Timestamp: today})
+	m.Add(models.Log{Message: "health check ok", Level: "info", Timestamp: today})
+
+	since := today.Add(-time.Hour)
+	newErrors := m.Patterns(Query{Level: "ERROR", NewSince: since})
+	if len(newErrors) != 1 || newErrors[0].Template != "refund service unavailable" {
+		t.Errorf("Expected one new error pattern, got %+v", newErrors)
+	}
+
+	active := m.Patterns(Query{Level: "error", Since: since})
+	if len(active) != 2 {
+		t.Errorf("Expected 2 error patterns seen today, got %d", len(active))
+	}
+}
+
+func TestMiner_QueryBySourceAndLimit(t *testing.T) {
+	m := testMiner(Config{})
+
+	m.Add(models.Log{Message: "job started", Source: "worker"})
+	m.Add(models.Log{Message: "request served", Source: "api"})
+	m.Add(models.Log{Message: "request served", Source: "api"})
+
+	if patterns := m.Patterns(Query{Source: "worker"}); len(patterns) != 1 || patterns[0].Template != "job started" {
+		t.Errorf("Expected worker pattern only, got %+v", patterns)
+	}
+	if patterns := m.Patterns(Query{Limit: 1}); len(patterns) != 1 || patterns[0].Count != 2 {
+		t.Errorf("Expected most frequent pattern only, got %+v", patterns)
+	}
+}
+
+func TestMiner_EvictsLeastRecentlySeen(t *testing.T) {
+	m := testMiner(Config{MaxPatterns: 2})
+	base := time.Now()
+
+	m.Add(models.Log{Message: "alpha event", Timestamp: base})
+	m.Add(models.Log{Message: "beta happened here", Timestamp: base.Add(time.Second)})
+	m.Add(models.Log{Message: "gamma", Timestamp: base.Add(2 * time.Second)})
+
+	patterns := m.Patterns(Query{})
+	if len(patterns) != 2 {
+		t.Fatalf("Expected 2 patterns after eviction, got %d", len(patterns))
+	}
+	for _, p := range patterns {
+		if p.Template == "alpha event" {
+			t.Errorf("Expected oldest pattern to be evicted")
+		}
+	}
+}
+
+func TestMiner_SnapshotIsIndependent(t *testing.T) {
+	m := testMiner(Config{})
+	m.Add(models.Log{Message: "disk full", Level: "error"})
+
+	snapshot := m.Patterns(Query{})[0]
+	snapshot.Levels["error"] = 100
+
+	if m.Patterns(Query{})[0].Levels["error"] != 1 {
+		t.Errorf("Expected snapshot changes not to affect the miner")
+	}
+}
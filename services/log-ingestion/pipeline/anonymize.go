@@ -0,0 +1,134 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func init() {
+	Register("anonymize_ip", newAnonymizeIPProcessor)
+}
+
+// ipCandidate finds IPv4 and IPv6 lookalikes in free text; each match is
+// confirmed with net.ParseIP before it is replaced
+var ipCandidate = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b|(?:[0-9A-Fa-f]{0,4}:){2,7}[0-9A-Fa-f]{0,4}(?:(?:\d{1,3}\.){3}\d{1,3})?`)
+
+// anonymizeIPConfig rewrites the IP addresses in fields before storage.
+// truncate zeroes the host bits beyond ipv4_prefix/ipv6_prefix; hash
+// replaces the address with a keyed SHA-256 digest, so the same address
+// still correlates across entries without being recoverable.
+type anonymizeIPConfig struct {
+	Fields     []string `json:"fields"`
+	Method     string   `json:"method"`
+	IPv4Prefix int      `json:"ipv4_prefix"`
+	IPv6Prefix int      `json:"ipv6_prefix"`
+	Key        string   `json:"key"`
+}
+
+type anonymizeIPProcessor struct {
+	fields   []string
+	hash     bool
+	ipv4Mask net.IPMask
+	ipv6Mask net.IPMask
+	key      []byte
+}
+
+func newAnonymizeIPProcessor(raw json.RawMessage) (Processor, error) {
+	cfg := anonymizeIPConfig{Method: "truncate", IPv4Prefix: 24, IPv6Prefix: 48}
+	if err := decodeConfig(raw, &cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.Fields) == 0 {
+		return nil, errors.New("anonymize_ip requires at least one field")
+	}
+
+	p := &anonymizeIPProcessor{fields: cfg.Fields}
+	switch cfg.Method {
+	case "truncate":
+		if cfg.IPv4Prefix < 0 || cfg.IPv4Prefix > 32 {
+			return nil, fmt.Errorf("invalid ipv4_prefix %d", cfg.IPv4Prefix)
+		}
+		if cfg.IPv6Prefix < 0 || cfg.IPv6Prefix > 128 {
+			return nil, fmt.Errorf("invalid ipv6_prefix %d", cfg.IPv6Prefix)
+		}
+		p.ipv4Mask = net.CIDRMask(cfg.IPv4Prefix, 32)
+		p.ipv6Mask = net.CIDRMask(cfg.IPv6Prefix, 128)
+	case "hash":
+		if cfg.Key == "" {
+			return nil, errors.New("hash method requires a key")
+		}
+		p.hash = true
+		p.key = []byte(cfg.Key)
+	default:
+		return nil, fmt.Errorf("unknown method %q", cfg.Method)
+	}
+
+	return p, nil
+}
+
+func (p *anonymizeIPProcessor) Process(ctx context.Context, entry *models.Log) ([]*models.Log, error) {
+	for _, field := range p.fields {
+		if field == "message" {
+			entry.Message = p.anonymizeText(entry.Message)
+			continue
+		}
+
+		switch value := entry.Fields[field].(type) {
+		case string:
+			entry.Fields[field] = p.anonymizeValue(value)
+		case []interface{}:
+			for i, item := range value {
+				if s, ok := item.(string); ok {
+					value[i] = p.anonymizeValue(s)
+				}
+			}
+		}
+	}
+
+	return Keep(entry), nil
+}
+
+// anonymizeValue handles a field holding an address, possibly with a port
+// (e.g. a RemoteAddr); other strings are searched for embedded addresses
+func (p *anonymizeIPProcessor) anonymizeValue(value string) string {
+	if ip := net.ParseIP(value); ip != nil {
+		return p.anonymize(ip)
+	}
+	if host, port, err := net.SplitHostPort(value); err == nil {
+		if ip := net.ParseIP(host); ip != nil {
+			return net.JoinHostPort(p.anonymize(ip), port)
+		}
+	}
+	return p.anonymizeText(value)
+}
+
+func (p *anonymizeIPProcessor) anonymizeText(text string) string {
+	return ipCandidate.ReplaceAllStringFunc(text, func(match string) string {
+		ip := net.ParseIP(match)
+		if ip == nil {
+			return match
+		}
+		return p.anonymize(ip)
+	})
+}
+
+func (p *anonymizeIPProcessor) anonymize(ip net.IP) string {
+	if p.hash {
+		mac := hmac.New(sha256.New, p.key)
+		mac.Write(ip.To16())
+		return "ip-" + hex.EncodeToString(mac.Sum(nil)[:8])
+	}
+
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(p.ipv4Mask).String()
+	}
+	return ip.Mask(p.ipv6Mask).String()
+}
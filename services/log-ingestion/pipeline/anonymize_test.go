@@ -0,0 +1,74 @@
+package pipeline
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func TestAnonymizeIPStage_Truncate(t *testing.T) {
+	p := buildPipeline(t, `{"stages":[{"type":"anonymize_ip","config":{"fields":["client_ip","remote_addr","forwarded","message"]}}]}`)
+
+	entry := &models.Log{
+		Message: "Login failed for 203.0.113.77 via 2001:db8:abcd:12::1, retry at 10:30:00",
+		Fields: models.Fields{
+			"client_ip":   "198.51.100.23",
+			"remote_addr": "[2001:db8:abcd:12::1]:51234",
+			"forwarded":   []interface{}{"192.0.2.9", "unknown"},
+		},
+	}
+	if _, err := p.Process(context.Background(), entry); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if entry.Fields["client_ip"] != "198.51.100.0" {
+		t.Errorf("Expected last octet zeroed, got %v", entry.Fields["client_ip"])
+	}
+	if entry.Fields["remote_addr"] != "[2001:db8:abcd::]:51234" {
+		t.Errorf("Expected IPv6 truncated to /48 with port kept, got %v", entry.Fields["remote_addr"])
+	}
+	forwarded := entry.Fields["forwarded"].([]interface{})
+	if forwarded[0] != "192.0.2.0" || forwarded[1] != "unknown" {
+		t.Errorf("Expected list items to be anonymized, got %v", forwarded)
+	}
+	expected := "Login failed for 203.0.113.0 via 2001:db8:abcd::, retry at 10:30:00"
+	if entry.Message != expected {
+		t.Errorf("Expected message %q, got %q", expected, entry.Message)
+	}
+}
+
+func TestAnonymizeIPStage_Hash(t *testing.T) {
+	p := buildPipeline(t, `{"stages":[{"type":"anonymize_ip","config":{"fields":["ip"],"method":"hash","key":"secret"}}]}`)
+
+	hash := func(ip string) string {
+		entry := &models.Log{Message: "m", Fields: models.Fields{"ip": ip}}
+		p.Process(context.Background(), entry)
+		return entry.Fields["ip"].(string)
+	}
+
+	first, second, other := hash("198.51.100.23"), hash("198.51.100.23"), hash("198.51.100.24")
+	if !strings.HasPrefix(first, "ip-") || strings.Contains(first, "198.51") {
+		t.Errorf("Expected a hashed address, got %q", first)
+	}
+	if first != second {
+		t.Errorf("Expected the same address to hash identically, got %q and %q", first, second)
+	}
+	if first == other {
+		t.Errorf("Expected different addresses to hash differently")
+	}
+}
+
+func TestAnonymizeIPStage_InvalidConfig(t *testing.T) {
+	configs := []string{
+		`{}`,
+		`{"fields":["ip"],"method":"encrypt"}`,
+		`{"fields":["ip"],"method":"hash"}`,
+		`{"fields":["ip"],"ipv4_prefix":33}`,
+	}
+	for _, cfg := range configs {
+		if _, err := newAnonymizeIPProcessor([]byte(cfg)); err == nil {
+			t.Errorf("Expected error for config %s", cfg)
+		}
+	}
+}
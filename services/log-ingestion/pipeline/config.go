@@ -0,0 +1,151 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"log-processing-system/services/log-ingestion/features"
+	"log-processing-system/services/log-ingestion/logger"
+)
+
+// StageConfig declares one stage of the pipeline
+type StageConfig struct {
+	Name    string          `json:"name"`
+	Type    string          `json:"type"`
+	Sources []string        `json:"sources,omitempty"`
+	OnError string          `json:"on_error,omitempty"`
+	Config  json.RawMessage `json:"config,omitempty"`
+}
+
+// Config declares the ordered stages of the pipeline
+type Config struct {
+	Stages []StageConfig `json:"stages"`
+}
+
+// Factory builds a processor from its stage-specific configuration
+type Factory func(config json.RawMessage) (Processor, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a stage type available to declarative configuration.
+// It panics if the type is registered twice.
+func Register(stageType string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := registry[stageType]; exists {
+		panic(fmt.Sprintf("pipeline: stage type %q registered twice", stageType))
+	}
+	registry[stageType] = factory
+}
+
+// StageTypes returns the registered stage types
+func StageTypes() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	types := make([]string, 0, len(registry))
+	for t := range registry {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// LoadConfig reads a JSON pipeline configuration file
+func LoadConfig(path string) (Config, error) {
+	var cfg Config
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read pipeline config %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse pipeline config %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// Build constructs a pipeline from its declarative configuration, leaving
+// out the stages whose feature flag is off
+func Build(cfg Config, log *logger.Logger) (*Pipeline, error) {
+	stages := make([]*Stage, 0, len(cfg.Stages))
+	names := make(map[string]bool)
+
+	for i, sc := range cfg.Stages {
+		if sc.Type == "" {
+			return nil, fmt.Errorf("pipeline stage %d: type is required", i)
+		}
+		if sc.Name == "" {
+			sc.Name = fmt.Sprintf("%s-%d", sc.Type, i)
+		}
+		if names[sc.Name] {
+			return nil, fmt.Errorf("pipeline stage %q: duplicate name", sc.Name)
+		}
+		names[sc.Name] = true
+
+		// A stage switched off by its feature flag is not built at all
+		if !features.StageEnabled(sc.Name, sc.Type) {
+			log.WithFields(map[string]interface{}{
+				"stage": sc.Name,
+				"type":  sc.Type,
+			}).Info("Pipeline stage disabled by feature flag")
+			continue
+		}
+
+		switch sc.OnError {
+		case "":
+			sc.OnError = OnErrorSkip
+		case OnErrorSkip, OnErrorDrop, OnErrorFail:
+		default:
+			return nil, fmt.Errorf("pipeline stage %q: invalid on_error %q", sc.Name, sc.OnError)
+		}
+
+		registryMu.RLock()
+		factory, ok := registry[sc.Type]
+		registryMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("pipeline stage %q: unknown type %q", sc.Name, sc.Type)
+		}
+
+		processor, err := factory(sc.Config)
+		if err != nil {
+			return nil, fmt.Errorf("pipeline stage %q: %w", sc.Name, err)
+		}
+
+		stage := &Stage{
+			Name:      sc.Name,
+			Type:      sc.Type,
+			Processor: processor,
+			OnError:   sc.OnError,
+		}
+		if len(sc.Sources) > 0 {
+			stage.sources = make(map[string]bool, len(sc.Sources))
+			for _, source := range sc.Sources {
+				stage.sources[source] = true
+			}
+		}
+		stages = append(stages, stage)
+	}
+
+	log.WithField("stages", len(stages)).Info("Processing pipeline built")
+
+	return New(stages, log), nil
+}
+
+// decodeConfig unmarshals stage configuration, treating a missing config as empty
+func decodeConfig(raw json.RawMessage, v interface{}) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("invalid stage config: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func init() {
+	Register("correlate", newCorrelateProcessor)
+}
+
+// SessionStore persists session summaries written by correlate stages
+type SessionStore func(sessions []models.Session) error
+
+var sessionStore SessionStore
+
+// SetSessionStore installs where correlate stages write session summaries;
+// it must be called before a pipeline with a correlate stage is built
+func SetSessionStore(store SessionStore) {
+	sessionStore = store
+}
+
+// correlateConfig groups entries by the first of fields that is set, such
+// as a trace or session ID, and keeps a running summary per session. Entries
+// pass through unchanged. On every flush the summaries of sessions that
+// changed are written as open, and sessions idle for timeout are written as
+// closed and forgotten. At most max_sessions are tracked at once.
+type correlateConfig struct {
+	Fields      []string `json:"fields"`
+	Timeout     string   `json:"timeout"`
+	MaxSessions int      `json:"max_sessions"`
+	ErrorLevels []string `json:"error_levels"`
+}
+
+type session struct {
+	summary  models.Session
+	sources  map[string]bool
+	lastSeen time.Time
+	dirty    bool
+}
+
+type correlateProcessor struct {
+	fields      []string
+	timeout     time.Duration
+	maxSessions int
+	errorLevels map[string]bool
+	store       SessionStore
+	now         func() time.Time
+
+	mu       sync.Mutex
+	sessions map[string]*session
+	// unsaved holds summaries whose write failed, retried on the next flush
+	unsaved []models.Session
+}
+
+func newCorrelateProcessor(raw json.RawMessage) (Processor, error) {
+	cfg := correlateConfig{
+		Fields:      []string{"trace_id", "session_id"},
+		Timeout:     "5m",
+		MaxSessions: 10000,
+		ErrorLevels: []string{"error", "fatal"},
+	}
+	if err := decodeConfig(raw, &cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.Fields) == 0 {
+		return nil, errors.New("correlate requires at least one field")
+	}
+	timeout, err := time.ParseDuration(cfg.Timeout)
+	if err != nil || timeout <= 0 {
+		return nil, fmt.Errorf("invalid timeout %q", cfg.Timeout)
+	}
+	if cfg.MaxSessions <= 0 {
+		return nil, fmt.Errorf("invalid max_sessions %d", cfg.MaxSessions)
+	}
+	if sessionStore == nil {
+		return nil, errors.New("correlate requires a session store")
+	}
+
+	errorLevels := make(map[string]bool, len(cfg.ErrorLevels))
+	for _, level := range cfg.ErrorLevels {
+		errorLevels[strings.ToLower(level)] = true
+	}
+
+	return &correlateProcessor{
+		fields:      cfg.Fields,
+		timeout:     timeout,
+		maxSessions: cfg.MaxSessions,
+		errorLevels: errorLevels,
+		store:       sessionStore,
+		now:         time.Now,
+		sessions:    make(map[string]*session),
+	}, nil
+}
+
+func (p *correlateProcessor) Process(ctx context.Context, entry *models.Log) ([]*models.Log, error) {
+	id := p.sessionID(entry)
+	if id == "" {
+		return Keep(entry), nil
+	}
+
+	now := p.now()
+	ts := entry.Timestamp
+	if ts.IsZero() {
+		ts = now
+	}
+	level := strings.ToLower(entry.Level)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s, ok := p.sessions[id]
+	if !ok {
+		if len(p.sessions) >= p.maxSessions {
+			return Keep(entry), nil
+		}
+		s = &session{
+			summary: models.Session{
+				ID:        id,
+				StartedAt: ts,
+				EndedAt:   ts,
+				Levels:    make(map[string]int64),
+				Status:    models.SessionOpen,
+			},
+			sources: make(map[string]bool),
+		}
+		p.sessions[id] = s
+	}
+
+	// Entries may arrive out of order, so either end can move
+	if ts.Before(s.summary.StartedAt) {
+		s.summary.StartedAt = ts
+	}
+	if ts.After(s.summary.EndedAt) {
+		s.summary.EndedAt = ts
+	}
+	s.summary.EntryCount++
+	s.summary.Levels[level]++
+	if p.errorLevels[level] {
+		s.summary.ErrorCount++
+	}
+	if entry.Source != "" {
+		s.sources[entry.Source] = true
+	}
+	s.lastSeen = now
+	s.dirty = true
+
+	return Keep(entry), nil
+}
+
+// Flush writes the summaries of sessions that changed and closes idle ones,
+// or all of them when forced. It releases no entries.
+func (p *correlateProcessor) Flush(now time.Time, force bool) []*models.Log {
+	p.mu.Lock()
+	summaries := p.unsaved
+	p.unsaved = nil
+	for id, s := range p.sessions {
+		closed := force || now.Sub(s.lastSeen) >= p.timeout
+		if !closed && !s.dirty {
+			continue
+		}
+		if closed {
+			s.summary.Status = models.SessionClosed
+			delete(p.sessions, id)
+		}
+		s.dirty = false
+		summaries = append(summaries, s.snapshot())
+	}
+	p.mu.Unlock()
+
+	if len(summaries) == 0 {
+		return nil
+	}
+	if err := p.store(summaries); err != nil {
+		pipelineLogger.WithError(err).WithField("sessions", len(summaries)).Error("Failed to store session summaries, retrying on next flush")
+
+		p.mu.Lock()
+		p.unsaved = append(p.unsaved, summaries...)
+		p.mu.Unlock()
+	}
+	return nil
+}
+
+// sessionID returns the first configured field that is set, or ""; numeric
+// IDs are formatted as decoded from JSON
+func (p *correlateProcessor) sessionID(entry *models.Log) string {
+	for _, field := range p.fields {
+		if id, ok := fieldString(entry, field); ok && id != "" {
+			return id
+		}
+		if n, ok := entry.Fields[field].(float64); ok {
+			return strconv.FormatFloat(n, 'f', -1, 64)
+		}
+	}
+	return ""
+}
+
+// snapshot copies the summary so it can be stored outside the lock
+func (s *session) snapshot() models.Session {
+	summary := s.summary
+	summary.DurationMs = summary.EndedAt.Sub(summary.StartedAt).Milliseconds()
+	summary.Levels = make(map[string]int64, len(s.summary.Levels))
+	for level, count := range s.summary.Levels {
+		summary.Levels[level] = count
+	}
+	summary.Sources = make([]string, 0, len(s.sources))
+	for source := range s.sources {
+		summary.Sources = append(summary.Sources, source)
+	}
+	sort.Strings(summary.Sources)
+	return summary
+}
@@ -0,0 +1,117 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+// withSessionStore installs a capturing session store for one test
+func withSessionStore(t *testing.T, store SessionStore) {
+	previous := sessionStore
+	SetSessionStore(store)
+	t.Cleanup(func() { sessionStore = previous })
+}
+
+func TestCorrelateStage(t *testing.T) {
+	var stored []models.Session
+	withSessionStore(t, func(sessions []models.Session) error {
+		stored = append(stored, sessions...)
+		return nil
+	})
+
+	p := buildPipeline(t, `{"stages":[{"type":"correlate","config":{"timeout":"1m"}}]}`)
+	correlator := p.stages[0].Processor.(*correlateProcessor)
+	clock := time.Date(2025, 8, 29, 12, 0, 0, 0, time.UTC)
+	correlator.now = func() time.Time { return clock }
+
+	entries := []*models.Log{
+		{Message: "checkout started", Level: "info", Source: "web", Timestamp: clock, Fields: models.Fields{"trace_id": "t1"}},
+		{Message: "charge failed", Level: "ERROR", Source: "payments", Timestamp: clock.Add(1500 * time.Millisecond), Fields: models.Fields{"trace_id": "t1"}},
+		{Message: "db query", Level: "debug", Source: "payments", Timestamp: clock.Add(500 * time.Millisecond), Fields: models.Fields{"trace_id": "t1"}},
+		{Message: "login", Level: "info", Source: "web", Timestamp: clock, Fields: models.Fields{"session_id": 42.0}},
+		{Message: "uncorrelated", Level: "info", Source: "web", Timestamp: clock},
+	}
+	for _, entry := range entries {
+		if out, err := p.Process(context.Background(), entry); err != nil || len(out) != 1 {
+			t.Fatalf("Expected entry to pass through, got %d entries (err %v)", len(out), err)
+		}
+	}
+
+	p.Flush(context.Background(), clock.Add(10*time.Second), false)
+	if len(stored) != 2 {
+		t.Fatalf("Expected two open session summaries, got %+v", stored)
+	}
+	var trace models.Session
+	for _, s := range stored {
+		if s.ID == "t1" {
+			trace = s
+		}
+	}
+	if trace.Status != models.SessionOpen || trace.EntryCount != 3 || trace.ErrorCount != 1 || trace.DurationMs != 1500 {
+		t.Errorf("Unexpected trace summary: %+v", trace)
+	}
+	if len(trace.Sources) != 2 || trace.Sources[0] != "payments" || trace.Levels["error"] != 1 {
+		t.Errorf("Unexpected trace sources or levels: %+v", trace)
+	}
+
+	// Unchanged open sessions are not written again
+	stored = nil
+	p.Flush(context.Background(), clock.Add(20*time.Second), false)
+	if len(stored) != 0 {
+		t.Errorf("Expected no writes for unchanged sessions, got %+v", stored)
+	}
+
+	p.Flush(context.Background(), clock.Add(time.Minute), false)
+	if len(stored) != 2 || stored[0].Status != models.SessionClosed || len(correlator.sessions) != 0 {
+		t.Errorf("Expected both sessions closed after the timeout, got %+v", stored)
+	}
+}
+
+func TestCorrelateStage_RetriesFailedWrites(t *testing.T) {
+	fail := true
+	var stored []models.Session
+	withSessionStore(t, func(sessions []models.Session) error {
+		if fail {
+			return errors.New("database down")
+		}
+		stored = append(stored, sessions...)
+		return nil
+	})
+
+	processor, err := newCorrelateProcessor([]byte(`{"fields":["request_id"]}`))
+	if err != nil {
+		t.Fatalf("Failed to create stage: %v", err)
+	}
+	correlator := processor.(*correlateProcessor)
+	correlator.Process(context.Background(), &models.Log{Level: "info", Fields: models.Fields{"request_id": "r1"}})
+
+	correlator.Flush(time.Now(), true)
+	fail = false
+	correlator.Flush(time.Now(), false)
+	if len(stored) != 1 || stored[0].ID != "r1" || stored[0].Status != models.SessionClosed {
+		t.Errorf("Expected the failed summary to be written on the next flush, got %+v", stored)
+	}
+}
+
+func TestCorrelateStage_InvalidConfig(t *testing.T) {
+	withSessionStore(t, func(sessions []models.Session) error { return nil })
+
+	configs := []string{
+		`{"fields":[]}`,
+		`{"timeout":"soon"}`,
+		`{"max_sessions":0}`,
+	}
+	for _, cfg := range configs {
+		if _, err := newCorrelateProcessor([]byte(cfg)); err == nil {
+			t.Errorf("Expected error for config %s", cfg)
+		}
+	}
+
+	SetSessionStore(nil)
+	if _, err := newCorrelateProcessor([]byte(`{}`)); err == nil {
+		t.Error("Expected error without a session store")
+	}
+}
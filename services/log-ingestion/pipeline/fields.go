@@ -0,0 +1,48 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func init() {
+	Register("add_fields", newAddFieldsProcessor)
+}
+
+// addFieldsConfig attaches static fields to every entry, e.g. region or cluster
+type addFieldsConfig struct {
+	Fields    map[string]interface{} `json:"fields"`
+	Overwrite bool                   `json:"overwrite"`
+}
+
+type addFieldsProcessor struct {
+	fields    map[string]interface{}
+	overwrite bool
+}
+
+func newAddFieldsProcessor(raw json.RawMessage) (Processor, error) {
+	var cfg addFieldsConfig
+	if err := decodeConfig(raw, &cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.Fields) == 0 {
+		return nil, errors.New("add_fields requires at least one field")
+	}
+
+	return &addFieldsProcessor{fields: cfg.Fields, overwrite: cfg.Overwrite}, nil
+}
+
+func (p *addFieldsProcessor) Process(ctx context.Context, entry *models.Log) ([]*models.Log, error) {
+	if entry.Fields == nil {
+		entry.Fields = make(models.Fields, len(p.fields))
+	}
+	for k, v := range p.fields {
+		if _, exists := entry.Fields[k]; exists && !p.overwrite {
+			continue
+		}
+		entry.Fields[k] = v
+	}
+	return Keep(entry), nil
+}
@@ -0,0 +1,94 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func init() {
+	Register("filter", newFilterProcessor)
+}
+
+// filterConfig selects entries by level, source and message. All given
+// conditions must match. Matching entries are dropped, or with keep_matching
+// only matching entries are kept.
+type filterConfig struct {
+	Levels          []string `json:"levels"`
+	Sources         []string `json:"sources"`
+	MessageContains []string `json:"message_contains"`
+	MessageRegex    string   `json:"message_regex"`
+	KeepMatching    bool     `json:"keep_matching"`
+}
+
+type filterProcessor struct {
+	levels       map[string]bool
+	sources      map[string]bool
+	contains     []string
+	regex        *regexp.Regexp
+	keepMatching bool
+}
+
+func newFilterProcessor(raw json.RawMessage) (Processor, error) {
+	var cfg filterConfig
+	if err := decodeConfig(raw, &cfg); err != nil {
+		return nil, err
+	}
+	return buildFilter(cfg)
+}
+
+// buildFilter compiles a filter config; other stages reuse it to select entries
+func buildFilter(cfg filterConfig) (*filterProcessor, error) {
+	p := &filterProcessor{
+		contains:     cfg.MessageContains,
+		keepMatching: cfg.KeepMatching,
+	}
+	if len(cfg.Levels) > 0 {
+		p.levels = make(map[string]bool, len(cfg.Levels))
+		for _, level := range cfg.Levels {
+			p.levels[strings.ToLower(level)] = true
+		}
+	}
+	if len(cfg.Sources) > 0 {
+		p.sources = make(map[string]bool, len(cfg.Sources))
+		for _, source := range cfg.Sources {
+			p.sources[source] = true
+		}
+	}
+	if cfg.MessageRegex != "" {
+		re, err := regexp.Compile(cfg.MessageRegex)
+		if err != nil {
+			return nil, err
+		}
+		p.regex = re
+	}
+
+	return p, nil
+}
+
+func (p *filterProcessor) Process(ctx context.Context, entry *models.Log) ([]*models.Log, error) {
+	if p.matches(entry) == p.keepMatching {
+		return Keep(entry), nil
+	}
+	return nil, nil
+}
+
+func (p *filterProcessor) matches(entry *models.Log) bool {
+	if p.levels != nil && !p.levels[strings.ToLower(entry.Level)] {
+		return false
+	}
+	if p.sources != nil && !p.sources[entry.Source] {
+		return false
+	}
+	for _, s := range p.contains {
+		if !strings.Contains(entry.Message, s) {
+			return false
+		}
+	}
+	if p.regex != nil && !p.regex.MatchString(entry.Message) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,167 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"log-processing-system/services/log-ingestion/models"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+func init() {
+	Register("geoip", newGeoIPProcessor)
+}
+
+// geoIPConfig maps fields containing IP addresses to the prefix of the
+// location fields added for them, e.g. {"client_ip": "client_geo"} adds
+// client_geo.country_code, client_geo.city, client_geo.asn and so on
+type geoIPConfig struct {
+	Database    string            `json:"database"`
+	ASNDatabase string            `json:"asn_database"`
+	Fields      map[string]string `json:"fields"`
+	Language    string            `json:"language"`
+}
+
+// geoLookup is satisfied by *maxminddb.Reader
+type geoLookup interface {
+	Lookup(ip net.IP, result interface{}) error
+}
+
+// cityRecord is the subset of a GeoLite2/GeoIP2 City or Country record we use
+type cityRecord struct {
+	Country struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Location struct {
+		Latitude  float64 `maxminddb:"latitude"`
+		Longitude float64 `maxminddb:"longitude"`
+	} `maxminddb:"location"`
+}
+
+// asnRecord is a GeoLite2 ASN record
+type asnRecord struct {
+	Number       uint   `maxminddb:"autonomous_system_number"`
+	Organization string `maxminddb:"autonomous_system_organization"`
+}
+
+type geoIPProcessor struct {
+	city     geoLookup
+	asn      geoLookup
+	fields   []string
+	prefixes map[string]string
+	language string
+}
+
+func newGeoIPProcessor(raw json.RawMessage) (Processor, error) {
+	cfg := geoIPConfig{Language: "en"}
+	if err := decodeConfig(raw, &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Database == "" && cfg.ASNDatabase == "" {
+		return nil, errors.New("geoip requires database and/or asn_database")
+	}
+	if len(cfg.Fields) == 0 {
+		return nil, errors.New("geoip requires at least one field mapping")
+	}
+
+	p := &geoIPProcessor{
+		prefixes: cfg.Fields,
+		language: cfg.Language,
+	}
+	for field := range cfg.Fields {
+		p.fields = append(p.fields, field)
+	}
+	sort.Strings(p.fields)
+
+	if cfg.Database != "" {
+		reader, err := maxminddb.Open(cfg.Database)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open GeoIP database %s: %w", cfg.Database, err)
+		}
+		p.city = reader
+	}
+	if cfg.ASNDatabase != "" {
+		reader, err := maxminddb.Open(cfg.ASNDatabase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open ASN database %s: %w", cfg.ASNDatabase, err)
+		}
+		p.asn = reader
+	}
+
+	return p, nil
+}
+
+func (p *geoIPProcessor) Process(ctx context.Context, entry *models.Log) ([]*models.Log, error) {
+	for _, field := range p.fields {
+		value, ok := entry.Fields[field].(string)
+		if !ok {
+			continue
+		}
+
+		ip := parseIP(value)
+		if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() {
+			continue
+		}
+
+		prefix := p.prefixes[field]
+		if err := p.annotate(entry, prefix, ip); err != nil {
+			return nil, fmt.Errorf("lookup %s: %w", field, err)
+		}
+	}
+
+	return Keep(entry), nil
+}
+
+func (p *geoIPProcessor) annotate(entry *models.Log, prefix string, ip net.IP) error {
+	if p.city != nil {
+		var record cityRecord
+		if err := p.city.Lookup(ip, &record); err != nil {
+			return err
+		}
+		setIfPresent(entry, prefix+".country_code", record.Country.ISOCode)
+		setIfPresent(entry, prefix+".country", record.Country.Names[p.language])
+		setIfPresent(entry, prefix+".city", record.City.Names[p.language])
+		if record.Location.Latitude != 0 || record.Location.Longitude != 0 {
+			entry.Fields[prefix+".latitude"] = record.Location.Latitude
+			entry.Fields[prefix+".longitude"] = record.Location.Longitude
+		}
+	}
+
+	if p.asn != nil {
+		var record asnRecord
+		if err := p.asn.Lookup(ip, &record); err != nil {
+			return err
+		}
+		if record.Number != 0 {
+			entry.Fields[prefix+".asn"] = record.Number
+		}
+		setIfPresent(entry, prefix+".as_org", record.Organization)
+	}
+
+	return nil
+}
+
+// parseIP accepts a bare IP or a host:port pair such as a RemoteAddr
+func parseIP(value string) net.IP {
+	if ip := net.ParseIP(value); ip != nil {
+		return ip
+	}
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		return net.ParseIP(host)
+	}
+	return nil
+}
+
+func setIfPresent(entry *models.Log, key, value string) {
+	if value != "" {
+		entry.Fields[key] = value
+	}
+}
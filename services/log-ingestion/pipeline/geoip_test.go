@@ -0,0 +1,81 @@
+package pipeline
+
+import (
+	"context"
+	"log-processing-system/services/log-ingestion/models"
+	"net"
+	"testing"
+)
+
+// fakeGeoDB answers lookups for a single IP
+type fakeGeoDB struct {
+	ip      string
+	fill    func(result interface{})
+	lookups int
+}
+
+func (f *fakeGeoDB) Lookup(ip net.IP, result interface{}) error {
+	f.lookups++
+	if ip.String() == f.ip {
+		f.fill(result)
+	}
+	return nil
+}
+
+func TestGeoIPStage(t *testing.T) {
+	city := &fakeGeoDB{ip: "81.2.69.142", fill: func(result interface{}) {
+		record := result.(*cityRecord)
+		record.Country.ISOCode = "GB"
+		record.Country.Names = map[string]string{"en": "United Kingdom"}
+		record.City.Names = map[string]string{"en": "London"}
+		record.Location.Latitude = 51.5
+		record.Location.Longitude = -0.12
+	}}
+	asn := &fakeGeoDB{ip: "81.2.69.142", fill: func(result interface{}) {
+		record := result.(*asnRecord)
+		record.Number = 20712
+		record.Organization = "Andrews & Arnold Ltd"
+	}}
+
+	p := &geoIPProcessor{
+		city:     city,
+		asn:      asn,
+		fields:   []string{"client_ip"},
+		prefixes: map[string]string{"client_ip": "client_geo"},
+		language: "en",
+	}
+
+	entry := &models.Log{Message: "login", Fields: models.Fields{"client_ip": "81.2.69.142:52311"}}
+	if _, err := p.Process(context.Background(), entry); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"client_geo.country_code": "GB",
+		"client_geo.country":      "United Kingdom",
+		"client_geo.city":         "London",
+		"client_geo.latitude":     51.5,
+		"client_geo.asn":          uint(20712),
+		"client_geo.as_org":       "Andrews & Arnold Ltd",
+	}
+	for k, v := range expected {
+		if entry.Fields[k] != v {
+			t.Errorf("Field %s: expected %v, got %v", k, v, entry.Fields[k])
+		}
+	}
+
+	private := &models.Log{Message: "login", Fields: models.Fields{"client_ip": "10.0.0.5"}}
+	p.Process(context.Background(), private)
+	if city.lookups != 1 {
+		t.Errorf("Expected private addresses to be skipped, got %d lookups", city.lookups)
+	}
+}
+
+func TestGeoIPStage_RequiresConfig(t *testing.T) {
+	if _, err := newGeoIPProcessor([]byte(`{"fields":{"ip":"geo"}}`)); err == nil {
+		t.Errorf("Expected error without a database")
+	}
+	if _, err := newGeoIPProcessor([]byte(`{"database":"/nonexistent.mmdb","fields":{"ip":"geo"}}`)); err == nil {
+		t.Errorf("Expected error for a missing database file")
+	}
+}
@@ -0,0 +1,152 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func init() {
+	Register("json_flatten", newJSONFlattenProcessor)
+}
+
+// jsonFlattenConfig promotes the keys of a JSON-encoded message into fields
+type jsonFlattenConfig struct {
+	MaxDepth    int      `json:"max_depth"`
+	MaxKeys     int      `json:"max_keys"`
+	Separator   string   `json:"separator"`
+	Prefix      string   `json:"prefix"`
+	MessageKeys []string `json:"message_keys"`
+	Overwrite   bool     `json:"overwrite"`
+}
+
+type jsonFlattenProcessor struct {
+	cfg jsonFlattenConfig
+}
+
+// truncatedField marks entries whose JSON body exceeded max_keys
+const truncatedField = "json_truncated"
+
+func newJSONFlattenProcessor(raw json.RawMessage) (Processor, error) {
+	cfg := jsonFlattenConfig{
+		MaxDepth:    3,
+		MaxKeys:     50,
+		Separator:   ".",
+		MessageKeys: []string{"message", "msg"},
+	}
+	if err := decodeConfig(raw, &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.MaxDepth <= 0 {
+		cfg.MaxDepth = 1
+	}
+	if cfg.MaxKeys <= 0 {
+		cfg.MaxKeys = 50
+	}
+
+	return &jsonFlattenProcessor{cfg: cfg}, nil
+}
+
+func (p *jsonFlattenProcessor) Process(ctx context.Context, entry *models.Log) ([]*models.Log, error) {
+	body := strings.TrimSpace(entry.Message)
+	if !strings.HasPrefix(body, "{") || !strings.HasSuffix(body, "}") {
+		return Keep(entry), nil
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+
+	var object map[string]interface{}
+	if err := decoder.Decode(&object); err != nil {
+		// Not JSON after all; keep the message as plain text
+		return Keep(entry), nil
+	}
+
+	// Use the embedded message, if any, as the entry's message
+	for _, key := range p.cfg.MessageKeys {
+		if msg, ok := object[key].(string); ok && msg != "" {
+			entry.Message = msg
+			delete(object, key)
+			break
+		}
+	}
+
+	if entry.Fields == nil {
+		entry.Fields = make(models.Fields)
+	}
+
+	added := 0
+	truncated := p.flatten(entry, p.cfg.Prefix, object, 1, &added)
+	if truncated {
+		entry.Fields[truncatedField] = true
+	}
+
+	if len(entry.Fields) == 0 {
+		entry.Fields = nil
+	}
+
+	return Keep(entry), nil
+}
+
+// flatten adds object's keys to entry.Fields and reports whether max_keys was hit
+func (p *jsonFlattenProcessor) flatten(entry *models.Log, prefix string, object map[string]interface{}, depth int, added *int) bool {
+	// Iterate in sorted order so truncation is deterministic
+	keys := make([]string, 0, len(object))
+	for k := range object {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		name := k
+		if prefix != "" {
+			name = prefix + p.cfg.Separator + k
+		}
+
+		if nested, ok := object[k].(map[string]interface{}); ok && depth < p.cfg.MaxDepth {
+			if p.flatten(entry, name, nested, depth+1, added) {
+				return true
+			}
+			continue
+		}
+
+		if *added >= p.cfg.MaxKeys {
+			return true
+		}
+		if _, exists := entry.Fields[name]; exists && !p.cfg.Overwrite {
+			continue
+		}
+		entry.Fields[name] = flattenValue(object[k])
+		*added++
+	}
+
+	return false
+}
+
+// flattenValue converts decoded JSON into a field value, keeping objects
+// beyond max_depth as compact JSON strings
+func flattenValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i
+		}
+		if f, err := value.Float64(); err == nil {
+			return f
+		}
+		return value.String()
+	case map[string]interface{}, []interface{}:
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(value); err != nil {
+			return nil
+		}
+		return strings.TrimSuffix(buf.String(), "\n")
+	default:
+		return value
+	}
+}
@@ -0,0 +1,61 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func TestJSONFlattenStage(t *testing.T) {
+	p := buildPipeline(t, `{"stages":[{"type":"json_flatten","config":{"max_depth":2}}]}`)
+
+	entry := &models.Log{
+		Message: `{"msg":"payment failed","status":502,"latency":1.5,"user":{"id":"u-1","geo":{"country":"DE"}},"tags":["a","b"]}`,
+	}
+	if _, err := p.Process(context.Background(), entry); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if entry.Message != "payment failed" {
+		t.Errorf("Expected embedded message to be promoted, got %q", entry.Message)
+	}
+
+	expected := map[string]interface{}{
+		"status":   int64(502),
+		"latency":  1.5,
+		"user.id":  "u-1",
+		"user.geo": `{"country":"DE"}`,
+		"tags":     `["a","b"]`,
+	}
+	for k, v := range expected {
+		if entry.Fields[k] != v {
+			t.Errorf("Field %s: expected %v (%T), got %v (%T)", k, v, v, entry.Fields[k], entry.Fields[k])
+		}
+	}
+}
+
+func TestJSONFlattenStage_MaxKeys(t *testing.T) {
+	p := buildPipeline(t, `{"stages":[{"type":"json_flatten","config":{"max_keys":2}}]}`)
+
+	entry := &models.Log{Message: `{"a":1,"b":2,"c":3}`}
+	p.Process(context.Background(), entry)
+
+	if len(entry.Fields) != 3 || entry.Fields[truncatedField] != true {
+		t.Errorf("Expected 2 fields plus truncation marker, got %v", entry.Fields)
+	}
+	if entry.Message != `{"a":1,"b":2,"c":3}` {
+		t.Errorf("Expected message without embedded message key to be kept, got %q", entry.Message)
+	}
+}
+
+func TestJSONFlattenStage_PlainText(t *testing.T) {
+	p := buildPipeline(t, `{"stages":[{"type":"json_flatten"}]}`)
+
+	for _, message := range []string{"plain text", "{not json}"} {
+		entry := &models.Log{Message: message}
+		p.Process(context.Background(), entry)
+		if entry.Message != message || entry.Fields != nil {
+			t.Errorf("Expected %q to pass through unchanged, got %q %v", message, entry.Message, entry.Fields)
+		}
+	}
+}
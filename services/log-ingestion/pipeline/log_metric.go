@@ -0,0 +1,249 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"log-processing-system/services/log-ingestion/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	Register("log_metric", newLogMetricProcessor)
+}
+
+// MetricsRegisterer is where log_metric stages register their collectors
+var MetricsRegisterer prometheus.Registerer = prometheus.DefaultRegisterer
+
+// logMetricRuleConfig turns matching entries into a Prometheus metric.
+// Value and label sources name a capture group of message_regex or an
+// entry field (message, level, source or a structured field).
+type logMetricRuleConfig struct {
+	Name            string            `json:"name"`
+	Help            string            `json:"help"`
+	Type            string            `json:"type"`
+	Levels          []string          `json:"levels"`
+	Sources         []string          `json:"sources"`
+	MessageContains []string          `json:"message_contains"`
+	MessageRegex    string            `json:"message_regex"`
+	Value           string            `json:"value"`
+	Scale           float64           `json:"scale"`
+	Labels          map[string]string `json:"labels"`
+	Buckets         []float64         `json:"buckets"`
+}
+
+type logMetricConfig struct {
+	Metrics []logMetricRuleConfig `json:"metrics"`
+}
+
+type logMetricRule struct {
+	name       string
+	metricType string
+	match      *filterProcessor
+	value      string
+	scale      float64
+	labelNames []string
+	labelFrom  []string
+
+	counter   *prometheus.CounterVec
+	gauge     *prometheus.GaugeVec
+	histogram *prometheus.HistogramVec
+}
+
+type logMetricProcessor struct {
+	rules []*logMetricRule
+}
+
+func newLogMetricProcessor(raw json.RawMessage) (Processor, error) {
+	var cfg logMetricConfig
+	if err := decodeConfig(raw, &cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.Metrics) == 0 {
+		return nil, errors.New("log_metric requires at least one metric")
+	}
+
+	p := &logMetricProcessor{}
+	for _, mc := range cfg.Metrics {
+		rule, err := newLogMetricRule(mc)
+		if err != nil {
+			return nil, fmt.Errorf("metric %q: %w", mc.Name, err)
+		}
+		p.rules = append(p.rules, rule)
+	}
+	return p, nil
+}
+
+func newLogMetricRule(mc logMetricRuleConfig) (*logMetricRule, error) {
+	if mc.Name == "" {
+		return nil, errors.New("name is required")
+	}
+	if mc.Help == "" {
+		mc.Help = "Derived from log entries"
+	}
+	if mc.Scale == 0 {
+		mc.Scale = 1
+	}
+	if mc.Type == "" {
+		mc.Type = "counter"
+	}
+	if mc.Type != "counter" && mc.Value == "" {
+		return nil, fmt.Errorf("%s metrics require a value", mc.Type)
+	}
+
+	match, err := buildFilter(filterConfig{
+		Levels:          mc.Levels,
+		Sources:         mc.Sources,
+		MessageContains: mc.MessageContains,
+		MessageRegex:    mc.MessageRegex,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rule := &logMetricRule{
+		name:       mc.Name,
+		metricType: mc.Type,
+		match:      match,
+		value:      mc.Value,
+		scale:      mc.Scale,
+	}
+	for label := range mc.Labels {
+		rule.labelNames = append(rule.labelNames, label)
+	}
+	sort.Strings(rule.labelNames)
+	for _, label := range rule.labelNames {
+		rule.labelFrom = append(rule.labelFrom, mc.Labels[label])
+	}
+
+	var collector prometheus.Collector
+	switch mc.Type {
+	case "counter":
+		collector = prometheus.NewCounterVec(prometheus.CounterOpts{Name: mc.Name, Help: mc.Help}, rule.labelNames)
+	case "gauge":
+		collector = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: mc.Name, Help: mc.Help}, rule.labelNames)
+	case "histogram":
+		buckets := mc.Buckets
+		if len(buckets) == 0 {
+			buckets = prometheus.DefBuckets
+		}
+		collector = prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: mc.Name, Help: mc.Help, Buckets: buckets}, rule.labelNames)
+	default:
+		return nil, fmt.Errorf("unknown metric type %q", mc.Type)
+	}
+
+	// Rebuilding a pipeline with the same metric reuses the registered collector
+	if err := MetricsRegisterer.Register(collector); err != nil {
+		var already prometheus.AlreadyRegisteredError
+		if !errors.As(err, &already) {
+			return nil, err
+		}
+		collector = already.ExistingCollector
+	}
+
+	var ok bool
+	switch mc.Type {
+	case "counter":
+		rule.counter, ok = collector.(*prometheus.CounterVec)
+	case "gauge":
+		rule.gauge, ok = collector.(*prometheus.GaugeVec)
+	default:
+		rule.histogram, ok = collector.(*prometheus.HistogramVec)
+	}
+	if !ok {
+		return nil, fmt.Errorf("already registered as a different metric type")
+	}
+
+	return rule, nil
+}
+
+func (p *logMetricProcessor) Process(ctx context.Context, entry *models.Log) ([]*models.Log, error) {
+	for _, rule := range p.rules {
+		if err := rule.observe(entry); err != nil {
+			return nil, fmt.Errorf("metric %q: %w", rule.name, err)
+		}
+	}
+	return Keep(entry), nil
+}
+
+func (r *logMetricRule) observe(entry *models.Log) error {
+	if !r.match.matches(entry) {
+		return nil
+	}
+
+	var captures map[string]string
+	if r.match.regex != nil {
+		if m := r.match.regex.FindStringSubmatch(entry.Message); m != nil {
+			captures = make(map[string]string)
+			for i, name := range r.match.regex.SubexpNames() {
+				if name != "" {
+					captures[name] = m[i]
+				}
+			}
+		}
+	}
+	lookup := func(name string) (interface{}, bool) {
+		if v, ok := captures[name]; ok {
+			return v, true
+		}
+		if s, ok := fieldString(entry, name); ok {
+			return s, true
+		}
+		v, ok := entry.Fields[name]
+		return v, ok
+	}
+
+	labels := make([]string, len(r.labelFrom))
+	for i, from := range r.labelFrom {
+		if v, ok := lookup(from); ok {
+			labels[i] = fmt.Sprint(v)
+		}
+	}
+
+	value := 1.0
+	if r.value != "" {
+		raw, ok := lookup(r.value)
+		if !ok {
+			return fmt.Errorf("value %q not found", r.value)
+		}
+		v, err := metricValue(raw)
+		if err != nil {
+			return fmt.Errorf("value %q: %w", r.value, err)
+		}
+		value = v * r.scale
+	}
+
+	switch {
+	case r.counter != nil:
+		if value < 0 {
+			return fmt.Errorf("counter value %v is negative", value)
+		}
+		r.counter.WithLabelValues(labels...).Add(value)
+	case r.gauge != nil:
+		r.gauge.WithLabelValues(labels...).Set(value)
+	default:
+		r.histogram.WithLabelValues(labels...).Observe(value)
+	}
+	return nil
+}
+
+func metricValue(raw interface{}) (float64, error) {
+	switch v := raw.(type) {
+	case float64:
+		return v, nil
+	case int64:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case json.Number:
+		return v.Float64()
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(v), 64)
+	}
+	return 0, fmt.Errorf("cannot use %T as a number", raw)
+}
@@ -0,0 +1,125 @@
+package pipeline
+
+import (
+	"context"
+	"math"
+	"testing"
+	"log-processing-system/services/log-ingestion/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func useTestRegistry(t *testing.T) *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	previous := MetricsRegisterer
+	MetricsRegisterer = registry
+	t.Cleanup(func() { MetricsRegisterer = previous })
+	return registry
+}
+
+func TestLogMetricStage(t *testing.T) {
+	registry := useTestRegistry(t)
+
+	p := buildPipeline(t, `{"stages":[{"type":"log_metric","config":{"metrics":[
+		{"name":"http_request_duration_seconds","type":"histogram","sources":["nginx"],
+		 "message_regex":"(?P<method>[A-Z]+) (?P<path>\\S+) took (?P<ms>\\d+)ms",
+		 "value":"ms","scale":0.001,"labels":{"method":"method"},"buckets":[0.05,0.1,0.5]},
+		{"name":"log_errors_total","levels":["error"],"labels":{"source":"source","code":"error_code"}},
+		{"name":"queue_depth","type":"gauge","value":"depth","message_contains":["queue"]}
+	]}}]}`)
+
+	entries := []*models.Log{
+		{Source: "nginx", Level: "info", Message: "GET /orders took 40ms"},
+		{Source: "nginx", Level: "info", Message: "GET /orders took 300ms"},
+		{Source: "nginx", Level: "info", Message: "POST /orders took 80ms"},
+		{Source: "api", Level: "error", Message: "boom", Fields: models.Fields{"error_code": "E42"}},
+		{Source: "api", Level: "ERROR", Message: "boom again", Fields: models.Fields{"error_code": "E42"}},
+		{Source: "worker", Level: "info", Message: "queue stats", Fields: models.Fields{"depth": float64(17)}},
+	}
+	for _, entry := range entries {
+		out, err := p.Process(context.Background(), entry)
+		if err != nil || len(out) != 1 {
+			t.Fatalf("Expected entry to pass through unchanged, got %v, %v", out, err)
+		}
+	}
+
+	if n := testutil.CollectAndCount(registry, "http_request_duration_seconds"); n != 2 {
+		t.Errorf("Expected 2 histogram series (GET, POST), got %d", n)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		switch family.GetName() {
+		case "log_errors_total":
+			metric := family.GetMetric()[0]
+			if metric.GetCounter().GetValue() != 2 {
+				t.Errorf("Expected 2 errors counted, got %v", metric.GetCounter().GetValue())
+			}
+		case "queue_depth":
+			if v := family.GetMetric()[0].GetGauge().GetValue(); v != 17 {
+				t.Errorf("Expected queue_depth 17, got %v", v)
+			}
+		case "http_request_duration_seconds":
+			for _, metric := range family.GetMetric() {
+				if metric.GetLabel()[0].GetValue() == "GET" {
+					h := metric.GetHistogram()
+					if h.GetSampleCount() != 2 || math.Abs(h.GetSampleSum()-0.34) > 1e-9 {
+						t.Errorf("Expected 2 GET samples summing to 0.34s, got %d and %v", h.GetSampleCount(), h.GetSampleSum())
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestLogMetricStage_ReusesRegisteredCollector(t *testing.T) {
+	useTestRegistry(t)
+
+	config := []byte(`{"metrics":[{"name":"rebuilt_total"}]}`)
+	if _, err := newLogMetricProcessor(config); err != nil {
+		t.Fatalf("Failed to create stage: %v", err)
+	}
+	if _, err := newLogMetricProcessor(config); err != nil {
+		t.Errorf("Expected rebuilding the same metric to succeed, got %v", err)
+	}
+	if _, err := newLogMetricProcessor([]byte(`{"metrics":[{"name":"rebuilt_total","type":"gauge","value":"v"}]}`)); err == nil {
+		t.Errorf("Expected error registering the same name as a different type")
+	}
+}
+
+func TestLogMetricStage_ValueErrors(t *testing.T) {
+	useTestRegistry(t)
+
+	processor, err := newLogMetricProcessor([]byte(`{"metrics":[{"name":"bytes_total","value":"bytes"}]}`))
+	if err != nil {
+		t.Fatalf("Failed to create stage: %v", err)
+	}
+
+	for _, fields := range []models.Fields{{}, {"bytes": "lots"}, {"bytes": float64(-1)}} {
+		if _, err := processor.Process(context.Background(), &models.Log{Message: "m", Fields: fields}); err == nil {
+			t.Errorf("Expected error for fields %v", fields)
+		}
+	}
+}
+
+func TestLogMetricStage_InvalidConfig(t *testing.T) {
+	useTestRegistry(t)
+
+	configs := []string{
+		`{"metrics":[]}`,
+		`{"metrics":[{"type":"counter"}]}`,
+		`{"metrics":[{"name":"g","type":"gauge"}]}`,
+		`{"metrics":[{"name":"s","type":"summary","value":"v"}]}`,
+		`{"metrics":[{"name":"invalid-name"}]}`,
+		`{"metrics":[{"name":"r","message_regex":"("}]}`,
+	}
+	for _, config := range configs {
+		if _, err := newLogMetricProcessor([]byte(config)); err == nil {
+			t.Errorf("Expected error for config %s", config)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func init() {
+	Register("lookup", newLookupProcessor)
+}
+
+// lookupConfig joins the value of field against an external table and adds
+// the matching row's columns as fields. The table is either a CSV file,
+// re-read when it changes, or an HTTP endpoint returning a JSON object for
+// url with {key} replaced, whose answers are cached for cache_ttl.
+type lookupConfig struct {
+	Field     string   `json:"field"`
+	Prefix    string   `json:"prefix"`
+	Columns   []string `json:"columns"`
+	Overwrite bool     `json:"overwrite"`
+
+	CSV            string `json:"csv"`
+	KeyColumn      string `json:"key_column"`
+	ReloadInterval string `json:"reload_interval"`
+
+	URL       string            `json:"url"`
+	Headers   map[string]string `json:"headers"`
+	Timeout   string            `json:"timeout"`
+	CacheTTL  string            `json:"cache_ttl"`
+	CacheSize int               `json:"cache_size"`
+}
+
+// lookupTable returns the row for key, or false when there is none
+type lookupTable interface {
+	lookup(ctx context.Context, key string) (map[string]interface{}, bool, error)
+}
+
+type lookupProcessor struct {
+	field     string
+	prefix    string
+	columns   []string
+	overwrite bool
+	table     lookupTable
+}
+
+func newLookupProcessor(raw json.RawMessage) (Processor, error) {
+	cfg := lookupConfig{
+		ReloadInterval: "1m",
+		Timeout:        "2s",
+		CacheTTL:       "5m",
+		CacheSize:      10000,
+	}
+	if err := decodeConfig(raw, &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Field == "" {
+		return nil, errors.New("lookup requires a field")
+	}
+	if (cfg.CSV == "") == (cfg.URL == "") {
+		return nil, errors.New("lookup requires exactly one of csv or url")
+	}
+
+	p := &lookupProcessor{
+		field:     cfg.Field,
+		prefix:    cfg.Prefix,
+		columns:   cfg.Columns,
+		overwrite: cfg.Overwrite,
+	}
+
+	var err error
+	if cfg.CSV != "" {
+		p.table, err = newCSVTable(cfg.CSV, cfg.KeyColumn, cfg.ReloadInterval)
+	} else {
+		p.table, err = newHTTPTable(cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *lookupProcessor) Process(ctx context.Context, entry *models.Log) ([]*models.Log, error) {
+	key, ok := fieldString(entry, p.field)
+	if !ok {
+		if value, exists := entry.Fields[p.field]; exists && value != nil {
+			key = fmt.Sprint(value)
+		}
+	}
+	if key == "" {
+		return Keep(entry), nil
+	}
+
+	row, found, err := p.table.lookup(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return Keep(entry), nil
+	}
+
+	columns := p.columns
+	if len(columns) == 0 {
+		for column := range row {
+			columns = append(columns, column)
+		}
+	}
+	for _, column := range columns {
+		v, ok := row[column]
+		if !ok {
+			continue
+		}
+		name := p.prefix + column
+		if _, exists := entry.Fields[name]; exists && !p.overwrite {
+			continue
+		}
+		if entry.Fields == nil {
+			entry.Fields = models.Fields{}
+		}
+		entry.Fields[name] = v
+	}
+	return Keep(entry), nil
+}
+
+// csvTable holds a CSV file in memory keyed by one of its columns
+type csvTable struct {
+	path      string
+	keyColumn string
+	watcher   *fileWatcher
+	rows      atomic.Value // map[string]map[string]interface{}
+}
+
+func newCSVTable(path, keyColumn, reloadInterval string) (*csvTable, error) {
+	interval, err := time.ParseDuration(reloadInterval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reload_interval %q: %w", reloadInterval, err)
+	}
+
+	t := &csvTable{path: path, keyColumn: keyColumn}
+	if t.watcher, err = newFileWatcher(path, interval); err != nil {
+		return nil, fmt.Errorf("failed to read lookup table: %w", err)
+	}
+	rows, err := t.load()
+	if err != nil {
+		return nil, err
+	}
+	t.rows.Store(rows)
+	return t, nil
+}
+
+// load reads the file; the first row holds the column names
+func (t *csvTable) load() (map[string]map[string]interface{}, error) {
+	f, err := os.Open(t.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lookup table: %w", err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lookup table header %s: %w", t.path, err)
+	}
+
+	keyIndex := 0
+	if t.keyColumn != "" {
+		keyIndex = -1
+		for i, column := range header {
+			if column == t.keyColumn {
+				keyIndex = i
+			}
+		}
+		if keyIndex < 0 {
+			return nil, fmt.Errorf("lookup table %s has no column %q", t.path, t.keyColumn)
+		}
+	}
+
+	rows := make(map[string]map[string]interface{})
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse lookup table %s: %w", t.path, err)
+		}
+
+		row := make(map[string]interface{}, len(header)-1)
+		for i, column := range header {
+			if i != keyIndex {
+				row[column] = record[i]
+			}
+		}
+		rows[record[keyIndex]] = row
+	}
+	return rows, nil
+}
+
+func (t *csvTable) lookup(ctx context.Context, key string) (map[string]interface{}, bool, error) {
+	t.reloadIfChanged()
+
+	rows := t.rows.Load().(map[string]map[string]interface{})
+	row, ok := rows[key]
+	return row, ok, nil
+}
+
+func (t *csvTable) reloadIfChanged() {
+	changed, err := t.watcher.changed()
+	if err != nil {
+		pipelineLogger.WithError(err).WithField("file", t.path).Warn("Failed to check lookup table for changes")
+		return
+	}
+	if !changed {
+		return
+	}
+
+	rows, err := t.load()
+	if err != nil {
+		pipelineLogger.WithError(err).WithField("file", t.path).Error("Lookup table reload failed, keeping previous rows")
+		return
+	}
+	t.rows.Store(rows)
+
+	pipelineLogger.WithFields(map[string]interface{}{
+		"file": t.path,
+		"rows": len(rows),
+	}).Info("Lookup table reloaded")
+}
+
+type cachedLookup struct {
+	row     map[string]interface{}
+	found   bool
+	expires time.Time
+}
+
+// httpTable looks keys up at an HTTP endpoint. Rows and misses (404) are
+// cached; errors are not.
+type httpTable struct {
+	url       string
+	headers   map[string]string
+	client    *http.Client
+	ttl       time.Duration
+	cacheSize int
+
+	mu    sync.Mutex
+	cache map[string]cachedLookup
+}
+
+func newHTTPTable(cfg lookupConfig) (*httpTable, error) {
+	if !strings.Contains(cfg.URL, "{key}") {
+		return nil, errors.New("url must contain a {key} placeholder")
+	}
+	timeout, err := time.ParseDuration(cfg.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timeout %q: %w", cfg.Timeout, err)
+	}
+	ttl, err := time.ParseDuration(cfg.CacheTTL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cache_ttl %q: %w", cfg.CacheTTL, err)
+	}
+
+	return &httpTable{
+		url:       cfg.URL,
+		headers:   cfg.Headers,
+		client:    &http.Client{Timeout: timeout},
+		ttl:       ttl,
+		cacheSize: cfg.CacheSize,
+		cache:     make(map[string]cachedLookup),
+	}, nil
+}
+
+func (t *httpTable) lookup(ctx context.Context, key string) (map[string]interface{}, bool, error) {
+	now := time.Now()
+
+	t.mu.Lock()
+	cached, ok := t.cache[key]
+	t.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.row, cached.found, nil
+	}
+
+	row, found, err := t.fetch(ctx, key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	t.mu.Lock()
+	if len(t.cache) >= t.cacheSize {
+		// Evict arbitrary entries; map iteration order is random
+		for k := range t.cache {
+			delete(t.cache, k)
+			if len(t.cache) < t.cacheSize*9/10 {
+				break
+			}
+		}
+	}
+	if t.cacheSize > 0 {
+		t.cache[key] = cachedLookup{row: row, found: found, expires: now.Add(t.ttl)}
+	}
+	t.mu.Unlock()
+
+	return row, found, nil
+}
+
+func (t *httpTable) fetch(ctx context.Context, key string) (map[string]interface{}, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.ReplaceAll(t.url, "{key}", url.PathEscape(key)), nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for name, value := range t.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("lookup request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, false, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, false, fmt.Errorf("lookup request returned %s", resp.Status)
+	}
+
+	var row map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&row); err != nil {
+		return nil, false, fmt.Errorf("invalid lookup response: %w", err)
+	}
+	return row, true, nil
+}
@@ -0,0 +1,112 @@
+package pipeline
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func TestLookupStage_CSV(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "hosts.csv")
+	if err := os.WriteFile(file, []byte("team,host_id,tier\npayments,h-1,gold\nsearch,h-2,silver\n"), 0644); err != nil {
+		t.Fatalf("Failed to write table: %v", err)
+	}
+
+	processor, err := newLookupProcessor([]byte(`{"field":"host_id","csv":"` + filepath.ToSlash(file) +
+		`","key_column":"host_id","prefix":"host_","reload_interval":"1ns"}`))
+	if err != nil {
+		t.Fatalf("Failed to create stage: %v", err)
+	}
+
+	entry := &models.Log{Message: "m", Fields: models.Fields{"host_id": "h-1"}}
+	if _, err := processor.Process(context.Background(), entry); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if entry.Fields["host_team"] != "payments" || entry.Fields["host_tier"] != "gold" {
+		t.Errorf("Expected host_team and host_tier to be added, got %v", entry.Fields)
+	}
+
+	missing := &models.Log{Message: "m", Fields: models.Fields{"host_id": "h-9"}}
+	processor.Process(context.Background(), missing)
+	if len(missing.Fields) != 1 {
+		t.Errorf("Expected unknown key to leave fields alone, got %v", missing.Fields)
+	}
+
+	later := time.Now().Add(time.Minute)
+	os.WriteFile(file, []byte("team,host_id,tier\nledger,h-1,gold\n"), 0644)
+	os.Chtimes(file, later, later)
+
+	entry = &models.Log{Message: "m", Fields: models.Fields{"host_id": "h-1"}}
+	processor.Process(context.Background(), entry)
+	if entry.Fields["host_team"] != "ledger" {
+		t.Errorf("Expected reloaded team ledger, got %v", entry.Fields["host_team"])
+	}
+}
+
+func TestLookupStage_HTTP(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		switch r.URL.Path {
+		case "/customers/c 1":
+			w.Write([]byte(`{"plan":"enterprise","region":"eu"}`))
+		case "/customers/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	processor, err := newLookupProcessor([]byte(`{"field":"customer_id","url":"` + server.URL +
+		`/customers/{key}","columns":["plan"]}`))
+	if err != nil {
+		t.Fatalf("Failed to create stage: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		entry := &models.Log{Message: "m", Fields: models.Fields{"customer_id": "c 1"}}
+		if _, err := processor.Process(context.Background(), entry); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if entry.Fields["plan"] != "enterprise" {
+			t.Errorf("Expected plan enterprise, got %v", entry.Fields["plan"])
+		}
+		if _, ok := entry.Fields["region"]; ok {
+			t.Error("Expected only configured columns to be added")
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		processor.Process(context.Background(), &models.Log{Message: "m", Fields: models.Fields{"customer_id": "unknown"}})
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("Expected hits and misses to be cached (2 requests), got %d", n)
+	}
+
+	_, err = processor.Process(context.Background(), &models.Log{Message: "m", Fields: models.Fields{"customer_id": "broken"}})
+	if err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("Expected server error to fail the stage, got %v", err)
+	}
+}
+
+func TestLookupStage_InvalidConfig(t *testing.T) {
+	configs := []string{
+		`{"csv":"table.csv"}`,
+		`{"field":"host_id"}`,
+		`{"field":"host_id","csv":"a.csv","url":"http://x/{key}"}`,
+		`{"field":"host_id","url":"http://x/hosts"}`,
+	}
+	for _, cfg := range configs {
+		if _, err := newLookupProcessor([]byte(cfg)); err == nil {
+			t.Errorf("Expected error for config %s", cfg)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func init() {
+	Register("mapping", newMappingProcessor)
+}
+
+// mappingRuleConfig moves producer-specific fields onto the common schema.
+// Rename keys and targets are structured fields or the envelope attributes
+// message, level, source and timestamp; types then converts fields (by
+// their new name) to string, int, float or bool.
+type mappingRuleConfig struct {
+	Sources   []string          `json:"sources"`
+	Rename    map[string]string `json:"rename"`
+	Types     map[string]string `json:"types"`
+	Remove    []string          `json:"remove"`
+	Overwrite bool              `json:"overwrite"`
+}
+
+type mappingConfig struct {
+	Rules []mappingRuleConfig `json:"rules"`
+}
+
+type mappingRule struct {
+	sources   map[string]bool
+	rename    map[string]string
+	types     map[string]string
+	remove    []string
+	overwrite bool
+}
+
+type mappingProcessor struct {
+	rules []mappingRule
+}
+
+func newMappingProcessor(raw json.RawMessage) (Processor, error) {
+	var cfg mappingConfig
+	if err := decodeConfig(raw, &cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.Rules) == 0 {
+		return nil, errors.New("mapping requires at least one rule")
+	}
+
+	p := &mappingProcessor{}
+	for i, rc := range cfg.Rules {
+		if len(rc.Rename) == 0 && len(rc.Types) == 0 && len(rc.Remove) == 0 {
+			return nil, fmt.Errorf("rule %d: requires rename, types or remove", i)
+		}
+		for from, to := range rc.Rename {
+			if from == "" || to == "" {
+				return nil, fmt.Errorf("rule %d: rename needs non-empty field names", i)
+			}
+		}
+		for name, typ := range rc.Types {
+			switch typ {
+			case "string", "int", "float", "bool":
+			default:
+				return nil, fmt.Errorf("rule %d: unsupported type %q for %s", i, typ, name)
+			}
+			if isEnvelopeField(name) {
+				return nil, fmt.Errorf("rule %d: cannot change the type of %s", i, name)
+			}
+		}
+
+		rule := mappingRule{
+			rename:    rc.Rename,
+			types:     rc.Types,
+			remove:    rc.Remove,
+			overwrite: rc.Overwrite,
+		}
+		if len(rc.Sources) > 0 {
+			rule.sources = make(map[string]bool, len(rc.Sources))
+			for _, source := range rc.Sources {
+				rule.sources[source] = true
+			}
+		}
+		p.rules = append(p.rules, rule)
+	}
+
+	return p, nil
+}
+
+func (p *mappingProcessor) Process(ctx context.Context, entry *models.Log) ([]*models.Log, error) {
+	for _, rule := range p.rules {
+		if rule.sources != nil && !rule.sources[entry.Source] {
+			continue
+		}
+
+		for from, to := range rule.rename {
+			value, ok := takeField(entry, from)
+			if !ok {
+				continue
+			}
+			if hasField(entry, to) && !rule.overwrite {
+				// Leave the original in place rather than lose it
+				setField(entry, from, value)
+				continue
+			}
+			if err := setField(entry, to, value); err != nil {
+				return nil, fmt.Errorf("field %s: %w", to, err)
+			}
+		}
+
+		for name, typ := range rule.types {
+			value, ok := entry.Fields[name]
+			if !ok || value == nil {
+				continue
+			}
+			converted, err := convertAny(value, typ)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", name, err)
+			}
+			entry.Fields[name] = converted
+		}
+
+		for _, name := range rule.remove {
+			delete(entry.Fields, name)
+		}
+	}
+
+	return Keep(entry), nil
+}
+
+func isEnvelopeField(name string) bool {
+	switch name {
+	case "message", "level", "source", "timestamp":
+		return true
+	}
+	return false
+}
+
+// hasField reports whether an envelope attribute is set or a field exists
+func hasField(entry *models.Log, name string) bool {
+	switch name {
+	case "message":
+		return entry.Message != ""
+	case "level":
+		return entry.Level != ""
+	case "source":
+		return entry.Source != ""
+	case "timestamp":
+		return !entry.Timestamp.IsZero()
+	}
+	_, ok := entry.Fields[name]
+	return ok
+}
+
+// takeField removes and returns an envelope attribute or a field
+func takeField(entry *models.Log, name string) (interface{}, bool) {
+	if isEnvelopeField(name) {
+		if !hasField(entry, name) {
+			return nil, false
+		}
+		var value interface{}
+		switch name {
+		case "message":
+			value, entry.Message = entry.Message, ""
+		case "level":
+			value, entry.Level = entry.Level, ""
+		case "source":
+			value, entry.Source = entry.Source, ""
+		case "timestamp":
+			value, entry.Timestamp = entry.Timestamp, time.Time{}
+		}
+		return value, true
+	}
+
+	value, ok := entry.Fields[name]
+	if ok {
+		delete(entry.Fields, name)
+	}
+	return value, ok
+}
+
+// setField assigns an envelope attribute, converting the value, or a field
+func setField(entry *models.Log, name string, value interface{}) error {
+	switch name {
+	case "message", "level", "source":
+		s, err := convertAny(value, "string")
+		if err != nil {
+			return err
+		}
+		switch name {
+		case "message":
+			entry.Message = s.(string)
+		case "level":
+			entry.Level = s.(string)
+		default:
+			entry.Source = s.(string)
+		}
+		return nil
+	case "timestamp":
+		ts, err := toTime(value)
+		if err != nil {
+			return err
+		}
+		entry.Timestamp = ts
+		return nil
+	}
+
+	if entry.Fields == nil {
+		entry.Fields = make(models.Fields)
+	}
+	entry.Fields[name] = value
+	return nil
+}
+
+// convertAny converts a decoded JSON value to the configured type
+func convertAny(value interface{}, typ string) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		if typ == "int" {
+			// Accept "42.0" as well as "42"
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				return convertAny(f, typ)
+			}
+		}
+		return convertValue(strings.TrimSpace(v), typ)
+	case float64:
+		switch typ {
+		case "int":
+			if v != math.Trunc(v) || math.IsInf(v, 0) {
+				return nil, fmt.Errorf("%v is not an integer", v)
+			}
+			return int64(v), nil
+		case "float":
+			return v, nil
+		case "bool":
+			return v != 0, nil
+		default:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		}
+	case int64:
+		return convertAny(float64(v), typ)
+	case int:
+		return convertAny(float64(v), typ)
+	case bool:
+		switch typ {
+		case "int":
+			if v {
+				return int64(1), nil
+			}
+			return int64(0), nil
+		case "float":
+			if v {
+				return 1.0, nil
+			}
+			return 0.0, nil
+		case "bool":
+			return v, nil
+		default:
+			return strconv.FormatBool(v), nil
+		}
+	case time.Time:
+		if typ == "string" {
+			return v.Format(time.RFC3339Nano), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot convert %T to %s", value, typ)
+}
+
+// toTime accepts an RFC 3339 string or Unix seconds
+func toTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		return time.Parse(time.RFC3339Nano, strings.TrimSpace(v))
+	case float64:
+		sec, frac := math.Modf(v)
+		return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
+	case int64:
+		return time.Unix(v, 0).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("cannot use %T as a timestamp", value)
+}
@@ -0,0 +1,106 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func TestMappingStage_RenameToEnvelope(t *testing.T) {
+	p := buildPipeline(t, `{"stages":[{"type":"mapping","config":{"rules":[{
+		"sources":["legacy-app"],
+		"rename":{"msg":"message","sev":"level","ts":"timestamp","code":"status"},
+		"types":{"status":"int"},
+		"remove":["internal"]}]}}]}`)
+
+	entry := &models.Log{Source: "legacy-app", Fields: models.Fields{
+		"msg": "disk full", "sev": "error", "ts": float64(1700000000), "code": "507", "internal": true,
+	}}
+	entries, err := p.Process(context.Background(), entry)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	got := entries[0]
+	if got.Message != "disk full" || got.Level != "error" {
+		t.Errorf("Expected message and level to be mapped, got %q/%q", got.Message, got.Level)
+	}
+	if !got.Timestamp.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("Expected timestamp from ts, got %v", got.Timestamp)
+	}
+	if got.Fields["status"] != int64(507) {
+		t.Errorf("Expected status 507 as int, got %#v", got.Fields["status"])
+	}
+	for _, name := range []string{"msg", "sev", "ts", "code", "internal"} {
+		if _, ok := got.Fields[name]; ok {
+			t.Errorf("Expected %s to be removed, got %v", name, got.Fields)
+		}
+	}
+
+	other := &models.Log{Source: "other", Fields: models.Fields{"msg": "x"}}
+	p.Process(context.Background(), other)
+	if other.Message != "" || other.Fields["msg"] != "x" {
+		t.Errorf("Expected entries from other sources to be untouched, got %+v", other)
+	}
+}
+
+func TestMappingStage_ExistingTarget(t *testing.T) {
+	p := buildPipeline(t, `{"stages":[{"type":"mapping","config":{"rules":[{"rename":{"msg":"message"}}]}}]}`)
+
+	entry := &models.Log{Message: "original", Fields: models.Fields{"msg": "other"}}
+	p.Process(context.Background(), entry)
+	if entry.Message != "original" || entry.Fields["msg"] != "other" {
+		t.Errorf("Expected existing message to be kept along with msg, got %+v", entry)
+	}
+
+	p = buildPipeline(t, `{"stages":[{"type":"mapping","config":{"rules":[{"rename":{"msg":"message"},"overwrite":true}]}}]}`)
+	entry = &models.Log{Message: "original", Fields: models.Fields{"msg": "other"}}
+	p.Process(context.Background(), entry)
+	if entry.Message != "other" {
+		t.Errorf("Expected overwrite to replace message, got %q", entry.Message)
+	}
+}
+
+func TestMappingStage_Types(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		typ      string
+		expected interface{}
+		err      bool
+	}{
+		{"42", "int", int64(42), false},
+		{"42.0", "int", int64(42), false},
+		{float64(3), "int", int64(3), false},
+		{float64(3.5), "int", nil, true},
+		{"1.5", "float", 1.5, false},
+		{float64(200), "string", "200", false},
+		{"true", "bool", true, false},
+		{true, "int", int64(1), false},
+		{"abc", "int", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := convertAny(tt.value, tt.typ)
+		if (err != nil) != tt.err {
+			t.Errorf("convertAny(%#v, %s) error = %v, want error %v", tt.value, tt.typ, err, tt.err)
+			continue
+		}
+		if !tt.err && got != tt.expected {
+			t.Errorf("convertAny(%#v, %s) = %#v, want %#v", tt.value, tt.typ, got, tt.expected)
+		}
+	}
+}
+
+func TestMappingStage_InvalidConfig(t *testing.T) {
+	configs := []string{
+		`{"rules":[]}`,
+		`{"rules":[{"sources":["a"]}]}`,
+		`{"rules":[{"types":{"x":"date"}}]}`,
+		`{"rules":[{"types":{"level":"int"}}]}`,
+	}
+	for _, cfg := range configs {
+		if _, err := newMappingProcessor([]byte(cfg)); err == nil {
+			t.Errorf("Expected error for config %s", cfg)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func init() {
+	Register("multiline", newMultilineProcessor)
+}
+
+// multilineConfig merges continuation lines, such as stack trace frames,
+// into the entry that started them. A line continues the previous entry
+// from the same source when it matches continuation_pattern or does not
+// match start_pattern. Entries are held until the next start line, until
+// timeout passes without a continuation, or until max_lines is reached.
+type multilineConfig struct {
+	StartPattern        string   `json:"start_pattern"`
+	ContinuationPattern string   `json:"continuation_pattern"`
+	Timeout             string   `json:"timeout"`
+	MaxLines            int      `json:"max_lines"`
+	KeyFields           []string `json:"key_fields"`
+}
+
+type pendingEntry struct {
+	entry   *models.Log
+	lines   int
+	updated time.Time
+}
+
+type multilineProcessor struct {
+	start        *regexp.Regexp
+	continuation *regexp.Regexp
+	timeout      time.Duration
+	maxLines     int
+	keyFields    []string
+	now          func() time.Time
+
+	mu      sync.Mutex
+	pending map[string]*pendingEntry
+}
+
+func newMultilineProcessor(raw json.RawMessage) (Processor, error) {
+	cfg := multilineConfig{Timeout: "2s", MaxLines: 500}
+	if err := decodeConfig(raw, &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.StartPattern == "" && cfg.ContinuationPattern == "" {
+		return nil, errors.New("multiline requires start_pattern or continuation_pattern")
+	}
+
+	p := &multilineProcessor{
+		maxLines:  cfg.MaxLines,
+		keyFields: cfg.KeyFields,
+		now:       time.Now,
+		pending:   make(map[string]*pendingEntry),
+	}
+
+	var err error
+	if cfg.StartPattern != "" {
+		if p.start, err = regexp.Compile(cfg.StartPattern); err != nil {
+			return nil, fmt.Errorf("invalid start_pattern: %w", err)
+		}
+	}
+	if cfg.ContinuationPattern != "" {
+		if p.continuation, err = regexp.Compile(cfg.ContinuationPattern); err != nil {
+			return nil, fmt.Errorf("invalid continuation_pattern: %w", err)
+		}
+	}
+	if p.timeout, err = time.ParseDuration(cfg.Timeout); err != nil || p.timeout <= 0 {
+		return nil, fmt.Errorf("invalid timeout %q", cfg.Timeout)
+	}
+	if p.maxLines <= 1 {
+		return nil, fmt.Errorf("max_lines must be greater than 1")
+	}
+
+	return p, nil
+}
+
+func (p *multilineProcessor) Process(ctx context.Context, entry *models.Log) ([]*models.Log, error) {
+	key := p.key(entry)
+	now := p.now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	current, ok := p.pending[key]
+	if ok && p.continues(entry.Message) {
+		current.entry.Message += "\n" + entry.Message
+		current.lines++
+		current.updated = now
+
+		if current.lines < p.maxLines {
+			return nil, nil
+		}
+		delete(p.pending, key)
+		return Keep(current.release()), nil
+	}
+
+	// A start line (or a continuation with nothing to continue) begins a new
+	// entry and releases the previous one
+	p.pending[key] = &pendingEntry{entry: entry, lines: 1, updated: now}
+	if ok {
+		return Keep(current.release()), nil
+	}
+	return nil, nil
+}
+
+// Flush releases entries that have not been continued within the timeout
+func (p *multilineProcessor) Flush(now time.Time, force bool) []*models.Log {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var released []*models.Log
+	for key, pending := range p.pending {
+		if !force && now.Sub(pending.updated) < p.timeout {
+			continue
+		}
+		delete(p.pending, key)
+		released = append(released, pending.release())
+	}
+	return released
+}
+
+func (p *multilineProcessor) continues(line string) bool {
+	if p.continuation != nil && p.continuation.MatchString(line) {
+		return true
+	}
+	return p.start != nil && !p.start.MatchString(line)
+}
+
+func (p *multilineProcessor) key(entry *models.Log) string {
+	var b strings.Builder
+	b.WriteString(entry.Source)
+	for _, field := range p.keyFields {
+		b.WriteByte(0)
+		fmt.Fprint(&b, entry.Fields[field])
+	}
+	return b.String()
+}
+
+// release returns the merged entry, recording how many lines it spans
+func (e *pendingEntry) release() *models.Log {
+	if e.lines > 1 {
+		if e.entry.Fields == nil {
+			e.entry.Fields = models.Fields{}
+		}
+		e.entry.Fields["line_count"] = e.lines
+	}
+	return e.entry
+}
@@ -0,0 +1,109 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func newTestMultiline(t *testing.T, config string) (*multilineProcessor, *time.Time) {
+	processor, err := newMultilineProcessor([]byte(config))
+	if err != nil {
+		t.Fatalf("Failed to create stage: %v", err)
+	}
+	p := processor.(*multilineProcessor)
+	clock := time.Date(2025, 8, 29, 12, 0, 0, 0, time.UTC)
+	p.now = func() time.Time { return clock }
+	return p, &clock
+}
+
+func TestMultilineStage_StackTrace(t *testing.T) {
+	p, _ := newTestMultiline(t, `{"continuation_pattern":"^(\\s+at |Caused by:|\\s*\\.\\.\\. \\d+ more)"}`)
+
+	lines := []string{
+		"Exception in thread \"main\" java.lang.IllegalStateException: boom",
+		"    at com.example.Service.run(Service.java:42)",
+		"    at com.example.Main.main(Main.java:7)",
+		"Caused by: java.io.IOException: disk full",
+		"    ... 2 more",
+		"Recovered and continuing",
+	}
+
+	var stored []*models.Log
+	for _, line := range lines {
+		out, err := p.Process(context.Background(), &models.Log{Source: "java", Level: "error", Message: line})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		stored = append(stored, out...)
+	}
+
+	if len(stored) != 1 {
+		t.Fatalf("Expected the trace released when the next entry starts, got %d entries", len(stored))
+	}
+	if stored[0].Fields["line_count"] != 5 {
+		t.Errorf("Expected 5 merged lines, got %v", stored[0].Fields["line_count"])
+	}
+	if stored[0].Message != "Exception in thread \"main\" java.lang.IllegalStateException: boom\n    at com.example.Service.run(Service.java:42)\n    at com.example.Main.main(Main.java:7)\nCaused by: java.io.IOException: disk full\n    ... 2 more" {
+		t.Errorf("Unexpected merged message: %q", stored[0].Message)
+	}
+
+	released := p.Flush(time.Now(), true)
+	if len(released) != 1 || released[0].Message != "Recovered and continuing" || released[0].Fields != nil {
+		t.Errorf("Expected the single-line entry released on force flush, got %+v", released)
+	}
+}
+
+func TestMultilineStage_StartPatternAndTimeout(t *testing.T) {
+	p, clock := newTestMultiline(t, `{"start_pattern":"^\\d{4}-\\d{2}-\\d{2}","timeout":"2s"}`)
+
+	p.Process(context.Background(), &models.Log{Source: "py", Message: "2025-08-29 12:00:00 ERROR Traceback (most recent call last):"})
+	p.Process(context.Background(), &models.Log{Source: "py", Message: `  File "app.py", line 3, in <module>`})
+	p.Process(context.Background(), &models.Log{Source: "other", Message: "2025-08-29 12:00:00 unrelated source"})
+
+	if released := p.Flush(clock.Add(time.Second), false); len(released) != 0 {
+		t.Errorf("Expected nothing released before the timeout, got %d", len(released))
+	}
+
+	*clock = clock.Add(500 * time.Millisecond)
+	p.Process(context.Background(), &models.Log{Source: "py", Message: "ZeroDivisionError: division by zero"})
+
+	released := p.Flush(clock.Add(1600*time.Millisecond), false)
+	if len(released) != 1 || released[0].Source != "other" {
+		t.Fatalf("Expected only the idle entry released, got %+v", released)
+	}
+
+	released = p.Flush(clock.Add(3*time.Second), false)
+	if len(released) != 1 || released[0].Fields["line_count"] != 3 {
+		t.Errorf("Expected the traceback released after the timeout, got %+v", released)
+	}
+}
+
+func TestMultilineStage_MaxLines(t *testing.T) {
+	p, _ := newTestMultiline(t, `{"continuation_pattern":"^\\s","max_lines":3}`)
+
+	var stored []*models.Log
+	for _, line := range []string{"start", " one", " two", " three"} {
+		out, _ := p.Process(context.Background(), &models.Log{Message: line})
+		stored = append(stored, out...)
+	}
+
+	if len(stored) != 1 || stored[0].Message != "start\n one\n two" {
+		t.Errorf("Expected entry released at max_lines, got %+v", stored)
+	}
+}
+
+func TestMultilineStage_InvalidConfig(t *testing.T) {
+	configs := []string{
+		`{}`,
+		`{"start_pattern":"("}`,
+		`{"continuation_pattern":"^\\s","timeout":"later"}`,
+		`{"continuation_pattern":"^\\s","max_lines":1}`,
+	}
+	for _, config := range configs {
+		if _, err := newMultilineProcessor([]byte(config)); err == nil {
+			t.Errorf("Expected error for config %s", config)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func init() {
+	Register("classify_noise", newClassifyNoiseProcessor)
+}
+
+// builtinNoiseRules are the rules enabled unless builtin lists fewer
+var builtinNoiseRules = map[string]filterConfig{
+	"health_check": {
+		MessageRegex: `(?i)(\b(GET|HEAD)\s+/(health|healthz|healthcheck|ready|readyz|readiness|live|livez|liveness|ping|status)\b|\bhealth ?check (passed|ok|succeeded)\b)`,
+	},
+	"heartbeat": {
+		MessageRegex: `(?i)\b(heart ?beat|keep-?alive|still alive)\b`,
+	},
+	"retry_succeeded": {
+		MessageRegex: `(?i)(\b(retry|retried|retrying|attempt)\b.*\b(succeeded|successful|recovered)\b|\bsucceeded (after|on) (\d+ )?(retries|retry|attempts?)\b)`,
+	},
+}
+
+// noiseRuleConfig tags entries matching all of its conditions, as in a
+// filter stage, with name as the reason
+type noiseRuleConfig struct {
+	Name            string   `json:"name"`
+	Levels          []string `json:"levels"`
+	Sources         []string `json:"sources"`
+	MessageContains []string `json:"message_contains"`
+	MessageRegex    string   `json:"message_regex"`
+}
+
+// noiseFrequencyConfig is the simple model: an entry is noise once more than
+// threshold entries from its source with the same message, digits aside,
+// arrived in the current window
+type noiseFrequencyConfig struct {
+	Window    string `json:"window"`
+	Threshold int    `json:"threshold"`
+	MaxKeys   int    `json:"max_keys"`
+}
+
+// classifyNoiseConfig tags likely noise - health checks, heartbeats,
+// retries that succeeded - by setting the noise and noise_reason fields, so
+// it can be hidden by default and retained for less time. Entries at
+// protect_levels are never tagged.
+type classifyNoiseConfig struct {
+	Builtin       []string              `json:"builtin"`
+	Rules         []noiseRuleConfig     `json:"rules"`
+	Frequency     *noiseFrequencyConfig `json:"frequency"`
+	ProtectLevels []string              `json:"protect_levels"`
+}
+
+type noiseRule struct {
+	name   string
+	filter *filterProcessor
+}
+
+type frequencyCounter struct {
+	window    time.Duration
+	threshold int
+	maxKeys   int
+
+	mu      sync.Mutex
+	started time.Time
+	counts  map[string]int
+}
+
+type classifyNoiseProcessor struct {
+	rules     []noiseRule
+	frequency *frequencyCounter
+	protected map[string]bool
+	now       func() time.Time
+}
+
+// digits masks the variable parts of messages for the frequency model
+var digits = regexp.MustCompile(`\d+`)
+
+func newClassifyNoiseProcessor(raw json.RawMessage) (Processor, error) {
+	cfg := classifyNoiseConfig{
+		Builtin:       []string{"health_check", "heartbeat", "retry_succeeded"},
+		ProtectLevels: []string{"warn", "error", "fatal"},
+	}
+	if err := decodeConfig(raw, &cfg); err != nil {
+		return nil, err
+	}
+
+	p := &classifyNoiseProcessor{
+		protected: make(map[string]bool, len(cfg.ProtectLevels)),
+		now:       time.Now,
+	}
+	for _, level := range cfg.ProtectLevels {
+		p.protected[strings.ToLower(level)] = true
+	}
+
+	for _, name := range cfg.Builtin {
+		rule, ok := builtinNoiseRules[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown builtin noise rule %q", name)
+		}
+		filter, err := buildFilter(rule)
+		if err != nil {
+			return nil, err
+		}
+		p.rules = append(p.rules, noiseRule{name: name, filter: filter})
+	}
+
+	for i, rule := range cfg.Rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("noise rule %d requires a name", i)
+		}
+		filter, err := buildFilter(filterConfig{
+			Levels:          rule.Levels,
+			Sources:         rule.Sources,
+			MessageContains: rule.MessageContains,
+			MessageRegex:    rule.MessageRegex,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid noise rule %q: %w", rule.Name, err)
+		}
+		p.rules = append(p.rules, noiseRule{name: rule.Name, filter: filter})
+	}
+
+	if cfg.Frequency != nil {
+		freq := noiseFrequencyConfig{Window: "1m", MaxKeys: 10000}
+		if cfg.Frequency.Window != "" {
+			freq.Window = cfg.Frequency.Window
+		}
+		if cfg.Frequency.MaxKeys != 0 {
+			freq.MaxKeys = cfg.Frequency.MaxKeys
+		}
+		window, err := time.ParseDuration(freq.Window)
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf("invalid frequency window %q", freq.Window)
+		}
+		if cfg.Frequency.Threshold <= 0 {
+			return nil, errors.New("frequency requires a positive threshold")
+		}
+		p.frequency = &frequencyCounter{
+			window:    window,
+			threshold: cfg.Frequency.Threshold,
+			maxKeys:   freq.MaxKeys,
+			counts:    make(map[string]int),
+		}
+	}
+
+	if len(p.rules) == 0 && p.frequency == nil {
+		return nil, errors.New("classify_noise requires at least one rule or frequency")
+	}
+	return p, nil
+}
+
+func (p *classifyNoiseProcessor) Process(ctx context.Context, entry *models.Log) ([]*models.Log, error) {
+	if p.protected[strings.ToLower(entry.Level)] {
+		return Keep(entry), nil
+	}
+
+	reason := ""
+	for _, rule := range p.rules {
+		if rule.filter.matches(entry) {
+			reason = rule.name
+			break
+		}
+	}
+	// Entries matched by a rule are not counted, so they cannot push
+	// unrelated messages over the threshold
+	if reason == "" && p.frequency != nil && p.frequency.exceeded(entry, p.now()) {
+		reason = "high_frequency"
+	}
+
+	if reason != "" {
+		if entry.Fields == nil {
+			entry.Fields = make(models.Fields)
+		}
+		entry.Fields[models.NoiseField] = true
+		entry.Fields[models.NoiseReasonField] = reason
+	}
+	return Keep(entry), nil
+}
+
+// exceeded counts the entry in the current window and reports whether its
+// message has gone over the threshold
+func (c *frequencyCounter) exceeded(entry *models.Log, now time.Time) bool {
+	key := entry.Source + "\x00" + digits.ReplaceAllString(entry.Message, "#")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Sub(c.started) >= c.window {
+		c.started = now
+		c.counts = make(map[string]int)
+	}
+	count, ok := c.counts[key]
+	if !ok && len(c.counts) >= c.maxKeys {
+		return false
+	}
+	c.counts[key] = count + 1
+	return count+1 > c.threshold
+}
@@ -0,0 +1,106 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func TestClassifyNoiseStage_Builtin(t *testing.T) {
+	p := buildPipeline(t, `{"stages":[{"type":"classify_noise"}]}`)
+
+	tests := []struct {
+		entry  models.Log
+		reason string
+	}{
+		{models.Log{Message: "GET /healthz 200 1ms", Level: "info"}, "health_check"},
+		{models.Log{Message: "Heartbeat sent to coordinator", Level: "debug"}, "heartbeat"},
+		{models.Log{Message: "Request to billing succeeded after 2 retries", Level: "info"}, "retry_succeeded"},
+		{models.Log{Message: "GET /healthz 503", Level: "error"}, ""},
+		{models.Log{Message: "GET /api/orders 200", Level: "info"}, ""},
+	}
+
+	for _, tt := range tests {
+		entry := tt.entry
+		entries, err := p.Process(context.Background(), &entry)
+		if err != nil || len(entries) != 1 {
+			t.Fatalf("Expected entry to pass through, got %v, %v", entries, err)
+		}
+		if tt.reason == "" {
+			if entry.Fields.IsNoise() {
+				t.Errorf("Expected %q not to be noise, got %v", entry.Message, entry.Fields)
+			}
+			continue
+		}
+		if !entry.Fields.IsNoise() || entry.Fields[models.NoiseReasonField] != tt.reason {
+			t.Errorf("Expected %q to be noise for %s, got %v", entry.Message, tt.reason, entry.Fields)
+		}
+	}
+}
+
+func TestClassifyNoiseStage_Rules(t *testing.T) {
+	p := buildPipeline(t, `{"stages":[{"type":"classify_noise","config":{"builtin":[],
+		"rules":[{"name":"cache_probe","sources":["cache"],"message_contains":["probe"]}]}}]}`)
+
+	entry := &models.Log{Message: "probe ok", Level: "info", Source: "cache"}
+	p.Process(context.Background(), entry)
+	if entry.Fields[models.NoiseReasonField] != "cache_probe" {
+		t.Errorf("Expected cache_probe reason, got %v", entry.Fields)
+	}
+
+	entry = &models.Log{Message: "GET /health", Level: "info", Source: "api"}
+	p.Process(context.Background(), entry)
+	if entry.Fields.IsNoise() {
+		t.Errorf("Expected builtin rules to be disabled, got %v", entry.Fields)
+	}
+}
+
+func TestClassifyNoiseStage_Frequency(t *testing.T) {
+	processor, err := newClassifyNoiseProcessor([]byte(`{"builtin":[],"frequency":{"window":"1m","threshold":2}}`))
+	if err != nil {
+		t.Fatalf("Failed to create stage: %v", err)
+	}
+	classifier := processor.(*classifyNoiseProcessor)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	classifier.now = func() time.Time { return now }
+
+	classify := func(message string) bool {
+		entry := &models.Log{Message: message, Level: "info", Source: "worker"}
+		classifier.Process(context.Background(), entry)
+		return entry.Fields.IsNoise()
+	}
+
+	for _, message := range []string{"Processed batch 11", "Processed batch 12"} {
+		if classify(message) {
+			t.Errorf("Expected %q to stay under the threshold", message)
+		}
+	}
+	if !classify("Processed batch 99") {
+		t.Errorf("Expected third similar message to be noise")
+	}
+	if classify("Worker started") {
+		t.Errorf("Expected a different message not to be noise")
+	}
+
+	now = now.Add(time.Minute)
+	if classify("Processed batch 100") {
+		t.Errorf("Expected counts to reset in a new window")
+	}
+}
+
+func TestClassifyNoiseStage_InvalidConfig(t *testing.T) {
+	configs := []string{
+		`{"builtin":["unknown"]}`,
+		`{"builtin":[]}`,
+		`{"rules":[{"message_contains":["x"]}]}`,
+		`{"rules":[{"name":"bad","message_regex":"("}]}`,
+		`{"frequency":{"threshold":0}}`,
+		`{"frequency":{"window":"soon","threshold":5}}`,
+	}
+	for _, cfg := range configs {
+		if _, err := newClassifyNoiseProcessor([]byte(cfg)); err == nil {
+			t.Errorf("Expected error for config %s", cfg)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+// pipelineLogger is for stages that log outside of Process, e.g. when a
+// watched file is reloaded
+var pipelineLogger = logger.NewFromEnv("log-ingestion", "pipeline")
+
+// Processor is implemented by every pipeline stage. A stage receives one
+// entry and returns the entries to pass on: usually the same entry, none to
+// drop it, or several to split it.
+type Processor interface {
+	Process(ctx context.Context, entry *models.Log) ([]*models.Log, error)
+}
+
+// ProcessorFunc adapts an ordinary function to the Processor interface
+type ProcessorFunc func(ctx context.Context, entry *models.Log) ([]*models.Log, error)
+
+// Process calls f(ctx, entry)
+func (f ProcessorFunc) Process(ctx context.Context, entry *models.Log) ([]*models.Log, error) {
+	return f(ctx, entry)
+}
+
+// Flusher is implemented by processors that hold entries back across calls,
+// such as grouping repeated messages. Flush returns the held entries that
+// are due at now, or every held entry when force is set.
+type Flusher interface {
+	Flush(now time.Time, force bool) []*models.Log
+}
+
+// Closer is implemented by processors holding resources, such as the route
+// stage's sinks, that must be released when the pipeline is shut down
+type Closer interface {
+	Close() error
+}
+
+// OutputFlusher is implemented by processors writing to outputs that buffer
+// entries, such as the route stage's sinks. FlushOutputs sends what the
+// outputs hold now.
+type OutputFlusher interface {
+	FlushOutputs() error
+}
+
+// OutputBuffering is implemented by processors writing to outputs that buffer
+// entries and reports how many are waiting to be sent
+type OutputBuffering interface {
+	Buffered() int
+}
+
+// EmitFunc receives entries released by a flush, ready to be stored
+type EmitFunc func(ctx context.Context, entries []*models.Log)
+
+// Stage error policies
+const (
+	// OnErrorSkip passes the entry on unchanged (default)
+	OnErrorSkip = "skip"
+	// OnErrorDrop discards the entry
+	OnErrorDrop = "drop"
+	// OnErrorFail rejects the entry and returns the error to the caller
+	OnErrorFail = "fail"
+)
+
+// Stage is a configured processor in the pipeline
+type Stage struct {
+	Name      string
+	Type      string
+	Processor Processor
+	OnError   string
+
+	// sources restricts the stage to entries from these sources; nil means all
+	sources map[string]bool
+}
+
+// StageError reports which stage rejected an entry
+type StageError struct {
+	Stage string
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return fmt.Sprintf("pipeline stage %q failed: %v", e.Stage, e.Err)
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// Pipeline runs entries through an ordered list of stages between ingestion and storage
+type Pipeline struct {
+	stages []*Stage
+	logger *logger.Logger
+}
+
+// New creates a pipeline from already constructed stages
+func New(stages []*Stage, log *logger.Logger) *Pipeline {
+	return &Pipeline{
+		stages: stages,
+		logger: log,
+	}
+}
+
+// Keep is a helper for processors that pass a single entry on
+func Keep(entry *models.Log) []*models.Log {
+	return []*models.Log{entry}
+}
+
+// Process runs entry through every stage in order and returns the entries to store
+func (p *Pipeline) Process(ctx context.Context, entry *models.Log) ([]*models.Log, error) {
+	return p.runStages(ctx, Keep(entry), 0)
+}
+
+// runStages runs batch through the stages starting at index from
+func (p *Pipeline) runStages(ctx context.Context, batch []*models.Log, from int) ([]*models.Log, error) {
+	for _, stage := range p.stages[from:] {
+		next := make([]*models.Log, 0, len(batch))
+
+		for _, e := range batch {
+			if !stage.appliesTo(e) {
+				next = append(next, e)
+				continue
+			}
+
+			out, err := stage.Processor.Process(ctx, e)
+			if err != nil {
+				stageLogger := p.logger.WithFields(map[string]interface{}{
+					"stage":      stage.Name,
+					"stage_type": stage.Type,
+					"on_error":   stage.OnError,
+					"source":     e.Source,
+				}).WithError(err)
+
+				switch stage.OnError {
+				case OnErrorFail:
+					stageLogger.WarnContext(ctx, "Pipeline stage rejected log entry")
+					return nil, &StageError{Stage: stage.Name, Err: err}
+				case OnErrorDrop:
+					stageLogger.WarnContext(ctx, "Pipeline stage failed, dropping log entry")
+				default:
+					stageLogger.WarnContext(ctx, "Pipeline stage failed, passing log entry through")
+					next = append(next, e)
+				}
+				continue
+			}
+
+			next = append(next, out...)
+		}
+
+		batch = next
+		if len(batch) == 0 {
+			break
+		}
+	}
+
+	return batch, nil
+}
+
+// HasFlushers reports whether any stage holds entries back and needs Run
+func (p *Pipeline) HasFlushers() bool {
+	for _, stage := range p.stages {
+		if _, ok := stage.Processor.(Flusher); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Run flushes held entries every interval and hands them to emit until ctx
+// is cancelled. Call Flush with force set afterwards to release the rest.
+func (p *Pipeline) Run(ctx context.Context, interval time.Duration, emit EmitFunc) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if entries := p.Flush(ctx, now, false); len(entries) > 0 {
+				emit(ctx, entries)
+			}
+		}
+	}
+}
+
+// Flush collects the entries released by every Flusher stage and runs them
+// through the stages that follow it
+func (p *Pipeline) Flush(ctx context.Context, now time.Time, force bool) []*models.Log {
+	var released []*models.Log
+
+	for i, stage := range p.stages {
+		flusher, ok := stage.Processor.(Flusher)
+		if !ok {
+			continue
+		}
+		entries := flusher.Flush(now, force)
+		if len(entries) == 0 {
+			continue
+		}
+
+		out, err := p.runStages(ctx, entries, i+1)
+		if err != nil {
+			p.logger.WithFields(map[string]interface{}{
+				"stage":   stage.Name,
+				"entries": len(entries),
+			}).WithError(err).WarnContext(ctx, "Flushed log entries rejected by processing pipeline")
+			continue
+		}
+		released = append(released, out...)
+	}
+
+	return released
+}
+
+// Stages returns the names of the configured stages in order
+func (p *Pipeline) Stages() []string {
+	names := make([]string, len(p.stages))
+	for i, stage := range p.stages {
+		names[i] = stage.Name
+	}
+	return names
+}
+
+// FlushOutputs flushes the outputs of every stage implementing
+// OutputFlusher, returning the first error
+func (p *Pipeline) FlushOutputs() error {
+	var failed error
+	for _, stage := range p.stages {
+		flusher, ok := stage.Processor.(OutputFlusher)
+		if !ok {
+			continue
+		}
+		if err := flusher.FlushOutputs(); err != nil && failed == nil {
+			failed = fmt.Errorf("pipeline stage %q: %w", stage.Name, err)
+		}
+	}
+	return failed
+}
+
+// Buffered reports, by stage name, how many entries the outputs of each
+// stage implementing OutputBuffering are waiting to send
+func (p *Pipeline) Buffered() map[string]int {
+	buffered := make(map[string]int)
+	for _, stage := range p.stages {
+		if b, ok := stage.Processor.(OutputBuffering); ok {
+			buffered[stage.Name] = b.Buffered()
+		}
+	}
+	return buffered
+}
+
+func (s *Stage) appliesTo(entry *models.Log) bool {
+	return s.sources == nil || s.sources[entry.Source]
+}
+
+// Close releases every stage implementing Closer. Call it after the final
+// Flush, once no more entries are processed.
+func (p *Pipeline) Close() {
+	for _, stage := range p.stages {
+		closer, ok := stage.Processor.(Closer)
+		if !ok {
+			continue
+		}
+		if err := closer.Close(); err != nil {
+			p.logger.WithFields(map[string]interface{}{
+				"stage":      stage.Name,
+				"stage_type": stage.Type,
+			}).WithError(err).Warn("Failed to close pipeline stage")
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"log-processing-system/services/log-ingestion/features"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func testLogger() *logger.Logger {
+	return logger.New(logger.Config{Level: "ERROR", Service: "test-service", Component: "pipeline"})
+}
+
+func buildPipeline(t *testing.T, config string) *Pipeline {
+	var cfg Config
+	if err := json.Unmarshal([]byte(config), &cfg); err != nil {
+		t.Fatalf("Invalid test config: %v", err)
+	}
+	p, err := Build(cfg, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to build pipeline: %v", err)
+	}
+	return p
+}
+
+func failingStage(onError string) *Stage {
+	return &Stage{
+		Name:    "failing",
+		Type:    "test",
+		OnError: onError,
+		Processor: ProcessorFunc(func(ctx context.Context, entry *models.Log) ([]*models.Log, error) {
+			return nil, errors.New("stage error")
+		}),
+	}
+}
+
+func TestPipeline_EmptyPassesThrough(t *testing.T) {
+	p := New(nil, testLogger())
+	entry := &models.Log{Message: "hello"}
+
+	out, err := p.Process(context.Background(), entry)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(out) != 1 || out[0] != entry {
+		t.Errorf("Expected entry to pass through unchanged, got %v", out)
+	}
+}
+
+func TestPipeline_OnErrorPolicies(t *testing.T) {
+	tests := []struct {
+		onError   string
+		wantCount int
+		wantErr   bool
+	}{
+		{OnErrorSkip, 1, false},
+		{OnErrorDrop, 0, false},
+		{OnErrorFail, 0, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.onError, func(t *testing.T) {
+			p := New([]*Stage{failingStage(test.onError)}, testLogger())
+
+			out, err := p.Process(context.Background(), &models.Log{Message: "hello"})
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Expected error=%v, got %v", test.wantErr, err)
+			}
+			if len(out) != test.wantCount {
+				t.Errorf("Expected %d entries, got %d", test.wantCount, len(out))
+			}
+
+			var stageErr *StageError
+			if test.wantErr && !errors.As(err, &stageErr) {
+				t.Errorf("Expected StageError, got %T", err)
+			}
+		})
+	}
+}
+
+func TestPipeline_SplitEntries(t *testing.T) {
+	split := &Stage{
+		Name: "split",
+		Processor: ProcessorFunc(func(ctx context.Context, entry *models.Log) ([]*models.Log, error) {
+			second := *entry
+			second.Message = entry.Message + " (copy)"
+			return []*models.Log{entry, &second}, nil
+		}),
+	}
+	count := 0
+	counter := &Stage{
+		Name: "count",
+		Processor: ProcessorFunc(func(ctx context.Context, entry *models.Log) ([]*models.Log, error) {
+			count++
+			return Keep(entry), nil
+		}),
+	}
+
+	out, err := New([]*Stage{split, counter}, testLogger()).Process(context.Background(), &models.Log{Message: "hello"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(out) != 2 || count != 2 {
+		t.Errorf("Expected both split entries to reach later stages, got %d entries and %d calls", len(out), count)
+	}
+}
+
+func TestBuild_Errors(t *testing.T) {
+	tests := map[string]string{
+		"missing type":   `{"stages":[{"name":"a"}]}`,
+		"unknown type":   `{"stages":[{"type":"does-not-exist"}]}`,
+		"bad on_error":   `{"stages":[{"type":"filter","on_error":"explode"}]}`,
+		"duplicate name": `{"stages":[{"name":"a","type":"filter"},{"name":"a","type":"filter"}]}`,
+		"bad config":     `{"stages":[{"type":"filter","config":{"message_regex":"("}}]}`,
+	}
+
+	for name, config := range tests {
+		t.Run(name, func(t *testing.T) {
+			var cfg Config
+			if err := json.Unmarshal([]byte(config), &cfg); err != nil {
+				t.Fatalf("Invalid test config: %v", err)
+			}
+			if _, err := Build(cfg, testLogger()); err == nil {
+				t.Errorf("Expected build error for %s", name)
+			}
+		})
+	}
+}
+
+func TestBuild_StageFeatureFlags(t *testing.T) {
+	features.Set(map[string]bool{features.StagePrefix + "add_fields": false, features.StagePrefix + "drop-debug": false})
+	defer features.Set(nil)
+
+	// The disabled stage's invalid config is never built
+	p := buildPipeline(t, `{"stages":[
+		{"name":"drop-debug","type":"filter","config":{"message_regex":"("}},
+		{"type":"add_fields","config":{"fields":{"team":"payments"}}}
+	]}`)
+
+	entry := &models.Log{Level: "debug", Message: "charged"}
+	if out, _ := p.Process(context.Background(), entry); len(out) != 1 || entry.Fields != nil {
+		t.Errorf("Expected the entry to pass through untouched, got %v", out)
+	}
+}
+
+func TestFilterStage(t *testing.T) {
+	p := buildPipeline(t, `{"stages":[
+		{"type":"filter","config":{"levels":["debug"],"message_contains":["heartbeat"]}}
+	]}`)
+
+	tests := []struct {
+		entry models.Log
+		kept  bool
+	}{
+		{models.Log{Level: "DEBUG", Message: "heartbeat ok"}, false},
+		{models.Log{Level: "info", Message: "heartbeat ok"}, true},
+		{models.Log{Level: "debug", Message: "cache miss"}, true},
+	}
+
+	for _, test := range tests {
+		entry := test.entry
+		out, _ := p.Process(context.Background(), &entry)
+		if (len(out) == 1) != test.kept {
+			t.Errorf("Entry %+v: expected kept=%v", test.entry, test.kept)
+		}
+	}
+}
+
+func TestStageSourceRestriction(t *testing.T) {
+	p := buildPipeline(t, `{"stages":[
+		{"type":"add_fields","sources":["billing"],"config":{"fields":{"team":"payments"}}}
+	]}`)
+
+	billing := &models.Log{Source: "billing", Message: "charged"}
+	auth := &models.Log{Source: "auth", Message: "login"}
+	p.Process(context.Background(), billing)
+	p.Process(context.Background(), auth)
+
+	if billing.Fields["team"] != "payments" {
+		t.Errorf("Expected billing entry to be enriched, got %v", billing.Fields)
+	}
+	if auth.Fields != nil {
+		t.Errorf("Expected auth entry to be untouched, got %v", auth.Fields)
+	}
+}
+
+func TestAddFieldsStage_Overwrite(t *testing.T) {
+	p := buildPipeline(t, `{"stages":[{"type":"add_fields","config":{"fields":{"env":"prod"}}}]}`)
+
+	entry := &models.Log{Message: "hello", Fields: models.Fields{"env": "staging"}}
+	p.Process(context.Background(), entry)
+
+	if entry.Fields["env"] != "staging" {
+		t.Errorf("Expected existing field to be preserved, got %v", entry.Fields["env"])
+	}
+}
@@ -0,0 +1,166 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func init() {
+	Register("regex_extract", newRegexExtractProcessor)
+}
+
+// regexRuleConfig extracts named capture groups from a field into structured fields
+type regexRuleConfig struct {
+	Sources   []string          `json:"sources"`
+	Field     string            `json:"field"`
+	Pattern   string            `json:"pattern"`
+	Types     map[string]string `json:"types"`
+	Overwrite bool              `json:"overwrite"`
+}
+
+type regexExtractConfig struct {
+	Rules []regexRuleConfig `json:"rules"`
+}
+
+type regexRule struct {
+	sources   map[string]bool
+	field     string
+	re        *regexp.Regexp
+	types     map[string]string
+	overwrite bool
+}
+
+type regexExtractProcessor struct {
+	rules []regexRule
+}
+
+func newRegexExtractProcessor(raw json.RawMessage) (Processor, error) {
+	var cfg regexExtractConfig
+	if err := decodeConfig(raw, &cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.Rules) == 0 {
+		return nil, errors.New("regex_extract requires at least one rule")
+	}
+
+	p := &regexExtractProcessor{}
+	for i, rc := range cfg.Rules {
+		re, err := regexp.Compile(rc.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+
+		named := 0
+		for _, name := range re.SubexpNames() {
+			if name != "" {
+				named++
+			}
+		}
+		if named == 0 {
+			return nil, fmt.Errorf("rule %d: pattern has no named capture groups", i)
+		}
+
+		for name, typ := range rc.Types {
+			switch typ {
+			case "string", "int", "float", "bool":
+			default:
+				return nil, fmt.Errorf("rule %d: unsupported type %q for %s", i, typ, name)
+			}
+		}
+
+		rule := regexRule{
+			field:     rc.Field,
+			re:        re,
+			types:     rc.Types,
+			overwrite: rc.Overwrite,
+		}
+		if rule.field == "" {
+			rule.field = "message"
+		}
+		if len(rc.Sources) > 0 {
+			rule.sources = make(map[string]bool, len(rc.Sources))
+			for _, source := range rc.Sources {
+				rule.sources[source] = true
+			}
+		}
+		p.rules = append(p.rules, rule)
+	}
+
+	return p, nil
+}
+
+func (p *regexExtractProcessor) Process(ctx context.Context, entry *models.Log) ([]*models.Log, error) {
+	for _, rule := range p.rules {
+		if rule.sources != nil && !rule.sources[entry.Source] {
+			continue
+		}
+
+		input, ok := fieldString(entry, rule.field)
+		if !ok {
+			continue
+		}
+
+		match := rule.re.FindStringSubmatch(input)
+		if match == nil {
+			continue
+		}
+
+		for i, name := range rule.re.SubexpNames() {
+			if name == "" || i >= len(match) || match[i] == "" {
+				continue
+			}
+			if entry.Fields == nil {
+				entry.Fields = make(models.Fields)
+			}
+			if _, exists := entry.Fields[name]; exists && !rule.overwrite {
+				continue
+			}
+
+			value, err := convertValue(match[i], rule.types[name])
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", name, err)
+			}
+			entry.Fields[name] = value
+		}
+	}
+
+	return Keep(entry), nil
+}
+
+// fieldString reads a top-level entry attribute or a structured field as a string
+func fieldString(entry *models.Log, field string) (string, bool) {
+	switch field {
+	case "message":
+		return entry.Message, true
+	case "level":
+		return entry.Level, true
+	case "source":
+		return entry.Source, true
+	}
+
+	value, ok := entry.Fields[field]
+	if !ok {
+		return "", false
+	}
+	s, ok := value.(string)
+	return s, ok
+}
+
+// convertValue converts a captured string to the configured type
+func convertValue(s, typ string) (interface{}, error) {
+	switch typ {
+	case "int":
+		return strconv.ParseInt(s, 10, 64)
+	case "float":
+		return strconv.ParseFloat(s, 64)
+	case "bool":
+		return strconv.ParseBool(s)
+	default:
+		return s, nil
+	}
+}
@@ -0,0 +1,48 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func TestRegexExtractStage(t *testing.T) {
+	p := buildPipeline(t, `{"stages":[{"type":"regex_extract","config":{"rules":[
+		{"sources":["orders"],"pattern":"order (?P<order_id>[A-Z]-\\d+) took (?P<duration_ms>\\d+)ms","types":{"duration_ms":"int"}},
+		{"pattern":"code=(?P<error_code>E\\d+)"}
+	]}}]}`)
+
+	entry := &models.Log{Source: "orders", Message: "order A-1042 took 87ms code=E500"}
+	if _, err := p.Process(context.Background(), entry); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if entry.Fields["order_id"] != "A-1042" {
+		t.Errorf("Expected order_id A-1042, got %v", entry.Fields["order_id"])
+	}
+	if entry.Fields["duration_ms"] != int64(87) {
+		t.Errorf("Expected duration_ms 87 as int, got %v (%T)", entry.Fields["duration_ms"], entry.Fields["duration_ms"])
+	}
+	if entry.Fields["error_code"] != "E500" {
+		t.Errorf("Expected error_code E500, got %v", entry.Fields["error_code"])
+	}
+
+	other := &models.Log{Source: "auth", Message: "order B-1 took 5ms"}
+	p.Process(context.Background(), other)
+	if _, ok := other.Fields["order_id"]; ok {
+		t.Errorf("Expected source-restricted rule not to apply to auth entries")
+	}
+}
+
+func TestRegexExtractStage_InvalidConfig(t *testing.T) {
+	configs := []string{
+		`{"rules":[]}`,
+		`{"rules":[{"pattern":"no groups here"}]}`,
+		`{"rules":[{"pattern":"(?P<n>\\d+)","types":{"n":"decimal"}}]}`,
+	}
+	for _, config := range configs {
+		if _, err := newRegexExtractProcessor([]byte(config)); err == nil {
+			t.Errorf("Expected error for config %s", config)
+		}
+	}
+}
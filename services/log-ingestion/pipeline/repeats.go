@@ -0,0 +1,151 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func init() {
+	Register("group_repeats", newGroupRepeatsProcessor)
+}
+
+// groupRepeatsConfig collapses bursts of identical messages. The first
+// entry of a burst is passed on immediately; identical entries arriving
+// within window of it are held back and released as one summary entry,
+// like syslog's "last message repeated N times".
+type groupRepeatsConfig struct {
+	Window    string   `json:"window"`
+	KeyFields []string `json:"key_fields"`
+	MaxKeys   int      `json:"max_keys"`
+}
+
+type repeatGroup struct {
+	first    *models.Log
+	started  time.Time
+	repeats  int
+	firstRep time.Time
+	lastRep  time.Time
+}
+
+type groupRepeatsProcessor struct {
+	window    time.Duration
+	keyFields []string
+	maxKeys   int
+	now       func() time.Time
+
+	mu     sync.Mutex
+	groups map[string]*repeatGroup
+}
+
+func newGroupRepeatsProcessor(raw json.RawMessage) (Processor, error) {
+	cfg := groupRepeatsConfig{Window: "30s", MaxKeys: 10000}
+	if err := decodeConfig(raw, &cfg); err != nil {
+		return nil, err
+	}
+
+	window, err := time.ParseDuration(cfg.Window)
+	if err != nil || window <= 0 {
+		return nil, fmt.Errorf("invalid window %q", cfg.Window)
+	}
+
+	return &groupRepeatsProcessor{
+		window:    window,
+		keyFields: cfg.KeyFields,
+		maxKeys:   cfg.MaxKeys,
+		now:       time.Now,
+		groups:    make(map[string]*repeatGroup),
+	}, nil
+}
+
+func (p *groupRepeatsProcessor) Process(ctx context.Context, entry *models.Log) ([]*models.Log, error) {
+	now := p.now()
+	ts := entry.Timestamp
+	if ts.IsZero() {
+		ts = now
+	}
+	key := p.key(entry)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	out := Keep(entry)
+	if group, ok := p.groups[key]; ok {
+		if now.Sub(group.started) < p.window {
+			if group.repeats == 0 {
+				group.firstRep = ts
+			}
+			group.repeats++
+			group.lastRep = ts
+			return nil, nil
+		}
+
+		// The previous burst is over; release its summary ahead of the new entry
+		delete(p.groups, key)
+		if summary := group.summary(); summary != nil {
+			out = []*models.Log{summary, entry}
+		}
+	}
+
+	if len(p.groups) < p.maxKeys {
+		// Later stages may modify entry, so the summary starts from a copy
+		first := *entry
+		first.Fields = make(models.Fields, len(entry.Fields))
+		for k, v := range entry.Fields {
+			first.Fields[k] = v
+		}
+		p.groups[key] = &repeatGroup{first: &first, started: now}
+	}
+	return out, nil
+}
+
+// Flush releases the summaries of bursts whose window has passed
+func (p *groupRepeatsProcessor) Flush(now time.Time, force bool) []*models.Log {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var released []*models.Log
+	for key, group := range p.groups {
+		if !force && now.Sub(group.started) < p.window {
+			continue
+		}
+		delete(p.groups, key)
+		if summary := group.summary(); summary != nil {
+			released = append(released, summary)
+		}
+	}
+	return released
+}
+
+func (p *groupRepeatsProcessor) key(entry *models.Log) string {
+	var b strings.Builder
+	b.WriteString(entry.Source)
+	b.WriteByte(0)
+	b.WriteString(strings.ToLower(entry.Level))
+	b.WriteByte(0)
+	b.WriteString(entry.Message)
+	for _, field := range p.keyFields {
+		b.WriteByte(0)
+		fmt.Fprint(&b, entry.Fields[field])
+	}
+	return b.String()
+}
+
+// summary builds the entry standing in for the held-back repeats, or nil if
+// the burst had none
+func (g *repeatGroup) summary() *models.Log {
+	if g.repeats == 0 {
+		return nil
+	}
+
+	summary := g.first
+	summary.Timestamp = g.lastRep
+	summary.Fields["repeat_count"] = g.repeats
+	summary.Fields["repeat_first_timestamp"] = g.firstRep.UTC().Format(time.RFC3339Nano)
+	summary.Fields["repeat_last_timestamp"] = g.lastRep.UTC().Format(time.RFC3339Nano)
+	return summary
+}
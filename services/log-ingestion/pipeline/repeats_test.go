@@ -0,0 +1,100 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func TestGroupRepeatsStage(t *testing.T) {
+	p := buildPipeline(t, `{"stages":[
+		{"name":"repeats","type":"group_repeats","config":{"window":"10s"}},
+		{"type":"add_fields","config":{"fields":{"env":"prod"}}}
+	]}`)
+	grouper := p.stages[0].Processor.(*groupRepeatsProcessor)
+	clock := time.Date(2025, 8, 29, 12, 0, 0, 0, time.UTC)
+	grouper.now = func() time.Time { return clock }
+
+	entry := func(message string, offset time.Duration) *models.Log {
+		return &models.Log{Source: "db", Level: "error", Message: message, Timestamp: clock.Add(offset)}
+	}
+
+	if out, _ := p.Process(context.Background(), entry("connection refused", 0)); len(out) != 1 {
+		t.Fatalf("Expected first entry to pass through, got %d entries", len(out))
+	}
+	for i := 1; i <= 4; i++ {
+		if out, _ := p.Process(context.Background(), entry("connection refused", time.Duration(i)*time.Second)); len(out) != 0 {
+			t.Fatalf("Expected repeat %d to be held back, got %d entries", i, len(out))
+		}
+	}
+	if out, _ := p.Process(context.Background(), entry("disk full", 0)); len(out) != 1 {
+		t.Errorf("Expected a different message to pass through")
+	}
+
+	if released := p.Flush(context.Background(), clock.Add(5*time.Second), false); len(released) != 0 {
+		t.Errorf("Expected nothing released before the window passes, got %d", len(released))
+	}
+
+	released := p.Flush(context.Background(), clock.Add(10*time.Second), false)
+	if len(released) != 1 {
+		t.Fatalf("Expected one summary entry, got %d", len(released))
+	}
+	summary := released[0]
+	if summary.Message != "connection refused" || summary.Fields["repeat_count"] != 4 {
+		t.Errorf("Expected summary of 4 repeats, got %q with %v", summary.Message, summary.Fields["repeat_count"])
+	}
+	if summary.Fields["repeat_first_timestamp"] != "2025-08-29T12:00:01Z" || summary.Fields["repeat_last_timestamp"] != "2025-08-29T12:00:04Z" {
+		t.Errorf("Unexpected repeat timestamps: %v", summary.Fields)
+	}
+	if summary.Fields["env"] != "prod" {
+		t.Errorf("Expected flushed summary to pass through later stages")
+	}
+	if len(grouper.groups) != 0 {
+		t.Errorf("Expected expired groups to be removed, got %d", len(grouper.groups))
+	}
+}
+
+func TestGroupRepeatsStage_BurstEndsOnNextArrival(t *testing.T) {
+	processor, err := newGroupRepeatsProcessor([]byte(`{"window":"1s"}`))
+	if err != nil {
+		t.Fatalf("Failed to create stage: %v", err)
+	}
+	grouper := processor.(*groupRepeatsProcessor)
+	clock := time.Now()
+	grouper.now = func() time.Time { return clock }
+
+	processor.Process(context.Background(), &models.Log{Message: "tick"})
+	processor.Process(context.Background(), &models.Log{Message: "tick"})
+
+	clock = clock.Add(2 * time.Second)
+	out, _ := processor.Process(context.Background(), &models.Log{Message: "tick"})
+	if len(out) != 2 || out[0].Fields["repeat_count"] != 1 || out[1].Fields != nil {
+		t.Errorf("Expected the previous burst's summary followed by the new entry, got %+v", out)
+	}
+}
+
+func TestGroupRepeatsStage_KeyFieldsAndForceFlush(t *testing.T) {
+	processor, _ := newGroupRepeatsProcessor([]byte(`{"key_fields":["host"]}`))
+
+	for _, host := range []string{"a", "b", "a", "b", "b"} {
+		processor.Process(context.Background(), &models.Log{Message: "retrying", Fields: models.Fields{"host": host}})
+	}
+
+	released := processor.(Flusher).Flush(time.Now(), true)
+	counts := map[interface{}]interface{}{}
+	for _, entry := range released {
+		counts[entry.Fields["host"]] = entry.Fields["repeat_count"]
+	}
+	if counts["a"] != 1 || counts["b"] != 2 {
+		t.Errorf("Expected repeats grouped per host, got %v", counts)
+	}
+}
+
+func TestGroupRepeatsStage_InvalidConfig(t *testing.T) {
+	for _, config := range []string{`{"window":"soon"}`, `{"window":"-1s"}`} {
+		if _, err := newGroupRepeatsProcessor([]byte(config)); err == nil {
+			t.Errorf("Expected error for config %s", config)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log-processing-system/services/log-ingestion/models"
+	"log-processing-system/services/log-ingestion/sink"
+)
+
+func init() {
+	Register("route", newRouteProcessor)
+}
+
+// routeConfig sends entries matching the conditions of filter to every one
+// of sinks. They are passed on to default storage as well, unless exclusive
+// is set.
+type routeConfig struct {
+	Levels          []string      `json:"levels"`
+	Sources         []string      `json:"sources"`
+	MessageContains []string      `json:"message_contains"`
+	MessageRegex    string        `json:"message_regex"`
+	Sinks           []sink.Config `json:"sinks"`
+	Exclusive       bool          `json:"exclusive"`
+}
+
+type routeProcessor struct {
+	filter    *filterProcessor
+	sinks     []sink.Sink
+	types     []string
+	exclusive bool
+}
+
+func newRouteProcessor(raw json.RawMessage) (Processor, error) {
+	var cfg routeConfig
+	if err := decodeConfig(raw, &cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.Sinks) == 0 {
+		return nil, errors.New("route requires at least one sink")
+	}
+
+	filter, err := buildFilter(filterConfig{
+		Levels:          cfg.Levels,
+		Sources:         cfg.Sources,
+		MessageContains: cfg.MessageContains,
+		MessageRegex:    cfg.MessageRegex,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	p := &routeProcessor{filter: filter, exclusive: cfg.Exclusive}
+	for _, sc := range cfg.Sinks {
+		s, err := sink.Build(sc)
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.sinks = append(p.sinks, s)
+		p.types = append(p.types, sc.Type)
+	}
+	return p, nil
+}
+
+// Process writes a matching entry to every sink, even when one of them
+// fails, so one unreachable output does not starve the others
+func (p *routeProcessor) Process(ctx context.Context, entry *models.Log) ([]*models.Log, error) {
+	if !p.filter.matches(entry) {
+		return Keep(entry), nil
+	}
+
+	var failed error
+	for i, s := range p.sinks {
+		if err := s.Write(ctx, []*models.Log{entry}); err != nil && failed == nil {
+			failed = fmt.Errorf("sink %q: %w", p.types[i], err)
+		}
+	}
+	if failed != nil {
+		return nil, failed
+	}
+
+	if p.exclusive {
+		return nil, nil
+	}
+	return Keep(entry), nil
+}
+
+// FlushOutputs flushes every sink that buffers entries, returning the first
+// error
+func (p *routeProcessor) FlushOutputs() error {
+	var failed error
+	for i, s := range p.sinks {
+		flusher, ok := s.(sink.Flusher)
+		if !ok {
+			continue
+		}
+		if err := flusher.Flush(); err != nil && failed == nil {
+			failed = fmt.Errorf("sink %q: %w", p.types[i], err)
+		}
+	}
+	return failed
+}
+
+// Buffered reports how many entries the sinks are waiting to send
+func (p *routeProcessor) Buffered() int {
+	total := 0
+	for _, s := range p.sinks {
+		if b, ok := s.(sink.Buffering); ok {
+			total += b.Buffered()
+		}
+	}
+	return total
+}
+
+// Close closes every sink, returning the first error
+func (p *routeProcessor) Close() error {
+	var failed error
+	for _, s := range p.sinks {
+		if err := s.Close(); err != nil && failed == nil {
+			failed = err
+		}
+	}
+	return failed
+}
@@ -0,0 +1,146 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"log-processing-system/services/log-ingestion/models"
+	"log-processing-system/services/log-ingestion/sink"
+)
+
+// memorySink records written entries; a config of {"fail":true} makes every
+// write fail
+type memorySink struct {
+	entries []*models.Log
+	fail    bool
+	closed  bool
+	flushes int
+}
+
+var memorySinks []*memorySink
+
+func init() {
+	sink.Register("test_memory", func(raw json.RawMessage) (sink.Sink, error) {
+		var cfg struct {
+			Fail bool `json:"fail"`
+		}
+		json.Unmarshal(raw, &cfg)
+		s := &memorySink{fail: cfg.Fail}
+		memorySinks = append(memorySinks, s)
+		return s, nil
+	})
+}
+
+func (s *memorySink) Write(ctx context.Context, entries []*models.Log) error {
+	if s.fail {
+		return errors.New("sink unavailable")
+	}
+	s.entries = append(s.entries, entries...)
+	return nil
+}
+
+func (s *memorySink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func (s *memorySink) Flush() error {
+	s.flushes++
+	return nil
+}
+
+// Buffered treats every written entry as waiting to be sent
+func (s *memorySink) Buffered() int {
+	return len(s.entries)
+}
+
+func TestRouteStage_FanOut(t *testing.T) {
+	memorySinks = nil
+	p := buildPipeline(t, `{"stages":[{"type":"route","config":{"sources":["auth"],
+		"sinks":[{"type":"test_memory"},{"type":"test_memory"}]}}]}`)
+
+	entries, err := p.Process(context.Background(), &models.Log{Message: "Login failed", Level: "warn", Source: "auth"})
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("Expected entry to be kept for storage, got %v, %v", entries, err)
+	}
+	p.Process(context.Background(), &models.Log{Message: "GET /", Level: "info", Source: "web"})
+
+	for i, s := range memorySinks {
+		if len(s.entries) != 1 || s.entries[0].Source != "auth" {
+			t.Errorf("Expected sink %d to receive the auth entry only, got %+v", i, s.entries)
+		}
+	}
+
+	p.Close()
+	for i, s := range memorySinks {
+		if !s.closed {
+			t.Errorf("Expected sink %d to be closed", i)
+		}
+	}
+}
+
+func TestRouteStage_Exclusive(t *testing.T) {
+	memorySinks = nil
+	p := buildPipeline(t, `{"stages":[{"type":"route","config":{"levels":["debug"],"exclusive":true,
+		"sinks":[{"type":"test_memory"}]}}]}`)
+
+	entries, _ := p.Process(context.Background(), &models.Log{Message: "cache miss", Level: "debug"})
+	if len(entries) != 0 {
+		t.Errorf("Expected exclusive route to take the entry from storage, got %d entries", len(entries))
+	}
+	if len(memorySinks[0].entries) != 1 {
+		t.Errorf("Expected sink to receive the entry, got %d", len(memorySinks[0].entries))
+	}
+
+	entries, _ = p.Process(context.Background(), &models.Log{Message: "started", Level: "info"})
+	if len(entries) != 1 {
+		t.Errorf("Expected unmatched entry to be kept, got %d entries", len(entries))
+	}
+}
+
+func TestRouteStage_SinkFailure(t *testing.T) {
+	memorySinks = nil
+	p := buildPipeline(t, `{"stages":[{"type":"route","on_error":"fail","config":{
+		"sinks":[{"type":"test_memory","config":{"fail":true}},{"type":"test_memory"}]}}]}`)
+
+	if _, err := p.Process(context.Background(), &models.Log{Message: "m", Level: "info"}); err == nil {
+		t.Errorf("Expected sink failure to be reported")
+	}
+	if len(memorySinks[1].entries) != 1 {
+		t.Errorf("Expected the healthy sink to still receive the entry")
+	}
+}
+
+func TestRouteStage_FlushOutputs(t *testing.T) {
+	memorySinks = nil
+	p := buildPipeline(t, `{"stages":[{"type":"route","config":{
+		"sinks":[{"type":"test_memory"},{"type":"test_memory"}]}}]}`)
+
+	p.Process(context.Background(), &models.Log{Message: "m", Level: "info"})
+	if buffered := p.Buffered(); buffered["route-0"] != 2 {
+		t.Errorf("Expected 2 entries buffered by the route stage, got %v", buffered)
+	}
+
+	if err := p.FlushOutputs(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for i, s := range memorySinks {
+		if s.flushes != 1 {
+			t.Errorf("Expected sink %d to be flushed once, got %d", i, s.flushes)
+		}
+	}
+}
+
+func TestRouteStage_InvalidConfig(t *testing.T) {
+	configs := []string{
+		`{}`,
+		`{"sinks":[{"type":"unknown"}]}`,
+		`{"sinks":[{"type":"test_memory"}],"message_regex":"("}`,
+	}
+	for _, cfg := range configs {
+		if _, err := newRouteProcessor([]byte(cfg)); err == nil {
+			t.Errorf("Expected error for config %s", cfg)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+	"log-processing-system/services/log-ingestion/script"
+)
+
+func init() {
+	Register("script", newScriptProcessor)
+}
+
+// scriptConfig holds a script, either inline or in a file reloaded like a
+// transform file. Each run may take at most max_steps steps, build at most
+// max_bytes of strings, lists and maps and take timeout of wall time; a run
+// that exceeds any of them fails the stage.
+type scriptConfig struct {
+	Source         string `json:"source"`
+	File           string `json:"file"`
+	ReloadInterval string `json:"reload_interval"`
+	MaxSteps       int    `json:"max_steps"`
+	MaxBytes       int    `json:"max_bytes"`
+	Timeout        string `json:"timeout"`
+}
+
+type scriptProcessor struct {
+	script  atomic.Value // *script.Script
+	limits  script.Limits
+	timeout time.Duration
+
+	file    string
+	watcher *fileWatcher
+}
+
+func newScriptProcessor(raw json.RawMessage) (Processor, error) {
+	cfg := scriptConfig{ReloadInterval: "10s", MaxSteps: 10000, MaxBytes: 8 << 20, Timeout: "10ms"}
+	if err := decodeConfig(raw, &cfg); err != nil {
+		return nil, err
+	}
+	if (cfg.Source == "") == (cfg.File == "") {
+		return nil, errors.New("script requires exactly one of source or file")
+	}
+	if cfg.MaxSteps <= 0 {
+		return nil, fmt.Errorf("invalid max_steps %d", cfg.MaxSteps)
+	}
+	if cfg.MaxBytes <= 0 {
+		return nil, fmt.Errorf("invalid max_bytes %d", cfg.MaxBytes)
+	}
+	timeout, err := time.ParseDuration(cfg.Timeout)
+	if err != nil || timeout <= 0 {
+		return nil, fmt.Errorf("invalid timeout %q", cfg.Timeout)
+	}
+
+	p := &scriptProcessor{
+		limits:  script.Limits{MaxSteps: cfg.MaxSteps, MaxBytes: cfg.MaxBytes},
+		timeout: timeout,
+		file:    cfg.File,
+	}
+
+	if cfg.Source != "" {
+		s, err := script.Compile(cfg.Source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid script: %w", err)
+		}
+		p.script.Store(s)
+		return p, nil
+	}
+
+	interval, err := time.ParseDuration(cfg.ReloadInterval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reload_interval %q: %w", cfg.ReloadInterval, err)
+	}
+	if p.watcher, err = newFileWatcher(p.file, interval); err != nil {
+		return nil, fmt.Errorf("failed to read script file: %w", err)
+	}
+	s, err := p.load()
+	if err != nil {
+		return nil, err
+	}
+	p.script.Store(s)
+
+	return p, nil
+}
+
+func (p *scriptProcessor) Process(ctx context.Context, entry *models.Log) ([]*models.Log, error) {
+	if p.watcher != nil {
+		p.reloadIfChanged()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	s := p.script.Load().(*script.Script)
+	keep, err := s.Run(ctx, entry, p.limits)
+	if err != nil {
+		return nil, err
+	}
+	if !keep {
+		return nil, nil
+	}
+	return Keep(entry), nil
+}
+
+func (p *scriptProcessor) load() (*script.Script, error) {
+	data, err := os.ReadFile(p.file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script file: %w", err)
+	}
+	s, err := script.Compile(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid script file %s: %w", p.file, err)
+	}
+	return s, nil
+}
+
+// reloadIfChanged recompiles the script when the file has been modified
+func (p *scriptProcessor) reloadIfChanged() {
+	changed, err := p.watcher.changed()
+	if err != nil {
+		pipelineLogger.WithError(err).WithField("file", p.file).Warn("Failed to check script file for changes")
+		return
+	}
+	if !changed {
+		return
+	}
+
+	s, err := p.load()
+	if err != nil {
+		pipelineLogger.WithError(err).WithField("file", p.file).Error("Script reload failed, keeping previous script")
+		return
+	}
+	p.script.Store(s)
+
+	pipelineLogger.WithField("file", p.file).Info("Script reloaded")
+}
@@ -0,0 +1,108 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+	"log-processing-system/services/log-ingestion/script"
+)
+
+func TestScriptStage_Inline(t *testing.T) {
+	p := buildPipeline(t, `{"stages":[{"type":"script","config":{"source":
+		"let parts = split(.message, \" \")\n.verb = parts[0]\ndrop if .verb == \"PING\""}}]}`)
+
+	entries, err := p.Process(context.Background(), &models.Log{Message: "GET /api", Level: "info"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(entries) != 1 || entries[0].Fields["verb"] != "GET" {
+		t.Errorf("Expected one entry with verb GET, got %+v", entries)
+	}
+
+	entries, _ = p.Process(context.Background(), &models.Log{Message: "PING", Level: "info"})
+	if len(entries) != 0 {
+		t.Errorf("Expected PING entry to be dropped, got %d entries", len(entries))
+	}
+}
+
+func TestScriptStage_Limits(t *testing.T) {
+	processor, err := newScriptProcessor([]byte(`{"source":"while true { .n = 1 }","max_steps":500,"timeout":"1s"}`))
+	if err != nil {
+		t.Fatalf("Failed to create stage: %v", err)
+	}
+	if _, err := processor.Process(context.Background(), &models.Log{}); !errors.Is(err, script.ErrStepLimit) {
+		t.Errorf("Expected step limit error, got %v", err)
+	}
+
+	processor, _ = newScriptProcessor([]byte(`{"source":"while true { .n = 1 }","max_steps":1000000000,"timeout":"5ms"}`))
+	start := time.Now()
+	_, err = processor.Process(context.Background(), &models.Log{})
+	if err == nil || !strings.Contains(err.Error(), "time limit") {
+		t.Errorf("Expected time limit error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the run to stop near its timeout, took %v", elapsed)
+	}
+
+	// Doubling a string fails on the default memory budget long before it
+	// runs out of steps
+	processor, _ = newScriptProcessor([]byte(`{"source":"let s = \"x\"\nwhile true { s = s + s }","timeout":"1s"}`))
+	if _, err := processor.Process(context.Background(), &models.Log{}); !errors.Is(err, script.ErrMemoryLimit) {
+		t.Errorf("Expected memory limit error, got %v", err)
+	}
+}
+
+func TestScriptStage_Reload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "enrich.script")
+	if err := os.WriteFile(file, []byte(`.version = 1`), 0644); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+
+	processor, err := newScriptProcessor([]byte(`{"file":"` + filepath.ToSlash(file) + `","reload_interval":"1ns"}`))
+	if err != nil {
+		t.Fatalf("Failed to create stage: %v", err)
+	}
+
+	version := func() interface{} {
+		entry := &models.Log{Message: "m"}
+		if _, err := processor.Process(context.Background(), entry); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return entry.Fields["version"]
+	}
+
+	later := time.Now().Add(time.Minute)
+	os.WriteFile(file, []byte(`.version = 2`), 0644)
+	os.Chtimes(file, later, later)
+	if v := version(); v != int64(2) {
+		t.Errorf("Expected reloaded version 2, got %v", v)
+	}
+
+	later = later.Add(time.Minute)
+	os.WriteFile(file, []byte(`.version = `), 0644)
+	os.Chtimes(file, later, later)
+	if v := version(); v != int64(2) {
+		t.Errorf("Expected broken script to keep version 2, got %v", v)
+	}
+}
+
+func TestScriptStage_InvalidConfig(t *testing.T) {
+	configs := []string{
+		`{}`,
+		`{"source":".a = 1","file":"x.script"}`,
+		`{"source":".a = "}`,
+		`{"source":".a = 1","timeout":"soon"}`,
+		`{"source":".a = 1","max_steps":-1}`,
+		`{"source":".a = 1","max_bytes":0}`,
+	}
+	for _, cfg := range configs {
+		if _, err := newScriptProcessor([]byte(cfg)); err == nil {
+			t.Errorf("Expected error for config %s", cfg)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func init() {
+	Register("severity", newSeverityProcessor)
+}
+
+// severityAliases maps common severity names onto the canonical levels
+var severityAliases = map[string]string{
+	"trace":         "debug",
+	"verbose":       "debug",
+	"debug":         "debug",
+	"dbg":           "debug",
+	"info":          "info",
+	"information":   "info",
+	"informational": "info",
+	"notice":        "info",
+	"inf":           "info",
+	"warn":          "warn",
+	"warning":       "warn",
+	"wrn":           "warn",
+	"error":         "error",
+	"err":           "error",
+	"fail":          "error",
+	"failure":       "error",
+	"fatal":         "fatal",
+	"critical":      "fatal",
+	"crit":          "fatal",
+	"alert":         "fatal",
+	"emerg":         "fatal",
+	"emergency":     "fatal",
+	"panic":         "fatal",
+	"severe":        "fatal",
+}
+
+// numericSeverities are the supported numeric level schemes, each a list of
+// thresholds checked in order: a value maps to the first level whose
+// threshold it does not exceed
+var numericSeverities = map[string][]struct {
+	max   int
+	level string
+}{
+	// RFC 5424: 0 emergency .. 7 debug
+	"syslog": {{2, "fatal"}, {3, "error"}, {4, "warn"}, {6, "info"}, {7, "debug"}},
+	// bunyan/pino: 10 trace, 20 debug, 30 info, 40 warn, 50 error, 60 fatal
+	"bunyan": {{20, "debug"}, {30, "info"}, {40, "warn"}, {50, "error"}, {60, "fatal"}},
+}
+
+// severityConfig normalizes entry levels. Mapping entries take precedence
+// over the built-in aliases; unknown levels become default when it is set
+// and are otherwise left for validation to reject.
+type severityConfig struct {
+	Numeric      string            `json:"numeric"`
+	Mapping      map[string]string `json:"mapping"`
+	Default      string            `json:"default"`
+	KeepOriginal string            `json:"keep_original"`
+}
+
+type severityProcessor struct {
+	numeric      string
+	mapping      map[string]string
+	defaultLevel string
+	keepOriginal string
+}
+
+func newSeverityProcessor(raw json.RawMessage) (Processor, error) {
+	cfg := severityConfig{Numeric: "syslog"}
+	if err := decodeConfig(raw, &cfg); err != nil {
+		return nil, err
+	}
+	if _, ok := numericSeverities[cfg.Numeric]; !ok {
+		return nil, fmt.Errorf("unknown numeric scheme %q (expected syslog or bunyan)", cfg.Numeric)
+	}
+
+	p := &severityProcessor{
+		numeric:      cfg.Numeric,
+		mapping:      make(map[string]string, len(cfg.Mapping)),
+		defaultLevel: strings.ToLower(cfg.Default),
+		keepOriginal: cfg.KeepOriginal,
+	}
+	if p.defaultLevel != "" && !isCanonicalLevel(p.defaultLevel) {
+		return nil, fmt.Errorf("default %q is not a valid level", cfg.Default)
+	}
+	for from, to := range cfg.Mapping {
+		to = strings.ToLower(to)
+		if !isCanonicalLevel(to) {
+			return nil, fmt.Errorf("mapping %q -> %q: not a valid level", from, to)
+		}
+		p.mapping[strings.ToLower(from)] = to
+	}
+
+	return p, nil
+}
+
+func (p *severityProcessor) Process(ctx context.Context, entry *models.Log) ([]*models.Log, error) {
+	original := entry.Level
+	level, ok := p.normalize(original)
+	if !ok {
+		if p.defaultLevel == "" {
+			return Keep(entry), nil
+		}
+		level = p.defaultLevel
+	}
+
+	if level != original {
+		entry.Level = level
+		if p.keepOriginal != "" && original != "" {
+			if entry.Fields == nil {
+				entry.Fields = models.Fields{}
+			}
+			entry.Fields[p.keepOriginal] = original
+		}
+	}
+	return Keep(entry), nil
+}
+
+// normalize maps a raw severity onto a canonical level
+func (p *severityProcessor) normalize(raw string) (string, bool) {
+	key := strings.ToLower(strings.TrimSpace(raw))
+
+	if level, ok := p.mapping[key]; ok {
+		return level, true
+	}
+	if level, ok := severityAliases[key]; ok {
+		return level, true
+	}
+	if n, err := strconv.Atoi(key); err == nil && n >= 0 {
+		for _, threshold := range numericSeverities[p.numeric] {
+			if n <= threshold.max {
+				return threshold.level, true
+			}
+		}
+	}
+	return "", false
+}
+
+func isCanonicalLevel(level string) bool {
+	switch level {
+	case "debug", "info", "warn", "error", "fatal":
+		return true
+	}
+	return false
+}
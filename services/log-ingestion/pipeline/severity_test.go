@@ -0,0 +1,76 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func TestSeverityStage(t *testing.T) {
+	processor, err := newSeverityProcessor([]byte(`{"mapping":{"sev2":"error"},"keep_original":"original_level"}`))
+	if err != nil {
+		t.Fatalf("Failed to create stage: %v", err)
+	}
+
+	tests := []struct {
+		level    string
+		expected string
+	}{
+		{"WARNING", "warn"},
+		{"critical", "fatal"},
+		{"Trace", "debug"},
+		{"notice", "info"},
+		{"err", "error"},
+		{"0", "fatal"},
+		{"3", "error"},
+		{"4", "warn"},
+		{"6", "info"},
+		{"7", "debug"},
+		{"SEV2", "error"},
+		{"info", "info"},
+		{"bogus", "bogus"},
+		{"99", "99"},
+	}
+
+	for _, tt := range tests {
+		entry := &models.Log{Message: "m", Level: tt.level}
+		processor.Process(context.Background(), entry)
+		if entry.Level != tt.expected {
+			t.Errorf("Level %q: expected %q, got %q", tt.level, tt.expected, entry.Level)
+		}
+
+		changed := tt.level != tt.expected
+		if original, ok := entry.Fields["original_level"]; ok != changed || (changed && original != tt.level) {
+			t.Errorf("Level %q: expected original_level recorded only when changed, got %v", tt.level, entry.Fields)
+		}
+	}
+}
+
+func TestSeverityStage_BunyanAndDefault(t *testing.T) {
+	processor, err := newSeverityProcessor([]byte(`{"numeric":"bunyan","default":"INFO"}`))
+	if err != nil {
+		t.Fatalf("Failed to create stage: %v", err)
+	}
+
+	tests := map[string]string{"10": "debug", "30": "info", "40": "warn", "50": "error", "60": "fatal", "99": "info", "": "info", "loud": "info"}
+	for level, expected := range tests {
+		entry := &models.Log{Message: "m", Level: level}
+		processor.Process(context.Background(), entry)
+		if entry.Level != expected {
+			t.Errorf("Level %q: expected %q, got %q", level, expected, entry.Level)
+		}
+	}
+}
+
+func TestSeverityStage_InvalidConfig(t *testing.T) {
+	configs := []string{
+		`{"numeric":"windows"}`,
+		`{"default":"verbose"}`,
+		`{"mapping":{"x":"loud"}}`,
+	}
+	for _, config := range configs {
+		if _, err := newSeverityProcessor([]byte(config)); err == nil {
+			t.Errorf("Expected error for config %s", config)
+		}
+	}
+}
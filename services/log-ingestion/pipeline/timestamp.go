@@ -0,0 +1,253 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func init() {
+	Register("timestamp", newTimestampProcessor)
+}
+
+// namedLayouts are shorthands accepted in layouts besides Go reference layouts
+var namedLayouts = map[string]string{
+	"rfc3339":  time.RFC3339Nano,
+	"rfc1123":  time.RFC1123Z,
+	"iso8601":  "2006-01-02T15:04:05.999999999",
+	"datetime": "2006-01-02 15:04:05.999999999",
+	"apache":   "02/Jan/2006:15:04:05 -0700",
+	"syslog":   time.Stamp,
+}
+
+// Timestamp stage modes
+const (
+	timestampModeMissing = "missing"
+	timestampModeAlways  = "always"
+	timestampModeSkew    = "skew"
+)
+
+// timestampRuleConfig extracts the event time from a field. The pattern's
+// "timestamp" capture group (or the whole match) is parsed with each layout
+// in turn; layouts without a zone are read in timezone. strip removes the
+// whole match from the message.
+type timestampRuleConfig struct {
+	Sources  []string `json:"sources"`
+	Field    string   `json:"field"`
+	Pattern  string   `json:"pattern"`
+	Layouts  []string `json:"layouts"`
+	Timezone string   `json:"timezone"`
+	Strip    bool     `json:"strip"`
+}
+
+// timestampConfig chooses when the envelope timestamp is replaced: when it
+// is missing, always, or when it is more than max_skew away from now
+type timestampConfig struct {
+	Mode         string                `json:"mode"`
+	MaxSkew      string                `json:"max_skew"`
+	KeepOriginal string                `json:"keep_original"`
+	Rules        []timestampRuleConfig `json:"rules"`
+}
+
+type timestampRule struct {
+	sources map[string]bool
+	field   string
+	re      *regexp.Regexp
+	group   int
+	layouts []string
+	loc     *time.Location
+	strip   bool
+}
+
+type timestampProcessor struct {
+	mode         string
+	maxSkew      time.Duration
+	keepOriginal string
+	rules        []timestampRule
+	now          func() time.Time
+}
+
+func newTimestampProcessor(raw json.RawMessage) (Processor, error) {
+	cfg := timestampConfig{Mode: timestampModeMissing, MaxSkew: "24h"}
+	if err := decodeConfig(raw, &cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.Rules) == 0 {
+		return nil, errors.New("timestamp requires at least one rule")
+	}
+
+	p := &timestampProcessor{
+		mode:         cfg.Mode,
+		keepOriginal: cfg.KeepOriginal,
+		now:          time.Now,
+	}
+	switch cfg.Mode {
+	case timestampModeMissing, timestampModeAlways:
+	case timestampModeSkew:
+		skew, err := time.ParseDuration(cfg.MaxSkew)
+		if err != nil || skew <= 0 {
+			return nil, fmt.Errorf("invalid max_skew %q", cfg.MaxSkew)
+		}
+		p.maxSkew = skew
+	default:
+		return nil, fmt.Errorf("unknown mode %q (expected missing, always or skew)", cfg.Mode)
+	}
+
+	for i, rc := range cfg.Rules {
+		rule, err := newTimestampRule(rc)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+		p.rules = append(p.rules, rule)
+	}
+	return p, nil
+}
+
+func newTimestampRule(rc timestampRuleConfig) (timestampRule, error) {
+	rule := timestampRule{field: rc.Field, strip: rc.Strip, loc: time.UTC}
+	if rule.field == "" {
+		rule.field = "message"
+	}
+	if len(rc.Sources) > 0 {
+		rule.sources = make(map[string]bool, len(rc.Sources))
+		for _, source := range rc.Sources {
+			rule.sources[source] = true
+		}
+	}
+
+	if rc.Pattern != "" {
+		re, err := regexp.Compile(rc.Pattern)
+		if err != nil {
+			return rule, err
+		}
+		rule.re = re
+		if i := re.SubexpIndex("timestamp"); i > 0 {
+			rule.group = i
+		}
+	}
+
+	if len(rc.Layouts) == 0 {
+		return rule, errors.New("at least one layout is required")
+	}
+	for _, layout := range rc.Layouts {
+		if named, ok := namedLayouts[strings.ToLower(layout)]; ok {
+			layout = named
+		}
+		rule.layouts = append(rule.layouts, layout)
+	}
+
+	if rc.Timezone != "" {
+		loc, err := time.LoadLocation(rc.Timezone)
+		if err != nil {
+			return rule, fmt.Errorf("invalid timezone %q: %w", rc.Timezone, err)
+		}
+		rule.loc = loc
+	}
+	return rule, nil
+}
+
+func (p *timestampProcessor) Process(ctx context.Context, entry *models.Log) ([]*models.Log, error) {
+	now := p.now()
+	switch p.mode {
+	case timestampModeMissing:
+		if !entry.Timestamp.IsZero() {
+			return Keep(entry), nil
+		}
+	case timestampModeSkew:
+		if !entry.Timestamp.IsZero() && absDuration(now.Sub(entry.Timestamp)) <= p.maxSkew {
+			return Keep(entry), nil
+		}
+	}
+
+	for _, rule := range p.rules {
+		if rule.sources != nil && !rule.sources[entry.Source] {
+			continue
+		}
+		value, ok := fieldString(entry, rule.field)
+		if !ok {
+			continue
+		}
+
+		// match is the span stripped from the message, text the part parsed
+		text, match := value, []int{0, len(value)}
+		if rule.re != nil {
+			m := rule.re.FindStringSubmatchIndex(value)
+			if m == nil || m[2*rule.group] < 0 {
+				continue
+			}
+			match = m[:2]
+			text = value[m[2*rule.group]:m[2*rule.group+1]]
+		}
+
+		ts, err := rule.parse(strings.TrimSpace(text), now)
+		if err != nil {
+			return nil, err
+		}
+
+		if p.keepOriginal != "" && !entry.Timestamp.IsZero() {
+			if entry.Fields == nil {
+				entry.Fields = models.Fields{}
+			}
+			entry.Fields[p.keepOriginal] = entry.Timestamp.UTC().Format(time.RFC3339Nano)
+		}
+		entry.Timestamp = ts
+
+		if rule.strip && rule.field == "message" {
+			entry.Message = strings.TrimSpace(value[:match[0]] + value[match[1]:])
+		}
+		return Keep(entry), nil
+	}
+
+	return Keep(entry), nil
+}
+
+// parse tries every layout, then unix epochs for the "unix" and "unix_ms" layouts
+func (r *timestampRule) parse(text string, now time.Time) (time.Time, error) {
+	for _, layout := range r.layouts {
+		switch layout {
+		case "unix", "unix_ms":
+			n, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				continue
+			}
+			if layout == "unix_ms" {
+				return time.UnixMilli(int64(n)).UTC(), nil
+			}
+			sec := int64(n)
+			return time.Unix(sec, int64((n-float64(sec))*1e9)).UTC(), nil
+		}
+
+		ts, err := time.ParseInLocation(layout, text, r.loc)
+		if err != nil {
+			continue
+		}
+		if ts.Year() == 0 {
+			ts = withCurrentYear(ts, now.In(r.loc))
+		}
+		return ts, nil
+	}
+	return time.Time{}, fmt.Errorf("timestamp %q matches none of the configured layouts", text)
+}
+
+// withCurrentYear completes a timestamp from a layout without a year, such
+// as syslog's, assuming it is not more than a day in the future
+func withCurrentYear(ts, now time.Time) time.Time {
+	ts = ts.AddDate(now.Year(), 0, 0)
+	if ts.Sub(now) > 24*time.Hour {
+		ts = ts.AddDate(-1, 0, 0)
+	}
+	return ts
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
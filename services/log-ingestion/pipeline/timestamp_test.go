@@ -0,0 +1,142 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func newTestTimestampProcessor(t *testing.T, config string, now time.Time) *timestampProcessor {
+	processor, err := newTimestampProcessor([]byte(config))
+	if err != nil {
+		t.Fatalf("Failed to create stage: %v", err)
+	}
+	p := processor.(*timestampProcessor)
+	p.now = func() time.Time { return now }
+	return p
+}
+
+func TestTimestampStage_Layouts(t *testing.T) {
+	now := time.Date(2025, 8, 29, 12, 0, 0, 0, time.UTC)
+	p := newTestTimestampProcessor(t, `{"rules":[
+		{"sources":["nginx"],"pattern":"\\[(?P<timestamp>[^\\]]+)\\]","layouts":["apache"],"strip":true},
+		{"sources":["app"],"pattern":"^(?P<timestamp>\\S+ \\S+)","layouts":["rfc3339","datetime"],"timezone":"Europe/Berlin"},
+		{"sources":["sshd"],"pattern":"^\\w{3} [ \\d]\\d \\d\\d:\\d\\d:\\d\\d","layouts":["syslog"]},
+		{"sources":["jobs"],"field":"ts","layouts":["unix_ms"]},
+		{"sources":["cron"],"field":"ts","layouts":["unix"]}
+	]}`, now)
+
+	tests := []struct {
+		entry    *models.Log
+		expected time.Time
+		message  string
+	}{
+		{
+			&models.Log{Source: "nginx", Message: `10.0.0.1 - - [29/Aug/2025:10:15:30 +0200] "GET / HTTP/1.1" 200`},
+			time.Date(2025, 8, 29, 8, 15, 30, 0, time.UTC),
+			`10.0.0.1 - -  "GET / HTTP/1.1" 200`,
+		},
+		{
+			&models.Log{Source: "app", Message: "2025-08-29 10:15:30.250 worker started"},
+			time.Date(2025, 8, 29, 8, 15, 30, 250e6, time.UTC),
+			"",
+		},
+		{
+			&models.Log{Source: "sshd", Message: "Aug 29 11:59:01 host sshd[42]: Accepted publickey"},
+			time.Date(2025, 8, 29, 11, 59, 1, 0, time.UTC),
+			"",
+		},
+		{
+			&models.Log{Source: "sshd", Message: "Dec 31 23:59:59 host sshd[42]: last year"},
+			time.Date(2024, 12, 31, 23, 59, 59, 0, time.UTC),
+			"",
+		},
+		{
+			&models.Log{Source: "jobs", Message: "done", Fields: models.Fields{"ts": "1756462530000"}},
+			time.Date(2025, 8, 29, 10, 15, 30, 0, time.UTC),
+			"",
+		},
+		{
+			&models.Log{Source: "cron", Message: "done", Fields: models.Fields{"ts": "1756462530.5"}},
+			time.Date(2025, 8, 29, 10, 15, 30, 5e8, time.UTC),
+			"",
+		},
+	}
+
+	for _, tt := range tests {
+		original := tt.entry.Message
+		if _, err := p.Process(context.Background(), tt.entry); err != nil {
+			t.Errorf("Source %s: expected no error, got %v", tt.entry.Source, err)
+			continue
+		}
+		if !tt.entry.Timestamp.Equal(tt.expected) {
+			t.Errorf("Source %s: expected %v, got %v", tt.entry.Source, tt.expected, tt.entry.Timestamp.UTC())
+		}
+		if tt.message != "" && tt.entry.Message != tt.message {
+			t.Errorf("Source %s: expected stripped message %q, got %q", tt.entry.Source, tt.message, tt.entry.Message)
+		}
+		if tt.message == "" && tt.entry.Message != original {
+			t.Errorf("Source %s: expected message untouched, got %q", tt.entry.Source, tt.entry.Message)
+		}
+	}
+}
+
+func TestTimestampStage_Modes(t *testing.T) {
+	now := time.Date(2025, 8, 29, 12, 0, 0, 0, time.UTC)
+	rules := `"rules":[{"pattern":"at (?P<timestamp>\\S+)","layouts":["rfc3339"]}]`
+	message := "job finished at 2025-08-29T11:00:00Z"
+	eventTime := time.Date(2025, 8, 29, 11, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		config   string
+		envelope time.Time
+		expected time.Time
+	}{
+		{`{` + rules + `}`, time.Time{}, eventTime},
+		{`{` + rules + `}`, now, now},
+		{`{"mode":"always",` + rules + `}`, now, eventTime},
+		{`{"mode":"skew","max_skew":"1h",` + rules + `}`, now.Add(-30 * time.Minute), now.Add(-30 * time.Minute)},
+		{`{"mode":"skew","max_skew":"1h",` + rules + `}`, now.Add(-48 * time.Hour), eventTime},
+	}
+
+	for _, tt := range tests {
+		p := newTestTimestampProcessor(t, tt.config, now)
+		entry := &models.Log{Message: message, Timestamp: tt.envelope}
+		p.Process(context.Background(), entry)
+		if !entry.Timestamp.Equal(tt.expected) {
+			t.Errorf("Config %s with envelope %v: expected %v, got %v", tt.config, tt.envelope, tt.expected, entry.Timestamp)
+		}
+	}
+}
+
+func TestTimestampStage_KeepOriginalAndErrors(t *testing.T) {
+	now := time.Date(2025, 8, 29, 12, 0, 0, 0, time.UTC)
+	p := newTestTimestampProcessor(t, `{"mode":"always","keep_original":"received_at","rules":[{"pattern":"^(\\S+)","layouts":["rfc3339"]}]}`, now)
+
+	entry := &models.Log{Message: "2025-08-29T09:00:00Z started", Timestamp: now}
+	p.Process(context.Background(), entry)
+	if entry.Fields["received_at"] != "2025-08-29T12:00:00Z" {
+		t.Errorf("Expected original timestamp kept, got %v", entry.Fields["received_at"])
+	}
+
+	if _, err := p.Process(context.Background(), &models.Log{Message: "yesterday started"}); err == nil {
+		t.Errorf("Expected error for unparseable timestamp")
+	}
+}
+
+func TestTimestampStage_InvalidConfig(t *testing.T) {
+	configs := []string{
+		`{"rules":[]}`,
+		`{"rules":[{"pattern":"("}]}`,
+		`{"rules":[{"layouts":[]}]}`,
+		`{"rules":[{"layouts":["rfc3339"],"timezone":"Mars/Olympus"}]}`,
+		`{"mode":"sometimes","rules":[{"layouts":["rfc3339"]}]}`,
+		`{"mode":"skew","max_skew":"0s","rules":[{"layouts":["rfc3339"]}]}`,
+	}
+	for _, config := range configs {
+		if _, err := newTimestampProcessor([]byte(config)); err == nil {
+			t.Errorf("Expected error for config %s", config)
+		}
+	}
+}
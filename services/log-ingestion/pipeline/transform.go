@@ -0,0 +1,122 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+	"log-processing-system/services/log-ingestion/transform"
+)
+
+func init() {
+	Register("transform", newTransformProcessor)
+}
+
+// transformConfig holds a transform program, either inline or in a file.
+// A file is checked for changes every reload_interval and recompiled when
+// its modification time moves; a program that fails to compile is logged
+// and the previous one stays in effect.
+type transformConfig struct {
+	Program        string `json:"program"`
+	File           string `json:"file"`
+	ReloadInterval string `json:"reload_interval"`
+}
+
+type transformProcessor struct {
+	program atomic.Value // *transform.Program
+
+	file    string
+	watcher *fileWatcher
+}
+
+func newTransformProcessor(raw json.RawMessage) (Processor, error) {
+	cfg := transformConfig{ReloadInterval: "10s"}
+	if err := decodeConfig(raw, &cfg); err != nil {
+		return nil, err
+	}
+	if (cfg.Program == "") == (cfg.File == "") {
+		return nil, errors.New("transform requires exactly one of program or file")
+	}
+
+	p := &transformProcessor{file: cfg.File}
+
+	if cfg.Program != "" {
+		program, err := transform.Compile(cfg.Program)
+		if err != nil {
+			return nil, fmt.Errorf("invalid transform program: %w", err)
+		}
+		p.program.Store(program)
+		return p, nil
+	}
+
+	interval, err := time.ParseDuration(cfg.ReloadInterval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reload_interval %q: %w", cfg.ReloadInterval, err)
+	}
+	if p.watcher, err = newFileWatcher(p.file, interval); err != nil {
+		return nil, fmt.Errorf("failed to read transform file: %w", err)
+	}
+	program, err := p.load()
+	if err != nil {
+		return nil, err
+	}
+	p.program.Store(program)
+
+	return p, nil
+}
+
+func (p *transformProcessor) Process(ctx context.Context, entry *models.Log) ([]*models.Log, error) {
+	if p.watcher != nil {
+		p.reloadIfChanged()
+	}
+
+	program := p.program.Load().(*transform.Program)
+	keep, err := program.Apply(entry)
+	if err != nil {
+		return nil, err
+	}
+	if !keep {
+		return nil, nil
+	}
+	return Keep(entry), nil
+}
+
+func (p *transformProcessor) load() (*transform.Program, error) {
+	data, err := os.ReadFile(p.file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transform file: %w", err)
+	}
+	program, err := transform.Compile(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid transform file %s: %w", p.file, err)
+	}
+	return program, nil
+}
+
+// reloadIfChanged recompiles the program when the file has been modified
+func (p *transformProcessor) reloadIfChanged() {
+	changed, err := p.watcher.changed()
+	if err != nil {
+		pipelineLogger.WithError(err).WithField("file", p.file).Warn("Failed to check transform file for changes")
+		return
+	}
+	if !changed {
+		return
+	}
+
+	program, err := p.load()
+	if err != nil {
+		pipelineLogger.WithError(err).WithField("file", p.file).Error("Transform reload failed, keeping previous program")
+		return
+	}
+	p.program.Store(program)
+
+	pipelineLogger.WithFields(map[string]interface{}{
+		"file":       p.file,
+		"statements": program.Len(),
+	}).Info("Transform program reloaded")
+}
@@ -0,0 +1,80 @@
+package pipeline
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func TestTransformStage_Inline(t *testing.T) {
+	p := buildPipeline(t, `{"stages":[{"type":"transform","config":{"program":
+		"set .env = \"prod\"\ndrop if .message == \"ping\""}}]}`)
+
+	entries, err := p.Process(context.Background(), &models.Log{Message: "hello", Level: "info"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(entries) != 1 || entries[0].Fields["env"] != "prod" {
+		t.Errorf("Expected one entry with env prod, got %+v", entries)
+	}
+
+	entries, _ = p.Process(context.Background(), &models.Log{Message: "ping", Level: "info"})
+	if len(entries) != 0 {
+		t.Errorf("Expected ping entry to be dropped, got %d entries", len(entries))
+	}
+}
+
+func TestTransformStage_Reload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rules.transform")
+	if err := os.WriteFile(file, []byte(`set .version = 1`), 0644); err != nil {
+		t.Fatalf("Failed to write program: %v", err)
+	}
+
+	processor, err := newTransformProcessor([]byte(`{"file":"` + filepath.ToSlash(file) + `","reload_interval":"1ns"}`))
+	if err != nil {
+		t.Fatalf("Failed to create stage: %v", err)
+	}
+
+	version := func() interface{} {
+		entry := &models.Log{Message: "m"}
+		if _, err := processor.Process(context.Background(), entry); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return entry.Fields["version"]
+	}
+
+	if v := version(); v != int64(1) {
+		t.Fatalf("Expected version 1, got %v", v)
+	}
+
+	later := time.Now().Add(time.Minute)
+	os.WriteFile(file, []byte(`set .version = 2`), 0644)
+	os.Chtimes(file, later, later)
+	if v := version(); v != int64(2) {
+		t.Errorf("Expected reloaded version 2, got %v", v)
+	}
+
+	// A broken program keeps the previous one in effect
+	os.WriteFile(file, []byte(`set .version =`), 0644)
+	os.Chtimes(file, later.Add(time.Minute), later.Add(time.Minute))
+	if v := version(); v != int64(2) {
+		t.Errorf("Expected version 2 after failed reload, got %v", v)
+	}
+}
+
+func TestTransformStage_InvalidConfig(t *testing.T) {
+	configs := []string{
+		`{}`,
+		`{"program":"set .a = 1","file":"rules.transform"}`,
+		`{"program":"set .a ="}`,
+		`{"file":"/nonexistent/rules.transform"}`,
+	}
+	for _, config := range configs {
+		if _, err := newTransformProcessor([]byte(config)); err == nil {
+			t.Errorf("Expected error for config %s", config)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func init() {
+	Register("user_agent", newUserAgentProcessor)
+}
+
+// userAgentConfig maps fields containing user-agent strings to the prefix
+// of the fields added for them, e.g. {"http_user_agent": "ua"}
+type userAgentConfig struct {
+	Fields    map[string]string `json:"fields"`
+	CacheSize int               `json:"cache_size"`
+}
+
+// UserAgent is the parsed form of a user-agent string
+type UserAgent struct {
+	Browser        string
+	BrowserVersion string
+	OS             string
+	OSVersion      string
+	Device         string
+}
+
+type userAgentProcessor struct {
+	fields   []string
+	prefixes map[string]string
+
+	mu        sync.Mutex
+	cache     map[string]UserAgent
+	cacheSize int
+}
+
+// uaPattern matches a browser or OS token; the first submatch is the version
+type uaPattern struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// Order matters: many browsers include the tokens of the ones they imitate
+var browserPatterns = []uaPattern{
+	{"Edge", regexp.MustCompile(`Edg(?:e|A|iOS)?/([\d.]+)`)},
+	{"Opera", regexp.MustCompile(`(?:OPR|Opera)/([\d.]+)`)},
+	{"Samsung Internet", regexp.MustCompile(`SamsungBrowser/([\d.]+)`)},
+	{"Chrome", regexp.MustCompile(`(?:Chrome|CriOS)/([\d.]+)`)},
+	{"Firefox", regexp.MustCompile(`(?:Firefox|FxiOS)/([\d.]+)`)},
+	{"Safari", regexp.MustCompile(`Version/([\d.]+).*Safari/`)},
+	{"Internet Explorer", regexp.MustCompile(`(?:MSIE |Trident/.*rv:)([\d.]+)`)},
+	{"curl", regexp.MustCompile(`^curl/([\d.]+)`)},
+	{"Go HTTP Client", regexp.MustCompile(`^Go-http-client/([\d.]+)`)},
+	{"Python Requests", regexp.MustCompile(`^python-requests/([\d.]+)`)},
+}
+
+var osPatterns = []uaPattern{
+	{"Windows", regexp.MustCompile(`Windows NT ([\d.]+)`)},
+	{"iOS", regexp.MustCompile(`(?:iPhone|CPU) OS ([\d_]+)`)},
+	{"macOS", regexp.MustCompile(`Mac OS X ([\d_.]+)`)},
+	{"Android", regexp.MustCompile(`Android ([\d.]+)`)},
+	{"Chrome OS", regexp.MustCompile(`CrOS \S+ ([\d.]+)`)},
+	{"Linux", regexp.MustCompile(`Linux()`)},
+}
+
+var (
+	botPattern    = regexp.MustCompile(`(?i)bot|crawl|spider|slurp|facebookexternalhit|monitor`)
+	tabletPattern = regexp.MustCompile(`iPad|Tablet`)
+	mobilePattern = regexp.MustCompile(`Mobi|iPhone|iPod`)
+)
+
+// windowsVersions maps Windows NT kernel versions to marketing names
+var windowsVersions = map[string]string{
+	"10.0": "10",
+	"6.3":  "8.1",
+	"6.2":  "8",
+	"6.1":  "7",
+	"6.0":  "Vista",
+	"5.1":  "XP",
+}
+
+func newUserAgentProcessor(raw json.RawMessage) (Processor, error) {
+	cfg := userAgentConfig{CacheSize: 10000}
+	if err := decodeConfig(raw, &cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.Fields) == 0 {
+		return nil, errors.New("user_agent requires at least one field mapping")
+	}
+
+	p := &userAgentProcessor{
+		prefixes:  cfg.Fields,
+		cache:     make(map[string]UserAgent),
+		cacheSize: cfg.CacheSize,
+	}
+	for field := range cfg.Fields {
+		p.fields = append(p.fields, field)
+	}
+	sort.Strings(p.fields)
+
+	return p, nil
+}
+
+func (p *userAgentProcessor) Process(ctx context.Context, entry *models.Log) ([]*models.Log, error) {
+	for _, field := range p.fields {
+		value, ok := entry.Fields[field].(string)
+		if !ok || value == "" {
+			continue
+		}
+
+		ua := p.parse(value)
+		prefix := p.prefixes[field]
+		setIfPresent(entry, prefix+".browser", ua.Browser)
+		setIfPresent(entry, prefix+".browser_version", ua.BrowserVersion)
+		setIfPresent(entry, prefix+".os", ua.OS)
+		setIfPresent(entry, prefix+".os_version", ua.OSVersion)
+		setIfPresent(entry, prefix+".device", ua.Device)
+	}
+
+	return Keep(entry), nil
+}
+
+// parse returns the cached result for value, parsing it on first sight
+func (p *userAgentProcessor) parse(value string) UserAgent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if ua, ok := p.cache[value]; ok {
+		return ua
+	}
+
+	ua := ParseUserAgent(value)
+	if p.cacheSize > 0 {
+		// Crude bound: start over rather than track recency
+		if len(p.cache) >= p.cacheSize {
+			p.cache = make(map[string]UserAgent)
+		}
+		p.cache[value] = ua
+	}
+	return ua
+}
+
+// ParseUserAgent extracts browser, operating system and device class from a user-agent string
+func ParseUserAgent(value string) UserAgent {
+	ua := UserAgent{Browser: "Other", OS: "Other"}
+
+	for _, pattern := range browserPatterns {
+		if match := pattern.re.FindStringSubmatch(value); match != nil {
+			ua.Browser = pattern.name
+			ua.BrowserVersion = match[1]
+			break
+		}
+	}
+
+	for _, pattern := range osPatterns {
+		if match := pattern.re.FindStringSubmatch(value); match != nil {
+			ua.OS = pattern.name
+			ua.OSVersion = strings.ReplaceAll(match[1], "_", ".")
+			break
+		}
+	}
+	if ua.OS == "Windows" {
+		if name, ok := windowsVersions[ua.OSVersion]; ok {
+			ua.OSVersion = name
+		}
+	}
+
+	switch {
+	case botPattern.MatchString(value):
+		ua.Device = "bot"
+	case tabletPattern.MatchString(value), ua.OS == "Android" && !strings.Contains(value, "Mobile"):
+		ua.Device = "tablet"
+	case mobilePattern.MatchString(value), ua.OS == "Android":
+		ua.Device = "mobile"
+	case ua.OS == "Other" && ua.Browser != "Other" && !strings.Contains(value, "Mozilla"):
+		ua.Device = "client"
+	default:
+		ua.Device = "desktop"
+	}
+
+	return ua
+}
@@ -0,0 +1,65 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func TestParseUserAgent(t *testing.T) {
+	tests := []struct {
+		ua       string
+		expected UserAgent
+	}{
+		{
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			UserAgent{"Chrome", "120.0.0.0", "Windows", "10", "desktop"},
+		},
+		{
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.91",
+			UserAgent{"Edge", "120.0.2210.91", "Windows", "10", "desktop"},
+		},
+		{
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_1_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1.2 Mobile/15E148 Safari/604.1",
+			UserAgent{"Safari", "17.1.2", "iOS", "17.1.2", "mobile"},
+		},
+		{
+			"Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:121.0) Gecko/20100101 Firefox/121.0",
+			UserAgent{"Firefox", "121.0", "macOS", "10.15", "desktop"},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36",
+			UserAgent{"Chrome", "119.0.0.0", "Android", "13", "tablet"},
+		},
+		{
+			"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			UserAgent{"Other", "", "Other", "", "bot"},
+		},
+		{
+			"curl/8.4.0",
+			UserAgent{"curl", "8.4.0", "Other", "", "client"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expected.Browser+"/"+test.expected.OS, func(t *testing.T) {
+			if got := ParseUserAgent(test.ua); got != test.expected {
+				t.Errorf("ParseUserAgent(%q) = %+v, want %+v", test.ua, got, test.expected)
+			}
+		})
+	}
+}
+
+func TestUserAgentStage(t *testing.T) {
+	p := buildPipeline(t, `{"stages":[{"type":"user_agent","config":{"fields":{"http_user_agent":"ua"}}}]}`)
+
+	entry := &models.Log{Message: "GET /", Fields: models.Fields{"http_user_agent": "curl/8.4.0"}}
+	p.Process(context.Background(), entry)
+
+	if entry.Fields["ua.browser"] != "curl" || entry.Fields["ua.device"] != "client" {
+		t.Errorf("Expected parsed user agent fields, got %v", entry.Fields)
+	}
+	if _, ok := entry.Fields["ua.os_version"]; ok {
+		t.Errorf("Expected empty values to be omitted, got %v", entry.Fields)
+	}
+}
@@ -0,0 +1,55 @@
+package pipeline
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// fileWatcher reports when a file's modification time moves. It checks at
+// most once per interval, so stages can poll it from the hot path.
+type fileWatcher struct {
+	path     string
+	interval time.Duration
+
+	mu        sync.Mutex
+	lastCheck time.Time
+	modTime   time.Time
+}
+
+func newFileWatcher(path string, interval time.Duration) (*fileWatcher, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &fileWatcher{
+		path:      path,
+		interval:  interval,
+		lastCheck: time.Now(),
+		modTime:   info.ModTime(),
+	}, nil
+}
+
+// changed reports whether the file was modified since the last change it
+// reported. Callers arriving while another check runs get false.
+func (w *fileWatcher) changed() (bool, error) {
+	if w.interval <= 0 || !w.mu.TryLock() {
+		return false, nil
+	}
+	defer w.mu.Unlock()
+
+	if time.Since(w.lastCheck) < w.interval {
+		return false, nil
+	}
+	w.lastCheck = time.Now()
+
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return false, err
+	}
+	if info.ModTime().Equal(w.modTime) {
+		return false, nil
+	}
+	w.modTime = info.ModTime()
+	return true, nil
+}
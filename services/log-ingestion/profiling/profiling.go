@@ -0,0 +1,52 @@
+// Package profiling serves the runtime profiles of net/http/pprof, so CPU,
+// heap, goroutine, block and mutex profiles can be captured from a running
+// service when ingestion slows down.
+package profiling
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+)
+
+// Config controls the optional runtime profiles. Block and mutex profiles
+// cost time on every contended operation, so they are off unless a rate is
+// set.
+type Config struct {
+	// Record one blocking event per BlockProfileRate nanoseconds blocked;
+	// 1 records every event, 0 none
+	BlockProfileRate int
+	// Report one in MutexProfileFraction mutex contention events; 0 none
+	MutexProfileFraction int
+}
+
+// Configure sets the runtime's block and mutex profiling rates
+func Configure(cfg Config) {
+	runtime.SetBlockProfileRate(cfg.BlockProfileRate)
+	runtime.SetMutexProfileFraction(cfg.MutexProfileFraction)
+}
+
+// Handler serves the profiles under /debug/pprof/, the paths go tool pprof
+// expects. Mounted under another prefix, strip it first:
+//
+//	http.StripPrefix("/admin", profiling.Handler())
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+// NewServer creates a server for the profiles alone, listening on addr. It
+// has no write timeout, which would cut CPU profiles and traces short.
+func NewServer(addr string) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
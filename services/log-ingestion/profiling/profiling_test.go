@@ -0,0 +1,50 @@
+package profiling
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	handler := Handler()
+
+	tests := []struct {
+		path     string
+		contains string
+	}{
+		{"/debug/pprof/", "goroutine"},
+		{"/debug/pprof/heap?debug=1", "heap profile"},
+		{"/debug/pprof/goroutine?debug=1", "goroutine profile"},
+		{"/debug/pprof/cmdline", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+			if !strings.Contains(rec.Body.String(), tt.contains) {
+				t.Errorf("Expected the response to contain %q", tt.contains)
+			}
+		})
+	}
+}
+
+func TestHandler_StripPrefix(t *testing.T) {
+	handler := http.StripPrefix("/admin", Handler())
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/debug/pprof/goroutine?debug=1", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "goroutine profile") {
+		t.Errorf("Expected the goroutine profile, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/debug/pprof/nonexistent", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown profile, got %d", rec.Code)
+	}
+}
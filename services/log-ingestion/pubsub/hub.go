@@ -0,0 +1,129 @@
+package pubsub
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"log-processing-system/services/log-ingestion/models"
+)
+
+// DefaultBufferSize is the per-subscriber channel capacity used when none is given
+const DefaultBufferSize = 256
+
+// Hub fans out newly stored log entries to any number of subscribers.
+// Publishing never blocks: a subscriber that falls behind loses entries
+// instead of stalling the listener or the other subscribers.
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[uint64]*Subscription
+	nextID      uint64
+	dropped     uint64
+	closed      bool
+}
+
+// Subscription receives entries published to the hub until it is closed
+type Subscription struct {
+	C <-chan models.Log
+
+	id      uint64
+	ch      chan models.Log
+	filter  func(models.Log) bool
+	hub     *Hub
+	once    sync.Once
+	dropped uint64
+}
+
+// NewHub creates an empty hub
+func NewHub() *Hub {
+	return &Hub{
+		subscribers: make(map[uint64]*Subscription),
+	}
+}
+
+// Subscribe registers a new subscriber. A nil filter accepts every entry.
+func (h *Hub) Subscribe(bufferSize int, filter func(models.Log) bool) *Subscription {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+
+	ch := make(chan models.Log, bufferSize)
+	sub := &Subscription{
+		C:      ch,
+		ch:     ch,
+		filter: filter,
+		hub:    h,
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		// Nothing will be published any more
+		sub.once.Do(func() { close(ch) })
+		return sub
+	}
+	h.nextID++
+	sub.id = h.nextID
+	h.subscribers[sub.id] = sub
+
+	return sub
+}
+
+// Publish delivers an entry to every matching subscriber without blocking
+func (h *Hub) Publish(entry models.Log) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, sub := range h.subscribers {
+		if sub.filter != nil && !sub.filter(entry) {
+			continue
+		}
+		select {
+		case sub.ch <- entry:
+		default:
+			atomic.AddUint64(&sub.dropped, 1)
+			atomic.AddUint64(&h.dropped, 1)
+		}
+	}
+}
+
+// SubscriberCount returns the number of active subscribers
+func (h *Hub) SubscriberCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subscribers)
+}
+
+// Close ends every subscription by closing its channel, so that streams
+// reading from the hub finish, and closes subscriptions made afterwards at
+// once. The server calls it when shutting down, as it waits for them.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	h.closed = true
+	subscribers := h.subscribers
+	h.subscribers = make(map[uint64]*Subscription)
+	h.mu.Unlock()
+
+	for _, sub := range subscribers {
+		sub.once.Do(func() { close(sub.ch) })
+	}
+}
+
+// Dropped returns the total number of entries dropped for slow subscribers
+func (h *Hub) Dropped() uint64 {
+	return atomic.LoadUint64(&h.dropped)
+}
+
+// Close unregisters the subscription and closes its channel. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.hub.mu.Lock()
+		delete(s.hub.subscribers, s.id)
+		s.hub.mu.Unlock()
+		close(s.ch)
+	})
+}
+
+// Dropped returns the number of entries this subscriber missed because its buffer was full
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
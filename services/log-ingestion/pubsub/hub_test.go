@@ -0,0 +1,116 @@
+package pubsub
+
+import (
+	"testing"
+
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func TestHub_PublishDeliversToSubscribers(t *testing.T) {
+	hub := NewHub()
+
+	first := hub.Subscribe(4, nil)
+	defer first.Close()
+	second := hub.Subscribe(4, nil)
+	defer second.Close()
+
+	hub.Publish(models.Log{ID: 1, Message: "hello", Level: "info"})
+
+	for _, sub := range []*Subscription{first, second} {
+		select {
+		case entry := <-sub.C:
+			if entry.ID != 1 {
+				t.Errorf("Expected entry ID 1, got %d", entry.ID)
+			}
+		default:
+			t.Errorf("Expected subscriber to receive published entry")
+		}
+	}
+}
+
+func TestHub_Filter(t *testing.T) {
+	hub := NewHub()
+
+	sub := hub.Subscribe(4, func(entry models.Log) bool {
+		return entry.Level == "error"
+	})
+	defer sub.Close()
+
+	hub.Publish(models.Log{ID: 1, Level: "info"})
+	hub.Publish(models.Log{ID: 2, Level: "error"})
+
+	select {
+	case entry := <-sub.C:
+		if entry.ID != 2 {
+			t.Errorf("Expected only the error entry, got ID %d", entry.ID)
+		}
+	default:
+		t.Fatalf("Expected filtered subscriber to receive matching entry")
+	}
+
+	if len(sub.C) != 0 {
+		t.Errorf("Expected no further entries, got %d", len(sub.C))
+	}
+}
+
+func TestHub_SlowSubscriberDropsInsteadOfBlocking(t *testing.T) {
+	hub := NewHub()
+
+	sub := hub.Subscribe(1, nil)
+	defer sub.Close()
+
+	hub.Publish(models.Log{ID: 1})
+	hub.Publish(models.Log{ID: 2})
+	hub.Publish(models.Log{ID: 3})
+
+	if sub.Dropped() != 2 {
+		t.Errorf("Expected 2 dropped entries for subscriber, got %d", sub.Dropped())
+	}
+	if hub.Dropped() != 2 {
+		t.Errorf("Expected 2 dropped entries for hub, got %d", hub.Dropped())
+	}
+}
+
+func TestSubscription_Close(t *testing.T) {
+	hub := NewHub()
+
+	sub := hub.Subscribe(1, nil)
+	if hub.SubscriberCount() != 1 {
+		t.Fatalf("Expected 1 subscriber, got %d", hub.SubscriberCount())
+	}
+
+	sub.Close()
+	sub.Close() // must be safe to call twice
+
+	if hub.SubscriberCount() != 0 {
+		t.Errorf("Expected 0 subscribers after close, got %d", hub.SubscriberCount())
+	}
+
+	if _, ok := <-sub.C; ok {
+		t.Errorf("Expected subscription channel to be closed")
+	}
+
+	// Publishing after close must not panic
+	hub.Publish(models.Log{ID: 1})
+}
+
+func TestHub_Close(t *testing.T) {
+	hub := NewHub()
+
+	sub := hub.Subscribe(1, nil)
+	hub.Close()
+	if _, ok := <-sub.C; ok {
+		t.Errorf("Expected the subscription to be ended by closing the hub")
+	}
+	if hub.SubscriberCount() != 0 {
+		t.Errorf("Expected 0 subscribers after close, got %d", hub.SubscriberCount())
+	}
+	sub.Close() // still safe after the hub closed it
+
+	late := hub.Subscribe(1, nil)
+	if _, ok := <-late.C; ok {
+		t.Errorf("Expected a subscription made after close to be ended at once")
+	}
+	late.Close()
+	hub.Publish(models.Log{ID: 1})
+}
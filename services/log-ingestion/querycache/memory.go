@@ -0,0 +1,95 @@
+package querycache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// memoryCache is a least recently used cache bounded by entries and bytes
+type memoryCache struct {
+	ttl           time.Duration
+	maxEntryBytes int64
+	maxEntries    int
+	maxBytes      int64
+	now           func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // most recently used first
+	bytes   int64
+}
+
+func newMemoryCache(cfg Config) *memoryCache {
+	return &memoryCache{
+		ttl:           cfg.TTL,
+		maxEntryBytes: cfg.MaxEntryBytes,
+		maxEntries:    cfg.MaxEntries,
+		maxBytes:      cfg.MaxBytes,
+		now:           time.Now,
+		entries:       make(map[string]*list.Element),
+		order:         list.New(),
+	}
+}
+
+func (c *memoryCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := elem.Value.(*memoryEntry)
+	if !c.now().Before(entry.expires) {
+		c.remove(elem)
+		return nil, false, nil
+	}
+	c.order.MoveToFront(elem)
+	return entry.value, true, nil
+}
+
+func (c *memoryCache) Set(ctx context.Context, key string, value []byte) error {
+	size := int64(len(value))
+	if size > c.maxEntryBytes || size > c.maxBytes {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+	c.entries[key] = c.order.PushFront(&memoryEntry{key: key, value: value, expires: c.now().Add(c.ttl)})
+	c.bytes += size
+	for len(c.entries) > c.maxEntries || c.bytes > c.maxBytes {
+		c.remove(c.order.Back())
+		evictionsCounter.Inc()
+	}
+	bytesGauge.Set(float64(c.bytes))
+	return nil
+}
+
+func (c *memoryCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+	c.bytes = 0
+	bytesGauge.Set(0)
+	return nil
+}
+
+// remove drops an entry; the caller holds c.mu
+func (c *memoryCache) remove(elem *list.Element) {
+	entry := c.order.Remove(elem).(*memoryEntry)
+	delete(c.entries, entry.key)
+	c.bytes -= int64(len(entry.value))
+	bytesGauge.Set(float64(c.bytes))
+}
@@ -0,0 +1,77 @@
+package querycache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newTestMemoryCache(t *testing.T, cfg Config) (*memoryCache, *time.Time) {
+	t.Helper()
+	cfg.Backend = "memory"
+	c, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	mc := c.(*memoryCache)
+	mc.now = func() time.Time { return now }
+	return mc, &now
+}
+
+func TestMemoryCache_TTL(t *testing.T) {
+	c, now := newTestMemoryCache(t, Config{TTL: 10 * time.Second})
+	ctx := context.Background()
+
+	c.Set(ctx, "/logs/aggregate?interval=hour", []byte(`{"aggregates":[]}`))
+	if value, ok, _ := c.Get(ctx, "/logs/aggregate?interval=hour"); !ok || string(value) != `{"aggregates":[]}` {
+		t.Fatalf("Expected the cached response, got %q, %v", value, ok)
+	}
+	if _, ok, _ := c.Get(ctx, "/logs/aggregate?interval=day"); ok {
+		t.Error("Expected a miss for another query")
+	}
+
+	*now = now.Add(10 * time.Second)
+	if _, ok, _ := c.Get(ctx, "/logs/aggregate?interval=hour"); ok {
+		t.Error("Expected the response to expire after the TTL")
+	}
+	if len(c.entries) != 0 || c.bytes != 0 {
+		t.Errorf("Expected the expired response dropped, got %d entries of %d bytes", len(c.entries), c.bytes)
+	}
+}
+
+func TestMemoryCache_Limits(t *testing.T) {
+	c, _ := newTestMemoryCache(t, Config{MaxEntries: 2, MaxBytes: 10, MaxEntryBytes: 6})
+	ctx := context.Background()
+
+	c.Set(ctx, "a", []byte("aaaa"))
+	c.Set(ctx, "b", []byte("bbbb"))
+	c.Get(ctx, "a")
+	// Over MaxEntries: b was used least recently
+	c.Set(ctx, "c", []byte("cc"))
+	if _, ok, _ := c.Get(ctx, "b"); ok {
+		t.Error("Expected the least recently used response evicted")
+	}
+	// Over MaxBytes: a is now the least recently used
+	c.Set(ctx, "d", []byte("dddddd"))
+	if _, ok, _ := c.Get(ctx, "a"); ok {
+		t.Error("Expected a response evicted to stay within MaxBytes")
+	}
+	if c.bytes != 8 || len(c.entries) != 2 {
+		t.Errorf("Expected c and d of 8 bytes cached, got %d entries of %d bytes", len(c.entries), c.bytes)
+	}
+
+	c.Set(ctx, "e", []byte("eeeeeee"))
+	if _, ok, _ := c.Get(ctx, "e"); ok {
+		t.Error("Expected a response over MaxEntryBytes not cached")
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	if _, err := New(Config{Backend: "memcached"}); err == nil {
+		t.Error("Expected an unknown backend to be refused")
+	}
+	if _, err := New(Config{Backend: "redis"}); err == nil {
+		t.Error("Expected the redis backend to require an address")
+	}
+}
@@ -0,0 +1,129 @@
+// Package querycache keeps the responses of bounded queries for a short
+// time, so dashboards refreshing the same panels are answered from memory or
+// Redis instead of running identical queries against PostgreSQL. A cached
+// response is served until its TTL expires, so entries stored late or
+// deleted in the meantime show up at most one TTL later.
+package querycache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	hitsCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "query_cache_hits_total",
+		Help: "Query responses served from the query cache",
+	})
+	missesCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "query_cache_misses_total",
+		Help: "Cacheable queries run against the database because the query cache held no response",
+	})
+	errorsCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "query_cache_errors_total",
+		Help: "Query cache reads and writes that failed, answered from the database instead",
+	})
+	evictionsCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "query_cache_evictions_total",
+		Help: "Responses dropped from the in-memory query cache to stay within its size limits",
+	})
+	bytesGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "query_cache_bytes",
+		Help: "Bytes of responses held by the in-memory query cache",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(hitsCounter, missesCounter, errorsCounter, evictionsCounter, bytesGauge)
+}
+
+// Config selects the backend and bounds what it keeps; zero values fall
+// back to defaults
+type Config struct {
+	Backend string        // "memory" or "redis"
+	TTL     time.Duration // how long a response is served, 30s by default
+	// MaxEntryBytes is the largest response cached, 1MB by default; larger
+	// ones are always read from the database
+	MaxEntryBytes int64
+	// MaxEntries and MaxBytes bound the in-memory cache, 1000 responses and
+	// 64MB by default; the least recently used responses are dropped first
+	MaxEntries int
+	MaxBytes   int64
+	// RedisAddr is the Redis server of the redis backend, and KeyPrefix
+	// what its keys start with, "logquery:" by default
+	RedisAddr string
+	KeyPrefix string
+}
+
+// Cache keeps encoded query responses by key
+type Cache interface {
+	// Get returns the response cached under key, if any
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set caches value under key for the TTL; values larger than
+	// MaxEntryBytes are not cached
+	Set(ctx context.Context, key string, value []byte) error
+	Close() error
+}
+
+// Pinger is implemented by caches that can check their connection to a
+// cache server
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// New creates a cache for the configured backend
+func New(cfg Config) (Cache, error) {
+	if cfg.TTL <= 0 {
+		cfg.TTL = 30 * time.Second
+	}
+	if cfg.MaxEntryBytes <= 0 {
+		cfg.MaxEntryBytes = 1 << 20
+	}
+	switch cfg.Backend {
+	case "memory":
+		if cfg.MaxEntries <= 0 {
+			cfg.MaxEntries = 1000
+		}
+		if cfg.MaxBytes <= 0 {
+			cfg.MaxBytes = 64 << 20
+		}
+		return newMemoryCache(cfg), nil
+	case "redis":
+		if cfg.RedisAddr == "" {
+			return nil, fmt.Errorf("redis query cache requires an address")
+		}
+		if cfg.KeyPrefix == "" {
+			cfg.KeyPrefix = "logquery:"
+		}
+		return newRedisCache(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown query cache backend %q", cfg.Backend)
+	}
+}
+
+// Lookup is Get recording the outcome in the query cache metrics; a failed
+// read counts as a miss
+func Lookup(ctx context.Context, c Cache, key string) ([]byte, bool, error) {
+	value, ok, err := c.Get(ctx, key)
+	if err != nil {
+		errorsCounter.Inc()
+	}
+	if ok {
+		hitsCounter.Inc()
+	} else {
+		missesCounter.Inc()
+	}
+	return value, ok, err
+}
+
+// Store is Set recording a failure in the query cache metrics
+func Store(ctx context.Context, c Cache, key string, value []byte) error {
+	err := c.Set(ctx, key, value)
+	if err != nil {
+		errorsCounter.Inc()
+	}
+	return err
+}
@@ -0,0 +1,65 @@
+package querycache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis timeouts are short: a slow cache server should cost a query little
+// more than the database round trip it was meant to save
+const (
+	redisDialTimeout = time.Second
+	redisIOTimeout   = 200 * time.Millisecond
+)
+
+// redisCache shares cached responses between the instances of the service.
+// Redis expires keys after the TTL; its own maxmemory policy bounds the size.
+type redisCache struct {
+	client        *redis.Client
+	prefix        string
+	ttl           time.Duration
+	maxEntryBytes int64
+}
+
+func newRedisCache(cfg Config) *redisCache {
+	return &redisCache{
+		client: redis.NewClient(&redis.Options{
+			Addr:         cfg.RedisAddr,
+			DialTimeout:  redisDialTimeout,
+			ReadTimeout:  redisIOTimeout,
+			WriteTimeout: redisIOTimeout,
+		}),
+		prefix:        cfg.KeyPrefix,
+		ttl:           cfg.TTL,
+		maxEntryBytes: cfg.MaxEntryBytes,
+	}
+}
+
+func (c *redisCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := c.client.Get(ctx, c.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (c *redisCache) Set(ctx context.Context, key string, value []byte) error {
+	if int64(len(value)) > c.maxEntryBytes {
+		return nil
+	}
+	return c.client.Set(ctx, c.prefix+key, value, c.ttl).Err()
+}
+
+func (c *redisCache) Ping(ctx context.Context) error {
+	return c.client.Ping(ctx).Err()
+}
+
+func (c *redisCache) Close() error {
+	return c.client.Close()
+}
@@ -0,0 +1,102 @@
+package queue
+
+import (
+	"context"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type kafkaPublisher struct {
+	writer *kafka.Writer
+	addrs  []string
+}
+
+func newKafkaPublisher(cfg Config) *kafkaPublisher {
+	return &kafkaPublisher{addrs: cfg.Addrs, writer: &kafka.Writer{
+		Addr:     kafka.TCP(cfg.Addrs...),
+		Topic:    cfg.Topic,
+		Balancer: &kafka.Hash{},
+		// Publishing is synchronous per request, so batches stay short
+		BatchTimeout:           5 * time.Millisecond,
+		RequiredAcks:           kafka.RequireAll,
+		AllowAutoTopicCreation: true,
+	}}
+}
+
+func (p *kafkaPublisher) Publish(ctx context.Context, msg Message) error {
+	m := kafka.Message{Key: []byte(msg.Key), Value: msg.Value, Time: time.Now()}
+	if received := formatTime(msg.Received); received != "" {
+		m.Headers = []kafka.Header{{Key: receivedField, Value: []byte(received)}}
+	}
+	return p.writer.WriteMessages(ctx, m)
+}
+
+func (p *kafkaPublisher) Ping(ctx context.Context) error {
+	return pingBrokers(ctx, p.addrs)
+}
+
+func (p *kafkaPublisher) Close() error {
+	return p.writer.Close()
+}
+
+type kafkaConsumer struct {
+	reader *kafka.Reader
+	addrs  []string
+}
+
+func newKafkaConsumer(cfg Config) *kafkaConsumer {
+	return &kafkaConsumer{addrs: cfg.Addrs, reader: kafka.NewReader(kafka.ReaderConfig{
+		Brokers: cfg.Addrs,
+		Topic:   cfg.Topic,
+		GroupID: cfg.Group,
+	})}
+}
+
+func (c *kafkaConsumer) Consume(ctx context.Context, handle Handler) error {
+	for {
+		m, err := c.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		msg := Message{Key: string(m.Key), Value: m.Value, Enqueued: m.Time}
+		for _, h := range m.Headers {
+			if h.Key == receivedField {
+				msg.Received = parseTime(string(h.Value))
+			}
+		}
+		handle(ctx, msg)
+
+		if err := c.reader.CommitMessages(ctx, m); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func (c *kafkaConsumer) Ping(ctx context.Context) error {
+	return pingBrokers(ctx, c.addrs)
+}
+
+func (c *kafkaConsumer) Close() error {
+	return c.reader.Close()
+}
+
+// pingBrokers succeeds once one of the brokers accepts a connection; the
+// client finds the others through it
+func pingBrokers(ctx context.Context, addrs []string) error {
+	var err error
+	for _, addr := range addrs {
+		var conn *kafka.Conn
+		if conn, err = kafka.DialContext(ctx, "tcp", addr); err == nil {
+			return conn.Close()
+		}
+	}
+	return err
+}
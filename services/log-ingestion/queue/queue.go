@@ -0,0 +1,131 @@
+// Package queue carries raw log entries from the ingestion tier to the
+// log-processor service over Kafka or Redis Streams, so processing can scale
+// independently of the HTTP servers. Delivery is at least once: a message is
+// committed only after its handler has returned.
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Message is one queued entry; Key groups related entries (the source) so
+// Kafka keeps them on one partition and in order
+type Message struct {
+	Key   string
+	Value []byte
+	// Received is when ingestion received the entry, carried alongside the
+	// value so the consumer can measure the end-to-end latency; zero if
+	// unknown
+	Received time.Time
+	// Enqueued is when the broker took the message, as the consumer reads
+	// it; publishers ignore it
+	Enqueued time.Time
+}
+
+// receivedField names the Kafka header or Redis stream field carrying
+// Message.Received
+const receivedField = "received_at"
+
+// formatTime writes a time as Unix nanoseconds, empty for the zero time
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// parseTime reads a time formatTime wrote; anything else is the zero time,
+// as for messages published before the field existed
+func parseTime(s string) time.Time {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+// Config selects and addresses the queue backend
+type Config struct {
+	Backend string   // "kafka" or "redis"
+	Addrs   []string // Kafka brokers, or the Redis address
+	Topic   string   // Kafka topic or Redis stream
+	Group   string   // consumer group
+	MaxLen  int64    // approximate cap on the Redis stream length; 0 is unbounded
+}
+
+// Publisher sends messages to the queue
+type Publisher interface {
+	Publish(ctx context.Context, msg Message) error
+	Close() error
+}
+
+// DepthReporter is implemented by publishers that can tell how many messages
+// the queue holds. For Redis this is the stream length, which MaxLen caps;
+// Kafka keeps no such count for a producer.
+type DepthReporter interface {
+	Depth(ctx context.Context) (int64, error)
+}
+
+// Pinger is implemented by publishers and consumers that can check their
+// connection to the broker without sending or receiving a message
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Handler processes one message. Failures are the handler's to record; the
+// message is committed once it returns.
+type Handler func(ctx context.Context, msg Message)
+
+// Consumer reads messages as part of a consumer group
+type Consumer interface {
+	// Consume calls handle for each message until ctx is done
+	Consume(ctx context.Context, handle Handler) error
+	Close() error
+}
+
+// NewPublisher creates a publisher for the configured backend
+func NewPublisher(cfg Config) (Publisher, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	switch cfg.Backend {
+	case "kafka":
+		return newKafkaPublisher(cfg), nil
+	default:
+		return newRedisPublisher(cfg), nil
+	}
+}
+
+// NewConsumer creates a group consumer for the configured backend
+func NewConsumer(cfg Config) (Consumer, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Group == "" {
+		return nil, fmt.Errorf("queue consumer requires a group")
+	}
+	switch cfg.Backend {
+	case "kafka":
+		return newKafkaConsumer(cfg), nil
+	default:
+		return newRedisConsumer(cfg)
+	}
+}
+
+func (cfg Config) validate() error {
+	switch cfg.Backend {
+	case "kafka", "redis":
+	default:
+		return fmt.Errorf("unknown queue backend %q", cfg.Backend)
+	}
+	if len(cfg.Addrs) == 0 {
+		return fmt.Errorf("%s queue requires at least one address", cfg.Backend)
+	}
+	if cfg.Topic == "" {
+		return fmt.Errorf("%s queue requires a topic", cfg.Backend)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisReadCount is the number of entries read from the stream at a time
+const redisReadCount = 100
+
+type redisPublisher struct {
+	client *redis.Client
+	stream string
+	maxLen int64
+}
+
+func newRedisPublisher(cfg Config) *redisPublisher {
+	return &redisPublisher{
+		client: redis.NewClient(&redis.Options{Addr: cfg.Addrs[0]}),
+		stream: cfg.Topic,
+		maxLen: cfg.MaxLen,
+	}
+}
+
+func (p *redisPublisher) Publish(ctx context.Context, msg Message) error {
+	return p.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: p.stream,
+		MaxLen: p.maxLen,
+		Approx: true,
+		Values: map[string]interface{}{"key": msg.Key, "value": msg.Value, receivedField: formatTime(msg.Received)},
+	}).Err()
+}
+
+func (p *redisPublisher) Depth(ctx context.Context) (int64, error) {
+	return p.client.XLen(ctx, p.stream).Result()
+}
+
+func (p *redisPublisher) Ping(ctx context.Context) error {
+	return p.client.Ping(ctx).Err()
+}
+
+func (p *redisPublisher) Close() error {
+	return p.client.Close()
+}
+
+type redisConsumer struct {
+	client *redis.Client
+	stream string
+	group  string
+	name   string
+}
+
+func newRedisConsumer(cfg Config) (*redisConsumer, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	return &redisConsumer{
+		client: redis.NewClient(&redis.Options{Addr: cfg.Addrs[0]}),
+		stream: cfg.Topic,
+		group:  cfg.Group,
+		name:   fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+	}, nil
+}
+
+// Consume first works through entries delivered to this consumer but never
+// acknowledged, then reads new ones
+func (c *redisConsumer) Consume(ctx context.Context, handle Handler) error {
+	err := c.client.XGroupCreateMkStream(ctx, c.stream, c.group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+
+	pending := true
+	for {
+		id := ">"
+		if pending {
+			id = "0"
+		}
+		streams, err := c.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    c.group,
+			Consumer: c.name,
+			Streams:  []string{c.stream, id},
+			Count:    redisReadCount,
+			Block:    5 * time.Second,
+		}).Result()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		var read int
+		for _, stream := range streams {
+			read += len(stream.Messages)
+			for _, m := range stream.Messages {
+				key, _ := m.Values["key"].(string)
+				value, _ := m.Values["value"].(string)
+				received, _ := m.Values[receivedField].(string)
+				handle(ctx, Message{
+					Key:      key,
+					Value:    []byte(value),
+					Received: parseTime(received),
+					Enqueued: streamIDTime(m.ID),
+				})
+
+				if err := c.client.XAck(ctx, c.stream, c.group, m.ID).Err(); err != nil {
+					if ctx.Err() != nil {
+						return nil
+					}
+					return err
+				}
+			}
+		}
+		if pending && read == 0 {
+			pending = false
+		}
+	}
+}
+
+func (c *redisConsumer) Ping(ctx context.Context) error {
+	return c.client.Ping(ctx).Err()
+}
+
+func (c *redisConsumer) Close() error {
+	return c.client.Close()
+}
+
+// streamIDTime returns when Redis added a stream entry: the first part of
+// its ID is the Unix time in milliseconds
+func streamIDTime(id string) time.Time {
+	ms, err := strconv.ParseInt(strings.SplitN(id, "-", 2)[0], 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
@@ -0,0 +1,322 @@
+// Package quota enforces per-tenant ingestion quotas: a daily volume of
+// request bytes and a request rate. Usage is counted in memory, per
+// instance, and the daily volume resets at midnight UTC.
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+	"log-processing-system/services/log-ingestion/auth"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/middleware"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+// Limits are a tenant's quotas; zero means unlimited
+type Limits struct {
+	DailyBytes        int64 // request body bytes per UTC day
+	RequestsPerMinute int   // ingestion requests per minute
+}
+
+// Config sets the quotas of every tenant, with overrides by tenant
+type Config struct {
+	Default Limits
+	// Per-tenant overrides of Default, by tenant as Tenant returns it,
+	// e.g. "acme" or "key:billing-shipper"
+	DailyBytes        map[string]int64
+	RequestsPerMinute map[string]int
+	// Fraction of the daily volume at which a warning is logged, once a
+	// day, before requests are rejected; 0.8 by default
+	SoftLimit float64
+}
+
+// Usage is a tenant's consumption of its quotas today
+type Usage struct {
+	Tenant            string    `json:"tenant"`
+	Day               string    `json:"day"`
+	Bytes             int64     `json:"bytes"`
+	Requests          int64     `json:"requests"`
+	Rejected          int64     `json:"rejected"`
+	DailyBytes        int64     `json:"daily_bytes_limit,omitempty"`
+	RequestsPerMinute int       `json:"requests_per_minute_limit,omitempty"`
+	ResetsAt          time.Time `json:"resets_at"`
+}
+
+type tenantState struct {
+	day      string
+	bytes    int64
+	requests int64
+	rejected int64
+	warned   bool
+
+	tokens  float64
+	updated time.Time
+}
+
+// Enforcer counts each tenant's ingestion against its quotas
+type Enforcer struct {
+	cfg    Config
+	logger *logger.Logger
+	now    func() time.Time
+
+	mu      sync.Mutex
+	tenants map[string]*tenantState
+}
+
+// NewEnforcer creates an enforcer; zero config values fall back to defaults
+func NewEnforcer(cfg Config, log *logger.Logger) *Enforcer {
+	if cfg.SoftLimit <= 0 || cfg.SoftLimit > 1 {
+		cfg.SoftLimit = 0.8
+	}
+	return &Enforcer{
+		cfg:     cfg,
+		logger:  log,
+		now:     time.Now,
+		tenants: make(map[string]*tenantState),
+	}
+}
+
+// Tenant returns the tenant a request's usage is counted against: the
+// tenant of its bearer token, else its API key ("key:<name>"), token
+// subject ("sub:<subject>") or client certificate ("cn:<common name>").
+// Requests made with the admin key or without authentication have none
+// and are not limited.
+func Tenant(ctx context.Context) string {
+	if tenant := logger.GetTenantID(ctx); tenant != "" {
+		return tenant
+	}
+	switch actorType, actor := auth.Identity(ctx); actorType {
+	case models.ActorAPIKey:
+		return "key:" + actor
+	case models.ActorToken:
+		return "sub:" + actor
+	case models.ActorCertificate:
+		return "cn:" + actor
+	}
+	return ""
+}
+
+// limits returns the quotas of a tenant
+func (e *Enforcer) limits(tenant string) Limits {
+	limits := e.cfg.Default
+	if bytes, ok := e.cfg.DailyBytes[tenant]; ok {
+		limits.DailyBytes = bytes
+	}
+	if rate, ok := e.cfg.RequestsPerMinute[tenant]; ok {
+		limits.RequestsPerMinute = rate
+	}
+	return limits
+}
+
+// state returns a tenant's state for today, starting a new day's count
+// when the day has changed. The caller holds e.mu.
+func (e *Enforcer) state(tenant string, limits Limits, now time.Time) *tenantState {
+	day := now.UTC().Format("2006-01-02")
+	s, ok := e.tenants[tenant]
+	if !ok {
+		s = &tenantState{tokens: float64(limits.RequestsPerMinute), updated: now}
+		e.tenants[tenant] = s
+	}
+	if s.day != day {
+		s.day, s.bytes, s.requests, s.rejected, s.warned = day, 0, 0, 0, false
+	}
+	return s
+}
+
+// rejection is why a request was refused
+type rejection struct {
+	quota      string // "daily_volume" or "rate"
+	limit      int64
+	used       int64
+	retryAfter time.Duration
+}
+
+// reserve counts a request of size bytes (-1 when unknown) against the
+// tenant's quotas, reserving its size in the daily volume
+func (e *Enforcer) reserve(tenant string, size int64) *rejection {
+	limits := e.limits(tenant)
+	now := e.now()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s := e.state(tenant, limits, now)
+
+	if limits.DailyBytes > 0 {
+		if s.bytes >= limits.DailyBytes || size > 0 && s.bytes+size > limits.DailyBytes {
+			s.rejected++
+			return &rejection{quota: "daily_volume", limit: limits.DailyBytes, used: s.bytes, retryAfter: untilMidnight(now)}
+		}
+	}
+
+	if limits.RequestsPerMinute > 0 {
+		perSecond := float64(limits.RequestsPerMinute) / 60
+		s.tokens = math.Min(float64(limits.RequestsPerMinute), s.tokens+now.Sub(s.updated).Seconds()*perSecond)
+		s.updated = now
+		if s.tokens < 1 {
+			s.rejected++
+			retryAfter := time.Duration((1 - s.tokens) / perSecond * float64(time.Second))
+			return &rejection{quota: "rate", limit: int64(limits.RequestsPerMinute), used: s.requests, retryAfter: retryAfter}
+		}
+		s.tokens--
+	}
+
+	s.requests++
+	if size > 0 {
+		s.bytes += size
+	}
+	return nil
+}
+
+// settle replaces the reserved size of a request with the bytes it
+// actually sent, or releases it when the request was not accepted, and
+// warns once a day when the tenant passes the soft limit
+func (e *Enforcer) settle(ctx context.Context, tenant string, reserved, actual int64, accepted bool) {
+	limits := e.limits(tenant)
+	if reserved < 0 {
+		reserved = 0
+	}
+	if !accepted {
+		actual = 0
+	}
+
+	e.mu.Lock()
+	s := e.state(tenant, limits, e.now())
+	s.bytes += actual - reserved
+	if s.bytes < 0 {
+		// The day changed while the request was served
+		s.bytes = 0
+	}
+	warn := limits.DailyBytes > 0 && !s.warned && float64(s.bytes) >= e.cfg.SoftLimit*float64(limits.DailyBytes)
+	if warn {
+		s.warned = true
+	}
+	used := s.bytes
+	e.mu.Unlock()
+
+	if warn {
+		e.logger.WithFields(map[string]interface{}{
+			"tenant":      tenant,
+			"bytes":       used,
+			"daily_bytes": limits.DailyBytes,
+			"soft_limit":  e.cfg.SoftLimit,
+			"request_id":  logger.GetRequestID(ctx),
+		}).WarnContext(ctx, "Tenant approaching daily ingestion quota")
+	}
+}
+
+// Usage returns a tenant's usage today
+func (e *Enforcer) Usage(tenant string) Usage {
+	limits := e.limits(tenant)
+	now := e.now()
+
+	e.mu.Lock()
+	s := e.state(tenant, limits, now)
+	usage := Usage{Tenant: tenant, Day: s.day, Bytes: s.bytes, Requests: s.requests, Rejected: s.rejected}
+	e.mu.Unlock()
+
+	usage.DailyBytes = limits.DailyBytes
+	usage.RequestsPerMinute = limits.RequestsPerMinute
+	usage.ResetsAt = now.UTC().Add(untilMidnight(now))
+	return usage
+}
+
+// All returns the usage today of every tenant seen, by tenant
+func (e *Enforcer) All() []Usage {
+	e.mu.Lock()
+	tenants := make([]string, 0, len(e.tenants))
+	for tenant := range e.tenants {
+		tenants = append(tenants, tenant)
+	}
+	e.mu.Unlock()
+
+	sort.Strings(tenants)
+	usages := make([]Usage, 0, len(tenants))
+	for _, tenant := range tenants {
+		usages = append(usages, e.Usage(tenant))
+	}
+	return usages
+}
+
+// Middleware counts ingestion requests against their tenant's quotas and
+// rejects those over a quota with 429 and a JSON body. It goes after the
+// authentication middleware, which identifies the tenant.
+func (e *Enforcer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant := Tenant(r.Context())
+		if tenant == "" || r.Method == http.MethodGet || auth.RequiredScope(r) != models.ScopeIngest {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if rejected := e.reserve(tenant, r.ContentLength); rejected != nil {
+			e.reject(w, r, tenant, rejected)
+			return
+		}
+
+		body := &countingReader{ReadCloser: r.Body}
+		r.Body = body
+		sw := middleware.NewStatusWriter(w)
+		next.ServeHTTP(sw, r)
+		e.settle(r.Context(), tenant, r.ContentLength, body.n, sw.Status() < 300)
+	})
+}
+
+// reject answers a request over a quota
+func (e *Enforcer) reject(w http.ResponseWriter, r *http.Request, tenant string, rejected *rejection) {
+	requestID := logger.GetRequestID(r.Context())
+	retryAfter := int(math.Ceil(rejected.retryAfter.Seconds()))
+
+	e.logger.WithFields(map[string]interface{}{
+		"tenant":     tenant,
+		"quota":      rejected.quota,
+		"limit":      rejected.limit,
+		"used":       rejected.used,
+		"request_id": requestID,
+	}).WarnContext(r.Context(), "Tenant ingestion quota exceeded")
+
+	message := fmt.Sprintf("Tenant %q exceeded its daily ingestion volume of %d bytes", tenant, rejected.limit)
+	if rejected.quota == "rate" {
+		message = fmt.Sprintf("Tenant %q exceeded its rate of %d requests per minute", tenant, rejected.limit)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":              "quota_exceeded",
+		"message":             message,
+		"tenant":              tenant,
+		"quota":               rejected.quota,
+		"limit":               rejected.limit,
+		"used":                rejected.used,
+		"retry_after_seconds": retryAfter,
+		"request_id":          requestID,
+	})
+}
+
+// untilMidnight returns the time left until the next UTC day
+func untilMidnight(now time.Time) time.Duration {
+	now = now.UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	return midnight.Sub(now)
+}
+
+// countingReader counts the bytes read from a request body
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,218 @@
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/auth"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+// entrySink records the entries written through it
+type entrySink struct {
+	mu      sync.Mutex
+	entries []logger.LogEntry
+}
+
+func (s *entrySink) Write(entry logger.LogEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func (s *entrySink) Sync() error { return nil }
+
+func (s *entrySink) count(message string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, entry := range s.entries {
+		if entry.Message == message {
+			n++
+		}
+	}
+	return n
+}
+
+func newTestEnforcer(cfg Config, sink *entrySink, now *time.Time) *Enforcer {
+	e := NewEnforcer(cfg, logger.New(logger.Config{Level: "INFO", Service: "test-service", Component: "quota", Sink: sink}))
+	e.now = func() time.Time { return *now }
+	return e
+}
+
+// newTestHandler serves ingestion requests made by tenant through e
+func newTestHandler(e *Enforcer, tenant string) http.Handler {
+	ingest := e.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if r.URL.Query().Get("fail") != "" {
+			http.Error(w, "Invalid log entry", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ingest.ServeHTTP(w, r.WithContext(logger.WithTenantID(r.Context(), tenant)))
+	})
+}
+
+func post(handler http.Handler, path, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", path, strings.NewReader(body)))
+	return rec
+}
+
+func TestMiddleware_DailyVolume(t *testing.T) {
+	now := time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC)
+	sink := &entrySink{}
+	e := newTestEnforcer(Config{Default: Limits{DailyBytes: 100}}, sink, &now)
+	handler := newTestHandler(e, "acme")
+
+	body := strings.Repeat("x", 40)
+	for i := 0; i < 2; i++ {
+		if rec := post(handler, "/ingest", body); rec.Code != http.StatusCreated {
+			t.Fatalf("Expected request %d to be accepted, got %d", i, rec.Code)
+		}
+	}
+	if sink.count("Tenant approaching daily ingestion quota") != 1 {
+		t.Errorf("Expected a soft limit warning at 80 of 100 bytes")
+	}
+
+	rec := post(handler, "/ingest", body)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429, got %d", rec.Code)
+	}
+	var resp map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Expected a JSON body, got %s", rec.Body.String())
+	}
+	if resp["status"] != "quota_exceeded" || resp["quota"] != "daily_volume" || resp["tenant"] != "acme" || resp["used"] != float64(80) {
+		t.Errorf("Unexpected body %v", resp)
+	}
+	if retryAfter := rec.Header().Get("Retry-After"); retryAfter != "21600" {
+		t.Errorf("Expected to retry at midnight, got Retry-After %s", retryAfter)
+	}
+
+	// A request that fits the remaining volume is still accepted
+	if rec := post(handler, "/ingest", strings.Repeat("x", 20)); rec.Code != http.StatusCreated {
+		t.Errorf("Expected a request within the remaining volume to be accepted, got %d", rec.Code)
+	}
+	if sink.count("Tenant approaching daily ingestion quota") != 1 {
+		t.Errorf("Expected the soft limit warning to be logged once a day")
+	}
+
+	// The volume resets with the day
+	now = now.Add(7 * time.Hour)
+	if rec := post(handler, "/ingest", body); rec.Code != http.StatusCreated {
+		t.Errorf("Expected the quota to reset the next day, got %d", rec.Code)
+	}
+	if usage := e.Usage("acme"); usage.Day != "2025-03-02" || usage.Bytes != 40 || usage.Requests != 1 || usage.Rejected != 0 {
+		t.Errorf("Unexpected usage %+v", usage)
+	}
+}
+
+func TestMiddleware_FailedRequestsReleaseVolume(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	e := newTestEnforcer(Config{Default: Limits{DailyBytes: 100}}, &entrySink{}, &now)
+	handler := newTestHandler(e, "acme")
+
+	post(handler, "/ingest?fail=1", strings.Repeat("x", 60))
+	if usage := e.Usage("acme"); usage.Bytes != 0 || usage.Requests != 1 {
+		t.Errorf("Expected a rejected entry not to count against the volume, got %+v", usage)
+	}
+}
+
+func TestMiddleware_Rate(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	e := newTestEnforcer(Config{
+		Default:           Limits{RequestsPerMinute: 60},
+		RequestsPerMinute: map[string]int{"key:batch": 2},
+	}, &entrySink{}, &now)
+	handler := newTestHandler(e, "key:batch")
+
+	for i := 0; i < 2; i++ {
+		if rec := post(handler, "/ingest", "{}"); rec.Code != http.StatusCreated {
+			t.Fatalf("Expected request %d to be accepted, got %d", i, rec.Code)
+		}
+	}
+	rec := post(handler, "/ingest", "{}")
+	if rec.Code != http.StatusTooManyRequests || !strings.Contains(rec.Body.String(), `"quota":"rate"`) {
+		t.Fatalf("Expected the rate quota to reject, got %d %s", rec.Code, rec.Body.String())
+	}
+	if retryAfter := rec.Header().Get("Retry-After"); retryAfter != "30" {
+		t.Errorf("Expected to retry when a request is available, got Retry-After %s", retryAfter)
+	}
+
+	now = now.Add(30 * time.Second)
+	if rec := post(handler, "/ingest", "{}"); rec.Code != http.StatusCreated {
+		t.Errorf("Expected the rate to refill, got %d", rec.Code)
+	}
+	if usage := e.Usage("key:batch"); usage.Requests != 3 || usage.Rejected != 1 || usage.RequestsPerMinute != 2 {
+		t.Errorf("Unexpected usage %+v", usage)
+	}
+}
+
+func TestMiddleware_SkipsReadsAndUntenanted(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	e := newTestEnforcer(Config{Default: Limits{DailyBytes: 1}}, &entrySink{}, &now)
+
+	rec := httptest.NewRecorder()
+	newTestHandler(e, "acme").ServeHTTP(rec, httptest.NewRequest("GET", "/quota", nil))
+	if rec.Code != http.StatusCreated {
+		t.Errorf("Expected reads not to be limited, got %d", rec.Code)
+	}
+	if rec := post(newTestHandler(e, ""), "/ingest", "{}"); rec.Code != http.StatusCreated {
+		t.Errorf("Expected requests without a tenant not to be limited, got %d", rec.Code)
+	}
+	if usages := e.All(); len(usages) != 0 {
+		t.Errorf("Expected no usage, got %+v", usages)
+	}
+}
+
+func TestTenant(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{"anonymous", ctx, ""},
+		{"admin key", auth.WithKey(ctx, models.APIKey{Name: "admin"}), ""},
+		{"api key", auth.WithKey(ctx, models.APIKey{ID: 3, Name: "shipper"}), "key:shipper"},
+		{"tenant", logger.WithTenantID(auth.WithKey(ctx, models.APIKey{ID: 3, Name: "shipper"}), "acme"), "acme"},
+	}
+	for _, tt := range tests {
+		if got := Tenant(tt.ctx); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestEnforcer_All(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	e := newTestEnforcer(Config{
+		Default:    Limits{DailyBytes: 1000},
+		DailyBytes: map[string]int64{"globex": 0},
+	}, &entrySink{}, &now)
+
+	post(newTestHandler(e, "initech"), "/ingest", "{}")
+	post(newTestHandler(e, "globex"), "/ingest", "{}")
+
+	usages := e.All()
+	if len(usages) != 2 || usages[0].Tenant != "globex" || usages[1].Tenant != "initech" {
+		t.Fatalf("Expected usage by tenant, got %+v", usages)
+	}
+	if usages[0].DailyBytes != 0 || usages[1].DailyBytes != 1000 || usages[1].Bytes != 2 {
+		t.Errorf("Unexpected usage %+v", usages)
+	}
+	if !usages[0].ResetsAt.Equal(time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the quota to reset at midnight, got %v", usages[0].ResetsAt)
+	}
+}
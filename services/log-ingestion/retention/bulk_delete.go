@@ -0,0 +1,223 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+	"log-processing-system/services/log-ingestion/database"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/models"
+
+	"github.com/google/uuid"
+)
+
+// DefaultDeleteBatchSize is the number of rows removed per transaction when none is given
+const DefaultDeleteBatchSize = 5000
+
+// DeleteJobTTL is how long a finished job can still be looked up
+const DeleteJobTTL = 24 * time.Hour
+
+// Delete job states
+const (
+	DeleteJobRunning   = "running"
+	DeleteJobCompleted = "completed"
+	DeleteJobFailed    = "failed"
+)
+
+// ErrEmptyDeleteFilter is returned when a bulk delete would match every log entry
+var ErrEmptyDeleteFilter = errors.New("bulk delete requires at least one filter criterion")
+
+// ErrDeleteStopped is returned when a bulk delete is requested after Stop,
+// and ends the deletes Stop interrupted
+var ErrDeleteStopped = errors.New("bulk delete stopped by shutdown")
+
+// DeleteBatchFunc removes up to batchSize entries matching filter, giving
+// up when ctx is done
+type DeleteBatchFunc func(ctx context.Context, filter models.LogFilter, batchSize int) (int64, error)
+
+// DeleteJob reports the progress of a bulk delete
+type DeleteJob struct {
+	ID         string           `json:"id"`
+	Filter     models.LogFilter `json:"filter"`
+	BatchSize  int              `json:"batch_size"`
+	Status     string           `json:"status"`
+	Deleted    int64            `json:"deleted"`
+	Batches    int              `json:"batches"`
+	Error      string           `json:"error,omitempty"`
+	StartedAt  time.Time        `json:"started_at"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+}
+
+// BulkDeleter runs filtered deletes in the background in batched transactions
+// so a large cleanup never holds one long lock on the logs table
+type BulkDeleter struct {
+	deleteBatch DeleteBatchFunc
+	logger      *logger.Logger
+
+	mu   sync.RWMutex
+	jobs map[string]*DeleteJob
+	// Finished jobs are forgotten this long after they finish
+	jobTTL time.Duration
+
+	// Cancelled by Stop; running jobs end within their current batch
+	ctx     context.Context
+	cancel  context.CancelFunc
+	running sync.WaitGroup
+}
+
+// NewBulkDeleter creates a bulk deleter backed by database.DeleteLogsBatch
+func NewBulkDeleter(log *logger.Logger) *BulkDeleter {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &BulkDeleter{
+		deleteBatch: database.DeleteLogsBatch,
+		logger:      log,
+		jobs:        make(map[string]*DeleteJob),
+		jobTTL:      DeleteJobTTL,
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+}
+
+// Start validates the filter and launches a background delete job
+func (d *BulkDeleter) Start(filter models.LogFilter, batchSize int) (DeleteJob, error) {
+	if filter.IsEmpty() {
+		return DeleteJob{}, ErrEmptyDeleteFilter
+	}
+	if err := filter.Validate(); err != nil {
+		return DeleteJob{}, err
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultDeleteBatchSize
+	}
+
+	job := &DeleteJob{
+		ID:        uuid.New().String(),
+		Filter:    filter,
+		BatchSize: batchSize,
+		Status:    DeleteJobRunning,
+		StartedAt: time.Now().UTC(),
+	}
+
+	d.mu.Lock()
+	if d.ctx.Err() != nil {
+		d.mu.Unlock()
+		return DeleteJob{}, ErrDeleteStopped
+	}
+	d.evict(time.Now())
+	d.jobs[job.ID] = job
+	snapshot := *job
+	// Under the lock, so Stop cannot miss the job
+	d.running.Add(1)
+	d.mu.Unlock()
+
+	d.logger.WithFields(map[string]interface{}{
+		"job_id":     job.ID,
+		"filter":     filter,
+		"batch_size": batchSize,
+	}).Info("Bulk delete started")
+
+	go d.run(job)
+
+	return snapshot, nil
+}
+
+// Get returns a snapshot of the job with the given id
+func (d *BulkDeleter) Get(id string) (DeleteJob, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	job, ok := d.jobs[id]
+	if !ok || d.expired(job, time.Now()) {
+		return DeleteJob{}, false
+	}
+	return *job, true
+}
+
+// Stop interrupts running deletes, cancelling the batch they are deleting,
+// and waits for them until ctx is done. A stopped delete fails with
+// ErrDeleteStopped; the rows of its earlier batches stay deleted.
+func (d *BulkDeleter) Stop(ctx context.Context) error {
+	d.mu.Lock()
+	d.cancel()
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// evict forgets the jobs that expired; callers must hold d.mu
+func (d *BulkDeleter) evict(now time.Time) {
+	for id, job := range d.jobs {
+		if d.expired(job, now) {
+			delete(d.jobs, id)
+		}
+	}
+}
+
+// expired reports whether job finished more than jobTTL before now
+func (d *BulkDeleter) expired(job *DeleteJob, now time.Time) bool {
+	return job.FinishedAt != nil && now.Sub(*job.FinishedAt) > d.jobTTL
+}
+
+func (d *BulkDeleter) run(job *DeleteJob) {
+	defer d.running.Done()
+
+	for {
+		deleted, err := d.deleteBatch(d.ctx, job.Filter, job.BatchSize)
+
+		d.mu.Lock()
+		if err != nil {
+			if d.ctx.Err() != nil {
+				err = ErrDeleteStopped
+			}
+			d.finish(job, DeleteJobFailed, err)
+			d.mu.Unlock()
+			return
+		}
+		job.Deleted += deleted
+		job.Batches++
+		done := deleted < int64(job.BatchSize)
+		if done {
+			d.finish(job, DeleteJobCompleted, nil)
+		} else if d.ctx.Err() != nil {
+			d.finish(job, DeleteJobFailed, ErrDeleteStopped)
+			done = true
+		}
+		d.mu.Unlock()
+
+		if done {
+			return
+		}
+	}
+}
+
+// finish marks the job as done; callers must hold d.mu
+func (d *BulkDeleter) finish(job *DeleteJob, status string, err error) {
+	now := time.Now().UTC()
+	job.Status = status
+	job.FinishedAt = &now
+
+	fields := map[string]interface{}{
+		"job_id":      job.ID,
+		"deleted":     job.Deleted,
+		"batches":     job.Batches,
+		"duration_ms": now.Sub(job.StartedAt).Milliseconds(),
+	}
+
+	if err != nil {
+		job.Error = err.Error()
+		d.logger.WithFields(fields).WithError(err).Error("Bulk delete failed")
+		return
+	}
+	d.logger.WithFields(fields).Info("Bulk delete completed")
+}
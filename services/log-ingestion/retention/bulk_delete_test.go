@@ -0,0 +1,148 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func newTestDeleter(deleteBatch DeleteBatchFunc) *BulkDeleter {
+	deleter := NewBulkDeleter(logger.New(logger.Config{Service: "test-service", Component: "retention"}))
+	deleter.deleteBatch = deleteBatch
+	return deleter
+}
+
+func waitForJob(t *testing.T, deleter *BulkDeleter, id string) DeleteJob {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		job, ok := deleter.Get(id)
+		if !ok {
+			t.Fatalf("Expected job %s to exist", id)
+		}
+		if job.Status != DeleteJobRunning {
+			return job
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Job %s did not finish in time", id)
+	return DeleteJob{}
+}
+
+func TestBulkDeleter_DeletesInBatches(t *testing.T) {
+	remaining := int64(25)
+	deleter := newTestDeleter(func(ctx context.Context, filter models.LogFilter, batchSize int) (int64, error) {
+		n := int64(batchSize)
+		if remaining < n {
+			n = remaining
+		}
+		remaining -= n
+		return n, nil
+	})
+
+	job, err := deleter.Start(models.LogFilter{Source: "flood"}, 10)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	job = waitForJob(t, deleter, job.ID)
+	if job.Status != DeleteJobCompleted {
+		t.Errorf("Expected status completed, got %s", job.Status)
+	}
+	if job.Deleted != 25 {
+		t.Errorf("Expected 25 deleted, got %d", job.Deleted)
+	}
+	if job.Batches != 3 {
+		t.Errorf("Expected 3 batches, got %d", job.Batches)
+	}
+	if job.FinishedAt == nil {
+		t.Errorf("Expected finished_at to be set")
+	}
+}
+
+func TestBulkDeleter_Failure(t *testing.T) {
+	deleter := newTestDeleter(func(ctx context.Context, filter models.LogFilter, batchSize int) (int64, error) {
+		return 0, errors.New("database error")
+	})
+
+	job, err := deleter.Start(models.LogFilter{Level: "debug"}, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if job.BatchSize != DefaultDeleteBatchSize {
+		t.Errorf("Expected default batch size, got %d", job.BatchSize)
+	}
+
+	job = waitForJob(t, deleter, job.ID)
+	if job.Status != DeleteJobFailed || job.Error != "database error" {
+		t.Errorf("Expected failed job with error, got status=%s error=%s", job.Status, job.Error)
+	}
+}
+
+func TestBulkDeleter_RejectsEmptyFilter(t *testing.T) {
+	deleter := newTestDeleter(func(ctx context.Context, filter models.LogFilter, batchSize int) (int64, error) {
+		t.Fatalf("Delete should not run for an empty filter")
+		return 0, nil
+	})
+
+	if _, err := deleter.Start(models.LogFilter{}, 10); err != ErrEmptyDeleteFilter {
+		t.Errorf("Expected ErrEmptyDeleteFilter, got %v", err)
+	}
+}
+
+func TestBulkDeleter_EvictsFinishedJobs(t *testing.T) {
+	deleter := newTestDeleter(func(ctx context.Context, filter models.LogFilter, batchSize int) (int64, error) {
+		return 0, nil
+	})
+	deleter.jobTTL = 20 * time.Millisecond
+
+	first, err := deleter.Start(models.LogFilter{Source: "flood"}, 10)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	waitForJob(t, deleter, first.ID)
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := deleter.Get(first.ID); ok {
+		t.Error("Expected a job finished longer than the TTL ago to be gone")
+	}
+
+	// Starting another job drops the expired one for good
+	if _, err := deleter.Start(models.LogFilter{Source: "flood"}, 10); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	deleter.mu.RLock()
+	_, kept := deleter.jobs[first.ID]
+	deleter.mu.RUnlock()
+	if kept {
+		t.Error("Expected the expired job to be evicted")
+	}
+}
+
+func TestBulkDeleter_Stop(t *testing.T) {
+	deleter := newTestDeleter(func(ctx context.Context, filter models.LogFilter, batchSize int) (int64, error) {
+		// A batch that only ends when cancelled
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+
+	job, err := deleter.Start(models.LogFilter{Source: "flood"}, 10)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := deleter.Stop(ctx); err != nil {
+		t.Fatalf("Expected the running job to stop, got %v", err)
+	}
+	job, _ = deleter.Get(job.ID)
+	if job.Status != DeleteJobFailed || job.Error != ErrDeleteStopped.Error() {
+		t.Errorf("Expected the job stopped by shutdown, got status=%s error=%s", job.Status, job.Error)
+	}
+
+	if _, err := deleter.Start(models.LogFilter{Source: "flood"}, 10); err != ErrDeleteStopped {
+		t.Errorf("Expected ErrDeleteStopped after Stop, got %v", err)
+	}
+}
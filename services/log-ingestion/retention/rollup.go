@@ -0,0 +1,111 @@
+package retention
+
+import (
+	"context"
+	"sync"
+	"time"
+	"log-processing-system/services/log-ingestion/database"
+	"log-processing-system/services/log-ingestion/logger"
+)
+
+// RollupFunc downsamples raw logs older than cutoff for the given levels
+type RollupFunc func(cutoff time.Time, levels []string) (database.RollupResult, error)
+
+// NoiseRollupFunc downsamples raw logs tagged as noise older than cutoff
+type NoiseRollupFunc func(cutoff time.Time) (database.RollupResult, error)
+
+// RollupJob periodically replaces aged low-severity logs with hourly
+// summaries. Entries tagged as noise are rolled up after noiseAfter instead,
+// whatever their level; zero leaves them to the level rules.
+type RollupJob struct {
+	after       time.Duration
+	noiseAfter  time.Duration
+	interval    time.Duration
+	levels      []string
+	rollup      RollupFunc
+	rollupNoise NoiseRollupFunc
+	logger      *logger.Logger
+
+	mu      sync.Mutex
+	lastRun time.Time
+}
+
+// NewRollupJob creates a rollup job backed by database.RollupLogs and
+// database.RollupNoiseLogs
+func NewRollupJob(after, noiseAfter, interval time.Duration, levels []string, log *logger.Logger) *RollupJob {
+	return &RollupJob{
+		after:       after,
+		noiseAfter:  noiseAfter,
+		interval:    interval,
+		levels:      levels,
+		rollup:      database.RollupLogs,
+		rollupNoise: database.RollupNoiseLogs,
+		logger:      log,
+	}
+}
+
+// Start runs the job every interval until ctx is cancelled
+func (j *RollupJob) Start(ctx context.Context) {
+	j.logger.WithFields(map[string]interface{}{
+		"after":       j.after.String(),
+		"noise_after": j.noiseAfter.String(),
+		"interval":    j.interval.String(),
+		"levels":      j.levels,
+	}).Info("Log rollup job started")
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			j.logger.Info("Log rollup job stopped")
+			return
+		case <-ticker.C:
+			j.RunOnce()
+		}
+	}
+}
+
+// RunOnce performs a single rollup pass. Concurrent calls are serialized.
+func (j *RollupJob) RunOnce() (database.RollupResult, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	cutoff := time.Now().Add(-j.after).Truncate(time.Hour)
+
+	result, err := j.rollup(cutoff, j.levels)
+	if err != nil {
+		j.logger.WithError(err).WithField("cutoff", cutoff).Error("Log rollup run failed")
+		return result, err
+	}
+
+	if j.noiseAfter > 0 {
+		noiseCutoff := time.Now().Add(-j.noiseAfter).Truncate(time.Hour)
+		noise, err := j.rollupNoise(noiseCutoff)
+		if err != nil {
+			j.logger.WithError(err).WithField("cutoff", noiseCutoff).Error("Noise log rollup run failed")
+			return result, err
+		}
+		result.RowsRolledUp += noise.RowsRolledUp
+		result.BucketsWritten += noise.BucketsWritten
+		result.Duration += noise.Duration
+	}
+	j.lastRun = time.Now()
+
+	j.logger.WithFields(map[string]interface{}{
+		"cutoff":          cutoff,
+		"rows_rolled_up":  result.RowsRolledUp,
+		"buckets_written": result.BucketsWritten,
+		"duration_ms":     result.Duration.Milliseconds(),
+	}).Info("Log rollup run completed")
+
+	return result, nil
+}
+
+// LastRun returns the time of the last successful run
+func (j *RollupJob) LastRun() time.Time {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.lastRun
+}
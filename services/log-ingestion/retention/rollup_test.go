@@ -0,0 +1,84 @@
+package retention
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/database"
+	"log-processing-system/services/log-ingestion/logger"
+)
+
+func newTestJob(rollup RollupFunc) *RollupJob {
+	job := NewRollupJob(24*time.Hour, 0, time.Hour, []string{"debug", "info"}, logger.New(logger.Config{Service: "test-service", Component: "retention"}))
+	job.rollup = rollup
+	return job
+}
+
+func TestRollupJob_RunOnce(t *testing.T) {
+	var gotCutoff time.Time
+	var gotLevels []string
+
+	job := newTestJob(func(cutoff time.Time, levels []string) (database.RollupResult, error) {
+		gotCutoff = cutoff
+		gotLevels = levels
+		return database.RollupResult{RowsRolledUp: 10, BucketsWritten: 2}, nil
+	})
+
+	result, err := job.RunOnce()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.RowsRolledUp != 10 {
+		t.Errorf("Expected 10 rows rolled up, got %d", result.RowsRolledUp)
+	}
+
+	expected := time.Now().Add(-24 * time.Hour).Truncate(time.Hour)
+	if !gotCutoff.Equal(expected) {
+		t.Errorf("Expected cutoff %v, got %v", expected, gotCutoff)
+	}
+	if len(gotLevels) != 2 || gotLevels[0] != "debug" {
+		t.Errorf("Expected levels [debug info], got %v", gotLevels)
+	}
+	if job.LastRun().IsZero() {
+		t.Errorf("Expected last run to be recorded")
+	}
+}
+
+func TestRollupJob_RunOnceError(t *testing.T) {
+	job := newTestJob(func(cutoff time.Time, levels []string) (database.RollupResult, error) {
+		return database.RollupResult{}, errors.New("database error")
+	})
+
+	if _, err := job.RunOnce(); err == nil {
+		t.Errorf("Expected error from failed rollup")
+	}
+	if !job.LastRun().IsZero() {
+		t.Errorf("Expected last run to stay unset after failure")
+	}
+}
+
+func TestRollupJob_RunOnceNoise(t *testing.T) {
+	var gotCutoff time.Time
+
+	job := newTestJob(func(cutoff time.Time, levels []string) (database.RollupResult, error) {
+		return database.RollupResult{RowsRolledUp: 10, BucketsWritten: 2}, nil
+	})
+	job.noiseAfter = time.Hour
+	job.rollupNoise = func(cutoff time.Time) (database.RollupResult, error) {
+		gotCutoff = cutoff
+		return database.RollupResult{RowsRolledUp: 5, BucketsWritten: 1}, nil
+	}
+
+	result, err := job.RunOnce()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.RowsRolledUp != 15 || result.BucketsWritten != 3 {
+		t.Errorf("Expected noise counts to be added, got %+v", result)
+	}
+
+	expected := time.Now().Add(-time.Hour).Truncate(time.Hour)
+	if !gotCutoff.Equal(expected) {
+		t.Errorf("Expected noise cutoff %v, got %v", expected, gotCutoff)
+	}
+}
@@ -0,0 +1,418 @@
+package script
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// function is a built-in; maxArgs of -1 means variadic. regexArg is the
+// index of an argument that must be a string literal regular expression,
+// compiled once with the script, or -1.
+type function struct {
+	minArgs, maxArgs int
+	regexArg         int
+	call             func(s *state, re *regexp.Regexp, args []interface{}) (interface{}, error)
+}
+
+func (f function) arity() string {
+	switch {
+	case f.maxArgs < 0:
+		return fmt.Sprintf("at least %d arguments", f.minArgs)
+	case f.minArgs == f.maxArgs && f.minArgs == 1:
+		return "1 argument"
+	case f.minArgs == f.maxArgs:
+		return fmt.Sprintf("%d arguments", f.minArgs)
+	default:
+		return fmt.Sprintf("%d to %d arguments", f.minArgs, f.maxArgs)
+	}
+}
+
+// pure wraps a function that needs neither the interpreter state nor a regex
+func pure(minArgs, maxArgs int, call func(args []interface{}) (interface{}, error)) function {
+	return function{minArgs, maxArgs, -1, func(s *state, re *regexp.Regexp, args []interface{}) (interface{}, error) {
+		return call(args)
+	}}
+}
+
+// building wraps a pure function whose result is a new value no larger
+// than a few times its arguments, charging the result against the memory
+// budget once built. Functions whose result can grow past that charge it
+// before building it.
+func building(minArgs, maxArgs int, call func(args []interface{}) (interface{}, error)) function {
+	return function{minArgs, maxArgs, -1, func(s *state, re *regexp.Regexp, args []interface{}) (interface{}, error) {
+		value, err := call(args)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.alloc(sizeOf(value)); err != nil {
+			return nil, err
+		}
+		return value, nil
+	}}
+}
+
+// maxRange caps range() even when steps are unlimited
+const maxRange = 1000000
+
+var functions = map[string]function{
+	"upper": building(1, 1, func(args []interface{}) (interface{}, error) {
+		return strings.ToUpper(toString(args[0])), nil
+	}),
+	"lower": building(1, 1, func(args []interface{}) (interface{}, error) {
+		return strings.ToLower(toString(args[0])), nil
+	}),
+	"trim": pure(1, 1, func(args []interface{}) (interface{}, error) {
+		return strings.TrimSpace(toString(args[0])), nil
+	}),
+	"len": pure(1, 1, func(args []interface{}) (interface{}, error) {
+		switch v := args[0].(type) {
+		case nil:
+			return int64(0), nil
+		case []interface{}:
+			return int64(len(v)), nil
+		case map[string]interface{}:
+			return int64(len(v)), nil
+		}
+		return int64(len([]rune(toString(args[0])))), nil
+	}),
+	"replace": {3, 3, -1, func(s *state, re *regexp.Regexp, args []interface{}) (interface{}, error) {
+		value, old, replacement := toString(args[0]), toString(args[1]), toString(args[2])
+		// An empty old matches at every character and once more
+		n := strings.Count(value, old)
+		if err := s.alloc(len(value) + n*len(replacement)); err != nil {
+			return nil, err
+		}
+		return strings.ReplaceAll(value, old, replacement), nil
+	}},
+	"split": {2, 2, -1, func(s *state, re *regexp.Regexp, args []interface{}) (interface{}, error) {
+		value, sep := toString(args[0]), toString(args[1])
+		if err := s.alloc((strings.Count(value, sep) + 1) * elementSize); err != nil {
+			return nil, err
+		}
+		parts := strings.Split(value, sep)
+		list := make([]interface{}, len(parts))
+		for i, part := range parts {
+			list[i] = part
+		}
+		return list, nil
+	}},
+	"join": {2, 2, -1, func(s *state, re *regexp.Regexp, args []interface{}) (interface{}, error) {
+		list, ok := args[0].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected a list, got %s", typeName(args[0]))
+		}
+		sep := toString(args[1])
+		size := len(list) * len(sep)
+		parts := make([]string, len(list))
+		for i, item := range list {
+			parts[i] = toString(item)
+			size += len(parts[i])
+		}
+		if err := s.alloc(size); err != nil {
+			return nil, err
+		}
+		return strings.Join(parts, sep), nil
+	}},
+	"substr": building(2, 3, func(args []interface{}) (interface{}, error) {
+		runes := []rune(toString(args[0]))
+		start, ok := toInt(args[1])
+		if !ok {
+			return nil, fmt.Errorf("start must be an integer")
+		}
+		end := int64(len(runes))
+		if len(args) == 3 {
+			if end, ok = toInt(args[2]); !ok {
+				return nil, fmt.Errorf("end must be an integer")
+			}
+		}
+		start, end = clamp(start, len(runes)), clamp(end, len(runes))
+		if start >= end {
+			return "", nil
+		}
+		return string(runes[start:end]), nil
+	}),
+	"int": pure(1, 1, func(args []interface{}) (interface{}, error) {
+		if n, ok := toNumber(args[0]); ok {
+			return int64(n), nil
+		}
+		return strconv.ParseInt(strings.TrimSpace(toString(args[0])), 10, 64)
+	}),
+	"float": pure(1, 1, func(args []interface{}) (interface{}, error) {
+		if n, ok := toNumber(args[0]); ok {
+			return n, nil
+		}
+		return strconv.ParseFloat(strings.TrimSpace(toString(args[0])), 64)
+	}),
+	"string": building(1, 1, func(args []interface{}) (interface{}, error) {
+		return toString(args[0]), nil
+	}),
+	"type": pure(1, 1, func(args []interface{}) (interface{}, error) {
+		return typeName(args[0]), nil
+	}),
+	"exists": pure(1, 1, func(args []interface{}) (interface{}, error) {
+		return args[0] != nil, nil
+	}),
+	"coalesce": pure(1, -1, func(args []interface{}) (interface{}, error) {
+		for _, arg := range args {
+			if arg != nil && arg != "" {
+				return arg, nil
+			}
+		}
+		return nil, nil
+	}),
+	"keys": building(1, 1, func(args []interface{}) (interface{}, error) {
+		m, err := mapArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+		names := sortedKeys(m)
+		list := make([]interface{}, len(names))
+		for i, name := range names {
+			list[i] = name
+		}
+		return list, nil
+	}),
+	"values": building(1, 1, func(args []interface{}) (interface{}, error) {
+		m, err := mapArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+		names := sortedKeys(m)
+		list := make([]interface{}, len(names))
+		for i, name := range names {
+			list[i] = m[name]
+		}
+		return list, nil
+	}),
+	"append": {2, -1, -1, func(s *state, re *regexp.Regexp, args []interface{}) (interface{}, error) {
+		var list []interface{}
+		switch v := args[0].(type) {
+		case nil:
+		case []interface{}:
+			list = v
+		default:
+			return nil, fmt.Errorf("expected a list, got %s", typeName(args[0]))
+		}
+		if err := s.alloc((len(list) + len(args) - 1) * elementSize); err != nil {
+			return nil, err
+		}
+		return append(append(make([]interface{}, 0, len(list)+len(args)-1), list...), args[1:]...), nil
+	}},
+	"range": {1, 2, -1, func(s *state, re *regexp.Regexp, args []interface{}) (interface{}, error) {
+		start, end := int64(0), int64(0)
+		var ok bool
+		if len(args) == 1 {
+			end, ok = toInt(args[0])
+		} else if start, ok = toInt(args[0]); ok {
+			end, ok = toInt(args[1])
+		}
+		if !ok {
+			return nil, fmt.Errorf("bounds must be integers")
+		}
+		if end <= start {
+			return []interface{}{}, nil
+		}
+		if end-start > maxRange {
+			return nil, fmt.Errorf("more than %d elements", maxRange)
+		}
+		// Building the list costs a step per element
+		if err := s.step(int(end - start)); err != nil {
+			return nil, err
+		}
+		if err := s.alloc(int(end-start) * elementSize); err != nil {
+			return nil, err
+		}
+		list := make([]interface{}, 0, end-start)
+		for i := start; i < end; i++ {
+			list = append(list, i)
+		}
+		return list, nil
+	}},
+	"match": {2, 2, 1, func(s *state, re *regexp.Regexp, args []interface{}) (interface{}, error) {
+		if args[0] == nil {
+			return nil, nil
+		}
+		m := re.FindStringSubmatch(toString(args[0]))
+		if m == nil {
+			return nil, nil
+		}
+		if err := s.alloc(len(m) * elementSize); err != nil {
+			return nil, err
+		}
+
+		// Named groups become a map, otherwise a list of all groups
+		names := re.SubexpNames()
+		named := make(map[string]interface{})
+		for i, name := range names {
+			if name != "" {
+				named[name] = m[i]
+			}
+		}
+		if len(named) > 0 {
+			return named, nil
+		}
+		list := make([]interface{}, len(m))
+		for i, group := range m {
+			list[i] = group
+		}
+		return list, nil
+	}},
+	"regex_replace": {3, 3, 1, func(s *state, re *regexp.Regexp, args []interface{}) (interface{}, error) {
+		// Expanding match by match charges the result as it grows, since a
+		// template can repeat each match any number of times
+		value, template := toString(args[0]), toString(args[2])
+		var result []byte
+		last := 0
+		for _, m := range re.FindAllStringSubmatchIndex(value, -1) {
+			before := len(result)
+			result = append(result, value[last:m[0]]...)
+			result = re.ExpandString(result, template, value, m)
+			last = m[1]
+			if err := s.alloc(len(result) - before); err != nil {
+				return nil, err
+			}
+		}
+		if err := s.alloc(len(value) - last); err != nil {
+			return nil, err
+		}
+		return string(append(result, value[last:]...)), nil
+	}},
+	"parse_json": {1, 1, -1, func(s *state, re *regexp.Regexp, args []interface{}) (interface{}, error) {
+		var value interface{}
+		if err := json.Unmarshal([]byte(toString(args[0])), &value); err != nil {
+			return nil, err
+		}
+		if err := s.alloc(deepSizeOf(value)); err != nil {
+			return nil, err
+		}
+		return value, nil
+	}},
+	"to_json": building(1, 1, func(args []interface{}) (interface{}, error) {
+		data, err := json.Marshal(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	}),
+	"sha256": pure(1, 1, func(args []interface{}) (interface{}, error) {
+		sum := sha256.Sum256([]byte(toString(args[0])))
+		return hex.EncodeToString(sum[:]), nil
+	}),
+	"abs": pure(1, 1, func(args []interface{}) (interface{}, error) {
+		if i, ok := args[0].(int64); ok {
+			if i < 0 {
+				return -i, nil
+			}
+			return i, nil
+		}
+		n, ok := toNumber(args[0])
+		if !ok {
+			return nil, fmt.Errorf("expected a number, got %s", typeName(args[0]))
+		}
+		return math.Abs(n), nil
+	}),
+	"round": pure(1, 1, func(args []interface{}) (interface{}, error) {
+		n, ok := toNumber(args[0])
+		if !ok {
+			return nil, fmt.Errorf("expected a number, got %s", typeName(args[0]))
+		}
+		return int64(math.Round(n)), nil
+	}),
+	"min": pure(1, -1, func(args []interface{}) (interface{}, error) {
+		return extreme(args, -1)
+	}),
+	"max": pure(1, -1, func(args []interface{}) (interface{}, error) {
+		return extreme(args, 1)
+	}),
+}
+
+// sizeOf is what a value built by a function counts against the memory
+// budget; the elements of a list or map are counted, not what they hold
+func sizeOf(value interface{}) int {
+	switch v := value.(type) {
+	case string:
+		return len(v)
+	case []interface{}:
+		return len(v) * elementSize
+	case map[string]interface{}:
+		size := len(v) * elementSize
+		for key := range v {
+			size += len(key)
+		}
+		return size
+	}
+	return 0
+}
+
+// deepSizeOf is sizeOf including everything a list or map holds
+func deepSizeOf(value interface{}) int {
+	size := sizeOf(value)
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			size += deepSizeOf(item)
+		}
+	case map[string]interface{}:
+		for _, item := range v {
+			size += deepSizeOf(item)
+		}
+	}
+	return size
+}
+
+func clamp(i int64, n int) int64 {
+	if i < 0 {
+		i += int64(n)
+	}
+	if i < 0 {
+		return 0
+	}
+	if i > int64(n) {
+		return int64(n)
+	}
+	return i
+}
+
+func mapArg(value interface{}) (map[string]interface{}, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		return v, nil
+	}
+	return nil, fmt.Errorf("expected a map, got %s", typeName(value))
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// extreme returns the smallest (sign -1) or largest (sign 1) argument; a
+// single list argument is searched instead
+func extreme(args []interface{}, sign int) (interface{}, error) {
+	if list, ok := args[0].([]interface{}); ok && len(args) == 1 {
+		args = list
+	}
+	if len(args) == 0 {
+		return nil, nil
+	}
+	best := args[0]
+	for _, arg := range args[1:] {
+		if compare(arg, best)*sign > 0 {
+			best = arg
+		}
+	}
+	return best, nil
+}
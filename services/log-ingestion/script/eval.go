@@ -0,0 +1,663 @@
+package script
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type expr interface {
+	eval(s *state) (interface{}, error)
+}
+
+type literal struct {
+	value interface{}
+}
+
+func (e literal) eval(s *state) (interface{}, error) {
+	return e.value, nil
+}
+
+type listExpr struct {
+	items []expr
+}
+
+func (e listExpr) eval(s *state) (interface{}, error) {
+	if err := s.alloc(len(e.items) * elementSize); err != nil {
+		return nil, err
+	}
+	list := make([]interface{}, len(e.items))
+	for i, item := range e.items {
+		value, err := item.eval(s)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = value
+	}
+	return list, nil
+}
+
+type mapExpr struct {
+	keys   []string
+	values []expr
+}
+
+func (e mapExpr) eval(s *state) (interface{}, error) {
+	if err := s.alloc(len(e.keys) * elementSize); err != nil {
+		return nil, err
+	}
+	m := make(map[string]interface{}, len(e.keys))
+	for i, key := range e.keys {
+		value, err := e.values[i].eval(s)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = value
+	}
+	return m, nil
+}
+
+// pathExpr yields null for missing fields so conditions on them are simply false
+type pathExpr struct {
+	path path
+}
+
+func (e pathExpr) eval(s *state) (interface{}, error) {
+	return normalize(e.path.get(s.entry)), nil
+}
+
+type varExpr struct {
+	name string
+	slot int
+}
+
+func (e varExpr) eval(s *state) (interface{}, error) {
+	return s.vars[e.slot], nil
+}
+
+type indexExpr struct {
+	target, index expr
+}
+
+func (e indexExpr) eval(s *state) (interface{}, error) {
+	target, err := e.target.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	index, err := e.index.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	return getIndex(target, index)
+}
+
+// getIndex reads a list element (negative indexes count from the end), a
+// map value or a character; anything missing is null
+func getIndex(target, index interface{}) (interface{}, error) {
+	switch t := target.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		i, ok := toInt(index)
+		if !ok {
+			return nil, fmt.Errorf("list index must be an integer, got %s", typeName(index))
+		}
+		if i < 0 {
+			i += int64(len(t))
+		}
+		if i < 0 || i >= int64(len(t)) {
+			return nil, nil
+		}
+		return normalize(t[i]), nil
+	case map[string]interface{}:
+		return normalize(t[toString(index)]), nil
+	case string:
+		i, ok := toInt(index)
+		if !ok {
+			return nil, fmt.Errorf("string index must be an integer, got %s", typeName(index))
+		}
+		runes := []rune(t)
+		if i < 0 {
+			i += int64(len(runes))
+		}
+		if i < 0 || i >= int64(len(runes)) {
+			return nil, nil
+		}
+		return string(runes[i]), nil
+	}
+	return nil, fmt.Errorf("cannot index %s", typeName(target))
+}
+
+func setIndex(target, index, value interface{}) error {
+	switch t := target.(type) {
+	case []interface{}:
+		i, ok := toInt(index)
+		if !ok {
+			return fmt.Errorf("list index must be an integer, got %s", typeName(index))
+		}
+		if i < 0 {
+			i += int64(len(t))
+		}
+		if i < 0 || i >= int64(len(t)) {
+			return fmt.Errorf("list index %d out of range", i)
+		}
+		t[i] = value
+		return nil
+	case map[string]interface{}:
+		t[toString(index)] = value
+		return nil
+	}
+	return fmt.Errorf("cannot assign into %s", typeName(target))
+}
+
+type negExpr struct {
+	inner expr
+}
+
+func (e negExpr) eval(s *state) (interface{}, error) {
+	value, err := e.inner.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	if i, ok := value.(int64); ok {
+		return -i, nil
+	}
+	if f, ok := toNumber(value); ok {
+		return -f, nil
+	}
+	return nil, fmt.Errorf("cannot negate %s", typeName(value))
+}
+
+type notExpr struct {
+	inner expr
+}
+
+func (e notExpr) eval(s *state) (interface{}, error) {
+	value, err := e.inner.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	return !truthy(value), nil
+}
+
+type logicalExpr struct {
+	op          string
+	left, right expr
+}
+
+func (e logicalExpr) eval(s *state) (interface{}, error) {
+	left, err := e.left.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	if e.op == "and" && !truthy(left) {
+		return false, nil
+	}
+	if e.op == "or" && truthy(left) {
+		return true, nil
+	}
+
+	right, err := e.right.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	return truthy(right), nil
+}
+
+type arithExpr struct {
+	op          string
+	left, right expr
+}
+
+func (e arithExpr) eval(s *state) (interface{}, error) {
+	left, err := e.left.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	right, err := e.right.eval(s)
+	if err != nil {
+		return nil, err
+	}
+
+	// Concatenations are charged before they are built, so a script
+	// doubling a value fails instead of exhausting memory
+	if e.op == "+" {
+		if l, ok := left.([]interface{}); ok {
+			if r, ok := right.([]interface{}); ok {
+				if err := s.alloc((len(l) + len(r)) * elementSize); err != nil {
+					return nil, err
+				}
+				return append(append(make([]interface{}, 0, len(l)+len(r)), l...), r...), nil
+			}
+		}
+		_, lnum := toNumber(left)
+		_, rnum := toNumber(right)
+		if !lnum || !rnum {
+			_, lstr := left.(string)
+			_, rstr := right.(string)
+			if lstr || rstr {
+				ls, rs := toString(left), toString(right)
+				if err := s.alloc(len(ls) + len(rs)); err != nil {
+					return nil, err
+				}
+				return ls + rs, nil
+			}
+		}
+	}
+
+	l, lok := toNumber(left)
+	r, rok := toNumber(right)
+	if !lok || !rok {
+		return nil, fmt.Errorf("cannot apply %s to %s and %s", e.op, typeName(left), typeName(right))
+	}
+	li, lint := left.(int64)
+	ri, rint := right.(int64)
+	ints := lint && rint
+
+	switch e.op {
+	case "+":
+		if ints {
+			return li + ri, nil
+		}
+		return l + r, nil
+	case "-":
+		if ints {
+			return li - ri, nil
+		}
+		return l - r, nil
+	case "*":
+		if ints {
+			return li * ri, nil
+		}
+		return l * r, nil
+	case "/":
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return l / r, nil
+	default:
+		a, aok := toInt(left)
+		b, bok := toInt(right)
+		if !aok || !bok {
+			return nil, fmt.Errorf("%% requires integers")
+		}
+		if b == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return a % b, nil
+	}
+}
+
+type compareExpr struct {
+	op          string
+	left, right expr
+	re          *regexp.Regexp
+}
+
+func (e compareExpr) eval(s *state) (interface{}, error) {
+	left, err := e.left.eval(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.re != nil {
+		return left != nil && e.re.MatchString(toString(left)), nil
+	}
+
+	right, err := e.right.eval(s)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "in":
+		return contains(right, left)
+	case "contains":
+		if left == nil {
+			return false, nil
+		}
+		return contains(left, right)
+	case "startswith":
+		return left != nil && strings.HasPrefix(toString(left), toString(right)), nil
+	case "endswith":
+		return left != nil && strings.HasSuffix(toString(left), toString(right)), nil
+	}
+
+	// null never orders
+	if left == nil || right == nil {
+		return false, nil
+	}
+	cmp := compare(left, right)
+	switch e.op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+// contains looks for an element of a list, a key of a map or a substring
+func contains(container, item interface{}) (bool, error) {
+	switch c := container.(type) {
+	case nil:
+		return false, nil
+	case []interface{}:
+		for _, v := range c {
+			if equal(v, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case map[string]interface{}:
+		_, ok := c[toString(item)]
+		return ok, nil
+	case string:
+		return strings.Contains(c, toString(item)), nil
+	}
+	return false, fmt.Errorf("cannot search %s", typeName(container))
+}
+
+type callExpr struct {
+	name string
+	fn   function
+	args []expr
+	re   *regexp.Regexp
+}
+
+func (e callExpr) eval(s *state) (interface{}, error) {
+	if err := s.step(1); err != nil {
+		return nil, err
+	}
+
+	args := make([]interface{}, len(e.args))
+	for i, arg := range e.args {
+		value, err := arg.eval(s)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+	}
+
+	value, err := e.fn.call(s, e.re, args)
+	if err != nil {
+		if err == ErrStepLimit || err == ErrMemoryLimit {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: %w", e.name, err)
+	}
+	return value, nil
+}
+
+// control tells enclosing blocks how a statement finished
+type control int
+
+const (
+	ctlNone control = iota
+	ctlBreak
+	ctlContinue
+	ctlReturn
+	ctlDrop
+)
+
+type stmt interface {
+	exec(s *state) (control, error)
+	position() int
+}
+
+type stmtBase struct {
+	line int
+}
+
+func (b stmtBase) position() int {
+	return b.line
+}
+
+// execBlock runs statements in order, charging a step for each
+func execBlock(s *state, body []stmt) (control, error) {
+	for _, st := range body {
+		if err := s.step(1); err != nil {
+			return ctlNone, &runtimeError{line: st.position(), err: err}
+		}
+		ctl, err := st.exec(s)
+		if err != nil {
+			if _, ok := err.(*runtimeError); !ok {
+				err = &runtimeError{line: st.position(), err: err}
+			}
+			return ctlNone, err
+		}
+		if ctl != ctlNone {
+			return ctl, nil
+		}
+	}
+	return ctlNone, nil
+}
+
+// lvalue is a path or variable, optionally followed by indexes
+type lvalue struct {
+	path    *path
+	slot    int
+	indexes []expr
+}
+
+func (lv lvalue) base(s *state) interface{} {
+	if lv.path != nil {
+		return normalize(lv.path.get(s.entry))
+	}
+	return s.vars[lv.slot]
+}
+
+// container evaluates everything but the last index, returning the
+// collection that holds the addressed element and the last index
+func (lv lvalue) container(s *state) (interface{}, interface{}, error) {
+	target := lv.base(s)
+	for _, ie := range lv.indexes[:len(lv.indexes)-1] {
+		index, err := ie.eval(s)
+		if err != nil {
+			return nil, nil, err
+		}
+		if target, err = getIndex(target, index); err != nil {
+			return nil, nil, err
+		}
+	}
+	if target == nil {
+		return nil, nil, fmt.Errorf("cannot index null")
+	}
+
+	last, err := lv.indexes[len(lv.indexes)-1].eval(s)
+	if err != nil {
+		return nil, nil, err
+	}
+	return target, last, nil
+}
+
+func (lv lvalue) assign(s *state, value interface{}) error {
+	if len(lv.indexes) == 0 {
+		if lv.path != nil {
+			return lv.path.set(s.entry, value)
+		}
+		s.vars[lv.slot] = value
+		return nil
+	}
+
+	target, index, err := lv.container(s)
+	if err != nil {
+		return err
+	}
+	return setIndex(target, index, value)
+}
+
+type assignStmt struct {
+	stmtBase
+	target lvalue
+	value  expr
+}
+
+func (st *assignStmt) exec(s *state) (control, error) {
+	value, err := st.value.eval(s)
+	if err != nil {
+		return ctlNone, err
+	}
+	return ctlNone, st.target.assign(s, value)
+}
+
+type delStmt struct {
+	stmtBase
+	target lvalue
+}
+
+func (st *delStmt) exec(s *state) (control, error) {
+	if len(st.target.indexes) == 0 {
+		// The parser only allows fields here
+		delete(s.entry.Fields, st.target.path.name)
+		return ctlNone, nil
+	}
+
+	target, index, err := st.target.container(s)
+	if err != nil {
+		return ctlNone, err
+	}
+	m, ok := target.(map[string]interface{})
+	if !ok {
+		return ctlNone, fmt.Errorf("cannot delete from %s", typeName(target))
+	}
+	delete(m, toString(index))
+	return ctlNone, nil
+}
+
+type ifStmt struct {
+	stmtBase
+	cond       expr
+	then, els  []stmt
+}
+
+func (st *ifStmt) exec(s *state) (control, error) {
+	value, err := st.cond.eval(s)
+	if err != nil {
+		return ctlNone, err
+	}
+	if truthy(value) {
+		return execBlock(s, st.then)
+	}
+	return execBlock(s, st.els)
+}
+
+type forStmt struct {
+	stmtBase
+	keySlot   int
+	valueSlot int // -1 when only one variable is bound
+	iter      expr
+	body      []stmt
+}
+
+// exec iterates a list (index, value) or a map (key, value) in key order;
+// with one variable it receives list values or map keys. null iterates
+// zero times.
+func (st *forStmt) exec(s *state) (control, error) {
+	value, err := st.iter.eval(s)
+	if err != nil {
+		return ctlNone, err
+	}
+
+	var keys, values []interface{}
+	switch v := value.(type) {
+	case nil:
+		return ctlNone, nil
+	case []interface{}:
+		values = append(values, v...)
+		for i := range v {
+			keys = append(keys, int64(i))
+		}
+	case map[string]interface{}:
+		names := make([]string, 0, len(v))
+		for k := range v {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			keys = append(keys, k)
+			values = append(values, v[k])
+		}
+	default:
+		return ctlNone, fmt.Errorf("cannot iterate over %s", typeName(value))
+	}
+
+	for i := range keys {
+		if err := s.step(1); err != nil {
+			return ctlNone, err
+		}
+
+		_, isMap := value.(map[string]interface{})
+		switch {
+		case st.valueSlot >= 0:
+			s.vars[st.keySlot] = keys[i]
+			s.vars[st.valueSlot] = normalize(values[i])
+		case isMap:
+			s.vars[st.keySlot] = keys[i]
+		default:
+			s.vars[st.keySlot] = normalize(values[i])
+		}
+
+		ctl, err := execBlock(s, st.body)
+		if err != nil {
+			return ctlNone, err
+		}
+		switch ctl {
+		case ctlBreak:
+			return ctlNone, nil
+		case ctlReturn, ctlDrop:
+			return ctl, nil
+		}
+	}
+	return ctlNone, nil
+}
+
+type whileStmt struct {
+	stmtBase
+	cond expr
+	body []stmt
+}
+
+func (st *whileStmt) exec(s *state) (control, error) {
+	for {
+		if err := s.step(1); err != nil {
+			return ctlNone, err
+		}
+		value, err := st.cond.eval(s)
+		if err != nil {
+			return ctlNone, err
+		}
+		if !truthy(value) {
+			return ctlNone, nil
+		}
+
+		ctl, err := execBlock(s, st.body)
+		if err != nil {
+			return ctlNone, err
+		}
+		switch ctl {
+		case ctlBreak:
+			return ctlNone, nil
+		case ctlReturn, ctlDrop:
+			return ctl, nil
+		}
+	}
+}
+
+// controlStmt is break, continue, return or drop
+type controlStmt struct {
+	stmtBase
+	ctl control
+}
+
+func (st *controlStmt) exec(s *state) (control, error) {
+	return st.ctl, nil
+}
@@ -0,0 +1,683 @@
+package script
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"log-processing-system/services/log-ingestion/lexer"
+)
+
+// operators are matched longest first
+var operators = []string{
+	"==", "!=", "<=", ">=", "<", ">", "=",
+	"+", "-", "*", "/", "%",
+	"(", ")", "[", "]", "{", "}", ",", ":", ";",
+}
+
+var keywords = map[string]bool{
+	"let": true, "if": true, "else": true, "for": true, "in": true, "while": true,
+	"break": true, "continue": true, "return": true, "drop": true, "del": true,
+	"and": true, "or": true, "not": true, "true": true, "false": true, "null": true,
+	"contains": true, "matches": true, "startswith": true, "endswith": true,
+}
+
+var comparisonOps = map[string]bool{
+	"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true,
+	"in": true, "contains": true, "matches": true, "startswith": true, "endswith": true,
+}
+
+type parser struct {
+	tokens []lexer.Token
+	pos    int
+
+	// scopes map variable names to slots, innermost last
+	scopes []map[string]int
+	slots  int
+	loops  int
+}
+
+func (p *parser) peek() lexer.Token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() lexer.Token {
+	tok := p.tokens[p.pos]
+	if tok.Kind != lexer.EOF {
+		p.pos++
+	}
+	return tok
+}
+
+// accept consumes the next token if it is the given keyword or operator
+func (p *parser) accept(text string) bool {
+	tok := p.peek()
+	if (tok.Kind == lexer.Ident || tok.Kind == lexer.Op) && tok.Text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	if !p.accept(text) {
+		return p.errorf("expected %q", text)
+	}
+	return nil
+}
+
+func (p *parser) is(text string) bool {
+	tok := p.peek()
+	return (tok.Kind == lexer.Ident || tok.Kind == lexer.Op) && tok.Text == text
+}
+
+func (p *parser) skipNewlines() {
+	for p.peek().Kind == lexer.Newline {
+		p.pos++
+	}
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	tok := p.peek()
+	return fmt.Errorf("line %d, column %d: %s, found %s", tok.Line, tok.Col, fmt.Sprintf(format, args...), tok)
+}
+
+func (p *parser) pushScope() {
+	p.scopes = append(p.scopes, map[string]int{})
+}
+
+func (p *parser) popScope() {
+	p.scopes = p.scopes[:len(p.scopes)-1]
+}
+
+func (p *parser) declare(name string) int {
+	slot := p.slots
+	p.slots++
+	p.scopes[len(p.scopes)-1][name] = slot
+	return slot
+}
+
+func (p *parser) resolve(name string) (int, bool) {
+	for i := len(p.scopes) - 1; i >= 0; i-- {
+		if slot, ok := p.scopes[i][name]; ok {
+			return slot, true
+		}
+	}
+	return 0, false
+}
+
+func (p *parser) parseProgram() ([]stmt, error) {
+	body, err := p.parseStatements()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().Kind != lexer.EOF {
+		return nil, p.errorf("unexpected token")
+	}
+	return body, nil
+}
+
+// parseStatements parses statements separated by newlines or semicolons
+// up to the end of the script or a closing brace
+func (p *parser) parseStatements() ([]stmt, error) {
+	var body []stmt
+	for {
+		for p.peek().Kind == lexer.Newline || p.is(";") {
+			p.pos++
+		}
+		if p.peek().Kind == lexer.EOF || p.is("}") {
+			return body, nil
+		}
+
+		st, err := p.parseStatement()
+		if err != nil {
+			return nil, err
+		}
+		body = append(body, st)
+
+		if p.peek().Kind != lexer.Newline && p.peek().Kind != lexer.EOF && !p.is(";") && !p.is("}") {
+			return nil, p.errorf("expected end of statement")
+		}
+	}
+}
+
+func (p *parser) parseBlock() ([]stmt, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	p.pushScope()
+	body, err := p.parseStatements()
+	p.popScope()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("}"); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func (p *parser) parseStatement() (stmt, error) {
+	tok := p.peek()
+	base := stmtBase{line: tok.Line}
+
+	if tok.Kind == lexer.Ident {
+		switch tok.Text {
+		case "let":
+			p.pos++
+			return p.parseLet(base)
+		case "if":
+			p.pos++
+			return p.parseIf(base)
+		case "for":
+			p.pos++
+			return p.parseFor(base)
+		case "while":
+			p.pos++
+			return p.parseWhile(base)
+		case "break", "continue":
+			p.pos++
+			if p.loops == 0 {
+				return nil, fmt.Errorf("line %d, column %d: %s outside a loop", tok.Line, tok.Col, tok.Text)
+			}
+			ctl := ctlBreak
+			if tok.Text == "continue" {
+				ctl = ctlContinue
+			}
+			return p.parseGuard(&controlStmt{stmtBase: base, ctl: ctl})
+		case "return":
+			p.pos++
+			return p.parseGuard(&controlStmt{stmtBase: base, ctl: ctlReturn})
+		case "drop":
+			p.pos++
+			return p.parseGuard(&controlStmt{stmtBase: base, ctl: ctlDrop})
+		case "del":
+			p.pos++
+			target, err := p.parseLvalue()
+			if err != nil {
+				return nil, err
+			}
+			if len(target.indexes) == 0 && (target.path == nil || !target.path.field) {
+				return nil, fmt.Errorf("line %d, column %d: del needs a field or an indexed map", tok.Line, tok.Col)
+			}
+			return p.parseGuard(&delStmt{stmtBase: base, target: target})
+		}
+	}
+
+	target, err := p.parseLvalue()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("="); err != nil {
+		return nil, err
+	}
+	value, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	return p.parseGuard(&assignStmt{stmtBase: base, target: target, value: value})
+}
+
+// parseGuard wraps a simple statement followed by "if <condition>"
+func (p *parser) parseGuard(st stmt) (stmt, error) {
+	if !p.accept("if") {
+		return st, nil
+	}
+	cond, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	return &ifStmt{stmtBase: stmtBase{line: st.position()}, cond: cond, then: []stmt{st}}, nil
+}
+
+func (p *parser) parseLet(base stmtBase) (stmt, error) {
+	tok := p.next()
+	if tok.Kind != lexer.Ident || keywords[tok.Text] {
+		p.pos--
+		return nil, p.errorf("expected a variable name")
+	}
+	if err := p.expect("="); err != nil {
+		return nil, err
+	}
+	value, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	// Declared after the value so "let x = x + 1" reads an outer x
+	slot := p.declare(tok.Text)
+	return &assignStmt{stmtBase: base, target: lvalue{slot: slot}, value: value}, nil
+}
+
+func (p *parser) parseIf(base stmtBase) (stmt, error) {
+	cond, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	then, err := p.parseBlock()
+	if err != nil {
+		return nil, err
+	}
+	st := &ifStmt{stmtBase: base, cond: cond, then: then}
+
+	// else may follow the closing brace on the same or a later line
+	save := p.pos
+	p.skipNewlines()
+	if !p.accept("else") {
+		p.pos = save
+		return st, nil
+	}
+
+	if p.is("if") {
+		elseBase := stmtBase{line: p.peek().Line}
+		p.pos++
+		nested, err := p.parseIf(elseBase)
+		if err != nil {
+			return nil, err
+		}
+		st.els = []stmt{nested}
+		return st, nil
+	}
+
+	if st.els, err = p.parseBlock(); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+func (p *parser) parseFor(base stmtBase) (stmt, error) {
+	var names []string
+	for {
+		tok := p.next()
+		if tok.Kind != lexer.Ident || keywords[tok.Text] {
+			p.pos--
+			return nil, p.errorf("expected a loop variable")
+		}
+		names = append(names, tok.Text)
+		if len(names) == 2 || !p.accept(",") {
+			break
+		}
+	}
+	if err := p.expect("in"); err != nil {
+		return nil, err
+	}
+	iter, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	p.pushScope()
+	defer p.popScope()
+
+	st := &forStmt{stmtBase: base, iter: iter, keySlot: p.declare(names[0]), valueSlot: -1}
+	if len(names) == 2 {
+		st.valueSlot = p.declare(names[1])
+	}
+
+	p.loops++
+	st.body, err = p.parseBlock()
+	p.loops--
+	if err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+func (p *parser) parseWhile(base stmtBase) (stmt, error) {
+	cond, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	p.loops++
+	body, err := p.parseBlock()
+	p.loops--
+	if err != nil {
+		return nil, err
+	}
+	return &whileStmt{stmtBase: base, cond: cond, body: body}, nil
+}
+
+// parseLvalue parses a path or declared variable followed by any indexes
+func (p *parser) parseLvalue() (lvalue, error) {
+	var lv lvalue
+
+	tok := p.peek()
+	switch {
+	case tok.Kind == lexer.Path:
+		p.pos++
+		target := newPath(tok.Text)
+		lv.path = &target
+	case tok.Kind == lexer.Ident && !keywords[tok.Text]:
+		slot, ok := p.resolve(tok.Text)
+		if !ok {
+			return lv, fmt.Errorf("line %d, column %d: undefined variable %s", tok.Line, tok.Col, tok.Text)
+		}
+		p.pos++
+		lv.slot = slot
+	default:
+		return lv, p.errorf("expected a statement")
+	}
+
+	for p.accept("[") {
+		index, err := p.parseExpr()
+		if err != nil {
+			return lv, err
+		}
+		if err := p.expect("]"); err != nil {
+			return lv, err
+		}
+		lv.indexes = append(lv.indexes, index)
+	}
+	return lv, nil
+}
+
+// parseExpr parses "or" chains of "and" chains of optionally negated comparisons
+func (p *parser) parseExpr() (expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalExpr{op: "or", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalExpr{op: "and", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (expr, error) {
+	if p.accept("not") {
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{inner: inner}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (expr, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	tok := p.peek()
+	if (tok.Kind != lexer.Op && tok.Kind != lexer.Ident) || !comparisonOps[tok.Text] {
+		return left, nil
+	}
+	p.pos++
+
+	right, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	cmp := compareExpr{op: tok.Text, left: left, right: right}
+	if tok.Text == "matches" {
+		if cmp.re, err = literalRegex(right); err != nil {
+			return nil, fmt.Errorf("line %d, column %d: matches %v", tok.Line, tok.Col, err)
+		}
+	}
+	return cmp, nil
+}
+
+func (p *parser) parseAdditive() (expr, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.is("+") || p.is("-") {
+		op := p.next().Text
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = arithExpr{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseMultiplicative() (expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.is("*") || p.is("/") || p.is("%") {
+		op := p.next().Text
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = arithExpr{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (expr, error) {
+	if p.accept("-") {
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if lit, ok := inner.(literal); ok {
+			switch v := lit.value.(type) {
+			case int64:
+				return literal{value: -v}, nil
+			case float64:
+				return literal{value: -v}, nil
+			}
+		}
+		return negExpr{inner: inner}, nil
+	}
+
+	e, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("[") {
+		index, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		e = indexExpr{target: e, index: index}
+	}
+	return e, nil
+}
+
+func (p *parser) parsePrimary() (expr, error) {
+	tok := p.peek()
+
+	switch tok.Kind {
+	case lexer.String:
+		p.pos++
+		return literal{value: tok.Text}, nil
+
+	case lexer.Number:
+		p.pos++
+		if !strings.Contains(tok.Text, ".") {
+			if n, err := strconv.ParseInt(tok.Text, 10, 64); err == nil {
+				return literal{value: n}, nil
+			}
+		}
+		f, err := strconv.ParseFloat(tok.Text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d, column %d: invalid number %q", tok.Line, tok.Col, tok.Text)
+		}
+		return literal{value: f}, nil
+
+	case lexer.Path:
+		p.pos++
+		return pathExpr{path: newPath(tok.Text)}, nil
+
+	case lexer.Op:
+		switch tok.Text {
+		case "(":
+			p.pos++
+			p.skipNewlines()
+			inner, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			p.skipNewlines()
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return inner, nil
+		case "[":
+			p.pos++
+			return p.parseList()
+		case "{":
+			p.pos++
+			return p.parseMap()
+		}
+
+	case lexer.Ident:
+		switch tok.Text {
+		case "true", "false":
+			p.pos++
+			return literal{value: tok.Text == "true"}, nil
+		case "null":
+			p.pos++
+			return literal{value: nil}, nil
+		}
+		if keywords[tok.Text] {
+			break
+		}
+		if p.tokens[p.pos+1].Kind == lexer.Op && p.tokens[p.pos+1].Text == "(" {
+			return p.parseCall()
+		}
+		slot, ok := p.resolve(tok.Text)
+		if !ok {
+			return nil, fmt.Errorf("line %d, column %d: undefined variable %s", tok.Line, tok.Col, tok.Text)
+		}
+		p.pos++
+		return varExpr{name: tok.Text, slot: slot}, nil
+	}
+
+	return nil, p.errorf("expected a value")
+}
+
+func (p *parser) parseList() (expr, error) {
+	var items []expr
+	for {
+		p.skipNewlines()
+		if p.accept("]") {
+			return listExpr{items: items}, nil
+		}
+		item, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		p.skipNewlines()
+		if !p.accept(",") && !p.is("]") {
+			return nil, p.errorf("expected \",\" or \"]\"")
+		}
+	}
+}
+
+// parseMap parses {"key": value, name: value}
+func (p *parser) parseMap() (expr, error) {
+	m := mapExpr{}
+	for {
+		p.skipNewlines()
+		if p.accept("}") {
+			return m, nil
+		}
+		tok := p.next()
+		if tok.Kind != lexer.String && tok.Kind != lexer.Ident {
+			p.pos--
+			return nil, p.errorf("expected a map key")
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		value, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		m.keys = append(m.keys, tok.Text)
+		m.values = append(m.values, value)
+		p.skipNewlines()
+		if !p.accept(",") && !p.is("}") {
+			return nil, p.errorf("expected \",\" or \"}\"")
+		}
+	}
+}
+
+func (p *parser) parseCall() (expr, error) {
+	tok := p.next()
+	fn, ok := functions[tok.Text]
+	if !ok {
+		p.pos--
+		return nil, p.errorf("unknown function")
+	}
+	p.pos++ // (
+
+	var args []expr
+	for {
+		p.skipNewlines()
+		if p.accept(")") {
+			break
+		}
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		p.skipNewlines()
+		if !p.accept(",") && !p.is(")") {
+			return nil, p.errorf("expected \",\" or \")\"")
+		}
+	}
+
+	if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		return nil, fmt.Errorf("line %d, column %d: %s takes %s", tok.Line, tok.Col, tok.Text, fn.arity())
+	}
+
+	call := callExpr{name: tok.Text, fn: fn, args: args}
+	if fn.regexArg >= 0 {
+		re, err := literalRegex(args[fn.regexArg])
+		if err != nil {
+			return nil, fmt.Errorf("line %d, column %d: %s %v", tok.Line, tok.Col, tok.Text, err)
+		}
+		call.re = re
+	}
+	return call, nil
+}
+
+// literalRegex compiles a pattern given as a string literal
+func literalRegex(e expr) (*regexp.Regexp, error) {
+	lit, ok := e.(literal)
+	pattern, isString := lit.value.(string)
+	if !ok || !isString {
+		return nil, fmt.Errorf("requires a string literal pattern")
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("has an invalid pattern: %v", err)
+	}
+	return re, nil
+}
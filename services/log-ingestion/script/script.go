@@ -0,0 +1,213 @@
+// Package script implements a small sandboxed scripting language for log
+// transformations that do not fit declarative rules. A script sees the
+// entry through the same paths as the transform language and can use
+// variables, conditionals, loops and built-in functions:
+//
+//	let parts = split(.message, " ")
+//	if len(parts) >= 3 and parts[0] in ["GET", "POST"] {
+//	    .method = parts[0]
+//	    .path = parts[1]
+//	    .status = int(parts[2])
+//	}
+//	for key, value in .headers {
+//	    if lower(key) == "authorization" {
+//	        .headers[key] = "[redacted]"
+//	    }
+//	}
+//	drop if .path == "/health"
+//
+// Scripts cannot reach anything outside the entry. Every statement, loop
+// iteration and function call counts as a step, and every string, list and
+// map a run builds counts against its memory budget; a run fails once it
+// exceeds either or its context is done. Scripts are tokenized like the
+// transform language, see package lexer.
+package script
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"log-processing-system/services/log-ingestion/lexer"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+// ErrStepLimit is returned when a run exceeds Limits.MaxSteps
+var ErrStepLimit = errors.New("script exceeded its step limit")
+
+// ErrMemoryLimit is returned when a run exceeds Limits.MaxBytes
+var ErrMemoryLimit = errors.New("script exceeded its memory limit")
+
+// checkInterval is how many steps run between context checks
+const checkInterval = 64
+
+// elementSize is what each element of a list or map counts against
+// Limits.MaxBytes, about the size of the value it holds
+const elementSize = 16
+
+// Limits bound a single run. MaxBytes caps the bytes of all the strings,
+// lists and maps the run builds, whether or not it keeps them, with each
+// list or map element counted as elementSize bytes. Zero means unlimited;
+// time is bounded by the context passed to Run.
+type Limits struct {
+	MaxSteps int
+	MaxBytes int
+}
+
+// Script is a compiled script, safe for concurrent use
+type Script struct {
+	body   []stmt
+	slots  int
+	source string
+}
+
+// Compile parses a script; errors carry the offending line and column
+func Compile(source string) (*Script, error) {
+	tokens, err := lexer.Tokenize(source, operators)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens, scopes: []map[string]int{{}}}
+	body, err := p.parseProgram()
+	if err != nil {
+		return nil, err
+	}
+	return &Script{body: body, slots: p.slots, source: source}, nil
+}
+
+// Source returns the text the script was compiled from
+func (s *Script) Source() string {
+	return s.source
+}
+
+// Run executes the script against the entry. It returns false when the
+// script dropped the entry.
+func (s *Script) Run(ctx context.Context, entry *models.Log, limits Limits) (bool, error) {
+	st := &state{
+		ctx:      ctx,
+		entry:    entry,
+		vars:     make([]interface{}, s.slots),
+		maxSteps: limits.MaxSteps,
+		maxBytes: limits.MaxBytes,
+	}
+
+	ctl, err := execBlock(st, s.body)
+	if err != nil {
+		return false, err
+	}
+	return ctl != ctlDrop, nil
+}
+
+// state is the per-run interpreter state
+type state struct {
+	ctx      context.Context
+	entry    *models.Log
+	vars     []interface{}
+	steps    int
+	maxSteps int
+	bytes    int
+	maxBytes int
+}
+
+// step charges n steps and checks the limits
+func (s *state) step(n int) error {
+	before := s.steps
+	s.steps += n
+	if s.maxSteps > 0 && s.steps > s.maxSteps {
+		return ErrStepLimit
+	}
+	if s.steps/checkInterval != before/checkInterval {
+		if err := s.ctx.Err(); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return errors.New("script exceeded its time limit")
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// alloc charges n bytes about to be built against the memory budget
+func (s *state) alloc(n int) error {
+	s.bytes += n
+	if s.maxBytes > 0 && (s.bytes > s.maxBytes || n < 0) {
+		return ErrMemoryLimit
+	}
+	return nil
+}
+
+// runtimeError attaches the source position of the failing statement
+type runtimeError struct {
+	line int
+	err  error
+}
+
+func (e *runtimeError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.line, e.err)
+}
+
+func (e *runtimeError) Unwrap() error {
+	return e.err
+}
+
+// path addresses a built-in attribute or a structured field
+type path struct {
+	name  string
+	field bool
+}
+
+func newPath(text string) path {
+	switch text {
+	case "message", "level", "source", "timestamp":
+		return path{name: text}
+	}
+	return path{name: strings.TrimPrefix(text, "fields."), field: true}
+}
+
+func (p path) get(entry *models.Log) interface{} {
+	if p.field {
+		return entry.Fields[p.name]
+	}
+
+	switch p.name {
+	case "message":
+		return entry.Message
+	case "level":
+		return entry.Level
+	case "source":
+		return entry.Source
+	default:
+		if entry.Timestamp.IsZero() {
+			return nil
+		}
+		return entry.Timestamp.Format(time.RFC3339Nano)
+	}
+}
+
+func (p path) set(entry *models.Log, value interface{}) error {
+	if p.field {
+		if entry.Fields == nil {
+			entry.Fields = models.Fields{}
+		}
+		entry.Fields[p.name] = value
+		return nil
+	}
+
+	switch p.name {
+	case "message":
+		entry.Message = toString(value)
+	case "level":
+		entry.Level = toString(value)
+	case "source":
+		entry.Source = toString(value)
+	default:
+		ts, err := time.Parse(time.RFC3339Nano, toString(value))
+		if err != nil {
+			return fmt.Errorf("cannot set .timestamp: %v", err)
+		}
+		entry.Timestamp = ts
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package script
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func run(t *testing.T, source string, entry *models.Log) bool {
+	s, err := Compile(source)
+	if err != nil {
+		t.Fatalf("Failed to compile: %v", err)
+	}
+	keep, err := s.Run(context.Background(), entry, Limits{MaxSteps: 10000})
+	if err != nil {
+		t.Fatalf("Failed to run: %v", err)
+	}
+	return keep
+}
+
+func TestRun_AccessLog(t *testing.T) {
+	entry := &models.Log{
+		Message: "GET /checkout 503 0.250",
+		Level:   "info",
+		Fields: models.Fields{
+			"headers": map[string]interface{}{"Authorization": "Bearer x", "Accept": "*/*"},
+		},
+	}
+
+	keep := run(t, `
+		let parts = split(.message, " ")
+		if len(parts) == 4 and parts[0] in ["GET", "POST"] {
+			.method = parts[0]
+			.path = parts[1]
+			.status = int(parts[2])
+			.duration_ms = float(parts[3]) * 1000
+		}
+		.level = "error" if .status >= 500
+
+		let redacted = []
+		for name, value in .headers {
+			if lower(name) == "authorization" {
+				.headers[name] = "[redacted]"
+				redacted = append(redacted, name)
+			}
+		}
+		.redacted = join(redacted, ",")
+		del .headers["Accept"]
+	`, entry)
+
+	if !keep {
+		t.Fatal("Expected entry to be kept")
+	}
+	if entry.Fields["method"] != "GET" || entry.Fields["path"] != "/checkout" || entry.Fields["status"] != int64(503) {
+		t.Errorf("Expected parsed request fields, got %v", entry.Fields)
+	}
+	if entry.Fields["duration_ms"] != 250.0 {
+		t.Errorf("Expected duration_ms 250, got %v", entry.Fields["duration_ms"])
+	}
+	if entry.Level != "error" {
+		t.Errorf("Expected level error, got %s", entry.Level)
+	}
+	headers := entry.Fields["headers"].(map[string]interface{})
+	if headers["Authorization"] != "[redacted]" || len(headers) != 1 {
+		t.Errorf("Expected redacted authorization only, got %v", headers)
+	}
+	if entry.Fields["redacted"] != "Authorization" {
+		t.Errorf("Expected redacted list, got %v", entry.Fields["redacted"])
+	}
+}
+
+func TestRun_ControlFlow(t *testing.T) {
+	entry := &models.Log{Message: "a=1 b=2 c=3", Fields: models.Fields{}}
+	keep := run(t, `
+		let total = 0
+		let seen = {}
+		for pair in split(.message, " ") {
+			let kv = match(pair, "^(?P<key>\\w+)=(?P<value>\\d+)$")
+			if kv == null {
+				continue
+			} else if kv["key"] == "c" {
+				break
+			}
+			seen[kv["key"]] = int(kv["value"])
+			total = total + int(kv["value"])
+		}
+		.total = total
+		.keys = keys(seen)
+
+		let n = 0
+		while n < 10 { n = n + 3 }
+		.n = n
+		return
+		.unreachable = true
+	`, entry)
+
+	if !keep {
+		t.Fatal("Expected entry to be kept")
+	}
+	if entry.Fields["total"] != int64(3) {
+		t.Errorf("Expected total 3, got %v", entry.Fields["total"])
+	}
+	if keys, _ := entry.Fields["keys"].([]interface{}); len(keys) != 2 || keys[0] != "a" || keys[1] != "b" {
+		t.Errorf("Expected keys [a b], got %v", entry.Fields["keys"])
+	}
+	if entry.Fields["n"] != int64(12) {
+		t.Errorf("Expected n 12, got %v", entry.Fields["n"])
+	}
+	if _, ok := entry.Fields["unreachable"]; ok {
+		t.Error("Expected return to stop the script")
+	}
+}
+
+func TestRun_Drop(t *testing.T) {
+	s, _ := Compile(`drop if .message startswith "GET /health"`)
+
+	keep, err := s.Run(context.Background(), &models.Log{Message: "GET /health 200"}, Limits{})
+	if err != nil || keep {
+		t.Errorf("Expected health check to be dropped, got keep=%v err=%v", keep, err)
+	}
+	keep, _ = s.Run(context.Background(), &models.Log{Message: "GET /api 200"}, Limits{})
+	if !keep {
+		t.Error("Expected other entries to be kept")
+	}
+}
+
+func TestRun_Limits(t *testing.T) {
+	s, err := Compile("let n = 0\nwhile true {\n  n = n + 1\n}")
+	if err != nil {
+		t.Fatalf("Failed to compile: %v", err)
+	}
+
+	_, err = s.Run(context.Background(), &models.Log{}, Limits{MaxSteps: 1000})
+	if !errors.Is(err, ErrStepLimit) {
+		t.Errorf("Expected step limit error, got %v", err)
+	}
+	if err != nil && !strings.HasPrefix(err.Error(), "line ") {
+		t.Errorf("Expected error to carry the line, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = s.Run(ctx, &models.Log{}, Limits{})
+	if err == nil || !strings.Contains(err.Error(), "time limit") {
+		t.Errorf("Expected time limit error, got %v", err)
+	}
+
+	s, _ = Compile(`let xs = range(5000)`)
+	if _, err := s.Run(context.Background(), &models.Log{}, Limits{MaxSteps: 1000}); !errors.Is(err, ErrStepLimit) {
+		t.Errorf("Expected range to be charged against the step limit, got %v", err)
+	}
+}
+
+func TestRun_MemoryLimit(t *testing.T) {
+	tests := []string{
+		// Doubling reaches gigabytes long before the step limit
+		"let s = \"x\"\nwhile true {\n  s = s + s\n}",
+		"let xs = [1]\nwhile true {\n  xs = xs + xs\n}",
+		`.x = join(range(1000), replace(string(range(1000)), ",", ",,,,,,,,"))`,
+		`.x = split(replace(string(range(1000)), ",", ",,,,,,,,"), "")`,
+		`.x = regex_replace(string(range(5000)), "(,)", "$1$1$1$1$1$1$1$1$1$1$1$1$1$1$1$1$1$1$1$1$1$1$1$1$1$1$1$1$1$1")`,
+	}
+
+	for _, source := range tests {
+		s, err := Compile(source)
+		if err != nil {
+			t.Fatalf("Failed to compile %q: %v", source, err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err = s.Run(ctx, &models.Log{}, Limits{MaxSteps: 10000, MaxBytes: 64 << 10})
+		cancel()
+		if !errors.Is(err, ErrMemoryLimit) {
+			t.Errorf("Expected memory limit error for %q, got %v", source, err)
+		}
+	}
+
+	s, _ := Compile(`.x = join(split("a b c", " "), "-")`)
+	entry := &models.Log{}
+	if _, err := s.Run(context.Background(), entry, Limits{MaxSteps: 10000, MaxBytes: 64 << 10}); err != nil || entry.Fields["x"] != "a-b-c" {
+		t.Errorf("Expected a small run within the budget, got %v, %v", entry.Fields["x"], err)
+	}
+}
+
+func TestRun_RuntimeErrors(t *testing.T) {
+	tests := []string{
+		`.x = 1 / 0`,
+		`.x = "a" - 1`,
+		`.x = parse_json("{")`,
+		`for x in 5 { }`,
+		`let m = null
+m["a"] = 1`,
+	}
+
+	for _, source := range tests {
+		s, err := Compile(source)
+		if err != nil {
+			t.Fatalf("Failed to compile %q: %v", source, err)
+		}
+		if _, err := s.Run(context.Background(), &models.Log{}, Limits{}); err == nil {
+			t.Errorf("Expected runtime error for %q", source)
+		}
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	tests := []struct {
+		source string
+		errMsg string
+	}{
+		{`.x = y`, "undefined variable y"},
+		{`if .a { let y = 1 }
+.x = y`, "line 2"},
+		{`break`, "outside a loop"},
+		{`.x = nope(1)`, "unknown function"},
+		{`.x = match(.message, .pattern)`, "string literal"},
+		{`.x = .m matches "("`, "invalid pattern"},
+		{`del .message`, "del needs"},
+		{`.x = [1, 2`, "expected"},
+		{`.x = "open`, "unterminated string"},
+		{`let if = 1`, "variable name"},
+	}
+
+	for _, tt := range tests {
+		_, err := Compile(tt.source)
+		if err == nil {
+			t.Errorf("Expected error for %q", tt.source)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.errMsg) {
+			t.Errorf("Compile(%q) error = %q, want it to contain %q", tt.source, err, tt.errMsg)
+		}
+	}
+}
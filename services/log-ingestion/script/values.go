@@ -0,0 +1,187 @@
+package script
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Values are those of decoded JSON plus int64: nil, bool, int64, float64,
+// string, []interface{} and map[string]interface{}
+
+// toNumber converts numeric values, including those decoded from JSON
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// toInt converts integral numbers and numeric strings
+func toInt(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		return n, err == nil
+	}
+	if f, ok := toNumber(value); ok && f == float64(int64(f)) {
+		return int64(f), true
+	}
+	return 0, false
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}, map[string]interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case string:
+		return "string"
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "map"
+	}
+	if _, ok := toNumber(value); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func equal(left, right interface{}) bool {
+	if left == nil || right == nil {
+		return left == nil && right == nil
+	}
+	if l, ok := toNumber(left); ok {
+		if r, ok := toNumber(right); ok {
+			return l == r
+		}
+	}
+	switch l := left.(type) {
+	case bool:
+		r, ok := right.(bool)
+		return ok && l == r
+	case string:
+		r, ok := right.(string)
+		return ok && l == r
+	case []interface{}:
+		r, ok := right.([]interface{})
+		if !ok || len(l) != len(r) {
+			return false
+		}
+		for i := range l {
+			if !equal(l[i], r[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		r, ok := right.(map[string]interface{})
+		if !ok || len(l) != len(r) {
+			return false
+		}
+		for k, v := range l {
+			if rv, exists := r[k]; !exists || !equal(v, rv) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(left, right)
+}
+
+// compare orders numbers numerically and anything else as strings
+func compare(left, right interface{}) int {
+	l, lok := toNumber(left)
+	r, rok := toNumber(right)
+	if lok && rok {
+		switch {
+		case l < r:
+			return -1
+		case l > r:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(toString(left), toString(right))
+}
+
+func truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	}
+	if n, ok := toNumber(value); ok {
+		return n != 0
+	}
+	return true
+}
+
+// normalize converts values a script may hold in fields, such as
+// models.Fields or []string, into the generic forms scripts work with
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return int64(v)
+	case []string:
+		list := make([]interface{}, len(v))
+		for i, s := range v {
+			list[i] = s
+		}
+		return list
+	case map[string]string:
+		m := make(map[string]interface{}, len(v))
+		for k, s := range v {
+			m[k] = s
+		}
+		return m
+	}
+	if rv := reflect.ValueOf(value); rv.IsValid() && rv.Type().ConvertibleTo(mapType) {
+		return rv.Convert(mapType).Interface()
+	}
+	return value
+}
+
+var mapType = reflect.TypeOf(map[string]interface{}{})
@@ -0,0 +1,85 @@
+// Package sdnotify reports the state of a service to systemd, for units
+// with Type=notify: that it is ready or stopping, a status line for
+// systemctl status, and watchdog keep-alives. Notifications go to the
+// socket systemd names in NOTIFY_SOCKET; without it, as outside systemd,
+// they are skipped.
+package sdnotify
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// States understood by systemd
+const (
+	Ready    = "READY=1"
+	Stopping = "STOPPING=1"
+	Watchdog = "WATCHDOG=1"
+)
+
+// Status returns the state setting the status line systemctl status shows
+func Status(text string) string {
+	return "STATUS=" + text
+}
+
+// Notify sends the states to systemd in one message. It returns false
+// without an error when the process was not started by a unit that expects
+// notifications.
+func Notify(states ...string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+	addr := &net.UnixAddr{Name: socket, Net: "unixgram"}
+	// A leading @ names a socket in the abstract namespace
+	if strings.HasPrefix(socket, "@") {
+		addr.Name = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, addr)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(strings.Join(states, "\n"))); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// WatchdogInterval returns how often systemd expects keep-alives before it
+// considers the service hung, or 0 when the unit sets no WatchdogSec or its
+// watchdog is meant for another process
+func WatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// RunWatchdog sends keep-alives at half the watchdog interval until ctx is
+// done; it returns at once when the unit has no watchdog
+func RunWatchdog(ctx context.Context) {
+	interval := WatchdogInterval()
+	if interval == 0 {
+		return
+	}
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			Notify(Watchdog)
+		}
+	}
+}
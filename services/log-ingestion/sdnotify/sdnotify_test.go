@@ -0,0 +1,72 @@
+package sdnotify
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// listen creates a socket standing in for systemd's and points
+// NOTIFY_SOCKET at it
+func listen(t *testing.T) *net.UnixConn {
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", path)
+	return conn
+}
+
+func TestNotify(t *testing.T) {
+	conn := listen(t)
+
+	sent, err := Notify(Ready, Status("Serving"))
+	if err != nil || !sent {
+		t.Fatalf("Expected the notification sent, got %v, %v", sent, err)
+	}
+
+	buf := make([]byte, 256)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("Failed to read notification: %v", err)
+	}
+	if got, want := string(buf[:n]), "READY=1\nSTATUS=Serving"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestNotify_WithoutSocket(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+
+	sent, err := Notify(Ready)
+	if err != nil || sent {
+		t.Errorf("Expected nothing sent without NOTIFY_SOCKET, got %v, %v", sent, err)
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	pid := strconv.Itoa(os.Getpid())
+	tests := []struct {
+		usec, pid string
+		want      time.Duration
+	}{
+		{"", "", 0},
+		{"invalid", "", 0},
+		{"30000000", "", 30 * time.Second},
+		{"30000000", pid, 30 * time.Second},
+		{"30000000", "1", 0},
+	}
+	for _, tt := range tests {
+		t.Setenv("WATCHDOG_USEC", tt.usec)
+		t.Setenv("WATCHDOG_PID", tt.pid)
+		if got := WatchdogInterval(); got != tt.want {
+			t.Errorf("WATCHDOG_USEC=%q WATCHDOG_PID=%q: expected %v, got %v", tt.usec, tt.pid, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+// Package secrets resolves configuration values that refer to secrets kept
+// in AWS Secrets Manager or the SSM Parameter Store, so deployments never
+// have to put passwords in environment variables or files.
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// Reference schemes
+const (
+	SecretsManagerScheme = "aws-sm://"
+	SSMScheme            = "ssm://"
+)
+
+// IsReference reports whether a value refers to a secret rather than being
+// one
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, SecretsManagerScheme) || strings.HasPrefix(value, SSMScheme)
+}
+
+// Resolver looks up secret references:
+//
+//	aws-sm://name          the string value of a Secrets Manager secret
+//	aws-sm://name#field    one field of a secret holding a JSON object,
+//	                       such as the password of an RDS secret
+//	ssm://path/to/param    an SSM parameter, SecureStrings decrypted
+//
+// A secret may be named by its ARN, whose region then overrides the
+// configured one; ssm:// paths are taken as absolute. Values are cached, so
+// each secret is fetched once however many settings refer to it.
+type Resolver struct {
+	credentials aws.CredentialsProvider
+	region      string
+	client      *http.Client
+	signer      *v4.Signer
+	// endpoint returns the base URL of a service in a region
+	endpoint func(service, region string) string
+
+	mu    sync.Mutex
+	cache map[string]string
+}
+
+// NewResolver creates a resolver with credentials and region from the
+// environment, shared config or instance role
+func NewResolver(ctx context.Context) (*Resolver, error) {
+	awsCfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	return &Resolver{
+		credentials: awsCfg.Credentials,
+		region:      awsCfg.Region,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		signer:      v4.NewSigner(),
+		endpoint: func(service, region string) string {
+			return fmt.Sprintf("https://%s.%s.amazonaws.com", service, region)
+		},
+		cache: make(map[string]string),
+	}, nil
+}
+
+// Resolve returns the secret a reference refers to
+func (r *Resolver) Resolve(ctx context.Context, ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, SecretsManagerScheme):
+		name, field, _ := strings.Cut(strings.TrimPrefix(ref, SecretsManagerScheme), "#")
+		if name == "" {
+			return "", fmt.Errorf("%s: missing secret name", ref)
+		}
+		value, err := r.secret(ctx, name)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", ref, err)
+		}
+		if field == "" {
+			return value, nil
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(value), &fields); err != nil {
+			return "", fmt.Errorf("%s: secret is not a JSON object", ref)
+		}
+		v, ok := fields[field]
+		if !ok {
+			return "", fmt.Errorf("%s: secret has no field %q", ref, field)
+		}
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+		return fmt.Sprint(v), nil
+
+	case strings.HasPrefix(ref, SSMScheme):
+		name := strings.TrimPrefix(ref, SSMScheme)
+		if name == "" {
+			return "", fmt.Errorf("%s: missing parameter name", ref)
+		}
+		if strings.Contains(name, "/") && !strings.HasPrefix(name, "/") {
+			name = "/" + name
+		}
+		value, err := r.parameter(ctx, name)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", ref, err)
+		}
+		return value, nil
+	}
+	return "", fmt.Errorf("%q is not a secret reference", ref)
+}
+
+// secret fetches a Secrets Manager secret's string value
+func (r *Resolver) secret(ctx context.Context, name string) (string, error) {
+	region := r.region
+	if arn := strings.Split(name, ":"); len(arn) > 3 && arn[0] == "arn" {
+		region = arn[3]
+	}
+	var out struct {
+		SecretString *string
+	}
+	if err := r.call(ctx, "secretsmanager", region, "secretsmanager.GetSecretValue", map[string]interface{}{"SecretId": name}, &out); err != nil {
+		return "", err
+	}
+	if out.SecretString == nil {
+		return "", fmt.Errorf("secret has no string value")
+	}
+	return *out.SecretString, nil
+}
+
+// parameter fetches an SSM parameter's value
+func (r *Resolver) parameter(ctx context.Context, name string) (string, error) {
+	var out struct {
+		Parameter struct {
+			Value string
+		}
+	}
+	if err := r.call(ctx, "ssm", r.region, "AmazonSSM.GetParameter", map[string]interface{}{"Name": name, "WithDecryption": true}, &out); err != nil {
+		return "", err
+	}
+	return out.Parameter.Value, nil
+}
+
+// call makes a signed request to an AWS JSON API, caching its answer
+func (r *Resolver) call(ctx context.Context, service, region, target string, input map[string]interface{}, output interface{}) error {
+	if region == "" {
+		return fmt.Errorf("no AWS region configured, set AWS_REGION")
+	}
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+
+	key := region + " " + target + " " + string(body)
+	r.mu.Lock()
+	cached, ok := r.cache[key]
+	r.mu.Unlock()
+	if !ok {
+		if cached, err = r.fetch(ctx, service, region, target, body); err != nil {
+			return err
+		}
+		r.mu.Lock()
+		r.cache[key] = cached
+		r.mu.Unlock()
+	}
+	return json.Unmarshal([]byte(cached), output)
+}
+
+// fetch sends a signed request and returns the response body
+func (r *Resolver) fetch(ctx context.Context, service, region, target string, body []byte) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint(service, region)+"/", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", target)
+
+	creds, err := r.credentials.Retrieve(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve AWS credentials: %w", err)
+	}
+	hash := sha256.Sum256(body)
+	if err := r.signer.SignHTTP(ctx, creds, req, hex.EncodeToString(hash[:]), service, region, time.Now()); err != nil {
+		return "", fmt.Errorf("failed to sign request: %w", err)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		// Services answer with message or Message, which Unmarshal both
+		// matches
+		var apiErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(data, &apiErr)
+		if _, errType, ok := strings.Cut(apiErr.Type, "#"); ok {
+			apiErr.Type = errType
+		}
+		return "", fmt.Errorf("%s failed with status %d: %s", target, resp.StatusCode, strings.TrimSpace(apiErr.Type+" "+apiErr.Message))
+	}
+	return string(data), nil
+}
@@ -0,0 +1,109 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// newTestResolver returns a resolver whose services are answered by handler
+func newTestResolver(t *testing.T, handler http.HandlerFunc) *Resolver {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return &Resolver{
+		credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, nil
+		}),
+		region:   "eu-west-1",
+		client:   server.Client(),
+		signer:   v4.NewSigner(),
+		endpoint: func(service, region string) string { return server.URL },
+		cache:    make(map[string]string),
+	}
+}
+
+func TestResolver_Resolve(t *testing.T) {
+	var calls int32
+	r := newTestResolver(t, func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if !strings.HasPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			t.Errorf("Expected a signed request, got %q", req.Header.Get("Authorization"))
+		}
+		var input map[string]interface{}
+		json.NewDecoder(req.Body).Decode(&input)
+
+		switch req.Header.Get("X-Amz-Target") {
+		case "secretsmanager.GetSecretValue":
+			if !strings.Contains(req.Header.Get("Authorization"), "/eu-west-1/secretsmanager/") {
+				t.Errorf("Expected a Secrets Manager signature, got %q", req.Header.Get("Authorization"))
+			}
+			switch input["SecretId"] {
+			case "prod/db":
+				json.NewEncoder(w).Encode(map[string]string{"SecretString": `{"username":"logs","password":"s3cret","port":5432}`})
+			case "token":
+				json.NewEncoder(w).Encode(map[string]string{"SecretString": "t0ken"})
+			default:
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"__type":"ResourceNotFoundException","Message":"Secrets Manager can't find the specified secret."}`))
+			}
+		case "AmazonSSM.GetParameter":
+			if input["Name"] != "/prod/db/password" || input["WithDecryption"] != true {
+				t.Errorf("Unexpected GetParameter input %v", input)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"Parameter": map[string]string{"Value": "ssm-s3cret"}})
+		default:
+			t.Errorf("Unexpected target %q", req.Header.Get("X-Amz-Target"))
+		}
+	})
+
+	ctx := context.Background()
+	for ref, want := range map[string]string{
+		"aws-sm://prod/db#password": "s3cret",
+		"aws-sm://prod/db#username": "logs",
+		"aws-sm://prod/db#port":     "5432",
+		"aws-sm://token":            "t0ken",
+		"ssm://prod/db/password":    "ssm-s3cret",
+		"ssm:///prod/db/password":   "ssm-s3cret",
+	} {
+		got, err := r.Resolve(ctx, ref)
+		if err != nil {
+			t.Errorf("%s: %v", ref, err)
+		} else if got != want {
+			t.Errorf("%s: expected %q, got %q", ref, want, got)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("Expected each secret to be fetched once, got %d calls", n)
+	}
+
+	for _, ref := range []string{"aws-sm://missing", "aws-sm://prod/db#email", "aws-sm://token#field", "aws-sm://", "vault://x"} {
+		if _, err := r.Resolve(ctx, ref); err == nil {
+			t.Errorf("%s: expected an error", ref)
+		}
+	}
+	if _, err := r.Resolve(ctx, "aws-sm://missing"); err == nil || !strings.Contains(err.Error(), "ResourceNotFoundException") {
+		t.Errorf("Expected the service's error, got %v", err)
+	}
+}
+
+func TestResolver_ARNRegion(t *testing.T) {
+	var region string
+	r := newTestResolver(t, func(w http.ResponseWriter, req *http.Request) {
+		region = strings.Split(req.Header.Get("Authorization"), "/")[2]
+		json.NewEncoder(w).Encode(map[string]string{"SecretString": "x"})
+	})
+	if _, err := r.Resolve(context.Background(), "aws-sm://arn:aws:secretsmanager:us-east-2:123456789012:secret:prod/db-AbCdEf"); err != nil {
+		t.Fatal(err)
+	}
+	if region != "us-east-2" {
+		t.Errorf("Expected the ARN's region, got %q", region)
+	}
+}
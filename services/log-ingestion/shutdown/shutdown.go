@@ -0,0 +1,125 @@
+// Package shutdown drains a service in order when it stops: the server
+// stops accepting requests, work already accepted is finished and stored,
+// and only then are the connections it needs closed.
+package shutdown
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"log-processing-system/services/log-ingestion/logger"
+)
+
+// StepFunc is one step of a shutdown. It should give up when ctx is done;
+// a step that does not is abandoned at the deadline.
+type StepFunc func(ctx context.Context) error
+
+type step struct {
+	name    string
+	fn      StepFunc
+	reserve time.Duration
+}
+
+// Sequence runs shutdown steps one after the other, in the order they were
+// added, all within one deadline
+type Sequence struct {
+	logger *logger.Logger
+	steps  []step
+}
+
+// New creates an empty shutdown sequence
+func New(log *logger.Logger) *Sequence {
+	return &Sequence{logger: log}
+}
+
+// Add appends a step
+func (s *Sequence) Add(name string, fn StepFunc) {
+	s.steps = append(s.steps, step{name: name, fn: fn})
+}
+
+// AddReserved appends a step that keeps reserve of the deadline for
+// itself: the steps before it are cut off that long before the deadline,
+// so that one slow step cannot leave it no time at all
+func (s *Sequence) AddReserved(name string, reserve time.Duration, fn StepFunc) {
+	s.steps = append(s.steps, step{name: name, fn: fn, reserve: reserve})
+}
+
+// AddFunc appends a step that cannot be cancelled, such as closing a
+// client
+func (s *Sequence) AddFunc(name string, fn func()) {
+	s.Add(name, func(ctx context.Context) error {
+		fn()
+		return nil
+	})
+}
+
+// Run runs every step, even after one fails or the deadline of ctx passes,
+// so that the last ones, which release resources, always run. A step still
+// running at the deadline is left behind. Run returns the first failure.
+func (s *Sequence) Run(ctx context.Context) error {
+	start := time.Now()
+	var failed error
+
+	for i, st := range s.steps {
+		stepStart := time.Now()
+		stepCtx, cancel := s.stepContext(ctx, i)
+		err := s.run(stepCtx, st)
+		cancel()
+		log := s.logger.WithFields(map[string]interface{}{
+			"step":        st.name,
+			"duration_ms": time.Since(stepStart).Milliseconds(),
+		})
+		if err != nil {
+			log.WithError(err).Error("Shutdown step failed")
+			if failed == nil {
+				failed = fmt.Errorf("%s: %w", st.name, err)
+			}
+			continue
+		}
+		log.Debug("Shutdown step completed")
+	}
+
+	log := s.logger.WithField("duration_ms", time.Since(start).Milliseconds())
+	if failed != nil {
+		log.Warn("Shutdown completed with errors")
+	} else {
+		log.Info("Shutdown completed")
+	}
+	return failed
+}
+
+// stepContext returns the context step i runs with: ctx, ending early by
+// the time the steps after it reserved
+func (s *Sequence) stepContext(ctx context.Context, i int) (context.Context, context.CancelFunc) {
+	var reserved time.Duration
+	for _, later := range s.steps[i+1:] {
+		reserved += later.reserve
+	}
+	deadline, ok := ctx.Deadline()
+	if reserved <= 0 || !ok {
+		return ctx, func() {}
+	}
+	return context.WithDeadline(ctx, deadline.Add(-reserved))
+}
+
+// run runs a step until it returns or the deadline passes
+func (s *Sequence) run(ctx context.Context, st step) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- st.fn(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		// Give a step that was just handed an expired context the chance
+		// to return at once, as closing a client does
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(10 * time.Millisecond):
+			return fmt.Errorf("did not finish before the deadline: %w", ctx.Err())
+		}
+	}
+}
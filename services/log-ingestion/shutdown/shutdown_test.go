@@ -0,0 +1,107 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/logger"
+)
+
+func newSequence() *Sequence {
+	return New(logger.New(logger.Config{Level: "ERROR", Service: "test-service", Component: "shutdown"}))
+}
+
+func TestSequence_Order(t *testing.T) {
+	s := newSequence()
+	var order []string
+	for _, name := range []string{"server", "queue", "database"} {
+		name := name
+		s.AddFunc(name, func() { order = append(order, name) })
+	}
+
+	if err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := strings.Join(order, ","); got != "server,queue,database" {
+		t.Errorf("Expected the steps in order, got %s", got)
+	}
+}
+
+func TestSequence_ContinuesAfterFailure(t *testing.T) {
+	s := newSequence()
+	s.Add("queue", func(ctx context.Context) error { return errors.New("broker unavailable") })
+	closed := false
+	s.AddFunc("database", func() { closed = true })
+
+	err := s.Run(context.Background())
+	if err == nil || err.Error() != "queue: broker unavailable" {
+		t.Errorf("Expected the failed step to be reported, got %v", err)
+	}
+	if !closed {
+		t.Error("Expected the later steps to run after a failure")
+	}
+}
+
+func TestSequence_Deadline(t *testing.T) {
+	s := newSequence()
+	release := make(chan struct{})
+	defer close(release)
+	// Ignores its context
+	s.AddFunc("stuck", func() { <-release })
+	cancelled := false
+	s.Add("drain", func(ctx context.Context) error {
+		<-ctx.Done()
+		cancelled = true
+		return ctx.Err()
+	})
+	closed := false
+	s.AddFunc("database", func() { closed = true })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := s.Run(ctx)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the stuck step to be abandoned at the deadline, took %v", elapsed)
+	}
+	if err == nil || !strings.HasPrefix(err.Error(), "stuck: did not finish before the deadline") {
+		t.Errorf("Expected the stuck step to be reported, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the error to wrap the deadline, got %v", err)
+	}
+	if !cancelled || !closed {
+		t.Errorf("Expected the later steps to run with the expired context, drain %v, database %v", cancelled, closed)
+	}
+}
+
+func TestSequence_Reserved(t *testing.T) {
+	s := newSequence()
+	// Would take the whole deadline if it were not cut off
+	s.Add("server", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	drained := false
+	s.AddReserved("write queue", 200*time.Millisecond, func(ctx context.Context) error {
+		select {
+		case <-time.After(50 * time.Millisecond):
+			drained = true
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	err := s.Run(ctx)
+	if err == nil || !strings.HasPrefix(err.Error(), "server: ") {
+		t.Errorf("Expected the server step to be cut off, got %v", err)
+	}
+	if !drained {
+		t.Error("Expected the reserved step to keep its share of the deadline")
+	}
+}
@@ -0,0 +1,359 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func init() {
+	Register("http_forward", newForwardSink)
+}
+
+// forwardConfig forwards entries in batches, as a JSON array, to url, such
+// as the /ingest/batch endpoint of a central instance. Failed batches are
+// retried with exponential backoff; while they are, the buffer fills and
+// writes wait up to block_timeout for room.
+type forwardConfig struct {
+	URL           string            `json:"url"`
+	Headers       map[string]string `json:"headers"`
+	Timeout       string            `json:"timeout"`
+	BatchSize     int               `json:"batch_size"`
+	BatchInterval string            `json:"batch_interval"`
+	MaxBuffered   int               `json:"max_buffered"`
+	BlockTimeout  string            `json:"block_timeout"`
+	MaxRetries    int               `json:"max_retries"`
+	RetryBackoff  string            `json:"retry_backoff"`
+	MaxBackoff    string            `json:"max_backoff"`
+}
+
+type forwardSink struct {
+	url          string
+	headers      map[string]string
+	client       *http.Client
+	batchSize    int
+	interval     time.Duration
+	blockTimeout time.Duration
+	maxRetries   int
+	backoff      time.Duration
+	maxBackoff   time.Duration
+
+	entries chan *models.Log
+	flushes chan chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+
+	// pending counts entries written but not yet sent or dropped
+	pending int64
+}
+
+// permanentError is a response that retrying the same batch cannot fix
+type permanentError struct {
+	status string
+}
+
+func (e *permanentError) Error() string {
+	return fmt.Sprintf("forward target returned %s", e.status)
+}
+
+// retryAfterError is a throttling response naming how long to wait
+type retryAfterError struct {
+	status string
+	wait   time.Duration
+}
+
+func (e *retryAfterError) Error() string {
+	return fmt.Sprintf("forward target returned %s", e.status)
+}
+
+func newForwardSink(raw json.RawMessage) (Sink, error) {
+	cfg := forwardConfig{
+		Timeout:       "10s",
+		BatchSize:     500,
+		BatchInterval: "1s",
+		MaxBuffered:   10000,
+		BlockTimeout:  "5s",
+		MaxRetries:    5,
+		RetryBackoff:  "500ms",
+		MaxBackoff:    "30s",
+	}
+	if err := decodeConfig(raw, &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.URL == "" {
+		return nil, errors.New("http_forward requires a url")
+	}
+	if u, err := url.Parse(cfg.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid forward url %q", cfg.URL)
+	}
+	if cfg.BatchSize <= 0 || cfg.MaxBuffered < cfg.BatchSize {
+		return nil, errors.New("batch_size must be positive and at most max_buffered")
+	}
+	if cfg.MaxRetries < 0 {
+		return nil, fmt.Errorf("invalid max_retries %d", cfg.MaxRetries)
+	}
+
+	s := &forwardSink{
+		url:        cfg.URL,
+		headers:    cfg.Headers,
+		batchSize:  cfg.BatchSize,
+		maxRetries: cfg.MaxRetries,
+		entries:    make(chan *models.Log, cfg.MaxBuffered),
+		flushes:    make(chan chan struct{}),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	timeout, err := positiveDuration("timeout", cfg.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	if s.interval, err = positiveDuration("batch_interval", cfg.BatchInterval); err != nil {
+		return nil, err
+	}
+	if s.blockTimeout, err = positiveDuration("block_timeout", cfg.BlockTimeout); err != nil {
+		return nil, err
+	}
+	if s.backoff, err = positiveDuration("retry_backoff", cfg.RetryBackoff); err != nil {
+		return nil, err
+	}
+	if s.maxBackoff, err = positiveDuration("max_backoff", cfg.MaxBackoff); err != nil {
+		return nil, err
+	}
+	s.client = &http.Client{Timeout: timeout}
+
+	go s.run()
+	return s, nil
+}
+
+// Write buffers entries for the background sender. When the buffer is full
+// it waits up to block_timeout for room, slowing the caller down rather than
+// dropping entries, and then fails with ErrBufferFull.
+func (s *forwardSink) Write(ctx context.Context, entries []*models.Log) error {
+	var timer *time.Timer
+	for _, entry := range entries {
+		select {
+		case s.entries <- entry:
+			atomic.AddInt64(&s.pending, 1)
+			continue
+		default:
+		}
+
+		if timer == nil {
+			timer = time.NewTimer(s.blockTimeout)
+			defer timer.Stop()
+		}
+		select {
+		case s.entries <- entry:
+			atomic.AddInt64(&s.pending, 1)
+		case <-timer.C:
+			return ErrBufferFull
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Flush sends everything written so far and waits until it is sent or
+// dropped after max_retries
+func (s *forwardSink) Flush() error {
+	flushed := make(chan struct{})
+	select {
+	case s.flushes <- flushed:
+		<-flushed
+	case <-s.done:
+	}
+	return nil
+}
+
+// Buffered reports how many entries wait to be sent
+func (s *forwardSink) Buffered() int {
+	return int(atomic.LoadInt64(&s.pending))
+}
+
+// run sends a batch once batch_size entries are buffered or every
+// batch_interval, until Close
+func (s *forwardSink) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	batch := make([]*models.Log, 0, s.batchSize)
+	send := func() {
+		if len(batch) > 0 {
+			s.send(batch)
+			atomic.AddInt64(&s.pending, -int64(len(batch)))
+			batch = make([]*models.Log, 0, s.batchSize)
+		}
+	}
+	// drain batches up everything buffered and sends it
+	drain := func() {
+		for {
+			select {
+			case entry := <-s.entries:
+				batch = append(batch, entry)
+				if len(batch) >= s.batchSize {
+					send()
+				}
+			default:
+				send()
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case entry := <-s.entries:
+			batch = append(batch, entry)
+			if len(batch) >= s.batchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case flushed := <-s.flushes:
+			drain()
+			close(flushed)
+		case <-s.stop:
+			// Send everything written before Close
+			drain()
+			return
+		}
+	}
+}
+
+// send posts a batch, retrying failures with exponential backoff. Entries
+// the target reports as retryable are sent again the same way, without the
+// rest of the batch. A batch that is rejected outright or still fails after
+// max_retries is dropped.
+func (s *forwardSink) send(batch []*models.Log) {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		sinkLogger.WithError(err).Error("Failed to encode forwarded log batch")
+		return
+	}
+
+	backoff := s.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := s.post(body)
+		if err == nil && len(retry) == 0 {
+			return
+		}
+		if err == nil {
+			if batch = retriedEntries(batch, retry); len(batch) == 0 {
+				return
+			}
+			if body, err = json.Marshal(batch); err != nil {
+				sinkLogger.WithError(err).Error("Failed to encode forwarded log batch")
+				return
+			}
+			err = fmt.Errorf("forward target failed to store %d entries", len(batch))
+		}
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) || attempt >= s.maxRetries {
+			sinkLogger.WithFields(map[string]interface{}{
+				"url":      s.url,
+				"entries":  len(batch),
+				"attempts": attempt + 1,
+			}).WithError(err).Error("Dropping log batch that could not be forwarded")
+			return
+		}
+
+		wait := backoff
+		var throttled *retryAfterError
+		if errors.As(err, &throttled) && throttled.wait > 0 {
+			wait = throttled.wait
+		}
+		sinkLogger.WithFields(map[string]interface{}{
+			"url":     s.url,
+			"entries": len(batch),
+			"attempt": attempt + 1,
+			"wait_ms": wait.Milliseconds(),
+		}).WithError(err).Warn("Failed to forward log batch, retrying")
+
+		time.Sleep(wait)
+		if backoff *= 2; backoff > s.maxBackoff {
+			backoff = s.maxBackoff
+		}
+	}
+}
+
+// post sends a batch once. On a 207 it returns the indexes of the entries
+// the target could not store and asks to be sent again.
+func (s *forwardSink) post(body []byte) ([]int, error) {
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, &permanentError{status: err.Error()}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range s.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	defer io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode == http.StatusMultiStatus:
+		var response struct {
+			Rejected []struct {
+				Index     int  `json:"index"`
+				Retryable bool `json:"retryable"`
+			} `json:"rejected"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+			return nil, nil
+		}
+		var retry []int
+		for _, rejection := range response.Rejected {
+			if rejection.Retryable {
+				retry = append(retry, rejection.Index)
+			}
+		}
+		return retry, nil
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		return nil, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable:
+		seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return nil, &retryAfterError{status: resp.Status, wait: time.Duration(seconds) * time.Second}
+	case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode >= 500:
+		return nil, fmt.Errorf("forward target returned %s", resp.Status)
+	default:
+		return nil, &permanentError{status: resp.Status}
+	}
+}
+
+// retriedEntries returns the entries of batch at indexes, skipping any the
+// target made up
+func retriedEntries(batch []*models.Log, indexes []int) []*models.Log {
+	entries := make([]*models.Log, 0, len(indexes))
+	for _, i := range indexes {
+		if i >= 0 && i < len(batch) {
+			entries = append(entries, batch[i])
+		}
+	}
+	return entries
+}
+
+// Close stops accepting entries and sends what is still buffered
+func (s *forwardSink) Close() error {
+	close(s.stop)
+	<-s.done
+	s.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,196 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+// batchRecorder is a forward target that fails the first failures requests
+// with status
+type batchRecorder struct {
+	mu       sync.Mutex
+	batches  [][]models.Log
+	requests int
+	failures int
+	status   int
+}
+
+func (b *batchRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.requests++
+	if b.requests <= b.failures {
+		w.WriteHeader(b.status)
+		return
+	}
+	var batch []models.Log
+	json.NewDecoder(r.Body).Decode(&batch)
+	b.batches = append(b.batches, batch)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (b *batchRecorder) counts() (int, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.batches), b.requests
+}
+
+func newTestForwardSink(t *testing.T, url, extra string) Sink {
+	s, err := Build(Config{Type: "http_forward", Config: []byte(`{"url":"` + url + `","retry_backoff":"1ms"` + extra + `}`)})
+	if err != nil {
+		t.Fatalf("Failed to build sink: %v", err)
+	}
+	return s
+}
+
+func TestForwardSink_Batches(t *testing.T) {
+	target := &batchRecorder{}
+	server := httptest.NewServer(target)
+	defer server.Close()
+
+	s := newTestForwardSink(t, server.URL, `,"batch_size":2,"batch_interval":"1h"`)
+	for _, message := range []string{"a", "b", "c"} {
+		if err := s.Write(context.Background(), []*models.Log{{Message: message, Level: "info"}}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	s.Close()
+
+	if len(target.batches) != 2 || len(target.batches[0]) != 2 || target.batches[1][0].Message != "c" {
+		t.Errorf("Expected a full batch and the rest on close, got %+v", target.batches)
+	}
+}
+
+func TestForwardSink_Retry(t *testing.T) {
+	target := &batchRecorder{failures: 2, status: http.StatusServiceUnavailable}
+	server := httptest.NewServer(target)
+	defer server.Close()
+
+	s := newTestForwardSink(t, server.URL, ``)
+	s.Write(context.Background(), []*models.Log{{Message: "m", Level: "info"}})
+	s.Close()
+
+	if batches, requests := target.counts(); batches != 1 || requests != 3 {
+		t.Errorf("Expected batch delivered on the third attempt, got %d batches in %d requests", batches, requests)
+	}
+}
+
+func TestForwardSink_RetryableEntries(t *testing.T) {
+	var mu sync.Mutex
+	var sent [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch []models.Log
+		json.NewDecoder(r.Body).Decode(&batch)
+		mu.Lock()
+		defer mu.Unlock()
+		var messages []string
+		for _, entry := range batch {
+			messages = append(messages, entry.Message)
+		}
+		sent = append(sent, messages)
+		if len(sent) == 1 {
+			// The second entry could not be stored
+			w.WriteHeader(http.StatusMultiStatus)
+			w.Write([]byte(`{"status":"partial","accepted":2,"rejected":[{"index":1,"error":"Failed to store log entry","retryable":true}]}`))
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	s := newTestForwardSink(t, server.URL, ``)
+	s.Write(context.Background(), []*models.Log{{Message: "a", Level: "info"}, {Message: "b", Level: "info"}, {Message: "c", Level: "info"}})
+	s.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sent) != 2 || len(sent[1]) != 1 || sent[1][0] != "b" {
+		t.Errorf("Expected only the retryable entry sent again, got %v", sent)
+	}
+}
+
+func TestForwardSink_Flush(t *testing.T) {
+	target := &batchRecorder{}
+	server := httptest.NewServer(target)
+	defer server.Close()
+
+	s := newTestForwardSink(t, server.URL, `,"batch_size":10,"batch_interval":"1h"`)
+	defer s.Close()
+	s.Write(context.Background(), []*models.Log{{Message: "a", Level: "info"}, {Message: "b", Level: "info"}})
+	if buffered := s.(Buffering).Buffered(); buffered != 2 {
+		t.Errorf("Expected 2 buffered entries, got %d", buffered)
+	}
+
+	if err := s.(Flusher).Flush(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if batches, _ := target.counts(); batches != 1 {
+		t.Errorf("Expected the buffered entries sent on flush, got %d batches", batches)
+	}
+	if buffered := s.(Buffering).Buffered(); buffered != 0 {
+		t.Errorf("Expected nothing buffered after flush, got %d", buffered)
+	}
+}
+
+func TestForwardSink_PermanentFailure(t *testing.T) {
+	target := &batchRecorder{failures: 1, status: http.StatusBadRequest}
+	server := httptest.NewServer(target)
+	defer server.Close()
+
+	s := newTestForwardSink(t, server.URL, ``)
+	s.Write(context.Background(), []*models.Log{{Message: "m", Level: "info"}})
+	s.Close()
+
+	if batches, requests := target.counts(); batches != 0 || requests != 1 {
+		t.Errorf("Expected rejected batch not to be retried, got %d batches in %d requests", batches, requests)
+	}
+}
+
+func TestForwardSink_Backpressure(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	s := newTestForwardSink(t, server.URL, `,"batch_size":1,"max_buffered":1,"block_timeout":"20ms"`)
+
+	// The first entry is taken by the sender, which then blocks on the
+	// target; the second fills the buffer
+	s.Write(context.Background(), []*models.Log{{Message: "a", Level: "info"}})
+	time.Sleep(20 * time.Millisecond)
+	s.Write(context.Background(), []*models.Log{{Message: "b", Level: "info"}})
+
+	start := time.Now()
+	err := s.Write(context.Background(), []*models.Log{{Message: "c", Level: "info"}})
+	if !errors.Is(err, ErrBufferFull) {
+		t.Errorf("Expected full buffer error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Expected the write to wait for room, returned after %v", elapsed)
+	}
+}
+
+func TestForwardSink_InvalidConfig(t *testing.T) {
+	configs := []string{
+		`{}`,
+		`{"url":"localhost:8080"}`,
+		`{"url":"http://central","batch_size":0}`,
+		`{"url":"http://central","batch_size":100,"max_buffered":10}`,
+		`{"url":"http://central","max_retries":-1}`,
+		`{"url":"http://central","retry_backoff":"soon"}`,
+	}
+	for _, cfg := range configs {
+		if _, err := newForwardSink([]byte(cfg)); err == nil {
+			t.Errorf("Expected error for config %s", cfg)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func init() {
+	Register("kafka", newKafkaSink)
+}
+
+// Kafka sink serializations
+const (
+	// kafkaFormatJSON sends the whole entry as JSON (default)
+	kafkaFormatJSON = "json"
+	// kafkaFormatMessage sends the message text, with level and source as
+	// record headers
+	kafkaFormatMessage = "message"
+)
+
+// kafkaConfig publishes entries to topic, keyed by key_field: "source"
+// (default) or "level", otherwise the named entry field such as a tenant
+// ID. An empty key_field leaves records unkeyed, spreading them over
+// partitions.
+type kafkaConfig struct {
+	Brokers      []string `json:"brokers"`
+	Topic        string   `json:"topic"`
+	KeyField     *string  `json:"key_field"`
+	Format       string   `json:"format"`
+	Compression  string   `json:"compression"`
+	RequiredAcks string   `json:"required_acks"`
+	BatchTimeout string   `json:"batch_timeout"`
+}
+
+// messageWriter is the part of kafka.Writer the sink uses
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+type kafkaSink struct {
+	writer   messageWriter
+	keyField string
+	format   string
+}
+
+func newKafkaSink(raw json.RawMessage) (Sink, error) {
+	cfg := kafkaConfig{Format: kafkaFormatJSON, RequiredAcks: "all", BatchTimeout: "5ms"}
+	if err := decodeConfig(raw, &cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.Brokers) == 0 || cfg.Topic == "" {
+		return nil, errors.New("kafka requires brokers and a topic")
+	}
+	keyField := "source"
+	if cfg.KeyField != nil {
+		keyField = *cfg.KeyField
+	}
+	switch cfg.Format {
+	case kafkaFormatJSON, kafkaFormatMessage:
+	default:
+		return nil, fmt.Errorf("invalid format %q", cfg.Format)
+	}
+
+	writer := &kafka.Writer{
+		Addr:                   kafka.TCP(cfg.Brokers...),
+		Topic:                  cfg.Topic,
+		Balancer:               &kafka.Hash{},
+		AllowAutoTopicCreation: true,
+	}
+	switch cfg.Compression {
+	case "", "none":
+	case "gzip":
+		writer.Compression = kafka.Gzip
+	case "snappy":
+		writer.Compression = kafka.Snappy
+	case "lz4":
+		writer.Compression = kafka.Lz4
+	case "zstd":
+		writer.Compression = kafka.Zstd
+	default:
+		return nil, fmt.Errorf("invalid compression %q", cfg.Compression)
+	}
+	switch cfg.RequiredAcks {
+	case "all":
+		writer.RequiredAcks = kafka.RequireAll
+	case "one":
+		writer.RequiredAcks = kafka.RequireOne
+	case "none":
+		writer.RequiredAcks = kafka.RequireNone
+	default:
+		return nil, fmt.Errorf("invalid required_acks %q", cfg.RequiredAcks)
+	}
+	batchTimeout, err := time.ParseDuration(cfg.BatchTimeout)
+	if err != nil || batchTimeout <= 0 {
+		return nil, fmt.Errorf("invalid batch_timeout %q", cfg.BatchTimeout)
+	}
+	writer.BatchTimeout = batchTimeout
+
+	return &kafkaSink{writer: writer, keyField: keyField, format: cfg.Format}, nil
+}
+
+func (s *kafkaSink) Write(ctx context.Context, entries []*models.Log) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	msgs := make([]kafka.Message, 0, len(entries))
+	for _, entry := range entries {
+		msg, err := s.message(entry)
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, msg)
+	}
+	return s.writer.WriteMessages(ctx, msgs...)
+}
+
+// message serializes an entry into a record
+func (s *kafkaSink) message(entry *models.Log) (kafka.Message, error) {
+	msg := kafka.Message{}
+	if key := s.key(entry); key != "" {
+		msg.Key = []byte(key)
+	}
+
+	if s.format == kafkaFormatMessage {
+		msg.Value = []byte(entry.Message)
+		msg.Headers = []kafka.Header{
+			{Key: "level", Value: []byte(entry.Level)},
+			{Key: "source", Value: []byte(entry.Source)},
+		}
+		return msg, nil
+	}
+
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return msg, err
+	}
+	msg.Value = value
+	return msg, nil
+}
+
+// key returns the record key of an entry, empty when it has none
+func (s *kafkaSink) key(entry *models.Log) string {
+	switch s.keyField {
+	case "":
+		return ""
+	case "source":
+		return entry.Source
+	case "level":
+		return entry.Level
+	}
+	switch v := entry.Fields[s.keyField].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func (s *kafkaSink) Close() error {
+	return s.writer.Close()
+}
@@ -0,0 +1,92 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"log-processing-system/services/log-ingestion/models"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type fakeWriter struct {
+	msgs []kafka.Message
+}
+
+func (w *fakeWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.msgs = append(w.msgs, msgs...)
+	return nil
+}
+
+func (w *fakeWriter) Close() error {
+	return nil
+}
+
+func newTestKafkaSink(t *testing.T, config string) (*kafkaSink, *fakeWriter) {
+	s, err := newKafkaSink([]byte(config))
+	if err != nil {
+		t.Fatalf("Failed to create sink: %v", err)
+	}
+	writer := &fakeWriter{}
+	kafkaSink := s.(*kafkaSink)
+	kafkaSink.writer = writer
+	return kafkaSink, writer
+}
+
+func TestKafkaSink_JSON(t *testing.T) {
+	s, writer := newTestKafkaSink(t, `{"brokers":["localhost:9092"],"topic":"normalized"}`)
+
+	entry := &models.Log{Message: "Order placed", Level: "info", Source: "shop", Fields: models.Fields{"order_id": "42"}}
+	if err := s.Write(context.Background(), []*models.Log{entry}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(writer.msgs) != 1 || string(writer.msgs[0].Key) != "shop" {
+		t.Fatalf("Expected one record keyed by source, got %+v", writer.msgs)
+	}
+	var decoded models.Log
+	if err := json.Unmarshal(writer.msgs[0].Value, &decoded); err != nil {
+		t.Fatalf("Expected JSON value, got %v", err)
+	}
+	if decoded.Message != "Order placed" || decoded.Fields["order_id"] != "42" {
+		t.Errorf("Expected entry to round-trip, got %+v", decoded)
+	}
+}
+
+func TestKafkaSink_MessageFormatAndFieldKey(t *testing.T) {
+	s, writer := newTestKafkaSink(t, `{"brokers":["localhost:9092"],"topic":"raw","format":"message","key_field":"tenant"}`)
+
+	s.Write(context.Background(), []*models.Log{
+		{Message: "Login", Level: "info", Source: "auth", Fields: models.Fields{"tenant": "acme"}},
+		{Message: "Logout", Level: "info", Source: "auth"},
+	})
+
+	if len(writer.msgs) != 2 {
+		t.Fatalf("Expected two records, got %d", len(writer.msgs))
+	}
+	if string(writer.msgs[0].Key) != "acme" || string(writer.msgs[0].Value) != "Login" {
+		t.Errorf("Expected tenant key and message value, got %q %q", writer.msgs[0].Key, writer.msgs[0].Value)
+	}
+	if writer.msgs[1].Key != nil {
+		t.Errorf("Expected entry without tenant to be unkeyed, got %q", writer.msgs[1].Key)
+	}
+	if len(writer.msgs[0].Headers) != 2 || string(writer.msgs[0].Headers[0].Value) != "info" {
+		t.Errorf("Expected level and source headers, got %+v", writer.msgs[0].Headers)
+	}
+}
+
+func TestKafkaSink_InvalidConfig(t *testing.T) {
+	configs := []string{
+		`{}`,
+		`{"brokers":["localhost:9092"]}`,
+		`{"brokers":["localhost:9092"],"topic":"t","format":"xml"}`,
+		`{"brokers":["localhost:9092"],"topic":"t","compression":"brotli"}`,
+		`{"brokers":["localhost:9092"],"topic":"t","required_acks":"some"}`,
+		`{"brokers":["localhost:9092"],"topic":"t","batch_timeout":"soon"}`,
+	}
+	for _, cfg := range configs {
+		if _, err := newKafkaSink([]byte(cfg)); err == nil {
+			t.Errorf("Expected error for config %s", cfg)
+		}
+	}
+}
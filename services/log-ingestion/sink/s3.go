@@ -0,0 +1,316 @@
+package sink
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/google/uuid"
+	"github.com/klauspost/compress/zstd"
+)
+
+func init() {
+	Register("s3", newS3Sink)
+}
+
+// s3Config archives entries as NDJSON objects under prefix, partitioned by
+// the hour of their timestamp with the partition time layout. A batch is
+// uploaded once batch_size entries are buffered or every batch_interval.
+type s3Config struct {
+	Bucket        string `json:"bucket"`
+	Prefix        string `json:"prefix"`
+	Region        string `json:"region"`
+	Endpoint      string `json:"endpoint"`
+	Format        string `json:"format"`
+	Compression   string `json:"compression"`
+	Partition     string `json:"partition"`
+	BatchSize     int    `json:"batch_size"`
+	BatchInterval string `json:"batch_interval"`
+	MaxBuffered   int    `json:"max_buffered"`
+	UploadTimeout string `json:"upload_timeout"`
+}
+
+// objectPutter is the part of the S3 client the sink uses
+type objectPutter interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+type s3Sink struct {
+	client        objectPutter
+	bucket        string
+	prefix        string
+	compression   string
+	partition     string
+	batchSize     int
+	maxBuffered   int
+	uploadTimeout time.Duration
+
+	mu       sync.Mutex
+	buffered map[string][]*models.Log
+	count    int
+
+	// uploadMu serializes uploads so a batch is never sent twice
+	uploadMu sync.Mutex
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func newS3Sink(raw json.RawMessage) (Sink, error) {
+	cfg := s3Config{
+		Prefix:        "logs/",
+		Format:        "ndjson",
+		Compression:   "gzip",
+		Partition:     "dt=2006-01-02/hour=15",
+		BatchSize:     10000,
+		BatchInterval: "1m",
+		UploadTimeout: "1m",
+	}
+	if err := decodeConfig(raw, &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Bucket == "" {
+		return nil, errors.New("s3 requires a bucket")
+	}
+	// NDJSON is the only format for now; Parquet needs a writer dependency
+	// the service does not carry yet
+	if cfg.Format != "ndjson" {
+		return nil, fmt.Errorf("unsupported format %q", cfg.Format)
+	}
+	switch cfg.Compression {
+	case "gzip", "zstd", "none":
+	default:
+		return nil, fmt.Errorf("invalid compression %q", cfg.Compression)
+	}
+	if cfg.Partition == "" {
+		return nil, errors.New("s3 requires a partition layout")
+	}
+	if cfg.BatchSize <= 0 {
+		return nil, fmt.Errorf("invalid batch_size %d", cfg.BatchSize)
+	}
+	if cfg.MaxBuffered == 0 {
+		cfg.MaxBuffered = 10 * cfg.BatchSize
+	}
+	if cfg.MaxBuffered < cfg.BatchSize {
+		return nil, errors.New("max_buffered must be at least batch_size")
+	}
+	interval, err := time.ParseDuration(cfg.BatchInterval)
+	if err != nil || interval <= 0 {
+		return nil, fmt.Errorf("invalid batch_interval %q", cfg.BatchInterval)
+	}
+	uploadTimeout, err := time.ParseDuration(cfg.UploadTimeout)
+	if err != nil || uploadTimeout <= 0 {
+		return nil, fmt.Errorf("invalid upload_timeout %q", cfg.UploadTimeout)
+	}
+
+	// Credentials come from the environment, shared config or instance role
+	var opts []func(*config.LoadOptions) error
+	if cfg.Region != "" {
+		opts = append(opts, config.WithRegion(cfg.Region))
+	}
+	awsCfg, err := config.LoadDefaultConfig(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		// S3-compatible stores such as MinIO are addressed by path
+		if cfg.Endpoint != "" {
+			o.EndpointResolver = s3.EndpointResolverFromURL(cfg.Endpoint)
+			o.UsePathStyle = true
+		}
+	})
+
+	s := &s3Sink{
+		client:        client,
+		bucket:        cfg.Bucket,
+		prefix:        cfg.Prefix,
+		compression:   cfg.Compression,
+		partition:     cfg.Partition,
+		batchSize:     cfg.BatchSize,
+		maxBuffered:   cfg.MaxBuffered,
+		uploadTimeout: uploadTimeout,
+		buffered:      make(map[string][]*models.Log),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	go s.run(interval)
+	return s, nil
+}
+
+// Write buffers entries and uploads them once a batch is full. It fails
+// without buffering when earlier uploads keep failing and the buffer is full.
+func (s *s3Sink) Write(ctx context.Context, entries []*models.Log) error {
+	s.mu.Lock()
+	if s.count+len(entries) > s.maxBuffered {
+		s.mu.Unlock()
+		return ErrBufferFull
+	}
+	for _, entry := range entries {
+		partition := entry.Timestamp.UTC().Format(s.partition)
+		s.buffered[partition] = append(s.buffered[partition], entry)
+	}
+	s.count += len(entries)
+	full := s.count >= s.batchSize
+	s.mu.Unlock()
+
+	if full {
+		return s.Flush()
+	}
+	return nil
+}
+
+// run uploads buffered entries every interval until Close
+func (s *s3Sink) run(interval time.Duration) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if err := s.Flush(); err != nil {
+				sinkLogger.WithFields(map[string]interface{}{
+					"bucket":   s.bucket,
+					"buffered": s.Buffered(),
+				}).WithError(err).Warn("Failed to upload log archive, will retry")
+			}
+		}
+	}
+}
+
+// Buffered reports how many entries wait for the next upload
+func (s *s3Sink) Buffered() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.count
+}
+
+// Flush uploads one object per partition. Partitions that fail to upload are
+// put back to be retried with the next flush.
+func (s *s3Sink) Flush() error {
+	s.uploadMu.Lock()
+	defer s.uploadMu.Unlock()
+
+	s.mu.Lock()
+	batch := s.buffered
+	s.buffered = make(map[string][]*models.Log)
+	s.count = 0
+	s.mu.Unlock()
+
+	partitions := make([]string, 0, len(batch))
+	for partition := range batch {
+		partitions = append(partitions, partition)
+	}
+	sort.Strings(partitions)
+
+	var failed error
+	for _, partition := range partitions {
+		entries := batch[partition]
+		if err := s.upload(partition, entries); err != nil {
+			if failed == nil {
+				failed = err
+			}
+			s.mu.Lock()
+			s.buffered[partition] = append(entries, s.buffered[partition]...)
+			s.count += len(entries)
+			s.mu.Unlock()
+		}
+	}
+	return failed
+}
+
+// upload writes entries as one compressed NDJSON object
+func (s *s3Sink) upload(partition string, entries []*models.Log) error {
+	body, err := s.encode(entries)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s%s/%d-%s.ndjson%s", s.prefix, partition, time.Now().UnixMilli(), uuid.NewString(), s.extension())
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.uploadTimeout)
+	defer cancel()
+	_, err = s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/x-ndjson"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+
+	sinkLogger.WithFields(map[string]interface{}{
+		"bucket":  s.bucket,
+		"key":     key,
+		"entries": len(entries),
+		"bytes":   len(body),
+	}).Debug("Log archive uploaded")
+	return nil
+}
+
+func (s *s3Sink) encode(entries []*models.Log) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch s.compression {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "zstd":
+		zw, err := zstd.NewWriter(&buf)
+		if err != nil {
+			return nil, err
+		}
+		w = zw
+	default:
+		w = nopCloser{&buf}
+	}
+
+	enc := json.NewEncoder(w)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *s3Sink) extension() string {
+	switch s.compression {
+	case "gzip":
+		return ".gz"
+	case "zstd":
+		return ".zst"
+	}
+	return ""
+}
+
+// Close stops the background uploads and sends what is still buffered
+func (s *s3Sink) Close() error {
+	close(s.stop)
+	<-s.done
+	return s.Flush()
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,142 @@
+package sink
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+type fakePutter struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	fail    bool
+}
+
+func (p *fakePutter) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fail {
+		return nil, errors.New("bucket unavailable")
+	}
+	body, _ := io.ReadAll(params.Body)
+	p.objects[*params.Key] = body
+	return &s3.PutObjectOutput{}, nil
+}
+
+func newTestS3Sink(t *testing.T, config string) (*s3Sink, *fakePutter) {
+	s, err := newS3Sink([]byte(config))
+	if err != nil {
+		t.Fatalf("Failed to create sink: %v", err)
+	}
+	putter := &fakePutter{objects: make(map[string][]byte)}
+	s3Sink := s.(*s3Sink)
+	s3Sink.client = putter
+	return s3Sink, putter
+}
+
+func readObject(t *testing.T, body []byte) []models.Log {
+	zr, err := gzip.NewReader(strings.NewReader(string(body)))
+	if err != nil {
+		t.Fatalf("Expected gzip object, got %v", err)
+	}
+	var entries []models.Log
+	scanner := bufio.NewScanner(zr)
+	for scanner.Scan() {
+		var entry models.Log
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Expected NDJSON line, got %v", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestS3Sink_PartitionsBatches(t *testing.T) {
+	s, putter := newTestS3Sink(t, `{"bucket":"archive","region":"us-east-1","batch_size":3,"batch_interval":"1h"}`)
+	defer s.Close()
+
+	first := time.Date(2024, 3, 1, 10, 15, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+	s.Write(context.Background(), []*models.Log{
+		{Message: "a", Level: "info", Timestamp: first},
+		{Message: "b", Level: "info", Timestamp: second},
+	})
+	if len(putter.objects) != 0 {
+		t.Fatalf("Expected no upload before the batch is full, got %d", len(putter.objects))
+	}
+
+	if err := s.Write(context.Background(), []*models.Log{{Message: "c", Level: "info", Timestamp: first}}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(putter.objects) != 2 {
+		t.Fatalf("Expected one object per hour partition, got %d", len(putter.objects))
+	}
+
+	for key, body := range putter.objects {
+		entries := readObject(t, body)
+		switch {
+		case strings.HasPrefix(key, "logs/dt=2024-03-01/hour=10/") && strings.HasSuffix(key, ".ndjson.gz"):
+			if len(entries) != 2 || entries[1].Message != "c" {
+				t.Errorf("Expected entries a and c in hour 10, got %+v", entries)
+			}
+		case strings.HasPrefix(key, "logs/dt=2024-03-01/hour=11/"):
+			if len(entries) != 1 || entries[0].Message != "b" {
+				t.Errorf("Expected entry b in hour 11, got %+v", entries)
+			}
+		default:
+			t.Errorf("Unexpected object key %s", key)
+		}
+	}
+}
+
+func TestS3Sink_RetriesAndCloses(t *testing.T) {
+	s, putter := newTestS3Sink(t, `{"bucket":"archive","region":"us-east-1","batch_size":2,"max_buffered":3,"batch_interval":"1h"}`)
+	putter.fail = true
+
+	now := time.Now()
+	if err := s.Write(context.Background(), []*models.Log{{Message: "a", Timestamp: now}, {Message: "b", Timestamp: now}}); err == nil {
+		t.Errorf("Expected failed upload to be reported")
+	}
+	if err := s.Write(context.Background(), []*models.Log{{Message: "c", Timestamp: now}, {Message: "d", Timestamp: now}}); !errors.Is(err, ErrBufferFull) {
+		t.Errorf("Expected full buffer error, got %v", err)
+	}
+
+	putter.fail = false
+	if err := s.Close(); err != nil {
+		t.Fatalf("Expected buffered entries to be uploaded on close, got %v", err)
+	}
+	if len(putter.objects) != 1 {
+		t.Fatalf("Expected one object, got %d", len(putter.objects))
+	}
+	for _, body := range putter.objects {
+		if entries := readObject(t, body); len(entries) != 2 {
+			t.Errorf("Expected the two retried entries, got %+v", entries)
+		}
+	}
+}
+
+func TestS3Sink_InvalidConfig(t *testing.T) {
+	configs := []string{
+		`{}`,
+		`{"bucket":"b","format":"parquet"}`,
+		`{"bucket":"b","compression":"brotli"}`,
+		`{"bucket":"b","batch_size":-1}`,
+		`{"bucket":"b","batch_size":10,"max_buffered":5}`,
+		`{"bucket":"b","batch_interval":"soon"}`,
+	}
+	for _, cfg := range configs {
+		if _, err := newS3Sink([]byte(cfg)); err == nil {
+			t.Errorf("Expected error for config %s", cfg)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Package sink writes processed log entries to outputs other than the logs
+// table, such as a SIEM webhook. The pipeline's route stage sends entries to
+// sinks built from declarative configuration.
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+// sinkLogger is for sinks that send in the background, outside of Write
+var sinkLogger = logger.NewFromEnv("log-ingestion", "sink")
+
+// ErrBufferFull is returned by a batching sink that cannot send its batches
+// and holds as many entries as it may buffer
+var ErrBufferFull = errors.New("sink buffer is full")
+
+// Sink receives processed entries. Write may buffer entries and send them
+// later; Close sends anything still buffered and releases the sink.
+type Sink interface {
+	Write(ctx context.Context, entries []*models.Log) error
+	Close() error
+}
+
+// Flusher is implemented by sinks that buffer entries. Flush sends what is
+// buffered now rather than when the batch fills or its interval passes.
+type Flusher interface {
+	Flush() error
+}
+
+// Buffering is implemented by sinks that buffer entries and reports how many
+// are waiting to be sent
+type Buffering interface {
+	Buffered() int
+}
+
+// Config declares one sink
+type Config struct {
+	Type   string          `json:"type"`
+	Config json.RawMessage `json:"config,omitempty"`
+}
+
+// Factory builds a sink from its type-specific configuration
+type Factory func(config json.RawMessage) (Sink, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a sink type available to declarative configuration.
+// It panics if the type is registered twice.
+func Register(sinkType string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := registry[sinkType]; exists {
+		panic(fmt.Sprintf("sink: type %q registered twice", sinkType))
+	}
+	registry[sinkType] = factory
+}
+
+// Types returns the registered sink types
+func Types() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	types := make([]string, 0, len(registry))
+	for t := range registry {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Build constructs a sink from its declarative configuration
+func Build(cfg Config) (Sink, error) {
+	if cfg.Type == "" {
+		return nil, fmt.Errorf("sink type is required")
+	}
+
+	registryMu.RLock()
+	factory, ok := registry[cfg.Type]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown sink type %q", cfg.Type)
+	}
+
+	s, err := factory(cfg.Config)
+	if err != nil {
+		return nil, fmt.Errorf("sink %q: %w", cfg.Type, err)
+	}
+	return s, nil
+}
+
+// decodeConfig unmarshals sink configuration, treating a missing config as empty
+func decodeConfig(raw json.RawMessage, v interface{}) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("invalid sink config: %w", err)
+	}
+	return nil
+}
+
+// positiveDuration parses a duration setting that must be above zero
+func positiveDuration(name, value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, value)
+	}
+	return d, nil
+}
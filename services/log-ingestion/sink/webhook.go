@@ -0,0 +1,93 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func init() {
+	Register("webhook", newWebhookSink)
+}
+
+// webhookConfig posts each write as a JSON array of entries to url
+type webhookConfig struct {
+	URL     string            `json:"url"`
+	Method  string            `json:"method"`
+	Headers map[string]string `json:"headers"`
+	Timeout string            `json:"timeout"`
+}
+
+type webhookSink struct {
+	url     string
+	method  string
+	headers map[string]string
+	client  *http.Client
+}
+
+func newWebhookSink(raw json.RawMessage) (Sink, error) {
+	cfg := webhookConfig{Method: http.MethodPost, Timeout: "10s"}
+	if err := decodeConfig(raw, &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.URL == "" {
+		return nil, errors.New("webhook requires a url")
+	}
+	if u, err := url.Parse(cfg.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid webhook url %q", cfg.URL)
+	}
+	timeout, err := time.ParseDuration(cfg.Timeout)
+	if err != nil || timeout <= 0 {
+		return nil, fmt.Errorf("invalid timeout %q", cfg.Timeout)
+	}
+
+	return &webhookSink{
+		url:     cfg.URL,
+		method:  cfg.Method,
+		headers: cfg.Headers,
+		client:  &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func (s *webhookSink) Write(ctx context.Context, entries []*models.Log) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, s.method, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range s.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *webhookSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
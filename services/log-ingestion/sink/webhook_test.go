@@ -0,0 +1,67 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func TestWebhookSink_Write(t *testing.T) {
+	var received []models.Log
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	s, err := Build(Config{Type: "webhook", Config: []byte(`{"url":"` + server.URL + `","headers":{"Authorization":"Bearer token"}}`)})
+	if err != nil {
+		t.Fatalf("Failed to build sink: %v", err)
+	}
+	defer s.Close()
+
+	entries := []*models.Log{
+		{Message: "Login failed", Level: "warn", Source: "auth"},
+		{Message: "Account locked", Level: "error", Source: "auth"},
+	}
+	if err := s.Write(context.Background(), entries); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(received) != 2 || received[1].Message != "Account locked" {
+		t.Errorf("Expected both entries to be posted, got %+v", received)
+	}
+	if auth != "Bearer token" {
+		t.Errorf("Expected configured header to be sent, got %q", auth)
+	}
+}
+
+func TestWebhookSink_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	s, _ := Build(Config{Type: "webhook", Config: []byte(`{"url":"` + server.URL + `"}`)})
+	if err := s.Write(context.Background(), []*models.Log{{Message: "m", Level: "info"}}); err == nil {
+		t.Errorf("Expected error for 503 response")
+	}
+}
+
+func TestBuild_InvalidConfig(t *testing.T) {
+	configs := []Config{
+		{},
+		{Type: "unknown"},
+		{Type: "webhook"},
+		{Type: "webhook", Config: []byte(`{"url":"ftp://example.com"}`)},
+		{Type: "webhook", Config: []byte(`{"url":"http://example.com","timeout":"soon"}`)},
+	}
+	for _, cfg := range configs {
+		if _, err := Build(cfg); err == nil {
+			t.Errorf("Expected error for config %+v", cfg)
+		}
+	}
+}
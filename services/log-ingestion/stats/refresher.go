@@ -0,0 +1,62 @@
+package stats
+
+import (
+	"context"
+	"time"
+	"log-processing-system/services/log-ingestion/database"
+	"log-processing-system/services/log-ingestion/logger"
+)
+
+// Refresher keeps the per-minute stats table up to date with newly stored logs
+type Refresher struct {
+	interval time.Duration
+	refresh  func() (int64, error)
+	logger   *logger.Logger
+}
+
+// NewRefresher creates a refresher backed by database.RefreshLogStats
+func NewRefresher(interval time.Duration, log *logger.Logger) *Refresher {
+	return &Refresher{
+		interval: interval,
+		refresh:  database.RefreshLogStats,
+		logger:   log,
+	}
+}
+
+// Start refreshes the stats every interval until ctx is cancelled
+func (r *Refresher) Start(ctx context.Context) {
+	r.logger.WithField("interval", r.interval.String()).Info("Log stats refresher started")
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.logger.Info("Log stats refresher stopped")
+			return
+		case <-ticker.C:
+			r.RunOnce()
+		}
+	}
+}
+
+// RunOnce folds newly stored entries into the stats table
+func (r *Refresher) RunOnce() error {
+	start := time.Now()
+
+	rows, err := r.refresh()
+	if err != nil {
+		r.logger.WithError(err).Error("Log stats refresh failed")
+		return err
+	}
+
+	if rows > 0 {
+		r.logger.WithFields(map[string]interface{}{
+			"rows_updated": rows,
+			"duration_ms":  time.Since(start).Milliseconds(),
+		}).Debug("Log stats refreshed")
+	}
+
+	return nil
+}
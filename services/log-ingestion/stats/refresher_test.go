@@ -0,0 +1,59 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/logger"
+)
+
+func newTestRefresher(interval time.Duration, refresh func() (int64, error)) *Refresher {
+	r := NewRefresher(interval, logger.New(logger.Config{Level: "ERROR", Service: "test-service", Component: "stats"}))
+	r.refresh = refresh
+	return r
+}
+
+func TestRefresher_RunOnce(t *testing.T) {
+	r := newTestRefresher(time.Minute, func() (int64, error) { return 3, nil })
+	if err := r.RunOnce(); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	failure := errors.New("database error")
+	r = newTestRefresher(time.Minute, func() (int64, error) { return 0, failure })
+	if err := r.RunOnce(); err != failure {
+		t.Errorf("Expected the refresh error, got %v", err)
+	}
+}
+
+func TestRefresher_Start(t *testing.T) {
+	var calls int32
+	r := newTestRefresher(5*time.Millisecond, func() (int64, error) {
+		atomic.AddInt32(&calls, 1)
+		return 0, errors.New("database error")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Start(ctx)
+		close(done)
+	}()
+
+	// A failed refresh is retried on the next tick
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&calls) < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the refresher to stop when its context is cancelled")
+	}
+	if n := atomic.LoadInt32(&calls); n < 3 {
+		t.Errorf("Expected a refresh every interval, got %d", n)
+	}
+}
@@ -0,0 +1,226 @@
+// Package throttle enforces per-source ingest budgets so a single flooding
+// source cannot starve the others.
+package throttle
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"log-processing-system/services/log-ingestion/logger"
+)
+
+// Action is what happens to an entry under its source's budget
+type Action string
+
+const (
+	Accept Action = "accept" // within budget, or kept by sampling
+	Sample Action = "sample" // over budget and dropped by sampling
+	Reject Action = "reject" // far over budget, the client must back off
+)
+
+// Config tunes the limiter
+type Config struct {
+	Rate          float64            // entries per second each source may ingest
+	SourceRates   map[string]float64 // per-source overrides of Rate
+	SampleUntil   float64            // multiple of the budget up to which excess is sampled rather than rejected
+	Window        time.Duration      // length of the sliding window rates are measured over
+	ProtectLevels []string           // levels sampling never drops
+}
+
+// Decision is the verdict for one entry
+type Decision struct {
+	Action     Action
+	Limit      float64       // the source's budget in entries per second
+	Rate       float64       // the source's measured rate in entries per second
+	SampleRate float64       // entries each kept entry stands for while sampling, 0 otherwise
+	RetryAfter time.Duration // when a rejected client may retry
+}
+
+// SourceState is a snapshot of one source the limiter is tracking
+type SourceState struct {
+	Source string  `json:"source"`
+	Action Action  `json:"action"`
+	Rate   float64 `json:"rate"`  // measured entries per second
+	Limit  float64 `json:"limit"` // budget in entries per second
+}
+
+type sourceState struct {
+	current  float64
+	previous float64
+	credit   float64
+	action   Action
+}
+
+// Limiter measures each source's ingest rate over a sliding window. Within
+// budget every entry is accepted; above it entries are sampled down to the
+// budget, sparing protected levels; beyond SampleUntil times the budget they
+// are rejected until the rate falls again. Every arrival counts towards the
+// rate, so a source must back off to get out of rejection.
+type Limiter struct {
+	cfg     Config
+	protect map[string]bool
+	logger  *logger.Logger
+	now     func() time.Time
+
+	mu          sync.Mutex
+	sources     map[string]*sourceState
+	windowStart time.Time
+}
+
+// NewLimiter creates a limiter; zero config values fall back to defaults
+func NewLimiter(cfg Config, log *logger.Logger) *Limiter {
+	if cfg.Rate <= 0 {
+		cfg.Rate = 100
+	}
+	if cfg.SampleUntil < 1 {
+		cfg.SampleUntil = 5
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = 10 * time.Second
+	}
+
+	protect := make(map[string]bool)
+	for _, level := range cfg.ProtectLevels {
+		protect[strings.ToLower(level)] = true
+	}
+
+	return &Limiter{
+		cfg:         cfg,
+		protect:     protect,
+		logger:      log,
+		now:         time.Now,
+		sources:     make(map[string]*sourceState),
+		windowStart: time.Now(),
+	}
+}
+
+// Check counts an entry from source and decides what happens to it
+func (l *Limiter) Check(source, level string) Decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.roll(now)
+
+	s, ok := l.sources[source]
+	if !ok {
+		s = &sourceState{action: Accept}
+		l.sources[source] = s
+	}
+	s.current++
+
+	// The previous window is weighted by how much of it still overlaps the
+	// sliding window ending now
+	elapsed := now.Sub(l.windowStart)
+	overlap := 1 - elapsed.Seconds()/l.cfg.Window.Seconds()
+	rate := (s.previous*overlap + s.current) / l.cfg.Window.Seconds()
+
+	limit := l.cfg.Rate
+	if override, ok := l.cfg.SourceRates[source]; ok {
+		limit = override
+	}
+
+	decision := Decision{Action: Accept, Limit: limit, Rate: rate}
+	switch {
+	case rate <= limit:
+		s.credit = 0
+	case rate > limit*l.cfg.SampleUntil:
+		decision.Action = Reject
+		decision.RetryAfter = l.cfg.Window - elapsed
+		if decision.RetryAfter < time.Second {
+			decision.RetryAfter = time.Second
+		}
+	case l.protect[strings.ToLower(level)]:
+	default:
+		// Keep limit/rate of the entries, spread evenly
+		s.credit += limit / rate
+		if s.credit >= 1 {
+			s.credit--
+			decision.SampleRate = rate / limit
+		} else {
+			decision.Action = Sample
+		}
+	}
+
+	l.transition(source, s, decision)
+	return decision
+}
+
+// transition logs when a source moves between accepting, sampling and
+// rejection, rather than for every throttled entry
+func (l *Limiter) transition(source string, s *sourceState, decision Decision) {
+	action := decision.Action
+	if action == Accept && decision.SampleRate > 0 {
+		action = Sample
+	}
+	if action == s.action || (action == Accept && decision.Rate > decision.Limit) {
+		return
+	}
+	s.action = action
+
+	entry := l.logger.WithFields(map[string]interface{}{
+		"source": source,
+		"action": string(action),
+		"rate":   math.Round(decision.Rate*100) / 100,
+		"limit":  decision.Limit,
+	})
+	if action == Accept {
+		entry.Info("Source back within its ingest budget")
+		return
+	}
+	entry.Warn("Source exceeded its ingest budget")
+}
+
+// roll starts a new window once the current one is over and forgets sources
+// that were idle for both windows; callers must hold l.mu
+func (l *Limiter) roll(now time.Time) {
+	elapsed := now.Sub(l.windowStart)
+	if elapsed < l.cfg.Window {
+		return
+	}
+
+	windows := elapsed / l.cfg.Window
+	l.windowStart = l.windowStart.Add(windows * l.cfg.Window)
+	for source, s := range l.sources {
+		s.previous = s.current
+		if windows > 1 {
+			s.previous = 0
+		}
+		s.current = 0
+		if s.previous == 0 {
+			if s.action != Accept {
+				l.logger.WithField("source", source).Info("Source back within its ingest budget")
+			}
+			delete(l.sources, source)
+		}
+	}
+}
+
+// State returns the sources active in the current or previous window, sorted
+// by source, with their measured rate and budget
+func (l *Limiter) State() []SourceState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.roll(now)
+	overlap := 1 - now.Sub(l.windowStart).Seconds()/l.cfg.Window.Seconds()
+
+	states := make([]SourceState, 0, len(l.sources))
+	for source, s := range l.sources {
+		limit := l.cfg.Rate
+		if override, ok := l.cfg.SourceRates[source]; ok {
+			limit = override
+		}
+		states = append(states, SourceState{
+			Source: source,
+			Action: s.action,
+			Rate:   math.Round((s.previous*overlap+s.current)/l.cfg.Window.Seconds()*100) / 100,
+			Limit:  limit,
+		})
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Source < states[j].Source })
+	return states
+}
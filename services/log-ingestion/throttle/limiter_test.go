@@ -0,0 +1,122 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/logger"
+)
+
+// newTestLimiter returns a limiter on a fake clock advanced by the caller
+func newTestLimiter(cfg Config) (*Limiter, *time.Time) {
+	l := NewLimiter(cfg, logger.New(logger.Config{Service: "test-service", Component: "throttle"}))
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	l.windowStart = now
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+// send checks n entries spread evenly over d and counts the actions
+func send(l *Limiter, now *time.Time, source, level string, n int, d time.Duration) map[Action]int {
+	counts := make(map[Action]int)
+	for i := 0; i < n; i++ {
+		counts[l.Check(source, level).Action]++
+		*now = now.Add(d / time.Duration(n))
+	}
+	return counts
+}
+
+func TestLimiter_AcceptsWithinBudget(t *testing.T) {
+	l, now := newTestLimiter(Config{Rate: 10, Window: 10 * time.Second})
+
+	counts := send(l, now, "api", "info", 250, 30*time.Second)
+	if counts[Accept] != 250 {
+		t.Errorf("Expected every entry to be accepted, got %v", counts)
+	}
+}
+
+func TestLimiter_SamplesDownToBudget(t *testing.T) {
+	l, now := newTestLimiter(Config{Rate: 10, SampleUntil: 5, Window: 10 * time.Second})
+
+	// Warm up at 3x the budget so the sliding window is full
+	send(l, now, "flood", "info", 300, 10*time.Second)
+	counts := send(l, now, "flood", "info", 300, 10*time.Second)
+	if counts[Reject] != 0 {
+		t.Fatalf("Expected no rejections below the sampling ceiling, got %v", counts)
+	}
+	if counts[Accept] < 90 || counts[Accept] > 110 {
+		t.Errorf("Expected about 100 entries kept, got %v", counts)
+	}
+
+	decision := l.Check("flood", "info")
+	for decision.SampleRate == 0 {
+		decision = l.Check("flood", "info")
+	}
+	if decision.SampleRate < 2.5 || decision.SampleRate > 3.5 {
+		t.Errorf("Expected kept entries to stand for about 3 entries, got %v", decision.SampleRate)
+	}
+}
+
+func TestLimiter_ProtectsLevelsWhileSampling(t *testing.T) {
+	l, now := newTestLimiter(Config{Rate: 10, Window: 10 * time.Second, ProtectLevels: []string{"error"}})
+
+	send(l, now, "flood", "info", 300, 10*time.Second)
+	counts := send(l, now, "flood", "ERROR", 200, 10*time.Second)
+	if counts[Accept] != 200 {
+		t.Errorf("Expected protected entries to be kept, got %v", counts)
+	}
+}
+
+func TestLimiter_RejectsFarOverBudget(t *testing.T) {
+	l, now := newTestLimiter(Config{Rate: 10, SampleUntil: 2, Window: 10 * time.Second})
+
+	send(l, now, "flood", "error", 500, 5*time.Second)
+	decision := l.Check("flood", "error")
+	if decision.Action != Reject {
+		t.Fatalf("Expected rejection, got %+v", decision)
+	}
+	if decision.RetryAfter != 5*time.Second {
+		t.Errorf("Expected retry after the window ends, got %v", decision.RetryAfter)
+	}
+	if decision.Limit != 10 {
+		t.Errorf("Expected limit 10, got %v", decision.Limit)
+	}
+
+	// Other sources keep their own budget
+	if d := l.Check("quiet", "info"); d.Action != Accept || d.SampleRate != 0 {
+		t.Errorf("Expected another source to be accepted, got %+v", d)
+	}
+
+	// Once the source goes quiet it is forgotten and accepted again
+	*now = now.Add(30 * time.Second)
+	if d := l.Check("flood", "info"); d.Action != Accept {
+		t.Errorf("Expected the source to recover, got %+v", d)
+	}
+}
+
+func TestLimiter_SourceRates(t *testing.T) {
+	l, now := newTestLimiter(Config{Rate: 10, SourceRates: map[string]float64{"bulk": 100}, Window: 10 * time.Second})
+
+	counts := send(l, now, "bulk", "info", 900, 10*time.Second)
+	if counts[Accept] != 900 {
+		t.Errorf("Expected the override to admit 90 entries/s, got %v", counts)
+	}
+}
+
+func TestLimiter_State(t *testing.T) {
+	l, now := newTestLimiter(Config{Rate: 100, SourceRates: map[string]float64{"flood": 10}, Window: 10 * time.Second})
+
+	send(l, now, "flood", "info", 300, 10*time.Second)
+	send(l, now, "api", "info", 5, time.Second)
+
+	states := l.State()
+	if len(states) != 2 || states[0].Source != "api" || states[1].Source != "flood" {
+		t.Fatalf("Expected both sources sorted by name, got %+v", states)
+	}
+	flood := states[1]
+	if flood.Action != Sample || flood.Limit != 10 || flood.Rate < 25 || flood.Rate > 35 {
+		t.Errorf("Expected flood sampled at about 30/s against 10/s, got %+v", flood)
+	}
+	if states[0].Action != Accept || states[0].Limit != 100 {
+		t.Errorf("Expected api accepted with the default budget, got %+v", states[0])
+	}
+}
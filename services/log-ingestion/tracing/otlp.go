@@ -0,0 +1,272 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Defaults of the exporter
+const (
+	DefaultBatchSize     = 512
+	DefaultFlushInterval = 5 * time.Second
+	exportTimeout        = 10 * time.Second
+	scopeName            = "log-processing-system/tracing"
+)
+
+// OTLP span status codes
+const (
+	statusOK    = 1
+	statusError = 2
+)
+
+// exporter batches ended spans in the background and posts them to the
+// collector. Spans ending while its queue is full are dropped, so an
+// unreachable collector never slows requests down.
+type exporter struct {
+	url      string
+	headers  map[string]string
+	service  string
+	batch    int
+	interval time.Duration
+	client   *http.Client
+
+	spans   chan spanRecord
+	flushes chan chan struct{}
+	dropped uint64
+}
+
+func newExporter(cfg Config) *exporter {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = DefaultFlushInterval
+	}
+
+	e := &exporter{
+		url:      tracesURL(cfg.Endpoint),
+		headers:  cfg.Headers,
+		service:  cfg.Service,
+		batch:    cfg.BatchSize,
+		interval: cfg.FlushInterval,
+		client:   &http.Client{Timeout: exportTimeout},
+		spans:    make(chan spanRecord, cfg.BatchSize*4),
+		flushes:  make(chan chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// tracesURL adds the OTLP/HTTP traces path to an endpoint without a path
+func tracesURL(endpoint string) string {
+	endpoint = strings.TrimRight(endpoint, "/")
+	if i := strings.Index(endpoint, "://"); i >= 0 && !strings.Contains(endpoint[i+3:], "/") {
+		return endpoint + "/v1/traces"
+	}
+	return endpoint
+}
+
+// export queues a span without waiting
+func (e *exporter) export(span spanRecord) {
+	select {
+	case e.spans <- span:
+	default:
+		atomic.AddUint64(&e.dropped, 1)
+	}
+}
+
+// Flush waits until the spans queued so far have been sent
+func (e *exporter) Flush() {
+	done := make(chan struct{})
+	e.flushes <- done
+	<-done
+}
+
+func (e *exporter) run() {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	pending := make([]spanRecord, 0, e.batch)
+	send := func() {
+		if len(pending) > 0 {
+			e.send(pending)
+			pending = pending[:0]
+		}
+	}
+
+	for {
+		select {
+		case span := <-e.spans:
+			pending = append(pending, span)
+			if len(pending) >= e.batch {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case done := <-e.flushes:
+			// Take what was queued before the flush was asked for
+			for n := len(e.spans); n > 0; n-- {
+				pending = append(pending, <-e.spans)
+				if len(pending) >= e.batch {
+					send()
+				}
+			}
+			send()
+			close(done)
+		}
+	}
+}
+
+// send posts a batch. Failures go to stderr, like those of the logger's
+// OTLP export.
+func (e *exporter) send(spans []spanRecord) {
+	if dropped := atomic.SwapUint64(&e.dropped, 0); dropped > 0 {
+		fmt.Fprintf(os.Stderr, "tracing: dropped %d spans: export queue full\n", dropped)
+	}
+
+	body, err := json.Marshal(e.request(spans))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tracing: failed to encode %d spans: %v\n", len(spans), err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tracing: invalid endpoint %q: %v\n", e.url, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range e.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tracing: failed to export %d spans: %v\n", len(spans), err)
+		return
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		fmt.Fprintf(os.Stderr, "tracing: collector rejected %d spans with status %d\n", len(spans), resp.StatusCode)
+	}
+}
+
+// OTLP/JSON messages, as defined by the OpenTelemetry protocol's
+// ExportTraceServiceRequest
+type exportRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scopeSpans struct {
+	Scope scopeInfo    `json:"scope"`
+	Spans []spanRecord `json:"spans"`
+}
+
+type scopeInfo struct {
+	Name string `json:"name"`
+}
+
+type spanRecord struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	Status            spanStatus `json:"status"`
+}
+
+type spanStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func (e *exporter) request(spans []spanRecord) exportRequest {
+	service := e.service
+	return exportRequest{ResourceSpans: []resourceSpans{{
+		Resource:   resource{Attributes: []keyValue{{Key: "service.name", Value: anyValue{StringValue: &service}}}},
+		ScopeSpans: []scopeSpans{{Scope: scopeInfo{Name: scopeName}, Spans: spans}},
+	}}}
+}
+
+// attribute converts a value as the logger's OTLP export converts fields
+func attribute(key string, value interface{}) keyValue {
+	var v anyValue
+	switch x := value.(type) {
+	case bool:
+		v.BoolValue = &x
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		s := fmt.Sprint(x)
+		v.IntValue = &s
+	case float32:
+		f := float64(x)
+		v.DoubleValue = &f
+	case float64:
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			// JSON has no NaN or infinities
+			s := fmt.Sprint(x)
+			v.StringValue = &s
+			break
+		}
+		v.DoubleValue = &x
+	default:
+		s := fmt.Sprint(value)
+		v.StringValue = &s
+	}
+	return keyValue{Key: key, Value: v}
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// parseHeaders parses headers written as key=value pairs separated by
+// commas, as in OTEL_EXPORTER_OTLP_HEADERS
+func parseHeaders(value string) map[string]string {
+	headers := make(map[string]string)
+	for _, item := range strings.Split(value, ",") {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) == 2 && strings.TrimSpace(parts[0]) != "" {
+			headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+	return headers
+}
@@ -0,0 +1,194 @@
+// Package tracing records spans of the work done for a request, such as its
+// database operations, and exports them to an OpenTelemetry collector over
+// OTLP/HTTP with JSON encoding, as the logger does with log entries. Spans
+// belong to the W3C trace the logging middleware continues or starts, so
+// they line up with the caller's trace and with the request's log entries.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"log-processing-system/services/log-ingestion/logger"
+)
+
+// Span kinds, numbered as in OTLP
+const (
+	KindInternal = 1
+	KindServer   = 2
+	KindClient   = 3
+)
+
+// Config configures span export. An empty Endpoint disables tracing.
+type Config struct {
+	// Endpoint is the collector's traces URL, e.g.
+	// http://otel-collector:4318/v1/traces; /v1/traces is added to a URL
+	// without a path
+	Endpoint string
+	Headers  map[string]string
+	Service  string
+	// BatchSize spans are sent together, and a partial batch after
+	// FlushInterval
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+// ConfigFromEnv reads the standard OpenTelemetry variables: the traces
+// endpoint, or the collector's base URL, and the headers. OTEL_TRACES_EXPORTER
+// set to none turns export off while logs are still exported.
+func ConfigFromEnv(service string) Config {
+	cfg := Config{Service: service}
+	if os.Getenv("OTEL_TRACES_EXPORTER") == "none" {
+		return cfg
+	}
+	cfg.Endpoint = os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); cfg.Endpoint == "" && base != "" {
+		cfg.Endpoint = strings.TrimRight(base, "/") + "/v1/traces"
+	}
+	cfg.Headers = parseHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+	return cfg
+}
+
+var (
+	exporterMu     sync.RWMutex
+	activeExporter *exporter
+)
+
+// Init starts exporting spans as cfg says; without an endpoint spans are
+// not recorded at all. Call it once, before serving requests.
+func Init(cfg Config) {
+	exporterMu.Lock()
+	defer exporterMu.Unlock()
+	if cfg.Endpoint == "" {
+		activeExporter = nil
+		return
+	}
+	activeExporter = newExporter(cfg)
+}
+
+// Flush waits until the spans ended so far have been sent
+func Flush() {
+	if e := currentExporter(); e != nil {
+		e.Flush()
+	}
+}
+
+func currentExporter() *exporter {
+	exporterMu.RLock()
+	defer exporterMu.RUnlock()
+	return activeExporter
+}
+
+// Span is an operation within a trace. A nil Span, returned when the work is
+// not traced, ignores every call, so callers need not check.
+type Span struct {
+	exporter *exporter
+	traceID  string
+	spanID   string
+	parentID string
+	name     string
+	kind     int
+	start    time.Time
+
+	mu    sync.Mutex
+	attrs []keyValue
+	ended bool
+}
+
+type spanKey struct{}
+
+// StartServer starts the span of an incoming request with the IDs the
+// logging middleware gave it; parentID is the caller's span, empty for a new
+// trace. Unsampled traces are not recorded.
+func StartServer(ctx context.Context, name, traceID, spanID, parentID string, sampled bool) (context.Context, *Span) {
+	e := currentExporter()
+	if e == nil || !sampled {
+		return ctx, nil
+	}
+	span := &Span{
+		exporter: e,
+		traceID:  traceID,
+		spanID:   spanID,
+		parentID: parentID,
+		name:     name,
+		kind:     KindServer,
+		start:    time.Now(),
+	}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// Start starts a child of the span in ctx. Work outside a traced request is
+// not recorded. Entries logged with the returned context carry the new
+// span's ID.
+func Start(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	parent, ok := ctx.Value(spanKey{}).(*Span)
+	if !ok || parent == nil {
+		return ctx, nil
+	}
+	span := &Span{
+		exporter: parent.exporter,
+		traceID:  parent.traceID,
+		spanID:   newSpanID(),
+		parentID: parent.spanID,
+		name:     name,
+		kind:     kind,
+		start:    time.Now(),
+	}
+	ctx = logger.WithSpanID(context.WithValue(ctx, spanKey{}, span), span.spanID)
+	return ctx, span
+}
+
+// SetAttribute records an attribute; booleans, integers and floats keep
+// their type, anything else is written as text
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs = append(s.attrs, attribute(key, value))
+}
+
+// End finishes the span and queues it for export; a non-nil err marks it
+// failed. Calls after the first are ignored.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	record := spanRecord{
+		TraceID:           s.traceID,
+		SpanID:            s.spanID,
+		ParentSpanID:      s.parentID,
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: unixNano(s.start),
+		EndTimeUnixNano:   unixNano(time.Now()),
+		Attributes:        s.attrs,
+		Status:            spanStatus{Code: statusOK},
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		record.Status = spanStatus{Code: statusError, Message: err.Error()}
+	}
+	s.exporter.export(record)
+}
+
+// newSpanID returns 8 random bytes as lowercase hex, never all zeros
+func newSpanID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil || strings.Trim(hex.EncodeToString(b), "0") == "" {
+		b[7] = 1
+	}
+	return hex.EncodeToString(b)
+}
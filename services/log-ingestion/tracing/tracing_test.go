@@ -0,0 +1,108 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"log-processing-system/services/log-ingestion/logger"
+)
+
+// collector records the spans posted to it
+type collector struct {
+	mu    sync.Mutex
+	spans []spanRecord
+	paths []string
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req exportRequest
+	json.NewDecoder(r.Body).Decode(&req)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paths = append(c.paths, r.URL.Path)
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			c.spans = append(c.spans, ss.Spans...)
+		}
+	}
+}
+
+func newTestCollector(t *testing.T) *collector {
+	c := &collector{}
+	server := httptest.NewServer(c)
+	t.Cleanup(server.Close)
+	Init(Config{Endpoint: server.URL, Service: "test-service"})
+	t.Cleanup(func() { Init(Config{}) })
+	return c
+}
+
+func TestSpans_Exported(t *testing.T) {
+	c := newTestCollector(t)
+
+	ctx, server := StartServer(context.Background(), "POST /ingest", "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", "b7ad6b7169203331", true)
+	childCtx, child := Start(ctx, "INSERT logs", KindClient)
+	child.SetAttribute("db.sql.table", "logs")
+	child.SetAttribute("db.rows_affected", int64(1))
+	child.End(errors.New("connection reset"))
+	server.End(nil)
+	Flush()
+
+	if len(c.paths) != 1 || c.paths[0] != "/v1/traces" {
+		t.Errorf("Expected one export to /v1/traces, got %v", c.paths)
+	}
+	if len(c.spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %+v", c.spans)
+	}
+	db, request := c.spans[0], c.spans[1]
+	if request.SpanID != "00f067aa0ba902b7" || request.ParentSpanID != "b7ad6b7169203331" || request.Kind != KindServer {
+		t.Errorf("Expected the request span with the middleware's IDs, got %+v", request)
+	}
+	if db.TraceID != request.TraceID || db.ParentSpanID != request.SpanID || db.Kind != KindClient {
+		t.Errorf("Expected the database span to be a child of the request, got %+v", db)
+	}
+	if db.Status.Code != statusError || db.Status.Message != "connection reset" || request.Status.Code != statusOK {
+		t.Errorf("Expected the failed operation marked as an error, got %+v and %+v", db.Status, request.Status)
+	}
+	if len(db.Attributes) != 2 || *db.Attributes[1].Value.IntValue != "1" {
+		t.Errorf("Expected typed attributes, got %+v", db.Attributes)
+	}
+	if spanID := logger.GetSpanID(childCtx); spanID != db.SpanID {
+		t.Errorf("Expected entries logged in the span to carry its ID, got %q", spanID)
+	}
+}
+
+func TestSpans_NotRecorded(t *testing.T) {
+	c := newTestCollector(t)
+
+	// Outside a request, and in a trace the caller did not sample
+	_, span := Start(context.Background(), "INSERT logs", KindClient)
+	span.SetAttribute("db.sql.table", "logs")
+	span.End(nil)
+	ctx, server := StartServer(context.Background(), "POST /ingest", "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", "", false)
+	_, child := Start(ctx, "INSERT logs", KindClient)
+	child.End(nil)
+	server.End(nil)
+	Flush()
+
+	if span != nil || server != nil || child != nil || len(c.spans) != 0 {
+		t.Errorf("Expected nothing recorded, got %+v", c.spans)
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://otel-collector:4318/")
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "x-api-key=secret")
+	cfg := ConfigFromEnv("log-ingestion")
+	if cfg.Endpoint != "http://otel-collector:4318/v1/traces" || cfg.Headers["x-api-key"] != "secret" {
+		t.Errorf("Expected the traces endpoint below the base URL, got %+v", cfg)
+	}
+
+	t.Setenv("OTEL_TRACES_EXPORTER", "none")
+	if cfg := ConfigFromEnv("log-ingestion"); cfg.Endpoint != "" {
+		t.Errorf("Expected export turned off, got %q", cfg.Endpoint)
+	}
+}
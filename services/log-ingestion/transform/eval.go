@@ -0,0 +1,307 @@
+package transform
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+type expr interface {
+	eval(entry *models.Log) (interface{}, error)
+}
+
+type literal struct {
+	value interface{}
+}
+
+func (e literal) eval(entry *models.Log) (interface{}, error) {
+	return e.value, nil
+}
+
+type pathExpr struct {
+	path path
+}
+
+// eval yields null for missing fields so conditions on them are simply false
+func (e pathExpr) eval(entry *models.Log) (interface{}, error) {
+	value, _ := e.path.get(entry)
+	return value, nil
+}
+
+// addExpr adds numbers and concatenates anything else as strings
+type addExpr struct {
+	left, right expr
+}
+
+func (e addExpr) eval(entry *models.Log) (interface{}, error) {
+	left, err := e.left.eval(entry)
+	if err != nil {
+		return nil, err
+	}
+	right, err := e.right.eval(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	if l, ok := left.(int64); ok {
+		if r, ok := right.(int64); ok {
+			return l + r, nil
+		}
+	}
+	if l, ok := toNumber(left); ok {
+		if r, ok := toNumber(right); ok {
+			return l + r, nil
+		}
+	}
+	return toString(left) + toString(right), nil
+}
+
+type logicalExpr struct {
+	op          string
+	left, right expr
+}
+
+func (e logicalExpr) eval(entry *models.Log) (interface{}, error) {
+	left, err := e.left.eval(entry)
+	if err != nil {
+		return nil, err
+	}
+	if e.op == "and" && !truthy(left) {
+		return false, nil
+	}
+	if e.op == "or" && truthy(left) {
+		return true, nil
+	}
+
+	right, err := e.right.eval(entry)
+	if err != nil {
+		return nil, err
+	}
+	return truthy(right), nil
+}
+
+type notExpr struct {
+	inner expr
+}
+
+func (e notExpr) eval(entry *models.Log) (interface{}, error) {
+	value, err := e.inner.eval(entry)
+	if err != nil {
+		return nil, err
+	}
+	return !truthy(value), nil
+}
+
+type compareExpr struct {
+	op          string
+	left, right expr
+	re          *regexp.Regexp
+}
+
+func (e compareExpr) eval(entry *models.Log) (interface{}, error) {
+	left, err := e.left.eval(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.re != nil {
+		return left != nil && e.re.MatchString(toString(left)), nil
+	}
+
+	right, err := e.right.eval(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.op {
+	case "contains":
+		return left != nil && strings.Contains(toString(left), toString(right)), nil
+	case "startswith":
+		return left != nil && strings.HasPrefix(toString(left), toString(right)), nil
+	case "endswith":
+		return left != nil && strings.HasSuffix(toString(left), toString(right)), nil
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	}
+
+	// Ordering compares numerically when both sides are numbers and falls
+	// back to string ordering otherwise; null never orders.
+	if left == nil || right == nil {
+		return false, nil
+	}
+	var cmp int
+	l, lok := toNumber(left)
+	r, rok := toNumber(right)
+	if lok && rok {
+		switch {
+		case l < r:
+			cmp = -1
+		case l > r:
+			cmp = 1
+		}
+	} else {
+		cmp = strings.Compare(toString(left), toString(right))
+	}
+
+	switch e.op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+type callExpr struct {
+	name string
+	fn   function
+	args []expr
+}
+
+func (e callExpr) eval(entry *models.Log) (interface{}, error) {
+	args := make([]interface{}, len(e.args))
+	for i, arg := range e.args {
+		value, err := arg.eval(entry)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+	}
+
+	value, err := e.fn.call(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", e.name, err)
+	}
+	return value, nil
+}
+
+// function is a built-in; maxArgs of -1 means variadic
+type function struct {
+	minArgs, maxArgs int
+	call             func(args []interface{}) (interface{}, error)
+}
+
+func (f function) arity() string {
+	switch {
+	case f.maxArgs < 0:
+		return fmt.Sprintf("at least %d arguments", f.minArgs)
+	case f.minArgs == f.maxArgs && f.minArgs == 1:
+		return "1 argument"
+	case f.minArgs == f.maxArgs:
+		return fmt.Sprintf("%d arguments", f.minArgs)
+	default:
+		return fmt.Sprintf("%d to %d arguments", f.minArgs, f.maxArgs)
+	}
+}
+
+var functions = map[string]function{
+	"upper": {1, 1, func(args []interface{}) (interface{}, error) {
+		return strings.ToUpper(toString(args[0])), nil
+	}},
+	"lower": {1, 1, func(args []interface{}) (interface{}, error) {
+		return strings.ToLower(toString(args[0])), nil
+	}},
+	"trim": {1, 1, func(args []interface{}) (interface{}, error) {
+		return strings.TrimSpace(toString(args[0])), nil
+	}},
+	"len": {1, 1, func(args []interface{}) (interface{}, error) {
+		return int64(len([]rune(toString(args[0])))), nil
+	}},
+	"replace": {3, 3, func(args []interface{}) (interface{}, error) {
+		return strings.ReplaceAll(toString(args[0]), toString(args[1]), toString(args[2])), nil
+	}},
+	"int": {1, 1, func(args []interface{}) (interface{}, error) {
+		if n, ok := toNumber(args[0]); ok {
+			return int64(n), nil
+		}
+		return strconv.ParseInt(strings.TrimSpace(toString(args[0])), 10, 64)
+	}},
+	"float": {1, 1, func(args []interface{}) (interface{}, error) {
+		if n, ok := toNumber(args[0]); ok {
+			return n, nil
+		}
+		return strconv.ParseFloat(strings.TrimSpace(toString(args[0])), 64)
+	}},
+	"string": {1, 1, func(args []interface{}) (interface{}, error) {
+		return toString(args[0]), nil
+	}},
+	"exists": {1, 1, func(args []interface{}) (interface{}, error) {
+		return args[0] != nil, nil
+	}},
+	"coalesce": {1, -1, func(args []interface{}) (interface{}, error) {
+		for _, arg := range args {
+			if arg != nil && arg != "" {
+				return arg, nil
+			}
+		}
+		return nil, nil
+	}},
+}
+
+// toNumber converts numeric values, including those decoded from JSON
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func equal(left, right interface{}) bool {
+	if left == nil || right == nil {
+		return left == nil && right == nil
+	}
+	if l, ok := toNumber(left); ok {
+		if r, ok := toNumber(right); ok {
+			return l == r
+		}
+	}
+	if l, ok := left.(bool); ok {
+		r, ok := right.(bool)
+		return ok && l == r
+	}
+	return toString(left) == toString(right)
+}
+
+func truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	}
+	if n, ok := toNumber(value); ok {
+		return n != 0
+	}
+	return true
+}
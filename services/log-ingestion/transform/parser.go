@@ -0,0 +1,349 @@
+package transform
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"log-processing-system/services/log-ingestion/lexer"
+)
+
+// operators are matched longest first
+var operators = []string{"==", "!=", "<=", ">=", "<", ">", "=", "+", "(", ")", ","}
+
+type parser struct {
+	tokens []lexer.Token
+	pos    int
+}
+
+func (p *parser) peek() lexer.Token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() lexer.Token {
+	tok := p.tokens[p.pos]
+	if tok.Kind != lexer.EOF {
+		p.pos++
+	}
+	return tok
+}
+
+// accept consumes the next token if it is the given keyword or operator
+func (p *parser) accept(text string) bool {
+	tok := p.peek()
+	if (tok.Kind == lexer.Ident || tok.Kind == lexer.Op) && tok.Text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	if !p.accept(text) {
+		return p.errorf("expected %q", text)
+	}
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	tok := p.peek()
+	found := tok.Text
+	if tok.Kind == lexer.EOF {
+		found = "end of line"
+	}
+	return fmt.Errorf("column %d: %s, found %q", tok.Col, fmt.Sprintf(format, args...), found)
+}
+
+// parseStatement parses one line: an action optionally followed by "if <condition>"
+func (p *parser) parseStatement() (*statement, error) {
+	tok := p.next()
+	if tok.Kind != lexer.Ident {
+		p.pos--
+		return nil, p.errorf("expected an action")
+	}
+
+	var (
+		act action
+		err error
+	)
+	switch tok.Text {
+	case "set":
+		act, err = p.parseSet()
+	case "rename":
+		act, err = p.parseRename()
+	case "delete":
+		act, err = p.parseDelete()
+	case "truncate":
+		act, err = p.parseTruncate()
+	case "drop":
+		act = dropAction{}
+	default:
+		p.pos--
+		return nil, p.errorf("unknown action")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := &statement{action: act}
+	if p.accept("if") {
+		if stmt.cond, err = p.parseCondition(); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek().Kind != lexer.EOF {
+		return nil, p.errorf("unexpected token")
+	}
+	return stmt, nil
+}
+
+func (p *parser) parsePath() (path, error) {
+	tok := p.peek()
+	if tok.Kind != lexer.Path {
+		return path{}, p.errorf("expected a path such as .message")
+	}
+	p.pos++
+	return newPath(tok.Text), nil
+}
+
+func (p *parser) parseSet() (action, error) {
+	target, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("="); err != nil {
+		return nil, err
+	}
+	value, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	return setAction{target: target, value: value}, nil
+}
+
+func (p *parser) parseRename() (action, error) {
+	from, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("to"); err != nil {
+		return nil, err
+	}
+	to, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	if !from.field {
+		return nil, fmt.Errorf("cannot rename built-in attribute .%s", from.name)
+	}
+	return renameAction{from: from, to: to}, nil
+}
+
+func (p *parser) parseDelete() (action, error) {
+	target, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	if !target.field {
+		return nil, fmt.Errorf("cannot delete built-in attribute .%s", target.name)
+	}
+	return deleteAction{target: target}, nil
+}
+
+func (p *parser) parseTruncate() (action, error) {
+	target, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	tok := p.peek()
+	if tok.Kind != lexer.Number {
+		return nil, p.errorf("expected a maximum length")
+	}
+	p.pos++
+	n, err := strconv.Atoi(tok.Text)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("column %d: invalid length %q", tok.Col, tok.Text)
+	}
+	return truncateAction{target: target, max: n}, nil
+}
+
+// parseCondition parses "or" chains of "and" chains of optionally negated comparisons
+func (p *parser) parseCondition() (expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalExpr{op: "or", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalExpr{op: "and", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (expr, error) {
+	if p.accept("not") {
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{inner: inner}, nil
+	}
+	return p.parseComparison()
+}
+
+var comparisonOps = map[string]bool{
+	"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true,
+	"contains": true, "matches": true, "startswith": true, "endswith": true,
+}
+
+func (p *parser) parseComparison() (expr, error) {
+	left, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	tok := p.peek()
+	if (tok.Kind != lexer.Op && tok.Kind != lexer.Ident) || !comparisonOps[tok.Text] {
+		return left, nil
+	}
+	p.pos++
+
+	right, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	cmp := compareExpr{op: tok.Text, left: left, right: right}
+	if tok.Text == "matches" {
+		lit, ok := right.(literal)
+		pattern, isString := lit.value.(string)
+		if !ok || !isString {
+			return nil, fmt.Errorf("column %d: matches requires a string literal pattern", tok.Col)
+		}
+		if cmp.re, err = regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("column %d: invalid pattern: %v", tok.Col, err)
+		}
+	}
+	return cmp, nil
+}
+
+// parseExpr parses values joined by "+"
+func (p *parser) parseExpr() (expr, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("+") {
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = addExpr{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseTerm() (expr, error) {
+	tok := p.peek()
+
+	switch tok.Kind {
+	case lexer.String:
+		p.pos++
+		return literal{value: tok.Text}, nil
+
+	case lexer.Number:
+		p.pos++
+		if !strings.Contains(tok.Text, ".") {
+			if n, err := strconv.ParseInt(tok.Text, 10, 64); err == nil {
+				return literal{value: n}, nil
+			}
+		}
+		f, err := strconv.ParseFloat(tok.Text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("column %d: invalid number %q", tok.Col, tok.Text)
+		}
+		return literal{value: f}, nil
+
+	case lexer.Path:
+		p.pos++
+		return pathExpr{path: newPath(tok.Text)}, nil
+
+	case lexer.Op:
+		if tok.Text == "(" {
+			p.pos++
+			inner, err := p.parseCondition()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return inner, nil
+		}
+
+	case lexer.Ident:
+		switch tok.Text {
+		case "true", "false":
+			p.pos++
+			return literal{value: tok.Text == "true"}, nil
+		case "null":
+			p.pos++
+			return literal{value: nil}, nil
+		}
+		return p.parseCall()
+	}
+
+	return nil, p.errorf("expected a value")
+}
+
+func (p *parser) parseCall() (expr, error) {
+	tok := p.next()
+	fn, ok := functions[tok.Text]
+	if !ok {
+		p.pos--
+		return nil, p.errorf("unknown function")
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	var args []expr
+	if !p.accept(")") {
+		for {
+			arg, err := p.parseCondition()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if p.accept(")") {
+				break
+			}
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		return nil, fmt.Errorf("column %d: %s takes %s", tok.Col, tok.Text, fn.arity())
+	}
+	return callExpr{name: tok.Text, fn: fn, args: args}, nil
+}
@@ -0,0 +1,229 @@
+// Package transform implements a small line-oriented language for rewriting
+// log entries. Each non-empty line is one statement:
+//
+//	set .service = "checkout"
+//	rename .msg to .message_text
+//	set .level = "error" if .status >= 500
+//	truncate .message 1024
+//	delete .password
+//	drop if .path == "/health"
+//
+// Paths starting with a dot refer to .message, .level, .source and
+// .timestamp, or to a structured field for any other name. Use
+// .fields.<name> to reach a field that shadows a built-in attribute.
+package transform
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+	"log-processing-system/services/log-ingestion/lexer"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+// Program is a compiled list of statements, safe for concurrent use
+type Program struct {
+	statements []*statement
+	source     string
+}
+
+type statement struct {
+	action action
+	cond   expr
+	line   int
+}
+
+// Compile parses a program; errors carry the offending line number
+func Compile(source string) (*Program, error) {
+	program := &Program{source: source}
+	tokens, err := lexer.Tokenize(source, operators)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each line is parsed on its own, ending at its newline
+	for len(tokens) > 1 {
+		end := 0
+		for tokens[end].Kind != lexer.Newline && tokens[end].Kind != lexer.EOF {
+			end++
+		}
+		line := append(tokens[:end:end], lexer.Token{Kind: lexer.EOF, Line: tokens[end].Line, Col: tokens[end].Col})
+		tokens = tokens[end:]
+		if tokens[0].Kind == lexer.Newline {
+			tokens = tokens[1:]
+		}
+		if len(line) == 1 {
+			continue
+		}
+
+		p := &parser{tokens: line}
+		stmt, err := p.parseStatement()
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line[0].Line, err)
+		}
+		stmt.line = line[0].Line
+		program.statements = append(program.statements, stmt)
+	}
+
+	return program, nil
+}
+
+// Source returns the text the program was compiled from
+func (p *Program) Source() string {
+	return p.source
+}
+
+// Len returns the number of statements in the program
+func (p *Program) Len() int {
+	return len(p.statements)
+}
+
+// Apply runs every statement against the entry in order. It returns false
+// when a drop statement fired and the entry should be discarded.
+func (p *Program) Apply(entry *models.Log) (bool, error) {
+	for _, stmt := range p.statements {
+		if stmt.cond != nil {
+			value, err := stmt.cond.eval(entry)
+			if err != nil {
+				return false, fmt.Errorf("line %d: %w", stmt.line, err)
+			}
+			if !truthy(value) {
+				continue
+			}
+		}
+
+		keep, err := stmt.action.apply(entry)
+		if err != nil {
+			return false, fmt.Errorf("line %d: %w", stmt.line, err)
+		}
+		if !keep {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// path addresses a built-in attribute or a structured field
+type path struct {
+	name  string
+	field bool
+}
+
+func newPath(text string) path {
+	switch text {
+	case "message", "level", "source", "timestamp":
+		return path{name: text}
+	}
+	return path{name: strings.TrimPrefix(text, "fields."), field: true}
+}
+
+func (p path) get(entry *models.Log) (interface{}, bool) {
+	if p.field {
+		value, ok := entry.Fields[p.name]
+		return value, ok
+	}
+
+	switch p.name {
+	case "message":
+		return entry.Message, true
+	case "level":
+		return entry.Level, true
+	case "source":
+		return entry.Source, true
+	default:
+		if entry.Timestamp.IsZero() {
+			return nil, false
+		}
+		return entry.Timestamp.Format(time.RFC3339Nano), true
+	}
+}
+
+func (p path) set(entry *models.Log, value interface{}) error {
+	if p.field {
+		if entry.Fields == nil {
+			entry.Fields = models.Fields{}
+		}
+		entry.Fields[p.name] = value
+		return nil
+	}
+
+	switch p.name {
+	case "message":
+		entry.Message = toString(value)
+	case "level":
+		entry.Level = toString(value)
+	case "source":
+		entry.Source = toString(value)
+	default:
+		ts, err := time.Parse(time.RFC3339Nano, toString(value))
+		if err != nil {
+			return fmt.Errorf("cannot set .timestamp: %v", err)
+		}
+		entry.Timestamp = ts
+	}
+	return nil
+}
+
+type action interface {
+	apply(entry *models.Log) (bool, error)
+}
+
+type setAction struct {
+	target path
+	value  expr
+}
+
+func (a setAction) apply(entry *models.Log) (bool, error) {
+	value, err := a.value.eval(entry)
+	if err != nil {
+		return false, err
+	}
+	return true, a.target.set(entry, value)
+}
+
+type renameAction struct {
+	from path
+	to   path
+}
+
+func (a renameAction) apply(entry *models.Log) (bool, error) {
+	value, ok := a.from.get(entry)
+	if !ok {
+		return true, nil
+	}
+	delete(entry.Fields, a.from.name)
+	return true, a.to.set(entry, value)
+}
+
+type deleteAction struct {
+	target path
+}
+
+func (a deleteAction) apply(entry *models.Log) (bool, error) {
+	delete(entry.Fields, a.target.name)
+	return true, nil
+}
+
+type truncateAction struct {
+	target path
+	max    int
+}
+
+func (a truncateAction) apply(entry *models.Log) (bool, error) {
+	value, ok := a.target.get(entry)
+	if !ok {
+		return true, nil
+	}
+	s, ok := value.(string)
+	if !ok || utf8.RuneCountInString(s) <= a.max {
+		return true, nil
+	}
+	return true, a.target.set(entry, string([]rune(s)[:a.max]))
+}
+
+type dropAction struct{}
+
+func (dropAction) apply(entry *models.Log) (bool, error) {
+	return false, nil
+}
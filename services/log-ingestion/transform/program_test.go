@@ -0,0 +1,190 @@
+package transform
+
+import (
+	"strings"
+	"testing"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func apply(t *testing.T, source string, entry *models.Log) bool {
+	program, err := Compile(source)
+	if err != nil {
+		t.Fatalf("Failed to compile %q: %v", source, err)
+	}
+	keep, err := program.Apply(entry)
+	if err != nil {
+		t.Fatalf("Failed to apply %q: %v", source, err)
+	}
+	return keep
+}
+
+func TestApply_Statements(t *testing.T) {
+	entry := &models.Log{
+		Message: "GET /checkout failed",
+		Level:   "info",
+		Source:  "web",
+		Fields:  models.Fields{"msg": "raw", "status": float64(503), "password": "hunter2"},
+	}
+
+	keep := apply(t, `
+		# normalise the entry
+		rename .msg to .original
+		set .service = "checkout-" + .source
+		set .level = "error" if .status >= 500 and .message contains "failed"
+		set .status_text = "HTTP " + string(.status)
+		delete .password
+		truncate .message 11
+	`, entry)
+
+	if !keep {
+		t.Fatalf("Expected entry to be kept")
+	}
+	if _, ok := entry.Fields["msg"]; ok {
+		t.Errorf("Expected msg to be renamed away")
+	}
+	if entry.Fields["original"] != "raw" {
+		t.Errorf("Expected original raw, got %v", entry.Fields["original"])
+	}
+	if entry.Fields["service"] != "checkout-web" {
+		t.Errorf("Expected service checkout-web, got %v", entry.Fields["service"])
+	}
+	if entry.Level != "error" {
+		t.Errorf("Expected level error, got %s", entry.Level)
+	}
+	if entry.Fields["status_text"] != "HTTP 503" {
+		t.Errorf("Expected status_text HTTP 503, got %v", entry.Fields["status_text"])
+	}
+	if _, ok := entry.Fields["password"]; ok {
+		t.Errorf("Expected password to be deleted")
+	}
+	if entry.Message != "GET /checko" {
+		t.Errorf("Expected truncated message, got %q", entry.Message)
+	}
+}
+
+func TestApply_Conditions(t *testing.T) {
+	tests := []struct {
+		cond     string
+		expected bool
+	}{
+		{`.status == 200`, true},
+		{`.status != 200`, false},
+		{`.status >= 200 and .status < 300`, true},
+		{`.status > 500 or .source == "api"`, true},
+		{`not .source == "api"`, false},
+		{`.path matches "^/health"`, true},
+		{`.path startswith "/api"`, false},
+		{`.path endswith "live"`, true},
+		{`.missing == null`, true},
+		{`.missing > 1`, false},
+		{`exists(.missing)`, false},
+		{`len(.path) == 12`, true},
+		{`upper(.source) == "API"`, true},
+		{`(.status == 404 or .status == 200) and not exists(.missing)`, true},
+		{`.flag`, true},
+	}
+
+	for _, tt := range tests {
+		entry := &models.Log{
+			Message: "ok",
+			Level:   "info",
+			Source:  "api",
+			Fields:  models.Fields{"status": float64(200), "path": "/health/live", "flag": true},
+		}
+		kept := apply(t, "drop if "+tt.cond, entry)
+		if kept == tt.expected {
+			t.Errorf("Condition %q: expected %v, got %v", tt.cond, tt.expected, !kept)
+		}
+	}
+}
+
+func TestApply_Functions(t *testing.T) {
+	entry := &models.Log{
+		Message: "  padded  ",
+		Fields:  models.Fields{"count": "42", "empty": ""},
+	}
+
+	apply(t, `
+		set .trimmed = trim(.message)
+		set .count = int(.count) + 1
+		set .ratio = float("0.5")
+		set .path = replace("/a/b", "/", ".")
+		set .fallback = coalesce(.empty, .missing, "default")
+		set .label = "n=" + string(.count)
+	`, entry)
+
+	expected := map[string]interface{}{
+		"trimmed":  "padded",
+		"count":    int64(43),
+		"ratio":    0.5,
+		"path":     ".a.b",
+		"fallback": "default",
+		"label":    "n=43",
+	}
+	for field, want := range expected {
+		if entry.Fields[field] != want {
+			t.Errorf("Expected %s %v (%T), got %v (%T)", field, want, want, entry.Fields[field], entry.Fields[field])
+		}
+	}
+}
+
+func TestApply_BuiltinsAndFieldsPrefix(t *testing.T) {
+	entry := &models.Log{Message: "body", Fields: models.Fields{"message": "inner"}}
+
+	apply(t, `
+		set .source = .fields.message
+		set .timestamp = "2024-01-02T03:04:05Z"
+	`, entry)
+
+	if entry.Source != "inner" {
+		t.Errorf("Expected source inner, got %s", entry.Source)
+	}
+	if entry.Timestamp.Year() != 2024 || entry.Timestamp.Hour() != 3 {
+		t.Errorf("Expected parsed timestamp, got %v", entry.Timestamp)
+	}
+	if entry.Message != "body" {
+		t.Errorf("Expected message untouched, got %s", entry.Message)
+	}
+}
+
+func TestApply_RuntimeError(t *testing.T) {
+	program, err := Compile("set .n = int(.value)")
+	if err != nil {
+		t.Fatalf("Failed to compile: %v", err)
+	}
+	_, err = program.Apply(&models.Log{Fields: models.Fields{"value": "abc"}})
+	if err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("Expected line-numbered runtime error, got %v", err)
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	tests := []struct {
+		source  string
+		message string
+	}{
+		{`set .a "x"`, `expected "="`},
+		{`upsert .a = 1`, "unknown action"},
+		{`rename .message to .body`, "cannot rename built-in"},
+		{`delete .level`, "cannot delete built-in"},
+		{`truncate .message`, "expected a maximum length"},
+		{`set .a = nope(.b)`, "unknown function"},
+		{`set .a = upper(.b, .c)`, "upper takes 1 argument"},
+		{`drop if .a matches .b`, "string literal pattern"},
+		{`drop if .a matches "("`, "invalid pattern"},
+		{`set .a = "unterminated`, "unterminated string"},
+		{"set .a = 1\nset .b = ", "line 2"},
+		{`drop if .a == 1 extra`, "unexpected token"},
+	}
+
+	for _, tt := range tests {
+		_, err := Compile(tt.source)
+		if err == nil {
+			t.Errorf("Expected error compiling %q", tt.source)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("Compiling %q: expected error containing %q, got %v", tt.source, tt.message, err)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// Package writequeue stores accepted entries in the background, so that
+// ingestion answers 202 Accepted as soon as an entry is validated instead of
+// after the database stored it. Entries wait in a bounded in-memory queue
+// that a fixed number of workers store from; entries still queued when the
+// process dies are lost, as with any unacknowledged request.
+package writequeue
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+	"log-processing-system/services/log-ingestion/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// ErrFull is returned by Enqueue when the queue holds Size entries
+	ErrFull = errors.New("write queue is full")
+	// ErrClosed is returned by Enqueue once Close was called
+	ErrClosed = errors.New("write queue is closed")
+)
+
+var (
+	depthGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "ingest_write_queue_depth",
+		Help: "Accepted log entries waiting in the write queue to be stored",
+	})
+	capacityGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "ingest_write_queue_capacity",
+		Help: "Log entries the write queue holds before it is full",
+	})
+	fullCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "ingest_write_queue_full_total",
+		Help: "Log entries that found the write queue full",
+	})
+	failuresCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "ingest_write_failures_total",
+		Help: "Queued log entries that could not be stored, whether dead-lettered or lost",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(depthGauge, capacityGauge, fullCounter, failuresCounter)
+}
+
+// Config sizes the queue; zero values fall back to defaults
+type Config struct {
+	Size    int // entries queued before Enqueue fails with ErrFull, 10000 by default
+	Workers int // entries stored concurrently, 4 by default
+	// HighWater is the share of Size from which the queue is saturated and
+	// new requests should be refused, leaving room for the entries of
+	// requests already admitted; 0.9 by default
+	HighWater float64
+	// RetryAfter is how long refused clients are asked to wait, 5s by default
+	RetryAfter time.Duration
+}
+
+// StoreFunc stores a queued entry. ctx carries the values of the context
+// the entry was queued with, but not its cancellation or deadline.
+type StoreFunc func(ctx context.Context, entry *models.Log) error
+
+type item struct {
+	ctx   context.Context
+	entry *models.Log
+}
+
+// Queue stores entries with a StoreFunc from worker goroutines
+type Queue struct {
+	store      StoreFunc
+	items      chan item
+	highWater  int
+	retryAfter time.Duration
+	wg         sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// New creates a queue and starts its workers
+func New(cfg Config, store StoreFunc) *Queue {
+	if cfg.Size <= 0 {
+		cfg.Size = 10000
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 4
+	}
+	if cfg.HighWater <= 0 || cfg.HighWater > 1 {
+		cfg.HighWater = 0.9
+	}
+	if cfg.RetryAfter <= 0 {
+		cfg.RetryAfter = 5 * time.Second
+	}
+	q := &Queue{
+		store:      store,
+		items:      make(chan item, cfg.Size),
+		highWater:  int(math.Ceil(cfg.HighWater * float64(cfg.Size))),
+		retryAfter: cfg.RetryAfter,
+	}
+	capacityGauge.Set(float64(cfg.Size))
+	q.wg.Add(cfg.Workers)
+	for i := 0; i < cfg.Workers; i++ {
+		go q.work()
+	}
+	return q
+}
+
+// Enqueue queues entry to be stored without waiting. The request that
+// submitted it may end before it is stored, so only the values of ctx, such
+// as the request ID and receive time, are kept.
+func (q *Queue) Enqueue(ctx context.Context, entry *models.Log) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return ErrClosed
+	}
+	select {
+	case q.items <- item{ctx: detached{ctx}, entry: entry}:
+		depthGauge.Inc()
+		return nil
+	default:
+		fullCounter.Inc()
+		return ErrFull
+	}
+}
+
+// Depth returns how many entries wait to be stored
+func (q *Queue) Depth() int {
+	return len(q.items)
+}
+
+// Capacity returns how many entries the queue holds before it is full
+func (q *Queue) Capacity() int {
+	return cap(q.items)
+}
+
+// Saturated reports whether the queue reached its high-water mark, so that
+// storing falls behind and new requests should be refused until it drains
+func (q *Queue) Saturated() bool {
+	return len(q.items) >= q.highWater
+}
+
+// RetryAfter returns how long clients refused while the queue is saturated
+// should wait
+func (q *Queue) RetryAfter() time.Duration {
+	return q.retryAfter
+}
+
+// Close stops accepting entries and waits until the queued ones are
+// stored. When ctx is done first it returns ctx.Err(), and the entries still
+// queued are lost.
+func (q *Queue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.items)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+	for it := range q.items {
+		depthGauge.Dec()
+		if err := q.store(it.ctx, it.entry); err != nil {
+			failuresCounter.Inc()
+		}
+	}
+}
+
+// detached keeps the values of a context without its cancellation
+type detached struct {
+	context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detached) Done() <-chan struct{}       { return nil }
+func (detached) Err() error                  { return nil }
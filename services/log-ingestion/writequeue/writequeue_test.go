@@ -0,0 +1,72 @@
+package writequeue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/models"
+)
+
+func TestQueue_StoresAfterTheRequest(t *testing.T) {
+	var mu sync.Mutex
+	var stored []string
+	release := make(chan struct{})
+	q := New(Config{Size: 2, Workers: 1}, func(ctx context.Context, entry *models.Log) error {
+		<-release
+		if ctx.Err() != nil || logger.GetRequestID(ctx) != "req-1" {
+			t.Errorf("Expected the request's values without its cancellation, got %v, %q", ctx.Err(), logger.GetRequestID(ctx))
+		}
+		mu.Lock()
+		stored = append(stored, entry.Message)
+		mu.Unlock()
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(logger.WithRequestID(context.Background(), "req-1"))
+	for _, message := range []string{"first", "second", "third"} {
+		if err := q.Enqueue(ctx, &models.Log{Message: message}); err != nil {
+			t.Fatalf("Failed to queue %q: %v", message, err)
+		}
+		// The worker holds the first entry, the queue the others
+		for message == "first" && q.Depth() != 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	if err := q.Enqueue(ctx, &models.Log{Message: "fourth"}); !errors.Is(err, ErrFull) {
+		t.Errorf("Expected ErrFull with %d of %d entries queued, got %v", q.Depth(), q.Capacity(), err)
+	}
+	// The requests are done before their entries are stored
+	cancel()
+	close(release)
+
+	if err := q.Close(context.Background()); err != nil {
+		t.Fatalf("Failed to drain the queue: %v", err)
+	}
+	if len(stored) != 3 || stored[0] != "first" || stored[2] != "third" {
+		t.Errorf("Expected the queued entries stored in order, got %v", stored)
+	}
+	if err := q.Enqueue(context.Background(), &models.Log{Message: "late"}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed after Close, got %v", err)
+	}
+}
+
+func TestQueue_CloseGivesUpAtTheDeadline(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	q := New(Config{Size: 1, Workers: 1}, func(ctx context.Context, entry *models.Log) error {
+		<-release
+		return nil
+	})
+	if err := q.Enqueue(context.Background(), &models.Log{Message: "stuck"}); err != nil {
+		t.Fatalf("Failed to queue: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := q.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline to end the drain, got %v", err)
+	}
+}
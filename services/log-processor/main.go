@@ -0,0 +1,341 @@
+// Command log-processor consumes raw log entries that the ingestion service
+// published to Kafka or Redis Streams, runs them through the processing
+// pipeline and stores the results, so heavy enrichment scales independently
+// of the HTTP ingestion tier. Run as many instances as needed; they share
+// the work through the consumer group.
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "net/http"
+    "os"
+    "os/signal"
+    "syscall"
+    "time"
+    "log-processing-system/services/log-ingestion/config"
+    "log-processing-system/services/log-ingestion/crash"
+    "log-processing-system/services/log-ingestion/database"
+    "log-processing-system/services/log-ingestion/deadletter"
+    "log-processing-system/services/log-ingestion/features"
+    "log-processing-system/services/log-ingestion/health"
+    "log-processing-system/services/log-ingestion/logger"
+    "log-processing-system/services/log-ingestion/pipeline"
+    "log-processing-system/services/log-ingestion/profiling"
+    "log-processing-system/services/log-ingestion/queue"
+    "log-processing-system/services/log-ingestion/sdnotify"
+    "log-processing-system/services/log-ingestion/shutdown"
+    "github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func main() {
+    // Flags override every other configuration source; parsed before the
+    // logger is created so that -log-level applies to it
+    flags, err := config.ParseFlags("log-processor", "PROCESSOR_PORT", os.Args[1:])
+    if err != nil {
+        if err == flag.ErrHelp {
+            os.Exit(0)
+        }
+        os.Exit(2)
+    }
+
+    appLogger := logger.NewFromEnv("log-processor", "main")
+    // Deferred first so it runs last: writes out buffered entries, including
+    // those logged during shutdown, and closes the log outputs
+    defer appLogger.Close()
+
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+
+    cfg, err := config.LoadConfig()
+    if err != nil {
+        appLogger.WithError(err).Fatal("Failed to load configuration")
+    }
+
+    // -print-config shows what this instance would run with, and stops
+    if flags.PrintConfig {
+        encoder := json.NewEncoder(os.Stdout)
+        encoder.SetIndent("", "  ")
+        if err := encoder.Encode(cfg.Effective()); err != nil {
+            appLogger.WithError(err).Fatal("Failed to print configuration")
+        }
+        return
+    }
+    if cfg.Queue.Backend == "" {
+        appLogger.Fatal("QUEUE_BACKEND must be set to kafka or redis")
+    }
+
+    // Panics and fatal exits are counted, and with CRASH_REPORT_DIR set
+    // written up for postmortems
+    crashReporter, err := crash.New(crash.Config{
+        Dir:        cfg.Crash.Dir,
+        Service:    "log-processor",
+        MaxReports: cfg.Crash.MaxReports,
+        RecentLogs: cfg.Crash.RecentLogs,
+    })
+    if err != nil {
+        appLogger.WithError(err).Fatal("Failed to set up crash reports")
+    }
+    logger.OnFatal(crashReporter.Fatal)
+    features.Set(cfg.Features)
+
+    database.Tune(database.Tuning{
+        MaxOpenConns:    cfg.Database.MaxOpenConns,
+        MaxIdleConns:    cfg.Database.MaxIdleConns,
+        ConnMaxLifetime: cfg.Database.ConnMaxLifetime,
+        SlowQuery:       cfg.Database.SlowQuery,
+    })
+    if err := database.Connect(cfg.Database.URL); err != nil {
+        appLogger.WithError(err).Fatal("Failed to connect to database")
+    }
+    // Closed last when shutting down; Fatal exits without shutting down
+    logger.RegisterExitHook(database.Close)
+    // Entries go to the shard of their source, as ingestion stores them
+    if len(cfg.Database.Shards) > 0 {
+        if err := database.ConnectShards(cfg.Database.Shards); err != nil {
+            appLogger.WithError(err).Fatal("Failed to connect to log shards")
+        }
+    }
+
+    if cfg.Integrity.HashChain {
+        database.EnableHashChain(true)
+    }
+
+    p := &processor{
+        storeLog: database.StoreLog,
+        backoff:  100 * time.Millisecond,
+        logger:   appLogger.WithComponent("processor"),
+    }
+
+    pipeline.SetSessionStore(database.UpsertSessions)
+    if cfg.Pipeline.ConfigPath != "" && features.Enabled(features.Pipeline) {
+        pipelineCfg, err := pipeline.LoadConfig(cfg.Pipeline.ConfigPath)
+        if err != nil {
+            appLogger.WithError(err).Fatal("Failed to load pipeline configuration")
+        }
+        p.pipeline, err = pipeline.Build(pipelineCfg, appLogger.WithComponent("pipeline"))
+        if err != nil {
+            appLogger.WithError(err).Fatal("Failed to build processing pipeline")
+        }
+        logger.RegisterExitHook(p.pipeline.Close)
+    }
+
+    // Dead letters share the ingestion service's store, where they are
+    // inspected and replayed through its admin API
+    if cfg.DeadLetter.Enabled {
+        var store deadletter.Store
+        switch cfg.DeadLetter.Backend {
+        case "table":
+            store = deadletter.NewTableStore()
+        case "file":
+            store, err = deadletter.OpenFileStore(cfg.DeadLetter.File)
+            if err != nil {
+                appLogger.WithError(err).Fatal("Failed to open dead-letter file")
+            }
+        default:
+            appLogger.WithField("backend", cfg.DeadLetter.Backend).Fatal("Unknown dead-letter backend")
+        }
+        p.deadLetters = deadletter.NewQueue(store, cfg.DeadLetter.StoreRetries, cfg.DeadLetter.RetryBackoff, appLogger.WithComponent("deadletter"))
+    }
+
+    consumer, err := queue.NewConsumer(queue.Config{
+        Backend: cfg.Queue.Backend,
+        Addrs:   cfg.Queue.Addrs,
+        Topic:   cfg.Queue.Topic,
+        Group:   cfg.Queue.Group,
+    })
+    if err != nil {
+        appLogger.WithError(err).Fatal("Failed to create queue consumer")
+    }
+
+    // Dependencies reported by /health/details
+    healthChecker := health.NewChecker(cfg.Health.CheckTimeout, cfg.Health.CacheTTL)
+    healthChecker.Add("postgres", true, database.PingContext)
+    if pinger, ok := consumer.(queue.Pinger); ok {
+        healthChecker.Add(cfg.Queue.Backend, true, pinger.Ping)
+    }
+
+    // Health and metrics, including those of log_metric stages
+    mux := http.NewServeMux()
+    mux.Handle("/metrics", promhttp.Handler())
+    mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+        if err := database.Ping(); err != nil {
+            http.Error(w, "database connectivity issue", http.StatusServiceUnavailable)
+            return
+        }
+        w.WriteHeader(http.StatusOK)
+    })
+    mux.Handle("/health/details", health.Handler(healthChecker))
+    // 503 until the startup checks pass and again once shutting down, for
+    // readiness probes
+    readiness := health.NewReadiness()
+    mux.Handle("/readyz", readiness)
+    // Runtime profiles; the port is internal, like the metrics
+    var profilingServer *http.Server
+    if cfg.Profiling.Enabled {
+        profiling.Configure(profiling.Config{
+            BlockProfileRate:     cfg.Profiling.BlockProfileRate,
+            MutexProfileFraction: cfg.Profiling.MutexProfileFraction,
+        })
+        if cfg.Profiling.Addr != "" {
+            profilingServer = profiling.NewServer(cfg.Profiling.Addr)
+            go func() {
+                if err := profilingServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+                    appLogger.WithError(err).Error("Profiling server failed")
+                }
+            }()
+        } else {
+            mux.Handle("/debug/pprof/", profiling.Handler())
+        }
+    }
+    server := &http.Server{
+        Addr:              fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Processor.Port),
+        Handler:           mux,
+        ReadTimeout:       cfg.Server.ReadTimeout,
+        ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+        WriteTimeout:      cfg.Server.WriteTimeout,
+        IdleTimeout:       cfg.Server.IdleTimeout,
+        MaxHeaderBytes:    int(cfg.Server.MaxHeaderBytes),
+    }
+    server.SetKeepAlivesEnabled(cfg.Server.KeepAlive)
+    go func() {
+        if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+            appLogger.WithError(err).Fatal("Could not start server")
+        }
+    }()
+
+    // Closed when the timed flushes of the pipeline stop
+    pipelineDone := make(chan struct{})
+    if p.pipeline != nil && p.pipeline.HasFlushers() {
+        go func() {
+            p.pipeline.Run(ctx, cfg.Pipeline.FlushInterval, p.storeFlushed)
+            close(pipelineDone)
+        }()
+    }
+
+    // Consume once the database answers and the migrations this version
+    // needs are applied, so entries are not stored into an older schema;
+    // until then /readyz answers 503 and, with Type=notify, systemd keeps
+    // the unit activating
+    startupChecker := health.NewChecker(cfg.Health.CheckTimeout, 0)
+    startupChecker.Add("postgres", true, database.PingContext)
+    startupChecker.Add("schema", true, database.CheckSchema)
+    go sdnotify.RunWatchdog(ctx)
+
+    done := make(chan error, 1)
+    go func() {
+        if err := awaitReady(ctx, readiness, startupChecker, cfg.Health.ReadinessInterval, appLogger); err != nil {
+            // Stopped before it was ready
+            done <- nil
+            return
+        }
+
+        appLogger.WithFields(map[string]interface{}{
+            "backend": cfg.Queue.Backend,
+            "topic":   cfg.Queue.Topic,
+            "group":   cfg.Queue.Group,
+        }).Info("Starting log processor")
+
+        done <- consumer.Consume(ctx, p.handle)
+    }()
+
+    // SIGHUP re-reads the .env file and resets log levels to LOG_LEVEL and
+    // LOG_LEVELS without a restart
+    reload := make(chan os.Signal, 1)
+    signal.Notify(reload, syscall.SIGHUP)
+    go func() {
+        for range reload {
+            if err := config.ReloadEnv(); err != nil {
+                appLogger.WithError(err).Warn("Failed to reload .env file")
+            }
+            logger.ReloadLevels()
+            level, components := logger.Levels()
+            appLogger.WithFields(map[string]interface{}{
+                "level":      level,
+                "components": components,
+            }).Warn("Log levels reloaded")
+        }
+    }()
+
+    quit := make(chan os.Signal, 1)
+    signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+    select {
+    case <-quit:
+        appLogger.Info("Shutting down log processor...")
+        readiness.Stop()
+        sdnotify.Notify(sdnotify.Stopping, sdnotify.Status("Shutting down"))
+
+        // Stop consuming; the entry in progress is finished or redelivered later
+        cancel()
+        err = <-done
+    case err = <-done:
+        cancel()
+    }
+    if err != nil {
+        appLogger.WithError(err).Error("Queue consumer stopped")
+    }
+    if err := consumer.Close(); err != nil {
+        appLogger.WithError(err).Warn("Failed to close queue consumer")
+    }
+
+    // Drain within SHUTDOWN_TIMEOUT: store what was consumed, then close
+    // the database
+    shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+    defer shutdownCancel()
+    drain := shutdown.New(appLogger.WithComponent("shutdown"))
+    drain.Add("http server", server.Shutdown)
+    if profilingServer != nil {
+        drain.AddFunc("profiling server", func() { profilingServer.Close() })
+    }
+    if p.pipeline != nil {
+        drain.Add("pipeline", func(ctx context.Context) error {
+            if p.pipeline.HasFlushers() {
+                // The timed flushes stopped with the consumer
+                select {
+                case <-pipelineDone:
+                case <-ctx.Done():
+                    return ctx.Err()
+                }
+                // Store entries still held back by pipeline stages
+                if entries := p.pipeline.Flush(ctx, time.Now(), true); len(entries) > 0 {
+                    p.storeFlushed(ctx, entries)
+                }
+            }
+            // Send anything still buffered by output sinks
+            p.pipeline.Close()
+            return nil
+        })
+    }
+    drain.AddFunc("database", database.Close)
+    drain.Run(shutdownCtx)
+
+    appLogger.Info("Log processor stopped")
+}
+
+// awaitReady retries the startup checks until they pass, then marks the
+// processor ready and tells systemd, which is waiting for it with
+// Type=notify. It returns ctx's error if stopped first.
+func awaitReady(ctx context.Context, readiness *health.Readiness, checker *health.Checker, interval time.Duration, appLogger *logger.Logger) error {
+    sdnotify.Notify(sdnotify.Status("Waiting for the database"))
+    err := readiness.Await(ctx, checker, interval, func(report health.Report) {
+        for _, result := range report.Checks {
+            if result.Status == health.StatusDown {
+                appLogger.WithFields(map[string]interface{}{
+                    "check": result.Name,
+                    "error": result.Error,
+                }).Warn("Not ready yet")
+            }
+        }
+    })
+    if err != nil {
+        return err
+    }
+
+    appLogger.Info("Log processor ready")
+    if _, err := sdnotify.Notify(sdnotify.Ready, sdnotify.Status("Consuming")); err != nil {
+        appLogger.WithError(err).Warn("Failed to notify systemd")
+    }
+    return nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+	"log-processing-system/services/log-ingestion/deadletter"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/metrics"
+	"log-processing-system/services/log-ingestion/models"
+	"log-processing-system/services/log-ingestion/pipeline"
+	"log-processing-system/services/log-ingestion/queue"
+)
+
+// maxStoreBackoff caps the wait between storage attempts without a dead-letter queue
+const maxStoreBackoff = 5 * time.Second
+
+// processor runs queued entries through the pipeline and stores them.
+// Entries that fail are dead-lettered when a dead-letter queue is
+// configured; without one, storage is retried until it succeeds so the
+// queue holds the backlog instead of entries being lost.
+type processor struct {
+	pipeline    *pipeline.Pipeline
+	deadLetters *deadletter.Queue
+	storeLog    func(models.Log) error
+	backoff     time.Duration
+	logger      *logger.Logger
+}
+
+// handle processes one queued entry
+func (p *processor) handle(ctx context.Context, msg queue.Message) {
+	metrics.QueueWait(msg.Enqueued)
+	// Storing the entry observes the latency from when ingestion received it
+	ctx = metrics.WithReceived(ctx, msg.Received)
+
+	var entry models.Log
+	if err := json.Unmarshal(msg.Value, &entry); err != nil {
+		p.reject(ctx, models.DeadLetterParse, msg, err, "Failed to decode queued log entry")
+		return
+	}
+	// The system's own logs are handled without logging, which would feed
+	// back through ingestion
+	if logger.IsSelfSource(entry.Fields) {
+		ctx = logger.MarkSelfLog(ctx)
+	}
+
+	entries := []*models.Log{&entry}
+	if p.pipeline != nil {
+		processed, err := p.pipeline.Process(ctx, &entry)
+		if err != nil {
+			p.reject(ctx, models.DeadLetterPipeline, msg, err, "Log entry rejected by processing pipeline")
+			return
+		}
+		entries = processed
+	}
+
+	for _, e := range entries {
+		if err := e.Validate(); err != nil {
+			p.reject(ctx, models.DeadLetterValidation, msg, err, "Log entry validation failed")
+			return
+		}
+	}
+
+	p.store(ctx, entries)
+}
+
+// storeFlushed validates and stores entries released by pipeline stages
+// outside of a queued entry, such as summaries of grouped repeats
+func (p *processor) storeFlushed(ctx context.Context, entries []*models.Log) {
+	valid := entries[:0]
+	for _, entry := range entries {
+		if err := entry.Validate(); err != nil {
+			p.logger.WithFields(map[string]interface{}{
+				"validation_error": err.Error(),
+				"log_entry":        entry,
+			}).WarnContext(ctx, "Flushed log entry validation failed")
+			continue
+		}
+		valid = append(valid, entry)
+	}
+	p.store(ctx, valid)
+}
+
+func (p *processor) store(ctx context.Context, entries []*models.Log) {
+	for _, entry := range entries {
+		start := time.Now()
+		err := p.storeWithRetry(ctx, entry)
+		if err == nil {
+			metrics.Stored(ctx, entry.Source)
+			p.logger.WithFields(map[string]interface{}{
+				"log_level":      entry.Level,
+				"log_source":     entry.Source,
+				"db_duration_ms": time.Since(start).Milliseconds(),
+			}).DebugContext(ctx, "Log entry stored successfully")
+			continue
+		}
+
+		p.logger.WithFields(map[string]interface{}{
+			"error":     err.Error(),
+			"log_entry": entry,
+		}).ErrorContext(ctx, "Failed to store log entry in database")
+
+		// Keep the processed entry so a replay can store it directly
+		if p.deadLetters != nil {
+			payload, _ := json.Marshal(entry)
+			p.deadLetters.Add(ctx, models.DeadLetterStorage, entry.Source, payload, err)
+		}
+	}
+}
+
+// storeWithRetry stores an entry, retrying as configured on the dead-letter
+// queue, or until it succeeds or ctx is done when there is none
+func (p *processor) storeWithRetry(ctx context.Context, entry *models.Log) error {
+	store := func() error {
+		return p.storeLog(*entry)
+	}
+	if p.deadLetters != nil {
+		return p.deadLetters.Retry(ctx, store)
+	}
+
+	backoff := p.backoff
+	for {
+		err := store()
+		if err == nil {
+			return nil
+		}
+		p.logger.WithFields(map[string]interface{}{
+			"error":       err.Error(),
+			"retry_in_ms": backoff.Milliseconds(),
+		}).WarnContext(ctx, "Failed to store log entry, retrying")
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxStoreBackoff {
+			backoff = maxStoreBackoff
+		}
+	}
+}
+
+// reject records a queued entry that cannot be stored; the payload is kept
+// as queued so a replay runs it through the pipeline again
+func (p *processor) reject(ctx context.Context, reason string, msg queue.Message, err error, message string) {
+	p.logger.WithFields(map[string]interface{}{
+		"reason":     reason,
+		"error":      err.Error(),
+		"log_source": msg.Key,
+	}).WarnContext(ctx, message)
+
+	if p.deadLetters != nil {
+		p.deadLetters.Add(ctx, reason, msg.Key, msg.Value, err)
+	}
+}
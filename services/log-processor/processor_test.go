@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+	"log-processing-system/services/log-ingestion/deadletter"
+	"log-processing-system/services/log-ingestion/logger"
+	"log-processing-system/services/log-ingestion/models"
+	"log-processing-system/services/log-ingestion/pipeline"
+	"log-processing-system/services/log-ingestion/queue"
+)
+
+func testLogger() *logger.Logger {
+	return logger.New(logger.Config{Service: "test-service", Component: "processor"})
+}
+
+func newTestProcessor(t *testing.T, pipelineConfig string, storeLog func(models.Log) error) *processor {
+	var cfg pipeline.Config
+	if err := json.Unmarshal([]byte(pipelineConfig), &cfg); err != nil {
+		t.Fatalf("Invalid test config: %v", err)
+	}
+	p, err := pipeline.Build(cfg, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to build pipeline: %v", err)
+	}
+
+	store, err := deadletter.OpenFileStore(filepath.Join(t.TempDir(), "dead-letters.jsonl"))
+	if err != nil {
+		t.Fatalf("Failed to open dead-letter store: %v", err)
+	}
+
+	return &processor{
+		pipeline:    p,
+		deadLetters: deadletter.NewQueue(store, 1, time.Millisecond, testLogger()),
+		storeLog:    storeLog,
+		backoff:     time.Millisecond,
+		logger:      testLogger(),
+	}
+}
+
+func TestProcessor_Handle(t *testing.T) {
+	var stored []models.Log
+	p := newTestProcessor(t, `{"stages":[{"type":"filter","config":{"levels":["debug"]}}]}`, func(entry models.Log) error {
+		stored = append(stored, entry)
+		return nil
+	})
+
+	p.handle(context.Background(), queue.Message{Key: "api", Value: []byte(`{"message":"ok","level":"info","source":"api"}`)})
+	p.handle(context.Background(), queue.Message{Key: "api", Value: []byte(`{"message":"noise","level":"debug","source":"api"}`)})
+	p.handle(context.Background(), queue.Message{Key: "api", Value: []byte(`{"message":"bad","level":"loud","source":"api"}`)})
+	p.handle(context.Background(), queue.Message{Key: "api", Value: []byte(`{"message":`)})
+
+	if len(stored) != 1 || stored[0].Message != "ok" {
+		t.Errorf("Expected only the info entry to be stored, got %+v", stored)
+	}
+
+	letters, _ := p.deadLetters.List(models.DeadLetterFilter{})
+	if len(letters) != 2 || letters[0].Reason != models.DeadLetterValidation || letters[1].Reason != models.DeadLetterParse {
+		t.Fatalf("Expected validation and parse dead letters, got %+v", letters)
+	}
+	if letters[0].Source != "api" || letters[0].Payload != `{"message":"bad","level":"loud","source":"api"}` {
+		t.Errorf("Expected the queued payload to be kept, got %+v", letters[0])
+	}
+}
+
+func TestProcessor_StorageFailure(t *testing.T) {
+	calls := 0
+	p := newTestProcessor(t, `{"stages":[]}`, func(entry models.Log) error {
+		calls++
+		return errors.New("connection refused")
+	})
+
+	p.handle(context.Background(), queue.Message{Key: "api", Value: []byte(`{"message":"m","level":"info","source":"api"}`)})
+
+	if calls != 2 {
+		t.Errorf("Expected one retry, got %d calls", calls)
+	}
+	letters, _ := p.deadLetters.List(models.DeadLetterFilter{Reason: models.DeadLetterStorage})
+	if len(letters) != 1 || letters[0].Error != "connection refused" {
+		t.Fatalf("Expected a storage dead letter, got %+v", letters)
+	}
+	var entry models.Log
+	if err := json.Unmarshal([]byte(letters[0].Payload), &entry); err != nil || entry.Message != "m" {
+		t.Errorf("Expected the processed entry as payload, got %s", letters[0].Payload)
+	}
+}
+
+func TestProcessor_RetriesWithoutDeadLetters(t *testing.T) {
+	calls := 0
+	p := newTestProcessor(t, `{"stages":[]}`, func(entry models.Log) error {
+		if calls++; calls < 4 {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	p.deadLetters = nil
+
+	p.handle(context.Background(), queue.Message{Key: "api", Value: []byte(`{"message":"m","level":"info"}`)})
+	if calls != 4 {
+		t.Errorf("Expected storage to be retried until it succeeds, got %d calls", calls)
+	}
+}